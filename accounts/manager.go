@@ -312,6 +312,18 @@ func (am *Manager) Export(a Account, passphrase, newPassphrase string) (keyJSON
 	return encryptKey(key, newPassphrase, am.keyStore.scryptN, am.keyStore.scryptP)
 }
 
+// DeriveKey derives a private key for another use from the key of an account,
+// identified by label. The derived key is recovered along with the account and
+// reveals nothing about the account's key.
+func (am *Manager) DeriveKey(a Account, passphrase string, label string) (*ecdsa.PrivateKey, error) {
+	_, key, err := am.getDecryptedKey(a, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key.PrivateKey)
+	return crypto.ToECDSA(crypto.Keccak256(crypto.FromECDSA(key.PrivateKey), []byte(label))), nil
+}
+
 // Import stores the given encrypted JSON key into the key directory.
 func (am *Manager) Import(keyJSON []byte, passphrase, newPassphrase string) (Account, error) {
 	key, err := decryptKey(keyJSON, passphrase)
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"math"
+
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/logger/glog"
+
+	"gopkg.in/urfave/cli.v1"
+)
+
+var buildInternalTxIndexCommand = cli.Command{
+	Action: buildInternalTxIndexCmd,
+	Name:   "itxi-build",
+	Usage:  "Generate index for internal transactions by address",
+	Description: `
+	Builds an index of the value transfers made by contracts (internal transactions), by
+	re-executing the blocks with a lightweight call tracer. This requires the state of every
+	block in the range to be available, ie. an archive node.
+	The command is idempotent; it will not hurt to run multiple times on the same range.
+	If run without --start flag, the command makes use of a persistent placeholder, so you can
+	run the command on multiple occasions and pick up indexing progress where the last session
+	left off.
+	The index is stored along with the address-transaction index and served by
+	geth_getInternalTransactions when running with the '--atxi' flag. Once built up to the
+	head, it is kept up to date as blocks are imported, reorganised or rewound.
+			`,
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "start",
+			Usage: "Block number at which to begin building index",
+		},
+		cli.IntFlag{
+			Name:  "stop",
+			Usage: "Block number at which to stop building index",
+		},
+	},
+}
+
+func buildInternalTxIndexCmd(ctx *cli.Context) error {
+	ethdb.SetCacheRatio("chaindata", 0.5)
+	ethdb.SetHandleRatio("chaindata", 1)
+	ethdb.SetCacheRatio("indexes", 0.5)
+	ethdb.SetHandleRatio("indexes", 1)
+
+	var startIndex uint64 = math.MaxUint64
+	if ctx.IsSet("start") {
+		startIndex = uint64(ctx.Int("start"))
+	}
+	stopIndex := uint64(ctx.Int("stop"))
+
+	indexDB := MakeIndexDatabase(ctx)
+	if indexDB == nil {
+		glog.Fatalln("can't open index database")
+	}
+	defer indexDB.Close()
+
+	bc, chainDB := MakeChain(ctx)
+	if bc == nil || chainDB == nil {
+		glog.Fatalln("can't open chain database")
+	}
+	defer chainDB.Close()
+
+	return core.BuildInternalTxIndex(bc, chainDB, indexDB, startIndex, stopIndex)
+}
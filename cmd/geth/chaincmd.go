@@ -16,6 +16,7 @@ import (
 	"github.com/openether/ethcore/core"
 	"github.com/openether/ethcore/core/state"
 	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
 )
 
@@ -34,6 +35,42 @@ var (
 	Optional second and third arguments control the first and
 	last block to write. In this mode, the file will be appended
 	if already existing.
+
+	If the first argument is a directory, or --segment-size is set, the
+	blocks are written into the directory as files of a fixed number of
+	blocks, along with a manifest of their checksums which import
+	verifies before importing any of them.
+		`,
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "segment-size",
+				Usage: "Number of blocks per file of a segmented export",
+			},
+		},
+	}
+	archiveExportCommand = cli.Command{
+		Action: exportArchives,
+		Name:   "archive-export",
+		Usage:  `Export historical blocks into compressed archives`,
+		Description: `
+	Requires a first argument of the directory to write to.
+	Optional second and third arguments control the first and
+	last block to archive. Archives hold a fixed number of blocks
+	along with their receipts, so only complete archives are
+	written. Archives already in the directory are kept.
+
+	Archives can be verified on their own, and imported with
+	archive-import or by fast sync with --archive-dir.
+		`,
+	}
+	archiveImportCommand = cli.Command{
+		Action: importArchives,
+		Name:   "archive-import",
+		Usage:  `Import historical blocks from compressed archives`,
+		Description: `
+	Requires a first argument of the directory to read from.
+	Archives following the current head fast block are verified and
+	imported in order, as fast sync does, until one is missing.
 		`,
 	}
 	upgradedbCommand = cli.Command{
@@ -109,7 +146,12 @@ func importChain(ctx *cli.Context) error {
 	}
 	chain, chainDb := MakeChain(ctx)
 	start := time.Now()
-	err := ImportChain(chain, ctx.Args().First())
+	var err error
+	if fi, statErr := os.Stat(ctx.Args().First()); statErr == nil && fi.IsDir() {
+		err = ImportChainSegments(chain, ctx.Args().First(), mustMakeSufficientChainConfig(ctx).Network)
+	} else {
+		err = ImportChain(chain, ctx.Args().First())
+	}
 	chainDb.Close()
 	if err != nil {
 		log.Fatal("Import error: ", err)
@@ -126,7 +168,29 @@ func exportChain(ctx *cli.Context) error {
 	start := time.Now()
 
 	fp := ctx.Args().First()
-	if len(ctx.Args()) < 3 {
+	if fi, err := os.Stat(fp); (err == nil && fi.IsDir()) || ctx.IsSet("segment-size") {
+		first, last := uint64(0), chain.CurrentBlock().NumberU64()
+		if len(ctx.Args()) >= 3 {
+			var err error
+			if first, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+				log.Fatal("export paramater: ", err)
+			}
+			if last, err = strconv.ParseUint(ctx.Args().Get(2), 10, 64); err != nil {
+				log.Fatal("export paramater: ", err)
+			}
+		}
+		size := uint64(core.DefaultExportSegmentSize)
+		if ctx.IsSet("segment-size") {
+			if n := ctx.Int("segment-size"); n <= 0 {
+				log.Fatalf("malformed segment-size flag value %d", n)
+			} else {
+				size = uint64(n)
+			}
+		}
+		if err := ExportChainSegments(chain, fp, mustMakeSufficientChainConfig(ctx).Network, first, last, size); err != nil {
+			log.Fatal(err)
+		}
+	} else if len(ctx.Args()) < 3 {
 		if err := ExportChain(chain, fp); err != nil {
 			log.Fatal(err)
 		}
@@ -149,6 +213,69 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+func exportArchives(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		log.Fatal("This command requires an argument.")
+	}
+	chain, chainDb := MakeChain(ctx)
+	defer chainDb.Close()
+	start := time.Now()
+
+	first, last := uint64(0), chain.CurrentFastBlock().NumberU64()
+	if len(ctx.Args()) >= 3 {
+		var err error
+		if first, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+			log.Fatal("archive-export paramater: ", err)
+		}
+		if last, err = strconv.ParseUint(ctx.Args().Get(2), 10, 64); err != nil {
+			log.Fatal("archive-export paramater: ", err)
+		}
+	}
+	n, err := core.ExportArchives(chainDb, ctx.Args().First(), first, last)
+	if err != nil {
+		log.Fatal("Archive export error: ", err)
+	}
+	glog.V(logger.Info).Infof("Exported %d archives in %v", n, time.Since(start))
+	return nil
+}
+
+func importArchives(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		log.Fatal("This command requires an argument.")
+	}
+	chain, chainDb := MakeChain(ctx)
+	defer chainDb.Close()
+	start := time.Now()
+
+	archives, err := core.ListArchives(ctx.Args().First())
+	if err != nil {
+		log.Fatal("Archive import error: ", err)
+	}
+	n := 0
+	for {
+		next := chain.CurrentFastBlock().NumberU64() + 1
+		path, ok := archives[next-next%core.ArchiveBlocks]
+		if !ok {
+			break
+		}
+		fh, err := os.Open(path)
+		if err != nil {
+			log.Fatal("Archive import error: ", err)
+		}
+		archive, err := core.ReadArchive(fh)
+		fh.Close()
+		if err != nil {
+			log.Fatalf("Archive %s invalid: %v", path, err)
+		}
+		if err := chain.InsertArchive(archive); err != nil {
+			log.Fatalf("Archive %s import error: %v", path, err)
+		}
+		n++
+	}
+	glog.V(logger.Info).Infof("Imported %d archives in %v, head fast block #%d", n, time.Since(start), chain.CurrentFastBlock().NumberU64())
+	return nil
+}
+
 func upgradeDB(ctx *cli.Context) error {
 	glog.Infoln("Upgrading blockchain database")
 
@@ -260,6 +260,39 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 	return nil
 }
 
+// ExportChainSegments exports blocks into a directory of segment files with a manifest.
+func ExportChainSegments(blockchain *core.BlockChain, dir string, networkId int, first, last, segmentSize uint64) error {
+	glog.D(logger.Warn).Infoln("Exporting blockchain segments to", dir, "(this may take a while)...")
+	manifest, err := blockchain.ExportSegments(dir, networkId, first, last, segmentSize)
+	if err != nil {
+		return err
+	}
+	glog.D(logger.Error).Infof("Exported blocks #%d-#%d in %d segments to %s", manifest.First, manifest.Last, len(manifest.Segments), dir)
+	return nil
+}
+
+// ImportChainSegments imports a segmented chain export, once its manifest is
+// verified to match the chain and the checksums of all its segments.
+func ImportChainSegments(chain *core.BlockChain, dir string, networkId int) error {
+	glog.D(logger.Error).Infoln("Verifying blockchain segments in", dir)
+	manifest, err := core.VerifyExport(dir)
+	if err != nil {
+		return fmt.Errorf("export verification failed: %v", err)
+	}
+	if manifest.NetworkId != networkId {
+		return fmt.Errorf("export is of network %d, want %d", manifest.NetworkId, networkId)
+	}
+	if genesis := chain.Genesis().Hash(); manifest.Genesis != genesis {
+		return fmt.Errorf("export has genesis %x, want %x", manifest.Genesis, genesis)
+	}
+	for _, segment := range manifest.Segments {
+		if err := ImportChain(chain, filepath.Join(dir, segment.File)); err != nil {
+			return fmt.Errorf("segment %s: %v", segment.File, err)
+		}
+	}
+	return nil
+}
+
 func withLineBreak(s string) string {
 	return s + "\n"
 }
@@ -368,6 +401,8 @@ func formatEthConfigPretty(ethConfig *eth.Config) (s []string) {
 	ss = append(ss, printable{0, "NAT spec", ethConfig.NatSpec})
 	// SolcPath
 	ss = append(ss, printable{0, "Solc path", ethConfig.SolcPath})
+	// VyperPath
+	ss = append(ss, printable{0, "Vyper path", ethConfig.VyperPath})
 
 	// Account Manager
 	lenAccts := len(ethConfig.AccountManager.Accounts())
@@ -696,6 +731,15 @@ func startNode(ctx *cli.Context, stack *node.Node) *eth.Ethereum {
 		a.AutoMode = true
 		go core.BuildAddrTxIndex(ethereum.BlockChain(), ethereum.ChainDb(), a.Db, math.MaxUint64, math.MaxUint64, 10000)
 	}
+	if ctx.GlobalBool(DoctorFlag.Name) {
+		go func() {
+			report := ethereum.Doctor()
+			eth.LogDoctorReport(report)
+			if !report.Healthy {
+				glog.D(logger.Warn).Warnln("Doctor: some self-checks failed, see admin.doctor() for details")
+			}
+		}()
+	}
 
 	return ethereum
 }
@@ -15,19 +15,25 @@ import (
 
 	"github.com/openether/ethcore/accounts"
 	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/httpclient"
 	"github.com/openether/ethcore/core"
 	"github.com/openether/ethcore/core/state"
 	"github.com/openether/ethcore/core/types"
 	"github.com/openether/ethcore/crypto"
 	"github.com/openether/ethcore/eth"
 	"github.com/openether/ethcore/eth/downloader"
+	"github.com/openether/ethcore/eth/filters"
 	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/ethstats"
 	"github.com/openether/ethcore/event"
+	"github.com/openether/ethcore/internal/tracing"
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
 	"github.com/openether/ethcore/node"
 	"github.com/openether/ethcore/p2p/discover"
+	"github.com/openether/ethcore/p2p/distip"
 	"github.com/openether/ethcore/p2p/nat"
+	"github.com/openether/ethcore/rpc"
 
 	"gopkg.in/urfave/cli.v1"
 )
@@ -242,13 +248,18 @@ func MakeIPCPath(ctx *cli.Context) string {
 	return ctx.GlobalString(aliasableName(IPCPathFlag.Name, ctx))
 }
 
+// nodeKeyLabel identifies node keys derived from keystore accounts.
+const nodeKeyLabel = "p2p node key"
+
 // MakeNodeKey creates a node key from set command line flags, either loading it
-// from a file or as a specified hex value. If neither flags were provided, this
-// method returns nil and an emphemeral key is to be generated.
+// from a file, as a specified hex value or derived from the key of a keystore
+// account. If no flags were provided, this method returns nil and the key is
+// loaded from, or generated in, the data directory.
 func MakeNodeKey(ctx *cli.Context) *ecdsa.PrivateKey {
 	var (
-		hex  = ctx.GlobalString(aliasableName(NodeKeyHexFlag.Name, ctx))
-		file = ctx.GlobalString(aliasableName(NodeKeyFileFlag.Name, ctx))
+		hex     = ctx.GlobalString(aliasableName(NodeKeyHexFlag.Name, ctx))
+		file    = ctx.GlobalString(aliasableName(NodeKeyFileFlag.Name, ctx))
+		account = ctx.GlobalString(aliasableName(NodeKeyAccountFlag.Name, ctx))
 
 		key *ecdsa.PrivateKey
 		err error
@@ -257,6 +268,22 @@ func MakeNodeKey(ctx *cli.Context) *ecdsa.PrivateKey {
 	case file != "" && hex != "":
 		log.Fatalf("Options %q and %q are mutually exclusive", aliasableName(NodeKeyFileFlag.Name, ctx), aliasableName(NodeKeyHexFlag.Name, ctx))
 
+	case account != "" && (file != "" || hex != ""):
+		log.Fatalf("Option %q is mutually exclusive with %q and %q", aliasableName(NodeKeyAccountFlag.Name, ctx), aliasableName(NodeKeyFileFlag.Name, ctx), aliasableName(NodeKeyHexFlag.Name, ctx))
+
+	case account != "":
+		// The keystore index isn't needed for a single lookup, and opening it
+		// here would keep the account manager of the node from opening it
+		accman := newAccountManager(ctx, false)
+		acc, err := MakeAddress(accman, account)
+		if err != nil {
+			log.Fatalf("Option %q: %v", aliasableName(NodeKeyAccountFlag.Name, ctx), err)
+		}
+		password := getPassPhrase(fmt.Sprintf("Unlocking account %s to derive the node key", account), false, 0, MakePasswordList(ctx))
+		if key, err = accman.DeriveKey(acc, password, nodeKeyLabel); err != nil {
+			log.Fatalf("Option %q: %v", aliasableName(NodeKeyAccountFlag.Name, ctx), err)
+		}
+
 	case file != "":
 		f, err := os.Open(file)
 		if err != nil {
@@ -309,6 +336,15 @@ func MakeNAT(ctx *cli.Context) nat.Interface {
 	return natif
 }
 
+// MakeIPMode parses the address family selection from set command line flags.
+func MakeIPMode(ctx *cli.Context) distip.IPMode {
+	mode, err := distip.ParseIPMode(ctx.GlobalString(aliasableName(IPModeFlag.Name, ctx)))
+	if err != nil {
+		log.Fatalf("Option %s: %v", aliasableName(IPModeFlag.Name, ctx), err)
+	}
+	return mode
+}
+
 // MakeRPCModules splits input separated by a comma and trims excessive white
 // space from the substrings.
 func MakeRPCModules(input string) []string {
@@ -355,6 +391,10 @@ func MakeDatabaseHandles() int {
 
 // MakeAccountManager creates an account manager from set command line flags.
 func MakeAccountManager(ctx *cli.Context) *accounts.Manager {
+	return newAccountManager(ctx, ctx.GlobalBool(aliasableName(AccountsIndexFlag.Name, ctx)))
+}
+
+func newAccountManager(ctx *cli.Context, wantCacheDB bool) *accounts.Manager {
 	// Create the keystore crypto primitive, light if requested
 	scryptN := accounts.StandardScryptN
 	scryptP := accounts.StandardScryptP
@@ -375,7 +415,7 @@ func MakeAccountManager(ctx *cli.Context) *accounts.Manager {
 		}
 	}
 
-	m, err := accounts.NewManager(keydir, scryptN, scryptP, ctx.GlobalBool(aliasableName(AccountsIndexFlag.Name, ctx)))
+	m, err := accounts.NewManager(keydir, scryptN, scryptP, wantCacheDB)
 	if err != nil {
 		glog.Fatalf("init account manager at %q: %s", keydir, err)
 	}
@@ -482,6 +522,11 @@ func MakeSystemNode(version string, ctx *cli.Context) *node.Node {
 	}); err != nil {
 		glog.Fatalf("%v: failed to register the Ethereum service: %v", ErrStackFail, err)
 	}
+	if url := ctx.GlobalString(aliasableName(EthStatsURLFlag.Name, ctx)); url != "" {
+		if err := stack.Register(ethstats.NewService(url)); err != nil {
+			glog.Fatalf("%v: failed to register the ethstats service: %v", ErrStackFail, err)
+		}
+	}
 
 	// If --mlog enabled, configure and create mlog dir and file
 	if ctx.GlobalString(MLogFlag.Name) != "off" {
@@ -495,6 +540,35 @@ func MakeSystemNode(version string, ctx *cli.Context) *node.Node {
 	return stack
 }
 
+// tracer is the exporter of trace spans, nil unless --otlp-endpoint is set.
+var tracer *tracing.Tracer
+
+// setupTracing starts exporting trace spans if an OTLP endpoint is configured.
+func setupTracing(ctx *cli.Context) error {
+	endpoint := ctx.GlobalString(aliasableName(OTLPEndpointFlag.Name, ctx))
+	if endpoint == "" {
+		return nil
+	}
+	t, err := tracing.Enable(tracing.Config{
+		Endpoint:    endpoint,
+		ServiceName: ctx.GlobalString(aliasableName(OTLPServiceFlag.Name, ctx)),
+		SampleRate:  ctx.GlobalFloat64(aliasableName(OTLPSampleRateFlag.Name, ctx)),
+	})
+	if err != nil {
+		return fmt.Errorf("invalid tracing configuration: %v", err)
+	}
+	tracer = t
+	return nil
+}
+
+// stopTracing flushes the trace spans not yet exported.
+func stopTracing() {
+	if tracer != nil {
+		tracer.Stop()
+		tracer = nil
+	}
+}
+
 // shouldAttemptDirMigration decides based on flags if
 // should attempt to migration from old (<=3.3) directory schema to new.
 func shouldAttemptDirMigration(ctx *cli.Context) bool {
@@ -516,6 +590,7 @@ func mustMakeStackConf(ctx *cli.Context, name string, config *core.SufficientCha
 		BootstrapNodes:  config.ParsedBootstrap,
 		ListenAddr:      MakeListenAddress(ctx),
 		NAT:             MakeNAT(ctx),
+		IPMode:          MakeIPMode(ctx),
 		MaxPeers:        ctx.GlobalInt(aliasableName(MaxPeersFlag.Name, ctx)),
 		MaxPendingPeers: ctx.GlobalInt(aliasableName(MaxPendingPeersFlag.Name, ctx)),
 		IPCPath:         MakeIPCPath(ctx),
@@ -528,6 +603,41 @@ func mustMakeStackConf(ctx *cli.Context, name string, config *core.SufficientCha
 		WSOrigins:       ctx.GlobalString(aliasableName(WSAllowedOriginsFlag.Name, ctx)),
 		WSModules:       MakeRPCModules(ctx.GlobalString(aliasableName(WSApiFlag.Name, ctx))),
 	}
+	stackConf.SubscriptionBuffer = ctx.GlobalInt(aliasableName(RPCSubBufferFlag.Name, ctx))
+	policy, err := rpc.ParseSlowConsumerPolicy(ctx.GlobalString(aliasableName(RPCSubPolicyFlag.Name, ctx)))
+	if err != nil {
+		log.Fatalf("malformed %s flag value: %v", aliasableName(RPCSubPolicyFlag.Name, ctx), err)
+	}
+	stackConf.SubscriptionPolicy = policy
+	timeouts, err := rpc.ParseMethodTimeouts(ctx.GlobalString(aliasableName(RPCTimeoutsFlag.Name, ctx)))
+	if err != nil {
+		log.Fatalf("malformed %s flag value: %v", aliasableName(RPCTimeoutsFlag.Name, ctx), err)
+	}
+	stackConf.RPCTimeouts = timeouts
+	stackConf.RPCBatchLimits = rpc.BatchLimits{
+		MaxItems:        ctx.GlobalInt(aliasableName(RPCBatchItemsFlag.Name, ctx)),
+		MaxResponseSize: ctx.GlobalInt(aliasableName(RPCBatchResponseSizeFlag.Name, ctx)) * 1024 * 1024,
+		Timeout:         ctx.GlobalDuration(aliasableName(RPCBatchTimeoutFlag.Name, ctx)),
+	}
+	if limits := stackConf.RPCBatchLimits; limits.MaxItems < 0 || limits.MaxResponseSize < 0 || limits.Timeout < 0 {
+		log.Fatalf("malformed %s, %s or %s flag value", aliasableName(RPCBatchItemsFlag.Name, ctx), aliasableName(RPCBatchResponseSizeFlag.Name, ctx), aliasableName(RPCBatchTimeoutFlag.Name, ctx))
+	}
+	if limit := ctx.GlobalInt(aliasableName(MemoryLimitFlag.Name, ctx)); limit < 0 {
+		log.Fatalf("malformed %s flag value %d", aliasableName(MemoryLimitFlag.Name, ctx), limit)
+	} else {
+		stackConf.MemoryLimit = uint64(limit) * 1024 * 1024
+	}
+	stackConf.ClockCheckInterval = ctx.GlobalDuration(aliasableName(ClockCheckIntervalFlag.Name, ctx))
+	stackConf.ClockDriftThreshold = ctx.GlobalDuration(aliasableName(ClockDriftThresholdFlag.Name, ctx))
+	if stackConf.ClockCheckInterval < 0 || stackConf.ClockDriftThreshold < 0 {
+		log.Fatalf("malformed %s or %s flag value", aliasableName(ClockCheckIntervalFlag.Name, ctx), aliasableName(ClockDriftThresholdFlag.Name, ctx))
+	}
+	stackConf.GeoIPDatabase = ctx.GlobalString(aliasableName(GeoIPDatabaseFlag.Name, ctx))
+	stackConf.HandshakeTimeout = ctx.GlobalDuration(aliasableName(HandshakeTimeoutFlag.Name, ctx))
+	stackConf.FrameReadTimeout = ctx.GlobalDuration(aliasableName(FrameReadTimeoutFlag.Name, ctx))
+	if stackConf.HandshakeTimeout < 0 || stackConf.FrameReadTimeout < 0 {
+		log.Fatalf("malformed %s or %s flag value", aliasableName(HandshakeTimeoutFlag.Name, ctx), aliasableName(FrameReadTimeoutFlag.Name, ctx))
+	}
 
 	// Configure the Whisper service
 	shhEnable = ctx.GlobalBool(aliasableName(WhisperEnabledFlag.Name, ctx))
@@ -573,9 +683,14 @@ func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.C
 		DatabaseHandles:         MakeDatabaseHandles(),
 		NetworkId:               sconf.Network,
 		MaxPeers:                ctx.GlobalInt(aliasableName(MaxPeersFlag.Name, ctx)),
+		SyncMinPeers:            ctx.GlobalInt(aliasableName(SyncMinPeersFlag.Name, ctx)),
+		SyncTDMargin:            new(big.Int),
 		AccountManager:          accman,
 		NatSpec:                 ctx.GlobalBool(aliasableName(NatspecEnabledFlag.Name, ctx)),
 		DocRoot:                 ctx.GlobalString(aliasableName(DocRootFlag.Name, ctx)),
+		ArchiveDir:              ctx.GlobalString(aliasableName(ArchiveDirFlag.Name, ctx)),
+		IPFSGateway:             ctx.GlobalString(aliasableName(IPFSGatewayFlag.Name, ctx)),
+		BzzGateway:              ctx.GlobalString(aliasableName(BzzGatewayFlag.Name, ctx)),
 		GasPrice:                new(big.Int),
 		GpoMinGasPrice:          new(big.Int),
 		GpoMaxGasPrice:          new(big.Int),
@@ -584,6 +699,76 @@ func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.C
 		GpobaseStepUp:           ctx.GlobalInt(aliasableName(GpobaseStepUpFlag.Name, ctx)),
 		GpobaseCorrectionFactor: ctx.GlobalInt(aliasableName(GpobaseCorrectionFactorFlag.Name, ctx)),
 		SolcPath:                ctx.GlobalString(aliasableName(SolcPathFlag.Name, ctx)),
+		VyperPath:               ctx.GlobalString(aliasableName(VyperPathFlag.Name, ctx)),
+	}
+
+	ethConf.HTTPClient = httpclient.Config{
+		Proxy:              ctx.GlobalString(aliasableName(HTTPClientProxyFlag.Name, ctx)),
+		Timeout:            ctx.GlobalDuration(aliasableName(HTTPClientTimeoutFlag.Name, ctx)),
+		MaxResponseSize:    int64(ctx.GlobalInt(aliasableName(HTTPClientMaxSizeFlag.Name, ctx))),
+		CACertFile:         ctx.GlobalString(aliasableName(HTTPClientCACertFlag.Name, ctx)),
+		InsecureSkipVerify: ctx.GlobalBool(aliasableName(HTTPClientInsecureFlag.Name, ctx)),
+	}
+
+	ethConf.Filters = filters.Config{
+		Timeout:     ctx.GlobalDuration(aliasableName(FilterTimeoutFlag.Name, ctx)),
+		MaxPerOwner: ctx.GlobalInt(aliasableName(FilterMaxFlag.Name, ctx)),
+		BufferSize:  ctx.GlobalInt(aliasableName(FilterBufferFlag.Name, ctx)),
+	}
+	if ethConf.Filters.Timeout <= 0 || ethConf.Filters.MaxPerOwner < 0 || ethConf.Filters.BufferSize < 0 {
+		log.Fatalf("malformed %s, %s or %s flag value", aliasableName(FilterTimeoutFlag.Name, ctx), aliasableName(FilterMaxFlag.Name, ctx), aliasableName(FilterBufferFlag.Name, ctx))
+	}
+	if gasCap := ctx.GlobalInt(aliasableName(RPCGasCapFlag.Name, ctx)); gasCap < 0 {
+		log.Fatalf("malformed %s flag value %d", aliasableName(RPCGasCapFlag.Name, ctx), gasCap)
+	} else if gasCap > 0 {
+		ethConf.RPCGasCap = big.NewInt(int64(gasCap))
+	}
+	if ethConf.RPCEVMTimeout = ctx.GlobalDuration(aliasableName(RPCEVMTimeoutFlag.Name, ctx)); ethConf.RPCEVMTimeout < 0 {
+		log.Fatalf("malformed %s flag value %v", aliasableName(RPCEVMTimeoutFlag.Name, ctx), ethConf.RPCEVMTimeout)
+	}
+	if ethConf.StaleHead = ctx.GlobalDuration(aliasableName(StaleHeadFlag.Name, ctx)); ethConf.StaleHead < 0 {
+		log.Fatalf("malformed %s flag value %v", aliasableName(StaleHeadFlag.Name, ctx), ethConf.StaleHead)
+	}
+
+	if quota := ctx.GlobalInt(aliasableName(DappQuotaFlag.Name, ctx)); quota <= 0 {
+		log.Fatalf("malformed %s flag value %d", aliasableName(DappQuotaFlag.Name, ctx), quota)
+	} else {
+		ethConf.DappQuota = uint64(quota)
+	}
+
+	if windows, err := ethdb.ParseCompactionWindows(ctx.GlobalString(aliasableName(CompactionWindowsFlag.Name, ctx))); err != nil {
+		log.Fatalf("malformed %s flag value: %v", aliasableName(CompactionWindowsFlag.Name, ctx), err)
+	} else {
+		ethConf.CompactionWindows = windows
+	}
+	if ethConf.CompactionIdle = ctx.GlobalDuration(aliasableName(CompactionIdleFlag.Name, ctx)); ethConf.CompactionIdle < 0 {
+		log.Fatalf("malformed %s flag value %v", aliasableName(CompactionIdleFlag.Name, ctx), ethConf.CompactionIdle)
+	}
+	if mirrors := ctx.GlobalString(aliasableName(ArchiveMirrorsFlag.Name, ctx)); mirrors != "" {
+		for _, mirror := range strings.Split(mirrors, ",") {
+			if mirror = strings.TrimSpace(mirror); mirror != "" {
+				ethConf.ArchiveMirrors = append(ethConf.ArchiveMirrors, mirror)
+			}
+		}
+		path := ctx.GlobalString(aliasableName(ArchiveCheckpointsFlag.Name, ctx))
+		if path == "" {
+			log.Fatalf("%s flag requires %s", aliasableName(ArchiveMirrorsFlag.Name, ctx), aliasableName(ArchiveCheckpointsFlag.Name, ctx))
+		}
+		checkpoints, err := downloader.LoadArchiveCheckpoints(path)
+		if err != nil {
+			log.Fatalf("malformed %s flag value: %v", aliasableName(ArchiveCheckpointsFlag.Name, ctx), err)
+		}
+		ethConf.ArchiveCheckpoints = checkpoints
+	}
+	if limit := ctx.GlobalInt(aliasableName(TxLookupLimitFlag.Name, ctx)); limit < 0 {
+		log.Fatalf("malformed %s flag value %d", aliasableName(TxLookupLimitFlag.Name, ctx), limit)
+	} else {
+		ethConf.TxLookupLimit = uint64(limit)
+	}
+	if free := ctx.GlobalInt(aliasableName(MinFreeDiskFlag.Name, ctx)); free < 0 {
+		log.Fatalf("malformed %s flag value %d", aliasableName(MinFreeDiskFlag.Name, ctx), free)
+	} else {
+		ethConf.MinFreeDisk = uint64(free) * 1024 * 1024
 	}
 
 	if ctx.GlobalBool(aliasableName(FastSyncFlag.Name, ctx)) {
@@ -593,9 +778,67 @@ func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.C
 		ethConf.SyncMode = downloader.ForceFullSync
 	}
 
+	if _, ok := ethConf.SyncTDMargin.SetString(ctx.GlobalString(aliasableName(SyncTDMarginFlag.Name, ctx)), 0); !ok || ethConf.SyncTDMargin.Sign() < 0 {
+		log.Fatalf("malformed %s flag value %q", aliasableName(SyncTDMarginFlag.Name, ctx), ctx.GlobalString(aliasableName(SyncTDMarginFlag.Name, ctx)))
+	}
+	serveLimits := eth.DefaultServeLimits
+	if err := serveLimits.ParseRequestLimits(ctx.GlobalString(aliasableName(ServeRequestLimitsFlag.Name, ctx))); err != nil {
+		log.Fatalf("malformed %s flag value: %v", aliasableName(ServeRequestLimitsFlag.Name, ctx), err)
+	}
+	if err := serveLimits.ParseRateLimits(ctx.GlobalString(aliasableName(ServeRateLimitsFlag.Name, ctx))); err != nil {
+		log.Fatalf("malformed %s flag value: %v", aliasableName(ServeRateLimitsFlag.Name, ctx), err)
+	}
+	ethConf.ServeLimits = &serveLimits
+	ethConf.NodeDataCache = ctx.GlobalInt(aliasableName(ServeStateCacheFlag.Name, ctx))
+	ethConf.NodeDataReaders = ctx.GlobalInt(aliasableName(ServeStateReadersFlag.Name, ctx))
+	if ethConf.NodeDataCache < 0 || ethConf.NodeDataReaders <= 0 {
+		log.Fatalf("malformed %s or %s flag value", aliasableName(ServeStateCacheFlag.Name, ctx), aliasableName(ServeStateReadersFlag.Name, ctx))
+	}
+	if depth := ctx.GlobalInt(aliasableName(ForkAlertDepthFlag.Name, ctx)); depth <= 0 {
+		log.Fatalf("malformed %s flag value %d", aliasableName(ForkAlertDepthFlag.Name, ctx), depth)
+	} else {
+		ethConf.ForkAlertDepth = uint64(depth)
+	}
+	if policy, err := core.ParseUnprotectedTxPolicy(ctx.GlobalString(aliasableName(UnprotectedTxsFlag.Name, ctx))); err != nil {
+		log.Fatalf("malformed %s flag value %q", aliasableName(UnprotectedTxsFlag.Name, ctx), ctx.GlobalString(aliasableName(UnprotectedTxsFlag.Name, ctx)))
+	} else {
+		ethConf.UnprotectedTxs = policy
+	}
+	ethConf.PrivateTxs = ctx.GlobalBool(aliasableName(PrivateTxsFlag.Name, ctx))
+	for _, url := range strings.Split(ctx.GlobalString(aliasableName(PrivateTxRelaysFlag.Name, ctx)), ",") {
+		if url = strings.TrimSpace(url); url == "" {
+			continue
+		}
+		node, err := discover.ParseNode(url)
+		if err != nil {
+			log.Fatalf("malformed %s flag value %q: %v", aliasableName(PrivateTxRelaysFlag.Name, ctx), url, err)
+		}
+		ethConf.PrivateTxRelays = append(ethConf.PrivateTxRelays, node)
+	}
+	ethConf.TxOrdering = ctx.GlobalString(aliasableName(TxOrderingFlag.Name, ctx))
+	if _, err := core.GetTxOrdering(ethConf.TxOrdering); err != nil {
+		log.Fatalf("malformed %s flag value: %v", aliasableName(TxOrderingFlag.Name, ctx), err)
+	}
+	if split := ctx.GlobalString(aliasableName(PayoutSplitFlag.Name, ctx)); split != "" {
+		shares, err := eth.ParsePayoutSplit(split)
+		if err != nil {
+			log.Fatalf("malformed %s flag value: %v", aliasableName(PayoutSplitFlag.Name, ctx), err)
+		}
+		ethConf.PayoutSplit = shares
+	}
+	if registry := ctx.GlobalString(aliasableName(ENSRegistryFlag.Name, ctx)); registry != "" {
+		if !common.IsHexAddress(registry) {
+			log.Fatalf("malformed %s flag value %q", aliasableName(ENSRegistryFlag.Name, ctx), registry)
+		}
+		ethConf.ENSRegistry = common.HexToAddress(registry)
+	}
 	if _, ok := ethConf.GasPrice.SetString(ctx.GlobalString(aliasableName(GasPriceFlag.Name, ctx)), 0); !ok {
 		log.Fatalf("malformed %s flag value %q", aliasableName(GasPriceFlag.Name, ctx), ctx.GlobalString(aliasableName(GasPriceFlag.Name, ctx)))
 	}
+	ethConf.TxMinGasPrice = new(big.Int)
+	if _, ok := ethConf.TxMinGasPrice.SetString(ctx.GlobalString(aliasableName(TxPoolMinGasPriceFlag.Name, ctx)), 0); !ok {
+		log.Fatalf("malformed %s flag value %q", aliasableName(TxPoolMinGasPriceFlag.Name, ctx), ctx.GlobalString(aliasableName(TxPoolMinGasPriceFlag.Name, ctx)))
+	}
 	if _, ok := ethConf.GpoMinGasPrice.SetString(ctx.GlobalString(aliasableName(GpoMinGasPriceFlag.Name, ctx)), 0); !ok {
 		log.Fatalf("malformed %s flag value %q", aliasableName(GpoMinGasPriceFlag.Name, ctx), ctx.GlobalString(aliasableName(GpoMinGasPriceFlag.Name, ctx)))
 	}
@@ -609,6 +852,9 @@ func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.C
 		if !ctx.GlobalIsSet(aliasableName(GasPriceFlag.Name, ctx)) {
 			ethConf.GasPrice = new(big.Int)
 		}
+		if !ctx.GlobalIsSet(aliasableName(TxPoolMinGasPriceFlag.Name, ctx)) {
+			ethConf.TxMinGasPrice = new(big.Int)
+		}
 	}
 
 	return ethConf
@@ -5,12 +5,16 @@ import (
 	"runtime"
 	"strings"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/urfave/cli.v1"
 
 	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/httpclient"
 	"github.com/openether/ethcore/core"
 	"github.com/openether/ethcore/eth"
+	"github.com/openether/ethcore/eth/dappstore"
+	"github.com/openether/ethcore/eth/filters"
 	"github.com/openether/ethcore/logger/glog"
 	"github.com/openether/ethcore/rpc"
 )
@@ -38,6 +42,10 @@ var (
 		Name:  "sputnikvm",
 		Usage: "Use SputnikVM Ethereum Virtual Machine implementation",
 	}
+	DoctorFlag = cli.BoolFlag{
+		Name:  "doctor",
+		Usage: "Run the self-checks of admin.doctor() at startup and log their outcome",
+	}
 	DataDirFlag = DirectoryFlag{
 		Name:  "data-dir,datadir",
 		Usage: "Data directory for the databases and keystore",
@@ -79,11 +87,77 @@ var (
 		Usage: "Document Root for HTTPClient file scheme",
 		Value: DirectoryString{common.HomeDir()},
 	}
+	IPFSGatewayFlag = cli.StringFlag{
+		Name:  "ipfs-gateway,ipfsgateway",
+		Usage: "HTTP gateway to retrieve ipfs:// content (eg. contract metadata) from, empty to disable",
+		Value: "http://localhost:8080",
+	}
+	BzzGatewayFlag = cli.StringFlag{
+		Name:  "bzz-gateway,bzzgateway",
+		Usage: "HTTP gateway to retrieve bzz:// (Swarm) content (eg. contract metadata) from, empty to disable",
+		Value: "http://localhost:8500",
+	}
+	HTTPClientProxyFlag = cli.StringFlag{
+		Name:  "http-client-proxy,httpclientproxy",
+		Usage: "Proxy URL for fetching offchain documents (default: from environment)",
+	}
+	HTTPClientTimeoutFlag = cli.DurationFlag{
+		Name:  "http-client-timeout,httpclienttimeout",
+		Usage: "Time limit for fetching an offchain document (0 = no limit)",
+		Value: httpclient.DefaultConfig.Timeout,
+	}
+	HTTPClientMaxSizeFlag = cli.IntFlag{
+		Name:  "http-client-max-size,httpclientmaxsize",
+		Usage: "Maximum size in bytes of a fetched offchain document (0 = no limit)",
+		Value: int(httpclient.DefaultConfig.MaxResponseSize),
+	}
+	DappQuotaFlag = cli.IntFlag{
+		Name:  "dapp-quota,dappquota",
+		Usage: "Number of bytes each dapp origin can keep in the dapp key-value store",
+		Value: dappstore.DefaultQuota,
+	}
+	HTTPClientCACertFlag = cli.StringFlag{
+		Name:  "http-client-cacert,httpclientcacert",
+		Usage: "PEM file with the CA certificates to trust when fetching offchain documents (default: system)",
+	}
+	HTTPClientInsecureFlag = cli.BoolFlag{
+		Name:  "http-client-insecure,httpclientinsecure",
+		Usage: "Skip verifying server certificates when fetching offchain documents (testing only!)",
+	}
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
 		Usage: "Megabytes of memory allocated to internal caching (min 16MB / database forced)",
 		Value: 1024,
 	}
+	CompactionWindowsFlag = cli.StringFlag{
+		Name:  "db-compaction-windows",
+		Usage: "Defer chain database compactions to comma separated daily windows of local time (eg. 01:00-05:00)",
+	}
+	CompactionIdleFlag = cli.DurationFlag{
+		Name:  "db-compaction-idle",
+		Usage: "Defer chain database compactions until block import has been idle for this long (0 = disabled)",
+	}
+	TxLookupLimitFlag = cli.IntFlag{
+		Name:  "tx-lookup-limit",
+		Usage: "Number of recent blocks whose transactions are looked up by hash, older ones are unindexed (0 = all blocks)",
+	}
+	MinFreeDiskFlag = cli.IntFlag{
+		Name:  "min-free-disk",
+		Usage: "Free disk space in MB in the datadir below which block import stops until space is freed (0 = don't watch storage)",
+		Value: 1024,
+	}
+	ArchiveDirFlag = DirectoryFlag{
+		Name:  "archive-dir",
+		Usage: "Directory of block archives to import before fast syncing the rest of the chain from peers",
+	}
+	ArchiveMirrorsFlag = cli.StringFlag{
+		Name:  "archive-mirrors",
+		Usage: "Comma separated HTTPS base URLs to download the checkpointed block archives from before fast syncing from peers",
+	}
+	ArchiveCheckpointsFlag = cli.StringFlag{
+		Name:  "archive-checkpoints",
+		Usage: "JSON file mapping the first block numbers of archives to the hashes of their last blocks, to verify archives downloaded from mirrors",
+	}
 	BlockchainVersionFlag = cli.IntFlag{
 		Name:  "blockchain-version,blockchainversion",
 		Usage: "Blockchain version (integer)",
@@ -97,6 +171,39 @@ var (
 		Name:  "slow",
 		Usage: "Force full sync, even if fast sync is in progress",
 	}
+	SyncMinPeersFlag = cli.IntFlag{
+		Name:  "sync-min-peers,syncminpeers",
+		Usage: "Minimum number of peers ahead of the local chain on the same head required before switching sync targets",
+		Value: 1,
+	}
+	SyncTDMarginFlag = cli.StringFlag{
+		Name:  "sync-td-margin,synctdmargin",
+		Usage: "Minimum total difficulty lead a peer must have over the local chain to become a sync target",
+		Value: "0",
+	}
+	ServeRequestLimitsFlag = cli.StringFlag{
+		Name:  "serve-request-limits",
+		Usage: "Maximum items served to peers in a single response, as comma separated kind=count pairs (kinds: headers, bodies, receipts, nodedata)",
+	}
+	ServeRateLimitsFlag = cli.StringFlag{
+		Name:  "serve-rate-limits",
+		Usage: "Maximum items served to a single peer per second, as comma separated kind=count pairs (kinds: headers, bodies, receipts, nodedata; 0 for unlimited)",
+	}
+	ServeStateCacheFlag = cli.IntFlag{
+		Name:  "serve-state-cache",
+		Usage: "Number of state entries cached for serving fast syncing peers (0 to disable)",
+		Value: 65536,
+	}
+	ServeStateReadersFlag = cli.IntFlag{
+		Name:  "serve-state-readers",
+		Usage: "Number of state requests from fast syncing peers served from disk concurrently",
+		Value: 4,
+	}
+	ForkAlertDepthFlag = cli.IntFlag{
+		Name:  "fork-alert-depth",
+		Usage: "Number of blocks a branch competing with the local chain may grow before a fork is reported",
+		Value: 2,
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "light-kdf,lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -149,6 +256,37 @@ var (
 		Usage: "Minimal gas price to accept for mining a transactions",
 		Value: new(big.Int).Mul(big.NewInt(20), common.Shannon).String(),
 	}
+	TxOrderingFlag = cli.StringFlag{
+		Name:  "miner-tx-ordering",
+		Usage: "Order of transactions offered for inclusion in mined blocks: price (highest gas price first), nonce-fair (round robin across senders), fifo (order of arrival)",
+		Value: core.TxOrderingPrice,
+	}
+	PayoutSplitFlag = cli.StringFlag{
+		Name:  "miner-payout-split",
+		Usage: "Pay shares of the rewards of blocks credited to (unlocked) local accounts to other addresses (e.g. 0xabc…:30,0xdef…:20)",
+	}
+	TxPoolMinGasPriceFlag = cli.StringFlag{
+		Name:  "txpool-min-gas-price",
+		Usage: "Lowest gas price of transactions from the network accepted into the pool and relayed, independent of --gpo-min",
+		Value: common.Shannon.String(),
+	}
+	UnprotectedTxsFlag = cli.StringFlag{
+		Name:  "unprotected-txs,unprotectedtxs",
+		Usage: "Acceptance of transactions without EIP-155 replay protection: chain (follow chain config), all, local (accept from RPC, don't relay), none",
+		Value: "chain",
+	}
+	PrivateTxsFlag = cli.BoolFlag{
+		Name:  "private-txs,privatetxs",
+		Usage: "Keep transactions submitted over RPC from the public network, sending them to the --private-tx-relays only or holding them until released with txpool_release",
+	}
+	PrivateTxRelaysFlag = cli.StringFlag{
+		Name:  "private-tx-relays,privatetxrelays",
+		Usage: "Comma separated enode URLs of the trusted peers private transactions are sent to",
+	}
+	ENSRegistryFlag = cli.StringFlag{
+		Name:  "ens-registry,ensregistry",
+		Usage: "Address of the ENS registry to resolve names given instead of addresses in RPC calls",
+	}
 	ExtraDataFlag = cli.StringFlag{
 		Name:  "extra-data,extradata",
 		Usage: "Freeform header field set by the miner",
@@ -249,6 +387,24 @@ var (
 		Usage: "Request a stack trace at a specific logging statement (e.g. \"block.go:271\")",
 		Value: glog.GetTraceLocation(),
 	}
+	EthStatsURLFlag = cli.StringFlag{
+		Name:  "ethstats",
+		Usage: "Reporting URL of an ethstats service (nodename:secret@host:port)",
+	}
+	OTLPEndpointFlag = cli.StringFlag{
+		Name:  "otlp-endpoint",
+		Usage: "OTLP/HTTP endpoint receiving trace spans of RPC requests, block imports and syncs (e.g. http://localhost:4318/v1/traces)",
+	}
+	OTLPSampleRateFlag = cli.Float64Flag{
+		Name:  "otlp-sample-rate",
+		Usage: "Fraction of traces exported to the OTLP endpoint, between 0 and 1",
+		Value: 1,
+	}
+	OTLPServiceFlag = cli.StringFlag{
+		Name:  "otlp-service",
+		Usage: "Service name reported with the exported trace spans",
+		Value: "geth",
+	}
 	MetricsFlag = cli.StringFlag{
 		Name:  "metrics",
 		Usage: "Enables metrics reporting. When the value is a path, either relative or absolute, then a log is written to the respective file.",
@@ -322,6 +478,69 @@ var (
 		Usage: "Origins from which to accept websockets requests",
 		Value: "",
 	}
+	RPCSubBufferFlag = cli.IntFlag{
+		Name:  "rpc-sub-buffer,rpcsubbuffer",
+		Usage: "Notifications buffered per IPC/WS subscription before the slow consumer policy applies (0 = no per-subscription limit)",
+		Value: 0,
+	}
+	RPCSubPolicyFlag = cli.StringFlag{
+		Name:  "rpc-sub-policy,rpcsubpolicy",
+		Usage: `Policy for subscriptions exceeding their buffer: "disconnect" or "drop-oldest"`,
+		Value: rpc.DisconnectSlowConsumer.String(),
+	}
+	RPCTimeoutsFlag = cli.StringFlag{
+		Name:  "rpc-timeouts,rpctimeouts",
+		Usage: "Comma separated execution time limits of RPC methods overriding the defaults, e.g. eth_call=10s,eth_getLogs=0 (0 = no limit)",
+		Value: "",
+	}
+	RPCBatchItemsFlag = cli.IntFlag{
+		Name:  "rpc-batch-items",
+		Usage: "Maximum number of requests in an RPC batch (0 = no limit)",
+		Value: rpc.DefaultBatchLimits.MaxItems,
+	}
+	RPCBatchResponseSizeFlag = cli.IntFlag{
+		Name:  "rpc-batch-response-size",
+		Usage: "Response size in MB beyond which the remaining requests of an RPC batch aren't executed (0 = no limit)",
+		Value: rpc.DefaultBatchLimits.MaxResponseSize / 1024 / 1024,
+	}
+	RPCBatchTimeoutFlag = cli.DurationFlag{
+		Name:  "rpc-batch-timeout",
+		Usage: "Execution time beyond which the remaining requests of an RPC batch aren't executed (0 = no limit)",
+		Value: rpc.DefaultBatchLimits.Timeout,
+	}
+	MemoryLimitFlag = cli.IntFlag{
+		Name:  "memory-limit",
+		Usage: "Heap size in MB beyond which caches are dropped, heavy RPC requests refused and peer transactions throttled (0 = no limit)",
+	}
+	RPCGasCapFlag = cli.IntFlag{
+		Name:  "rpc-gascap,rpcgascap",
+		Usage: "Gas limit of eth_call and eth_estimateGas executions (0 = no cap)",
+		Value: 50000000,
+	}
+	RPCEVMTimeoutFlag = cli.DurationFlag{
+		Name:  "rpc-evmtimeout,rpcevmtimeout",
+		Usage: "Wall time limit of eth_call and eth_estimateGas executions (0 = no limit)",
+		Value: 5 * time.Second,
+	}
+	StaleHeadFlag = cli.DurationFlag{
+		Name:  "stale-head,stalehead",
+		Usage: "Head block age beyond which the chain is considered stalled and the HTTP-RPC readiness endpoint fails (0 = never)",
+	}
+	FilterTimeoutFlag = cli.DurationFlag{
+		Name:  "filter-timeout,filtertimeout",
+		Usage: "Uninstall filters that haven't been polled for this long",
+		Value: filters.DefaultConfig.Timeout,
+	}
+	FilterMaxFlag = cli.IntFlag{
+		Name:  "filter-max,filtermax",
+		Usage: "Maximum number of filters a single RPC client can install (0 = no limit)",
+		Value: filters.DefaultConfig.MaxPerOwner,
+	}
+	FilterBufferFlag = cli.IntFlag{
+		Name:  "filter-buffer,filterbuffer",
+		Usage: "Maximum number of changes buffered per filter between polls, oldest are dropped first (0 = no limit)",
+		Value: filters.DefaultConfig.BufferSize,
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement (only in combination with console/attach)",
@@ -362,11 +581,44 @@ var (
 		Name:  "nodekey-hex,nodekeyhex",
 		Usage: "P2P node key as hex (for testing)",
 	}
+	NodeKeyAccountFlag = cli.StringFlag{
+		Name:  "nodekey-account",
+		Usage: "Derive the P2P node key from the key of this keystore account (address or index), unlocked with --password or a prompt",
+	}
 	NATFlag = cli.StringFlag{
 		Name:  "nat",
 		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
 		Value: "any",
 	}
+	IPModeFlag = cli.StringFlag{
+		Name:  "ip-mode,ipmode",
+		Usage: "Address families used for peer connections (any|prefer6|only6)",
+		Value: "any",
+	}
+	ClockCheckIntervalFlag = cli.DurationFlag{
+		Name:  "clock-check-interval",
+		Usage: "Interval of the NTP clock drift checks (0 disables)",
+		Value: 30 * time.Minute,
+	}
+	ClockDriftThresholdFlag = cli.DurationFlag{
+		Name:  "clock-drift-threshold",
+		Usage: "Clock drift beyond which a warning is logged",
+		Value: 10 * time.Second,
+	}
+	GeoIPDatabaseFlag = cli.StringFlag{
+		Name:  "geoip-db",
+		Usage: "Path of an offline MaxMind country database used to tag peers with their country (admin.peerStats)",
+	}
+	HandshakeTimeoutFlag = cli.DurationFlag{
+		Name:  "handshake-timeout",
+		Usage: "Time allowed for the handshakes of a peer connection",
+		Value: 5 * time.Second,
+	}
+	FrameReadTimeoutFlag = cli.DurationFlag{
+		Name:  "frame-read-timeout",
+		Usage: "Time allowed for a peer to deliver a complete message, i.e. to stay idle",
+		Value: 30 * time.Second,
+	}
 	NoDiscoverFlag = cli.BoolFlag{
 		Name:  "no-discover,nodiscover",
 		Usage: "Disables the peer discovery mechanism (manual peer addition)",
@@ -386,6 +638,11 @@ var (
 		Usage: "Solidity compiler command to be used",
 		Value: "solc",
 	}
+	VyperPathFlag = cli.StringFlag{
+		Name:  "vyper",
+		Usage: "Vyper compiler command to be used",
+		Value: "vyper",
+	}
 
 	// Gas price oracle settings
 	GpoMinGasPriceFlag = cli.StringFlag{
@@ -66,6 +66,8 @@ func makeCLIApp() (app *cli.App) {
 	app.Commands = []cli.Command{
 		importCommand,
 		exportCommand,
+		archiveExportCommand,
+		archiveImportCommand,
 		dumpChainConfigCommand,
 		upgradedbCommand,
 		dumpCommand,
@@ -83,12 +85,14 @@ func makeCLIApp() (app *cli.App) {
 		versionCommand,
 		makeMlogDocCommand,
 		buildAddrTxIndexCommand,
+		buildInternalTxIndexCommand,
 	}
 
 	app.Flags = []cli.Flag{
 		PprofFlag,
 		PprofIntervalFlag,
 		SputnikVMFlag,
+		DoctorFlag,
 		NodeNameFlag,
 		UnlockedAccountFlag,
 		PasswordFileFlag,
@@ -96,14 +100,36 @@ func makeCLIApp() (app *cli.App) {
 		BootnodesFlag,
 		DataDirFlag,
 		DocRootFlag,
+		IPFSGatewayFlag,
+		BzzGatewayFlag,
+		HTTPClientProxyFlag,
+		HTTPClientTimeoutFlag,
+		HTTPClientMaxSizeFlag,
+		HTTPClientCACertFlag,
+		HTTPClientInsecureFlag,
+		DappQuotaFlag,
 		KeyStoreDirFlag,
 		ChainIdentityFlag,
 		BlockchainVersionFlag,
 		FastSyncFlag,
 		SlowSyncFlag,
+		SyncMinPeersFlag,
+		SyncTDMarginFlag,
+		ServeRequestLimitsFlag,
+		ServeRateLimitsFlag,
+		ServeStateCacheFlag,
+		ServeStateReadersFlag,
+		ForkAlertDepthFlag,
 		AddrTxIndexFlag,
 		AddrTxIndexAutoBuildFlag,
 		CacheFlag,
+		CompactionWindowsFlag,
+		CompactionIdleFlag,
+		TxLookupLimitFlag,
+		MinFreeDiskFlag,
+		ArchiveDirFlag,
+		ArchiveMirrorsFlag,
+		ArchiveCheckpointsFlag,
 		LightKDFFlag,
 		JSpathFlag,
 		ListenPortFlag,
@@ -111,16 +137,30 @@ func makeCLIApp() (app *cli.App) {
 		MaxPendingPeersFlag,
 		EtherbaseFlag,
 		GasPriceFlag,
+		TxPoolMinGasPriceFlag,
+		UnprotectedTxsFlag,
+		PrivateTxsFlag,
+		PrivateTxRelaysFlag,
+		ENSRegistryFlag,
 		MinerThreadsFlag,
+		TxOrderingFlag,
+		PayoutSplitFlag,
 		MiningEnabledFlag,
 		MiningGPUFlag,
 		AutoDAGFlag,
 		TargetGasLimitFlag,
 		NATFlag,
+		IPModeFlag,
+		ClockCheckIntervalFlag,
+		ClockDriftThresholdFlag,
+		GeoIPDatabaseFlag,
+		HandshakeTimeoutFlag,
+		FrameReadTimeoutFlag,
 		NatspecEnabledFlag,
 		NoDiscoverFlag,
 		NodeKeyFileFlag,
 		NodeKeyHexFlag,
+		NodeKeyAccountFlag,
 		RPCEnabledFlag,
 		RPCListenAddrFlag,
 		RPCPortFlag,
@@ -130,6 +170,19 @@ func makeCLIApp() (app *cli.App) {
 		WSPortFlag,
 		WSApiFlag,
 		WSAllowedOriginsFlag,
+		RPCSubBufferFlag,
+		RPCSubPolicyFlag,
+		RPCTimeoutsFlag,
+		RPCBatchItemsFlag,
+		RPCBatchResponseSizeFlag,
+		RPCBatchTimeoutFlag,
+		RPCGasCapFlag,
+		RPCEVMTimeoutFlag,
+		StaleHeadFlag,
+		MemoryLimitFlag,
+		FilterTimeoutFlag,
+		FilterMaxFlag,
+		FilterBufferFlag,
 		IPCDisabledFlag,
 		IPCApiFlag,
 		IPCPathFlag,
@@ -158,8 +211,13 @@ func makeCLIApp() (app *cli.App) {
 		MLogComponentsFlag,
 		BacktraceAtFlag,
 		MetricsFlag,
+		EthStatsURLFlag,
+		OTLPEndpointFlag,
+		OTLPSampleRateFlag,
+		OTLPServiceFlag,
 		FakePoWFlag,
 		SolcPathFlag,
+		VyperPathFlag,
 		GpoMinGasPriceFlag,
 		GpoMaxGasPriceFlag,
 		GpoFullBlockRatioFlag,
@@ -222,6 +280,10 @@ func makeCLIApp() (app *cli.App) {
 			go metrics.CollectToFile(s)
 		}
 
+		if err := setupTracing(ctx); err != nil {
+			return err
+		}
+
 		// (whilei): I use `log` instead of `glog` because git diff tells me:
 		// > The output of this command is supposed to be machine-readable.
 		gasLimit := ctx.GlobalString(aliasableName(TargetGasLimitFlag.Name, ctx))
@@ -242,6 +304,7 @@ func makeCLIApp() (app *cli.App) {
 	}
 
 	app.After = func(ctx *cli.Context) error {
+		stopTracing()
 		logger.Flush()
 		console.Stdin.Close() // Resets terminal mode.
 		return nil
@@ -64,6 +64,8 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 		Commands: []cli.Command{
 			importCommand,
 			exportCommand,
+			archiveExportCommand,
+			archiveImportCommand,
 			dumpChainConfigCommand,
 			dumpCommand,
 			rollbackCommand,
@@ -78,10 +80,30 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			NodeNameFlag,
 			FastSyncFlag,
 			SlowSyncFlag,
+			SyncMinPeersFlag,
+			SyncTDMarginFlag,
+			ServeRequestLimitsFlag,
+			ServeRateLimitsFlag,
+			ServeStateCacheFlag,
+			ServeStateReadersFlag,
+			ForkAlertDepthFlag,
+			TxPoolMinGasPriceFlag,
+			UnprotectedTxsFlag,
+			PrivateTxsFlag,
+			PrivateTxRelaysFlag,
+			ENSRegistryFlag,
 			CacheFlag,
+			CompactionWindowsFlag,
+			CompactionIdleFlag,
+			TxLookupLimitFlag,
+			MinFreeDiskFlag,
+			ArchiveDirFlag,
+			ArchiveMirrorsFlag,
+			ArchiveCheckpointsFlag,
 			LightKDFFlag,
 			SputnikVMFlag,
 			BlockchainVersionFlag,
+			DoctorFlag,
 		},
 	},
 	{
@@ -90,6 +112,7 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			accountCommand,
 			//walletCommand,
 			buildAddrTxIndexCommand,
+			buildInternalTxIndexCommand,
 		},
 		Flags: []cli.Flag{
 			KeyStoreDirFlag,
@@ -118,6 +141,19 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			WSPortFlag,
 			WSApiFlag,
 			WSAllowedOriginsFlag,
+			RPCSubBufferFlag,
+			RPCSubPolicyFlag,
+			RPCTimeoutsFlag,
+			RPCBatchItemsFlag,
+			RPCBatchResponseSizeFlag,
+			RPCBatchTimeoutFlag,
+			RPCGasCapFlag,
+			RPCEVMTimeoutFlag,
+			StaleHeadFlag,
+			MemoryLimitFlag,
+			FilterTimeoutFlag,
+			FilterMaxFlag,
+			FilterBufferFlag,
 			IPCDisabledFlag,
 			IPCApiFlag,
 			IPCPathFlag,
@@ -135,9 +171,16 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			MaxPeersFlag,
 			MaxPendingPeersFlag,
 			NATFlag,
+			IPModeFlag,
+			ClockCheckIntervalFlag,
+			ClockDriftThresholdFlag,
+			GeoIPDatabaseFlag,
+			HandshakeTimeoutFlag,
+			FrameReadTimeoutFlag,
 			NoDiscoverFlag,
 			NodeKeyFileFlag,
 			NodeKeyHexFlag,
+			NodeKeyAccountFlag,
 		},
 	},
 	{
@@ -178,6 +221,10 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			MLogComponentsFlag,
 			BacktraceAtFlag,
 			MetricsFlag,
+			EthStatsURLFlag,
+			OTLPEndpointFlag,
+			OTLPSampleRateFlag,
+			OTLPServiceFlag,
 			FakePoWFlag,
 		},
 	},
@@ -205,6 +252,15 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 		Name: "MISCELLANEOUS",
 		Flags: []cli.Flag{
 			SolcPathFlag,
+			VyperPathFlag,
+			IPFSGatewayFlag,
+			BzzGatewayFlag,
+			HTTPClientProxyFlag,
+			HTTPClientTimeoutFlag,
+			HTTPClientMaxSizeFlag,
+			HTTPClientCACertFlag,
+			HTTPClientInsecureFlag,
+			DappQuotaFlag,
 		},
 	},
 }
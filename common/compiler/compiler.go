@@ -0,0 +1,21 @@
+package compiler
+
+// Compiler is a driver of a contract compiler binary.
+type Compiler interface {
+	// Language returns the name of the contract language, eg. "Solidity".
+	Language() string
+
+	// Version returns the semantic version of the compiler, eg. "0.4.24".
+	Version() string
+
+	// Info returns a human readable description of the compiler binary.
+	Info() string
+
+	// Compile builds and returns all the contracts contained within a source string.
+	Compile(source string) (map[string]*Contract, error)
+}
+
+var (
+	_ Compiler = (*Solidity)(nil)
+	_ Compiler = (*Vyper)(nil)
+)
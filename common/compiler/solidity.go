@@ -43,8 +43,9 @@ var (
 )
 
 type Contract struct {
-	Code string       `json:"code"`
-	Info ContractInfo `json:"info"`
+	Code        string       `json:"code"`
+	RuntimeCode string       `json:"runtimeCode,omitempty"`
+	Info        ContractInfo `json:"info"`
 }
 
 type ContractInfo struct {
@@ -56,6 +57,11 @@ type ContractInfo struct {
 	AbiDefinition   interface{} `json:"abiDefinition"`
 	UserDoc         interface{} `json:"userDoc"`
 	DeveloperDoc    interface{} `json:"developerDoc"`
+
+	// Only available from compilers supporting standard JSON
+	Metadata         string `json:"metadata,omitempty"`
+	SourceMap        string `json:"srcMap,omitempty"`
+	SourceMapRuntime string `json:"srcMapRuntime,omitempty"`
 }
 
 type Solidity struct {
@@ -105,12 +111,19 @@ func (sol *Solidity) Version() string {
 	return sol.version
 }
 
+func (sol *Solidity) Language() string {
+	return "Solidity"
+}
+
 // Compile builds and returns all the contracts contained within a source string.
 func (sol *Solidity) Compile(source string) (map[string]*Contract, error) {
 	// Short circuit if no source code was specified
 	if len(source) == 0 {
 		return nil, errors.New("solc: empty source string")
 	}
+	if sol.SupportsStandardJSON() {
+		return sol.compileStandardSource(source)
+	}
 	// Create a safe place to dump compilation output
 	wd, err := ioutil.TempDir("", "solc")
 	if err != nil {
@@ -0,0 +1,242 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// standardJSONVersion is the first solc version supporting --standard-json.
+var standardJSONVersion = [3]int{0, 4, 11}
+
+// stdinSourceName is the source unit name used for sources compiled with Compile.
+const stdinSourceName = "<stdin>"
+
+// StandardInput is the compiler input of solc --standard-json.
+type StandardInput struct {
+	Language string                    `json:"language"`
+	Sources  map[string]StandardSource `json:"sources"`
+	Settings StandardSettings          `json:"settings"`
+}
+
+// StandardSource is a source unit of the standard JSON input, given either by
+// its content or by URLs the compiler can load it from.
+type StandardSource struct {
+	Content   string   `json:"content,omitempty"`
+	URLs      []string `json:"urls,omitempty"`
+	Keccak256 string   `json:"keccak256,omitempty"`
+}
+
+// StandardSettings are the compilation settings of the standard JSON input.
+type StandardSettings struct {
+	Remappings      []string                       `json:"remappings,omitempty"`
+	Optimizer       *OptimizerSettings             `json:"optimizer,omitempty"`
+	EVMVersion      string                         `json:"evmVersion,omitempty"`
+	Libraries       map[string]map[string]string   `json:"libraries,omitempty"`
+	OutputSelection map[string]map[string][]string `json:"outputSelection,omitempty"`
+}
+
+// OptimizerSettings configure the solc optimizer.
+type OptimizerSettings struct {
+	Enabled bool `json:"enabled"`
+	Runs    int  `json:"runs,omitempty"`
+}
+
+// StandardOutput is the compiler output of solc --standard-json.
+type StandardOutput struct {
+	Errors    []StandardError                         `json:"errors,omitempty"`
+	Sources   map[string]StandardSourceOutput         `json:"sources,omitempty"`
+	Contracts map[string]map[string]*StandardContract `json:"contracts,omitempty"`
+}
+
+// StandardError is an error or warning reported by the compiler.
+type StandardError struct {
+	SourceLocation   *SourceLocation `json:"sourceLocation,omitempty"`
+	Type             string          `json:"type"`
+	Component        string          `json:"component"`
+	Severity         string          `json:"severity"`
+	Message          string          `json:"message"`
+	FormattedMessage string          `json:"formattedMessage,omitempty"`
+}
+
+// SourceLocation locates a compiler error within a source unit.
+type SourceLocation struct {
+	File  string `json:"file"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// StandardSourceOutput is the per source unit compiler output.
+type StandardSourceOutput struct {
+	ID  int             `json:"id"`
+	AST json.RawMessage `json:"ast,omitempty"`
+}
+
+// StandardContract holds the compilation artifacts of a single contract.
+type StandardContract struct {
+	ABI      json.RawMessage `json:"abi,omitempty"`
+	Metadata string          `json:"metadata,omitempty"`
+	UserDoc  json.RawMessage `json:"userdoc,omitempty"`
+	DevDoc   json.RawMessage `json:"devdoc,omitempty"`
+	EVM      struct {
+		Bytecode          StandardBytecode  `json:"bytecode"`
+		DeployedBytecode  StandardBytecode  `json:"deployedBytecode"`
+		MethodIdentifiers map[string]string `json:"methodIdentifiers,omitempty"`
+	} `json:"evm"`
+}
+
+// StandardBytecode is the (deployment or runtime) bytecode of a contract, along
+// with its source map and the placeholders of libraries still to be linked.
+type StandardBytecode struct {
+	Object         string                                `json:"object"`
+	Opcodes        string                                `json:"opcodes,omitempty"`
+	SourceMap      string                                `json:"sourceMap,omitempty"`
+	LinkReferences map[string]map[string][]LinkReference `json:"linkReferences,omitempty"`
+}
+
+// LinkReference is the position of a library address placeholder in bytecode.
+type LinkReference struct {
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+// defaultOutputSelection requests all the artifacts needed to deploy, interact
+// with, debug and verify the contracts.
+var defaultOutputSelection = map[string]map[string][]string{
+	"*": {
+		"*": {"abi", "metadata", "userdoc", "devdoc", "evm.bytecode", "evm.deployedBytecode", "evm.methodIdentifiers"},
+	},
+}
+
+// SupportsStandardJSON reports whether the compiler supports the standard JSON
+// input and output interface.
+func (sol *Solidity) SupportsStandardJSON() bool {
+	return !versionBefore(sol.version, standardJSONVersion)
+}
+
+// CompileStandard compiles the given standard JSON input, returning the full
+// compiler output. Compilation errors are returned as error, warnings are part of
+// the output.
+func (sol *Solidity) CompileStandard(input *StandardInput) (*StandardOutput, error) {
+	if !sol.SupportsStandardJSON() {
+		return nil, fmt.Errorf("solc: version %s doesn't support standard JSON (need %d.%d.%d or later)", sol.version, standardJSONVersion[0], standardJSONVersion[1], standardJSONVersion[2])
+	}
+	if len(input.Sources) == 0 {
+		return nil, errors.New("solc: no sources given")
+	}
+	in := *input
+	if in.Language == "" {
+		in.Language = "Solidity"
+	}
+	if in.Settings.OutputSelection == nil {
+		in.Settings.OutputSelection = defaultOutputSelection
+	}
+	blob, err := json.Marshal(&in)
+	if err != nil {
+		return nil, err
+	}
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd := exec.Command(sol.solcPath, "--standard-json")
+	cmd.Stdin = bytes.NewReader(blob)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("solc: %v\n%s", err, stderr.String())
+	}
+	output := new(StandardOutput)
+	if err := json.Unmarshal(stdout.Bytes(), output); err != nil {
+		return nil, fmt.Errorf("solc: error parsing standard JSON output: %v", err)
+	}
+	var errs []string
+	for _, e := range output.Errors {
+		if e.Severity == "error" {
+			msg := e.FormattedMessage
+			if msg == "" {
+				msg = e.Message
+			}
+			errs = append(errs, strings.TrimSpace(msg))
+		}
+	}
+	if len(errs) > 0 {
+		return output, fmt.Errorf("solc: %s", strings.Join(errs, "\n"))
+	}
+	return output, nil
+}
+
+// compileStandardSource compiles a single source string using the standard JSON
+// interface, converting the artifacts to contracts.
+func (sol *Solidity) compileStandardSource(source string) (map[string]*Contract, error) {
+	input := &StandardInput{
+		Sources: map[string]StandardSource{stdinSourceName: {Content: source}},
+		Settings: StandardSettings{
+			Optimizer: &OptimizerSettings{Enabled: true, Runs: 200},
+		},
+	}
+	output, err := sol.CompileStandard(input)
+	if err != nil {
+		return nil, err
+	}
+	options, _ := json.Marshal(&input.Settings)
+
+	contracts := make(map[string]*Contract)
+	for name, contract := range output.Contracts[stdinSourceName] {
+		var abi, userdoc, devdoc interface{}
+		if err := unmarshalArtifact(contract.ABI, &abi); err != nil {
+			return nil, fmt.Errorf("solc: error parsing abi definition: %v", err)
+		}
+		if err := unmarshalArtifact(contract.UserDoc, &userdoc); err != nil {
+			return nil, fmt.Errorf("solc: error parsing user doc: %v", err)
+		}
+		if err := unmarshalArtifact(contract.DevDoc, &devdoc); err != nil {
+			return nil, fmt.Errorf("solc: error parsing dev doc: %v", err)
+		}
+		contracts[name] = &Contract{
+			Code:        "0x" + contract.EVM.Bytecode.Object,
+			RuntimeCode: "0x" + contract.EVM.DeployedBytecode.Object,
+			Info: ContractInfo{
+				Source:           source,
+				Language:         "Solidity",
+				LanguageVersion:  sol.version,
+				CompilerVersion:  sol.version,
+				CompilerOptions:  string(options),
+				AbiDefinition:    abi,
+				UserDoc:          userdoc,
+				DeveloperDoc:     devdoc,
+				Metadata:         contract.Metadata,
+				SourceMap:        contract.EVM.Bytecode.SourceMap,
+				SourceMapRuntime: contract.EVM.DeployedBytecode.SourceMap,
+			},
+		}
+	}
+	if len(contracts) == 0 {
+		return nil, fmt.Errorf("solc: no build results found")
+	}
+	return contracts, nil
+}
+
+func unmarshalArtifact(blob json.RawMessage, v interface{}) error {
+	if len(blob) == 0 {
+		return nil
+	}
+	return json.Unmarshal(blob, v)
+}
+
+// versionBefore reports whether the dotted version string is older than min.
+func versionBefore(version string, min [3]int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	for i := 0; i < 3; i++ {
+		var n int
+		if i < len(parts) {
+			n, _ = strconv.Atoi(parts[i])
+		}
+		if n != min[i] {
+			return n < min[i]
+		}
+	}
+	return false
+}
@@ -21,6 +21,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/ethereumclassic/go-ethereum/common"
@@ -112,3 +115,83 @@ func TestSaveInfo(t *testing.T) {
 		t.Errorf("content hash for info is incorrect. expected %v, got %v", infohash.Hex(), cinfohash.Hex())
 	}
 }
+
+// fakeCompiler creates a script mimicking the named compiler binary, printing the
+// given version banner for --version and answering any other request, whether
+// the source comes from the standard input or a file, with the given output.
+func fakeCompiler(t *testing.T, name, version, output string) (string, func()) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake " + name + " needs a unix shell")
+	}
+	dir, err := ioutil.TempDir("", name+"-test")
+	if err != nil {
+		t.Fatal("cannot create temporary directory:", err)
+	}
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = \"--version\" ]; then cat <<'EOF'\n" + version + "\nEOF\nexit 0; fi\n" +
+		"cat > /dev/null\n" +
+		"cat <<'EOF'\n" + output + "\nEOF\n"
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(script), 0700); err != nil {
+		t.Fatal("could not write fake "+name+":", err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+// fakeSolc creates a script mimicking a solc binary of the given version, which
+// answers --standard-json requests with the given output.
+func fakeSolc(t *testing.T, version, output string) (string, func()) {
+	banner := "solc, the solidity compiler commandline interface\nVersion: " + version + "+commit.deadbeef.Linux.g++"
+	return fakeCompiler(t, "solc", banner, output)
+}
+
+func TestCompileStandard(t *testing.T) {
+	output := `{"contracts":{"<stdin>":{"test":{"abi":[{"constant":false,"inputs":[{"name":"a","type":"uint256"}],"name":"multiply","outputs":[{"name":"d","type":"uint256"}],"type":"function"}],"metadata":"{\"version\":1}","userdoc":{"methods":{}},"devdoc":{"methods":{}},"evm":{"bytecode":{"object":"6060","sourceMap":"0:1:0"},"deployedBytecode":{"object":"60","sourceMap":"0:1:0:-"}}}}},"errors":[{"severity":"warning","type":"Warning","component":"general","message":"no pragma"}]}`
+	path, cleanup := fakeSolc(t, "0.4.24", output)
+	defer cleanup()
+
+	sol, err := New(path)
+	if err != nil {
+		t.Fatalf("error creating compiler: %v", err)
+	}
+	if !sol.SupportsStandardJSON() {
+		t.Fatalf("expected standard JSON support for version %s", sol.Version())
+	}
+	contracts, err := sol.Compile(source)
+	if err != nil {
+		t.Fatalf("error compiling source: %v", err)
+	}
+	contract := contracts["test"]
+	if contract == nil {
+		t.Fatalf("contract missing from output: %v", contracts)
+	}
+	if contract.Code != "0x6060" || contract.RuntimeCode != "0x60" {
+		t.Errorf("wrong code, got %s (runtime %s)", contract.Code, contract.RuntimeCode)
+	}
+	if contract.Info.Metadata != `{"version":1}` || contract.Info.SourceMap != "0:1:0" || contract.Info.SourceMapRuntime != "0:1:0:-" {
+		t.Errorf("wrong artifacts: %+v", contract.Info)
+	}
+}
+
+func TestCompileStandardErrors(t *testing.T) {
+	output := `{"errors":[{"severity":"error","type":"ParserError","component":"general","message":"Expected token","formattedMessage":"<stdin>:1:1: ParserError: Expected token"}]}`
+	path, cleanup := fakeSolc(t, "0.4.24", output)
+	defer cleanup()
+
+	sol, err := New(path)
+	if err != nil {
+		t.Fatalf("error creating compiler: %v", err)
+	}
+	if _, err := sol.Compile(source); err == nil || !strings.Contains(err.Error(), "ParserError") {
+		t.Errorf("expected parser error, got %v", err)
+	}
+}
+
+func TestStandardJSONVersions(t *testing.T) {
+	tests := map[string]bool{"0.1.1": false, "0.4.10": false, "0.4.11": true, "0.5.0": true, "1.0.0": true}
+	for version, supported := range tests {
+		if have := (&Solidity{version: version}).SupportsStandardJSON(); have != supported {
+			t.Errorf("version %s: standard JSON support mismatch: have %v, want %v", version, have, supported)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package compiler
+
+// StripMetadata removes the CBOR encoded metadata solc appends to contract code.
+// The metadata embeds a hash of the exact source text, so it differs for sources
+// that compile to the same code but aren't byte for byte identical. Code without
+// metadata is returned unchanged.
+func StripMetadata(code []byte) []byte {
+	if len(code) < 2 {
+		return code
+	}
+	size := int(code[len(code)-2])<<8 | int(code[len(code)-1])
+	start := len(code) - 2 - size
+	if size == 0 || start < 0 {
+		return code
+	}
+	// The metadata is a CBOR map (major type 5)
+	if code[start]&0xe0 != 0xa0 {
+		return code
+	}
+	return code[:start]
+}
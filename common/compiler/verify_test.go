@@ -0,0 +1,28 @@
+package compiler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/openether/ethcore/common"
+)
+
+func TestStripMetadata(t *testing.T) {
+	code := common.FromHex("6060604052600080fd00")
+	// {"bzzr0": <32 bytes>}, as appended by solc 0.4.x
+	metadata := common.FromHex("a165627a7a72305820" + "1111111111111111111111111111111111111111111111111111111111111111" + "0029")
+
+	tests := []struct {
+		code, want []byte
+	}{
+		{append(append([]byte{}, code...), metadata...), code},
+		{code, code},
+		{common.FromHex("0029"), common.FromHex("0029")},
+		{nil, nil},
+	}
+	for i, tt := range tests {
+		if got := StripMetadata(tt.code); !bytes.Equal(got, tt.want) {
+			t.Errorf("test %d: got %x, want %x", i, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+// vyperContractName is the name of the contract compiled from a source string,
+// as Vyper names contracts after their source file.
+const vyperContractName = "contract"
+
+// Vyper is a driver of the vyper compiler.
+type Vyper struct {
+	vyperPath   string
+	version     string
+	fullVersion string
+}
+
+// vyperOutput is the per file output of vyper -f combined_json.
+type vyperOutput struct {
+	Bytecode        string          `json:"bytecode"`
+	BytecodeRuntime string          `json:"bytecode_runtime"`
+	ABI             json.RawMessage `json:"abi"`
+}
+
+// NewVyper creates a driver of the vyper binary at the given path, or of the
+// vyper binary in PATH if none is given.
+func NewVyper(vyperPath string) (*Vyper, error) {
+	if len(vyperPath) == 0 {
+		vyperPath = "vyper"
+	}
+	vyperPath, err := exec.LookPath(vyperPath)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	cmd := exec.Command(vyperPath, "--version")
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	fullVersion := strings.TrimSpace(out.String())
+
+	vyper := &Vyper{
+		vyperPath:   vyperPath,
+		version:     versionRegexp.FindString(fullVersion),
+		fullVersion: fullVersion,
+	}
+	glog.V(logger.Info).Infoln(vyper.Info())
+	return vyper, nil
+}
+
+func (vyper *Vyper) Info() string {
+	return fmt.Sprintf("vyper %s\npath: %s", vyper.fullVersion, vyper.vyperPath)
+}
+
+func (vyper *Vyper) Version() string {
+	return vyper.version
+}
+
+func (vyper *Vyper) Language() string {
+	return "Vyper"
+}
+
+// Compile builds the contract contained within a source string, which is named
+// "contract".
+func (vyper *Vyper) Compile(source string) (map[string]*Contract, error) {
+	if len(source) == 0 {
+		return nil, errors.New("vyper: empty source string")
+	}
+	// Vyper only compiles files, so dump the source into a temporary one
+	wd, err := ioutil.TempDir("", "vyper")
+	if err != nil {
+		return nil, fmt.Errorf("vyper: failed to create temporary build folder: %v", err)
+	}
+	defer os.RemoveAll(wd)
+
+	file := filepath.Join(wd, vyperContractName+".vy")
+	if err := ioutil.WriteFile(file, []byte(source), 0600); err != nil {
+		return nil, fmt.Errorf("vyper: failed to write source: %v", err)
+	}
+	params := []string{"-f", "combined_json"}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd := exec.Command(vyper.vyperPath, append(params, file)...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("vyper: %v\n%s", err, stderr.String())
+	}
+	var outputs map[string]json.RawMessage
+	if err := json.Unmarshal(stdout.Bytes(), &outputs); err != nil {
+		return nil, fmt.Errorf("vyper: error parsing output: %v", err)
+	}
+	var output *vyperOutput
+	for name, blob := range outputs {
+		if filepath.Base(name) != vyperContractName+".vy" {
+			continue // version and other metadata
+		}
+		output = new(vyperOutput)
+		if err := json.Unmarshal(blob, output); err != nil {
+			return nil, fmt.Errorf("vyper: error parsing output: %v", err)
+		}
+	}
+	if output == nil {
+		return nil, fmt.Errorf("vyper: no build results found")
+	}
+	var abi interface{}
+	if err := unmarshalArtifact(output.ABI, &abi); err != nil {
+		return nil, fmt.Errorf("vyper: error parsing abi definition: %v", err)
+	}
+	contract := &Contract{
+		Code:        ensureHexPrefix(output.Bytecode),
+		RuntimeCode: ensureHexPrefix(output.BytecodeRuntime),
+		Info: ContractInfo{
+			Source:          source,
+			Language:        vyper.Language(),
+			LanguageVersion: vyper.version,
+			CompilerVersion: vyper.version,
+			CompilerOptions: strings.Join(params, " "),
+			AbiDefinition:   abi,
+		},
+	}
+	return map[string]*Contract{vyperContractName: contract}, nil
+}
+
+func ensureHexPrefix(code string) string {
+	if strings.HasPrefix(code, "0x") {
+		return code
+	}
+	return "0x" + code
+}
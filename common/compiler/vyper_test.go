@@ -0,0 +1,53 @@
+package compiler
+
+import "testing"
+
+const vyperSource = `
+@public
+def multiply(a: int128) -> int128:
+    return a * 7
+`
+
+func TestVyperCompile(t *testing.T) {
+	output := `{"/tmp/vyper123/contract.vy":{"bytecode":"0x6060","bytecode_runtime":"60","abi":[{"name":"multiply","outputs":[{"type":"int128","name":"out"}],"inputs":[{"type":"int128","name":"a"}],"constant":false,"payable":false,"type":"function"}]},"version":"0.1.0b4"}`
+	path, cleanup := fakeCompiler(t, "vyper", "0.1.0b4", output)
+	defer cleanup()
+
+	vyper, err := NewVyper(path)
+	if err != nil {
+		t.Fatalf("error creating compiler: %v", err)
+	}
+	if vyper.Version() != "0.1.0" || vyper.Language() != "Vyper" {
+		t.Errorf("wrong compiler description: %s %s", vyper.Language(), vyper.Version())
+	}
+	contracts, err := vyper.Compile(vyperSource)
+	if err != nil {
+		t.Fatalf("error compiling source: %v", err)
+	}
+	contract := contracts["contract"]
+	if contract == nil {
+		t.Fatalf("contract missing from output: %v", contracts)
+	}
+	if contract.Code != "0x6060" || contract.RuntimeCode != "0x60" {
+		t.Errorf("wrong code, got %s (runtime %s)", contract.Code, contract.RuntimeCode)
+	}
+	if contract.Info.Language != "Vyper" || contract.Info.AbiDefinition == nil {
+		t.Errorf("wrong contract info: %+v", contract.Info)
+	}
+}
+
+func TestVyperCompileEmpty(t *testing.T) {
+	path, cleanup := fakeCompiler(t, "vyper", "0.1.0b4", `{}`)
+	defer cleanup()
+
+	vyper, err := NewVyper(path)
+	if err != nil {
+		t.Fatalf("error creating compiler: %v", err)
+	}
+	if _, err := vyper.Compile(""); err == nil {
+		t.Errorf("expected error compiling empty source")
+	}
+	if _, err := vyper.Compile(vyperSource); err == nil {
+		t.Errorf("expected error for missing build results")
+	}
+}
@@ -0,0 +1,59 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// gatewayPrefixes maps the content addressed URI schemes that can be resolved
+// through an HTTP gateway to the path prefix the gateway serves them under.
+var gatewayPrefixes = map[string]string{
+	"ipfs": "/ipfs",
+	"bzz":  "/bzz:",
+}
+
+// gatewayTransport retrieves content addressed URIs, eg. ipfs://<cid>/path, by
+// rewriting them to the corresponding gateway URL, eg. <gateway>/ipfs/<cid>/path.
+// Gateways are not trusted: content should be retrieved with GetAuthContent to
+// check it against the hash it was registered with.
+type gatewayTransport struct {
+	transport http.RoundTripper
+	gateway   *url.URL
+	prefix    string
+}
+
+func (t *gatewayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := *t.gateway
+	u.Path = path.Join("/", t.gateway.Path, t.prefix, req.URL.Host, req.URL.Path)
+	u.RawQuery = req.URL.RawQuery
+
+	greq := new(http.Request)
+	*greq = *req
+	greq.URL = &u
+	greq.Host = u.Host
+	return t.transport.RoundTrip(greq)
+}
+
+// RegisterGateway makes URIs of the given content addressed scheme (ipfs or bzz)
+// be retrieved through the HTTP gateway at the given URL.
+func (self *HTTPClient) RegisterGateway(scheme, gateway string) error {
+	prefix, ok := gatewayPrefixes[scheme]
+	if !ok {
+		return fmt.Errorf("no gateway support for scheme %q", scheme)
+	}
+	u, err := url.Parse(gateway)
+	if err != nil {
+		return fmt.Errorf("invalid %s gateway %q: %v", scheme, gateway, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid %s gateway %q: not an HTTP URL", scheme, gateway)
+	}
+	self.RegisterScheme(scheme, &gatewayTransport{
+		transport: self.Transport,
+		gateway:   u,
+		prefix:    prefix,
+	})
+	return nil
+}
@@ -1,29 +1,85 @@
 package httpclient
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"time"
 
 	"github.com/openether/ethcore/common"
 	"github.com/openether/ethcore/crypto"
 )
 
+// Config holds the settings of the HTTP client.
+type Config struct {
+	Proxy              string        // URL of the proxy to use, the environment's proxy settings are used if empty
+	Timeout            time.Duration // Time limit for a request including reading the response, no limit if zero
+	MaxResponseSize    int64         // Maximum size of a response body in bytes, no limit if zero
+	CACertFile         string        // PEM file with the CA certificates to trust instead of the system ones
+	InsecureSkipVerify bool          // Whether to skip verifying server certificates (testing only!)
+}
+
+// DefaultConfig contains the default HTTP client settings.
+var DefaultConfig = Config{
+	Timeout:         30 * time.Second,
+	MaxResponseSize: 10 * 1024 * 1024,
+}
+
 type HTTPClient struct {
 	*http.Transport
 	DocRoot string
 	schemes []string
+
+	timeout time.Duration
+	maxSize int64
 }
 
 func New(docRoot string) (self *HTTPClient) {
-	self = &HTTPClient{
-		Transport: &http.Transport{},
+	self, _ = NewWithConfig(docRoot, DefaultConfig)
+	return
+}
+
+// NewWithConfig creates an HTTP client with the given proxy, timeout, response
+// size and TLS settings.
+func NewWithConfig(docRoot string, config Config) (*HTTPClient, error) {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+	if config.Proxy != "" {
+		proxy, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %v", config.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if config.CACertFile != "" || config.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+		if config.CACertFile != "" {
+			pem, err := ioutil.ReadFile(config.CACertFile)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", config.CACertFile)
+			}
+			transport.TLSClientConfig.RootCAs = pool
+		}
+	}
+	self := &HTTPClient{
+		Transport: transport,
 		DocRoot:   docRoot,
 		schemes:   []string{"file"},
+		timeout:   config.Timeout,
+		maxSize:   config.MaxResponseSize,
 	}
 	self.RegisterProtocol("file", http.NewFileTransport(http.Dir(self.DocRoot)))
-	return
+	return self, nil
 }
 
 // Clients should be reused instead of created as needed. Clients are safe for concurrent use by multiple goroutines.
@@ -33,6 +89,7 @@ func New(docRoot string) (self *HTTPClient) {
 func (self *HTTPClient) Client() *http.Client {
 	return &http.Client{
 		Transport: self,
+		Timeout:   self.timeout,
 	}
 }
 
@@ -81,11 +138,18 @@ func (self *HTTPClient) Get(uri, path string) ([]byte, error) {
 		}
 	}()
 
+	var body io.Reader = resp.Body
+	if self.maxSize > 0 {
+		body = io.LimitReader(resp.Body, self.maxSize+1)
+	}
 	var content []byte
-	content, err = ioutil.ReadAll(resp.Body)
+	content, err = ioutil.ReadAll(body)
 	if err != nil {
 		return nil, err
 	}
+	if self.maxSize > 0 && int64(len(content)) > self.maxSize {
+		return nil, fmt.Errorf("response of %s exceeds maximum size of %d bytes", uri, self.maxSize)
+	}
 
 	if resp.StatusCode/100 != 2 {
 		return content, fmt.Errorf("HTTP error: %s", resp.Status)
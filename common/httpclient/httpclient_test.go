@@ -19,9 +19,11 @@ package httpclient
 import (
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/ethereumclassic/go-ethereum/common"
 	"github.com/ethereumclassic/go-ethereum/crypto"
@@ -75,3 +77,80 @@ func TestRegisterScheme(t *testing.T) {
 		t.Errorf("expected scheme to be registered")
 	}
 }
+
+func TestGateway(t *testing.T) {
+	text := "test"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gw/ipfs/QmHash/test.content", "/gw/bzz:/bzzhash/test.content":
+			w.Write([]byte(text))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := New("/tmp/")
+	if err := client.RegisterGateway("ftp", server.URL); err == nil {
+		t.Errorf("expected error for unsupported scheme")
+	}
+	if err := client.RegisterGateway("ipfs", server.URL+"/gw"); err != nil {
+		t.Fatalf("no error expected, got %v", err)
+	}
+	if err := client.RegisterGateway("bzz", server.URL+"/gw/"); err != nil {
+		t.Fatalf("no error expected, got %v", err)
+	}
+	hash := crypto.Keccak256Hash([]byte(text))
+	for _, uri := range []string{"ipfs://QmHash/test.content", "bzz://bzzhash/test.content"} {
+		content, err := client.GetAuthContent(uri, hash)
+		if err != nil {
+			t.Errorf("%s: no error expected, got %v", uri, err)
+		}
+		if string(content) != text {
+			t.Errorf("%s: incorrect content. expected %v, got %v", uri, text, string(content))
+		}
+	}
+	if _, err := client.GetAuthContent("ipfs://QmHash/test.content", common.Hash{}); err == nil {
+		t.Errorf("expected content hash mismatch error")
+	}
+	if _, err := client.Get("ipfs://QmOther/test.content", ""); err == nil {
+		t.Errorf("expected HTTP error for missing content")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			w.Write(make([]byte, 16))
+		case "/large":
+			w.Write(make([]byte, 17))
+		case "/hang":
+			<-release
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := NewWithConfig("/tmp/", Config{Timeout: 100 * time.Millisecond, MaxResponseSize: 16})
+	if err != nil {
+		t.Fatalf("no error expected, got %v", err)
+	}
+	if _, err := client.Get(server.URL+"/small", ""); err != nil {
+		t.Errorf("no error expected, got %v", err)
+	}
+	if _, err := client.Get(server.URL+"/large", ""); err == nil {
+		t.Errorf("expected error for response exceeding the size limit")
+	}
+	start := time.Now()
+	if _, err := client.Get(server.URL+"/hang", ""); err == nil {
+		t.Errorf("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request not timed out, took %v", elapsed)
+	}
+	if _, err := NewWithConfig("/tmp/", Config{Proxy: "://bad"}); err == nil {
+		t.Errorf("expected error for invalid proxy URL")
+	}
+}
@@ -0,0 +1,228 @@
+// Package memwatch monitors the heap of the process and signals memory pressure
+// to the subsystems able to back off, so a node under load sheds work instead of
+// being killed for running out of memory.
+//
+// A single watchdog is active per process, see SetDefault. Subsystems check the
+// current pressure with CurrentLevel or Overloaded and register to be notified
+// of rising pressure with OnPressure, whether or not a watchdog is running.
+package memwatch
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+// Level is the memory pressure of the process.
+type Level int32
+
+const (
+	Normal   Level = iota // Heap below the high watermark
+	High                  // Heap above the high watermark: caches are shrunk and heavy work refused
+	Critical              // Heap above the critical watermark: memory is returned to the OS as well
+)
+
+func (l Level) String() string {
+	switch l {
+	case Normal:
+		return "normal"
+	case High:
+		return "high"
+	case Critical:
+		return "critical"
+	}
+	return "unknown"
+}
+
+const (
+	DefaultInterval = 3 * time.Second // Default interval between heap measurements
+
+	highWatermark     = 0.80 // Fraction of the limit above which the pressure is high
+	criticalWatermark = 0.95 // Fraction of the limit above which the pressure is critical
+)
+
+// OverloadError is returned for work refused under memory pressure. It is
+// transient, the work can be retried once the pressure is relieved.
+type OverloadError struct {
+	Level Level
+}
+
+func (e *OverloadError) Error() string {
+	return "node under " + e.Level.String() + " memory pressure, retry later"
+}
+
+// Code reports a limit exceeded to RPC clients.
+func (e *OverloadError) Code() int {
+	return -32005
+}
+
+// ErrorData tells RPC clients the request can be retried.
+func (e *OverloadError) ErrorData() interface{} {
+	return map[string]interface{}{"retryable": true, "pressure": e.Level.String()}
+}
+
+// Watchdog periodically measures the heap against a limit.
+type Watchdog struct {
+	limit    uint64        // Heap size in bytes the watermarks are relative to
+	interval time.Duration // Interval between heap measurements
+	heap     func() uint64 // Measures the heap size, replaced in tests
+
+	level int32 // Current Level, accessed atomically
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a watchdog for the given heap limit in bytes, measuring the heap
+// at the given interval (DefaultInterval if zero).
+func New(limit uint64, interval time.Duration) *Watchdog {
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	return &Watchdog{
+		limit:    limit,
+		interval: interval,
+		heap:     heapInUse,
+	}
+}
+
+// heapInUse returns the number of bytes of heap memory in use.
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+// Start begins measuring the heap in the background.
+func (w *Watchdog) Start() {
+	w.quit = make(chan struct{})
+	w.wg.Add(1)
+	go w.loop()
+}
+
+// Stop terminates the measurements and resets the pressure.
+func (w *Watchdog) Stop() {
+	close(w.quit)
+	w.wg.Wait()
+	atomic.StoreInt32(&w.level, int32(Normal))
+}
+
+// Level returns the memory pressure measured last.
+func (w *Watchdog) Level() Level {
+	return Level(atomic.LoadInt32(&w.level))
+}
+
+func (w *Watchdog) loop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.check()
+		select {
+		case <-ticker.C:
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// check measures the heap, updates the pressure and relieves it if it rose.
+func (w *Watchdog) check() {
+	heap := w.heap()
+
+	level := Normal
+	switch {
+	case float64(heap) >= criticalWatermark*float64(w.limit):
+		level = Critical
+	case float64(heap) >= highWatermark*float64(w.limit):
+		level = High
+	}
+	prev := Level(atomic.SwapInt32(&w.level, int32(level)))
+	if level == prev {
+		return
+	}
+	if level < prev {
+		glog.V(logger.Info).Infof("Memory pressure eased to %v: heap %d MB of %d MB", level, heap>>20, w.limit>>20)
+		return
+	}
+	glog.V(logger.Warn).Warnf("Memory pressure %v: heap %d MB of %d MB, shedding load", level, heap>>20, w.limit>>20)
+	notify(level)
+	if level == Critical {
+		debug.FreeOSMemory()
+	}
+}
+
+var (
+	active *Watchdog // Watchdog of the process, nil if none
+
+	handlersMu sync.Mutex
+	handlers   = make(map[int]func(Level))
+	handlerId  int
+)
+
+// SetDefault makes the given watchdog, which may be nil, the one whose pressure
+// CurrentLevel reports.
+func SetDefault(w *Watchdog) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	active = w
+}
+
+// CurrentLevel returns the memory pressure of the process, Normal if no
+// watchdog is active.
+func CurrentLevel() Level {
+	handlersMu.Lock()
+	w := active
+	handlersMu.Unlock()
+
+	if w == nil {
+		return Normal
+	}
+	return w.Level()
+}
+
+// Overloaded returns an OverloadError if the process is under memory pressure,
+// for heavy work to be refused with.
+func Overloaded() error {
+	if level := CurrentLevel(); level > Normal {
+		return &OverloadError{Level: level}
+	}
+	return nil
+}
+
+// OnPressure registers a function called whenever the memory pressure rises,
+// typically to drop caches. The returned function unregisters it.
+func OnPressure(fn func(Level)) func() {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+
+	handlerId++
+	id := handlerId
+	handlers[id] = fn
+	return func() {
+		handlersMu.Lock()
+		defer handlersMu.Unlock()
+		delete(handlers, id)
+	}
+}
+
+// notify calls the registered pressure handlers.
+func notify(level Level) {
+	handlersMu.Lock()
+	fns := make([]func(Level), 0, len(handlers))
+	for _, fn := range handlers {
+		fns = append(fns, fn)
+	}
+	handlersMu.Unlock()
+
+	for _, fn := range fns {
+		fn(level)
+	}
+}
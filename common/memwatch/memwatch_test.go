@@ -0,0 +1,66 @@
+package memwatch
+
+import (
+	"testing"
+)
+
+func TestWatchdogLevels(t *testing.T) {
+	var heap uint64
+	w := New(1000, 0)
+	w.heap = func() uint64 { return heap }
+
+	var notified []Level
+	unregister := OnPressure(func(level Level) { notified = append(notified, level) })
+	defer unregister()
+
+	SetDefault(w)
+	defer SetDefault(nil)
+
+	tests := []struct {
+		heap     uint64
+		level    Level
+		notified int
+	}{
+		{100, Normal, 0},
+		{800, High, 1},
+		{900, High, 1},
+		{960, Critical, 2},
+		{850, High, 2},
+		{500, Normal, 2},
+		{990, Critical, 3},
+	}
+	for i, tt := range tests {
+		heap = tt.heap
+		w.check()
+		if level := CurrentLevel(); level != tt.level {
+			t.Errorf("test %d: level mismatch: have %v, want %v", i, level, tt.level)
+		}
+		if len(notified) != tt.notified {
+			t.Errorf("test %d: notified %d times, want %d", i, len(notified), tt.notified)
+		}
+		if err := Overloaded(); (err != nil) != (tt.level > Normal) {
+			t.Errorf("test %d: overload error mismatch: %v", i, err)
+		}
+	}
+	if notified[0] != High || notified[1] != Critical || notified[2] != Critical {
+		t.Errorf("notified levels mismatch: %v", notified)
+	}
+	unregister()
+	heap = 0
+	w.check()
+	heap = 1000
+	w.check()
+	if len(notified) != 3 {
+		t.Errorf("unregistered handler notified")
+	}
+}
+
+func TestNoWatchdog(t *testing.T) {
+	SetDefault(nil)
+	if level := CurrentLevel(); level != Normal {
+		t.Errorf("level without watchdog: have %v, want %v", level, Normal)
+	}
+	if err := Overloaded(); err != nil {
+		t.Errorf("overloaded without watchdog: %v", err)
+	}
+}
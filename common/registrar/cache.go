@@ -0,0 +1,122 @@
+package registrar
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/vm"
+	"github.com/openether/ethcore/crypto"
+)
+
+var (
+	changedTopic        = common.BytesToHash(crypto.Keccak256([]byte("Changed(bytes32)")))
+	primaryChangedTopic = common.BytesToHash(crypto.Keccak256([]byte("PrimaryChanged(bytes32,address)")))
+)
+
+// Cache is a size limited cache of GlobalRegistrar name and reverse lookups,
+// which can be shared between Registrar instances. Entries are invalidated by
+// the Changed and PrimaryChanged logs of the GlobalRegistrar, see ProcessLogs,
+// and dropped altogether if the GlobalRegistrar address changes.
+type Cache struct {
+	lock      sync.Mutex
+	registrar string     // GlobalRegistrar address the cached lookups were made against
+	names     *lru.Cache // name -> primary address, zero if not registered
+	addrs     *lru.Cache // address -> name, empty if no primary name
+}
+
+// NewCache creates a lookup cache holding up to size names and size addresses.
+func NewCache(size int) *Cache {
+	names, _ := lru.New(size)
+	addrs, _ := lru.New(size)
+	return &Cache{
+		registrar: GlobalRegistrarAddr,
+		names:     names,
+		addrs:     addrs,
+	}
+}
+
+// sync drops all entries if the GlobalRegistrar was changed since they were
+// looked up. The caller must hold the lock.
+func (c *Cache) sync() {
+	if c.registrar != GlobalRegistrarAddr {
+		c.names.Purge()
+		c.addrs.Purge()
+		c.registrar = GlobalRegistrarAddr
+	}
+}
+
+func (c *Cache) getAddr(name string) (common.Address, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sync()
+	if addr, ok := c.names.Get(name); ok {
+		return addr.(common.Address), true
+	}
+	return common.Address{}, false
+}
+
+func (c *Cache) addAddr(name string, addr common.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sync()
+	c.names.Add(name, addr)
+}
+
+func (c *Cache) getName(addr common.Address) (string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sync()
+	if name, ok := c.addrs.Get(addr); ok {
+		return name.(string), true
+	}
+	return "", false
+}
+
+func (c *Cache) addName(addr common.Address, name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sync()
+	c.addrs.Add(addr, name)
+}
+
+// ProcessLogs invalidates the lookups affected by the given logs of the
+// GlobalRegistrar. Both logs of newly imported blocks and logs removed by a
+// reorg must be processed.
+func (c *Cache) ProcessLogs(logs vm.Logs) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sync()
+	registrar := common.HexToAddress(c.registrar)
+	for _, log := range logs {
+		if log.Address != registrar || len(log.Topics) < 2 {
+			continue
+		}
+		if log.Topics[0] != changedTopic && log.Topics[0] != primaryChangedTopic {
+			continue
+		}
+		// The name was (re)assigned or disowned, forget all lookups involving it
+		name := decodeName(log.Topics[1][:])
+		c.names.Remove(name)
+		for _, key := range c.addrs.Keys() {
+			if cached, ok := c.addrs.Peek(key); ok && cached.(string) == name {
+				c.addrs.Remove(key)
+			}
+		}
+		// The new primary address might have had another name before
+		if log.Topics[0] == primaryChangedTopic && len(log.Topics) > 2 {
+			c.addrs.Remove(common.BytesToAddress(log.Topics[2][:]))
+		}
+	}
+}
+
+// decodeName converts a bytes32 registrar name to a string.
+func decodeName(b []byte) string {
+	return string(bytes.TrimRight(b, "\x00"))
+}
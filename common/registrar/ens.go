@@ -0,0 +1,84 @@
+package registrar
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/crypto"
+)
+
+var (
+	ensResolverAbi = abiSignature("resolver(bytes32)")
+	ensAddrAbi     = abiSignature("addr(bytes32)")
+)
+
+// ENS resolves names through an ENS compatible registry: the registry maps the
+// namehash of a name to a resolver contract, which in turn maps it to an address.
+type ENS struct {
+	backend  Backend
+	registry common.Address
+}
+
+// NewENS creates an ENS client using the registry deployed at the given address.
+func NewENS(b Backend, registry common.Address) *ENS {
+	return &ENS{backend: b, registry: registry}
+}
+
+// Registry returns the address of the registry names are resolved with.
+func (self *ENS) Registry() common.Address {
+	return self.registry
+}
+
+// Resolve returns the address name resolves to. It's an error if the name has no
+// resolver or resolves to the zero address.
+func (self *ENS) Resolve(name string) (common.Address, error) {
+	node := NameHash(name)
+
+	resolver, err := self.callAddress(self.registry, ensResolverAbi+common.Bytes2Hex(node[:]))
+	if err != nil {
+		return common.Address{}, err
+	}
+	if resolver.IsEmpty() {
+		return common.Address{}, fmt.Errorf("ENS: no resolver set for '%v'", name)
+	}
+	addr, err := self.callAddress(resolver, ensAddrAbi+common.Bytes2Hex(node[:]))
+	if err != nil {
+		return common.Address{}, err
+	}
+	if addr.IsEmpty() {
+		return common.Address{}, fmt.Errorf("ENS: '%v' does not resolve to an address", name)
+	}
+	return addr, nil
+}
+
+// callAddress calls a constant contract method returning an address.
+func (self *ENS) callAddress(contract common.Address, data string) (common.Address, error) {
+	res, _, err := self.backend.Call("", contract.Hex(), "", "", "", data)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(res) < 40 {
+		return common.Address{}, nil
+	}
+	return common.HexToAddress(res[len(res)-40:]), nil
+}
+
+// NameHash computes the ENS namehash of a name, see EIP-137. Labels are
+// lowercased but otherwise not normalised.
+func NameHash(name string) common.Hash {
+	var node common.Hash
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(strings.ToLower(name), ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = common.BytesToHash(crypto.Keccak256(node[:], crypto.Keccak256([]byte(labels[i]))))
+	}
+	return node
+}
+
+// IsName reports whether s looks like an ENS name rather than an address.
+func IsName(s string) bool {
+	return strings.Contains(s, ".") && !common.IsHexAddress(s)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/openether/ethcore/core/types"
 	"github.com/openether/ethcore/crypto"
 	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/event"
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
 )
@@ -28,16 +29,31 @@ type registryAPIBackend struct {
 	am      *accounts.Manager
 }
 
+// NewBackend creates a registrar backend executing calls against the current
+// head state and submitting transactions to the local pool.
+func NewBackend(config *core.ChainConfig, bc *core.BlockChain, chainDb ethdb.Database, txPool *core.TxPool, am *accounts.Manager) registrar.Backend {
+	return &registryAPIBackend{
+		config:  config,
+		bc:      bc,
+		chainDb: chainDb,
+		txPool:  txPool,
+		am:      am,
+	}
+}
+
 // PrivateRegistarAPI offers various functions to access the Ethereum registry.
 type PrivateRegistarAPI struct {
 	config *core.ChainConfig
 	be     *registryAPIBackend
+	cache  *registrar.Cache
 }
 
-// NewPrivateRegistarAPI creates a new PrivateRegistarAPI instance.
-func NewPrivateRegistarAPI(config *core.ChainConfig, bc *core.BlockChain, chainDb ethdb.Database, txPool *core.TxPool, am *accounts.Manager) *PrivateRegistarAPI {
+// NewPrivateRegistarAPI creates a new PrivateRegistarAPI instance. Name lookups
+// are served from the given cache, which may be shared between instances.
+func NewPrivateRegistarAPI(config *core.ChainConfig, bc *core.BlockChain, chainDb ethdb.Database, txPool *core.TxPool, am *accounts.Manager, cache *registrar.Cache) *PrivateRegistarAPI {
 	return &PrivateRegistarAPI{
 		config: config,
+		cache:  cache,
 		be: &registryAPIBackend{
 			config:  config,
 			bc:      bc,
@@ -96,6 +112,67 @@ func (api *PrivateRegistarAPI) RegisterUrl(sender common.Address, contentHashHex
 	return err == nil, err
 }
 
+// ResolveName returns the primary address registered for name in the global
+// registrar, or the zero address if there is none.
+func (api *PrivateRegistarAPI) ResolveName(name string) (common.Address, error) {
+	return registrar.NewCached(api.be, api.cache).NameToAddr(name)
+}
+
+// ReverseResolve returns the name whose primary address is addr in the global
+// registrar, or an empty string if there is none.
+func (api *PrivateRegistarAPI) ReverseResolve(addr common.Address) (string, error) {
+	return registrar.NewCached(api.be, api.cache).AddrToName(addr)
+}
+
+// ResolveNames resolves a batch of names, returning their primary addresses in
+// the same order.
+func (api *PrivateRegistarAPI) ResolveNames(names []string) ([]common.Address, error) {
+	reg := registrar.NewCached(api.be, api.cache)
+
+	addrs := make([]common.Address, len(names))
+	for i, name := range names {
+		addr, err := reg.NameToAddr(name)
+		if err != nil {
+			return nil, err
+		}
+		addrs[i] = addr
+	}
+	return addrs, nil
+}
+
+// ReverseResolveAddresses resolves the names of a batch of addresses, returning
+// them in the same order.
+func (api *PrivateRegistarAPI) ReverseResolveAddresses(addrs []common.Address) ([]string, error) {
+	reg := registrar.NewCached(api.be, api.cache)
+
+	names := make([]string, len(addrs))
+	for i, addr := range addrs {
+		name, err := reg.AddrToName(addr)
+		if err != nil {
+			return nil, err
+		}
+		names[i] = name
+	}
+	return names, nil
+}
+
+// InvalidateCacheOnLogs feeds the logs of imported and reorged blocks to the
+// cache, so that it forgets lookups changed by registrar transactions. It returns
+// once the event mux is stopped.
+func InvalidateCacheOnLogs(cache *registrar.Cache, mux *event.TypeMux) {
+	sub := mux.Subscribe(core.ChainEvent{}, core.RemovedLogsEvent{})
+	go func() {
+		for ev := range sub.Chan() {
+			switch ev := ev.Data.(type) {
+			case core.ChainEvent:
+				cache.ProcessLogs(ev.Logs)
+			case core.RemovedLogsEvent:
+				cache.ProcessLogs(ev.Logs)
+			}
+		}
+	}()
+}
+
 // callmsg is the message type used for call transations.
 type callmsg struct {
 	from          *state.StateObject
@@ -135,12 +212,17 @@ func (m callmsg) Data() []byte {
 // a private VM with a copy of the state. Any changes are therefore only temporary
 // and not part of the actual state. This allows for local execution/queries.
 func (be *registryAPIBackend) Call(fromStr, toStr, valueStr, gasStr, gasPriceStr, dataStr string) (string, string, error) {
-	value, ok := new(big.Int).SetString(valueStr, 0)
-	if !ok {
-		return "", "", fmt.Errorf("malformed value %q", valueStr)
+	value := new(big.Int)
+	if valueStr != "" {
+		if _, ok := value.SetString(valueStr, 0); !ok {
+			return "", "", fmt.Errorf("malformed value %q", valueStr)
+		}
 	}
 
-	var gas *big.Int
+	var (
+		gas = new(big.Int)
+		ok  bool
+	)
 	if gasStr != "" {
 		gas, ok = new(big.Int).SetString(gasStr, 0)
 		if !ok {
@@ -148,7 +230,7 @@ func (be *registryAPIBackend) Call(fromStr, toStr, valueStr, gasStr, gasPriceStr
 		}
 	}
 
-	var gasPrice *big.Int
+	gasPrice := new(big.Int)
 	if gasPriceStr != "" {
 		gasPrice, ok = new(big.Int).SetString(gasPriceStr, 0)
 		if !ok {
@@ -63,6 +63,7 @@ var (
 	setOwnerAbi            = abiSignature("setowner()")
 	reserveAbi             = abiSignature("reserve(bytes32)")
 	resolveAbi             = abiSignature("addr(bytes32)")
+	reverseAbi             = abiSignature("name(address)")
 	registerAbi            = abiSignature("setAddress(bytes32,address,bool)")
 	addressAbiPrefix       = falseHex[:24]
 )
@@ -83,13 +84,20 @@ type VersionedRegistrar interface {
 
 type Registrar struct {
 	backend Backend
+	cache   *Cache
 }
 
 func New(b Backend) (res *Registrar) {
-	res = &Registrar{b}
+	res = &Registrar{backend: b}
 	return
 }
 
+// NewCached creates a Registrar which serves name and reverse lookups from the
+// given cache when possible.
+func NewCached(b Backend, cache *Cache) *Registrar {
+	return &Registrar{backend: b, cache: cache}
+}
+
 func (self *Registrar) SetGlobalRegistrar(namereg string, addr common.Address) (txhash string, err error) {
 	if namereg != "" {
 		GlobalRegistrarAddr = namereg
@@ -281,6 +289,60 @@ func (self *Registrar) HashToHash(khash common.Hash) (chash common.Hash, err err
 	return
 }
 
+// NameToAddr(name) resolves the primary address of a name using the GlobalRegistrar.
+// The zero address is returned if the name has no primary address.
+func (self *Registrar) NameToAddr(name string) (address common.Address, err error) {
+	if zero.MatchString(GlobalRegistrarAddr) {
+		return common.Address{}, fmt.Errorf("GlobalRegistrar address is not set")
+	}
+	if len(name) > 32 {
+		return common.Address{}, fmt.Errorf("NameToAddr: name '%v' longer than 32 bytes", name)
+	}
+	if self.cache != nil {
+		if address, ok := self.cache.getAddr(name); ok {
+			return address, nil
+		}
+	}
+	nameHex, _ := encodeName(name, 2)
+	res, _, err := self.backend.Call("", GlobalRegistrarAddr, "", "", "", resolveAbi+nameHex)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(res) >= 40 {
+		address = common.HexToAddress(res[len(res)-40:])
+	}
+	if self.cache != nil {
+		self.cache.addAddr(name, address)
+	}
+	return address, nil
+}
+
+// AddrToName(address) resolves the name an address is the primary address of
+// (reverse resolution) using the GlobalRegistrar. An empty name is returned if
+// no name points to the address.
+func (self *Registrar) AddrToName(address common.Address) (name string, err error) {
+	if zero.MatchString(GlobalRegistrarAddr) {
+		return "", fmt.Errorf("GlobalRegistrar address is not set")
+	}
+	if self.cache != nil {
+		if name, ok := self.cache.getName(address); ok {
+			return name, nil
+		}
+	}
+	data := reverseAbi + addressAbiPrefix + common.Bytes2Hex(address[:])
+	res, _, err := self.backend.Call("", GlobalRegistrarAddr, "", "", "", data)
+	if err != nil {
+		return "", err
+	}
+	if b := common.FromHex(res); len(b) >= 32 {
+		name = decodeName(b[:32])
+	}
+	if self.cache != nil {
+		self.cache.addName(address, name)
+	}
+	return name, nil
+}
+
 func storageIdx2Addr(varidx uint32) []byte {
 	data := make([]byte, 32)
 	binary.BigEndian.PutUint32(data[28:32], varidx)
@@ -20,12 +20,16 @@ import (
 	"testing"
 
 	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core/vm"
 	"github.com/ethereumclassic/go-ethereum/crypto"
 )
 
 type testBackend struct {
 	// contracts mock
 	contracts map[string](map[string]string)
+	// call results mock, keyed by call data
+	calls     map[string]string
+	callCount int
 }
 
 var (
@@ -71,7 +75,8 @@ func (self *testBackend) Transact(fromStr, toStr, nonceStr, valueStr, gasStr, ga
 }
 
 func (self *testBackend) Call(fromStr, toStr, valueStr, gasStr, gasPriceStr, codeStr string) (string, string, error) {
-	return "", "", nil
+	self.callCount++
+	return self.calls[codeStr], "", nil
 }
 
 func TestSetGlobalRegistrar(t *testing.T) {
@@ -120,6 +125,82 @@ func TestHashToHash(t *testing.T) {
 	}
 }
 
+func TestNameResolution(t *testing.T) {
+	b := NewTestBackend()
+	cache := NewCache(16)
+	res := NewCached(b, cache)
+
+	GlobalRegistrarAddr = common.BigToAddress(common.Big1).Hex()
+	registrar := common.HexToAddress(GlobalRegistrarAddr)
+	addr := common.BigToAddress(common.Big2)
+
+	nameHex, _ := encodeName(text, 2)
+	b.calls = map[string]string{
+		resolveAbi + nameHex: common.ToHex(common.LeftPadBytes(addr[:], 32)),
+		reverseAbi + addressAbiPrefix + common.Bytes2Hex(addr[:]): common.ToHex(common.RightPadBytes([]byte(text), 32)),
+	}
+	for i := 0; i < 2; i++ {
+		if got, err := res.NameToAddr(text); err != nil || got != addr {
+			t.Errorf("NameToAddr: expected %x, got %x (err %v)", addr, got, err)
+		}
+		if got, err := res.AddrToName(addr); err != nil || got != text {
+			t.Errorf("AddrToName: expected '%v', got '%v' (err %v)", text, got, err)
+		}
+	}
+	if b.callCount != 2 {
+		t.Errorf("expected 2 calls with cached lookups, got %d", b.callCount)
+	}
+
+	// Changing the name's registration must invalidate both lookups
+	var topic common.Hash
+	copy(topic[:], text)
+	cache.ProcessLogs(vm.Logs{{Address: registrar, Topics: []common.Hash{changedTopic, topic}}})
+	delete(b.calls, resolveAbi+nameHex)
+	if got, err := res.NameToAddr(text); err != nil || got != (common.Address{}) {
+		t.Errorf("NameToAddr after change: expected zero address, got %x (err %v)", got, err)
+	}
+	res.AddrToName(addr)
+	if b.callCount != 4 {
+		t.Errorf("expected 4 calls after invalidation, got %d", b.callCount)
+	}
+}
+
+func TestNameHash(t *testing.T) {
+	tests := map[string]string{
+		"":        "0x0000000000000000000000000000000000000000000000000000000000000000",
+		"eth":     "0x93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae",
+		"foo.eth": "0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f",
+	}
+	for name, want := range tests {
+		if got := NameHash(name).Hex(); got != want {
+			t.Errorf("namehash of '%v': expected %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestENSResolve(t *testing.T) {
+	b := NewTestBackend()
+	registry := common.BigToAddress(common.Big1)
+	resolver := common.BigToAddress(common.Big2)
+	addr := common.BigToAddress(common.Big3)
+	ens := NewENS(b, registry)
+
+	node := NameHash("foo.eth")
+	b.calls = map[string]string{
+		ensResolverAbi + common.Bytes2Hex(node[:]): common.ToHex(common.LeftPadBytes(resolver[:], 32)),
+	}
+	if _, err := ens.Resolve("foo.eth"); err == nil {
+		t.Errorf("expected error for name without address")
+	}
+	b.calls[ensAddrAbi+common.Bytes2Hex(node[:])] = common.ToHex(common.LeftPadBytes(addr[:], 32))
+	if got, err := ens.Resolve("foo.eth"); err != nil || got != addr {
+		t.Errorf("expected %x, got %x (err %v)", addr, got, err)
+	}
+	if _, err := ens.Resolve("bar.eth"); err == nil {
+		t.Errorf("expected error for name without resolver")
+	}
+}
+
 func storageFixedArray(addr, idx []byte) []byte {
 	var carry byte
 	for i := 31; i >= 0; i-- {
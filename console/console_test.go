@@ -33,6 +33,7 @@ import (
 	"github.com/ethereumclassic/go-ethereum/eth"
 	"github.com/ethereumclassic/go-ethereum/logger/glog"
 	"github.com/ethereumclassic/go-ethereum/node"
+	"github.com/ethereumclassic/go-ethereum/pow"
 )
 
 const (
@@ -108,7 +109,7 @@ func newTester(t *testing.T, confOverride func(*eth.Config)) *tester {
 		ChainConfig:    core.DefaultConfigMainnet.ChainConfig,
 		Etherbase:      common.HexToAddress(testAddress),
 		AccountManager: accman,
-		PowTest:        true,
+		Sealer:         pow.Config{Mode: pow.ModeTest},
 	}
 	if confOverride != nil {
 		confOverride(ethConf)
@@ -15,6 +15,7 @@ import (
 
 	"github.com/openether/ethcore/common"
 	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/crypto"
 	"github.com/openether/ethcore/ethdb"
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
@@ -26,6 +27,8 @@ var (
 
 	txAddressIndexPrefix = []byte("atx-")
 	txAddressBookmarkKey = []byte("ATXIBookmark")
+
+	contractCreationPrefix = []byte("ctx-") // ctx-<contract address> -> blockNumber(8)+creator(20)+txhash(32)
 )
 
 type AtxiT struct {
@@ -133,10 +136,55 @@ func putBlockAddrTxsToBatch(putBatch ethdb.Batch, block *types.Block) (txsCount
 		if err := putBatch.Put(formatAddrTxBytesIndex(to.Bytes(), bn, []byte("t"), txKindOf, tx.Hash().Bytes()), nil); err != nil {
 			return txsCount, err
 		}
+		if tx.To() == nil {
+			if err := putBatch.Put(formatContractCreationKey(crypto.CreateAddress(from, tx.Nonce())), formatContractCreationValue(bn, from, tx.Hash())); err != nil {
+				return txsCount, err
+			}
+		}
 	}
 	return txsCount, nil
 }
 
+// ContractCreation is the transaction which created a contract.
+type ContractCreation struct {
+	TxHash      common.Hash
+	Creator     common.Address
+	BlockNumber uint64
+}
+
+// formatContractCreationKey formats the contract creation index key, eg. ctx-<address>
+func formatContractCreationKey(contract common.Address) []byte {
+	return append(append([]byte{}, contractCreationPrefix...), contract.Bytes()...)
+}
+
+// formatContractCreationValue formats the contract creation index value, eg. <blockNumber><creator><txhash>
+func formatContractCreationValue(blockNumber []byte, creator common.Address, txhash common.Hash) []byte {
+	value := make([]byte, 0, 60) // blockNumber(8)+creator(20)+txhash(32)
+	value = append(value, blockNumber...)
+	value = append(value, creator.Bytes()...)
+	value = append(value, txhash.Bytes()...)
+	return value
+}
+
+// GetContractCreation returns the transaction which created the given contract,
+// or nil if the address wasn't created by an indexed transaction. Only contracts
+// created directly by a transaction are indexed, not those created by other
+// contracts.
+func GetContractCreation(db ethdb.Database, contract common.Address) (*ContractCreation, error) {
+	value, err := db.Get(formatContractCreationKey(contract))
+	if err != nil || len(value) == 0 {
+		return nil, nil
+	}
+	if len(value) != 60 {
+		return nil, fmt.Errorf("invalid contract creation index entry for %x: length %d", contract, len(value))
+	}
+	return &ContractCreation{
+		BlockNumber: binary.LittleEndian.Uint64(value[:8]),
+		Creator:     common.BytesToAddress(value[8:28]),
+		TxHash:      common.BytesToHash(value[28:]),
+	}, nil
+}
+
 type atxi struct {
 	blockN uint64
 	tx     string
@@ -452,6 +500,13 @@ func RmAddrTx(db ethdb.Database, tx *types.Transaction) error {
 		}
 	}
 
+	if to == nil {
+		contract := crypto.CreateAddress(from, tx.Nonce())
+		if creation, err := GetContractCreation(db, contract); err == nil && creation != nil && creation.TxHash == txH {
+			removals = append(removals, formatContractCreationKey(contract))
+		}
+	}
+
 	for _, r := range removals {
 		if err := db.Delete(r); err != nil {
 			return err
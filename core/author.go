@@ -0,0 +1,16 @@
+package core
+
+import (
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+)
+
+// BlockAuthor returns the account that sealed the block with the given header.
+// Ethash is the only consensus engine in this tree, and it credits the coinbase
+// with the block reward, so the author is the coinbase. There is no clique or
+// other signer-based engine here, whose authors would have to be recovered from
+// the seal instead; consumers go through BlockAuthor so that such an engine
+// needs to change this function only.
+func BlockAuthor(header *types.Header) common.Address {
+	return header.Coinbase
+}
@@ -0,0 +1,16 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+)
+
+// Tests that the author of an ethash block is its coinbase.
+func TestBlockAuthor(t *testing.T) {
+	coinbase := common.HexToAddress("0x1234")
+	if author := BlockAuthor(&types.Header{Coinbase: coinbase}); author != coinbase {
+		t.Errorf("author mismatch: have %x, want %x", author, coinbase)
+	}
+}
@@ -0,0 +1,272 @@
+package core
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/rlp"
+)
+
+const (
+	ArchiveBlocks    = 8192   // Number of blocks in every archive, archives start at multiples of it
+	ArchiveExtension = ".era" // File name extension of archives
+
+	blockArchiveVersion         = 1
+	archiveHeaderCheckFrequency = 100 // Proof-of-work verification frequency of archived headers, as during fast sync
+)
+
+var errArchiveTruncated = errors.New("archive truncated")
+
+// BlockArchive is a run of ArchiveBlocks canonical blocks along with their
+// receipts, as stored in a compressed archive file. Archives of historical
+// blocks can seed the chain of a node without downloading them from peers.
+//
+// An archive can be verified on its own: the transactions, uncles and receipts
+// of every block are checked against its header and the blocks against the
+// parent hashes of their successors, so the hash of the last block, which is
+// part of the archive file name, authenticates the whole archive.
+type BlockArchive struct {
+	First    uint64
+	Last     common.Hash
+	Blocks   types.Blocks
+	Receipts []types.Receipts
+}
+
+// blockArchiveHeader precedes the blocks of an archive file.
+type blockArchiveHeader struct {
+	Version uint64
+	First   uint64
+	Count   uint64
+	Last    common.Hash
+}
+
+// blockArchiveEntry is a block of an archive file.
+type blockArchiveEntry struct {
+	Block    *types.Block
+	Receipts []*types.ReceiptForStorage
+}
+
+// ArchiveName returns the file name of the archive starting at the given block
+// and ending with the block of the given hash.
+func ArchiveName(first uint64, last common.Hash) string {
+	return fmt.Sprintf("blocks-%09d-%x%s", first, last[:4], ArchiveExtension)
+}
+
+// WriteArchive writes the compressed archive of the ArchiveBlocks canonical
+// blocks starting at the given one and returns the hash of the last of them.
+func WriteArchive(db ethdb.Database, w io.Writer, first uint64) (common.Hash, error) {
+	if first%ArchiveBlocks != 0 {
+		return common.Hash{}, fmt.Errorf("archive start #%d not a multiple of %d", first, ArchiveBlocks)
+	}
+	last := GetCanonicalHash(db, first+ArchiveBlocks-1)
+	if last == (common.Hash{}) {
+		return common.Hash{}, fmt.Errorf("block #%d unknown", first+ArchiveBlocks-1)
+	}
+	zw := gzip.NewWriter(w)
+	if err := rlp.Encode(zw, &blockArchiveHeader{Version: blockArchiveVersion, First: first, Count: ArchiveBlocks, Last: last}); err != nil {
+		return common.Hash{}, err
+	}
+	for number := first; number < first+ArchiveBlocks; number++ {
+		block := GetBlock(db, GetCanonicalHash(db, number))
+		if block == nil {
+			return common.Hash{}, fmt.Errorf("block #%d unknown", number)
+		}
+		receipts := GetBlockReceipts(db, block.Hash())
+		if len(receipts) != len(block.Transactions()) {
+			return common.Hash{}, fmt.Errorf("receipts of block #%d unknown", number)
+		}
+		entry := blockArchiveEntry{Block: block, Receipts: make([]*types.ReceiptForStorage, len(receipts))}
+		for i, receipt := range receipts {
+			entry.Receipts[i] = (*types.ReceiptForStorage)(receipt)
+		}
+		if err := rlp.Encode(zw, &entry); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	return last, zw.Close()
+}
+
+// ReadArchive decodes and verifies a compressed archive.
+func ReadArchive(r io.Reader) (*BlockArchive, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	stream := rlp.NewStream(zr, 0)
+	var header blockArchiveHeader
+	if err := stream.Decode(&header); err != nil {
+		return nil, fmt.Errorf("invalid archive header: %v", err)
+	}
+	if header.Version != blockArchiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", header.Version)
+	}
+	if header.Count != ArchiveBlocks || header.First%ArchiveBlocks != 0 {
+		return nil, fmt.Errorf("invalid archive range #%d+%d", header.First, header.Count)
+	}
+	archive := &BlockArchive{
+		First:    header.First,
+		Last:     header.Last,
+		Blocks:   make(types.Blocks, 0, header.Count),
+		Receipts: make([]types.Receipts, 0, header.Count),
+	}
+	for i := uint64(0); i < header.Count; i++ {
+		var entry blockArchiveEntry
+		if err := stream.Decode(&entry); err == io.EOF {
+			return nil, errArchiveTruncated
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid archive block %d: %v", i, err)
+		}
+		receipts := make(types.Receipts, len(entry.Receipts))
+		for j, receipt := range entry.Receipts {
+			receipts[j] = (*types.Receipt)(receipt)
+		}
+		archive.Blocks = append(archive.Blocks, entry.Block)
+		archive.Receipts = append(archive.Receipts, receipts)
+	}
+	if _, err := stream.Raw(); err != io.EOF {
+		return nil, errors.New("trailing data after archive blocks")
+	}
+	if err := archive.Verify(); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// Verify checks the consistency of the archive, see BlockArchive.
+func (a *BlockArchive) Verify() error {
+	if len(a.Blocks) != ArchiveBlocks || len(a.Receipts) != len(a.Blocks) {
+		return errArchiveTruncated
+	}
+	for i, block := range a.Blocks {
+		header := block.Header()
+		if want := a.First + uint64(i); block.NumberU64() != want {
+			return fmt.Errorf("archive block %d numbered #%d, want #%d", i, block.NumberU64(), want)
+		}
+		if i > 0 && header.ParentHash != a.Blocks[i-1].Hash() {
+			return fmt.Errorf("archive block #%d not a child of #%d", block.NumberU64(), block.NumberU64()-1)
+		}
+		if hash := types.DeriveSha(block.Transactions()); hash != header.TxHash {
+			return fmt.Errorf("archive block #%d transaction root mismatch: have %x, want %x", block.NumberU64(), hash, header.TxHash)
+		}
+		if hash := types.CalcUncleHash(block.Uncles()); hash != header.UncleHash {
+			return fmt.Errorf("archive block #%d uncle hash mismatch: have %x, want %x", block.NumberU64(), hash, header.UncleHash)
+		}
+		if len(a.Receipts[i]) != len(block.Transactions()) {
+			return fmt.Errorf("archive block #%d has %d receipts for %d transactions", block.NumberU64(), len(a.Receipts[i]), len(block.Transactions()))
+		}
+		if hash := types.DeriveSha(a.Receipts[i]); hash != header.ReceiptHash {
+			return fmt.Errorf("archive block #%d receipt root mismatch: have %x, want %x", block.NumberU64(), hash, header.ReceiptHash)
+		}
+	}
+	if hash := a.Blocks[len(a.Blocks)-1].Hash(); hash != a.Last {
+		return fmt.Errorf("archive last block hash mismatch: have %x, want %x", hash, a.Last)
+	}
+	return nil
+}
+
+// ExportArchives writes the archives of the canonical blocks in [first, last]
+// into the given directory. Only complete archives are written, archives
+// already present are skipped.
+func ExportArchives(db ethdb.Database, dir string, first, last uint64) (int, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	written := 0
+	for start := first - first%ArchiveBlocks; start+ArchiveBlocks-1 <= last; start += ArchiveBlocks {
+		if start < first {
+			continue
+		}
+		lastHash := GetCanonicalHash(db, start+ArchiveBlocks-1)
+		if lastHash == (common.Hash{}) {
+			break
+		}
+		path := filepath.Join(dir, ArchiveName(start, lastHash))
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if err := exportArchive(db, path, start); err != nil {
+			return written, err
+		}
+		written++
+		glog.V(logger.Info).Infof("Archived blocks #%d-#%d to %s", start, start+ArchiveBlocks-1, path)
+	}
+	return written, nil
+}
+
+// exportArchive writes an archive into a temporary file renamed into place once
+// complete.
+func exportArchive(db ethdb.Database, path string, first uint64) error {
+	fh, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := WriteArchive(db, fh, first); err != nil {
+		fh.Close()
+		os.Remove(fh.Name())
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// ListArchives returns the archive files in the given directory by first block.
+func ListArchives(dir string) (map[uint64]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "blocks-*"+ArchiveExtension))
+	if err != nil {
+		return nil, err
+	}
+	archives := make(map[uint64]string)
+	for _, file := range files {
+		var (
+			first uint64
+			last  string
+		)
+		name := strings.TrimSuffix(filepath.Base(file), ArchiveExtension)
+		if _, err := fmt.Sscanf(name, "blocks-%d-%s", &first, &last); err != nil || first%ArchiveBlocks != 0 {
+			continue
+		}
+		archives[first] = file
+	}
+	return archives, nil
+}
+
+// InsertArchive imports the headers, blocks and receipts of a verified archive,
+// as fast sync does, without processing the blocks. The archive must start at
+// most at the block after the head fast block, blocks up to it are skipped.
+func (bc *BlockChain) InsertArchive(archive *BlockArchive) error {
+	head := bc.CurrentFastBlock().NumberU64()
+	if archive.First > head+1 {
+		return fmt.Errorf("archive starts at #%d, beyond head #%d", archive.First, head)
+	}
+	skip := head + 1 - archive.First
+	if skip >= uint64(len(archive.Blocks)) {
+		return nil
+	}
+	blocks, receipts := archive.Blocks[skip:], archive.Receipts[skip:]
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if res := bc.InsertHeaderChain(headers, archiveHeaderCheckFrequency); res.Error != nil {
+		return fmt.Errorf("archive header #%d: %v", headers[res.Index].Number, res.Error)
+	}
+	if res := bc.InsertReceiptChain(blocks, receipts); res.Error != nil {
+		return fmt.Errorf("archive block #%d: %v", blocks[res.Index].Number(), res.Error)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/ethdb"
+)
+
+// writeArchiveTestChain stores a canonical chain of the given length, with a
+// transaction and its receipt in every hundredth block.
+func writeArchiveTestChain(t *testing.T, db ethdb.Database, n int) {
+	var parent common.Hash
+	for i := 0; i < n; i++ {
+		header := &types.Header{ParentHash: parent, Number: big.NewInt(int64(i)), Difficulty: big.NewInt(1), GasLimit: big.NewInt(5000), GasUsed: new(big.Int), Time: big.NewInt(int64(i))}
+		var (
+			txs      []*types.Transaction
+			receipts []*types.Receipt
+		)
+		if i%100 == 0 {
+			txs = append(txs, types.NewTransaction(uint64(i), common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil))
+			receipts = append(receipts, &types.Receipt{PostState: []byte{byte(i)}, CumulativeGasUsed: big.NewInt(21000), GasUsed: big.NewInt(21000), TxHash: txs[0].Hash()})
+		}
+		block := types.NewBlock(header, txs, nil, receipts)
+		if err := WriteBlock(db, block); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteBlockReceipts(db, block.Hash(), receipts); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteCanonicalHash(db, block.Hash(), block.NumberU64()); err != nil {
+			t.Fatal(err)
+		}
+		parent = block.Hash()
+	}
+}
+
+func TestBlockArchiveRoundTrip(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	writeArchiveTestChain(t, db, ArchiveBlocks+10)
+
+	var buf bytes.Buffer
+	last, err := WriteArchive(db, &buf, 0)
+	if err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	if want := GetCanonicalHash(db, ArchiveBlocks-1); last != want {
+		t.Fatalf("last hash mismatch: have %x, want %x", last, want)
+	}
+	if _, err := WriteArchive(db, ioutil.Discard, ArchiveBlocks); err == nil {
+		t.Errorf("incomplete archive written")
+	}
+	archive, err := ReadArchive(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	for i, block := range archive.Blocks {
+		if block.Hash() != GetCanonicalHash(db, uint64(i)) {
+			t.Fatalf("block %d mismatch", i)
+		}
+	}
+	if receipts := archive.Receipts[100]; len(receipts) != 1 || receipts[0].CumulativeGasUsed.Cmp(big.NewInt(21000)) != 0 {
+		t.Errorf("receipts of block 100 mismatch: %v", receipts)
+	}
+	// Tampering with the receipts or the chain must be detected
+	archive.Receipts[100][0].CumulativeGasUsed = big.NewInt(1)
+	if err := archive.Verify(); err == nil {
+		t.Errorf("tampered receipt passed verification")
+	}
+	archive.Receipts[100][0].CumulativeGasUsed = big.NewInt(21000)
+	archive.Last = common.Hash{0x01}
+	if err := archive.Verify(); err == nil {
+		t.Errorf("wrong last hash passed verification")
+	}
+	if _, err := ReadArchive(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); err == nil {
+		t.Errorf("truncated archive read")
+	}
+}
+
+func TestExportArchives(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	writeArchiveTestChain(t, db, ArchiveBlocks+10)
+
+	dir, err := ioutil.TempDir("", "archives")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if n, err := ExportArchives(db, dir, 0, ArchiveBlocks+9); err != nil || n != 1 {
+		t.Fatalf("export failed: %d archives, %v", n, err)
+	}
+	if n, err := ExportArchives(db, dir, 0, ArchiveBlocks+9); err != nil || n != 0 {
+		t.Fatalf("existing archive exported again: %d archives, %v", n, err)
+	}
+	archives, err := ListArchives(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dir, ArchiveName(0, GetCanonicalHash(db, ArchiveBlocks-1)))
+	if len(archives) != 1 || archives[0] != want {
+		t.Fatalf("archives mismatch: have %v, want %s", archives, want)
+	}
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
@@ -23,6 +24,7 @@ import (
 	"github.com/openether/ethcore/crypto"
 	"github.com/openether/ethcore/ethdb"
 	"github.com/openether/ethcore/event"
+	"github.com/openether/ethcore/internal/tracing"
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
 	"github.com/openether/ethcore/rlp"
@@ -43,6 +45,7 @@ const (
 	bodyCacheLimit      = 256
 	tdCacheLimit        = 1024
 	blockCacheLimit     = 256
+	receiptsCacheLimit  = 32
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	// must be bumped when consensus algorithm is changed, this forces the upgradedb
@@ -85,6 +88,10 @@ type BlockChain struct {
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
 	futureBlocks *lru.Cache     // future blocks are blocks added for later processing
 
+	receiptsCache *lru.Cache // Cache for the most recent block receipts
+
+	bodyMeter, bodyRLPMeter, blockMeter, receiptsMeter cacheMeter // Cache hit and miss counters
+
 	quit    chan struct{} // blockchain quit channel
 	running int32         // running must be called atomically
 	// procInterrupt must be atomically called
@@ -94,6 +101,12 @@ type BlockChain struct {
 	processor Processor // block processor interface
 	validator Validator // block and state validator interface
 
+	freeze     importFreeze // pauses block import on operator request
+	lastImport int64        // Unix time in nanoseconds the last insertion finished at, atomically accessed
+
+	txLookupVersion uint64 // Scheme the transaction lookups are written with
+	txLookupLimit   uint64 // Number of recent blocks whose transactions are indexed, zero for all
+
 	atxi *AtxiT
 }
 
@@ -131,20 +144,27 @@ func NewBlockChain(chainDb ethdb.Database, config *ChainConfig, mux *event.TypeM
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
+	receiptsCache, _ := lru.New(receiptsCacheLimit)
 
 	bc := &BlockChain{
-		config:       config,
-		chainDb:      chainDb,
-		eventMux:     mux,
-		quit:         make(chan struct{}),
-		bodyCache:    bodyCache,
-		bodyRLPCache: bodyRLPCache,
-		blockCache:   blockCache,
-		futureBlocks: futureBlocks,
+		config:        config,
+		chainDb:       chainDb,
+		eventMux:      mux,
+		quit:          make(chan struct{}),
+		bodyCache:     bodyCache,
+		bodyRLPCache:  bodyRLPCache,
+		blockCache:    blockCache,
+		futureBlocks:  futureBlocks,
+		receiptsCache: receiptsCache,
+		lastImport:    time.Now().UnixNano(),
 	}
 	bc.SetValidator(NewBlockValidator(config, bc))
 	bc.SetProcessor(NewStateProcessor(config, bc))
 
+	// Complete any head pointer update interrupted by a crash before reading the heads
+	if err := recoverHeadJournal(chainDb); err != nil {
+		return nil, err
+	}
 	gv := func() HeaderValidator { return bc.Validator() }
 	var err error
 	bc.hc, err = NewHeaderChain(chainDb, config, mux, gv, bc.getProcInterrupt)
@@ -160,6 +180,9 @@ func NewBlockChain(chainDb ethdb.Database, config *ChainConfig, mux *event.TypeM
 	if err := bc.LoadLastState(false); err != nil {
 		return nil, err
 	}
+	if err := bc.initTxLookupVersion(); err != nil {
+		return nil, err
+	}
 	// Check the current state of the block hashes and make sure that we do not have any of the bad blocks in our chain
 	for i := range config.BadHashes {
 		if header := bc.GetHeader(config.BadHashes[i].Hash); header != nil && header.Number.Cmp(config.BadHashes[i].Block) == 0 {
@@ -170,6 +193,13 @@ func NewBlockChain(chainDb ethdb.Database, config *ChainConfig, mux *event.TypeM
 	}
 	// Take ownership of this particular state
 	go bc.update()
+
+	// Preload the caches that were hot when the node was last shut down
+	bc.wg.Add(1)
+	go func() {
+		defer bc.wg.Done()
+		bc.warmCaches()
+	}()
 	return bc, nil
 }
 
@@ -178,16 +208,18 @@ func NewBlockChainDryrun(chainDb ethdb.Database, config *ChainConfig, mux *event
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
+	receiptsCache, _ := lru.New(receiptsCacheLimit)
 
 	bc := &BlockChain{
-		config:       config,
-		chainDb:      chainDb,
-		eventMux:     mux,
-		quit:         make(chan struct{}),
-		bodyCache:    bodyCache,
-		bodyRLPCache: bodyRLPCache,
-		blockCache:   blockCache,
-		futureBlocks: futureBlocks,
+		config:        config,
+		chainDb:       chainDb,
+		eventMux:      mux,
+		quit:          make(chan struct{}),
+		bodyCache:     bodyCache,
+		bodyRLPCache:  bodyRLPCache,
+		blockCache:    blockCache,
+		futureBlocks:  futureBlocks,
+		receiptsCache: receiptsCache,
 	}
 	bc.SetValidator(NewBlockValidator(config, bc))
 	bc.SetProcessor(NewStateProcessor(config, bc))
@@ -768,6 +800,7 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.bodyRLPCache.Purge()
 	bc.blockCache.Purge()
 	bc.futureBlocks.Purge()
+	bc.receiptsCache.Purge()
 
 	// Rewind the block chain, ensuring we don't end up with a stateless head block
 	if bc.currentBlock != nil && currentHeader.Number.Uint64() < bc.currentBlock.NumberU64() {
@@ -791,11 +824,8 @@ func (bc *BlockChain) SetHead(head uint64) error {
 		bc.currentFastBlock = bc.genesisBlock
 	}
 
-	if err := WriteHeadBlockHash(bc.chainDb, bc.currentBlock.Hash()); err != nil {
-		glog.Fatalf("failed to reset head block hash: %v", err)
-	}
-	if err := WriteHeadFastBlockHash(bc.chainDb, bc.currentFastBlock.Hash()); err != nil {
-		glog.Fatalf("failed to reset head fast block hash: %v", err)
+	if err := writeHeadPointers(bc.chainDb, headPointers{Block: bc.currentBlock.Hash(), Fast: bc.currentFastBlock.Hash()}); err != nil {
+		glog.Fatalf("failed to reset head block hashes: %v", err)
 	}
 
 	if bc.atxi != nil && bc.atxi.AutoMode {
@@ -845,6 +875,13 @@ func (bc *BlockChain) SetHead(head uint64) error {
 			}
 		}
 	}
+	// The internal transactions of the rewound blocks are indexed again as they're reimported
+	if bc.atxi != nil {
+		if err := rewindInternalTxIndex(bc.atxi.Db, head+1); err != nil {
+			bc.mu.Unlock()
+			return err
+		}
+	}
 
 	bc.mu.Unlock()
 	return bc.LoadLastState(false)
@@ -1001,18 +1038,42 @@ func (bc *BlockChain) ExportN(w io.Writer, first uint64, last uint64) error {
 
 	glog.V(logger.Info).Infof("exporting %d blocks...\n", last-first+1)
 
+	bw := bufio.NewWriter(w)
 	for nr := first; nr <= last; nr++ {
-		block := bc.GetBlockByNumber(nr)
-		if block == nil {
-			return fmt.Errorf("export failed on #%d: not found", nr)
-		}
-
-		if err := block.EncodeRLP(w); err != nil {
-			return err
+		if err := bc.exportBlock(bw, nr); err != nil {
+			return fmt.Errorf("export failed on #%d: %v", nr, err)
 		}
 	}
 
-	return nil
+	return bw.Flush()
+}
+
+// exportBlock writes the RLP encoding of the canonical block with the given
+// number, streaming the stored header and body instead of decoding and
+// re-encoding the whole block.
+func (bc *BlockChain) exportBlock(w io.Writer, number uint64) error {
+	hash := GetCanonicalHash(bc.chainDb, number)
+	if hash == (common.Hash{}) {
+		return errors.New("not found")
+	}
+	header, body := GetHeaderRLP(bc.chainDb, hash), GetBodyRLP(bc.chainDb, hash)
+	if len(header) == 0 || len(body) == 0 {
+		return errors.New("not found")
+	}
+	// A block is the list of its header, transactions and uncles, the latter
+	// two being the content of the body list.
+	content, _, err := rlp.SplitList(body)
+	if err != nil {
+		return fmt.Errorf("invalid body: %v", err)
+	}
+	if err := rlp.WriteListHeader(w, uint64(len(header)+len(content))); err != nil {
+		return err
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
 }
 
 // insert injects a new head block into the current block chain. This method
@@ -1029,18 +1090,18 @@ func (bc *BlockChain) insert(block *types.Block) {
 	if err := WriteCanonicalHash(bc.chainDb, block.Hash(), block.NumberU64()); err != nil {
 		glog.Fatalf("failed to insert block number: %v", err)
 	}
-	if err := WriteHeadBlockHash(bc.chainDb, block.Hash()); err != nil {
-		glog.Fatalf("failed to insert head block hash: %v", err)
-	}
-	bc.currentBlock = block
+	heads := headPointers{Block: block.Hash()}
 
 	// If the block is better than our head or is on a different chain, force update heads
 	if updateHeads {
-		bc.hc.SetCurrentHeader(block.Header())
-
-		if err := WriteHeadFastBlockHash(bc.chainDb, block.Hash()); err != nil {
-			glog.Fatalf("failed to insert head fast block hash: %v", err)
-		}
+		heads.Header, heads.Fast = block.Hash(), block.Hash()
+	}
+	if err := writeHeadPointers(bc.chainDb, heads); err != nil {
+		glog.Fatalf("failed to insert head block hashes: %v", err)
+	}
+	bc.currentBlock = block
+	if updateHeads {
+		bc.hc.setCurrentHeader(block.Header())
 		bc.currentFastBlock = block
 	}
 }
@@ -1055,9 +1116,11 @@ func (bc *BlockChain) Genesis() *types.Block {
 func (bc *BlockChain) GetBody(hash common.Hash) *types.Body {
 	// Short circuit if the body's already in the cache, retrieve otherwise
 	if cached, ok := bc.bodyCache.Get(hash); ok {
+		bc.bodyMeter.hit()
 		body := cached.(*types.Body)
 		return body
 	}
+	bc.bodyMeter.miss()
 	body := GetBody(bc.chainDb, hash)
 	if body == nil {
 		return nil
@@ -1072,8 +1135,10 @@ func (bc *BlockChain) GetBody(hash common.Hash) *types.Body {
 func (bc *BlockChain) GetBodyRLP(hash common.Hash) rlp.RawValue {
 	// Short circuit if the body's already in the cache, retrieve otherwise
 	if cached, ok := bc.bodyRLPCache.Get(hash); ok {
+		bc.bodyRLPMeter.hit()
 		return cached.(rlp.RawValue)
 	}
+	bc.bodyRLPMeter.miss()
 	body := GetBodyRLP(bc.chainDb, hash)
 	if len(body) == 0 {
 		return nil
@@ -1106,8 +1171,10 @@ func (bc *BlockChain) HasBlockAndState(hash common.Hash) bool {
 func (bc *BlockChain) GetBlock(hash common.Hash) *types.Block {
 	// Short circuit if the block's already in the cache, retrieve otherwise
 	if block, ok := bc.blockCache.Get(hash); ok {
+		bc.blockMeter.hit()
 		return block.(*types.Block)
 	}
+	bc.blockMeter.miss()
 	block := GetBlock(bc.chainDb, hash)
 	if block == nil {
 		return nil
@@ -1117,6 +1184,28 @@ func (bc *BlockChain) GetBlock(hash common.Hash) *types.Block {
 	return block
 }
 
+// GetReceiptsRLP retrieves the receipts of all transactions in the block with
+// the given hash in RLP encoding, as sent to peers.
+func (bc *BlockChain) GetReceiptsRLP(hash common.Hash) rlp.RawValue {
+	return GetBlockReceiptsRLP(bc.chainDb, hash)
+}
+
+// GetReceiptsByHash retrieves the receipts of all transactions in the block
+// with the given hash, caching them if found.
+func (bc *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
+	if receipts, ok := bc.receiptsCache.Get(hash); ok {
+		bc.receiptsMeter.hit()
+		return receipts.(types.Receipts)
+	}
+	bc.receiptsMeter.miss()
+	receipts := GetBlockReceipts(bc.chainDb, hash)
+	if receipts == nil {
+		return nil
+	}
+	bc.receiptsCache.Add(hash, receipts)
+	return receipts
+}
+
 // GetBlockByNumber retrieves a block from the database by number, caching it
 // (associated with its hash) if found.
 func (bc *BlockChain) GetBlockByNumber(number uint64) *types.Block {
@@ -1161,8 +1250,13 @@ func (bc *BlockChain) Stop() {
 	close(bc.quit)
 	atomic.StoreInt32(&bc.procInterrupt, 1)
 
+	// Release the insertions held back by a freeze so they can abort
+	bc.ThawImport()
 	bc.wg.Wait()
 
+	if err := bc.writeCacheWarmup(); err != nil {
+		glog.V(logger.Warn).Warnf("Failed to write cache warm-up summary: %v", err)
+	}
 	glog.V(logger.Info).Infoln("Chain manager stopped")
 }
 
@@ -1180,25 +1274,36 @@ func (bc *BlockChain) Rollback(chain []common.Hash) {
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
+	var (
+		header = bc.hc.CurrentHeader()
+		heads  headPointers
+	)
 	for i := len(chain) - 1; i >= 0; i-- {
 		hash := chain[i]
 
-		if bc.hc.CurrentHeader().Hash() == hash {
-			bc.hc.SetCurrentHeader(bc.GetHeader(bc.hc.CurrentHeader().ParentHash))
+		if header.Hash() == hash {
+			header = bc.GetHeader(header.ParentHash)
+			heads.Header = header.Hash()
 		}
 		if bc.currentFastBlock.Hash() == hash {
 			bc.currentFastBlock = bc.GetBlock(bc.currentFastBlock.ParentHash())
-			if err := WriteHeadFastBlockHash(bc.chainDb, bc.currentFastBlock.Hash()); err != nil {
-				glog.Fatalf("failed to write fast head block hash: %v", err)
-			}
+			heads.Fast = bc.currentFastBlock.Hash()
 		}
 		if bc.currentBlock.Hash() == hash {
 			bc.currentBlock = bc.GetBlock(bc.currentBlock.ParentHash())
-			if err := WriteHeadBlockHash(bc.chainDb, bc.currentBlock.Hash()); err != nil {
-				glog.Fatalf("failed to write head block hash: %v", err)
-			}
+			heads.Block = bc.currentBlock.Hash()
 		}
 	}
+	// Persist the rolled back heads together, so a crash can't leave them apart
+	if heads == (headPointers{}) {
+		return
+	}
+	if err := writeHeadPointers(bc.chainDb, heads); err != nil {
+		glog.Fatalf("failed to write rolled back head hashes: %v", err)
+	}
+	if heads.Header != (common.Hash{}) {
+		bc.hc.setCurrentHeader(header)
+	}
 }
 
 // InsertReceiptChain attempts to complete an already existing header chain with
@@ -1206,6 +1311,14 @@ func (bc *BlockChain) Rollback(chain []common.Hash) {
 func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain []types.Receipts) (res *ReceiptChainInsertResult) {
 	res = &ReceiptChainInsertResult{}
 
+	var span *tracing.Span
+	if len(blockChain) > 0 {
+		span = tracing.StartFor(blockChain[0].Hash(), "core.insertReceiptChain")
+		span.SetAttribute("blocks", len(blockChain))
+	}
+	defer func() { span.End(res.Error) }()
+
+	defer bc.enterImport()()
 	bc.wg.Add(1)
 	defer bc.wg.Done()
 
@@ -1291,7 +1404,7 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 				glog.Fatal(errs[index])
 				return
 			}
-			if err := WriteTransactions(bc.chainDb, block); err != nil {
+			if err := bc.writeTxLookups(block); err != nil {
 				errs[index] = fmt.Errorf("failed to write individual transactions: %v", err)
 				atomic.AddInt32(&failed, 1)
 				glog.Fatal(errs[index])
@@ -1308,6 +1421,9 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 				if err := WriteBlockAddTxIndexes(bc.atxi.Db, block); err != nil {
 					glog.Fatalf("failed to write block add-tx indexes, err: %v", err)
 				}
+				if err := WriteBlockLogStats(bc.atxi.Db, block.NumberU64(), receipts); err != nil {
+					glog.Fatalf("failed to write block log stats, err: %v", err)
+				}
 				// if buildATXI has been in use (via RPC) and is NOT finished, current < stop
 				// if buildATXI has been in use (via RPC) and IS finished, current == stop
 				// else if builtATXI has not been in use (via RPC), then current == stop == 0
@@ -1398,6 +1514,9 @@ func (bc *BlockChain) WriteBlockAddrTxIndexesBatch(indexDb ethdb.Database, start
 		if err != nil {
 			return txsCount, err
 		}
+		if err := putBlockLogStatsToBatch(batch, block.NumberU64(), GetBlockReceipts(bc.chainDb, block.Hash())); err != nil {
+			return txsCount, err
+		}
 		txsCount += txP
 		blockProcessedCount++
 
@@ -1416,8 +1535,22 @@ func (bc *BlockChain) WriteBlockAddrTxIndexesBatch(indexDb ethdb.Database, start
 	return txsCount, batch.Write()
 }
 
-// WriteBlock writes the block to the chain.
+// WriteBlock writes the block to the chain, waiting while block import is frozen.
 func (bc *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err error) {
+	defer bc.enterImport()()
+	return bc.writeBlock(block)
+}
+
+// writeBlock writes the block to the chain. The caller must have entered the
+// import, see enterImport.
+func (bc *BlockChain) writeBlock(block *types.Block) (status WriteStatus, err error) {
+	span := tracing.StartFor(block.Hash(), "core.writeBlock")
+	span.SetAttribute("number", block.NumberU64())
+	span.SetAttribute("hash", block.Hash().Hex())
+	defer func() {
+		span.SetAttribute("canonical", status == CanonStatTy)
+		span.End(err)
+	}()
 
 	if logger.MlogEnabled() {
 		defer func() {
@@ -1449,6 +1582,7 @@ func (bc *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err er
 				len(block.Uncles()),
 				block.ReceivedAt,
 				parentTimeDiff,
+				BlockAuthor(block.Header()).Hex(),
 			).Send(mlogBlockchain)
 		}()
 	}
@@ -1492,6 +1626,10 @@ func (bc *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err er
 		}
 		bc.insert(block) // Insert the block as the new head of the chain
 		status = CanonStatTy
+
+		if err := bc.updateInternalTxIndex(block); err != nil {
+			return NonStatTy, err
+		}
 	} else {
 		status = SideStatTy
 	}
@@ -1648,7 +1786,7 @@ func (bc *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err er
 //
 //		txcount += len(block.Transactions())
 //		// write the block to the chain and get the status
-//		status, err := bc.WriteBlock(block)
+//		status, err := bc.writeBlock(block)
 //		if err != nil {
 //			res.Error = err
 //			return
@@ -1840,6 +1978,12 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 					return err
 				}
 			}
+			if err := RmBlockLogStats(bc.atxi.Db, block.NumberU64(), GetBlockReceipts(bc.chainDb, block.Hash())); err != nil {
+				return err
+			}
+		}
+		if err := rewindInternalTxIndex(bc.atxi.Db, commonBlock.NumberU64()+1); err != nil {
+			return err
 		}
 	}
 
@@ -1849,7 +1993,7 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		// insert the block in the canonical way, re-writing history
 		bc.insert(block)
 		// write canonical receipts and transactions
-		if err := WriteTransactions(bc.chainDb, block); err != nil {
+		if err := bc.writeTxLookups(block); err != nil {
 			return err
 		}
 		// Store the addr-tx indexes if enabled
@@ -1871,12 +2015,23 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		if err := WriteReceipts(bc.chainDb, receipts); err != nil {
 			return err
 		}
+		if bc.atxi != nil {
+			if err := WriteBlockLogStats(bc.atxi.Db, block.NumberU64(), receipts); err != nil {
+				return err
+			}
+		}
 		// Write map map bloom filters
 		if err := WriteMipmapBloom(bc.chainDb, block.NumberU64(), receipts); err != nil {
 			return err
 		}
 		addedTxs = append(addedTxs, block.Transactions()...)
 	}
+	// Index the internal transactions of the new chain, which must be done oldest first
+	for i := len(newChain) - 1; i >= 0; i-- {
+		if err := bc.updateInternalTxIndex(newChain[i]); err != nil {
+			return err
+		}
+	}
 
 	// calculate the difference between deleted and added transactions
 	diff := types.TxDifference(deletedTxs, addedTxs)
@@ -1961,6 +2116,8 @@ func (bc *BlockChain) update() {
 // of the header retrieval mechanisms already need to verify nonces, as well as
 // because nonces can be verified sparsely, not needing to check each.
 func (bc *BlockChain) InsertHeaderChain(chain []*types.Header, checkFreq int) *HeaderChainInsertResult {
+	defer bc.enterImport()()
+
 	// Make sure only one thread manipulates the chain at once
 	bc.chainmu.Lock()
 	defer bc.chainmu.Unlock()
@@ -1968,6 +2125,12 @@ func (bc *BlockChain) InsertHeaderChain(chain []*types.Header, checkFreq int) *H
 	bc.wg.Add(1)
 	defer bc.wg.Done()
 
+	var span *tracing.Span
+	if len(chain) > 0 {
+		span = tracing.StartFor(chain[0].Hash(), "core.insertHeaderChain")
+		span.SetAttribute("headers", len(chain))
+	}
+
 	whFunc := func(header *types.Header) error {
 		bc.mu.Lock()
 		defer bc.mu.Unlock()
@@ -1976,7 +2139,9 @@ func (bc *BlockChain) InsertHeaderChain(chain []*types.Header, checkFreq int) *H
 		return err
 	}
 
-	return bc.hc.InsertHeaderChain(chain, checkFreq, whFunc)
+	res := bc.hc.InsertHeaderChain(chain, checkFreq, whFunc)
+	span.End(res.Error)
+	return res
 }
 
 // CurrentHeader retrieves the current head header of the canonical chain. The
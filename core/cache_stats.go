@@ -0,0 +1,59 @@
+package core
+
+import (
+	"sync/atomic"
+
+	"github.com/hashicorp/golang-lru"
+)
+
+// CacheStats reports the usage of an in-memory cache.
+type CacheStats struct {
+	Entries int     `json:"entries"`
+	Limit   int     `json:"limit"`
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hitRate"` // fraction of lookups served from the cache
+}
+
+// cacheMeter counts the hits and misses of a cache lookup path. The zero value
+// is ready to use.
+type cacheMeter struct {
+	hits, misses uint64
+}
+
+func (m *cacheMeter) hit()  { atomic.AddUint64(&m.hits, 1) }
+func (m *cacheMeter) miss() { atomic.AddUint64(&m.misses, 1) }
+
+// stats returns the statistics of the metered cache.
+func (m *cacheMeter) stats(cache *lru.Cache, limit int) CacheStats {
+	return NewCacheStats(cache.Len(), limit, atomic.LoadUint64(&m.hits), atomic.LoadUint64(&m.misses))
+}
+
+// NewCacheStats assembles cache statistics, computing the hit rate.
+func NewCacheStats(entries, limit int, hits, misses uint64) CacheStats {
+	stats := CacheStats{Entries: entries, Limit: limit, Hits: hits, Misses: misses}
+	if total := hits + misses; total > 0 {
+		stats.HitRate = float64(hits) / float64(total)
+	}
+	return stats
+}
+
+// CacheStats returns the statistics of the block chain caches, keyed by the
+// cached data.
+func (bc *BlockChain) CacheStats() map[string]CacheStats {
+	stats := bc.hc.CacheStats()
+	stats["bodies"] = bc.bodyMeter.stats(bc.bodyCache, bodyCacheLimit)
+	stats["bodiesRLP"] = bc.bodyRLPMeter.stats(bc.bodyRLPCache, bodyCacheLimit)
+	stats["blocks"] = bc.blockMeter.stats(bc.blockCache, blockCacheLimit)
+	stats["receipts"] = bc.receiptsMeter.stats(bc.receiptsCache, receiptsCacheLimit)
+	return stats
+}
+
+// CacheStats returns the statistics of the header chain caches, keyed by the
+// cached data.
+func (hc *HeaderChain) CacheStats() map[string]CacheStats {
+	return map[string]CacheStats{
+		"headers": hc.headerMeter.stats(hc.headerCache, headerCacheLimit),
+		"tds":     hc.tdMeter.stats(hc.tdCache, tdCacheLimit),
+	}
+}
@@ -0,0 +1,24 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/hashicorp/golang-lru"
+)
+
+func TestCacheMeter(t *testing.T) {
+	cache, _ := lru.New(4)
+	cache.Add(1, 1)
+
+	var m cacheMeter
+	if stats := m.stats(cache, 4); stats.HitRate != 0 || stats.Entries != 1 || stats.Limit != 4 {
+		t.Errorf("wrong initial stats: %+v", stats)
+	}
+	m.hit()
+	m.hit()
+	m.hit()
+	m.miss()
+	if stats := m.stats(cache, 4); stats.Hits != 3 || stats.Misses != 1 || stats.HitRate != 0.75 {
+		t.Errorf("wrong stats: %+v", stats)
+	}
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/rlp"
+	"github.com/openether/ethcore/trie"
+)
+
+// cacheWarmupKey stores the summary of the caches written on shutdown.
+var cacheWarmupKey = []byte("CacheWarmup")
+
+// warmupTrieDepth is the depth, in nibbles, down to which the nodes of the head
+// state trie are preloaded. Every state access traverses these nodes, making
+// them the hottest part of the database. In a full trie the nodes down to three
+// nibbles amount to about 4400 (1+16+256+4096), one more level would be 70k.
+const warmupTrieDepth = 3
+
+// cacheWarmup summarises the contents of the block chain caches, so they can be
+// preloaded after a restart instead of refilling from cold storage on demand.
+type cacheWarmup struct {
+	Head     common.Hash   // Head block when the summary was written
+	Headers  []common.Hash // Cached headers, least recently used first
+	Blocks   []common.Hash // Cached blocks and bodies, least recently used first
+	Receipts []common.Hash // Blocks of the cached receipts, least recently used first
+	Nodes    []common.Hash // Top nodes of the head state trie
+}
+
+// writeCacheWarmup persists the summary of the current cache contents.
+func (bc *BlockChain) writeCacheWarmup() error {
+	head := bc.CurrentBlock()
+	summary := &cacheWarmup{
+		Head:     head.Hash(),
+		Headers:  cachedHashes(bc.hc.headerCache.Keys()),
+		Blocks:   cachedHashes(append(bc.bodyCache.Keys(), bc.blockCache.Keys()...)),
+		Receipts: cachedHashes(bc.receiptsCache.Keys()),
+	}
+	if tr, err := trie.New(head.Root(), bc.chainDb); err == nil {
+		summary.Nodes = warmupNodes(tr)
+	}
+	data, err := rlp.EncodeToBytes(summary)
+	if err != nil {
+		return err
+	}
+	return bc.chainDb.Put(cacheWarmupKey, data)
+}
+
+// warmCaches preloads the caches listed in the summary persisted on the last
+// shutdown. It's meant to run in the background and gives up as soon as the
+// chain is stopped.
+func (bc *BlockChain) warmCaches() {
+	data, err := bc.chainDb.Get(cacheWarmupKey)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	var summary cacheWarmup
+	if err := rlp.DecodeBytes(data, &summary); err != nil {
+		glog.V(logger.Warn).Warnf("Discarding invalid cache warm-up summary: %v", err)
+		return
+	}
+	var (
+		start  = time.Now()
+		loaded int
+	)
+	preload := func(hashes []common.Hash, load func(common.Hash) bool) bool {
+		for _, hash := range hashes {
+			select {
+			case <-bc.quit:
+				return false
+			default:
+			}
+			if load(hash) {
+				loaded++
+			}
+		}
+		return true
+	}
+	// Trie nodes only need to be read to land in the database cache
+	if !preload(summary.Nodes, func(hash common.Hash) bool {
+		_, err := bc.chainDb.Get(hash.Bytes())
+		return err == nil
+	}) {
+		return
+	}
+	if !preload(summary.Headers, func(hash common.Hash) bool { return bc.GetHeader(hash) != nil }) {
+		return
+	}
+	if !preload(summary.Blocks, func(hash common.Hash) bool { return bc.GetBlock(hash) != nil && bc.GetBody(hash) != nil }) {
+		return
+	}
+	if !preload(summary.Receipts, func(hash common.Hash) bool { return bc.GetReceiptsByHash(hash) != nil }) {
+		return
+	}
+	glog.V(logger.Info).Infof("Warmed up caches with %d entries in %v", loaded, time.Since(start))
+}
+
+// warmupNodes returns the hashes of the nodes of the trie down to warmupTrieDepth.
+func warmupNodes(tr *trie.Trie) []common.Hash {
+	var nodes []common.Hash
+	for it := tr.NodeIterator(nil); it.Next(len(it.Path()) < warmupTrieDepth); {
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			nodes = append(nodes, hash)
+		}
+	}
+	return nodes
+}
+
+// cachedHashes converts the keys of a hash keyed cache, deduplicating them.
+func cachedHashes(keys []interface{}) []common.Hash {
+	hashes := make([]common.Hash, 0, len(keys))
+	seen := make(map[common.Hash]bool, len(keys))
+	for _, key := range keys {
+		if hash, ok := key.(common.Hash); ok && !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// PurgeCaches drops the cached headers, bodies, blocks and receipts, relieving
+// memory pressure at the cost of reading them from the database again.
+func (bc *BlockChain) PurgeCaches() {
+	bc.hc.headerCache.Purge()
+	bc.hc.tdCache.Purge()
+	bc.bodyCache.Purge()
+	bc.bodyRLPCache.Purge()
+	bc.blockCache.Purge()
+	bc.receiptsCache.Purge()
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/crypto"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/trie"
+)
+
+func TestCachedHashes(t *testing.T) {
+	a, b := common.HexToHash("0x01"), common.HexToHash("0x02")
+	keys := []interface{}{a, "not a hash", b, a}
+
+	if hashes := cachedHashes(keys); !reflect.DeepEqual(hashes, []common.Hash{a, b}) {
+		t.Errorf("hashes mismatch: have %x, want %x", hashes, []common.Hash{a, b})
+	}
+}
+
+// Tests that the trie nodes preloaded are bounded by the warm-up depth, however
+// large the trie.
+func TestWarmupNodes(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	tr, _ := trie.New(common.Hash{}, db)
+	for i := 0; i < 20000; i++ {
+		key := crypto.Keccak256([]byte{byte(i >> 8), byte(i)})
+		tr.Update(key, key)
+	}
+	root, err := tr.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, _ = trie.New(root, db)
+
+	// The branches down to three nibbles are mostly full with that many keys
+	if nodes := warmupNodes(tr); len(nodes) < 4096 || len(nodes) > 1+16+256+4096 {
+		t.Errorf("warm-up node count mismatch: have %d, want %d at most", len(nodes), 1+16+256+4096)
+	}
+}
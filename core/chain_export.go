@@ -0,0 +1,183 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+const (
+	ExportManifestName       = "manifest.json" // Name of the manifest file of a segmented export
+	DefaultExportSegmentSize = 100000          // Default number of blocks per export segment
+
+	exportManifestVersion = 1
+)
+
+// ExportManifest describes a segmented chain export, allowing the segments to
+// be verified before they are imported.
+type ExportManifest struct {
+	Version   int             `json:"version"`
+	NetworkId int             `json:"networkId"`
+	Genesis   common.Hash     `json:"genesis"`
+	First     uint64          `json:"first"`
+	Last      uint64          `json:"last"`
+	Segments  []ExportSegment `json:"segments"`
+}
+
+// ExportSegment is a file of RLP encoded blocks of a segmented chain export.
+type ExportSegment struct {
+	File   string      `json:"file"` // Name relative to the export directory
+	First  uint64      `json:"first"`
+	Last   uint64      `json:"last"`
+	Size   int64       `json:"size"`
+	SHA256 common.Hash `json:"sha256"`
+}
+
+// ExportSegments writes the canonical blocks in [first, last] into the given
+// directory as files of segmentSize blocks each, named after their block range,
+// along with a manifest of the segments. The manifest is written last, so an
+// interrupted export is not mistaken for a complete one. Exports of the same
+// range are identical.
+func (bc *BlockChain) ExportSegments(dir string, networkId int, first, last, segmentSize uint64) (*ExportManifest, error) {
+	if first > last {
+		return nil, fmt.Errorf("export failed: first (%d) is greater than last (%d)", first, last)
+	}
+	if segmentSize == 0 {
+		return nil, fmt.Errorf("export failed: zero segment size")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	manifest := &ExportManifest{
+		Version:   exportManifestVersion,
+		NetworkId: networkId,
+		Genesis:   GetCanonicalHash(bc.chainDb, 0),
+		First:     first,
+		Last:      last,
+	}
+	for start := first; start <= last; start += segmentSize {
+		end := last
+		if last-start >= segmentSize {
+			end = start + segmentSize - 1
+		}
+		segment, err := bc.exportSegment(dir, start, end)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Segments = append(manifest.Segments, *segment)
+		glog.V(logger.Info).Infof("Exported blocks #%d-#%d to %s", start, end, segment.File)
+
+		if end == last {
+			break
+		}
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	tmp := filepath.Join(dir, ExportManifestName+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, ExportManifestName)); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// exportSegment writes the canonical blocks in [first, last] into a segment file.
+func (bc *BlockChain) exportSegment(dir string, first, last uint64) (*ExportSegment, error) {
+	segment := &ExportSegment{
+		File:  fmt.Sprintf("blocks-%09d-%09d.rlp", first, last),
+		First: first,
+		Last:  last,
+	}
+	fh, err := os.OpenFile(filepath.Join(dir, segment.File), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	hasher := sha256.New()
+	if err := bc.ExportN(io.MultiWriter(fh, hasher), first, last); err != nil {
+		return nil, err
+	}
+	if err := fh.Sync(); err != nil {
+		return nil, err
+	}
+	info, err := fh.Stat()
+	if err != nil {
+		return nil, err
+	}
+	segment.Size = info.Size()
+	copy(segment.SHA256[:], hasher.Sum(nil))
+	return segment, nil
+}
+
+// VerifyExport reads the manifest of a segmented chain export and checks that
+// its segments cover its block range and are intact.
+func VerifyExport(dir string) (*ExportManifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ExportManifestName))
+	if err != nil {
+		return nil, err
+	}
+	manifest := new(ExportManifest)
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if manifest.Version != exportManifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+	if len(manifest.Segments) == 0 {
+		return nil, fmt.Errorf("manifest lists no segments")
+	}
+	next := manifest.First
+	for i, segment := range manifest.Segments {
+		if segment.First != next || segment.Last < segment.First {
+			return nil, fmt.Errorf("segment %d (%s) covers #%d-#%d, want from #%d", i, segment.File, segment.First, segment.Last, next)
+		}
+		if filepath.Base(segment.File) != segment.File {
+			return nil, fmt.Errorf("segment %d file %q outside the export directory", i, segment.File)
+		}
+		if err := verifySegment(dir, segment); err != nil {
+			return nil, err
+		}
+		next = segment.Last + 1
+	}
+	if last := manifest.Segments[len(manifest.Segments)-1].Last; last != manifest.Last {
+		return nil, fmt.Errorf("segments end at #%d, want #%d", last, manifest.Last)
+	}
+	return manifest, nil
+}
+
+// verifySegment checks the size and checksum of a segment file.
+func verifySegment(dir string, segment ExportSegment) error {
+	fh, err := os.Open(filepath.Join(dir, segment.File))
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, fh)
+	if err != nil {
+		return err
+	}
+	if size != segment.Size {
+		return fmt.Errorf("segment %s size mismatch: have %d, want %d", segment.File, size, segment.Size)
+	}
+	var sum common.Hash
+	copy(sum[:], hasher.Sum(nil))
+	if sum != segment.SHA256 {
+		return fmt.Errorf("segment %s checksum mismatch: have %x, want %x", segment.File, sum, segment.SHA256)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+)
+
+// iteratorBatch is the number of blocks read from the database ahead of the
+// iteration callback.
+const iteratorBatch = 256
+
+// ErrChainReorged is returned by ForEachBlock when the canonical chain was
+// reorganised while being walked.
+var ErrChainReorged = errors.New("canonical chain reorganised during iteration")
+
+// ForEachBlock calls fn with the canonical blocks in [from, to], in ascending
+// order, stopping at the first error fn returns, which is returned.
+//
+// Blocks are read from the database in batches by a background goroutine while
+// fn runs, bypassing the block cache so that walking the chain doesn't evict the
+// recent blocks from it.
+func (bc *BlockChain) ForEachBlock(from, to uint64, fn func(*types.Block) error) error {
+	return bc.forEachBlock(from, to, false, func(block *types.Block, _ types.Receipts) error {
+		return fn(block)
+	})
+}
+
+// ForEachBlockWithReceipts is like ForEachBlock, passing fn the receipts of the
+// transactions of each block along with it as well.
+func (bc *BlockChain) ForEachBlockWithReceipts(from, to uint64, fn func(*types.Block, types.Receipts) error) error {
+	return bc.forEachBlock(from, to, true, fn)
+}
+
+// iteratedBlock is a block read ahead of the iteration callback.
+type iteratedBlock struct {
+	block    *types.Block
+	receipts types.Receipts
+	err      error
+}
+
+func (bc *BlockChain) forEachBlock(from, to uint64, receipts bool, fn func(*types.Block, types.Receipts) error) error {
+	if from > to {
+		return fmt.Errorf("iteration failed: first (%d) is greater than last (%d)", from, to)
+	}
+	var (
+		batches = make(chan []iteratedBlock, 1)
+		quit    = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(batches)
+
+		for first := from; ; first += iteratorBatch {
+			last := first + iteratorBatch - 1
+			if last > to || last < first {
+				last = to
+			}
+			batch := bc.readBlocks(first, last, receipts)
+			select {
+			case batches <- batch:
+			case <-quit:
+				return
+			}
+			if last == to || batch[len(batch)-1].err != nil {
+				return
+			}
+		}
+	}()
+	defer wg.Wait()
+	defer close(quit)
+
+	var parent common.Hash
+	for batch := range batches {
+		for _, it := range batch {
+			if it.err != nil {
+				return it.err
+			}
+			// Blocks of a batch may be read before and after a reorg, check they link up
+			if parent != (common.Hash{}) && it.block.ParentHash() != parent {
+				return ErrChainReorged
+			}
+			parent = it.block.Hash()
+
+			if err := fn(it.block, it.receipts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readBlocks reads the canonical blocks in [first, last], and their receipts if
+// requested. Reading stops at the first missing block, whose error ends the batch.
+func (bc *BlockChain) readBlocks(first, last uint64, receipts bool) []iteratedBlock {
+	batch := make([]iteratedBlock, 0, last-first+1)
+	for number := first; ; number++ {
+		var it iteratedBlock
+		if hash := GetCanonicalHash(bc.chainDb, number); hash == (common.Hash{}) {
+			it.err = fmt.Errorf("iteration failed on #%d: not found", number)
+		} else if it.block = GetBlock(bc.chainDb, hash); it.block == nil {
+			it.err = fmt.Errorf("iteration failed on #%d: block %x not found", number, hash)
+		} else if receipts && len(it.block.Transactions()) > 0 {
+			if it.receipts = GetBlockReceipts(bc.chainDb, hash); it.receipts == nil {
+				it.err = fmt.Errorf("iteration failed on #%d: receipts of block %x not found", number, hash)
+			}
+		}
+		batch = append(batch, it)
+		if it.err != nil || number == last {
+			return batch
+		}
+	}
+}
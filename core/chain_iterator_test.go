@@ -0,0 +1,60 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/ethdb"
+)
+
+func TestForEachBlock(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	writeArchiveTestChain(t, db, 2*iteratorBatch+10)
+	bc := &BlockChain{chainDb: db}
+
+	var next uint64 = 5
+	err := bc.ForEachBlockWithReceipts(5, 2*iteratorBatch+9, func(block *types.Block, receipts types.Receipts) error {
+		if block.NumberU64() != next {
+			t.Fatalf("block number mismatch: have %d, want %d", block.NumberU64(), next)
+		}
+		if block.Hash() != GetCanonicalHash(db, next) {
+			t.Fatalf("block %d: hash mismatch", next)
+		}
+		if len(receipts) != len(block.Transactions()) {
+			t.Fatalf("block %d: receipt count mismatch: have %d, want %d", next, len(receipts), len(block.Transactions()))
+		}
+		next++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	if next != 2*iteratorBatch+10 {
+		t.Errorf("iteration stopped early at #%d", next)
+	}
+}
+
+func TestForEachBlockErrors(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	writeArchiveTestChain(t, db, 10)
+	bc := &BlockChain{chainDb: db}
+
+	if err := bc.ForEachBlock(5, 4, func(*types.Block) error { return nil }); err == nil {
+		t.Errorf("inverted range accepted")
+	}
+	if err := bc.ForEachBlock(0, 20, func(*types.Block) error { return nil }); err == nil {
+		t.Errorf("iteration past the head succeeded")
+	}
+	stop := errors.New("stop")
+	var seen int
+	err := bc.ForEachBlock(0, 9, func(block *types.Block) error {
+		if seen++; block.NumberU64() == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || seen != 4 {
+		t.Errorf("callback error not propagated: have %v after %d blocks", err, seen)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
 	"github.com/openether/ethcore/p2p/discover"
+	"github.com/openether/ethcore/rlp"
 )
 
 var (
@@ -109,12 +110,35 @@ type GenesisDump struct {
 	Alloc map[hex]*GenesisDumpAlloc `json:"alloc"`
 	// Alloc file contains CSV representation of Alloc
 	AllocFile string `json:"alloc_file"`
+	// AllocState tells whether the code and storage of allocations are part of
+	// the genesis. They used to be ignored when decoding, so they only are for
+	// configurations setting it, keeping the genesis hash of older ones.
+	AllocState bool `json:"allocState,omitempty"`
+}
+
+// UnmarshalJSON decodes a genesis, dropping the code and storage of allocations
+// unless AllocState is set.
+func (g *GenesisDump) UnmarshalJSON(input []byte) error {
+	type genesisDump GenesisDump
+	var dec genesisDump
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if !dec.AllocState {
+		for _, alloc := range dec.Alloc {
+			if alloc != nil {
+				alloc.Code, alloc.Storage = "", nil
+			}
+		}
+	}
+	*g = GenesisDump(dec)
+	return nil
 }
 
 // GenesisDumpAlloc is a GenesisDump.Alloc entry.
 type GenesisDumpAlloc struct {
-	Code    prefixedHex `json:"-"` // skip field for json encode
-	Storage map[hex]hex `json:"-"`
+	Code    prefixedHex `json:"code,omitempty"`
+	Storage map[hex]hex `json:"storage,omitempty"`
 	Balance string      `json:"balance"` // decimal string
 }
 
@@ -316,6 +340,24 @@ func (c *ChainConfig) IsExplosion(num *big.Int) bool {
 	return false
 }
 
+// RequiresProtectedTxs returns whether only replay protected transactions are
+// allowed at num, ie. whether the "eip155" feature is configured with "requireProtected".
+func (c *ChainConfig) RequiresProtectedTxs(num *big.Int) bool {
+	feature, _, configured := c.GetFeature(num, "eip155")
+	if !configured {
+		return false
+	}
+	required, _ := feature.GetBool("requireProtected")
+	return required
+}
+
+// IsEIP658 returns whether receipts of blocks at num carry the transaction status
+// instead of the intermediate state root, ie. whether the "eip658" feature is configured.
+func (c *ChainConfig) IsEIP658(num *big.Int) bool {
+	_, _, configured := c.GetFeature(num, "eip658")
+	return configured
+}
+
 // ForkByName looks up a Fork by its name, assumed to be unique
 func (c *ChainConfig) ForkByName(name string) *Fork {
 	for i := range c.Forks {
@@ -326,7 +368,7 @@ func (c *ChainConfig) ForkByName(name string) *Fork {
 	return &Fork{}
 }
 
-// GetFeature looks up fork features by id, where id can (currently) be [difficulty, gastable, eip155].
+// GetFeature looks up fork features by id, where id can (currently) be [difficulty, gastable, eip155, eip658].
 // GetFeature returns the feature|nil, the latest fork configuring a given id, and if the given feature id was found at all
 // If queried feature is not found, returns ForkFeature{}, Fork{}, false.
 // If queried block number and/or feature is a zero-value, returns ForkFeature{}, Fork{}, false.
@@ -656,6 +698,16 @@ func (o *ForkFeature) GetString(name string) (string, bool) {
 	return val, ok
 }
 
+// GetBool gets and option value for an options with key 'name',
+// returning value as a bool and ok if it exists.
+func (o *ForkFeature) GetBool(name string) (bool, bool) {
+	o.optionsLock.RLock()
+	defer o.optionsLock.RUnlock()
+
+	val, ok := o.Options[name].(bool)
+	return val, ok
+}
+
 // GetBigInt gets and option value for an options with key 'name',
 // returning value as a *big.Int and ok if it exists.
 func (o *ForkFeature) GetBigInt(name string) (*big.Int, bool) {
@@ -852,15 +904,33 @@ func MakeGenesisDump(chaindb ethdb.Database) (*GenesisDump, error) {
 
 	stateAccounts := stateDump.Accounts
 	dump.Alloc = make(map[hex]*GenesisDumpAlloc, len(stateAccounts))
+	dump.AllocState = true
 
 	for address, acct := range stateAccounts {
-		if common.IsHexAddress(address) {
-			dump.Alloc[hex(address)] = &GenesisDumpAlloc{
-				Balance: acct.Balance,
-			}
-		} else {
+		if !common.IsHexAddress(address) {
 			return nil, fmt.Errorf("Invalid address in genesis state: %v", address)
 		}
+		alloc := &GenesisDumpAlloc{
+			Balance: acct.Balance,
+		}
+		if len(acct.Code) > 0 {
+			alloc.Code = prefixedHex("0x" + acct.Code)
+		}
+		if len(acct.Storage) > 0 {
+			alloc.Storage = make(map[hex]hex, len(acct.Storage))
+			for key, value := range acct.Storage {
+				// Storage is keyed by the preimages of the trie keys, values are RLP encoded
+				if len(key) != 2*common.HashLength {
+					return nil, fmt.Errorf("missing storage key preimage of genesis account %v", address)
+				}
+				var content []byte
+				if err := rlp.DecodeBytes(common.FromHex(value), &content); err != nil {
+					return nil, fmt.Errorf("invalid storage value of genesis account %v: %v", address, err)
+				}
+				alloc.Storage[hex(key)] = hex(hexlib.EncodeToString(common.BytesToHash(content).Bytes()))
+			}
+		}
+		dump.Alloc[hex(address)] = alloc
 	}
 	return dump, nil
 }
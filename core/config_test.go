@@ -1,6 +1,8 @@
 package core
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
 	"math/big"
 	"os"
@@ -237,6 +239,90 @@ func TestMakeGenesisDump2(t *testing.T) {
 	}
 }
 
+// Tests that the code and storage of genesis accounts survive a JSON round trip
+// through MakeGenesisDump.
+func TestMakeGenesisDumpContracts(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	genesisDump := &GenesisDump{
+		Nonce:      "0x0000000000000042",
+		Difficulty: "0x020000",
+		GasLimit:   "0x47e7c4",
+		Alloc: map[hex]*GenesisDumpAlloc{
+			"000d836201318ec6899a67540690382780743280": {Balance: "1000"},
+			"001762430ea9c3a26e5749afdb70da5f78ddbb8c": {
+				Balance: "0",
+				Code:    "0x6060604052600a8060106000396000f360606040526008565b00",
+				Storage: map[hex]hex{
+					"0000000000000000000000000000000000000000000000000000000000000001": "00000000000000000000000000000000000000000000000000000000000000ff",
+				},
+			},
+		},
+	}
+	gBlock1, err := WriteGenesisBlock(db, genesisDump)
+	if err != nil {
+		t.Fatalf("failed to write genesis: %v", err)
+	}
+	gotGenesisDump, err := MakeGenesisDump(db)
+	if err != nil {
+		t.Fatalf("failed to dump genesis: %v", err)
+	}
+	blob, err := json.Marshal(gotGenesisDump)
+	if err != nil {
+		t.Fatalf("failed to encode genesis dump: %v", err)
+	}
+	decoded := new(GenesisDump)
+	if err := json.Unmarshal(blob, decoded); err != nil {
+		t.Fatalf("failed to decode genesis dump: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Alloc, genesisDump.Alloc) {
+		t.Errorf("allocations mismatch: have %s", blob)
+	}
+	db2, _ := ethdb.NewMemDatabase()
+	gBlock2, err := WriteGenesisBlock(db2, decoded)
+	if err != nil {
+		t.Fatalf("failed to write exported genesis: %v", err)
+	}
+	if gBlock1.Hash() != gBlock2.Hash() {
+		t.Errorf("genesis hash mismatch: have %x, want %x", gBlock2.Hash(), gBlock1.Hash())
+	}
+}
+
+// Tests that the code and storage of allocations are ignored in configurations
+// not setting allocState, as they were before it existed.
+func TestGenesisDumpAllocState(t *testing.T) {
+	const genesis = `{
+		"difficulty": "0x020000",
+		"gasLimit": "0x47e7c4",
+		"alloc": {
+			"001762430ea9c3a26e5749afdb70da5f78ddbb8c": {
+				"balance": "1000",
+				"code": "0x6060604052600a8060106000396000f360606040526008565b00",
+				"storage": {"0000000000000000000000000000000000000000000000000000000000000001": "00000000000000000000000000000000000000000000000000000000000000ff"}
+			}
+		}%s
+	}`
+	write := func(blob string) *types.Block {
+		var dump GenesisDump
+		if err := json.Unmarshal([]byte(blob), &dump); err != nil {
+			t.Fatalf("failed to decode genesis: %v", err)
+		}
+		db, _ := ethdb.NewMemDatabase()
+		block, err := WriteGenesisBlock(db, &dump)
+		if err != nil {
+			t.Fatalf("failed to write genesis: %v", err)
+		}
+		return block
+	}
+	legacy := write(fmt.Sprintf(genesis, ""))
+	balances := write(`{"difficulty": "0x020000", "gasLimit": "0x47e7c4", "alloc": {"001762430ea9c3a26e5749afdb70da5f78ddbb8c": {"balance": "1000"}}}`)
+	if legacy.Hash() != balances.Hash() {
+		t.Errorf("legacy genesis hash changed: have %x, want %x", legacy.Hash(), balances.Hash())
+	}
+	if state := write(fmt.Sprintf(genesis, `, "allocState": true`)); state.Hash() == balances.Hash() {
+		t.Errorf("code and storage ignored with allocState set")
+	}
+}
+
 func getDefaultChainConfigSorted() *ChainConfig {
 	return DefaultConfigMainnet.ChainConfig.SortForks()
 }
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/rlp"
+	ldbutil "github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	DefaultUsageSamples = 1024  // Default number of ranges the key space is split into for sampling
+	MaxUsageSamples     = 65536 // Maximum number of sampled ranges
+	usageSampleEntries  = 16    // Number of entries read at the start of every sampled range
+)
+
+// DatabaseUsage is the approximate disk usage of a database, by key family.
+type DatabaseUsage struct {
+	Total    uint64            `json:"total"`    // Bytes on disk, excluding the unflushed write buffer
+	Families map[string]uint64 `json:"families"` // Estimated bytes on disk of each key family
+	Sampled  int               `json:"sampled"`  // Number of entries the estimate is based on
+}
+
+// chainUsagePrefixes are the key prefixes of the chain database, whose ranges are
+// sampled on their own so their estimates don't depend on where their keys fall
+// in the key space.
+var chainUsagePrefixes = [][]byte{
+	blockPrefix, blockNumPrefix, blockHashPrefix, receiptsPrefix, blockReceiptsPrefix,
+	lookupPrefix, mipmapPre, []byte(preimagePrefix),
+}
+
+// indexUsagePrefixes are the key prefixes of the index database.
+var indexUsagePrefixes = [][]byte{txAddressIndexPrefix, contractCreationPrefix, internalTxIndexPrefix, logStatsAddressPrefix, logStatsTopicPrefix, logStatsPairPrefix}
+
+// classifyChainEntry returns the key family of a chain database entry. Entries
+// keyed by hash alone are told apart by their values: trie nodes are lists of 2
+// or 17 items, transactions are lists of other lengths and code isn't a list.
+func classifyChainEntry(key, value []byte) string {
+	switch {
+	case bytes.HasPrefix(key, blockNumPrefix):
+		return "canonicalHashes"
+	case bytes.HasPrefix(key, blockHashPrefix):
+		return "legacyBlocks"
+	case bytes.HasPrefix(key, blockPrefix):
+		switch {
+		case bytes.HasSuffix(key, headerSuffix):
+			return "headers"
+		case bytes.HasSuffix(key, bodySuffix):
+			return "bodies"
+		case bytes.HasSuffix(key, tdSuffix):
+			return "totalDifficulties"
+		}
+	case bytes.HasPrefix(key, blockReceiptsPrefix):
+		return "blockReceipts"
+	case bytes.HasPrefix(key, receiptsPrefix):
+		return "receipts"
+	case bytes.HasPrefix(key, mipmapPre):
+		return "mipmapBlooms"
+	case bytes.HasPrefix(key, []byte(preimagePrefix)):
+		return "preimages"
+	case len(key) == 33 && key[32] == txMetaSuffix[0]:
+		return "txMetadata"
+	case len(key) == 33 && bytes.HasPrefix(key, lookupPrefix):
+		return "txLookups"
+	case len(key) == 32:
+		content, _, err := rlp.SplitList(value)
+		if err != nil {
+			return "code"
+		}
+		if n, err := rlp.CountValues(content); err == nil && (n == 2 || n == 17) {
+			return "stateTrie"
+		}
+		return "transactions"
+	}
+	return "other"
+}
+
+// classifyIndexEntry returns the key family of an index database entry.
+func classifyIndexEntry(key, value []byte) string {
+	switch {
+	case bytes.HasPrefix(key, txAddressIndexPrefix):
+		return "addressTransactions"
+	case bytes.HasPrefix(key, contractCreationPrefix):
+		return "contractCreations"
+	case bytes.HasPrefix(key, internalTxIndexPrefix):
+		return "internalTransactions"
+	case bytes.HasPrefix(key, logStatsAddressPrefix), bytes.HasPrefix(key, logStatsTopicPrefix), bytes.HasPrefix(key, logStatsPairPrefix):
+		return "logStats"
+	}
+	return "other"
+}
+
+// ChainDatabaseUsage estimates the disk usage of the chain database by key
+// family, see EstimateDatabaseUsage.
+func ChainDatabaseUsage(db ethdb.Database, samples int) (*DatabaseUsage, error) {
+	return EstimateDatabaseUsage(db, chainUsagePrefixes, classifyChainEntry, samples)
+}
+
+// IndexDatabaseUsage estimates the disk usage of the index database by key
+// family, see EstimateDatabaseUsage.
+func IndexDatabaseUsage(db ethdb.Database, samples int) (*DatabaseUsage, error) {
+	return EstimateDatabaseUsage(db, indexUsagePrefixes, classifyIndexEntry, samples)
+}
+
+// EstimateDatabaseUsage estimates the disk usage of each key family without
+// reading the whole database. The key space, and separately the range of each
+// of the given prefixes, is split into the given number of ranges. LevelDB
+// reports the approximate disk size of every range, which is attributed to key
+// families in proportion to the entries read at the start of the range.
+func EstimateDatabaseUsage(db ethdb.Database, prefixes [][]byte, classify func(key, value []byte) string, samples int) (*DatabaseUsage, error) {
+	ldb, ok := db.(*ethdb.LDBDatabase)
+	if !ok {
+		return nil, errors.New("internal interface error; could not cast db to level db")
+	}
+	if samples <= 0 || samples > MaxUsageSamples {
+		return nil, errors.New("number of samples out of range")
+	}
+	// Split the key space at evenly spaced points, and the prefix ranges likewise
+	bounds := usageSplitPoints(nil, samples)
+	for _, prefix := range prefixes {
+		bounds = append(bounds, prefix, ethdb.NewBytesPrefix(prefix).Limit)
+		bounds = append(bounds, usageSplitPoints(prefix, samples)...)
+	}
+	sort.Sort(byteSlices(bounds))
+
+	ranges := make([]ldbutil.Range, 0, len(bounds)+1)
+	var start []byte
+	for _, bound := range bounds {
+		if bytes.Equal(bound, start) {
+			continue
+		}
+		ranges = append(ranges, ldbutil.Range{Start: start, Limit: bound})
+		start = bound
+	}
+	ranges = append(ranges, ldbutil.Range{Start: start})
+
+	sizes, err := ldb.LDB().SizeOf(ranges)
+	if err != nil {
+		return nil, err
+	}
+	usage := &DatabaseUsage{Families: make(map[string]uint64)}
+	for i := range ranges {
+		if sizes[i] <= 0 {
+			continue
+		}
+		usage.Total += uint64(sizes[i])
+
+		// Attribute the size of the range to the families of its first entries
+		read := make(map[string]uint64)
+		var total uint64
+		it := ldb.NewIteratorRange(&ranges[i])
+		for n := 0; n < usageSampleEntries && it.Next(); n++ {
+			size := uint64(len(it.Key()) + len(it.Value()))
+			read[classify(it.Key(), it.Value())] += size
+			total += size
+			usage.Sampled++
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return nil, err
+		}
+		if total == 0 {
+			// Sizes are approximated at table block granularity, or the range holds
+			// deleted entries not compacted away yet
+			usage.Families["unattributed"] += uint64(sizes[i])
+			continue
+		}
+		for family, size := range read {
+			usage.Families[family] += uint64(float64(sizes[i]) * float64(size) / float64(total))
+		}
+	}
+	return usage, nil
+}
+
+// usageSplitPoints returns the points splitting the range of the prefix into n
+// ranges of equal key space.
+func usageSplitPoints(prefix []byte, n int) [][]byte {
+	points := make([][]byte, 0, n-1)
+	for i := 1; i < n; i++ {
+		point := i * MaxUsageSamples / n
+		points = append(points, append(append([]byte{}, prefix...), byte(point>>8), byte(point)))
+	}
+	return points
+}
+
+type byteSlices [][]byte
+
+func (s byteSlices) Len() int           { return len(s) }
+func (s byteSlices) Less(i, j int) bool { return bytes.Compare(s[i], s[j]) < 0 }
+func (s byteSlices) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
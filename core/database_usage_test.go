@@ -0,0 +1,68 @@
+package core
+
+import (
+	"io/ioutil"
+	"math/big"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/crypto"
+	"github.com/ethereumclassic/go-ethereum/ethdb"
+	"github.com/ethereumclassic/go-ethereum/rlp"
+	ldbutil "github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func TestChainDatabaseUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "db-usage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := ethdb.NewLDBDatabase(dir, 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Fill the database with headers, canonical hashes and trie nodes
+	for i := 0; i < 2000; i++ {
+		header := &types.Header{Number: big.NewInt(int64(i)), Extra: make([]byte, 32)}
+		if err := WriteHeader(db, header); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if err := WriteCanonicalHash(db, header.Hash(), header.Number.Uint64()); err != nil {
+			t.Fatalf("failed to write canonical hash: %v", err)
+		}
+		value := make([]byte, 500)
+		rand.Read(value)
+		node, _ := rlp.EncodeToBytes([][]byte{common.Hex2Bytes("20"), value})
+		db.Put(crypto.Keccak256(node), node)
+	}
+	if err := db.LDB().CompactRange(ldbutil.Range{}); err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+	usage, err := ChainDatabaseUsage(db, 16)
+	if err != nil {
+		t.Fatalf("failed to estimate usage: %v", err)
+	}
+	if usage.Total == 0 || usage.Sampled == 0 {
+		t.Fatalf("nothing measured: %+v", usage)
+	}
+	var sum uint64
+	for family, size := range usage.Families {
+		sum += size
+		if family != "headers" && family != "canonicalHashes" && family != "stateTrie" && family != "unattributed" {
+			t.Errorf("unexpected family %q of %d bytes", family, size)
+		}
+	}
+	if sum > usage.Total || sum < usage.Total*95/100 {
+		t.Errorf("family sizes add up to %d, total %d", sum, usage.Total)
+	}
+	// Trie nodes carry the most data, then headers
+	if usage.Families["stateTrie"] <= usage.Families["headers"] || usage.Families["headers"] <= usage.Families["canonicalHashes"] {
+		t.Errorf("unexpected proportions: %v", usage.Families)
+	}
+}
@@ -185,13 +185,68 @@ func GetBlockReceipts(db ethdb.Database, hash common.Hash) types.Receipts {
 	for i, receipt := range storageReceipts {
 		receipts[i] = (*types.Receipt)(receipt)
 	}
+	deriveReceiptFields(db, hash, receipts)
+	return receipts
+}
+
+// deriveReceiptFields fills in the fields left out of the compact storage
+// encoding of the receipts of the block with the given hash. If the block isn't
+// available they stay zero, the consensus fields being usable nonetheless.
+func deriveReceiptFields(db ethdb.Database, hash common.Hash, receipts types.Receipts) {
+	compact := false
+	for _, receipt := range receipts {
+		if receipt.GasUsed == nil {
+			compact = true
+			break
+		}
+	}
+	if !compact {
+		return
+	}
+	header, body := GetHeader(db, hash), GetBody(db, hash)
+	if header == nil || body == nil {
+		glog.V(logger.Debug).Infof("receipts of missing block %x, fields not derived", hash)
+	} else if err := receipts.DeriveFields(hash, header.Number.Uint64(), body.Transactions); err != nil {
+		glog.V(logger.Error).Infof("failed to derive receipt fields of block %x: %v", hash, err)
+	} else {
+		return
+	}
+	for _, receipt := range receipts {
+		if receipt.GasUsed == nil {
+			receipt.GasUsed = new(big.Int)
+		}
+	}
+}
+
+// GetBlockReceiptsRLP retrieves the consensus encoding of the receipts generated
+// by the transactions included in a block given by its hash, converting the
+// stored encoding without decoding the receipts.
+func GetBlockReceiptsRLP(db ethdb.Database, hash common.Hash) rlp.RawValue {
+	data, _ := db.Get(append(blockReceiptsPrefix, hash[:]...))
+	if len(data) == 0 {
+		return nil
+	}
+	receipts, err := types.ConsensusReceiptsRLP(data)
+	if err != nil {
+		glog.V(logger.Error).Infof("invalid receipt array RLP for hash %x: %v", hash, err)
+		return nil
+	}
 	return receipts
 }
 
 // GetTransaction retrieves a specific transaction from the database, along with
 // its added positional metadata.
 func GetTransaction(db ethdb.Database, hash common.Hash) (*types.Transaction, common.Hash, uint64, uint64) {
-	// Retrieve the transaction itself from the database
+	// Look the transaction up in its block body if indexed by lookup entry
+	if entry := GetTxLookupEntry(db, hash); entry != nil {
+		body := GetBody(db, entry.BlockHash)
+		if body == nil || entry.Index >= uint64(len(body.Transactions)) {
+			glog.V(logger.Error).Infof("transaction lookup %x points to missing block body %x", hash, entry.BlockHash)
+			return nil, common.Hash{}, 0, 0
+		}
+		return body.Transactions[entry.Index], entry.BlockHash, entry.BlockIndex, entry.Index
+	}
+	// Otherwise retrieve the transaction itself from the database
 	data, _ := db.Get(hash.Bytes())
 	if len(data) == 0 {
 		return nil, common.Hash{}, 0, 0
@@ -227,6 +282,15 @@ func GetReceipt(db ethdb.Database, txHash common.Hash) *types.Receipt {
 	if err != nil {
 		glog.V(logger.Core).Errorln("GetReceipt err:", err)
 	}
+	if receipt.GasUsed == nil {
+		// Stored compact, derive the missing fields along with the rest of the block's receipts
+		if _, hash, _, index := GetTransaction(db, txHash); hash != (common.Hash{}) {
+			if receipts := GetBlockReceipts(db, hash); index < uint64(len(receipts)) && receipts[index].TxHash == txHash {
+				return receipts[index]
+			}
+		}
+		receipt.TxHash, receipt.GasUsed = txHash, new(big.Int)
+	}
 	return (*types.Receipt)(&receipt)
 }
 
@@ -451,6 +515,7 @@ func DeleteBlockReceipts(db ethdb.Database, hash common.Hash) {
 func DeleteTransaction(db ethdb.Database, hash common.Hash) {
 	db.Delete(hash.Bytes())
 	db.Delete(append(hash.Bytes(), txMetaSuffix...))
+	DeleteTxLookupEntry(db, hash)
 }
 
 // DeleteReceipt removes all receipt data associated with a transaction hash.
@@ -535,6 +535,58 @@ func TestFormatAndResolveAddrTxBytesKey(t *testing.T) {
 	}
 }
 
+// Tests that contract creations are indexed along with the address transactions,
+// and removed along with them.
+func TestContractCreationIndex(t *testing.T) {
+	dbFilepath, err := ioutil.TempDir("", "geth-db-util-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dbFilepath)
+	db, _ := ethdb.NewLDBDatabase(dbFilepath, 10, 100)
+
+	key, _ := crypto.HexToECDSA("123915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+	creator := crypto.PubkeyToAddress(key.PublicKey)
+
+	signer := types.NewChainIdSigner(big.NewInt(1))
+	txs := []*types.Transaction{
+		types.NewContractCreation(7, big.NewInt(0), big.NewInt(100000), big.NewInt(1), []byte{0x60, 0x00}),
+		types.NewTransaction(8, common.BytesToAddress([]byte{0x11}), big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil),
+	}
+	for i, tx := range txs {
+		tx.SetSigner(signer)
+		if txs[i], err = tx.SignECDSA(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(42)}, txs, nil, nil)
+	if err := WriteBlockAddTxIndexes(db, block); err != nil {
+		t.Fatal(err)
+	}
+
+	contract := crypto.CreateAddress(creator, 7)
+	creation, err := GetContractCreation(db, contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creation == nil {
+		t.Fatalf("contract creation not indexed")
+	}
+	if creation.TxHash != txs[0].Hash() || creation.Creator != creator || creation.BlockNumber != 42 {
+		t.Errorf("creation mismatch: have %+v, want tx %x creator %x block 42", creation, txs[0].Hash(), creator)
+	}
+	if creation, _ := GetContractCreation(db, crypto.CreateAddress(creator, 8)); creation != nil {
+		t.Errorf("plain transaction indexed as contract creation: %+v", creation)
+	}
+
+	if err := RmAddrTx(db, txs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if creation, _ := GetContractCreation(db, contract); creation != nil {
+		t.Errorf("removed contract creation still indexed: %+v", creation)
+	}
+}
+
 // Tests that canonical numbers can be mapped to hashes and retrieved.
 func TestCanonicalMappingStorage(t *testing.T) {
 	db, _ := ethdb.NewMemDatabase()
@@ -757,6 +809,63 @@ func TestBlockReceiptStorage(t *testing.T) {
 	}
 }
 
+// Tests that the fields left out of stored receipts are derived from their block
+// when the receipts are read.
+func TestReceiptFieldDerivation(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	key, _ := crypto.HexToECDSA("123915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+	txs := []*types.Transaction{
+		types.NewTransaction(0, common.BytesToAddress([]byte{0x11}), big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil),
+		types.NewContractCreation(1, big.NewInt(0), big.NewInt(100000), big.NewInt(1), []byte{0x60, 0x00}),
+	}
+	for i, tx := range txs {
+		var err error
+		if txs[i], err = tx.SignECDSA(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(7)}, txs, nil, nil)
+	receipts := types.Receipts{
+		&types.Receipt{PostState: []byte{0x01}, CumulativeGasUsed: big.NewInt(21000)},
+		&types.Receipt{PostState: []byte{0x02}, CumulativeGasUsed: big.NewInt(75000), Logs: vm.Logs{&vm.Log{Address: common.BytesToAddress([]byte{0x22})}}},
+	}
+	WriteBlock(db, block)
+	WriteTransactions(db, block)
+	if err := receipts.DeriveFields(block.Hash(), block.NumberU64(), txs); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteBlockReceipts(db, block.Hash(), receipts); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteReceipts(db, receipts); err != nil {
+		t.Fatal(err)
+	}
+	check := func(have, want *types.Receipt) {
+		if have == nil {
+			t.Fatalf("receipt %x not found", want.TxHash)
+		}
+		if have.TxHash != want.TxHash || have.ContractAddress != want.ContractAddress || have.GasUsed.Cmp(want.GasUsed) != 0 {
+			t.Errorf("receipt mismatch: have %x %x %v, want %x %x %v", have.TxHash, have.ContractAddress, have.GasUsed, want.TxHash, want.ContractAddress, want.GasUsed)
+		}
+		for i, log := range have.Logs {
+			haveLog, _ := rlp.EncodeToBytes((*vm.LogForStorage)(log))
+			wantLog, _ := rlp.EncodeToBytes((*vm.LogForStorage)(want.Logs[i]))
+			if !bytes.Equal(haveLog, wantLog) {
+				t.Errorf("log %d mismatch: have %+v, want %+v", i, log, want.Logs[i])
+			}
+		}
+	}
+	stored := GetBlockReceipts(db, block.Hash())
+	if len(stored) != len(receipts) {
+		t.Fatalf("receipt count mismatch: have %d, want %d", len(stored), len(receipts))
+	}
+	for i, receipt := range receipts {
+		check(stored[i], receipt)
+		check(GetReceipt(db, receipt.TxHash), receipt)
+	}
+}
+
 func TestMipmapBloom(t *testing.T) {
 	db, _ := ethdb.NewMemDatabase()
 
@@ -12,6 +12,37 @@ import (
 // TxPreEvent is posted when a transaction enters the transaction pool.
 type TxPreEvent struct{ Tx *types.Transaction }
 
+// TxNonceGapEvent is posted when queued transactions of an account can't be
+// promoted because of missing nonces, or when the missing range changes.
+type TxNonceGapEvent struct {
+	Account common.Address
+	Missing NonceRange
+}
+
+// TxNonceGapFilledEvent is posted when queued transactions of an account are no
+// longer blocked by a nonce gap, either because it was filled or because the
+// queued transactions were dropped.
+type TxNonceGapFilledEvent struct{ Account common.Address }
+
+// Statuses of local transactions reported by TxLifecycleEvent.
+const (
+	TxExpired    = "expired"    // Dropped from the pool once its time-to-live elapsed
+	TxCancelling = "cancelling" // Dropped once expired, a cancelling replacement was submitted
+	TxCancelled  = "cancelled"  // The cancelling replacement was mined
+	TxMined      = "mined"      // Mined before expiring, or despite it and the replacement was not
+	TxDropped    = "dropped"    // Dropped from the pool before expiring without being mined
+	TxSuperseded = "superseded" // Neither it nor its replacement was mined, another transaction used the nonce
+)
+
+// TxLifecycleEvent is posted when a local transaction tracked by the node changes
+// status, such as when its time-to-live elapses.
+type TxLifecycleEvent struct {
+	Hash        common.Hash
+	Status      string      // One of the Tx* statuses
+	Replacement common.Hash // Cancelling transaction, if any
+	Err         error       // Why the transaction couldn't be cancelled, if it couldn't
+}
+
 // TxPostEvent is posted when a transaction has been processed.
 type TxPostEvent struct{ Tx *types.Transaction }
 
@@ -97,6 +128,14 @@ type ChainHeadEvent struct{ Block *types.Block }
 
 type GasPriceChanged struct{ Price *big.Int }
 
+// StorageDegradedEvent is posted when the node stops importing blocks because
+// its disk space or file descriptors run out, before writes start failing.
+type StorageDegradedEvent struct{ Reason string }
+
+// StorageRecoveredEvent is posted when block import resumes after a
+// StorageDegradedEvent.
+type StorageRecoveredEvent struct{}
+
 // Mining operation events
 type StartMining struct{}
 type StopMining struct{}
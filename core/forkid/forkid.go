@@ -0,0 +1,128 @@
+// Package forkid implements the fork identifier of EIP-2124, exchanged in the
+// status message of eth/65 to tell apart peers on incompatible chains before
+// any block is exchanged.
+package forkid
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math"
+	"sort"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core"
+)
+
+var (
+	// ErrRemoteStale is returned by a Filter if the remote node is on a fork we
+	// passed but hasn't announced the next fork we know of, so it is outdated.
+	ErrRemoteStale = errors.New("remote needs update")
+
+	// ErrLocalIncompatibleOrStale is returned by a Filter if the remote node
+	// announces forks we don't know of, or a fork we already passed otherwise.
+	ErrLocalIncompatibleOrStale = errors.New("local incompatible or needs update")
+)
+
+// ID is the fork identifier: a checksum of the genesis hash and the blocks of
+// the forks passed, along with the block of the next fork, 0 if none is known.
+type ID struct {
+	Hash [4]byte
+	Next uint64
+}
+
+// Filter validates the fork identifier of a remote node.
+type Filter func(id ID) error
+
+// NewID returns the fork identifier of the given chain at the given head.
+func NewID(config *core.ChainConfig, genesis common.Hash, head uint64) ID {
+	hash := crc32.ChecksumIEEE(genesis[:])
+	for _, fork := range gatherForks(config) {
+		if fork > head {
+			return ID{Hash: checksumToBytes(hash), Next: fork}
+		}
+		hash = checksumUpdate(hash, fork)
+	}
+	return ID{Hash: checksumToBytes(hash)}
+}
+
+// NewFilter creates a filter of the fork identifiers compatible with the given
+// chain at the head returned by headfn.
+func NewFilter(config *core.ChainConfig, genesis common.Hash, headfn func() uint64) Filter {
+	forks := gatherForks(config)
+	sums := make([][4]byte, len(forks)+1) // Checksum before each fork and after the last one
+	hash := crc32.ChecksumIEEE(genesis[:])
+	sums[0] = checksumToBytes(hash)
+	for i, fork := range forks {
+		hash = checksumUpdate(hash, fork)
+		sums[i+1] = checksumToBytes(hash)
+	}
+	forks = append(forks, math.MaxUint64) // The last checksum holds until the end of the chain
+
+	return func(id ID) error {
+		head := headfn()
+		for i, fork := range forks {
+			if head >= fork {
+				continue
+			}
+			// Found the local fork, the remote must be on it or on a fork before or
+			// after it on our chain
+			if sums[i] == id.Hash {
+				if id.Next > 0 && head >= id.Next {
+					return ErrLocalIncompatibleOrStale
+				}
+				return nil
+			}
+			for j := 0; j < i; j++ {
+				if sums[j] == id.Hash {
+					// Behind us, it must know the fork that moved us past its checksum
+					if forks[j] != id.Next {
+						return ErrRemoteStale
+					}
+					return nil
+				}
+			}
+			for j := i + 1; j < len(sums); j++ {
+				if sums[j] == id.Hash {
+					// Ahead of us, we may still be syncing
+					return nil
+				}
+			}
+			return ErrLocalIncompatibleOrStale
+		}
+		return ErrLocalIncompatibleOrStale
+	}
+}
+
+// gatherForks returns the blocks of the forks changing the rules of the chain in
+// ascending order, leaving out the genesis and forks only pinning a block hash.
+func gatherForks(config *core.ChainConfig) []uint64 {
+	var forks []uint64
+	for _, fork := range config.Forks {
+		if fork.Block == nil || fork.Block.Sign() <= 0 || !fork.Block.IsUint64() || len(fork.Features) == 0 {
+			continue
+		}
+		forks = append(forks, fork.Block.Uint64())
+	}
+	sort.Slice(forks, func(i, j int) bool { return forks[i] < forks[j] })
+	for i := 1; i < len(forks); i++ {
+		if forks[i] == forks[i-1] {
+			forks = append(forks[:i], forks[i+1:]...)
+			i--
+		}
+	}
+	return forks
+}
+
+// checksumUpdate extends the checksum with a fork block.
+func checksumUpdate(hash uint32, fork uint64) uint32 {
+	var blob [8]byte
+	binary.BigEndian.PutUint64(blob[:], fork)
+	return crc32.Update(hash, crc32.IEEETable, blob[:])
+}
+
+func checksumToBytes(hash uint32) [4]byte {
+	var blob [4]byte
+	binary.BigEndian.PutUint32(blob[:], hash)
+	return blob
+}
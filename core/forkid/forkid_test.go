@@ -0,0 +1,74 @@
+package forkid
+
+import (
+	"testing"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core"
+)
+
+var mainnetGenesis = common.HexToHash("d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3")
+
+// Tests that fork identifiers are computed correctly, the values before the
+// first forks matching the test vectors of EIP-2124.
+func TestCreation(t *testing.T) {
+	config := core.DefaultConfigMainnet.ChainConfig
+	tests := []struct {
+		head uint64
+		want ID
+	}{
+		{0, ID{Hash: [4]byte{0xfc, 0x64, 0xec, 0x04}, Next: 1150000}},
+		{1149999, ID{Hash: [4]byte{0xfc, 0x64, 0xec, 0x04}, Next: 1150000}},
+		{1150000, ID{Hash: [4]byte{0x97, 0xc2, 0xc3, 0x4c}, Next: 2500000}},
+		{2499999, ID{Hash: [4]byte{0x97, 0xc2, 0xc3, 0x4c}, Next: 2500000}},
+	}
+	for _, tt := range tests {
+		if id := NewID(config, mainnetGenesis, tt.head); id != tt.want {
+			t.Errorf("head %d: fork ID mismatch: got %x/%d, want %x/%d", tt.head, id.Hash, id.Next, tt.want.Hash, tt.want.Next)
+		}
+	}
+	// Forks only pinning a block hash, like the DAO fork on this chain, don't count
+	if a, b := NewID(config, mainnetGenesis, 1920000), NewID(config, mainnetGenesis, 1919999); a != b {
+		t.Errorf("fork ID changed at a hash pinning fork: %x/%d, %x/%d", a.Hash, a.Next, b.Hash, b.Next)
+	}
+	if last := NewID(config, mainnetGenesis, 1<<62); last.Next != 0 {
+		t.Errorf("next fork announced after the last one: %d", last.Next)
+	}
+}
+
+// Tests that remote fork identifiers are validated correctly.
+func TestValidation(t *testing.T) {
+	config := core.DefaultConfigMainnet.ChainConfig
+	var (
+		frontier  = NewID(config, mainnetGenesis, 0)
+		homestead = NewID(config, mainnetGenesis, 1150000)
+		future    = NewID(config, mainnetGenesis, 1<<62)
+	)
+	tests := []struct {
+		head uint64
+		id   ID
+		err  error
+	}{
+		// Same fork, with and without knowing the next one
+		{0, frontier, nil},
+		{0, ID{Hash: frontier.Hash}, nil},
+		// Remote ahead of us, we may be syncing
+		{0, homestead, nil},
+		{0, future, nil},
+		// Remote behind us, knowing the fork we passed
+		{1150000, frontier, nil},
+		// Remote behind us, not knowing the fork we passed
+		{1150000, ID{Hash: frontier.Hash}, ErrRemoteStale},
+		// Remote announcing a fork we passed without forking
+		{2000000, ID{Hash: homestead.Hash, Next: 1920000}, ErrLocalIncompatibleOrStale},
+		// Remote on another chain
+		{0, ID{Hash: [4]byte{1, 2, 3, 4}}, ErrLocalIncompatibleOrStale},
+	}
+	for i, tt := range tests {
+		head := tt.head
+		filter := NewFilter(config, mainnetGenesis, func() uint64 { return head })
+		if err := filter(tt.id); err != tt.err {
+			t.Errorf("test %d: validation error mismatch: got %v, want %v", i, err, tt.err)
+		}
+	}
+}
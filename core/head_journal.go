@@ -0,0 +1,129 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/rlp"
+)
+
+// headJournalKey holds the pending update of the head pointers while it is being
+// applied. Its deletion marks the update as committed.
+var headJournalKey = []byte("HeadJournal")
+
+// headPointers are the hashes of the head header, head block and head fast block.
+// A zero hash leaves the corresponding pointer unchanged.
+type headPointers struct {
+	Header common.Hash
+	Block  common.Hash
+	Fast   common.Hash
+}
+
+func (p headPointers) String() string {
+	return fmt.Sprintf("header=%x block=%x fast=%x", p.Header[:4], p.Block[:4], p.Fast[:4])
+}
+
+// headJournal is the write-ahead record of an update of the head pointers.
+type headJournal struct {
+	Old headPointers
+	New headPointers
+}
+
+// readHeadPointers returns the head pointers currently stored in the database.
+func readHeadPointers(db ethdb.Database) headPointers {
+	return headPointers{
+		Header: GetHeadHeaderHash(db),
+		Block:  GetHeadBlockHash(db),
+		Fast:   GetHeadFastBlockHash(db),
+	}
+}
+
+// applyHeadPointers writes the non-zero head pointers.
+func applyHeadPointers(db ethdb.Database, p headPointers) error {
+	if p.Header != (common.Hash{}) {
+		if err := WriteHeadHeaderHash(db, p.Header); err != nil {
+			return err
+		}
+	}
+	if p.Block != (common.Hash{}) {
+		if err := WriteHeadBlockHash(db, p.Block); err != nil {
+			return err
+		}
+	}
+	if p.Fast != (common.Hash{}) {
+		if err := WriteHeadFastBlockHash(db, p.Fast); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHeadPointers updates several head pointers at once. The update is first
+// journaled along with the pointers it replaces, so that if it is interrupted
+// recoverHeadJournal can complete or undo it as a whole at the next startup.
+func writeHeadPointers(db ethdb.Database, p headPointers) error {
+	old := readHeadPointers(db)
+	if p.Header == (common.Hash{}) {
+		old.Header = common.Hash{}
+	}
+	if p.Block == (common.Hash{}) {
+		old.Block = common.Hash{}
+	}
+	if p.Fast == (common.Hash{}) {
+		old.Fast = common.Hash{}
+	}
+	data, err := rlp.EncodeToBytes(&headJournal{Old: old, New: p})
+	if err != nil {
+		return err
+	}
+	if err := db.Put(headJournalKey, data); err != nil {
+		return err
+	}
+	if err := applyHeadPointers(db, p); err != nil {
+		return err
+	}
+	return db.Delete(headJournalKey)
+}
+
+// recoverHeadJournal finishes a head pointer update interrupted by a crash. The
+// update is rolled forward if all the headers and blocks it points to are in the
+// database, and rolled back to the pointers it replaced otherwise.
+func recoverHeadJournal(db ethdb.Database) error {
+	data, _ := db.Get(headJournalKey)
+	if len(data) == 0 {
+		return nil
+	}
+	journal := new(headJournal)
+	if err := rlp.DecodeBytes(data, journal); err != nil {
+		return fmt.Errorf("invalid head journal: %v", err)
+	}
+	target, action := journal.New, "rolled forward"
+	if !headPointersPresent(db, journal.New) {
+		target, action = journal.Old, "rolled back"
+	}
+	if err := applyHeadPointers(db, target); err != nil {
+		return err
+	}
+	if err := db.Delete(headJournalKey); err != nil {
+		return err
+	}
+	glog.V(logger.Warn).Warnf("Interrupted head pointer update %s: %v", action, target)
+	return nil
+}
+
+// headPointersPresent reports whether the header and blocks the non-zero
+// pointers refer to are all stored.
+func headPointersPresent(db ethdb.Database, p headPointers) bool {
+	if p.Header != (common.Hash{}) && GetHeader(db, p.Header) == nil {
+		return false
+	}
+	for _, hash := range []common.Hash{p.Block, p.Fast} {
+		if hash != (common.Hash{}) && GetBlock(db, hash) == nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/ethdb"
+	"github.com/ethereumclassic/go-ethereum/rlp"
+)
+
+// Tests that an interrupted head pointer update is rolled forward if its target
+// blocks are stored, and rolled back otherwise.
+func TestHeadJournalRecovery(t *testing.T) {
+	old := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Extra: []byte("old")})
+	stored := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), Extra: []byte("stored")})
+	missing := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), Extra: []byte("missing")})
+
+	tests := []struct {
+		target *types.Block
+		want   common.Hash
+	}{
+		{stored, stored.Hash()},
+		{missing, old.Hash()},
+	}
+	for i, tt := range tests {
+		db, _ := ethdb.NewMemDatabase()
+		for _, block := range []*types.Block{old, stored} {
+			if err := WriteBlock(db, block); err != nil {
+				t.Fatalf("test %d: failed to write block: %v", i, err)
+			}
+		}
+		if err := writeHeadPointers(db, headPointers{Header: old.Hash(), Block: old.Hash(), Fast: old.Hash()}); err != nil {
+			t.Fatalf("test %d: failed to write heads: %v", i, err)
+		}
+		if data, _ := db.Get(headJournalKey); len(data) != 0 {
+			t.Fatalf("test %d: journal left behind after committed update", i)
+		}
+		// Simulate a crash after journaling the update and writing only the first pointer
+		target := tt.target.Hash()
+		data, err := rlp.EncodeToBytes(&headJournal{
+			Old: readHeadPointers(db),
+			New: headPointers{Header: target, Block: target, Fast: target},
+		})
+		if err != nil {
+			t.Fatalf("test %d: failed to encode journal: %v", i, err)
+		}
+		db.Put(headJournalKey, data)
+		WriteHeadHeaderHash(db, target)
+
+		if err := recoverHeadJournal(db); err != nil {
+			t.Fatalf("test %d: recovery failed: %v", i, err)
+		}
+		want := headPointers{Header: tt.want, Block: tt.want, Fast: tt.want}
+		if have := readHeadPointers(db); have != want {
+			t.Errorf("test %d: heads mismatch: have %v, want %v", i, have, want)
+		}
+		if data, _ := db.Get(headJournalKey); len(data) != 0 {
+			t.Errorf("test %d: journal left behind after recovery", i)
+		}
+	}
+}
@@ -37,6 +37,8 @@ type HeaderChain struct {
 	headerCache *lru.Cache // Cache for the most recent block headers
 	tdCache     *lru.Cache // Cache for the most recent block total difficulties
 
+	headerMeter, tdMeter cacheMeter // Cache hit and miss counters
+
 	procInterrupt func() bool
 
 	rand         *mrand.Rand
@@ -391,8 +393,10 @@ func (hc *HeaderChain) GetBlockHashesFromHash(hash common.Hash, max uint64) []co
 func (hc *HeaderChain) GetTd(hash common.Hash) *big.Int {
 	// Short circuit if the td's already in the cache, retrieve otherwise
 	if cached, ok := hc.tdCache.Get(hash); ok {
+		hc.tdMeter.hit()
 		return cached.(*big.Int)
 	}
+	hc.tdMeter.miss()
 	td := GetTd(hc.chainDb, hash)
 	if td == nil {
 		return nil
@@ -417,8 +421,10 @@ func (hc *HeaderChain) WriteTd(hash common.Hash, td *big.Int) error {
 func (hc *HeaderChain) GetHeader(hash common.Hash) *types.Header {
 	// Short circuit if the header's already in the cache, retrieve otherwise
 	if header, ok := hc.headerCache.Get(hash); ok {
+		hc.headerMeter.hit()
 		return header.(*types.Header)
 	}
+	hc.headerMeter.miss()
 	header := GetHeader(hc.chainDb, hash)
 	if header == nil {
 		return nil
@@ -455,6 +461,12 @@ func (hc *HeaderChain) SetCurrentHeader(head *types.Header) {
 	if err := WriteHeadHeaderHash(hc.chainDb, head.Hash()); err != nil {
 		glog.Fatalf("failed to insert head header hash: %v", err)
 	}
+	hc.setCurrentHeader(head)
+}
+
+// setCurrentHeader sets the in-memory head header only, for callers persisting
+// the head pointers themselves.
+func (hc *HeaderChain) setCurrentHeader(head *types.Header) {
 	hc.currentHeader = head
 	hc.currentHeaderHash = head.Hash()
 }
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+var (
+	errImportFrozen      = errors.New("block import already frozen")
+	errImportNotFrozen   = errors.New("block import not frozen")
+	errImportFrozenOther = errors.New("block import frozen by another freeze")
+)
+
+// importFreeze pauses the insertion of blocks, headers and receipts into the
+// chain. Insertions hold the read side of the gate, so freezing waits for those
+// in flight to finish and holds back new ones until thawed. Blocked callers keep
+// their data, which makes the downloader stop fetching once its bounded result
+// cache is full.
+type importFreeze struct {
+	gate sync.RWMutex
+
+	lock  sync.Mutex
+	id    uint64      // Identifier of the current or last freeze, counting from 1
+	since time.Time   // Zero if not frozen
+	until time.Time   // Automatic thaw, zero if none
+	timer *time.Timer // Fires the automatic thaw
+}
+
+// ImportFreezeStatus reports whether block import is frozen.
+type ImportFreezeStatus struct {
+	Frozen bool       `json:"frozen"`
+	Since  *time.Time `json:"since,omitempty"`
+	Until  *time.Time `json:"until,omitempty"` // Automatic thaw, if any
+}
+
+// FreezeImport pauses block import once the insertions in progress finish, so
+// the database can be backed up or queried without competing with import I/O.
+// If timeout is positive, import resumes automatically after it elapses.
+func (bc *BlockChain) FreezeImport(timeout time.Duration) error {
+	_, err := bc.FreezeImportID(timeout)
+	return err
+}
+
+// FreezeImportID is like FreezeImport, additionally returning the identifier of
+// the freeze, so that its owner can thaw it with ThawImportID without thawing a
+// freeze made by someone else in the meantime.
+func (bc *BlockChain) FreezeImportID(timeout time.Duration) (uint64, error) {
+	f := &bc.freeze
+	f.lock.Lock()
+	frozen := !f.since.IsZero()
+	f.lock.Unlock()
+	if frozen {
+		return 0, errImportFrozen
+	}
+	f.gate.Lock()
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if !f.since.IsZero() { // Lost a race with another freeze
+		f.gate.Unlock()
+		return 0, errImportFrozen
+	}
+	f.id++
+	f.since = time.Now()
+	if timeout > 0 {
+		id := f.id
+		f.until = f.since.Add(timeout)
+		f.timer = time.AfterFunc(timeout, func() {
+			if bc.ThawImportID(id) == nil {
+				glog.V(logger.Warn).Warnf("Block import thawed after freeze timeout of %v", timeout)
+			}
+		})
+	}
+	glog.V(logger.Info).Infoln("Block import frozen")
+	return f.id, nil
+}
+
+// ThawImport resumes block import paused by FreezeImport, whoever froze it.
+func (bc *BlockChain) ThawImport() error {
+	f := &bc.freeze
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.since.IsZero() {
+		return errImportNotFrozen
+	}
+	f.thaw()
+	return nil
+}
+
+// ThawImportID resumes block import if it is paused by the freeze with the given
+// identifier, as returned by FreezeImportID.
+func (bc *BlockChain) ThawImportID(id uint64) error {
+	f := &bc.freeze
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	switch {
+	case f.since.IsZero():
+		return errImportNotFrozen
+	case f.id != id:
+		return errImportFrozenOther
+	}
+	f.thaw()
+	return nil
+}
+
+// thaw resumes block import. The caller must hold the lock and import must be
+// frozen.
+func (f *importFreeze) thaw() {
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	glog.V(logger.Info).Infof("Block import thawed after %v", time.Since(f.since))
+	f.since, f.until = time.Time{}, time.Time{}
+	f.gate.Unlock()
+}
+
+// ImportFreezeStatus returns whether block import is frozen, since when and
+// until when.
+func (bc *BlockChain) ImportFreezeStatus() ImportFreezeStatus {
+	f := &bc.freeze
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var status ImportFreezeStatus
+	if !f.since.IsZero() {
+		since := f.since
+		status.Frozen, status.Since = true, &since
+	}
+	if !f.until.IsZero() {
+		until := f.until
+		status.Until = &until
+	}
+	return status
+}
+
+// enterImport blocks while import is frozen, the returned function must be
+// called once the insertion is done.
+func (bc *BlockChain) enterImport() func() {
+	bc.freeze.gate.RLock()
+	return func() {
+		atomic.StoreInt64(&bc.lastImport, time.Now().UnixNano())
+		bc.freeze.gate.RUnlock()
+	}
+}
+
+// ImportIdleTime returns the time elapsed since the last insertion of blocks,
+// headers or receipts finished, or since the chain was loaded if none did.
+func (bc *BlockChain) ImportIdleTime() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&bc.lastImport)))
+}
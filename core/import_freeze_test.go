@@ -0,0 +1,141 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/ethdb"
+	"github.com/ethereumclassic/go-ethereum/event"
+)
+
+func TestImportFreeze(t *testing.T) {
+	bc := new(BlockChain)
+
+	if err := bc.ThawImport(); err != errImportNotFrozen {
+		t.Fatalf("thaw of unfrozen import: have %v, want %v", err, errImportNotFrozen)
+	}
+	if err := bc.FreezeImport(0); err != nil {
+		t.Fatalf("failed to freeze import: %v", err)
+	}
+	if err := bc.FreezeImport(0); err != errImportFrozen {
+		t.Fatalf("second freeze: have %v, want %v", err, errImportFrozen)
+	}
+	if status := bc.ImportFreezeStatus(); !status.Frozen || status.Since == nil || status.Until != nil {
+		t.Fatalf("status mismatch: %+v", status)
+	}
+	// Imports must wait until thawed
+	entered := make(chan struct{})
+	go func() {
+		defer bc.enterImport()()
+		close(entered)
+	}()
+	select {
+	case <-entered:
+		t.Fatalf("import entered while frozen")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := bc.ThawImport(); err != nil {
+		t.Fatalf("failed to thaw import: %v", err)
+	}
+	select {
+	case <-entered:
+	case <-time.After(time.Second):
+		t.Fatalf("import still blocked after thaw")
+	}
+	if status := bc.ImportFreezeStatus(); status.Frozen {
+		t.Fatalf("status mismatch after thaw: %+v", status)
+	}
+
+	// Freezes with a timeout thaw automatically
+	if err := bc.FreezeImport(50 * time.Millisecond); err != nil {
+		t.Fatalf("failed to freeze import: %v", err)
+	}
+	if status := bc.ImportFreezeStatus(); status.Until == nil {
+		t.Fatalf("automatic thaw not reported: %+v", status)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer bc.enterImport()()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("import not thawed after timeout")
+	}
+}
+
+// Tests that blocks aren't written while import is frozen.
+func TestWriteBlockFrozen(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db)
+	config := MakeChainConfig()
+	bc, err := NewBlockChain(db, config, new(event.TypeMux))
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	blocks, _ := GenerateChain(config, genesis, db, 1, nil)
+
+	if err := bc.FreezeImport(0); err != nil {
+		t.Fatalf("failed to freeze import: %v", err)
+	}
+	written := make(chan error, 1)
+	go func() {
+		_, err := bc.WriteBlock(blocks[0])
+		written <- err
+	}()
+	select {
+	case <-written:
+		t.Fatalf("block written while frozen")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if head := bc.CurrentBlock().NumberU64(); head != 0 {
+		t.Fatalf("head moved while frozen: #%d", head)
+	}
+	if err := bc.ThawImport(); err != nil {
+		t.Fatalf("failed to thaw import: %v", err)
+	}
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatalf("failed to write block: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("block write still blocked after thaw")
+	}
+	if head := bc.CurrentBlock().NumberU64(); head != 1 {
+		t.Fatalf("head mismatch after thaw: have #%d, want #1", head)
+	}
+}
+
+// Tests that a freeze can only be thawed by its identifier while it lasts.
+func TestImportFreezeID(t *testing.T) {
+	bc := new(BlockChain)
+
+	first, err := bc.FreezeImportID(0)
+	if err != nil {
+		t.Fatalf("failed to freeze import: %v", err)
+	}
+	if err := bc.ThawImportID(first); err != nil {
+		t.Fatalf("failed to thaw own freeze: %v", err)
+	}
+	if err := bc.ThawImportID(first); err != errImportNotFrozen {
+		t.Fatalf("second thaw: have %v, want %v", err, errImportNotFrozen)
+	}
+	second, err := bc.FreezeImportID(0)
+	if err != nil {
+		t.Fatalf("failed to freeze import: %v", err)
+	}
+	if second == first {
+		t.Fatalf("freeze identifier reused: %d", second)
+	}
+	if err := bc.ThawImportID(first); err != errImportFrozenOther {
+		t.Fatalf("thaw of another freeze: have %v, want %v", err, errImportFrozenOther)
+	}
+	if !bc.ImportFreezeStatus().Frozen {
+		t.Fatalf("freeze thawed by another owner")
+	}
+	if err := bc.ThawImport(); err != nil {
+		t.Fatalf("failed to thaw import: %v", err)
+	}
+}
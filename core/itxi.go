@@ -0,0 +1,323 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/state"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/core/vm"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+var (
+	internalTxIndexPrefix = []byte("itx-")
+	internalTxBlockPrefix = []byte("itxb-") // itxb-<blockNumber> -> keys indexed for the block
+	internalTxBookmarkKey = []byte("ITXIBookmark")
+)
+
+// internalTxKeyLength is the length of an index key: prefix(4)+addr(20)+blockNumber(8)+dir(1)+txhash(32)+seq(2)
+const internalTxKeyLength = 67
+
+// itxiFlushBlocks is the number of blocks whose internal transactions are batched
+// before being written to the index.
+const itxiFlushBlocks = 1000
+
+// InternalTx is a value transfer made by a contract during the execution of a
+// transaction, as seen from one of the two accounts involved.
+type InternalTx struct {
+	TxHash       common.Hash
+	BlockNumber  uint64
+	Direction    byte           // 't' if the account received the value, 'f' if it sent it
+	Counterparty common.Address // The account on the other side of the transfer
+	Value        *big.Int
+	Op           vm.OpCode
+}
+
+// formatInternalTxIterator formats the index key prefix iterator, eg. itx-<address>
+func formatInternalTxIterator(address common.Address) []byte {
+	return append(append([]byte{}, internalTxIndexPrefix...), address.Bytes()...)
+}
+
+// formatInternalTxKey formats the index key, eg. itx-<addr><blockNumber><t|f><txhash><seq>
+// The sequence number tells apart the transfers of a transaction involving the same account.
+func formatInternalTxKey(address common.Address, blockNumber uint64, direction byte, txhash common.Hash, seq uint16) []byte {
+	key := make([]byte, 0, internalTxKeyLength)
+	key = append(key, formatInternalTxIterator(address)...)
+	key = append(key, make([]byte, 8)...)
+	binary.LittleEndian.PutUint64(key[24:32], blockNumber)
+	key = append(key, direction)
+	key = append(key, txhash.Bytes()...)
+	key = append(key, byte(seq>>8), byte(seq))
+	return key
+}
+
+// formatInternalTxBlockKey formats the key of the record of the index keys of a block, eg. itxb-<blockNumber>
+func formatInternalTxBlockKey(blockNumber uint64) []byte {
+	key := append(append([]byte{}, internalTxBlockPrefix...), make([]byte, 8)...)
+	binary.LittleEndian.PutUint64(key[len(internalTxBlockPrefix):], blockNumber)
+	return key
+}
+
+// putInternalTxsToBatch formats and puts the index entries of the transfers made by
+// a transaction, one for each account involved, returning the keys put.
+func putInternalTxsToBatch(batch ethdb.Batch, blockNumber uint64, txhash common.Hash, transfers []vm.Transfer) ([][]byte, error) {
+	var keys [][]byte
+	for i, transfer := range transfers {
+		if i > math.MaxUint16 {
+			return nil, fmt.Errorf("too many internal transactions in tx %x", txhash)
+		}
+		value := transfer.Value.Bytes()
+
+		outKey := formatInternalTxKey(transfer.From, blockNumber, 'f', txhash, uint16(i))
+		out := append(append([]byte{}, transfer.To.Bytes()...), byte(transfer.Op))
+		if err := batch.Put(outKey, append(out, value...)); err != nil {
+			return nil, err
+		}
+		inKey := formatInternalTxKey(transfer.To, blockNumber, 't', txhash, uint16(i))
+		in := append(append([]byte{}, transfer.From.Bytes()...), byte(transfer.Op))
+		if err := batch.Put(inKey, append(in, value...)); err != nil {
+			return nil, err
+		}
+		keys = append(keys, outKey, inKey)
+	}
+	return keys, nil
+}
+
+// indexBlockInternalTxs re-executes a block on the state of its parent and puts
+// the index entries of its internal transactions, along with the record of their
+// keys, returning the number of transactions and internal transactions indexed.
+func indexBlockInternalTxs(batch ethdb.Batch, bc *BlockChain, processor *StateProcessor, tracer *vm.TransferTracer, stateDB state.Database, block *types.Block) (int, int, error) {
+	n := block.NumberU64()
+	if len(block.Transactions()) == 0 {
+		return 0, 0, nil
+	}
+	parent := bc.GetBlock(block.ParentHash())
+	if parent == nil {
+		return 0, 0, fmt.Errorf("parent of block %d is nil", n)
+	}
+	statedb, err := state.New(parent.Root(), stateDB)
+	if err != nil {
+		return 0, 0, fmt.Errorf("state of block %d unavailable, indexing internal transactions requires an archive node: %v", n-1, err)
+	}
+	var record []byte
+	_, _, _, err = processor.ProcessTraced(block, statedb, tracer, func(i int, tx *types.Transaction, receipt *types.Receipt) error {
+		keys, err := putInternalTxsToBatch(batch, n, tx.Hash(), tracer.Finish(receipt.Status == types.TxSuccess))
+		for _, key := range keys {
+			record = append(record, key...)
+		}
+		return err
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to process block %d: %v", n, err)
+	}
+	if len(record) > 0 {
+		if err := batch.Put(formatInternalTxBlockKey(n), record); err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(block.Transactions()), len(record) / (2 * internalTxKeyLength), nil
+}
+
+// rmBlockInternalTxs removes the index entries of the internal transactions of
+// the given block, as recorded when it was indexed.
+func rmBlockInternalTxs(db ethdb.Database, blockNumber uint64) error {
+	record, err := db.Get(formatInternalTxBlockKey(blockNumber))
+	if err != nil {
+		return nil // Nothing indexed
+	}
+	for i := 0; i+internalTxKeyLength <= len(record); i += internalTxKeyLength {
+		if err := db.Delete(record[i : i+internalTxKeyLength]); err != nil {
+			return err
+		}
+	}
+	return db.Delete(formatInternalTxBlockKey(blockNumber))
+}
+
+// rewindInternalTxIndex removes the internal transactions indexed from the given
+// block on, lowering the progress of the index to it.
+func rewindInternalTxIndex(db ethdb.Database, from uint64) error {
+	progress := dbGetBookmark(db, internalTxBookmarkKey)
+	if progress <= from {
+		return nil
+	}
+	for n := from; n < progress; n++ {
+		if err := rmBlockInternalTxs(db, n); err != nil {
+			return err
+		}
+	}
+	return dbSetBookmark(db, internalTxBookmarkKey, from)
+}
+
+// GetInternalTxs returns the indexed internal transactions of the given account
+// between the given blocks (0 meaning unbounded), newest first. The direction is
+// 't' for received, 'f' for sent and 'b' or 0 for both.
+func GetInternalTxs(db ethdb.Database, address common.Address, blockStartN, blockEndN uint64, direction byte) ([]*InternalTx, error) {
+	if direction != 0 && direction != 'b' && direction != 't' && direction != 'f' {
+		return nil, fmt.Errorf("%v: direction must be one of [b|t|f]", errAtxiInvalidUse)
+	}
+	ldb, ok := db.(*ethdb.LDBDatabase)
+	if !ok {
+		return nil, errors.New("internal interface error; could not cast index db to level db")
+	}
+	it := ldb.NewIteratorRange(ethdb.NewBytesPrefix(formatInternalTxIterator(address)))
+	defer it.Release()
+
+	var txs []*InternalTx
+	for it.Next() {
+		key, value := it.Key(), it.Value()
+		if len(key) != internalTxKeyLength || len(value) < common.AddressLength+1 {
+			continue
+		}
+		tx := &InternalTx{
+			BlockNumber:  binary.LittleEndian.Uint64(key[24:32]),
+			Direction:    key[32],
+			TxHash:       common.BytesToHash(key[33:65]),
+			Counterparty: common.BytesToAddress(value[:common.AddressLength]),
+			Op:           vm.OpCode(value[common.AddressLength]),
+			Value:        new(big.Int).SetBytes(value[common.AddressLength+1:]),
+		}
+		if (blockStartN > 0 && tx.BlockNumber < blockStartN) || (blockEndN > 0 && tx.BlockNumber > blockEndN) {
+			continue
+		}
+		if direction == 't' || direction == 'f' {
+			if tx.Direction != direction {
+				continue
+			}
+		}
+		txs = append(txs, tx)
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	sort.Stable(internalTxsByBlock(txs))
+	return txs, nil
+}
+
+// InternalTxIndexProgress returns the first block whose internal transactions
+// aren't indexed yet, 0 if the index was never built.
+func InternalTxIndexProgress(indexDB ethdb.Database) uint64 {
+	return dbGetBookmark(indexDB, internalTxBookmarkKey)
+}
+
+// updateInternalTxIndex indexes the internal transactions of a block becoming
+// canonical, if the index was built up to its parent. Without the state of the
+// parent the index is left behind, to be caught up by the build command.
+func (bc *BlockChain) updateInternalTxIndex(block *types.Block) error {
+	if bc.atxi == nil {
+		return nil
+	}
+	n := block.NumberU64()
+	if progress := InternalTxIndexProgress(bc.atxi.Db); progress == 0 || progress != n {
+		return nil
+	}
+	batch := bc.atxi.Db.NewBatch()
+	_, _, err := indexBlockInternalTxs(batch, bc, NewStateProcessor(bc.config, bc), vm.NewTransferTracer(), state.NewDatabase(bc.chainDb), block)
+	if err != nil {
+		glog.V(logger.Debug).Infof("Internal transaction index left at block %d: %v", n, err)
+		return nil
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	return dbSetBookmark(bc.atxi.Db, internalTxBookmarkKey, n+1)
+}
+
+// internalTxsByBlock sorts internal transactions newest block first.
+type internalTxsByBlock []*InternalTx
+
+func (s internalTxsByBlock) Len() int           { return len(s) }
+func (s internalTxsByBlock) Less(i, j int) bool { return s[i].BlockNumber > s[j].BlockNumber }
+func (s internalTxsByBlock) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func dbGetBookmark(db ethdb.Database, key []byte) uint64 {
+	v, err := db.Get(key)
+	if err != nil || len(v) != 8 {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(v)
+}
+
+func dbSetBookmark(db ethdb.Database, key []byte, i uint64) error {
+	bn := make([]byte, 8)
+	binary.LittleEndian.PutUint64(bn, i)
+	return db.Put(key, bn)
+}
+
+// BuildInternalTxIndex indexes the internal transactions of the canonical blocks
+// in [startIndex, stopIndex] by re-executing them with a TransferTracer. This
+// requires the state of every parent block, ie. an archive node. If startIndex
+// is math.MaxUint64 indexing resumes from where the last build stopped, a
+// stopIndex of 0 or math.MaxUint64 indexes up to the current head.
+func BuildInternalTxIndex(bc *BlockChain, chainDB, indexDB ethdb.Database, startIndex, stopIndex uint64) error {
+	if startIndex == math.MaxUint64 {
+		startIndex = dbGetBookmark(indexDB, internalTxBookmarkKey)
+	}
+	if stopIndex == 0 || stopIndex == math.MaxUint64 {
+		stopIndex = bc.CurrentBlock().NumberU64()
+	}
+	if stopIndex < startIndex {
+		return fmt.Errorf("start must be prior to (smaller than) or equal to stop, got start=%d stop=%d", startIndex, stopIndex)
+	}
+	// sigc is a single-val channel for listening to program interrupt
+	var sigc = make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+
+	var (
+		processor = NewStateProcessor(bc.Config(), bc)
+		tracer    = vm.NewTransferTracer()
+		stateDB   = state.NewDatabase(chainDB) // Shared to reuse the tries of consecutive blocks
+		batch     = indexDB.NewBatch()
+		start     = time.Now()
+		txs, itxs int
+	)
+	flush := func(next uint64) error {
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch = indexDB.NewBatch()
+		return dbSetBookmark(indexDB, internalTxBookmarkKey, next)
+	}
+	glog.D(logger.Error).Infoln("Internal transaction indexing (itxi) start:", startIndex, "stop:", stopIndex)
+
+	for n := startIndex; n <= stopIndex; n++ {
+		block := bc.GetBlockByNumber(n)
+		if block == nil {
+			return fmt.Errorf("block %d is nil", n)
+		}
+		blockTxs, blockItxs, err := indexBlockInternalTxs(batch, bc, processor, tracer, stateDB, block)
+		if err != nil {
+			return err
+		}
+		txs += blockTxs
+		itxs += blockItxs
+		if (n+1-startIndex)%itxiFlushBlocks == 0 || n == stopIndex {
+			if err := flush(n + 1); err != nil {
+				return err
+			}
+			glog.D(logger.Error).Infof("itxi-build: block %d / %d txs: %d internal txs: %d took: %v", n, stopIndex, txs, itxs, time.Since(start).Round(time.Millisecond))
+
+			// Listen for interrupts, nonblocking
+			select {
+			case s := <-sigc:
+				glog.D(logger.Info).Warnln("itxi build", "got interrupt:", s, "quitting")
+				return nil
+			default:
+			}
+		}
+	}
+	glog.D(logger.Error).Infof("Finished itxi-build in %v: %d blocks, %d txs, %d internal txs", time.Since(start).Round(time.Second), stopIndex-startIndex+1, txs, itxs)
+	return nil
+}
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/state"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/core/vm"
+	"github.com/openether/ethcore/crypto"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/event"
+)
+
+// Tests that the value transfers of contracts are traced, leaving out those of
+// failed calls, and indexed for both accounts involved.
+func TestInternalTxIndex(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	var (
+		contract = common.BytesToAddress([]byte{0xaa})
+		receiver = common.BytesToAddress([]byte{0xbb})
+		thrower  = common.BytesToAddress([]byte{0xcc})
+	)
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	statedb.AddBalance(from, big.NewInt(1e18))
+	statedb.AddBalance(contract, big.NewInt(1000))
+	// Send 100 wei to the receiver, then 50 wei to the thrower, which fails
+	statedb.SetCode(contract, common.Hex2Bytes(
+		"6000600060006000606460bb612710f150"+
+			"6000600060006000603260cc612710f150"+
+			"00"))
+	statedb.SetCode(thrower, []byte{0xfe})
+
+	tx, err := types.NewTransaction(0, contract, new(big.Int), big.NewInt(200000), big.NewInt(1), nil).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &types.Header{Number: big.NewInt(1), GasLimit: big.NewInt(1000000), Difficulty: big.NewInt(1)}
+	block := types.NewBlock(header, []*types.Transaction{tx}, nil, nil)
+
+	var transfers []vm.Transfer
+	tracer := vm.NewTransferTracer()
+	processor := NewStateProcessor(DefaultConfigMainnet.ChainConfig, nil)
+	_, _, _, err = processor.ProcessTraced(block, statedb, tracer, func(i int, tx *types.Transaction, receipt *types.Receipt) error {
+		transfers = tracer.Finish(receipt.Status == types.TxSuccess)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	if balance := statedb.GetBalance(contract); balance.Cmp(big.NewInt(900)) != 0 {
+		t.Fatalf("contract balance mismatch: have %v, want 900", balance)
+	}
+	if len(transfers) != 1 {
+		t.Fatalf("transfer count mismatch: have %d, want 1: %+v", len(transfers), transfers)
+	}
+	if tr := transfers[0]; tr.From != contract || tr.To != receiver || tr.Value.Cmp(big.NewInt(100)) != 0 || tr.Op != vm.CALL || tr.Depth != 1 {
+		t.Errorf("transfer mismatch: %+v", tr)
+	}
+
+	dir, err := ioutil.TempDir("", "itxi-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	indexDb, _ := ethdb.NewLDBDatabase(dir, 0, 0)
+	defer indexDb.Close()
+
+	batch := indexDb.NewBatch()
+	if _, err := putInternalTxsToBatch(batch, 1, tx.Hash(), transfers); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	received, err := GetInternalTxs(indexDb, receiver, 0, 0, 'b')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0].Direction != 't' || received[0].Counterparty != contract || received[0].TxHash != tx.Hash() || received[0].Value.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("received transfers mismatch: %+v", received)
+	}
+	if sent, _ := GetInternalTxs(indexDb, contract, 0, 0, 't'); len(sent) != 0 {
+		t.Errorf("sent transfer returned as received: %+v", sent)
+	}
+	if sent, _ := GetInternalTxs(indexDb, contract, 2, 0, 'f'); len(sent) != 0 {
+		t.Errorf("transfer returned outside block range: %+v", sent)
+	}
+}
+
+// Tests that the internal transaction index follows the canonical chain as blocks
+// are imported, reorganised and rewound.
+func TestInternalTxIndexMaintenance(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	receiver := common.BytesToAddress([]byte{0xbb})
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{from, big.NewInt(1e18)})
+	config := MakeChainConfig()
+	bc, err := NewBlockChain(db, config, new(event.TypeMux))
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "itxi-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	indexDb, _ := ethdb.NewLDBDatabase(dir, 0, 0)
+	defer indexDb.Close()
+	bc.SetAtxi(&AtxiT{Db: indexDb})
+	dbSetBookmark(indexDb, internalTxBookmarkKey, 1)
+
+	// The contract forwards the value it receives to the receiver, the CALL
+	// being its last instruction
+	contract := crypto.CreateAddress(from, 0)
+	code := common.Hex2Bytes("6010600c60003960106000f3" + "600060006000600034" + "60bb612710f1")
+	call := func(value int64) func(int, *BlockGen) {
+		return func(i int, block *BlockGen) {
+			tx, _ := types.NewTransaction(block.TxNonce(from), contract, big.NewInt(value), big.NewInt(100000), big.NewInt(1), nil).SignECDSA(key)
+			block.AddTx(tx)
+		}
+	}
+	main, _ := GenerateChain(config, genesis, db, 2, func(i int, block *BlockGen) {
+		if i == 0 {
+			tx, _ := types.NewContractCreation(0, new(big.Int), big.NewInt(100000), big.NewInt(1), code).SignECDSA(key)
+			block.AddTx(tx)
+		} else {
+			call(100)(i, block)
+		}
+	})
+	side, _ := GenerateChain(config, main[0], db, 2, func(i int, block *BlockGen) {
+		call(int64(7+2*i))(i, block)
+	})
+	write := func(blocks ...*types.Block) {
+		for _, block := range blocks {
+			if _, err := bc.WriteBlock(block); err != nil {
+				t.Fatalf("failed to write block #%d: %v", block.NumberU64(), err)
+			}
+		}
+	}
+	check := func(context string, progress uint64, values ...int64) {
+		if have := InternalTxIndexProgress(indexDb); have != progress {
+			t.Errorf("%s: progress mismatch: have %d, want %d", context, have, progress)
+		}
+		received, err := GetInternalTxs(indexDb, receiver, 0, 0, 't')
+		if err != nil {
+			t.Fatalf("%s: %v", context, err)
+		}
+		if len(received) != len(values) {
+			t.Fatalf("%s: received transfers mismatch: have %d, want %d", context, len(received), len(values))
+		}
+		for i, tx := range received {
+			if tx.Counterparty != contract || tx.Value.Int64() != values[i] {
+				t.Errorf("%s: transfer %d mismatch: have %x %v, want %x %d", context, i, tx.Counterparty, tx.Value, contract, values[i])
+			}
+		}
+	}
+	write(main...)
+	check("import", 3, 100)
+
+	write(side...)
+	check("reorg", 4, 9, 7)
+
+	if err := bc.SetHead(2); err != nil {
+		t.Fatalf("failed to set head: %v", err)
+	}
+	check("rewind", 3, 7)
+
+	write(side[1])
+	check("reimport", 4, 9, 7)
+}
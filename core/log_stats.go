@@ -0,0 +1,124 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/ethdb"
+	ldbutil "github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Log statistics are kept in the index database as the number of logs emitted in
+// every block, per emitting address, per first topic (the event signature) and
+// per address and first topic. Block numbers are encoded big endian at the end of
+// the keys, so the counters of a block range are adjacent.
+var (
+	logStatsAddressPrefix = []byte("lsa-") // lsa-<address><blockNumber> -> count
+	logStatsTopicPrefix   = []byte("lst-") // lst-<topic><blockNumber> -> count
+	logStatsPairPrefix    = []byte("lsp-") // lsp-<address><topic><blockNumber> -> count
+)
+
+// logStatsKeyPrefix returns the key prefix of the counters of the given address,
+// topic or both, nil if neither is given.
+func logStatsKeyPrefix(address *common.Address, topic *common.Hash) []byte {
+	var prefix []byte
+	switch {
+	case address != nil && topic != nil:
+		prefix = append(append(append(prefix, logStatsPairPrefix...), address.Bytes()...), topic.Bytes()...)
+	case address != nil:
+		prefix = append(append(prefix, logStatsAddressPrefix...), address.Bytes()...)
+	case topic != nil:
+		prefix = append(append(prefix, logStatsTopicPrefix...), topic.Bytes()...)
+	}
+	return prefix
+}
+
+func logStatsKey(prefix []byte, blockNumber uint64) []byte {
+	key := make([]byte, len(prefix)+8)
+	copy(key, prefix)
+	binary.BigEndian.PutUint64(key[len(prefix):], blockNumber)
+	return key
+}
+
+// blockLogStats counts the logs of a block's receipts, keyed by counter key.
+func blockLogStats(blockNumber uint64, receipts types.Receipts) map[string]uint32 {
+	counts := make(map[string]uint32)
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			address := log.Address
+			counts[string(logStatsKey(logStatsKeyPrefix(&address, nil), blockNumber))]++
+			if len(log.Topics) > 0 {
+				topic := log.Topics[0]
+				counts[string(logStatsKey(logStatsKeyPrefix(nil, &topic), blockNumber))]++
+				counts[string(logStatsKey(logStatsKeyPrefix(&address, &topic), blockNumber))]++
+			}
+		}
+	}
+	return counts
+}
+
+// putBlockLogStatsToBatch puts the log counters of a block to a db Batch. The
+// counters are overwritten rather than incremented, so indexing a block again is
+// harmless.
+func putBlockLogStatsToBatch(batch ethdb.Batch, blockNumber uint64, receipts types.Receipts) error {
+	for key, count := range blockLogStats(blockNumber, receipts) {
+		value := make([]byte, 4)
+		binary.BigEndian.PutUint32(value, count)
+		if err := batch.Put([]byte(key), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteBlockLogStats writes the log counters of a block.
+func WriteBlockLogStats(indexDb ethdb.Database, blockNumber uint64, receipts types.Receipts) error {
+	batch := indexDb.NewBatch()
+	if err := putBlockLogStatsToBatch(batch, blockNumber, receipts); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// RmBlockLogStats removes the log counters of a block, eg. in the case of the
+// block being reorganised out of the canonical chain.
+func RmBlockLogStats(indexDb ethdb.Database, blockNumber uint64, receipts types.Receipts) error {
+	for key := range blockLogStats(blockNumber, receipts) {
+		if err := indexDb.Delete([]byte(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetLogCount returns the number of logs emitted by the given address, with the
+// given first topic or both, in the blocks [fromBlock, toBlock].
+func GetLogCount(indexDb ethdb.Database, address *common.Address, topic *common.Hash, fromBlock, toBlock uint64) (uint64, error) {
+	prefix := logStatsKeyPrefix(address, topic)
+	if prefix == nil {
+		return 0, errors.New("log count requires an address, a topic or both")
+	}
+	ldb, ok := indexDb.(*ethdb.LDBDatabase)
+	if !ok {
+		return 0, errors.New("internal interface error; could not cast index db to level db")
+	}
+	span := &ldbutil.Range{Start: logStatsKey(prefix, fromBlock)}
+	if toBlock < math.MaxUint64 {
+		span.Limit = logStatsKey(prefix, toBlock+1)
+	} else {
+		span.Limit = ethdb.NewBytesPrefix(prefix).Limit
+	}
+	it := ldb.NewIteratorRange(span)
+	defer it.Release()
+
+	var count uint64
+	for it.Next() {
+		if value := it.Value(); len(value) == 4 {
+			count += uint64(binary.BigEndian.Uint32(value))
+		}
+	}
+	return count, it.Error()
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/core/vm"
+	"github.com/openether/ethcore/ethdb"
+)
+
+func TestLogStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-stats-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, _ := ethdb.NewLDBDatabase(dir, 0, 0)
+	defer db.Close()
+
+	var (
+		addr1, addr2   = common.Address{0x01}, common.Address{0x02}
+		topic1, topic2 = common.Hash{0x01}, common.Hash{0x02}
+	)
+	receipts := func(logs ...*vm.Log) types.Receipts {
+		return types.Receipts{&types.Receipt{Logs: logs}}
+	}
+	blocks := []types.Receipts{
+		receipts(&vm.Log{Address: addr1, Topics: []common.Hash{topic1}}, &vm.Log{Address: addr1, Topics: []common.Hash{topic1, topic2}}),
+		receipts(&vm.Log{Address: addr1, Topics: []common.Hash{topic2}}, &vm.Log{Address: addr2, Topics: []common.Hash{topic1}}),
+		receipts(&vm.Log{Address: addr2}),
+	}
+	for i, r := range blocks {
+		if err := WriteBlockLogStats(db, uint64(i+1), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		address  *common.Address
+		topic    *common.Hash
+		from, to uint64
+		want     uint64
+	}{
+		{&addr1, nil, 0, math.MaxUint64, 3},
+		{&addr2, nil, 0, math.MaxUint64, 2},
+		{nil, &topic1, 0, math.MaxUint64, 3},
+		{nil, &topic2, 0, math.MaxUint64, 1}, // Only first topics are counted
+		{&addr1, &topic1, 0, math.MaxUint64, 2},
+		{&addr1, &topic1, 2, 3, 0},
+		{&addr2, nil, 1, 2, 1},
+		{&addr2, nil, 3, 3, 1},
+	}
+	for i, tt := range tests {
+		count, err := GetLogCount(db, tt.address, tt.topic, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if count != tt.want {
+			t.Errorf("test %d: count mismatch: have %d, want %d", i, count, tt.want)
+		}
+	}
+	if _, err := GetLogCount(db, nil, nil, 0, math.MaxUint64); err == nil {
+		t.Errorf("counted logs without address or topic")
+	}
+
+	// Indexing a block again mustn't double count, removing it drops its counters
+	if err := WriteBlockLogStats(db, 1, blocks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := GetLogCount(db, &addr1, nil, 0, math.MaxUint64); count != 3 {
+		t.Errorf("count after reindexing mismatch: have %d, want 3", count)
+	}
+	if err := RmBlockLogStats(db, 1, blocks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := GetLogCount(db, &addr1, nil, 0, math.MaxUint64); count != 1 {
+		t.Errorf("count after removal mismatch: have %d, want 1", count)
+	}
+}
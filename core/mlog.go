@@ -49,6 +49,7 @@ A STATUS of NONE means it was written _without_ any abnormal chain event, such a
 		{Owner: "BLOCK", Key: "UNCLES", Value: "INT"},
 		{Owner: "BLOCK", Key: "RECEIVED_AT", Value: "BIGINT"},
 		{Owner: "BLOCK", Key: "DIFF_PARENT_TIME", Value: "BIGINT"},
+		{Owner: "BLOCK", Key: "AUTHOR", Value: "STRING"},
 	},
 }
 
@@ -187,6 +187,9 @@ Loop:
 	} else {
 		receipt.Status = types.TxSuccess
 	}
+	if config.IsEIP658(header.Number) {
+		receipt.SetStatusPostState()
+	}
 	if MessageCreatesContract(tx) {
 		receipt.ContractAddress = crypto.CreateAddress(from, tx.Nonce())
 	}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+)
+
+// ReorgDepth returns the number of blocks of the chain ending in prev that are not
+// ancestors of head, i.e. how many blocks were dropped when moving the chain head from
+// prev to head. It is zero when head extends prev. Headers are resolved through
+// getHeader; if an ancestor can't be found the depth established so far is returned.
+func ReorgDepth(getHeader func(common.Hash) *types.Header, prev, head *types.Header) uint64 {
+	var depth uint64
+	for prev.Number.Cmp(head.Number) > 0 {
+		depth++
+		if prev = getHeader(prev.ParentHash); prev == nil {
+			return depth
+		}
+	}
+	for head.Number.Cmp(prev.Number) > 0 {
+		if head = getHeader(head.ParentHash); head == nil {
+			return depth
+		}
+	}
+	for prev.Hash() != head.Hash() {
+		depth++
+		if prev = getHeader(prev.ParentHash); prev == nil {
+			return depth
+		}
+		if head = getHeader(head.ParentHash); head == nil {
+			return depth
+		}
+	}
+	return depth
+}
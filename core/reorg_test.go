@@ -0,0 +1,57 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+)
+
+func TestReorgDepth(t *testing.T) {
+	headers := make(map[common.Hash]*types.Header)
+	extend := func(parent *types.Header, n int, extra byte) []*types.Header {
+		chain := make([]*types.Header, n)
+		for i := range chain {
+			h := &types.Header{
+				ParentHash: parent.Hash(),
+				Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+				Extra:      []byte{extra},
+			}
+			headers[h.Hash()] = h
+			chain[i], parent = h, h
+		}
+		return chain
+	}
+	genesis := &types.Header{Number: big.NewInt(0)}
+	headers[genesis.Hash()] = genesis
+	getHeader := func(hash common.Hash) *types.Header { return headers[hash] }
+
+	// main: genesis -> 1 .. 6, side: 3 -> 4' .. 5'
+	main := extend(genesis, 6, 0)
+	side := extend(main[2], 2, 1)
+
+	tests := []struct {
+		prev, head *types.Header
+		want       uint64
+	}{
+		{main[3], main[4], 0},
+		{main[3], main[3], 0},
+		{main[1], main[5], 0},
+		{main[5], side[1], 3},
+		{main[4], side[0], 2},
+		{side[1], main[5], 2},
+		{main[5], main[2], 3},
+	}
+	for i, tt := range tests {
+		if got := ReorgDepth(getHeader, tt.prev, tt.head); got != tt.want {
+			t.Errorf("test %d: reorg depth mismatch: got %d, want %d", i, got, tt.want)
+		}
+	}
+
+	// Unknown ancestors stop the walk.
+	orphan := &types.Header{ParentHash: common.Hash{0x01}, Number: big.NewInt(7)}
+	if got := ReorgDepth(getHeader, orphan, main[5]); got != 1 {
+		t.Errorf("orphan reorg depth mismatch: got %d, want 1", got)
+	}
+}
@@ -46,6 +46,7 @@ type Trie interface {
 	CommitTo(trie.DatabaseWriter) (common.Hash, error)
 	Hash() common.Hash
 	NodeIterator(startKey []byte) trie.NodeIterator
+	Prove(key []byte, fromLevel uint, proofDb trie.DatabaseWriter) error
 	GetKey([]byte) []byte // TODO(fjl): remove this when SecureTrie is removed
 }
 
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"compress/zlib"
+	"context"
 	"encoding/json"
 	"io"
 	"sort"
@@ -41,6 +42,13 @@ func lookupAddress(addr common.Address, addresses []common.Address) bool {
 }
 
 func (self *StateDB) RawDump(addresses []common.Address) Dump {
+	dump, _ := self.RawDumpContext(context.Background(), addresses)
+	return dump
+}
+
+// RawDumpContext is like RawDump, aborting the iteration of the state with the
+// error of the context once it is done.
+func (self *StateDB) RawDumpContext(ctx context.Context, addresses []common.Address) (Dump, error) {
 	dump := Dump{
 		Root:     fmt.Sprintf("%x", self.trie.Hash()),
 		Accounts: make(map[string]DumpAccount),
@@ -48,6 +56,9 @@ func (self *StateDB) RawDump(addresses []common.Address) Dump {
 
 	it := trie.NewIterator(self.trie.NodeIterator(nil))
 	for it.Next() {
+		if err := ctx.Err(); err != nil {
+			return Dump{}, err
+		}
 		addr := self.trie.GetKey(it.Key)
 		addrA := common.BytesToAddress(addr)
 
@@ -75,11 +86,14 @@ func (self *StateDB) RawDump(addresses []common.Address) Dump {
 		}
 		storageIt := trie.NewIterator(obj.getTrie(self.db).NodeIterator(nil))
 		for storageIt.Next() {
+			if err := ctx.Err(); err != nil {
+				return Dump{}, err
+			}
 			account.Storage[common.Bytes2Hex(self.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(storageIt.Value)
 		}
 		dump.Accounts[common.Bytes2Hex(addr)] = account
 	}
-	return dump
+	return dump, nil
 }
 
 const ZipperBlockLength = 1 * 1024 * 1024
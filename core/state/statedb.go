@@ -241,6 +241,46 @@ func (self *StateDB) GetState(a common.Address, b common.Hash) common.Hash {
 	return common.Hash{}
 }
 
+// GetStorageRoot returns the root of the storage trie of the account at addr,
+// or the zero hash if the account doesn't exist.
+func (self *StateDB) GetStorageRoot(addr common.Address) common.Hash {
+	stateObject := self.getStateObject(addr)
+	if stateObject == nil {
+		return common.Hash{}
+	}
+	return stateObject.data.Root
+}
+
+// GetProof returns the merkle proof of the account at addr in the account trie,
+// ordered from the root node down. If the account doesn't exist the proof shows
+// its absence.
+func (self *StateDB) GetProof(addr common.Address) ([][]byte, error) {
+	var proof proofList
+	err := self.trie.Prove(addr[:], 0, &proof)
+	return proof, err
+}
+
+// GetStorageProof returns the merkle proof of the storage slot key of the
+// account at addr in its storage trie, ordered from the root node down. The
+// proof is empty if the account doesn't exist.
+func (self *StateDB) GetStorageProof(addr common.Address, key common.Hash) ([][]byte, error) {
+	stateObject := self.getStateObject(addr)
+	if stateObject == nil {
+		return nil, nil
+	}
+	var proof proofList
+	err := stateObject.getTrie(self.db).Prove(key[:], 0, &proof)
+	return proof, err
+}
+
+// proofList collects the nodes of a merkle proof in the order they are written.
+type proofList [][]byte
+
+func (l *proofList) Put(key []byte, value []byte) error {
+	*l = append(*l, common.CopyBytes(value))
+	return nil
+}
+
 func (self *StateDB) HasSuicided(addr common.Address) bool {
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
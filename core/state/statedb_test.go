@@ -30,7 +30,10 @@ import (
 
 	"github.com/ethereumclassic/go-ethereum/common"
 	"github.com/ethereumclassic/go-ethereum/core/vm"
+	"github.com/ethereumclassic/go-ethereum/crypto"
 	"github.com/ethereumclassic/go-ethereum/ethdb"
+	"github.com/ethereumclassic/go-ethereum/rlp"
+	"github.com/ethereumclassic/go-ethereum/trie"
 	"gopkg.in/check.v1"
 )
 
@@ -167,6 +170,81 @@ func TestCopy(t *testing.T) {
 	}
 }
 
+// Tests that account and storage proofs verify against the state and storage
+// roots, and prove the absence of accounts and slots that don't exist.
+func TestProofs(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+
+	addr := common.BytesToAddress([]byte{0x01})
+	slot := common.BytesToHash([]byte{0x02})
+	for i := byte(0); i < 16; i++ {
+		other := common.BytesToAddress([]byte{0x10 + i})
+		state.AddBalance(other, big.NewInt(int64(i)))
+		state.SetState(addr, common.BytesToHash([]byte{0x10 + i}), common.BytesToHash([]byte{i + 1}))
+	}
+	state.AddBalance(addr, big.NewInt(42))
+	state.SetState(addr, slot, common.BytesToHash([]byte{0x03}))
+	root, err := state.CommitTo(db, false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	state, _ = New(root, NewDatabase(db))
+
+	// The account proof yields the account's trie entry
+	proof, err := state.GetProof(addr)
+	if err != nil {
+		t.Fatalf("failed to prove account: %v", err)
+	}
+	value, err, _ := trie.VerifyProof(root, crypto.Keccak256(addr[:]), proofDb(proof))
+	if err != nil {
+		t.Fatalf("account proof invalid: %v", err)
+	}
+	var account Account
+	if err := rlp.DecodeBytes(value, &account); err != nil {
+		t.Fatalf("can't decode proven account: %v", err)
+	}
+	if account.Balance.Int64() != 42 || account.Root != state.GetStorageRoot(addr) {
+		t.Errorf("proven account mismatch: %+v", account)
+	}
+	// The storage proof yields the slot's value
+	proof, err = state.GetStorageProof(addr, slot)
+	if err != nil {
+		t.Fatalf("failed to prove slot: %v", err)
+	}
+	value, err, _ = trie.VerifyProof(account.Root, crypto.Keccak256(slot[:]), proofDb(proof))
+	if err != nil {
+		t.Fatalf("storage proof invalid: %v", err)
+	}
+	if want, _ := rlp.EncodeToBytes([]byte{0x03}); !bytes.Equal(value, want) {
+		t.Errorf("proven slot mismatch: have %x, want %x", value, want)
+	}
+	// Absent accounts and slots are proven absent
+	missing := common.BytesToAddress([]byte{0xff})
+	if proof, err = state.GetProof(missing); err != nil {
+		t.Fatalf("failed to prove missing account: %v", err)
+	}
+	if value, err, _ = trie.VerifyProof(root, crypto.Keccak256(missing[:]), proofDb(proof)); err != nil || value != nil {
+		t.Errorf("missing account proof: have value %x, err %v", value, err)
+	}
+	missingSlot := common.BytesToHash([]byte{0xff})
+	if proof, err = state.GetStorageProof(addr, missingSlot); err != nil {
+		t.Fatalf("failed to prove missing slot: %v", err)
+	}
+	if value, err, _ = trie.VerifyProof(account.Root, crypto.Keccak256(missingSlot[:]), proofDb(proof)); err != nil || value != nil {
+		t.Errorf("missing slot proof: have value %x, err %v", value, err)
+	}
+}
+
+// proofDb puts proof nodes into a database keyed by their hash for VerifyProof.
+func proofDb(proof [][]byte) *ethdb.MemDatabase {
+	db, _ := ethdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
 func TestSnapshotRandom(t *testing.T) {
 	config := &quick.Config{MaxCount: 1000}
 	err := quick.Check((*snapshotTest).run, config)
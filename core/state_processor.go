@@ -9,6 +9,7 @@ import (
 	"github.com/openether/ethcore/core/types"
 	"github.com/openether/ethcore/core/vm"
 	"github.com/openether/ethcore/crypto"
+	"github.com/openether/ethcore/internal/tracing"
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
 )
@@ -48,6 +49,38 @@ func NewStateProcessor(config *ChainConfig, bc *BlockChain) *StateProcessor {
 // returns the amount of gas that was used in the process. If any of the
 // transactions failed to execute due to insufficient gas it will return an error.
 func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (types.Receipts, vm.Logs, *big.Int, error) {
+	return p.process(block, statedb, nil, nil)
+}
+
+// TxHook is called by ProcessHooked after each transaction of a block has been
+// applied, with the transaction's index and receipt. Returning an error aborts
+// the processing of the block.
+type TxHook func(index int, tx *types.Transaction, receipt *types.Receipt) error
+
+// ProcessHooked is like Process, calling hook after every applied transaction so
+// callers can inspect the intermediate state of the block.
+func (p *StateProcessor) ProcessHooked(block *types.Block, statedb *state.StateDB, hook TxHook) (types.Receipts, vm.Logs, *big.Int, error) {
+	return p.process(block, statedb, hook, nil)
+}
+
+// ProcessTraced is like ProcessHooked, additionally notifying tracer about every
+// instruction executed by the transactions. Tracing is not supported when the
+// transactions are executed by SputnikVM.
+func (p *StateProcessor) ProcessTraced(block *types.Block, statedb *state.StateDB, tracer vm.Tracer, hook TxHook) (types.Receipts, vm.Logs, *big.Int, error) {
+	return p.process(block, statedb, hook, tracer)
+}
+
+func (p *StateProcessor) process(block *types.Block, statedb *state.StateDB, hook TxHook, tracer vm.Tracer) (types.Receipts, vm.Logs, *big.Int, error) {
+	span := tracing.StartFor(block.Hash(), "core.processBlock")
+	span.SetAttribute("number", block.NumberU64())
+	span.SetAttribute("txs", len(block.Transactions()))
+
+	receipts, logs, usedGas, err := p.applyBlock(block, statedb, hook, tracer)
+	span.End(err)
+	return receipts, logs, usedGas, err
+}
+
+func (p *StateProcessor) applyBlock(block *types.Block, statedb *state.StateDB, hook TxHook, tracer vm.Tracer) (types.Receipts, vm.Logs, *big.Int, error) {
 	var (
 		receipts     types.Receipts
 		totalUsedGas = big.NewInt(0)
@@ -68,21 +101,25 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (ty
 			}
 		}
 		statedb.StartRecord(tx.Hash(), block.Hash(), i)
+		var (
+			receipt *types.Receipt
+			logs    vm.Logs
+		)
 		if UseSputnikVM != "true" {
-			receipt, logs, _, err := ApplyTransaction(p.config, p.bc, gp, statedb, header, tx, totalUsedGas)
-			if err != nil {
-				return nil, nil, totalUsedGas, err
-			}
-			receipts = append(receipts, receipt)
-			allLogs = append(allLogs, logs...)
-			continue
+			receipt, logs, _, err = applyTransaction(p.config, p.bc, gp, statedb, header, tx, totalUsedGas, tracer)
+		} else {
+			receipt, logs, _, err = ApplyMultiVmTransaction(p.config, p.bc, gp, statedb, header, tx, totalUsedGas)
 		}
-		receipt, logs, _, err := ApplyMultiVmTransaction(p.config, p.bc, gp, statedb, header, tx, totalUsedGas)
 		if err != nil {
 			return nil, nil, totalUsedGas, err
 		}
 		receipts = append(receipts, receipt)
 		allLogs = append(allLogs, logs...)
+		if hook != nil {
+			if err := hook(i, tx, receipt); err != nil {
+				return nil, nil, totalUsedGas, err
+			}
+		}
 	}
 	AccumulateRewards(p.config, statedb, header, block.Uncles())
 
@@ -95,9 +132,17 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (ty
 // ApplyTransactions returns the generated receipts and vm logs during the
 // execution of the state transition phase.
 func ApplyTransaction(config *ChainConfig, bc *BlockChain, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int) (*types.Receipt, vm.Logs, *big.Int, error) {
+	return applyTransaction(config, bc, gp, statedb, header, tx, usedGas, nil)
+}
+
+func applyTransaction(config *ChainConfig, bc *BlockChain, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int, tracer vm.Tracer) (*types.Receipt, vm.Logs, *big.Int, error) {
 	tx.SetSigner(config.GetSigner(header.Number))
 
-	_, gas, failed, err := ApplyMessage(NewEnv(statedb, config, bc, tx, header), tx, gp)
+	env := NewEnv(statedb, config, bc, tx, header)
+	if tracer != nil {
+		env.SetTracer(tracer)
+	}
+	_, gas, failed, err := ApplyMessage(env, tx, gp)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -120,6 +165,9 @@ func ApplyTransaction(config *ChainConfig, bc *BlockChain, gp *GasPool, statedb
 	} else {
 		receipt.Status = types.TxSuccess
 	}
+	if config.IsEIP658(header.Number) {
+		receipt.SetStatusPostState()
+	}
 
 	glog.V(logger.Debug).Infoln(receipt)
 
@@ -131,6 +179,17 @@ func ApplyTransaction(config *ChainConfig, bc *BlockChain, gp *GasPool, statedb
 // and rewards for included uncles. The coinbase of each uncle block is
 // also rewarded.
 func AccumulateRewards(config *ChainConfig, statedb *state.StateDB, header *types.Header, uncles []*types.Header) {
+	winner, uncleRewards := BlockRewards(config, header, uncles)
+	for i, uncle := range uncles {
+		statedb.AddBalance(uncle.Coinbase, uncleRewards[i]) // $$
+	}
+	statedb.AddBalance(header.Coinbase, winner) // $$
+}
+
+// BlockRewards returns the mining reward of the given block's coinbase, made of
+// the static block reward and the rewards for included uncles, along with the
+// reward of each uncle's coinbase.
+func BlockRewards(config *ChainConfig, header *types.Header, uncles []*types.Header) (winner *big.Int, uncleRewards []*big.Int) {
 
 	// An uncle is a block that would be considered an orphan because its not on the longest chain (it's an alternative block at the same height as your parent).
 	// https://www.reddit.com/r/ethereum/comments/3c9jbf/wtf_are_uncles_and_why_do_they_matter/
@@ -141,51 +200,48 @@ func AccumulateRewards(config *ChainConfig, statedb *state.StateDB, header *type
 
 	// Since ECIP1017 impacts "Era 1" idempotently and with constant 0-block based eras,
 	// we don't care about where the block/fork implementing it is.
+	uncleRewards = make([]*big.Int, len(uncles))
+
 	feat, _, configured := config.HasFeature("reward")
 	if !configured {
-		reward := new(big.Int).Set(MaximumBlockReward)
-		r := new(big.Int)
+		winner = new(big.Int).Set(MaximumBlockReward)
 
-		for _, uncle := range uncles {
+		for i, uncle := range uncles {
+			r := new(big.Int)
 			r.Add(uncle.Number, big8)    // 2,534,998 + 8              = 2,535,006
 			r.Sub(r, header.Number)      // 2,535,006 - 2,534,999        = 7
 			r.Mul(r, MaximumBlockReward) // 7 * 5e+18               = 35e+18
 			r.Div(r, big8)               // 35e+18 / 8                            = 7/8 * 5e+18
+			uncleRewards[i] = r
 
-			statedb.AddBalance(uncle.Coinbase, r) // $$
-
-			r.Div(MaximumBlockReward, big32) // 5e+18 / 32
-			reward.Add(reward, r)            // 5e+18 + (1/32*5e+18)
-		}
-		statedb.AddBalance(header.Coinbase, reward) //  $$ => 5e+18 + (1/32*5e+18)
-	} else {
-		// Check that configuration specifies ECIP1017.
-		val, ok := feat.GetString("type")
-		if !ok || val != "ecip1017" {
-			panic(ErrConfiguration)
-		}
-
-		// Ensure value 'era' is configured.
-		eraLen, ok := feat.GetBigInt("era")
-		if !ok || eraLen.Cmp(big.NewInt(0)) <= 0 {
-			panic(ErrConfiguration)
+			winner.Add(winner, new(big.Int).Div(MaximumBlockReward, big32)) // 5e+18 + (1/32*5e+18)
 		}
+		return winner, uncleRewards
+	}
+	// Check that configuration specifies ECIP1017.
+	val, ok := feat.GetString("type")
+	if !ok || val != "ecip1017" {
+		panic(ErrConfiguration)
+	}
 
-		era := GetBlockEra(header.Number, eraLen)
+	// Ensure value 'era' is configured.
+	eraLen, ok := feat.GetBigInt("era")
+	if !ok || eraLen.Cmp(big.NewInt(0)) <= 0 {
+		panic(ErrConfiguration)
+	}
 
-		wr := GetBlockWinnerRewardByEra(era) // wr "winner reward". 5, 4, 3.2, 2.56, ...
+	era := GetBlockEra(header.Number, eraLen)
 
-		wurs := GetBlockWinnerRewardForUnclesByEra(era, uncles) // wurs "winner uncle rewards"
-		wr.Add(wr, wurs)
+	winner = GetBlockWinnerRewardByEra(era) // wr "winner reward". 5, 4, 3.2, 2.56, ...
 
-		statedb.AddBalance(header.Coinbase, wr) // $$
+	wurs := GetBlockWinnerRewardForUnclesByEra(era, uncles) // wurs "winner uncle rewards"
+	winner.Add(winner, wurs)
 
-		// Reward uncle miners.
-		for _, uncle := range uncles {
-			ur := GetBlockUncleRewardByEra(era, header, uncle)
-			statedb.AddBalance(uncle.Coinbase, ur) // $$
-		}
+	// Reward uncle miners.
+	for i, uncle := range uncles {
+		uncleRewards[i] = GetBlockUncleRewardByEra(era, header, uncle)
 	}
+	return winner, uncleRewards
 }
 
 // As of "Era 2" (zero-index era 1), uncle miners and winners are rewarded equally for each included block.
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/state"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/crypto"
+	"github.com/openether/ethcore/ethdb"
+)
+
+func TestProcessHooked(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	statedb.AddBalance(from, big.NewInt(1e18))
+
+	var txs []*types.Transaction
+	for i := 0; i < 3; i++ {
+		tx, err := types.NewTransaction(uint64(i), common.Address{byte(i + 1)}, big.NewInt(1000), TxGas, big.NewInt(1), nil).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	header := &types.Header{Number: big.NewInt(1), GasLimit: big.NewInt(1000000), Difficulty: big.NewInt(1)}
+	block := types.NewBlock(header, txs, nil, nil)
+
+	var (
+		indexes []int
+		roots   []common.Hash
+	)
+	processor := NewStateProcessor(DefaultConfigMainnet.ChainConfig, nil)
+	receipts, _, _, err := processor.ProcessHooked(block, statedb, func(i int, tx *types.Transaction, receipt *types.Receipt) error {
+		if tx != txs[i] {
+			t.Errorf("hook %d: transaction mismatch", i)
+		}
+		indexes = append(indexes, i)
+		roots = append(roots, statedb.IntermediateRoot(false))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	if len(indexes) != len(txs) || len(receipts) != len(txs) {
+		t.Fatalf("hook called %d times for %d receipts, want %d", len(indexes), len(receipts), len(txs))
+	}
+	for i := range roots {
+		if indexes[i] != i {
+			t.Errorf("hook %d: index mismatch: got %d", i, indexes[i])
+		}
+		if i > 0 && roots[i] == roots[i-1] {
+			t.Errorf("hook %d: state root unchanged by transaction", i)
+		}
+	}
+
+	// Errors returned by the hook abort processing.
+	statedb, _ = state.New(common.Hash{}, state.NewDatabase(db))
+	statedb.AddBalance(from, big.NewInt(1e18))
+
+	calls := 0
+	_, _, _, err = processor.ProcessHooked(block, statedb, func(int, *types.Transaction, *types.Receipt) error {
+		calls++
+		return ErrConfiguration
+	})
+	if err != ErrConfiguration {
+		t.Errorf("error mismatch: got %v, want %v", err, ErrConfiguration)
+	}
+	if calls != 1 {
+		t.Errorf("hook called %d times after failing, want 1", calls)
+	}
+}
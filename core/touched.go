@@ -0,0 +1,38 @@
+package core
+
+import (
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+)
+
+// TouchedAccounts returns the accounts whose balance or nonce may have been changed
+// by the given block: transaction senders and recipients, created contracts, the
+// block's coinbase and the coinbases of its uncles. Value transfers made by contract
+// code to other accounts are not visible without re-executing the block and are
+// therefore not included. Receipts are optional and only used to resolve created
+// contract addresses.
+func TouchedAccounts(block *types.Block, receipts types.Receipts) []common.Address {
+	seen := make(map[common.Address]bool)
+	var touched []common.Address
+	add := func(addr common.Address) {
+		if !seen[addr] {
+			seen[addr] = true
+			touched = append(touched, addr)
+		}
+	}
+	add(block.Coinbase())
+	for _, uncle := range block.Uncles() {
+		add(uncle.Coinbase)
+	}
+	for i, tx := range block.Transactions() {
+		if from, err := tx.From(); err == nil {
+			add(from)
+		}
+		if to := tx.To(); to != nil {
+			add(*to)
+		} else if i < len(receipts) && !receipts[i].ContractAddress.IsEmpty() {
+			add(receipts[i].ContractAddress)
+		}
+	}
+	return touched
+}
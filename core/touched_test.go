@@ -0,0 +1,52 @@
+package core
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/crypto"
+)
+
+func TestTouchedAccounts(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	var (
+		to       = common.Address{0x01}
+		coinbase = common.Address{0x02}
+		uncle    = common.Address{0x03}
+		created  = common.Address{0x04}
+	)
+	transfer, err := types.NewTransaction(0, to, big.NewInt(1), TxGas, big.NewInt(1), nil).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create, err := types.NewContractCreation(1, big.NewInt(0), big.NewInt(100000), big.NewInt(1), nil).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipts := types.Receipts{
+		types.NewReceipt(nil, big.NewInt(0)),
+		types.NewReceipt(nil, big.NewInt(0)),
+	}
+	receipts[1].ContractAddress = created
+
+	block := types.NewBlock(
+		&types.Header{Number: big.NewInt(1), Coinbase: coinbase},
+		[]*types.Transaction{transfer, create},
+		[]*types.Header{{Number: big.NewInt(0), Coinbase: uncle}, {Number: big.NewInt(0), Coinbase: coinbase}},
+		receipts,
+	)
+	want := []common.Address{coinbase, uncle, from, to, created}
+	if got := TouchedAccounts(block, receipts); !reflect.DeepEqual(got, want) {
+		t.Errorf("touched accounts mismatch:\n got %x\nwant %x", got, want)
+	}
+
+	// Without receipts created contracts can't be resolved.
+	want = []common.Address{coinbase, uncle, from, to}
+	if got := TouchedAccounts(block, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("touched accounts without receipts mismatch:\n got %x\nwant %x", got, want)
+	}
+}
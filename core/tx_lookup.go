@@ -0,0 +1,195 @@
+package core
+
+import (
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/rlp"
+)
+
+// Transaction lookup schemes. A database keeps the scheme it was created with,
+// lookups written by any scheme can be read and unindexed.
+const (
+	TxLookupLegacy = 0 // Transactions stored in full next to their positional metadata
+	TxLookupV1     = 1 // Lookup entries only, transactions are read from the block bodies
+)
+
+const (
+	txUnindexInterval = time.Minute // Interval between passes of the transaction unindexer
+	txUnindexBatch    = 1000        // Number of blocks unindexed between tail updates
+)
+
+var (
+	txLookupVersionKey = []byte("TxLookupVersion") // Scheme of the transaction lookups written
+	txIndexTailKey     = []byte("TxIndexTail")     // Number of the oldest block whose transactions may be indexed
+)
+
+// GetTxLookupVersion returns the scheme transaction lookups are written with.
+func GetTxLookupVersion(db ethdb.Database) uint64 {
+	return dbGetBookmark(db, txLookupVersionKey)
+}
+
+// WriteTxLookupVersion stores the scheme transaction lookups are written with.
+func WriteTxLookupVersion(db ethdb.Database, version uint64) error {
+	return dbSetBookmark(db, txLookupVersionKey, version)
+}
+
+// GetTxIndexTail returns the number of the oldest block whose transactions may
+// still be indexed. Transactions of older blocks were unindexed.
+func GetTxIndexTail(db ethdb.Database) uint64 {
+	return dbGetBookmark(db, txIndexTailKey)
+}
+
+// WriteTxIndexTail stores the number of the oldest block whose transactions may
+// still be indexed.
+func WriteTxIndexTail(db ethdb.Database, number uint64) error {
+	return dbSetBookmark(db, txIndexTailKey, number)
+}
+
+// GetTxLookupEntry retrieves the positional metadata of a transaction stored by
+// WriteTxLookupEntries, nil if none found.
+func GetTxLookupEntry(db ethdb.Database, hash common.Hash) *TxLookupEntry {
+	data, _ := db.Get(append(lookupPrefix, hash.Bytes()...))
+	if len(data) == 0 {
+		return nil
+	}
+	entry := new(TxLookupEntry)
+	if err := rlp.DecodeBytes(data, entry); err != nil {
+		glog.V(logger.Error).Infof("invalid transaction lookup entry RLP for hash %x: %v", hash, err)
+		return nil
+	}
+	return entry
+}
+
+// DeleteTxLookupEntry removes the positional metadata of a transaction stored
+// by WriteTxLookupEntries.
+func DeleteTxLookupEntry(db ethdb.Database, hash common.Hash) {
+	db.Delete(append(lookupPrefix, hash.Bytes()...))
+}
+
+// WriteTxLookups indexes the transactions of a block by hash with the given
+// lookup scheme.
+func WriteTxLookups(db ethdb.Database, version uint64, block *types.Block) error {
+	if version >= TxLookupV1 {
+		return WriteTxLookupEntries(db, block)
+	}
+	return WriteTransactions(db, block)
+}
+
+// UnindexTransactions removes the hash lookups of the transactions and receipts
+// of the canonical blocks in [from, to), whichever scheme they were written
+// with. The blocks and their receipts remain available by block.
+func UnindexTransactions(db ethdb.Database, from, to uint64) {
+	for number := from; number < to; number++ {
+		hash := GetCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			continue
+		}
+		body := GetBody(db, hash)
+		if body == nil {
+			continue
+		}
+		for _, tx := range body.Transactions {
+			DeleteTransaction(db, tx.Hash())
+			DeleteReceipt(db, tx.Hash())
+		}
+	}
+}
+
+// initTxLookupVersion determines the lookup scheme of the chain. Databases
+// holding no blocks beyond the genesis are switched to the latest scheme, the
+// others keep writing theirs so their lookups stay uniform.
+func (bc *BlockChain) initTxLookupVersion() error {
+	bc.txLookupVersion = GetTxLookupVersion(bc.chainDb)
+	if bc.txLookupVersion >= TxLookupV1 {
+		return nil
+	}
+	if bc.CurrentFastBlock().NumberU64() > 0 || bc.CurrentHeader().Number.Sign() > 0 {
+		return nil
+	}
+	bc.txLookupVersion = TxLookupV1
+	return WriteTxLookupVersion(bc.chainDb, bc.txLookupVersion)
+}
+
+// writeTxLookups indexes the transactions of a block, unless the block is older
+// than the lookup retention window.
+func (bc *BlockChain) writeTxLookups(block *types.Block) error {
+	if limit := bc.txLookupLimit; limit > 0 && block.NumberU64()+limit <= bc.CurrentHeader().Number.Uint64() {
+		return nil
+	}
+	return WriteTxLookups(bc.chainDb, bc.txLookupVersion, block)
+}
+
+// SetTxLookupLimit restricts the transaction lookups to the most recent limit
+// blocks and starts unindexing the transactions of older blocks in the
+// background. Lookups already removed are not restored by raising the limit.
+// Zero keeps the lookups of all blocks.
+func (bc *BlockChain) SetTxLookupLimit(limit uint64) {
+	if limit == 0 || bc.txLookupLimit != 0 {
+		return
+	}
+	bc.txLookupLimit = limit
+
+	bc.wg.Add(1)
+	go bc.unindexLoop()
+}
+
+// TxLookupLimit returns the number of recent blocks whose transactions are
+// indexed, zero if all of them are.
+func (bc *BlockChain) TxLookupLimit() uint64 {
+	return bc.txLookupLimit
+}
+
+func (bc *BlockChain) unindexLoop() {
+	defer bc.wg.Done()
+
+	ticker := time.NewTicker(txUnindexInterval)
+	defer ticker.Stop()
+
+	for {
+		bc.unindexTxs()
+		select {
+		case <-bc.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// unindexTxs removes the lookups of the transactions that fell out of the
+// retention window since the last pass, advancing the tail as it goes so an
+// interrupted pass is resumed.
+func (bc *BlockChain) unindexTxs() {
+	head := bc.CurrentFastBlock().NumberU64()
+	if head < bc.txLookupLimit {
+		return
+	}
+	tail, target := GetTxIndexTail(bc.chainDb), head-bc.txLookupLimit+1
+	if tail >= target {
+		return
+	}
+	start, from := time.Now(), tail
+	for tail < target {
+		select {
+		case <-bc.quit:
+			glog.V(logger.Info).Infof("Transaction unindexing interrupted at block #%d", tail)
+			return
+		default:
+		}
+		end := tail + txUnindexBatch
+		if end > target {
+			end = target
+		}
+		UnindexTransactions(bc.chainDb, tail, end)
+		if err := WriteTxIndexTail(bc.chainDb, end); err != nil {
+			glog.V(logger.Error).Errorf("Failed to store transaction index tail: %v", err)
+			return
+		}
+		tail = end
+	}
+	glog.V(logger.Info).Infof("Unindexed transactions of blocks #%d-#%d in %v", from, target-1, time.Since(start))
+}
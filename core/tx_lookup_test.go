@@ -0,0 +1,69 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/ethdb"
+)
+
+// Tests that transactions indexed with either lookup scheme can be retrieved,
+// and that unindexing removes the lookups of the given blocks only.
+func TestTxLookupUnindexing(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	var blocks []*types.Block
+	for i := 0; i < 4; i++ {
+		txs := []*types.Transaction{
+			types.NewTransaction(uint64(2*i), common.BytesToAddress([]byte{0x11}), big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil),
+			types.NewTransaction(uint64(2*i+1), common.BytesToAddress([]byte{0x22}), big.NewInt(2), big.NewInt(21000), big.NewInt(1), nil),
+		}
+		block := types.NewBlock(&types.Header{Number: big.NewInt(int64(i))}, txs, nil, nil)
+		if err := WriteBlock(db, block); err != nil {
+			t.Fatalf("block %d: failed to write block: %v", i, err)
+		}
+		if err := WriteCanonicalHash(db, block.Hash(), block.NumberU64()); err != nil {
+			t.Fatalf("block %d: failed to write canonical hash: %v", i, err)
+		}
+		// Index the even blocks with the legacy scheme, the odd ones with lookup entries
+		if err := WriteTxLookups(db, uint64(i%2), block); err != nil {
+			t.Fatalf("block %d: failed to index transactions: %v", i, err)
+		}
+		blocks = append(blocks, block)
+	}
+	check := func(unindexed int) {
+		for i, block := range blocks {
+			for j, tx := range block.Transactions() {
+				txn, hash, number, index := GetTransaction(db, tx.Hash())
+				if i < unindexed {
+					if txn != nil {
+						t.Errorf("block %d tx %d: unindexed transaction returned", i, j)
+					}
+					continue
+				}
+				if txn == nil {
+					t.Errorf("block %d tx %d: transaction not found", i, j)
+					continue
+				}
+				if txn.Hash() != tx.Hash() || hash != block.Hash() || number != block.NumberU64() || index != uint64(j) {
+					t.Errorf("block %d tx %d: lookup mismatch: have %x/%x/%d/%d", i, j, txn.Hash(), hash, number, index)
+				}
+			}
+		}
+	}
+	check(0)
+
+	UnindexTransactions(db, 0, 2)
+	check(2)
+	UnindexTransactions(db, 2, 4)
+	check(4)
+
+	// The blocks themselves must be retained
+	for i, block := range blocks {
+		if GetBody(db, block.Hash()) == nil {
+			t.Errorf("block %d: body removed by unindexing", i)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+)
+
+// Names of the built-in transaction ordering strategies.
+const (
+	TxOrderingPrice     = "price"      // Highest gas price first, the default
+	TxOrderingNonceFair = "nonce-fair" // Round robin across senders, oldest sender first
+	TxOrderingFIFO      = "fifo"       // Order of arrival in the pool
+)
+
+// PendingTx is a processable transaction of the pool along with what an ordering
+// strategy may need to know about it.
+type PendingTx struct {
+	Tx      *types.Transaction
+	From    common.Address
+	Arrived time.Time // When the transaction entered the pool
+	Local   bool      // Whether the transaction was submitted locally
+}
+
+// TxOrdering arranges the processable transactions of the pool into the order
+// they're offered for inclusion in a block, allowing private chains to enforce
+// their own inclusion policies. Implementations must keep the transactions of
+// every sender in nonce order, and may leave transactions out.
+type TxOrdering interface {
+	Order(txs []*PendingTx) types.Transactions
+}
+
+// TxOrderingFunc adapts an ordinary function to the TxOrdering interface.
+type TxOrderingFunc func(txs []*PendingTx) types.Transactions
+
+// Order implements TxOrdering.
+func (f TxOrderingFunc) Order(txs []*PendingTx) types.Transactions { return f(txs) }
+
+var (
+	txOrderingsMu sync.RWMutex
+	txOrderings   = map[string]TxOrdering{
+		TxOrderingPrice:     TxOrderingFunc(orderByPrice),
+		TxOrderingNonceFair: TxOrderingFunc(orderNonceFair),
+		TxOrderingFIFO:      TxOrderingFunc(orderByArrival),
+	}
+)
+
+// RegisterTxOrdering makes a transaction ordering strategy available by name.
+// Registering a name twice is an error.
+func RegisterTxOrdering(name string, ordering TxOrdering) error {
+	txOrderingsMu.Lock()
+	defer txOrderingsMu.Unlock()
+
+	if _, ok := txOrderings[name]; ok {
+		return fmt.Errorf("transaction ordering %q already registered", name)
+	}
+	txOrderings[name] = ordering
+	return nil
+}
+
+// GetTxOrdering returns the transaction ordering strategy registered by name, the
+// price ordering if the name is empty.
+func GetTxOrdering(name string) (TxOrdering, error) {
+	if name == "" {
+		name = TxOrderingPrice
+	}
+	txOrderingsMu.RLock()
+	defer txOrderingsMu.RUnlock()
+
+	ordering, ok := txOrderings[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown transaction ordering %q (want one of %v)", name, txOrderingNames())
+	}
+	return ordering, nil
+}
+
+// TxOrderingNames returns the names of the registered transaction orderings.
+func TxOrderingNames() []string {
+	txOrderingsMu.RLock()
+	defer txOrderingsMu.RUnlock()
+
+	return txOrderingNames()
+}
+
+func txOrderingNames() []string {
+	names := make([]string, 0, len(txOrderings))
+	for name := range txOrderings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// orderByPrice orders the transactions by descending gas price, keeping the
+// nonce order of every sender.
+func orderByPrice(txs []*PendingTx) types.Transactions {
+	ordered := make(types.Transactions, len(txs))
+	for i, tx := range txs {
+		ordered[i] = tx.Tx
+	}
+	types.SortByPriceAndNonce(ordered)
+	return ordered
+}
+
+// orderNonceFair takes one transaction of every sender per round, in nonce order,
+// so no sender can crowd the others out of a block. Within a round the senders
+// are taken in the order their first transaction arrived.
+func orderNonceFair(txs []*PendingTx) types.Transactions {
+	senders := groupBySender(txs)
+	ordered := make(types.Transactions, 0, len(txs))
+	for round := 0; len(ordered) < len(txs); round++ {
+		for _, sender := range senders {
+			if round < len(sender) {
+				ordered = append(ordered, sender[round].Tx)
+			}
+		}
+	}
+	return ordered
+}
+
+// orderByArrival orders the transactions by their arrival in the pool. A
+// transaction arriving before a lower nonce one of the same sender is held back
+// until the latter.
+func orderByArrival(txs []*PendingTx) types.Transactions {
+	senders := groupBySender(txs)
+	ordered := make(types.Transactions, 0, len(txs))
+	for len(ordered) < len(txs) {
+		// Pick the earliest arrival among the next transaction of every sender
+		best := -1
+		for i, sender := range senders {
+			if len(sender) > 0 && (best < 0 || sender[0].Arrived.Before(senders[best][0].Arrived)) {
+				best = i
+			}
+		}
+		ordered = append(ordered, senders[best][0].Tx)
+		senders[best] = senders[best][1:]
+	}
+	return ordered
+}
+
+// groupBySender splits the transactions per sender, each in nonce order, with the
+// senders ordered by the arrival of their earliest transaction.
+func groupBySender(txs []*PendingTx) [][]*PendingTx {
+	index := make(map[common.Address]int)
+	var senders [][]*PendingTx
+	for _, tx := range txs {
+		i, ok := index[tx.From]
+		if !ok {
+			i = len(senders)
+			index[tx.From] = i
+			senders = append(senders, nil)
+		}
+		senders[i] = append(senders[i], tx)
+	}
+	first := make([]time.Time, len(senders))
+	for i, sender := range senders {
+		sort.Sort(pendingTxsByNonce(sender))
+		first[i] = sender[0].Arrived
+		for _, tx := range sender[1:] {
+			if tx.Arrived.Before(first[i]) {
+				first[i] = tx.Arrived
+			}
+		}
+	}
+	sort.Sort(sendersByArrival{senders, first})
+	return senders
+}
+
+type pendingTxsByNonce []*PendingTx
+
+func (s pendingTxsByNonce) Len() int           { return len(s) }
+func (s pendingTxsByNonce) Less(i, j int) bool { return s[i].Tx.Nonce() < s[j].Tx.Nonce() }
+func (s pendingTxsByNonce) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type sendersByArrival struct {
+	senders [][]*PendingTx
+	first   []time.Time
+}
+
+func (s sendersByArrival) Len() int           { return len(s.senders) }
+func (s sendersByArrival) Less(i, j int) bool { return s.first[i].Before(s.first[j]) }
+func (s sendersByArrival) Swap(i, j int) {
+	s.senders[i], s.senders[j] = s.senders[j], s.senders[i]
+	s.first[i], s.first[j] = s.first[j], s.first[i]
+}
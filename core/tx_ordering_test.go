@@ -0,0 +1,70 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/crypto"
+)
+
+func TestTxOrderings(t *testing.T) {
+	keyA, _ := crypto.GenerateKey()
+	keyB, _ := crypto.GenerateKey()
+
+	start := time.Now()
+	pending := func(key string, nonce uint64, price int64, arrived int) *PendingTx {
+		k := keyA
+		if key == "b" {
+			k = keyB
+		}
+		tx, _ := types.NewTransaction(nonce, common.Address{}, big.NewInt(0), big.NewInt(21000), big.NewInt(price), nil).SignECDSA(k)
+		from, _ := tx.From()
+		return &PendingTx{Tx: tx, From: from, Arrived: start.Add(time.Duration(arrived) * time.Second)}
+	}
+	// Sender a arrives first with cheap transactions, b later with expensive ones
+	txs := []*PendingTx{
+		pending("a", 1, 1, 1),
+		pending("a", 0, 1, 2), // arrives after its successor
+		pending("a", 2, 1, 3),
+		pending("b", 0, 10, 4),
+		pending("b", 1, 10, 5),
+	}
+	tests := []struct {
+		ordering string
+		want     []*PendingTx
+	}{
+		{TxOrderingPrice, []*PendingTx{txs[3], txs[4], txs[1], txs[0], txs[2]}},
+		{TxOrderingNonceFair, []*PendingTx{txs[1], txs[3], txs[0], txs[4], txs[2]}},
+		{TxOrderingFIFO, []*PendingTx{txs[1], txs[0], txs[2], txs[3], txs[4]}},
+	}
+	for _, tt := range tests {
+		ordering, err := GetTxOrdering(tt.ordering)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.ordering, err)
+		}
+		have := ordering.Order(append([]*PendingTx(nil), txs...))
+		if len(have) != len(tt.want) {
+			t.Fatalf("%s: length mismatch: have %d, want %d", tt.ordering, len(have), len(tt.want))
+		}
+		for i := range have {
+			if have[i] != tt.want[i].Tx {
+				t.Errorf("%s: tx %d mismatch: have nonce %d, want nonce %d", tt.ordering, i, have[i].Nonce(), tt.want[i].Tx.Nonce())
+			}
+		}
+	}
+}
+
+func TestRegisterTxOrdering(t *testing.T) {
+	if err := RegisterTxOrdering(TxOrderingPrice, TxOrderingFunc(orderByPrice)); err == nil {
+		t.Errorf("registered built-in ordering twice")
+	}
+	if _, err := GetTxOrdering("unknown"); err == nil {
+		t.Errorf("unknown ordering returned")
+	}
+	if ordering, err := GetTxOrdering(""); err != nil || ordering == nil {
+		t.Errorf("no default ordering: %v", err)
+	}
+}
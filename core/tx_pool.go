@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/memwatch"
 	"github.com/openether/ethcore/core/state"
 	"github.com/openether/ethcore/core/types"
 	"github.com/openether/ethcore/event"
@@ -27,14 +28,58 @@ var (
 	ErrIntrinsicGas       = errors.New("Intrinsic gas too low")
 	ErrGasLimit           = errors.New("Exceeds block gas limit")
 	ErrNegativeValue      = errors.New("Negative value")
+	ErrInvalidChainId     = errors.New("Invalid chain id")
+	ErrUnprotectedTx      = errors.New("Only replay-protected (EIP-155) transactions allowed")
+	ErrNotPrivate         = errors.New("Transaction not kept private")
 )
 
 const (
 	maxQueued = 64 // max limit of queued txs per address
+
+	throttledTxRate = 100 // Transactions from peers accepted per second under high memory pressure
 )
 
 type stateFn func() (*state.StateDB, error)
 
+// UnprotectedTxPolicy controls whether the pool accepts and relays transactions
+// without EIP-155 replay protection.
+type UnprotectedTxPolicy int
+
+const (
+	UnprotectedTxsChain UnprotectedTxPolicy = iota // Follow the chain configuration ("all" or "none")
+	UnprotectedTxsAll                              // Accept from anywhere and relay
+	UnprotectedTxsLocal                            // Accept only if submitted locally, don't relay
+	UnprotectedTxsNone                             // Reject
+)
+
+// ParseUnprotectedTxPolicy parses the textual form of an UnprotectedTxPolicy.
+func ParseUnprotectedTxPolicy(s string) (UnprotectedTxPolicy, error) {
+	switch s {
+	case "chain", "":
+		return UnprotectedTxsChain, nil
+	case "all":
+		return UnprotectedTxsAll, nil
+	case "local":
+		return UnprotectedTxsLocal, nil
+	case "none":
+		return UnprotectedTxsNone, nil
+	}
+	return UnprotectedTxsChain, fmt.Errorf("unknown unprotected transaction policy %q (want chain, all, local or none)", s)
+}
+
+func (p UnprotectedTxPolicy) String() string {
+	switch p {
+	case UnprotectedTxsAll:
+		return "all"
+	case UnprotectedTxsLocal:
+		return "local"
+	case UnprotectedTxsNone:
+		return "none"
+	default:
+		return "chain"
+	}
+}
+
 // TxPool contains all currently known transactions. Transactions
 // enter the pool when they are received from the network or submitted
 // locally. They exit the pool when they are included in the blockchain.
@@ -52,9 +97,24 @@ type TxPool struct {
 	eventMux     *event.TypeMux
 	events       event.Subscription
 	localTx      *txSet
+	private      map[common.Hash]struct{} // local transactions kept from the network until released
+	privateLocal bool                     // whether local transactions are marked private
 	mu           sync.RWMutex
 	pending      map[common.Hash]*types.Transaction // processable transactions
 	queue        map[common.Address]map[common.Hash]*types.Transaction
+	gaps         map[common.Address]NonceRange // nonce gaps currently blocking queued transactions
+	gapEvents    []interface{}                 // nonce gap events waiting to be posted, in order
+	gapLock      sync.Mutex                    // protects gapEvents, posted outside of the pool lock
+	gapPost      chan struct{}                 // notifies the gap event loop of waiting events
+	quit         chan struct{}                 // stops the gap event loop
+	arrivals     map[common.Hash]time.Time     // when the pooled transactions were first accepted
+	ordering     TxOrdering                    // order the processable transactions are offered for inclusion in
+
+	unprotected UnprotectedTxPolicy // Acceptance of transactions without replay protection
+	headNumber  *big.Int            // Number of the current chain head, for fork dependent checks
+
+	throttleStart time.Time // Start of the second the throttled transactions are counted in
+	throttleCount int       // Transactions from peers accepted in that second under memory pressure
 
 	wg sync.WaitGroup // for shutdown sync
 
@@ -67,17 +127,24 @@ func NewTxPool(config *ChainConfig, eventMux *event.TypeMux, currentStateFn stat
 		signer:       types.NewChainIdSigner(config.GetChainID()),
 		pending:      make(map[common.Hash]*types.Transaction),
 		queue:        make(map[common.Address]map[common.Hash]*types.Transaction),
+		gaps:         make(map[common.Address]NonceRange),
+		gapPost:      make(chan struct{}, 1),
+		quit:         make(chan struct{}),
+		arrivals:     make(map[common.Hash]time.Time),
+		ordering:     TxOrderingFunc(orderByPrice),
 		eventMux:     eventMux,
 		currentState: currentStateFn,
 		gasLimit:     gasLimitFn,
 		minGasPrice:  new(big.Int),
 		pendingState: nil,
 		localTx:      newTxSet(),
+		private:      make(map[common.Hash]struct{}),
 		events:       eventMux.Subscribe(ChainHeadEvent{}, GasPriceChanged{}, RemovedTransactionEvent{}),
 	}
 
-	pool.wg.Add(1)
+	pool.wg.Add(2)
 	go pool.eventLoop()
+	go pool.gapEventLoop()
 
 	return pool
 }
@@ -91,13 +158,7 @@ func (pool *TxPool) eventLoop() {
 	for ev := range pool.events.Chan() {
 		switch ev := ev.Data.(type) {
 		case ChainHeadEvent:
-			pool.mu.Lock()
-			if ev.Block != nil && pool.config.IsHomestead(ev.Block.Number()) {
-				pool.homestead = true
-			}
-
-			pool.resetState()
-			pool.mu.Unlock()
+			pool.Reset(ev.Block)
 		case GasPriceChanged:
 			pool.mu.Lock()
 			pool.minGasPrice = ev.Price
@@ -108,6 +169,22 @@ func (pool *TxPool) eventLoop() {
 	}
 }
 
+// Reset updates the pool to a new chain head: the fork dependent checks follow
+// its number and the pending state is rebuilt from the current state. The head
+// may be nil, leaving the fork dependent checks unchanged.
+func (pool *TxPool) Reset(head *types.Block) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if head != nil {
+		if pool.config.IsHomestead(head.Number()) {
+			pool.homestead = true
+		}
+		pool.headNumber = head.Number()
+	}
+	pool.resetState()
+}
+
 func (pool *TxPool) resetState() {
 	currentState, err := pool.currentState()
 	if err != nil {
@@ -141,10 +218,54 @@ func (pool *TxPool) resetState() {
 	// Check the queue and move transactions over to the pending if possible
 	// or remove those that have become invalid
 	pool.checkQueue()
+
+	// Forget the arrival of the transactions no longer pooled
+	pooled := make(map[common.Hash]struct{}, len(pool.arrivals))
+	for hash := range pool.pending {
+		pooled[hash] = struct{}{}
+	}
+	for _, txs := range pool.queue {
+		for hash := range txs {
+			pooled[hash] = struct{}{}
+		}
+	}
+	for hash := range pool.arrivals {
+		if _, ok := pooled[hash]; !ok {
+			delete(pool.arrivals, hash)
+		}
+	}
+	for hash := range pool.private {
+		if _, ok := pooled[hash]; !ok {
+			delete(pool.private, hash)
+		}
+	}
+}
+
+// gapEventLoop posts the nonce gap events in the order the gaps changed. They
+// are posted outside of the pool lock, as subscribers may call back into the pool.
+func (pool *TxPool) gapEventLoop() {
+	defer pool.wg.Done()
+
+	for {
+		select {
+		case <-pool.gapPost:
+			pool.gapLock.Lock()
+			events := pool.gapEvents
+			pool.gapEvents = nil
+			pool.gapLock.Unlock()
+
+			for _, ev := range events {
+				pool.eventMux.Post(ev)
+			}
+		case <-pool.quit:
+			return
+		}
+	}
 }
 
 func (pool *TxPool) Stop() {
 	pool.events.Unsubscribe()
+	close(pool.quit)
 	pool.wg.Wait()
 	glog.V(logger.Info).Infoln("Transaction pool stopped")
 }
@@ -197,12 +318,110 @@ func (pool *TxPool) Content() (map[common.Address]map[uint64][]*types.Transactio
 	return pending, queued
 }
 
+// SetMinGasPrice sets the lowest gas price of transactions from the network the
+// pool accepts. Local transactions are accepted at any price, transactions
+// already in the pool are kept.
+func (pool *TxPool) SetMinGasPrice(price *big.Int) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.minGasPrice = new(big.Int).Set(price)
+}
+
+// MinGasPrice returns the lowest gas price of transactions from the network the
+// pool accepts.
+func (pool *TxPool) MinGasPrice() *big.Int {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return new(big.Int).Set(pool.minGasPrice)
+}
+
+// SetUnprotectedTxPolicy sets whether transactions without replay protection
+// are accepted and relayed.
+func (pool *TxPool) SetUnprotectedTxPolicy(policy UnprotectedTxPolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.unprotected = policy
+}
+
+// unprotectedPolicy resolves the policy for unprotected transactions at the
+// current chain head. The caller must hold the pool lock.
+func (pool *TxPool) unprotectedPolicy() UnprotectedTxPolicy {
+	if pool.unprotected != UnprotectedTxsChain {
+		return pool.unprotected
+	}
+	if pool.config.RequiresProtectedTxs(pool.headNumber) {
+		return UnprotectedTxsNone
+	}
+	return UnprotectedTxsAll
+}
+
+// Relayable reports whether the given pool transaction may be propagated to
+// the network.
+func (pool *TxPool) Relayable(tx *types.Transaction) bool {
+	if tx.Protected() {
+		return true
+	}
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.unprotectedPolicy() == UnprotectedTxsAll
+}
+
 // SetLocal marks a transaction as local, skipping gas price
 //  check against local miner minimum in the future
 func (pool *TxPool) SetLocal(tx *types.Transaction) {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 	pool.localTx.add(tx.Hash())
+	if pool.privateLocal {
+		pool.private[tx.Hash()] = struct{}{}
+	}
+}
+
+// SetPrivateLocal sets whether the transactions marked local from now on are kept
+// private: they are withheld from the public network, only reaching the peers the
+// protocol handler trusts to relay them, until released.
+func (pool *TxPool) SetPrivateLocal(private bool) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.privateLocal = private
+}
+
+// Private reports whether the transaction with the given hash is kept private.
+func (pool *TxPool) Private(hash common.Hash) bool {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	_, ok := pool.private[hash]
+	return ok
+}
+
+// PrivateHashes returns the hashes of the pooled transactions kept private.
+func (pool *TxPool) PrivateHashes() []common.Hash {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	hashes := make([]common.Hash, 0, len(pool.private))
+	for hash := range pool.private {
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+// Release stops keeping the transaction with the given hash private, returning
+// it if it is processable and should now be propagated. Queued transactions are
+// propagated once they become processable.
+func (pool *TxPool) Release(hash common.Hash) (*types.Transaction, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if _, ok := pool.private[hash]; !ok {
+		return nil, ErrNotPrivate
+	}
+	delete(pool.private, hash)
+	return pool.pending[hash], nil
 }
 
 // validateTx checks whether a transaction is valid according
@@ -233,6 +452,17 @@ func (pool *TxPool) validateTx(tx *types.Transaction) (e error) {
 		return
 	}
 
+	// Check replay protection against the configured policy and chain id
+	if !tx.Protected() {
+		if policy := pool.unprotectedPolicy(); policy == UnprotectedTxsNone || (policy == UnprotectedTxsLocal && !local) {
+			e = ErrUnprotectedTx
+			return
+		}
+	} else if chainId := pool.config.GetChainID(); chainId.Sign() != 0 && tx.ChainId().Cmp(chainId) != 0 {
+		e = ErrInvalidChainId
+		return
+	}
+
 	from, err := types.Sender(pool.signer, tx)
 	if err != nil {
 		e = ErrInvalidSender
@@ -330,6 +560,9 @@ func (self *TxPool) queueTx(hash common.Hash, tx *types.Transaction) {
 		self.queue[from] = make(map[common.Hash]*types.Transaction)
 	}
 	self.queue[from][hash] = tx
+	if _, ok := self.arrivals[hash]; !ok {
+		self.arrivals[hash] = time.Now()
+	}
 }
 
 // addTx will add a transaction to the pending (processable queue) list of transactions
@@ -364,12 +597,14 @@ func (self *TxPool) Add(tx *types.Transaction) error {
 	return nil
 }
 
-// AddTransactions attempts to queue all valid transactions in txs.
+// AddTransactions attempts to queue all valid transactions in txs. The
+// transactions are received from peers, which are throttled under memory
+// pressure.
 func (self *TxPool) AddTransactions(txs []*types.Transaction) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
 
-	for _, tx := range txs {
+	for _, tx := range self.throttle(txs, memwatch.CurrentLevel(), time.Now()) {
 		if err := self.add(tx); err != nil {
 			glog.V(logger.Debug).Infoln("tx error:", err)
 		} else {
@@ -382,6 +617,28 @@ func (self *TxPool) AddTransactions(txs []*types.Transaction) {
 	self.checkQueue()
 }
 
+// throttle returns the transactions from peers to accept given the memory
+// pressure: all of them normally, up to throttledTxRate per second under high
+// pressure and none under critical pressure.
+func (self *TxPool) throttle(txs []*types.Transaction, level memwatch.Level, now time.Time) []*types.Transaction {
+	switch level {
+	case memwatch.Normal:
+		return txs
+	case memwatch.Critical:
+		glog.V(logger.Debug).Infof("Dropped %d transactions from peers under critical memory pressure", len(txs))
+		return nil
+	}
+	if now.Sub(self.throttleStart) >= time.Second {
+		self.throttleStart, self.throttleCount = now, 0
+	}
+	if allowed := throttledTxRate - self.throttleCount; len(txs) > allowed {
+		glog.V(logger.Debug).Infof("Dropped %d transactions from peers under high memory pressure", len(txs)-allowed)
+		txs = txs[:allowed]
+	}
+	self.throttleCount += len(txs)
+	return txs
+}
+
 // GetTransaction returns a transaction if it is contained in the pool
 // and nil otherwise.
 func (tp *TxPool) GetTransaction(hash common.Hash) *types.Transaction {
@@ -421,6 +678,37 @@ func (self *TxPool) GetTransactions() (txs types.Transactions) {
 	return txs
 }
 
+// SetOrdering sets the strategy ordering the transactions returned by Ordered.
+func (self *TxPool) SetOrdering(ordering TxOrdering) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.ordering = ordering
+}
+
+// Ordered returns all currently processable transactions in the order they're
+// offered for inclusion in a block, as arranged by the pool's ordering strategy.
+func (self *TxPool) Ordered() types.Transactions {
+	self.mu.Lock()
+	self.checkQueue()
+	self.validatePool()
+
+	txs := make([]*PendingTx, 0, len(self.pending))
+	for hash, tx := range self.pending {
+		from, _ := types.Sender(self.signer, tx) // already validated
+		txs = append(txs, &PendingTx{
+			Tx:      tx,
+			From:    from,
+			Arrived: self.arrivals[hash],
+			Local:   self.localTx.contains(hash),
+		})
+	}
+	ordering := self.ordering
+	self.mu.Unlock()
+
+	return ordering.Order(txs)
+}
+
 // GetQueuedTransactions returns all non-processable transactions.
 func (self *TxPool) GetQueuedTransactions() types.Transactions {
 	self.mu.RLock()
@@ -452,6 +740,44 @@ func (pool *TxPool) RemoveTx(hash common.Hash) {
 	pool.removeTx(hash)
 }
 
+// Replace swaps the pooled transaction with the given hash for a replacement
+// marked local, private if the replaced transaction was, as a single step. If the
+// replacement is refused, the replaced transaction stays pooled as it was.
+func (pool *TxPool) Replace(hash common.Hash, tx *types.Transaction) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	old, pending := pool.pending[hash]
+	if !pending {
+		for _, txs := range pool.queue {
+			if old = txs[hash]; old != nil {
+				break
+			}
+		}
+	}
+	if old == nil {
+		return fmt.Errorf("transaction %x not pooled", hash[:4])
+	}
+	_, private := pool.private[hash]
+
+	pool.removeTx(hash)
+	pool.localTx.add(tx.Hash())
+	if private || pool.privateLocal {
+		pool.private[tx.Hash()] = struct{}{}
+	}
+	if err := pool.add(tx); err != nil {
+		delete(pool.private, tx.Hash())
+		if pending {
+			pool.pending[hash] = old
+		} else {
+			pool.queueTx(hash, old)
+		}
+		return err
+	}
+	pool.checkQueue()
+	return nil
+}
+
 func (pool *TxPool) removeTx(hash common.Hash) {
 	// delete from pending pool
 	delete(pool.pending, hash)
@@ -477,6 +803,7 @@ func (pool *TxPool) checkQueue() {
 	}
 
 	var promote txQueue
+	gaps := make(map[common.Address]NonceRange)
 	for address, txs := range pool.queue {
 		currentState, err := pool.currentState()
 		if err != nil {
@@ -508,6 +835,7 @@ func (pool *TxPool) checkQueue() {
 		for i, entry := range promote {
 			// If we reached a gap in the nonces, enforce transaction limit and stop
 			if entry.Nonce() > guessedNonce {
+				gaps[address] = NonceRange{From: guessedNonce, To: entry.Nonce() - 1}
 				if len(promote)-i > maxQueued {
 					if glog.V(logger.Debug) {
 						glog.Infof("Queued tx limit exceeded for %s. Tx %s removed\n", common.PP(address[:]), common.PP(entry.hash[:]))
@@ -531,8 +859,94 @@ func (pool *TxPool) checkQueue() {
 			delete(pool.queue, address)
 		}
 	}
+	pool.updateGaps(gaps)
+}
+
+// updateGaps replaces the set of nonce gaps blocking queued transactions,
+// notifying subscribers about the gaps that were created or filled.
+func (pool *TxPool) updateGaps(gaps map[common.Address]NonceRange) {
+	pool.gapLock.Lock()
+	defer pool.gapLock.Unlock()
+
+	queued := len(pool.gapEvents)
+	for address, gap := range gaps {
+		if prev, ok := pool.gaps[address]; !ok || prev != gap {
+			glog.V(logger.Debug).Infof("queued txs of %x blocked by missing nonces %d-%d", address[:4], gap.From, gap.To)
+			pool.gapEvents = append(pool.gapEvents, TxNonceGapEvent{Account: address, Missing: gap})
+		}
+	}
+	for address := range pool.gaps {
+		if _, ok := gaps[address]; !ok {
+			glog.V(logger.Debug).Infof("nonce gap of %x filled", address[:4])
+			pool.gapEvents = append(pool.gapEvents, TxNonceGapFilledEvent{Account: address})
+		}
+	}
+	pool.gaps = gaps
+
+	if len(pool.gapEvents) > queued {
+		select {
+		case pool.gapPost <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// NonceRange is an inclusive range of account nonces.
+type NonceRange struct {
+	From uint64
+	To   uint64
+}
+
+// NonceGaps describes why the queued transactions of an account can't be
+// promoted to pending: the pool expects the Next nonce, but the account's
+// transactions are missing the nonces in the Missing ranges.
+type NonceGaps struct {
+	Next    uint64       // Next nonce the pool expects from the account
+	Missing []NonceRange // Ranges of nonces missing in front of queued transactions
+	Queued  int          // Number of transactions queued for the account
+}
+
+// NonceGaps returns, for every account with queued transactions, the nonces
+// missing for those transactions to become processable.
+func (pool *TxPool) NonceGaps() map[common.Address]*NonceGaps {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	// init delayed since tx pool could have been started before any state sync
+	if pool.pendingState == nil {
+		pool.resetState()
+	}
+	gaps := make(map[common.Address]*NonceGaps)
+	for address, txs := range pool.queue {
+		nonces := make([]uint64, 0, len(txs))
+		for _, tx := range txs {
+			nonces = append(nonces, tx.Nonce())
+		}
+		sort.Sort(nonceSlice(nonces))
+
+		next := pool.pendingState.GetNonce(address)
+		account := &NonceGaps{Next: next, Queued: len(txs)}
+		for _, nonce := range nonces {
+			if nonce > next {
+				account.Missing = append(account.Missing, NonceRange{From: next, To: nonce - 1})
+			}
+			if nonce >= next {
+				next = nonce + 1
+			}
+		}
+		if len(account.Missing) > 0 {
+			gaps[address] = account
+		}
+	}
+	return gaps
 }
 
+type nonceSlice []uint64
+
+func (s nonceSlice) Len() int           { return len(s) }
+func (s nonceSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s nonceSlice) Less(i, j int) bool { return s[i] < s[j] }
+
 // validatePool removes invalid and processed transactions from the main pool.
 // If a transaction is removed for being invalid (e.g. out of funds), all sub-
 // sequent (Still valid) transactions are moved back into the future queue. This
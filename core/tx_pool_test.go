@@ -20,8 +20,10 @@ import (
 	"crypto/ecdsa"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/common/memwatch"
 	"github.com/ethereumclassic/go-ethereum/core/state"
 	"github.com/ethereumclassic/go-ethereum/core/types"
 	"github.com/ethereumclassic/go-ethereum/crypto"
@@ -35,12 +37,16 @@ func transaction(nonce uint64, gaslimit *big.Int, key *ecdsa.PrivateKey) *types.
 }
 
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
+	return setupTxPoolWithConfig(testChainConfig())
+}
+
+func setupTxPoolWithConfig(config *ChainConfig) (*TxPool, *ecdsa.PrivateKey) {
 	db, _ := ethdb.NewMemDatabase()
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
 
 	var m event.TypeMux
 	key, _ := crypto.GenerateKey()
-	newPool := NewTxPool(testChainConfig(), &m, func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
+	newPool := NewTxPool(config, &m, func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
 	newPool.resetState()
 	return newPool, key
 }
@@ -136,6 +142,287 @@ func TestTransactionQueue(t *testing.T) {
 	}
 }
 
+// Tests that nonce gaps blocking queued transactions are reported and tracked
+// as they get filled.
+func TestNonceGaps(t *testing.T) {
+	pool, key := setupTxPool()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	currentState, _ := pool.currentState()
+	currentState.AddBalance(from, big.NewInt(1000))
+
+	for _, nonce := range []uint64{0, 3, 4, 7} {
+		tx := transaction(nonce, big.NewInt(100), key)
+		pool.queueTx(tx.Hash(), tx)
+	}
+	pool.checkQueue()
+
+	gaps := pool.NonceGaps()[from]
+	if gaps == nil {
+		t.Fatal("expected nonce gaps for account")
+	}
+	if gaps.Next != 1 || gaps.Queued != 3 {
+		t.Errorf("next/queued mismatch: have %d/%d, want %d/%d", gaps.Next, gaps.Queued, 1, 3)
+	}
+	want := []NonceRange{{1, 2}, {5, 6}}
+	if len(gaps.Missing) != len(want) {
+		t.Fatalf("missing ranges mismatch: have %v, want %v", gaps.Missing, want)
+	}
+	for i := range want {
+		if gaps.Missing[i] != want[i] {
+			t.Errorf("missing range %d mismatch: have %v, want %v", i, gaps.Missing[i], want[i])
+		}
+	}
+	if gap := pool.gaps[from]; gap != want[0] {
+		t.Errorf("tracked gap mismatch: have %v, want %v", gap, want[0])
+	}
+	// Fill the first gap, only the second one should remain
+	for _, nonce := range []uint64{1, 2} {
+		tx := transaction(nonce, big.NewInt(100), key)
+		pool.queueTx(tx.Hash(), tx)
+	}
+	pool.checkQueue()
+
+	if gap := pool.gaps[from]; gap != want[1] {
+		t.Errorf("tracked gap mismatch: have %v, want %v", gap, want[1])
+	}
+	// Fill the second gap, nothing should remain
+	for _, nonce := range []uint64{5, 6} {
+		tx := transaction(nonce, big.NewInt(100), key)
+		pool.queueTx(tx.Hash(), tx)
+	}
+	pool.checkQueue()
+
+	if _, ok := pool.gaps[from]; ok {
+		t.Error("expected nonce gap to be filled")
+	}
+	if gaps := pool.NonceGaps(); len(gaps) != 0 {
+		t.Errorf("expected no nonce gaps, got %v", gaps)
+	}
+}
+
+// Tests that the nonce gap events are posted in the order the gaps changed.
+func TestNonceGapEventOrder(t *testing.T) {
+	pool, key := setupTxPool()
+	defer pool.Stop()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	currentState, _ := pool.currentState()
+	currentState.AddBalance(from, big.NewInt(1000))
+
+	sub := pool.eventMux.Subscribe(TxNonceGapEvent{}, TxNonceGapFilledEvent{})
+	defer sub.Unsubscribe()
+
+	// Open a gap, move it and fill it, each step changing the gap of the account
+	for _, nonces := range [][]uint64{{3, 4, 7}, {0, 1, 2}, {5, 6}} {
+		for _, nonce := range nonces {
+			tx := transaction(nonce, big.NewInt(100), key)
+			pool.queueTx(tx.Hash(), tx)
+		}
+		pool.checkQueue()
+	}
+	want := []interface{}{
+		TxNonceGapEvent{Account: from, Missing: NonceRange{0, 2}},
+		TxNonceGapEvent{Account: from, Missing: NonceRange{5, 6}},
+		TxNonceGapFilledEvent{Account: from},
+	}
+	for i, ev := range want {
+		select {
+		case have := <-sub.Chan():
+			if have.Data != ev {
+				t.Errorf("event %d mismatch: have %+v, want %+v", i, have.Data, ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d not posted", i)
+		}
+	}
+}
+
+func TestUnprotectedTransactions(t *testing.T) {
+	pool, key := setupTxPool()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	currentState, _ := pool.currentState()
+	currentState.AddBalance(from, big.NewInt(1000000))
+
+	unprotected := transaction(0, big.NewInt(21000), key)
+	protected, _ := types.NewTransaction(0, common.Address{}, big.NewInt(100), big.NewInt(21000), big.NewInt(1), nil).WithSigner(types.NewChainIdSigner(big.NewInt(62))).SignECDSA(key)
+	foreign, _ := types.NewTransaction(0, common.Address{}, big.NewInt(100), big.NewInt(21000), big.NewInt(1), nil).WithSigner(types.NewChainIdSigner(big.NewInt(61))).SignECDSA(key)
+
+	if err := pool.validateTx(foreign); err != ErrInvalidChainId {
+		t.Errorf("foreign chain tx: expected %v, got %v", ErrInvalidChainId, err)
+	}
+	if err := pool.validateTx(protected); err != nil {
+		t.Errorf("protected tx: expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		policy    UnprotectedTxPolicy
+		require   bool
+		local     bool
+		err       error
+		relayable bool
+	}{
+		{UnprotectedTxsChain, false, false, nil, true},
+		{UnprotectedTxsChain, true, false, ErrUnprotectedTx, false},
+		{UnprotectedTxsAll, true, false, nil, true},
+		{UnprotectedTxsLocal, false, false, ErrUnprotectedTx, false},
+		{UnprotectedTxsLocal, false, true, nil, false},
+		{UnprotectedTxsNone, false, true, ErrUnprotectedTx, false},
+	}
+	for i, tt := range tests {
+		config := testChainConfig()
+		config.ForkByName("Diehard").Features[0].Options["requireProtected"] = tt.require
+		pool, _ := setupTxPoolWithConfig(config)
+		currentState, _ := pool.currentState()
+		currentState.AddBalance(from, big.NewInt(1000000))
+
+		pool.Reset(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5)}))
+		pool.SetUnprotectedTxPolicy(tt.policy)
+		if tt.local {
+			pool.SetLocal(unprotected)
+		}
+		if err := pool.validateTx(unprotected); err != tt.err {
+			t.Errorf("test %d: expected %v, got %v", i, tt.err, err)
+		}
+		if relayable := pool.Relayable(unprotected); relayable != tt.relayable {
+			t.Errorf("test %d: relayable mismatch: have %v, want %v", i, relayable, tt.relayable)
+		}
+		if !pool.Relayable(protected) {
+			t.Errorf("test %d: protected tx not relayable", i)
+		}
+	}
+	// The chain policy follows the head the pool was reset to
+	config := testChainConfig()
+	config.ForkByName("Diehard").Features[0].Options["requireProtected"] = true
+	pool, _ = setupTxPoolWithConfig(config)
+	currentState, _ = pool.currentState()
+	currentState.AddBalance(from, big.NewInt(1000000))
+
+	pool.Reset(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(4)}))
+	if err := pool.validateTx(unprotected); err != nil {
+		t.Errorf("before the fork: expected no error, got %v", err)
+	}
+	pool.Reset(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5)}))
+	if err := pool.validateTx(unprotected); err != ErrUnprotectedTx {
+		t.Errorf("at the fork: expected %v, got %v", ErrUnprotectedTx, err)
+	}
+}
+
+// Tests that the pool only accepts transactions from the network at its minimum
+// gas price or above, which can change at runtime.
+func TestTxPoolMinGasPrice(t *testing.T) {
+	pool, key := setupTxPool()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	currentState, _ := pool.currentState()
+	currentState.AddBalance(from, big.NewInt(0xffffffffffffff))
+
+	price := big.NewInt(10)
+	pool.SetMinGasPrice(price)
+	price.SetInt64(1000) // The pool keeps its own copy
+	if min := pool.MinGasPrice(); min.Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("min gas price mismatch: have %v, want 10", min)
+	}
+	cheap, _ := types.NewTransaction(0, common.Address{}, big.NewInt(100), big.NewInt(21000), big.NewInt(9), nil).SignECDSA(key)
+	if err := pool.Add(cheap); err != ErrCheap {
+		t.Errorf("transaction below the floor: expected %v, got %v", ErrCheap, err)
+	}
+	pool.SetLocal(cheap)
+	if err := pool.Add(cheap); err != nil {
+		t.Errorf("local transaction below the floor: expected %v, got %v", nil, err)
+	}
+	exact, _ := types.NewTransaction(1, common.Address{}, big.NewInt(100), big.NewInt(21000), big.NewInt(10), nil).SignECDSA(key)
+	if err := pool.Add(exact); err != nil {
+		t.Errorf("transaction at the floor: expected %v, got %v", nil, err)
+	}
+	// Raising the floor keeps the pooled transactions
+	pool.SetMinGasPrice(big.NewInt(20))
+	if pool.GetTransaction(exact.Hash()) == nil {
+		t.Errorf("pooled transaction dropped by raising the floor")
+	}
+	next, _ := types.NewTransaction(2, common.Address{}, big.NewInt(100), big.NewInt(21000), big.NewInt(10), nil).SignECDSA(key)
+	if err := pool.Add(next); err != ErrCheap {
+		t.Errorf("transaction below the raised floor: expected %v, got %v", ErrCheap, err)
+	}
+}
+
+func TestPrivateTransactions(t *testing.T) {
+	pool, key := setupTxPool()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	currentState, _ := pool.currentState()
+	currentState.AddBalance(from, big.NewInt(1000000))
+
+	public := transaction(0, big.NewInt(21000), key)
+	pool.SetLocal(public)
+	pool.SetPrivateLocal(true)
+	private := transaction(1, big.NewInt(21000), key)
+	pool.SetLocal(private)
+	if err := pool.Add(public); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Add(private); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Private(public.Hash()) {
+		t.Error("tx marked local before the private mode is private")
+	}
+	if !pool.Private(private.Hash()) {
+		t.Error("local tx not private")
+	}
+	if _, err := pool.Release(public.Hash()); err != ErrNotPrivate {
+		t.Errorf("releasing public tx: expected %v, got %v", ErrNotPrivate, err)
+	}
+	if tx, err := pool.Release(private.Hash()); err != nil || tx == nil || tx.Hash() != private.Hash() {
+		t.Errorf("releasing private tx: got %v, %v", tx, err)
+	}
+	if pool.Private(private.Hash()) {
+		t.Error("released tx still private")
+	}
+}
+
+// Tests that a replacement takes the place of the replaced transaction with its
+// private status, and that a refused one leaves the replaced transaction pooled.
+func TestReplaceTx(t *testing.T) {
+	pool, key := setupTxPool()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	currentState, _ := pool.currentState()
+	currentState.AddBalance(from, big.NewInt(1000000))
+
+	pool.SetPrivateLocal(true)
+	tx := transaction(0, big.NewInt(21000), key)
+	pool.SetLocal(tx)
+	if err := pool.Add(tx); err != nil {
+		t.Fatal(err)
+	}
+	pool.SetPrivateLocal(false)
+
+	replacement := func(price int64) *types.Transaction {
+		tx, _ := types.NewTransaction(0, common.Address{}, big.NewInt(100), big.NewInt(21000), big.NewInt(price), nil).SignECDSA(key)
+		return tx
+	}
+	unaffordable := replacement(1000)
+	if err := pool.Replace(tx.Hash(), unaffordable); err != ErrInsufficientFunds {
+		t.Fatalf("unaffordable replacement: expected %v, got %v", ErrInsufficientFunds, err)
+	}
+	if pool.pending[tx.Hash()] == nil || !pool.Private(tx.Hash()) || !pool.localTx.contains(tx.Hash()) {
+		t.Errorf("replaced tx not restored as it was")
+	}
+	if pool.GetTransaction(unaffordable.Hash()) != nil || pool.Private(unaffordable.Hash()) {
+		t.Errorf("refused replacement pooled")
+	}
+
+	bumped := replacement(2)
+	if err := pool.Replace(tx.Hash(), bumped); err != nil {
+		t.Fatalf("failed to replace tx: %v", err)
+	}
+	if pool.GetTransaction(tx.Hash()) != nil {
+		t.Errorf("replaced tx still pooled")
+	}
+	if pool.pending[bumped.Hash()] == nil || !pool.Private(bumped.Hash()) || !pool.localTx.contains(bumped.Hash()) {
+		t.Errorf("replacement not pending as a private local tx")
+	}
+	if err := pool.Replace(common.Hash{1}, replacement(3)); err == nil {
+		t.Errorf("replaced unknown tx")
+	}
+}
+
 func TestRemoveTx(t *testing.T) {
 	pool, key := setupTxPool()
 	tx := transaction(0, big.NewInt(100), key)
@@ -539,3 +826,34 @@ func benchmarkCheckQueue(b *testing.B, size int) {
 		pool.checkQueue()
 	}
 }
+
+// Tests that transactions from peers are accepted at a bounded rate under high
+// memory pressure, and dropped under critical pressure.
+func TestTxPoolThrottle(t *testing.T) {
+	pool, key := setupTxPool()
+
+	txs := make([]*types.Transaction, throttledTxRate+10)
+	for i := range txs {
+		txs[i] = transaction(uint64(i), big.NewInt(100000), key)
+	}
+	now := time.Now()
+	if have := pool.throttle(txs, memwatch.Normal, now); len(have) != len(txs) {
+		t.Errorf("normal pressure: accepted %d, want %d", len(have), len(txs))
+	}
+	if have := pool.throttle(txs, memwatch.Critical, now); len(have) != 0 {
+		t.Errorf("critical pressure: accepted %d, want 0", len(have))
+	}
+	// The rate is shared by all calls within a second
+	if have := pool.throttle(txs[:60], memwatch.High, now); len(have) != 60 {
+		t.Errorf("high pressure: accepted %d, want 60", len(have))
+	}
+	if have := pool.throttle(txs, memwatch.High, now.Add(500*time.Millisecond)); len(have) != throttledTxRate-60 || have[0] != txs[0] {
+		t.Errorf("high pressure: accepted %d, want %d", len(have), throttledTxRate-60)
+	}
+	if have := pool.throttle(txs, memwatch.High, now.Add(900*time.Millisecond)); len(have) != 0 {
+		t.Errorf("high pressure beyond the rate: accepted %d, want 0", len(have))
+	}
+	if have := pool.throttle(txs, memwatch.High, now.Add(time.Second)); len(have) != throttledTxRate {
+		t.Errorf("high pressure in the next second: accepted %d, want %d", len(have), throttledTxRate)
+	}
+}
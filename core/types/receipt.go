@@ -7,6 +7,7 @@ import (
 
 	"github.com/openether/ethcore/common"
 	"github.com/openether/ethcore/core/vm"
+	"github.com/openether/ethcore/crypto"
 	"github.com/openether/ethcore/rlp"
 )
 
@@ -18,6 +19,11 @@ const (
 	TxStatusUnknown ReceiptStatus = 0xFF
 )
 
+var (
+	receiptStatusFailedRLP     = []byte{}
+	receiptStatusSuccessfulRLP = []byte{0x01}
+)
+
 // Receipt represents the results of a transaction.
 type Receipt struct {
 	// Consensus fields
@@ -57,9 +63,32 @@ func (r *Receipt) DecodeRLP(s *rlp.Stream) error {
 		return err
 	}
 	r.PostState, r.CumulativeGasUsed, r.Bloom, r.Logs = receipt.PostState, receipt.CumulativeGasUsed, receipt.Bloom, receipt.Logs
+	if r.HasStatusPostState() {
+		r.Status = TxFailure
+		if len(r.PostState) == 1 && r.PostState[0] == receiptStatusSuccessfulRLP[0] {
+			r.Status = TxSuccess
+		}
+	}
 	return nil
 }
 
+// SetStatusPostState replaces the intermediate state root in the consensus
+// fields of the receipt by its status, as mandated by EIP-658.
+func (r *Receipt) SetStatusPostState() {
+	switch r.Status {
+	case TxSuccess:
+		r.PostState = common.CopyBytes(receiptStatusSuccessfulRLP)
+	case TxFailure:
+		r.PostState = common.CopyBytes(receiptStatusFailedRLP)
+	}
+}
+
+// HasStatusPostState reports whether the consensus post state field of the
+// receipt holds the transaction status (EIP-658) instead of a state root.
+func (r *Receipt) HasStatusPostState() bool {
+	return len(r.PostState) <= 1
+}
+
 // RlpEncode implements common.RlpEncode required for SHA3 derivation.
 func (r *Receipt) RlpEncode() []byte {
 	bytes, err := rlp.EncodeToBytes(r)
@@ -75,22 +104,62 @@ func (r *Receipt) String() string {
 }
 
 // ReceiptForStorage is a wrapper around a Receipt that flattens and parses the
-// entire content of a receipt, as opposed to only the consensus fields originally.
+// content of a receipt that can't be derived from its block, as opposed to only
+// the consensus fields originally.
 type ReceiptForStorage Receipt
 
-// EncodeRLP implements rlp.Encoder, and flattens all content fields of a receipt
-// into an RLP stream.
+// storedReceiptFields is the number of fields of the compact storage encoding,
+// distinguishing it from the earlier full encodings of 7 and 8 fields.
+const storedReceiptFields = 5
+
+// EncodeRLP implements rlp.Encoder, and flattens the consensus fields and the
+// status of a receipt into an RLP stream. The transaction hash, contract address,
+// gas used and log metadata are left out, see DeriveFields.
 func (r *ReceiptForStorage) EncodeRLP(w io.Writer) error {
-	logs := make([]*vm.LogForStorage, len(r.Logs))
-	for i, log := range r.Logs {
-		logs[i] = (*vm.LogForStorage)(log)
-	}
-	return rlp.Encode(w, []interface{}{r.PostState, r.CumulativeGasUsed, r.Bloom, r.TxHash, r.ContractAddress, logs, r.GasUsed, r.Status})
+	return rlp.Encode(w, []interface{}{r.PostState, r.CumulativeGasUsed, r.Bloom, r.Logs, r.Status})
 }
 
-// DecodeRLP implements rlp.Decoder, and loads both consensus and implementation
-// fields of a receipt from an RLP stream.
+// DecodeRLP implements rlp.Decoder, and loads the stored fields of a receipt from
+// an RLP stream. Receipts stored in the compact encoding are left with a nil
+// GasUsed until their derived fields are filled in by DeriveFields, receipts
+// stored in the earlier full encodings are loaded in full.
 func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
+	raw, err := s.Raw()
+	if err != nil {
+		return err
+	}
+	content, _, err := rlp.SplitList(raw)
+	if err != nil {
+		return err
+	}
+	if n, err := rlp.CountValues(content); err != nil {
+		return err
+	} else if n == storedReceiptFields {
+		var receipt struct {
+			PostState         []byte
+			CumulativeGasUsed *big.Int
+			Bloom             Bloom
+			Logs              vm.Logs
+			Status            ReceiptStatus
+		}
+		if err := rlp.DecodeBytes(raw, &receipt); err != nil {
+			return err
+		}
+		*r = ReceiptForStorage{
+			PostState:         receipt.PostState,
+			CumulativeGasUsed: receipt.CumulativeGasUsed,
+			Bloom:             receipt.Bloom,
+			Logs:              receipt.Logs,
+			Status:            receipt.Status,
+		}
+		return nil
+	}
+	return r.decodeFull(raw)
+}
+
+// decodeFull loads a receipt stored in one of the full encodings, which include
+// the fields derivable from the block.
+func (r *ReceiptForStorage) decodeFull(raw []byte) error {
 	var oldReceipt struct {
 		PostState         []byte
 		CumulativeGasUsed *big.Int
@@ -112,11 +181,6 @@ func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
 	}
 	receipt.Status = TxStatusUnknown
 
-	raw, err := s.Raw()
-	if err != nil {
-		return err
-	}
-
 	if err := rlp.DecodeBytes(raw, &receipt); err != nil {
 		if err := rlp.DecodeBytes(raw, &oldReceipt); err != nil {
 			return err
@@ -146,6 +210,43 @@ func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
 // Receipts is a wrapper around a Receipt array to implement types.DerivableList.
 type Receipts []*Receipt
 
+// DeriveFields fills in the fields of the receipts that aren't stored, being
+// derivable from the block with the given hash and number and its transactions:
+// the transaction hashes, contract addresses, gas used and log metadata.
+func (r Receipts) DeriveFields(hash common.Hash, number uint64, txs Transactions) error {
+	if len(txs) != len(r) {
+		return fmt.Errorf("transaction and receipt count mismatch: %d != %d", len(txs), len(r))
+	}
+	var (
+		prevGasUsed = new(big.Int)
+		logIndex    uint
+	)
+	for i, receipt := range r {
+		receipt.TxHash = txs[i].Hash()
+
+		// The contract address is derived from the creator's address and nonce
+		if txs[i].To() == nil {
+			from, err := txs[i].From()
+			if err != nil {
+				return fmt.Errorf("transaction %d: %v", i, err)
+			}
+			receipt.ContractAddress = crypto.CreateAddress(from, txs[i].Nonce())
+		}
+		receipt.GasUsed = new(big.Int).Sub(receipt.CumulativeGasUsed, prevGasUsed)
+		prevGasUsed = receipt.CumulativeGasUsed
+
+		for _, log := range receipt.Logs {
+			log.BlockNumber = number
+			log.BlockHash = hash
+			log.TxHash = receipt.TxHash
+			log.TxIndex = uint(i)
+			log.Index = logIndex
+			logIndex++
+		}
+	}
+	return nil
+}
+
 // Len returns the number of receipts in this list.
 func (r Receipts) Len() int { return len(r) }
 
@@ -0,0 +1,112 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/openether/ethcore/rlp"
+)
+
+// ConsensusReceiptsRLP converts the storage encoding of a list of receipts (see
+// ReceiptForStorage) to their consensus encoding, as sent to peers. Only the
+// encodings of the consensus fields are copied, nothing is decoded.
+func ConsensusReceiptsRLP(stored []byte) (rlp.RawValue, error) {
+	content, _, err := rlp.SplitList(stored)
+	if err != nil {
+		return nil, err
+	}
+	var receipts [][]byte
+	for len(content) > 0 {
+		var receipt []byte
+		if receipt, content, err = splitRaw(content); err != nil {
+			return nil, err
+		}
+		enc, err := consensusReceiptRLP(receipt)
+		if err != nil {
+			return nil, fmt.Errorf("receipt %d: %v", len(receipts), err)
+		}
+		receipts = append(receipts, enc)
+	}
+	return rawList(receipts...), nil
+}
+
+// consensusReceiptRLP converts a stored receipt, either compact [PostState,
+// CumulativeGasUsed, Bloom, Logs, Status] or full [PostState, CumulativeGasUsed,
+// Bloom, TxHash, ContractAddress, Logs, ...], to [PostState, CumulativeGasUsed,
+// Bloom, Logs], keeping only the consensus fields of the logs.
+func consensusReceiptRLP(stored []byte) ([]byte, error) {
+	content, _, err := rlp.SplitList(stored)
+	if err != nil {
+		return nil, err
+	}
+	n, err := rlp.CountValues(content)
+	if err != nil {
+		return nil, err
+	}
+	logsField := 5
+	if n == storedReceiptFields {
+		logsField = 3
+	}
+	fields, err := splitFields(stored, logsField+1)
+	if err != nil {
+		return nil, err
+	}
+	logs, _, err := rlp.SplitList(fields[logsField])
+	if err != nil {
+		return nil, err
+	}
+	var consensusLogs [][]byte
+	for len(logs) > 0 {
+		var log []byte
+		if log, logs, err = splitRaw(logs); err != nil {
+			return nil, err
+		}
+		// Address, Topics and Data come first, see vm.Log
+		logFields, err := splitFields(log, 3)
+		if err != nil {
+			return nil, err
+		}
+		consensusLogs = append(consensusLogs, rawList(logFields...))
+	}
+	return rawList(fields[0], fields[1], fields[2], rawList(consensusLogs...)), nil
+}
+
+// splitFields returns the encodings of the first n elements of an encoded list.
+func splitFields(list []byte, n int) ([][]byte, error) {
+	content, _, err := rlp.SplitList(list)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([][]byte, n)
+	for i := range fields {
+		if len(content) == 0 {
+			return nil, fmt.Errorf("too few list elements, want %d", n)
+		}
+		if fields[i], content, err = splitRaw(content); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// splitRaw splits b into the encoding of its first value and the rest.
+func splitRaw(b []byte) (raw, rest []byte, err error) {
+	if _, _, rest, err = rlp.Split(b); err != nil {
+		return nil, b, err
+	}
+	return b[:len(b)-len(rest)], rest, nil
+}
+
+// rawList encodes a list of already encoded elements.
+func rawList(elems ...[]byte) []byte {
+	size := 0
+	for _, elem := range elems {
+		size += len(elem)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, size+9))
+	rlp.WriteListHeader(buf, uint64(size))
+	for _, elem := range elems {
+		buf.Write(elem)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/vm"
+	"github.com/openether/ethcore/rlp"
+)
+
+func TestConsensusReceiptsRLP(t *testing.T) {
+	receipts := Receipts{
+		&Receipt{
+			PostState:         []byte{1, 2, 3},
+			CumulativeGasUsed: big.NewInt(21000),
+			TxHash:            common.Hash{1},
+			GasUsed:           big.NewInt(21000),
+			Status:            TxSuccess,
+		},
+		&Receipt{
+			PostState:         []byte{4, 5, 6},
+			CumulativeGasUsed: big.NewInt(100000),
+			TxHash:            common.Hash{2},
+			ContractAddress:   common.Address{3},
+			GasUsed:           big.NewInt(79000),
+			Logs: vm.Logs{
+				{Address: common.Address{4}, Topics: []common.Hash{{5}, {6}}, Data: []byte("data"), BlockNumber: 7, TxHash: common.Hash{2}, TxIndex: 1, BlockHash: common.Hash{8}, Index: 2},
+				{Address: common.Address{9}},
+			},
+		},
+	}
+	receipts[1].Bloom = CreateBloom(Receipts{receipts[1]})
+
+	storage := make([]*ReceiptForStorage, len(receipts))
+	for i, receipt := range receipts {
+		storage[i] = (*ReceiptForStorage)(receipt)
+	}
+	stored, err := rlp.EncodeToBytes(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := rlp.EncodeToBytes(receipts)
+
+	got, err := ConsensusReceiptsRLP(stored)
+	if err != nil {
+		t.Fatalf("conversion failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("wrong consensus encoding:\ngot  %x\nwant %x", got, want)
+	}
+	if got, err := ConsensusReceiptsRLP(rlp.EmptyList); err != nil || !bytes.Equal(got, rlp.EmptyList) {
+		t.Errorf("wrong encoding of no receipts: %x, %v", got, err)
+	}
+	if _, err := ConsensusReceiptsRLP([]byte{0xC2, 0xC1, 0x01}); err == nil {
+		t.Errorf("expected error for malformed receipt")
+	}
+}
@@ -0,0 +1,149 @@
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/vm"
+	"github.com/openether/ethcore/crypto"
+	"github.com/openether/ethcore/rlp"
+)
+
+// Tests that receipts carrying their status in the consensus post state field
+// (EIP-658) round trip through the consensus encoding, while receipts carrying
+// an intermediate state root are left untouched.
+func TestReceiptStatusPostState(t *testing.T) {
+	root := common.HexToHash("0x01").Bytes()
+
+	tests := []struct {
+		status    ReceiptStatus
+		eip658    bool
+		postState []byte
+		decoded   ReceiptStatus
+	}{
+		{TxSuccess, false, root, TxFailure}, // status is not part of the consensus encoding
+		{TxSuccess, true, []byte{0x01}, TxSuccess},
+		{TxFailure, true, []byte{}, TxFailure},
+	}
+	for i, tt := range tests {
+		receipt := NewReceipt(root, big.NewInt(21000))
+		receipt.Status = tt.status
+		if tt.eip658 {
+			receipt.SetStatusPostState()
+		}
+		if !bytes.Equal(receipt.PostState, tt.postState) {
+			t.Errorf("test %d: post state mismatch: have %x, want %x", i, receipt.PostState, tt.postState)
+		}
+		if receipt.HasStatusPostState() != tt.eip658 {
+			t.Errorf("test %d: status post state mismatch: have %v, want %v", i, receipt.HasStatusPostState(), tt.eip658)
+		}
+		enc, err := rlp.EncodeToBytes(receipt)
+		if err != nil {
+			t.Fatalf("test %d: failed to encode receipt: %v", i, err)
+		}
+		dec := new(Receipt)
+		if err := rlp.DecodeBytes(enc, dec); err != nil {
+			t.Fatalf("test %d: failed to decode receipt: %v", i, err)
+		}
+		if dec.Status != tt.decoded {
+			t.Errorf("test %d: decoded status mismatch: have %d, want %d", i, dec.Status, tt.decoded)
+		}
+	}
+}
+
+// Tests that receipts are stored without the fields derivable from their block,
+// that DeriveFields restores them, and that receipts stored in the earlier full
+// encoding still load in full.
+func TestReceiptStorageCompact(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	transfer, _ := NewTransaction(0, common.Address{1}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(key)
+	creation, _ := NewContractCreation(1, big.NewInt(0), big.NewInt(100000), big.NewInt(1), []byte{0x60}).SignECDSA(key)
+	txs := Transactions{transfer, creation}
+
+	blockHash, blockNumber := common.Hash{0xbb}, uint64(42)
+	receipts := Receipts{
+		{
+			PostState:         []byte{0x01},
+			CumulativeGasUsed: big.NewInt(21000),
+			Logs:              vm.Logs{{Address: common.Address{2}, Topics: []common.Hash{{3}}, Data: []byte{4}}},
+			Status:            TxSuccess,
+		},
+		{
+			PostState:         []byte{},
+			CumulativeGasUsed: big.NewInt(71000),
+			Logs:              vm.Logs{{Address: common.Address{5}}, {Address: common.Address{6}}},
+			Status:            TxFailure,
+		},
+	}
+	for _, receipt := range receipts {
+		receipt.Bloom = CreateBloom(Receipts{receipt})
+	}
+	if err := receipts.DeriveFields(blockHash, blockNumber, txs); err != nil {
+		t.Fatalf("failed to derive fields: %v", err)
+	}
+	if receipts[0].GasUsed.Cmp(big.NewInt(21000)) != 0 || receipts[1].GasUsed.Cmp(big.NewInt(50000)) != 0 {
+		t.Fatalf("gas used mismatch: %v, %v", receipts[0].GasUsed, receipts[1].GasUsed)
+	}
+	if want := crypto.CreateAddress(from, 1); receipts[1].ContractAddress != want {
+		t.Fatalf("contract address mismatch: have %x, want %x", receipts[1].ContractAddress, want)
+	}
+	if log := receipts[1].Logs[1]; log.BlockNumber != blockNumber || log.BlockHash != blockHash || log.TxHash != creation.Hash() || log.TxIndex != 1 || log.Index != 2 {
+		t.Fatalf("log metadata mismatch: %+v", log)
+	}
+	// Store compact and full, and compare what's loaded back
+	full := make([]interface{}, len(receipts))
+	compact := make([]*ReceiptForStorage, len(receipts))
+	for i, r := range receipts {
+		logs := make([]*vm.LogForStorage, len(r.Logs))
+		for j, log := range r.Logs {
+			logs[j] = (*vm.LogForStorage)(log)
+		}
+		full[i] = []interface{}{r.PostState, r.CumulativeGasUsed, r.Bloom, r.TxHash, r.ContractAddress, logs, r.GasUsed, r.Status}
+		compact[i] = (*ReceiptForStorage)(r)
+	}
+	fullEnc, _ := rlp.EncodeToBytes(full)
+	compactEnc, err := rlp.EncodeToBytes(compact)
+	if err != nil {
+		t.Fatalf("failed to encode receipts: %v", err)
+	}
+	if len(compactEnc) >= len(fullEnc) {
+		t.Errorf("compact encoding not smaller: %d >= %d bytes", len(compactEnc), len(fullEnc))
+	}
+	for _, enc := range [][]byte{fullEnc, compactEnc} {
+		var stored []*ReceiptForStorage
+		if err := rlp.DecodeBytes(enc, &stored); err != nil {
+			t.Fatalf("failed to decode receipts: %v", err)
+		}
+		loaded := make(Receipts, len(stored))
+		for i, r := range stored {
+			loaded[i] = (*Receipt)(r)
+		}
+		if loaded[0].GasUsed == nil {
+			if err := loaded.DeriveFields(blockHash, blockNumber, txs); err != nil {
+				t.Fatalf("failed to derive fields: %v", err)
+			}
+		}
+		for i := range receipts {
+			want, _ := rlp.EncodeToBytes(full[i])
+			r := loaded[i]
+			logs := make([]*vm.LogForStorage, len(r.Logs))
+			for j, log := range r.Logs {
+				logs[j] = (*vm.LogForStorage)(log)
+			}
+			have, _ := rlp.EncodeToBytes([]interface{}{r.PostState, r.CumulativeGasUsed, r.Bloom, r.TxHash, r.ContractAddress, logs, r.GasUsed, r.Status})
+			if !bytes.Equal(have, want) {
+				t.Errorf("receipt %d mismatch after loading", i)
+			}
+		}
+	}
+	// The consensus encoding is served from either storage encoding
+	consensus, _ := rlp.EncodeToBytes(receipts)
+	for _, enc := range [][]byte{fullEnc, compactEnc} {
+		if got, err := ConsensusReceiptsRLP(enc); err != nil || !bytes.Equal(got, consensus) {
+			t.Errorf("consensus encoding mismatch: %v", err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package types
+
+import (
+	"sync/atomic"
+
+	"github.com/hashicorp/golang-lru"
+)
+
+// senderCacheSize is the number of transaction senders kept in the shared cache.
+const senderCacheSize = 32768
+
+// senderCache maps transaction hashes to their recovered sender, shared by the
+// transaction pool, block processing and the RPC APIs, which each work on their
+// own copies of transactions.
+var senderCache, _ = lru.New(senderCacheSize)
+
+// senderCacheHits and senderCacheMisses count the Sender calls served from a
+// cache and recovering the sender from the signature.
+var senderCacheHits, senderCacheMisses uint64
+
+// SenderCacheStats returns the number of senders retrieved from a cache, and the
+// number recovered from the signature.
+func SenderCacheStats() (hits, misses uint64) {
+	return atomic.LoadUint64(&senderCacheHits), atomic.LoadUint64(&senderCacheMisses)
+}
+
+// SenderCacheSize returns the number of senders in the shared cache, and its limit.
+func SenderCacheSize() (entries, limit int) {
+	return senderCache.Len(), senderCacheSize
+}
@@ -0,0 +1,40 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/rlp"
+)
+
+func TestSharedSenderCache(t *testing.T) {
+	key, addr := defaultTestKey()
+	signer := NewChainIdSigner(big.NewInt(61))
+
+	tx := NewTransaction(7, common.Address{1}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), []byte("shared sender cache"))
+	tx, err := signer.SignECDSA(tx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from, err := Sender(signer, tx); err != nil || from != addr {
+		t.Fatalf("wrong sender: %x, %v", from, err)
+	}
+	// A copy of the transaction, eg. read from the database, doesn't recover again
+	enc, _ := rlp.EncodeToBytes(tx)
+	cpy := new(Transaction)
+	if err := rlp.DecodeBytes(enc, cpy); err != nil {
+		t.Fatal(err)
+	}
+	_, misses := SenderCacheStats()
+	if from, err := Sender(signer, cpy); err != nil || from != addr {
+		t.Fatalf("wrong sender of copy: %x, %v", from, err)
+	}
+	if _, after := SenderCacheStats(); after != misses {
+		t.Errorf("sender of copy recovered again")
+	}
+	// The cached sender isn't used with a different signer
+	if _, err := Sender(NewChainIdSigner(big.NewInt(62)), cpy); err != ErrInvalidChainId {
+		t.Errorf("expected invalid chain id error, got %v", err)
+	}
+}
@@ -110,7 +110,18 @@ func deriveSigner(V *big.Int) Signer {
 }
 
 func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
-	_, size, _ := s.Kind()
+	kind, size, _ := s.Kind()
+	if kind == rlp.String {
+		// Typed transactions (EIP-2718) are embedded as byte strings in lists
+		b, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		if !IsTypedTxEnvelope(b) {
+			return rlp.ErrExpectedList
+		}
+		return decodeTypedTx(b[0], b[1:])
+	}
 	err := s.Decode(&tx.data)
 	if err == nil {
 		tx.size.Store(common.StorageSize(rlp.ListSize(size)))
@@ -0,0 +1,123 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/rlp"
+)
+
+// Typed transaction envelope types, see EIP-2718. Legacy transactions are not
+// enveloped; their encoding is a plain RLP list.
+const (
+	AccessListTxType = 0x01 // EIP-2930
+	DynamicFeeTxType = 0x02 // EIP-1559
+)
+
+// maxTxType is the highest byte that can start a typed transaction envelope;
+// anything above it is the start of an RLP list, ie. a legacy transaction.
+const maxTxType = 0x7f
+
+var errEmptyTx = errors.New("empty transaction")
+
+// TxTypeError is returned when decoding a typed transaction envelope whose type
+// isn't supported on this chain.
+type TxTypeError struct {
+	Type byte
+}
+
+func (e *TxTypeError) Error() string {
+	if name := txTypeName(e.Type); name != "" {
+		return fmt.Sprintf("transaction type %#02x (%s) not supported on this chain", e.Type, name)
+	}
+	return fmt.Sprintf("transaction type %#02x not supported on this chain", e.Type)
+}
+
+func txTypeName(t byte) string {
+	switch t {
+	case AccessListTxType:
+		return "EIP-2930 access list"
+	case DynamicFeeTxType:
+		return "EIP-1559 dynamic fee"
+	default:
+		return ""
+	}
+}
+
+// IsTypedTxEnvelope reports whether the given binary encoding of a transaction
+// is a typed transaction envelope rather than a legacy RLP transaction.
+func IsTypedTxEnvelope(b []byte) bool {
+	return len(b) > 0 && b[0] <= maxTxType
+}
+
+// DecodeTransaction decodes the binary encoding of a transaction, as submitted
+// through eth_sendRawTransaction. Legacy RLP transactions are decoded as usual.
+// Typed transaction envelopes are decoded to make sure they are well formed, and
+// rejected with a *TxTypeError, since no transaction type is enabled on this
+// chain.
+func DecodeTransaction(b []byte) (*Transaction, error) {
+	if len(b) == 0 {
+		return nil, errEmptyTx
+	}
+	if !IsTypedTxEnvelope(b) {
+		tx := new(Transaction)
+		if err := rlp.DecodeBytes(b, tx); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+	return nil, decodeTypedTx(b[0], b[1:])
+}
+
+// decodeTypedTx validates the payload of a typed transaction envelope and
+// returns the error explaining why it was rejected.
+func decodeTypedTx(t byte, payload []byte) error {
+	var inner interface{}
+	switch t {
+	case AccessListTxType:
+		inner = new(accessListTxData)
+	case DynamicFeeTxType:
+		inner = new(dynamicFeeTxData)
+	default:
+		return &TxTypeError{Type: t}
+	}
+	if err := rlp.DecodeBytes(payload, inner); err != nil {
+		return fmt.Errorf("invalid %s transaction: %v", txTypeName(t), err)
+	}
+	return &TxTypeError{Type: t}
+}
+
+// accessTuple is an entry of an EIP-2930 access list.
+type accessTuple struct {
+	Address     common.Address
+	StorageKeys []common.Hash
+}
+
+// accessListTxData is the payload of an EIP-2930 transaction envelope.
+type accessListTxData struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasPrice   *big.Int
+	Gas        uint64
+	To         *common.Address `rlp:"nil"`
+	Value      *big.Int
+	Data       []byte
+	AccessList []accessTuple
+	V, R, S    *big.Int
+}
+
+// dynamicFeeTxData is the payload of an EIP-1559 transaction envelope.
+type dynamicFeeTxData struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        uint64
+	To         *common.Address `rlp:"nil"`
+	Value      *big.Int
+	Data       []byte
+	AccessList []accessTuple
+	V, R, S    *big.Int
+}
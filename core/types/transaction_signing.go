@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sync/atomic"
 
 	"github.com/openether/ethcore/common"
 	"github.com/openether/ethcore/crypto"
@@ -61,7 +62,8 @@ type sigCache struct {
 // signature.
 //
 // From may cache the address, allowing it to be used regardless of
-// signing method.
+// signing method. Senders are also kept in a cache shared by all copies
+// of a transaction, so each sender is recovered only once.
 func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 	if sc := tx.from.Load(); sc != nil {
 		sigCache := sc.(sigCache)
@@ -69,9 +71,17 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 		// call is not the same as used current, invalidate
 		// the cache.
 		if sigCache.signer.Equal(signer) {
+			atomic.AddUint64(&senderCacheHits, 1)
 			return sigCache.from, nil
 		}
 	}
+	hash := tx.Hash()
+	if sc, ok := senderCache.Get(hash); ok && sc.(sigCache).signer.Equal(signer) {
+		atomic.AddUint64(&senderCacheHits, 1)
+		tx.from.Store(sc)
+		return sc.(sigCache).from, nil
+	}
+	atomic.AddUint64(&senderCacheMisses, 1)
 
 	pubkey, err := signer.PublicKey(tx)
 	if err != nil {
@@ -80,6 +90,7 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 	var addr common.Address
 	copy(addr[:], crypto.Keccak256(pubkey[1:])[12:])
 	tx.from.Store(sigCache{signer: signer, from: addr})
+	senderCache.Add(hash, sigCache{signer: signer, from: addr})
 	return addr, nil
 }
 
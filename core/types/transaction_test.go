@@ -118,6 +118,51 @@ func TestRecipientNormal(t *testing.T) {
 	}
 }
 
+// Tests that typed transaction envelopes are recognized and rejected with a
+// precise error, while legacy transactions still decode.
+func TestDecodeTransactionEnvelope(t *testing.T) {
+	legacy, err := rlp.EncodeToBytes(rightvrsTx)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	tx, err := DecodeTransaction(legacy)
+	if err != nil {
+		t.Fatalf("legacy decode error: %v", err)
+	}
+	if tx.Hash() != rightvrsTx.Hash() {
+		t.Errorf("legacy hash mismatch: have %x, want %x", tx.Hash(), rightvrsTx.Hash())
+	}
+
+	to := common.HexToAddress("b94f5374fce5edbc8e2a8697c15331677e6ebf0b")
+	payload, err := rlp.EncodeToBytes(&dynamicFeeTxData{
+		ChainID: big.NewInt(61), Nonce: 3, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2),
+		Gas: 21000, To: &to, Value: big.NewInt(10), V: big.NewInt(0), R: big.NewInt(1), S: big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	envelope := append([]byte{DynamicFeeTxType}, payload...)
+
+	_, err = DecodeTransaction(envelope)
+	if typeErr, ok := err.(*TxTypeError); !ok || typeErr.Type != DynamicFeeTxType {
+		t.Errorf("dynamic fee envelope: have error %v, want type error", err)
+	}
+	if _, err := DecodeTransaction(append([]byte{DynamicFeeTxType}, 0xc1, 0x01)); err == nil {
+		t.Error("malformed dynamic fee envelope: expected error")
+	} else if _, ok := err.(*TxTypeError); ok {
+		t.Errorf("malformed dynamic fee envelope: have type error %v, want decoding error", err)
+	}
+	if _, err := DecodeTransaction([]byte{0x05, 0xc0}); err == nil {
+		t.Error("unknown envelope type: expected error")
+	}
+	// Typed transactions embedded in a list, as relayed on the wire
+	wire, _ := rlp.EncodeToBytes([]interface{}{envelope})
+	var txs []*Transaction
+	if err := rlp.DecodeBytes(wire, &txs); err == nil {
+		t.Error("embedded envelope: expected error")
+	}
+}
+
 // Tests that transactions can be correctly sorted according to their price in
 // decreasing order, but at the same time with increasing nonces when issued by
 // the same account.
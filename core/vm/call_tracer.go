@@ -0,0 +1,191 @@
+package vm
+
+import (
+	"math/big"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/crypto"
+)
+
+// CallFrame is a message call, contract creation or suicide made during the
+// execution of a transaction.
+type CallFrame struct {
+	Op      OpCode // CALL, CALLCODE, DELEGATECALL, CREATE or SUICIDE
+	From    common.Address
+	To      common.Address // Called account, created contract or suicide beneficiary
+	Value   *big.Int       // Value transferred, the balance sent for suicides
+	Gas     *big.Int       // Gas available to the callee
+	GasUsed *big.Int       // Gas used by the callee
+	Input   []byte         // Call data or init code
+	Output  []byte         // Data returned, the code deployed for creates
+	Failed  bool
+
+	TraceAddress []int // Indices of the frame and its callers among their siblings, outermost first
+	Subtraces    int   // Number of calls made directly by the frame
+}
+
+// openFrame is a call whose outcome isn't known yet.
+type openFrame struct {
+	frame             *CallFrame
+	depth             int      // Call depth of the callee's instructions
+	lastGas, lastCost *big.Int // Of the last instruction the callee executed
+}
+
+// settleGas sets the gas used by the frame from the last instruction it executed.
+func (f *openFrame) settleGas() {
+	frame := f.frame
+	if frame.Gas == nil {
+		// The callee executed no code, e.g. a plain transfer
+		frame.Gas = new(big.Int).Set(f.lastGas)
+		frame.GasUsed = new(big.Int)
+		return
+	}
+	frame.GasUsed = new(big.Int).Sub(frame.Gas, new(big.Int).Sub(f.lastGas, f.lastCost))
+	if frame.GasUsed.Sign() < 0 {
+		frame.GasUsed.SetInt64(0)
+	}
+}
+
+// CallTracer is a Tracer collecting the calls, creates and suicides made by
+// the contracts of a single transaction, with the calls they nest. The outcome
+// of a call is taken from the result the caller finds on its stack once
+// execution returns to it.
+type CallTracer struct {
+	open   []*openFrame // The transaction's own frame at the bottom, innermost call at the top
+	frames []*CallFrame // The transaction's own frame first
+}
+
+// NewCallTracer creates a tracer collecting calls.
+func NewCallTracer() *CallTracer {
+	t := &CallTracer{}
+	t.reset()
+	return t
+}
+
+func (t *CallTracer) reset() {
+	root := &openFrame{frame: &CallFrame{TraceAddress: []int{}}, depth: 1, lastGas: new(big.Int), lastCost: new(big.Int)}
+	t.open = []*openFrame{root}
+	t.frames = []*CallFrame{root.frame}
+}
+
+// CaptureState implements Tracer, resolving the calls that returned and opening
+// the call the instruction is about to make.
+func (t *CallTracer) CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int, err error) {
+	// Back in a calling frame, the calls made from it and deeper have returned
+	for len(t.open) > 1 && t.open[len(t.open)-1].depth > depth {
+		t.close(stack, depth)
+	}
+	top := t.open[len(t.open)-1]
+	if top.depth == depth {
+		if top.frame.Gas == nil {
+			top.frame.Gas = new(big.Int).Set(gas)
+		}
+		// The gas is updated in place as the instruction executes
+		top.lastGas, top.lastCost = copyBig(gas), copyBig(cost)
+	}
+	if err != nil {
+		return
+	}
+	operand := func(n int) *big.Int {
+		if n >= len(stack) {
+			return new(big.Int)
+		}
+		return stack[len(stack)-1-n]
+	}
+	self := contract.Address()
+	switch op {
+	case CALL, CALLCODE:
+		t.push(&CallFrame{Op: op, From: self, To: common.BigToAddress(operand(1)), Value: new(big.Int).Set(operand(2)),
+			Input: memorySlice(memory, operand(3), operand(4))}, depth, operand(0))
+	case DELEGATECALL:
+		t.push(&CallFrame{Op: op, From: self, To: common.BigToAddress(operand(1)), Value: new(big.Int),
+			Input: memorySlice(memory, operand(2), operand(3))}, depth, operand(0))
+	case CREATE:
+		t.push(&CallFrame{Op: op, From: self, To: crypto.CreateAddress(self, env.Db().GetNonce(self)), Value: new(big.Int).Set(operand(0)),
+			Input: memorySlice(memory, operand(1), operand(2))}, depth, gas)
+	case SUICIDE:
+		t.add(&CallFrame{Op: op, From: self, To: common.BigToAddress(operand(0)), Value: new(big.Int).Set(env.Db().GetBalance(self)),
+			Gas: new(big.Int), GasUsed: new(big.Int)})
+	case RETURN:
+		if top.depth == depth {
+			top.frame.Output = memorySlice(memory, operand(0), operand(1))
+		}
+	}
+}
+
+// add records a frame as called by the innermost open frame.
+func (t *CallTracer) add(frame *CallFrame) {
+	caller := t.open[len(t.open)-1].frame
+	frame.TraceAddress = append(append([]int{}, caller.TraceAddress...), caller.Subtraces)
+	caller.Subtraces++
+	t.frames = append(t.frames, frame)
+}
+
+func (t *CallTracer) push(frame *CallFrame, depth int, gas *big.Int) {
+	t.add(frame)
+	t.open = append(t.open, &openFrame{
+		frame:    frame,
+		depth:    depth + 1,
+		lastGas:  new(big.Int).Set(gas),
+		lastCost: new(big.Int),
+	})
+}
+
+// close resolves the innermost open call from the stack of its caller after it
+// returned. Without a caller's stack, the call is taken to have succeeded.
+func (t *CallTracer) close(stack []*big.Int, depth int) {
+	call := t.open[len(t.open)-1]
+	t.open = t.open[:len(t.open)-1]
+	call.settleGas()
+
+	// The caller resumes only if the call was made from right above it
+	if len(stack) == 0 || call.depth != depth+1 {
+		return
+	}
+	frame, result := call.frame, stack[len(stack)-1]
+	if frame.Failed = result.Sign() == 0; frame.Failed {
+		frame.GasUsed.Set(frame.Gas)
+		frame.Output = nil
+		return
+	}
+	if frame.Op == CREATE {
+		frame.To = common.BigToAddress(result)
+	}
+}
+
+// Finish returns the frames of the traced transaction in the order they were
+// entered, the transaction's own frame first, and resets the tracer for the next
+// transaction. Only the gas, output and subtraces of the transaction's frame are
+// set, and its gas is nil if no code was executed. Calls still open when the
+// transaction ended count as succeeded unless the transaction failed.
+func (t *CallTracer) Finish(succeeded bool) []*CallFrame {
+	for len(t.open) > 1 {
+		frame := t.open[len(t.open)-1].frame
+		t.close(nil, 0)
+		frame.Failed = !succeeded
+	}
+	if root := t.open[0]; root.frame.Gas != nil {
+		root.settleGas()
+	}
+	if t.frames[0].Failed = !succeeded; t.frames[0].Failed {
+		t.frames[0].Output = nil
+	}
+
+	frames := t.frames
+	t.reset()
+	return frames
+}
+
+// memorySlice copies size bytes of memory at offset, zero padded beyond the
+// memory's current size.
+func memorySlice(memory *Memory, offset, size *big.Int) []byte {
+	if size.Sign() == 0 || !offset.IsUint64() || !size.IsUint64() {
+		return nil
+	}
+	out := make([]byte, size.Uint64())
+	data := memory.Data()
+	if off := offset.Uint64(); off < uint64(len(data)) {
+		copy(out, data[off:])
+	}
+	return out
+}
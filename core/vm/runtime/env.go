@@ -57,6 +57,9 @@ func NewEnv(cfg *Config, state *state.StateDB) vm.Environment {
 		gasLimit:   cfg.GasLimit,
 	}
 	env.evm = vm.New(env)
+	if cfg.Tracer != nil {
+		env.evm.SetTracer(cfg.Tracer)
+	}
 
 	return env
 }
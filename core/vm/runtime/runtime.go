@@ -59,6 +59,7 @@ type Config struct {
 	Value       *big.Int
 	DisableJit  bool // "disable" so it's enabled by default
 	Debug       bool
+	Tracer      vm.Tracer
 
 	State     *state.StateDB
 	GetHashFn func(n uint64) common.Hash
@@ -17,6 +17,9 @@
 package runtime
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"math/big"
 	"strings"
 	"testing"
@@ -93,6 +96,245 @@ func TestExecute(t *testing.T) {
 	}
 }
 
+func TestJSONLogger(t *testing.T) {
+	var out bytes.Buffer
+	_, _, err := Execute([]byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 2,
+		byte(vm.ADD),
+		byte(vm.STOP),
+	}, nil, &Config{Tracer: vm.NewJSONLogger(&out)})
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+
+	var ops []string
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var entry struct {
+			Pc     uint64   `json:"pc"`
+			OpName string   `json:"opName"`
+			Stack  []string `json:"stack"`
+			Depth  int      `json:"depth"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid trace line %q: %v", scanner.Text(), err)
+		}
+		if entry.Depth != 1 {
+			t.Errorf("%s: depth mismatch: got %d, want 1", entry.OpName, entry.Depth)
+		}
+		ops = append(ops, entry.OpName)
+		if entry.OpName == "STOP" && (len(entry.Stack) != 1 || entry.Stack[0] != "0x3") {
+			t.Errorf("stack mismatch before STOP: got %v, want [0x3]", entry.Stack)
+		}
+	}
+	if want := []string{"PUSH1", "PUSH1", "ADD", "STOP"}; strings.Join(ops, ",") != strings.Join(want, ",") {
+		t.Errorf("traced ops mismatch: got %v, want %v", ops, want)
+	}
+}
+
+func TestStructLogger(t *testing.T) {
+	code := []byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 2,
+		byte(vm.ADD),
+		byte(vm.STOP),
+	}
+	tracer := vm.NewStructLogger(0)
+	if _, _, err := Execute(code, nil, &Config{Tracer: tracer}); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	logs := tracer.StructLogs()
+	if len(logs) != 4 {
+		t.Fatalf("log count mismatch: got %d, want 4", len(logs))
+	}
+	if last := logs[3]; last.Op != "STOP" || len(last.Stack) != 1 || last.Stack[0] != "0x3" {
+		t.Errorf("last step mismatch: got %+v", last)
+	}
+
+	tracer = vm.NewStructLogger(2)
+	if _, _, err := Execute(code, nil, &Config{Tracer: tracer}); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	if len(tracer.StructLogs()) != 2 || tracer.Dropped() != 2 {
+		t.Errorf("limit not applied: got %d logs, %d dropped", len(tracer.StructLogs()), tracer.Dropped())
+	}
+}
+
+func TestStructLoggerConfig(t *testing.T) {
+	code := []byte{
+		byte(vm.PUSH1), 0x2a,
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 7,
+		byte(vm.PUSH1), 1,
+		byte(vm.SSTORE),
+		byte(vm.STOP),
+	}
+	tracer := vm.NewStructLoggerConfig(vm.LogConfig{DisableStack: true, EnableMemory: true, EnableStorage: true})
+	if _, _, err := Execute(code, nil, &Config{Tracer: tracer}); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	logs := tracer.StructLogs()
+	if len(logs) != 7 {
+		t.Fatalf("log count mismatch: got %d, want 7", len(logs))
+	}
+	last := logs[6]
+	if last.Stack != nil {
+		t.Errorf("stack captured though disabled: %v", last.Stack)
+	}
+	if len(last.Memory) != 1 || !strings.HasSuffix(last.Memory[0], "2a") {
+		t.Errorf("memory mismatch: got %v", last.Memory)
+	}
+	// Only the SSTORE carries the slot it writes
+	key, value := common.BigToHash(common.Big1).Hex(), common.BigToHash(big.NewInt(7)).Hex()
+	if sstore := logs[5]; len(sstore.Storage) != 1 || sstore.Storage[key] != value {
+		t.Errorf("storage mismatch: got %v, want %s: %s", sstore.Storage, key, value)
+	}
+	if last.Storage != nil {
+		t.Errorf("storage captured for STOP: %v", last.Storage)
+	}
+}
+
+func TestStructLoggerMaxSize(t *testing.T) {
+	code := []byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 2,
+		byte(vm.PUSH1), 3,
+		byte(vm.STOP),
+	}
+	// The second instruction captures a stack item, reaching the limit
+	tracer := vm.NewStructLoggerConfig(vm.LogConfig{MaxSize: 32})
+	if _, _, err := Execute(code, nil, &Config{Tracer: tracer}); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	if len(tracer.StructLogs()) != 2 || tracer.Dropped() != 2 {
+		t.Errorf("size limit not applied: got %d logs, %d dropped", len(tracer.StructLogs()), tracer.Dropped())
+	}
+}
+
+func TestCallTracer(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	callee := common.HexToAddress("0x0b")
+	state.SetCode(callee, []byte{
+		byte(vm.PUSH1), 10,
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	})
+	code := []byte{
+		byte(vm.PUSH1), 32, // retSize
+		byte(vm.PUSH1), 0, // retOffset
+		byte(vm.PUSH1), 0, // inSize
+		byte(vm.PUSH1), 0, // inOffset
+		byte(vm.PUSH1), 0, // value
+		byte(vm.PUSH1), 0x0b, // address
+		byte(vm.PUSH2), 0xff, 0xff, // gas
+		byte(vm.CALL),
+		byte(vm.STOP),
+	}
+	tracer := vm.NewCallTracer()
+	if _, _, err := Execute(code, nil, &Config{State: state, Tracer: tracer}); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	frames := tracer.Finish(true)
+	if len(frames) != 2 {
+		t.Fatalf("frame count mismatch: got %d, want 2", len(frames))
+	}
+	if root := frames[0]; root.Subtraces != 1 || len(root.TraceAddress) != 0 || root.Failed {
+		t.Errorf("transaction frame mismatch: %d subtraces, trace address %v, failed %v", root.Subtraces, root.TraceAddress, root.Failed)
+	}
+	call := frames[1]
+	if call.Op != vm.CALL || call.From != common.StringToAddress("contract") || call.To != callee {
+		t.Errorf("call mismatch: got %v from %x to %x", call.Op, call.From, call.To)
+	}
+	if call.Failed {
+		t.Error("call reported failed")
+	}
+	if call.GasUsed.Sign() <= 0 || call.GasUsed.Cmp(call.Gas) >= 0 {
+		t.Errorf("gas used %v out of range (0, %v)", call.GasUsed, call.Gas)
+	}
+	if num := new(big.Int).SetBytes(call.Output); num.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("output mismatch: got %x", call.Output)
+	}
+	if len(call.TraceAddress) != 1 || call.TraceAddress[0] != 0 || call.Subtraces != 0 {
+		t.Errorf("position mismatch: trace address %v, %d subtraces", call.TraceAddress, call.Subtraces)
+	}
+}
+
+func TestCallTracerGasUsed(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	var (
+		returner = common.HexToAddress("0x0b")
+		failing  = common.HexToAddress("0x0c")
+	)
+	// PUSH1 32 PUSH1 0 RETURN, the RETURN paying for memory expansion
+	state.SetCode(returner, common.Hex2Bytes("60206000f3"))
+	// ADD on an empty stack, failing before its cost is known
+	state.SetCode(failing, common.Hex2Bytes("01"))
+	code := common.Hex2Bytes(
+		"6000600060006000600060" + "0b" + "61ffff" + "f1" + "50" +
+			"6000600060006000600060" + "0c" + "61ffff" + "f1" + "50" +
+			"00")
+
+	tracer := vm.NewCallTracer()
+	if _, _, err := Execute(code, nil, &Config{State: state, Tracer: tracer}); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	frames := tracer.Finish(true)
+	if len(frames) != 3 {
+		t.Fatalf("frame count mismatch: got %d, want 3", len(frames))
+	}
+	if call := frames[1]; call.Failed || call.GasUsed.Cmp(big.NewInt(9)) != 0 {
+		t.Errorf("returning call mismatch: failed %v, gas used %v, want 9", call.Failed, call.GasUsed)
+	}
+	if call := frames[2]; !call.Failed || call.GasUsed.Cmp(call.Gas) != 0 {
+		t.Errorf("failing call mismatch: failed %v, gas used %v of %v", call.Failed, call.GasUsed, call.Gas)
+	}
+	if frames[0].Failed {
+		t.Error("transaction frame reported failed")
+	}
+}
+
+func TestTransferTracer(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	var (
+		sender    = common.HexToAddress("0x0a")
+		forwarder = common.HexToAddress("0x0b")
+		receiver  = common.HexToAddress("0x0d")
+	)
+	state.AddBalance(sender, big.NewInt(1000))
+	// Sends 100 wei to the forwarder, then 10 wei to the receiver
+	state.SetCode(sender, common.Hex2Bytes(
+		"6000600060006000606460"+"0b"+"61ffff"+"f1"+"50"+
+			"6000600060006000600a60"+"0d"+"61ffff"+"f1"+"50"+
+			"00"))
+	// Forwards 50 wei, then fails, reverting both transfers
+	state.SetCode(forwarder, common.Hex2Bytes(
+		"6000600060006000603260"+"0c"+"611000"+"f1"+"50"+
+			"fe"))
+
+	tracer := vm.NewTransferTracer()
+	if _, err := Call(sender, nil, &Config{State: state, Tracer: tracer}); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	transfers := tracer.Finish(true)
+	if len(transfers) != 1 {
+		t.Fatalf("transfer count mismatch: got %d, want 1: %+v", len(transfers), transfers)
+	}
+	if tr := transfers[0]; tr.From != sender || tr.To != receiver || tr.Value.Cmp(big.NewInt(10)) != 0 || tr.Op != vm.CALL || tr.Depth != 1 {
+		t.Errorf("transfer mismatch: %+v", tr)
+	}
+	if transfers := tracer.Finish(false); transfers != nil {
+		t.Errorf("transfers of a failed transaction: %+v", transfers)
+	}
+}
+
 func TestCall(t *testing.T) {
 	db, _ := ethdb.NewMemDatabase()
 	state, _ := state.New(common.Hash{}, state.NewDatabase(db))
@@ -117,6 +359,30 @@ func TestCall(t *testing.T) {
 	}
 }
 
+// cancelingTracer cancels the execution after a number of instructions.
+type cancelingTracer struct {
+	steps, limit int
+}
+
+func (t *cancelingTracer) CaptureState(env vm.Environment, pc uint64, op vm.OpCode, gas, cost *big.Int, memory *vm.Memory, stack []*big.Int, contract *vm.Contract, depth int, err error) {
+	if t.steps++; t.steps == t.limit {
+		env.Vm().(*vm.EVM).Cancel()
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tracer := &cancelingTracer{limit: 100}
+	loop := []byte{byte(vm.JUMPDEST), byte(vm.PUSH1), 0, byte(vm.JUMP)}
+
+	_, _, err := Execute(loop, nil, &Config{GasLimit: big.NewInt(1000000), Tracer: tracer})
+	if err != vm.ErrExecutionCanceled {
+		t.Fatalf("error mismatch: have %v, want %v", err, vm.ErrExecutionCanceled)
+	}
+	if tracer.steps != tracer.limit {
+		t.Errorf("executed %d instructions after canceling at %d", tracer.steps, tracer.limit)
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
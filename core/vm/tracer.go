@@ -0,0 +1,323 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/openether/ethcore/common"
+)
+
+// Tracer is notified by the EVM about every instruction it is about to execute,
+// across all call depths, which allows collecting execution traces.
+type Tracer interface {
+	// CaptureState is called before the instruction op at pc is executed, with the
+	// gas available before and the cost of executing the instruction. If err is
+	// not nil the instruction can't be executed and the call is aborted.
+	CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int, err error)
+}
+
+// jsonLogEntry is a single executed instruction in the standard JSON trace format.
+type jsonLogEntry struct {
+	Pc      uint64   `json:"pc"`
+	Op      OpCode   `json:"op"`
+	Gas     string   `json:"gas"`
+	GasCost string   `json:"gasCost"`
+	MemSize int      `json:"memSize"`
+	Stack   []string `json:"stack"`
+	Depth   int      `json:"depth"`
+	OpName  string   `json:"opName"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// jsonLogSummary is written by JSONLogger once the traced message is done.
+type jsonLogSummary struct {
+	Output  string `json:"output"`
+	GasUsed string `json:"gasUsed"`
+	Time    int64  `json:"time"`
+	Error   string `json:"error,omitempty"`
+}
+
+// JSONLogger is a Tracer writing every executed instruction as a line of JSON in
+// the standard trace format shared between EVM implementations, followed by a
+// summary line written by CaptureEnd.
+type JSONLogger struct {
+	encoder *json.Encoder
+}
+
+// NewJSONLogger creates a tracer writing JSON traces to writer.
+func NewJSONLogger(writer io.Writer) *JSONLogger {
+	return &JSONLogger{encoder: json.NewEncoder(writer)}
+}
+
+// CaptureState implements Tracer, writing the instruction to the output.
+func (l *JSONLogger) CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int, err error) {
+	entry := jsonLogEntry{
+		Pc:      pc,
+		Op:      op,
+		Gas:     hexBig(gas),
+		GasCost: hexBig(cost),
+		MemSize: memory.Len(),
+		Stack:   make([]string, len(stack)),
+		Depth:   depth,
+		OpName:  op.String(),
+	}
+	for i, item := range stack {
+		entry.Stack[i] = hexBig(item)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	l.encoder.Encode(entry)
+}
+
+// CaptureEnd writes the summary of the traced message to the output.
+func (l *JSONLogger) CaptureEnd(output []byte, gasUsed *big.Int, t time.Duration, err error) error {
+	summary := jsonLogSummary{
+		Output:  fmt.Sprintf("%x", output),
+		GasUsed: hexBig(gasUsed),
+		Time:    int64(t),
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	return l.encoder.Encode(summary)
+}
+
+// hexBig formats n as a 0x prefixed hex quantity, treating nil as zero.
+func hexBig(n *big.Int) string {
+	if n == nil {
+		return "0x0"
+	}
+	return fmt.Sprintf("%#x", n)
+}
+
+// copyBig returns a copy of n, treating nil as zero. The cost given to tracers is
+// nil for instructions that fail before their cost is known, e.g. on a stack
+// underflow.
+func copyBig(n *big.Int) *big.Int {
+	if n == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(n)
+}
+
+// StructLog is a single executed instruction collected by StructLogger.
+type StructLog struct {
+	Pc      uint64            `json:"pc"`
+	Op      string            `json:"op"`
+	Gas     *big.Int          `json:"gas"`
+	GasCost *big.Int          `json:"gasCost"`
+	Depth   int               `json:"depth"`
+	Stack   []string          `json:"stack"`
+	Memory  []string          `json:"memory,omitempty"`  // 32 byte words, if enabled
+	Storage map[string]string `json:"storage,omitempty"` // Slot read or written by the instruction with its value, if enabled
+	Error   string            `json:"error,omitempty"`
+}
+
+// LogConfig selects what StructLogger captures along with each instruction.
+type LogConfig struct {
+	DisableStack  bool // Leave out the stack
+	EnableMemory  bool // Capture the memory
+	EnableStorage bool // Capture the storage slot read or written by SLOAD and SSTORE
+	Limit         int  // Maximum number of instructions collected, all if not positive
+	MaxSize       int  // Maximum bytes of stack, memory and storage captured, unbounded if not positive
+}
+
+// StructLogger is a Tracer collecting the executed instructions in memory, up to
+// limits beyond which further instructions are only counted.
+type StructLogger struct {
+	cfg     LogConfig
+	logs    []StructLog
+	dropped int
+	size    int // Bytes of stack, memory and storage captured
+}
+
+// NewStructLogger creates a tracer collecting at most limit instructions, or all
+// of them if limit is not positive, along with the stack.
+func NewStructLogger(limit int) *StructLogger {
+	return NewStructLoggerConfig(LogConfig{Limit: limit})
+}
+
+// NewStructLoggerConfig creates a tracer collecting instructions as configured.
+func NewStructLoggerConfig(cfg LogConfig) *StructLogger {
+	return &StructLogger{cfg: cfg}
+}
+
+// CaptureState implements Tracer, recording the instruction.
+func (l *StructLogger) CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int, err error) {
+	if (l.cfg.Limit > 0 && len(l.logs) >= l.cfg.Limit) || (l.cfg.MaxSize > 0 && l.size >= l.cfg.MaxSize) {
+		l.dropped++
+		return
+	}
+	entry := StructLog{
+		Pc:      pc,
+		Op:      op.String(),
+		Gas:     copyBig(gas),
+		GasCost: copyBig(cost),
+		Depth:   depth,
+	}
+	if !l.cfg.DisableStack {
+		entry.Stack = make([]string, len(stack))
+		for i, item := range stack {
+			entry.Stack[i] = hexBig(item)
+		}
+		l.size += 32 * len(stack)
+	}
+	if l.cfg.EnableMemory {
+		data := memory.Data()
+		l.size += len(data)
+		entry.Memory = make([]string, 0, (len(data)+31)/32)
+		for i := 0; i < len(data); i += 32 {
+			end := i + 32
+			if end > len(data) {
+				end = len(data)
+			}
+			entry.Memory = append(entry.Memory, fmt.Sprintf("%x", data[i:end]))
+		}
+	}
+	if l.cfg.EnableStorage {
+		if key, value, ok := accessedSlot(env, op, stack, contract); ok {
+			entry.Storage = map[string]string{key.Hex(): value.Hex()}
+			l.size += 2 * common.HashLength
+		}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	l.logs = append(l.logs, entry)
+}
+
+// accessedSlot returns the slot an SLOAD or SSTORE is about to access, with the
+// value read or written.
+func accessedSlot(env Environment, op OpCode, stack []*big.Int, contract *Contract) (key, value common.Hash, ok bool) {
+	switch {
+	case op == SLOAD && len(stack) >= 1:
+		key = common.BigToHash(stack[len(stack)-1])
+		return key, env.Db().GetState(contract.Address(), key), true
+	case op == SSTORE && len(stack) >= 2:
+		return common.BigToHash(stack[len(stack)-1]), common.BigToHash(stack[len(stack)-2]), true
+	}
+	return common.Hash{}, common.Hash{}, false
+}
+
+// StructLogs returns the instructions collected.
+func (l *StructLogger) StructLogs() []StructLog { return l.logs }
+
+// Dropped returns the number of instructions executed beyond the limit.
+func (l *StructLogger) Dropped() int { return l.dropped }
+
+// AccessListTracer is a Tracer collecting the accounts and storage slots accessed
+// by the executed instructions.
+type AccessListTracer struct {
+	accessed map[common.Address]map[common.Hash]struct{}
+}
+
+// NewAccessListTracer creates a tracer collecting accessed accounts and slots.
+func NewAccessListTracer() *AccessListTracer {
+	return &AccessListTracer{accessed: make(map[common.Address]map[common.Hash]struct{})}
+}
+
+// CaptureState implements Tracer, recording the executing contract along with the
+// accounts and slots the instruction accesses.
+func (l *AccessListTracer) CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int, err error) {
+	if err != nil {
+		return
+	}
+	l.addAddress(contract.Address())
+
+	// Operands are taken from the top of the stack, the end of the slice
+	operand := func(n int) *big.Int {
+		if n >= len(stack) {
+			return nil
+		}
+		return stack[len(stack)-1-n]
+	}
+	switch op {
+	case SLOAD, SSTORE:
+		if key := operand(0); key != nil {
+			l.addSlot(contract.Address(), common.BigToHash(key))
+		}
+	case BALANCE, EXTCODESIZE, EXTCODECOPY, SUICIDE:
+		if addr := operand(0); addr != nil {
+			l.addAddress(common.BigToAddress(addr))
+		}
+	case CALL, CALLCODE, DELEGATECALL:
+		if addr := operand(1); addr != nil {
+			l.addAddress(common.BigToAddress(addr))
+		}
+	}
+}
+
+func (l *AccessListTracer) addAddress(addr common.Address) {
+	if _, ok := l.accessed[addr]; !ok {
+		l.accessed[addr] = make(map[common.Hash]struct{})
+	}
+}
+
+func (l *AccessListTracer) addSlot(addr common.Address, slot common.Hash) {
+	l.addAddress(addr)
+	l.accessed[addr][slot] = struct{}{}
+}
+
+// Accessed returns the accessed accounts, each mapped to its accessed slots.
+func (l *AccessListTracer) Accessed() map[common.Address]map[common.Hash]struct{} {
+	return l.accessed
+}
+
+// Transfer is a value transfer between accounts made by a contract, rather than
+// by the transaction itself.
+type Transfer struct {
+	From  common.Address
+	To    common.Address
+	Value *big.Int
+	Op    OpCode // CALL, CREATE or SUICIDE
+	Depth int    // Call depth of the contract making the transfer, 1 being the transaction's
+}
+
+// TransferTracer is a Tracer collecting the value transfers made by contracts of
+// a single transaction from the calls collected by a CallTracer: value bearing
+// calls and creates that succeeded along with all their callers, and the
+// balances sent by suicides.
+type TransferTracer struct {
+	calls *CallTracer
+}
+
+// NewTransferTracer creates a tracer collecting value transfers.
+func NewTransferTracer() *TransferTracer {
+	return &TransferTracer{calls: NewCallTracer()}
+}
+
+// CaptureState implements Tracer.
+func (t *TransferTracer) CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int, err error) {
+	t.calls.CaptureState(env, pc, op, gas, cost, memory, stack, contract, depth, err)
+}
+
+// Finish returns the transfers of the traced transaction, none if it failed, and
+// resets the tracer for the next transaction.
+func (t *TransferTracer) Finish(succeeded bool) []Transfer {
+	frames := t.calls.Finish(succeeded)
+	if !succeeded {
+		return nil
+	}
+	var (
+		transfers []Transfer
+		reverted  = make(map[string]bool) // Failed frames and the frames they called, by trace address
+	)
+	for _, frame := range frames[1:] {
+		caller := frame.TraceAddress[:len(frame.TraceAddress)-1]
+		if frame.Failed || reverted[fmt.Sprint(caller)] {
+			reverted[fmt.Sprint(frame.TraceAddress)] = true
+			continue
+		}
+		switch frame.Op {
+		case CALL, CREATE, SUICIDE:
+			if frame.Value.Sign() > 0 {
+				transfers = append(transfers, Transfer{From: frame.From, To: frame.To, Value: frame.Value, Op: frame.Op, Depth: len(frame.TraceAddress)})
+			}
+		}
+	}
+	return transfers
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sync/atomic"
 	"time"
 
 	"github.com/openether/ethcore/common"
@@ -15,6 +16,7 @@ import (
 var (
 	OutOfGasError          = errors.New("Out of gas")
 	CodeStoreOutOfGasError = errors.New("Contract creation code storage out of gas")
+	ErrExecutionCanceled   = errors.New("execution canceled")
 )
 
 // VirtualMachine is an EVM interface
@@ -30,6 +32,8 @@ type EVM struct {
 	env       Environment
 	jumpTable vmJumpTable
 	gasTable  GasTable
+	tracer    Tracer
+	abort     int32 // Set by Cancel, stops execution at the next instruction
 }
 
 // New returns a new instance of the EVM.
@@ -41,6 +45,18 @@ func New(env Environment) *EVM {
 	}
 }
 
+// SetTracer sets the tracer notified about every executed instruction, or
+// disables tracing if nil.
+func (evm *EVM) SetTracer(tracer Tracer) {
+	evm.tracer = tracer
+}
+
+// Cancel stops the execution, at every call depth, before the next instruction.
+// It is safe to call from another goroutine.
+func (evm *EVM) Cancel() {
+	atomic.StoreInt32(&evm.abort, 1)
+}
+
 // Run loops and evaluates the contract's code with the given input data
 func (evm *EVM) Run(contract *Contract, input []byte) (ret []byte, err error) {
 	evm.env.SetDepth(evm.env.Depth() + 1)
@@ -102,10 +118,16 @@ func (evm *EVM) Run(contract *Contract, input []byte) (ret []byte, err error) {
 	}
 
 	for ; ; instrCount++ {
+		if atomic.LoadInt32(&evm.abort) != 0 {
+			return nil, ErrExecutionCanceled
+		}
 		// Get the memory location of pc
 		op = contract.GetOp(pc)
 		// calculate the new memory size and gas price for the current executing opcode
 		newMemSize, cost, err = calculateGasAndSize(&evm.gasTable, evm.env, contract, caller, op, statedb, mem, stack)
+		if evm.tracer != nil {
+			evm.tracer.CaptureState(evm.env, pc, op, contract.Gas, cost, mem, stack.Data(), contract, evm.env.Depth(), err)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -66,6 +66,13 @@ func (self *VMEnv) GetHash(n uint64) common.Hash {
 	return self.getHashFn(n)
 }
 
+// SetTracer sets the tracer notified about every instruction executed in this
+// environment, or disables tracing if nil.
+func (self *VMEnv) SetTracer(tracer vm.Tracer) { self.evm.SetTracer(tracer) }
+
+// Cancel stops the execution in this environment before its next instruction.
+func (self *VMEnv) Cancel() { self.evm.Cancel() }
+
 func (self *VMEnv) AddLog(log *vm.Log) {
 	self.state.AddLog(*log)
 }
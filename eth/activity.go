@@ -0,0 +1,379 @@
+package eth
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/crypto"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/rpc"
+)
+
+// Formats of account activity exports.
+const (
+	ActivityCSV  = "csv"  // Comma separated values, with a header line
+	ActivityJSON = "json" // One JSON object per line
+)
+
+// activityColumns are the fields of account activity rows, in CSV column order.
+var activityColumns = []string{"blockNumber", "timestamp", "transactionHash", "kind", "from", "to", "value", "fee", "status"}
+
+// activityRow is a value transfer touching an account. Amounts are decimal wei.
+type activityRow struct {
+	BlockNumber uint64         `json:"blockNumber"`
+	Timestamp   uint64         `json:"timestamp"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	Kind        string         `json:"kind"` // "transaction" for external transactions, else CALL, CREATE or SUICIDE
+	From        common.Address `json:"from"`
+	To          common.Address `json:"to"` // Created contract for contract creations
+	Value       string         `json:"value"`
+	Fee         string         `json:"fee"`    // Paid by the account, for transactions it sent
+	Status      string         `json:"status"` // "success", "failure" or "unknown" for external transactions, empty for internal ones
+}
+
+func (row *activityRow) record() []string {
+	return []string{
+		strconv.FormatUint(row.BlockNumber, 10),
+		strconv.FormatUint(row.Timestamp, 10),
+		row.TxHash.Hex(),
+		row.Kind,
+		row.From.Hex(),
+		row.To.Hex(),
+		row.Value,
+		row.Fee,
+		row.Status,
+	}
+}
+
+const (
+	// maxActivityRows is the number of rows after which an account activity
+	// export stops, at the next block boundary.
+	maxActivityRows = 10000
+
+	// activityCursorCacheSize is the number of unfinished account activity exports
+	// kept to be continued by their next chunk.
+	activityCursorCacheSize = 16
+)
+
+// ActivityExport is a chunk of an account activity export.
+type ActivityExport struct {
+	Data string `json:"data"` // Rows in the requested format, a complete CSV document with its header for CSV
+	Rows int    `json:"rows"`
+	// InternalUntil is the first block whose internal transfers aren't part of the
+	// export as the internal transaction index doesn't cover it, 0 if not built.
+	InternalUntil uint64 `json:"internalUntil"`
+	// NextBlock is the block the export continues from in the next chunk, nil if
+	// the export is complete.
+	NextBlock *uint64 `json:"nextBlock"`
+}
+
+// activityWriter writes account activity rows in one of the export formats.
+type activityWriter interface {
+	write(row *activityRow) error
+	flush() error
+}
+
+type csvActivityWriter struct{ w *csv.Writer }
+
+func (w *csvActivityWriter) write(row *activityRow) error { return w.w.Write(row.record()) }
+func (w *csvActivityWriter) flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type jsonActivityWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *jsonActivityWriter) write(row *activityRow) error { return w.enc.Encode(row) }
+func (w *jsonActivityWriter) flush() error                 { return w.buf.Flush() }
+
+func newActivityWriter(out io.Writer, format string) (activityWriter, error) {
+	switch strings.ToLower(format) {
+	case ActivityCSV, "":
+		w := csv.NewWriter(out)
+		if err := w.Write(activityColumns); err != nil {
+			return nil, err
+		}
+		return &csvActivityWriter{w}, nil
+	case ActivityJSON:
+		buf := bufio.NewWriter(out)
+		return &jsonActivityWriter{buf, json.NewEncoder(buf)}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %q, want %s or %s", format, ActivityCSV, ActivityJSON)
+}
+
+// activityCursor is the state of an account activity export: the transfers read
+// from the indexes once, as the export starts, and not written yet. It's kept
+// between chunks so that every chunk continues where the previous one stopped.
+type activityCursor struct {
+	address       common.Address
+	to            uint64
+	hashes        []common.Hash                      // Transactions sent or received, oldest first
+	byTx          map[common.Hash][]*core.InternalTx // Internal transfers of the transactions in hashes
+	others        []*core.InternalTx                 // Internal transfers of other transactions, in block order
+	internalUntil uint64
+}
+
+// newActivityCursor reads the transfers touching an account in the blocks
+// [from, to] from the address-transaction and internal transaction indexes.
+func newActivityCursor(indexDb ethdb.Database, address common.Address, from, to uint64) (*activityCursor, error) {
+	// The indexes take a zero bound as no bound, and list the newest transactions first
+	hexes, err := core.GetAddrTxs(indexDb, address, from, to, "b", "b", 0, -1, true)
+	if err != nil {
+		return nil, err
+	}
+	internal, err := core.GetInternalTxs(indexDb, address, from, to, 'b')
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(internal, func(i, j int) bool { return internal[i].BlockNumber < internal[j].BlockNumber })
+
+	// Internal transfers are written along with their transaction if the account
+	// is party to it, else on their own in block order
+	c := &activityCursor{
+		address:       address,
+		to:            to,
+		hashes:        make([]common.Hash, len(hexes)),
+		byTx:          make(map[common.Hash][]*core.InternalTx),
+		internalUntil: core.InternalTxIndexProgress(indexDb),
+	}
+	external := make(map[common.Hash]bool, len(hexes))
+	for i, hex := range hexes {
+		c.hashes[i] = common.HexToHash(hex)
+		external[c.hashes[i]] = true
+	}
+	for _, itx := range internal {
+		if itx.BlockNumber > to {
+			continue
+		}
+		if external[itx.TxHash] {
+			c.byTx[itx.TxHash] = append(c.byTx[itx.TxHash], itx)
+		} else {
+			c.others = append(c.others, itx)
+		}
+	}
+	return c, nil
+}
+
+// exportActivity writes the value transfers of an export to out, oldest first:
+// the transactions sent or received according to the address-transaction index,
+// each followed by the transfers contracts made as part of it, if the internal
+// transaction index covers them. Transactions are read one at a time as rows are
+// written. Once limit rows are written, the export stops at the next block, which
+// is reported as NextBlock, and the cursor is left there for the next chunk.
+func exportActivity(chainDb ethdb.Database, c *activityCursor, limit int, out activityWriter) (*ActivityExport, error) {
+	export := &ActivityExport{InternalUntil: c.internalUntil}
+	headers := &headerCache{db: chainDb}
+	// full reports whether the export stops before the rows of the given block
+	var last uint64
+	full := func(number uint64) bool {
+		if export.Rows >= limit && number != last {
+			export.NextBlock = &number
+			return true
+		}
+		last = number
+		return false
+	}
+	emitOthers := func(before uint64) error {
+		for len(c.others) > 0 && c.others[0].BlockNumber < before {
+			if full(c.others[0].BlockNumber) {
+				return nil
+			}
+			header, err := headers.byNumber(c.others[0].BlockNumber)
+			if err != nil {
+				return err
+			}
+			if err := out.write(internalRow(c.address, c.others[0], header)); err != nil {
+				return err
+			}
+			export.Rows++
+			c.others = c.others[1:]
+		}
+		return nil
+	}
+	for ; len(c.hashes) > 0; c.hashes = c.hashes[1:] {
+		hash := c.hashes[0]
+		tx, blockHash, number, _ := core.GetTransaction(chainDb, hash)
+		if tx == nil || number > c.to {
+			continue // Reorged out since indexed, or beyond the range
+		}
+		if err := emitOthers(number); err != nil {
+			return nil, err
+		}
+		if export.NextBlock != nil || full(number) {
+			break
+		}
+		header, err := headers.byHash(blockHash, number)
+		if err != nil {
+			return nil, err
+		}
+		if err := out.write(transactionRow(chainDb, c.address, tx, header)); err != nil {
+			return nil, err
+		}
+		export.Rows++
+		for _, itx := range c.byTx[hash] {
+			if err := out.write(internalRow(c.address, itx, header)); err != nil {
+				return nil, err
+			}
+			export.Rows++
+		}
+	}
+	if export.NextBlock == nil {
+		if err := emitOthers(c.to + 1); err != nil {
+			return nil, err
+		}
+	}
+	return export, out.flush()
+}
+
+// headerCache keeps the last header looked up, as rows come in block order.
+type headerCache struct {
+	db     ethdb.Database
+	header *types.Header
+}
+
+func (c *headerCache) byHash(hash common.Hash, number uint64) (*types.Header, error) {
+	if c.header == nil || c.header.Hash() != hash {
+		if c.header = core.GetHeader(c.db, hash); c.header == nil {
+			return nil, fmt.Errorf("header #%d [%x…] missing", number, hash.Bytes()[:4])
+		}
+	}
+	return c.header, nil
+}
+
+func (c *headerCache) byNumber(number uint64) (*types.Header, error) {
+	if c.header != nil && c.header.Number.Uint64() == number {
+		return c.header, nil
+	}
+	return c.byHash(core.GetCanonicalHash(c.db, number), number)
+}
+
+// transactionRow describes an external transaction, charging its fee to the
+// account if it sent it.
+func transactionRow(chainDb ethdb.Database, address common.Address, tx *types.Transaction, header *types.Header) *activityRow {
+	sender, _ := tx.From()
+	row := &activityRow{
+		BlockNumber: header.Number.Uint64(),
+		Timestamp:   header.Time.Uint64(),
+		TxHash:      tx.Hash(),
+		Kind:        "transaction",
+		From:        sender,
+		Value:       tx.Value().String(),
+		Fee:         "0",
+		Status:      "unknown",
+	}
+	if tx.To() != nil {
+		row.To = *tx.To()
+	} else {
+		row.To = crypto.CreateAddress(sender, tx.Nonce())
+	}
+	if receipt := core.GetReceipt(chainDb, tx.Hash()); receipt != nil {
+		switch receipt.Status {
+		case types.TxSuccess:
+			row.Status = "success"
+		case types.TxFailure:
+			row.Status = "failure"
+		}
+		if sender == address && receipt.GasUsed != nil {
+			row.Fee = new(big.Int).Mul(receipt.GasUsed, tx.GasPrice()).String()
+		}
+	}
+	return row
+}
+
+// internalRow describes a transfer made by a contract.
+func internalRow(address common.Address, itx *core.InternalTx, header *types.Header) *activityRow {
+	row := &activityRow{
+		BlockNumber: itx.BlockNumber,
+		Timestamp:   header.Time.Uint64(),
+		TxHash:      itx.TxHash,
+		Kind:        itx.Op.String(),
+		From:        address,
+		To:          itx.Counterparty,
+		Value:       itx.Value.String(),
+		Fee:         "0",
+	}
+	if itx.Direction == 't' {
+		row.From, row.To = itx.Counterparty, address
+	}
+	return row
+}
+
+// ExportAccountActivity returns the value transfers touching the given address in
+// the blocks [fromBlock, toBlock], for accounting: the transactions the account
+// sent or received, with the fees it paid, and the transfers contracts made to or
+// from it, as CSV or JSON lines. Large exports are returned in chunks, the next
+// one starting at the NextBlock of the previous. The indexes are read once, as an
+// export starts. It requires the address-transaction index; transfers made by
+// contracts are included as far as the internal transaction index was built.
+func (api *PrivateAdminAPI) ExportAccountActivity(address common.Address, fromBlock, toBlock rpc.BlockNumber, format string) (*ActivityExport, error) {
+	atxi := api.eth.BlockChain().GetAtxi()
+	if atxi == nil {
+		return nil, errors.New("addr-tx indexing not enabled")
+	}
+	if fromBlock < 0 {
+		return nil, errors.New("fromBlock must be a block number")
+	}
+	if toBlock < 0 {
+		toBlock = rpc.BlockNumber(api.eth.BlockChain().CurrentBlock().NumberU64())
+	}
+	if toBlock < fromBlock {
+		return nil, fmt.Errorf("toBlock %d before fromBlock %d", toBlock, fromBlock)
+	}
+	var out bytes.Buffer
+	w, err := newActivityWriter(&out, format)
+	if err != nil {
+		return nil, err
+	}
+	// Continue the export a previous chunk stopped at, else read the indexes
+	key := activityCursorKey{address, uint64(fromBlock), uint64(toBlock)}
+	cursor := api.takeActivityCursor(key)
+	if cursor == nil {
+		if cursor, err = newActivityCursor(atxi.Db, address, key.from, key.to); err != nil {
+			return nil, err
+		}
+	}
+	export, err := exportActivity(api.eth.ChainDb(), cursor, maxActivityRows, w)
+	if err != nil {
+		return nil, err
+	}
+	if export.NextBlock != nil {
+		api.activity.Add(activityCursorKey{address, *export.NextBlock, key.to}, cursor)
+	}
+	export.Data = out.String()
+	return export, nil
+}
+
+// activityCursorKey identifies an export chunk by its arguments.
+type activityCursorKey struct {
+	address  common.Address
+	from, to uint64
+}
+
+// takeActivityCursor returns the cursor of the unfinished export continued by
+// the chunk, if still cached, removing it so only a single request advances it.
+func (api *PrivateAdminAPI) takeActivityCursor(key activityCursorKey) *activityCursor {
+	api.activityLock.Lock()
+	defer api.activityLock.Unlock()
+
+	cursor, ok := api.activity.Get(key)
+	if !ok {
+		return nil
+	}
+	api.activity.Remove(key)
+	return cursor.(*activityCursor)
+}
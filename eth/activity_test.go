@@ -0,0 +1,157 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/ethdb"
+)
+
+func TestActivityWriters(t *testing.T) {
+	row := &activityRow{
+		BlockNumber: 7,
+		Timestamp:   1500000000,
+		TxHash:      common.HexToHash("0x01"),
+		Kind:        "transaction",
+		From:        common.HexToAddress("0x02"),
+		To:          common.HexToAddress("0x03"),
+		Value:       "1000",
+		Fee:         "21000",
+		Status:      "success",
+	}
+
+	var buf bytes.Buffer
+	w, err := newActivityWriter(&buf, ActivityCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.write(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != strings.Join(activityColumns, ",") {
+		t.Fatalf("unexpected csv export %q", buf.String())
+	}
+	if want := strings.Join(row.record(), ","); lines[1] != want {
+		t.Errorf("csv row %q, want %q", lines[1], want)
+	}
+
+	buf.Reset()
+	if w, err = newActivityWriter(&buf, ActivityJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.write(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+	var decoded activityRow
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != *row {
+		t.Errorf("json row %+v, want %+v", decoded, *row)
+	}
+
+	if _, err := newActivityWriter(&buf, "xml"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+// Tests that account activity exports are split in chunks at block boundaries.
+func TestExportActivity(t *testing.T) {
+	recipient := common.HexToAddress("0x1234")
+	api, blockchain := newTestBlockChainAPI(3, func(i int, block *core.BlockGen) {
+		for j := 0; j < 2; j++ {
+			tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), recipient, big.NewInt(1000), core.TxGas, big.NewInt(1), nil).SignECDSA(testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	dir, err := ioutil.TempDir("", "activity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	indexDb, err := ethdb.NewLDBDatabase(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := uint64(1); n <= 3; n++ {
+		block := blockchain.GetBlockByNumber(n)
+		if err := core.WriteTransactions(api.chainDb, block); err != nil {
+			t.Fatal(err)
+		}
+		if err := core.WriteBlockAddTxIndexes(indexDb, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	export := func(cursor *activityCursor, limit int) (*ActivityExport, []string) {
+		var buf bytes.Buffer
+		w, _ := newActivityWriter(&buf, ActivityCSV)
+		result, err := exportActivity(api.chainDb, cursor, limit, w)
+		if err != nil {
+			t.Fatalf("export failed: %v", err)
+		}
+		return result, strings.Split(strings.TrimSpace(buf.String()), "\n")[1:]
+	}
+	cursor, err := newActivityCursor(indexDb, recipient, 1, 3)
+	if err != nil {
+		t.Fatalf("failed to read indexes: %v", err)
+	}
+
+	// The rows of a block aren't split over chunks
+	first, rows := export(cursor, 3)
+	if first.Rows != 4 || len(rows) != 4 || first.NextBlock == nil || *first.NextBlock != 3 {
+		t.Fatalf("first chunk mismatch: %+v, %d rows written", first, len(rows))
+	}
+	for _, row := range rows {
+		if fields := strings.Split(row, ","); fields[4] != testBank.Address.Hex() || fields[5] != recipient.Hex() || fields[6] != "1000" {
+			t.Errorf("row mismatch: %s", row)
+		}
+	}
+	// The next chunk continues from the cursor, without the indexes
+	indexDb.Close()
+	second, rows := export(cursor, 3)
+	if second.Rows != 2 || len(rows) != 2 || second.NextBlock != nil {
+		t.Fatalf("second chunk mismatch: %+v, %d rows written", second, len(rows))
+	}
+	for i, row := range rows {
+		if fields := strings.Split(row, ","); fields[0] != "3" || fields[2] != blockchain.GetBlockByNumber(3).Transactions()[i].Hash().Hex() {
+			t.Errorf("second chunk row %d mismatch: %s", i, row)
+		}
+	}
+	if _, rows := export(cursor, 3); len(rows) != 0 {
+		t.Errorf("finished export continued: %d rows written", len(rows))
+	}
+}
+
+// Tests that the cursor of an unfinished export is handed to a single request
+// continuing it.
+func TestTakeActivityCursor(t *testing.T) {
+	api := NewPrivateAdminAPI(nil)
+	key := activityCursorKey{common.HexToAddress("0x1234"), 3, 5}
+	cursor := &activityCursor{}
+	api.activity.Add(key, cursor)
+
+	if c := api.takeActivityCursor(activityCursorKey{key.address, 2, 5}); c != nil {
+		t.Errorf("cursor continued from the wrong block")
+	}
+	if c := api.takeActivityCursor(key); c != cursor {
+		t.Fatalf("cursor mismatch: have %p, want %p", c, cursor)
+	}
+	if c := api.takeActivityCursor(key); c != nil {
+		t.Errorf("cursor handed out twice")
+	}
+}
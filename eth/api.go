@@ -11,13 +11,16 @@ import (
 	"math"
 	"math/big"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/hashicorp/golang-lru"
 	"github.com/openether/ethcore/accounts"
 	"github.com/openether/ethcore/common"
 	"github.com/openether/ethcore/common/compiler"
 	"github.com/openether/ethcore/common/hexutil"
+	"github.com/openether/ethcore/common/registrar"
 	"github.com/openether/ethcore/core"
 	"github.com/openether/ethcore/core/state"
 	"github.com/openether/ethcore/core/types"
@@ -79,18 +82,39 @@ func NewPublicEthereumAPI(e *Ethereum) *PublicEthereumAPI {
 }
 
 // GasPrice returns a suggestion for a gas price.
-func (s *PublicEthereumAPI) GasPrice() *big.Int {
-	return s.gpo.SuggestPrice()
+func (s *PublicEthereumAPI) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(s.gpo.SuggestPrice())
+}
+
+// FeeHistory returns the gas prices paid in the blockCount blocks up to newestBlock
+// at the given percentiles of the gas used in each block, along with the share of
+// the gas limit each block used, for wallets to base fee suggestions on.
+func (s *PublicEthereumAPI) FeeHistory(blockCount rpc.HexNumber, newestBlock rpc.BlockNumber, percentiles []float64) (*FeeHistory, error) {
+	if newestBlock == rpc.PendingBlockNumber {
+		newestBlock = rpc.LatestBlockNumber
+	}
+	// Check the count before converting it, large counts would wrap around
+	count := blockCount.BigInt()
+	if count.Sign() <= 0 {
+		return nil, fmt.Errorf("block count must be positive")
+	}
+	if count.Cmp(big.NewInt(maxFeeHistory)) > 0 {
+		return nil, fmt.Errorf("block count %v above maximum %d", count, maxFeeHistory)
+	}
+	newest := blockByNumber(s.e.BlockChain(), newestBlock)
+	if newest == nil {
+		return nil, fmt.Errorf("block %d not found", newestBlock)
+	}
+	return s.gpo.FeeHistory(count.Uint64(), newest, percentiles)
 }
 
 // GetCompilers returns the collection of available smart contract compilers
 func (s *PublicEthereumAPI) GetCompilers() ([]string, error) {
-	solc, err := s.e.Solc()
-	if err == nil && solc != nil {
-		return []string{"Solidity"}, nil
+	languages := []string{}
+	for _, c := range s.e.Compilers() {
+		languages = append(languages, c.Language())
 	}
-
-	return []string{}, nil
+	return languages, nil
 }
 
 // CompileSolidity compiles the given solidity source
@@ -107,9 +131,38 @@ func (s *PublicEthereumAPI) CompileSolidity(source string) (map[string]*compiler
 	return solc.Compile(source)
 }
 
+// CompileVyper compiles the given vyper source
+func (s *PublicEthereumAPI) CompileVyper(source string) (map[string]*compiler.Contract, error) {
+	vyper, err := s.e.Vyper()
+	if err != nil {
+		return nil, err
+	}
+
+	if vyper == nil {
+		return nil, errors.New("vyper compiler not found")
+	}
+
+	return vyper.Compile(source)
+}
+
+// CompileSolidityStandard compiles the given solc standard JSON input, returning
+// the full compilation output including metadata and source maps.
+func (s *PublicEthereumAPI) CompileSolidityStandard(input compiler.StandardInput) (*compiler.StandardOutput, error) {
+	solc, err := s.e.Solc()
+	if err != nil {
+		return nil, err
+	}
+
+	if solc == nil {
+		return nil, errors.New("solc (solidity compiler) not found")
+	}
+
+	return solc.CompileStandard(&input)
+}
+
 // ProtocolVersion returns the current Ethereum protocol version this node supports
-func (s *PublicEthereumAPI) ProtocolVersion() *rpc.HexNumber {
-	return rpc.NewHexNumber(s.e.EthVersion())
+func (s *PublicEthereumAPI) ProtocolVersion() hexutil.Uint {
+	return hexutil.Uint(s.e.EthVersion())
 }
 
 // Syncing returns false in case the node is currently not syncing with the network. It can be up to date or has not
@@ -128,11 +181,30 @@ func (s *PublicEthereumAPI) Syncing() (interface{}, error) {
 	}
 	// Otherwise gather the block sync stats
 	return map[string]interface{}{
-		"startingBlock": rpc.NewHexNumber(origin),
-		"currentBlock":  rpc.NewHexNumber(current),
-		"highestBlock":  rpc.NewHexNumber(height),
-		"pulledStates":  rpc.NewHexNumber(pulled),
-		"knownStates":   rpc.NewHexNumber(known),
+		"startingBlock": hexutil.Uint64(origin),
+		"currentBlock":  hexutil.Uint64(current),
+		"highestBlock":  hexutil.Uint64(height),
+		"pulledStates":  hexutil.Uint64(pulled),
+		"knownStates":   hexutil.Uint64(known),
+	}, nil
+}
+
+// HeadStatus returns the head block and how old it is relative to the wall clock of
+// the node, in seconds. A node done syncing whose head keeps aging follows a stalled
+// chain; stale is set once the age exceeds the configured threshold (staleThreshold,
+// 0 if disabled), which also fails the readiness endpoint of the node.
+func (s *PublicEthereumAPI) HeadStatus() (map[string]interface{}, error) {
+	if s.e.headWatch == nil {
+		return nil, errors.New("not started")
+	}
+	head, age := s.e.headWatch.age()
+	return map[string]interface{}{
+		"number":         hexutil.Uint64(head.NumberU64()),
+		"hash":           head.Hash(),
+		"timestamp":      (*hexutil.Big)(head.Time()),
+		"age":            hexutil.Uint64(age / time.Second),
+		"staleThreshold": hexutil.Uint64(s.e.headWatch.threshold / time.Second),
+		"stale":          s.e.headWatch.stale() != nil,
 	}, nil
 }
 
@@ -141,8 +213,8 @@ func (s *PublicEthereumAPI) Syncing() (interface{}, error) {
 // Number will be returned as a string in hexadecimal format.
 // 61 - Mainnet $((0x3d))
 // 62 - Morden $((0x3e))
-func (s *PublicEthereumAPI) ChainId() *big.Int {
-	return s.e.chainConfig.GetChainID()
+func (s *PublicEthereumAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(s.e.chainConfig.GetChainID())
 }
 
 
@@ -189,15 +261,175 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string][]*RPCTrans
 	return content
 }
 
+// PrivateTxPoolAPI offers an API for the transaction pool which isn't restricted to
+// the accounts managed by this node.
+type PrivateTxPoolAPI struct {
+	e *Ethereum
+}
+
+// NewPrivateTxPoolAPI creates a new tx pool service exposing the content of the pool
+// regardless of the sending account.
+func NewPrivateTxPoolAPI(e *Ethereum) *PrivateTxPoolAPI {
+	return &PrivateTxPoolAPI{e}
+}
+
+// PendingTransactions returns the pending transactions in the pool, optionally
+// filtered by sender and paginated, ordered by sender and nonce.
+func (s *PrivateTxPoolAPI) PendingTransactions(query *PendingTxQuery) ([]*RPCTransaction, error) {
+	return filterPendingTransactions(s.e.TxPool().GetTransactions(), query, nil)
+}
+
+// PrivateTransactions returns the hashes of the pooled transactions kept from the
+// public network, which are only sent to the private relays until released.
+func (s *PrivateTxPoolAPI) PrivateTransactions() []common.Hash {
+	return s.e.TxPool().PrivateHashes()
+}
+
+// Release propagates a private transaction to the network like any other. It
+// returns whether the transaction was propagated right away; queued transactions
+// are once they become processable.
+func (s *PrivateTxPoolAPI) Release(hash common.Hash) (bool, error) {
+	tx, err := s.e.TxPool().Release(hash)
+	if err != nil {
+		return false, err
+	}
+	if tx == nil {
+		return false, nil
+	}
+	s.e.protocolManager.BroadcastTx(hash, tx)
+	return true, nil
+}
+
+// SetMinGasPrice sets the lowest gas price of transactions from the network the
+// pool accepts, independent of the price suggested by the gas price oracle.
+// Transactions already in the pool are kept.
+func (s *PrivateTxPoolAPI) SetMinGasPrice(price hexutil.Big) bool {
+	s.e.TxPool().SetMinGasPrice((*big.Int)(&price))
+	glog.V(logger.Info).Infof("Transaction pool minimum gas price set to %v", (*big.Int)(&price))
+	return true
+}
+
+// PendingTxQuery filters and paginates a pending transactions listing. All fields
+// are optional; without a limit all matching transactions from offset on are returned.
+type PendingTxQuery struct {
+	From   *common.Address `json:"from"`
+	Offset *rpc.HexNumber  `json:"offset"`
+	Limit  *rpc.HexNumber  `json:"limit"`
+}
+
+// pendingTx is a pending transaction along with its sender, for sorting.
+type pendingTx struct {
+	from common.Address
+	tx   *types.Transaction
+}
+
+type pendingTxsBySender []pendingTx
+
+func (s pendingTxsBySender) Len() int      { return len(s) }
+func (s pendingTxsBySender) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s pendingTxsBySender) Less(i, j int) bool {
+	if c := bytes.Compare(s[i].from[:], s[j].from[:]); c != 0 {
+		return c < 0
+	}
+	return s[i].tx.Nonce() < s[j].tx.Nonce()
+}
+
+// filterPendingTransactions selects the transactions matching the query out of txs.
+// If allowed is non-nil, only transactions from senders it accepts are considered.
+func filterPendingTransactions(txs types.Transactions, query *PendingTxQuery, allowed func(common.Address) bool) ([]*RPCTransaction, error) {
+	if query == nil {
+		query = new(PendingTxQuery)
+	}
+	if query.Offset != nil && query.Offset.BigInt().Sign() < 0 {
+		return nil, fmt.Errorf("invalid offset %v", query.Offset.BigInt())
+	}
+	if query.Limit != nil && query.Limit.BigInt().Sign() <= 0 {
+		return nil, fmt.Errorf("invalid limit %v", query.Limit.BigInt())
+	}
+	matches := make(pendingTxsBySender, 0, len(txs))
+	for _, tx := range txs {
+		from, err := tx.From()
+		if err != nil {
+			continue
+		}
+		if query.From != nil && from != *query.From {
+			continue
+		}
+		if allowed != nil && !allowed(from) {
+			continue
+		}
+		matches = append(matches, pendingTx{from, tx})
+	}
+	sort.Sort(matches)
+
+	// Clamp the page to the matches before converting, offset and limit may not fit in an int
+	start, end := 0, len(matches)
+	if query.Offset != nil {
+		if offset := query.Offset.BigInt(); offset.Cmp(big.NewInt(int64(len(matches)))) < 0 {
+			start = int(offset.Int64())
+		} else {
+			start = len(matches)
+		}
+	}
+	if query.Limit != nil {
+		if limit := query.Limit.BigInt(); limit.Cmp(big.NewInt(int64(end-start))) < 0 {
+			end = start + int(limit.Int64())
+		}
+	}
+	transactions := make([]*RPCTransaction, 0, end-start)
+	for _, match := range matches[start:end] {
+		transactions = append(transactions, newRPCPendingTransaction(match.tx))
+	}
+	return transactions, nil
+}
+
 // Status returns the number of pending and queued transaction in the pool.
-func (s *PublicTxPoolAPI) Status() map[string]*rpc.HexNumber {
+func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
 	pending, queue := s.e.TxPool().Stats()
-	return map[string]*rpc.HexNumber{
-		"pending": rpc.NewHexNumber(pending),
-		"queued":  rpc.NewHexNumber(queue),
+	return map[string]hexutil.Uint{
+		"pending": hexutil.Uint(pending),
+		"queued":  hexutil.Uint(queue),
 	}
 }
 
+// MinGasPrice returns the lowest gas price of transactions from the network the
+// pool accepts.
+func (s *PublicTxPoolAPI) MinGasPrice() *hexutil.Big {
+	return (*hexutil.Big)(s.e.TxPool().MinGasPrice())
+}
+
+// RPCNonceRange is an inclusive range of missing account nonces.
+type RPCNonceRange struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// RPCNonceGaps reports the nonces an account is missing for its queued
+// transactions to be promoted to pending.
+type RPCNonceGaps struct {
+	Next    hexutil.Uint64  `json:"next"`
+	Missing []RPCNonceRange `json:"missing"`
+	Queued  hexutil.Uint    `json:"queued"`
+}
+
+// NonceGaps returns, per account, the nonce gaps that prevent queued transactions
+// from being promoted to pending.
+func (s *PublicTxPoolAPI) NonceGaps() map[string]*RPCNonceGaps {
+	gaps := make(map[string]*RPCNonceGaps)
+	for account, gap := range s.e.TxPool().NonceGaps() {
+		missing := make([]RPCNonceRange, len(gap.Missing))
+		for i, r := range gap.Missing {
+			missing[i] = RPCNonceRange{From: hexutil.Uint64(r.From), To: hexutil.Uint64(r.To)}
+		}
+		gaps[account.Hex()] = &RPCNonceGaps{
+			Next:    hexutil.Uint64(gap.Next),
+			Missing: missing,
+			Queued:  hexutil.Uint(gap.Queued),
+		}
+	}
+	return gaps
+}
+
 // Inspect retrieves the content of the transaction pool and flattens it into an
 // easily inspectable list.
 func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string][]string {
@@ -259,22 +491,37 @@ func (s *PublicAccountAPI) Accounts() []accounts.Account {
 // It offers methods to create, (un)lock en list accounts. Some methods accept
 // passwords and are therefore considered private by default.
 type PrivateAccountAPI struct {
-	bc     *core.BlockChain
-	am     *accounts.Manager
-	txPool *core.TxPool
-	txMu   *sync.Mutex
-	gpo    *GasPriceOracle
+	bc      *core.BlockChain
+	am      *accounts.Manager
+	txPool  *core.TxPool
+	txMu    *sync.Mutex
+	nonces  *nonceManager
+	gpo     *GasPriceOracle
+	ens     *registrar.ENS
+	expirer *txExpirer
+
+	eventMux        *event.TypeMux
+	muLifecycleSubs sync.Mutex
+	lifecycleSubs   map[string]rpc.Subscription
 }
 
 // NewPrivateAccountAPI create a new PrivateAccountAPI.
 func NewPrivateAccountAPI(e *Ethereum) *PrivateAccountAPI {
-	return &PrivateAccountAPI{
-		bc:     e.blockchain,
-		am:     e.accountManager,
-		txPool: e.txPool,
-		txMu:   &e.txMu,
-		gpo:    e.gpo,
+	api := &PrivateAccountAPI{
+		bc:            e.blockchain,
+		am:            e.accountManager,
+		txPool:        e.txPool,
+		txMu:          &e.txMu,
+		nonces:        e.nonces,
+		gpo:           e.gpo,
+		ens:           e.ens,
+		expirer:       e.txExpiry,
+		eventMux:      e.eventMux,
+		lifecycleSubs: make(map[string]rpc.Subscription),
 	}
+	go api.subscriptionLoop()
+
+	return api
 }
 
 // ListAccounts will return a list of addresses for accounts this node manages.
@@ -349,14 +596,19 @@ func (s *PrivateAccountAPI) Sign(data hexutil.Bytes, addr common.Address, passwd
 // SendTransaction will create a transaction from the given arguments and
 // tries to sign it with the key associated with args.To. If the given passwd isn't
 // able to decrypt the key it fails.
-func (s *PrivateAccountAPI) SendTransaction(args SendTxArgs, passwd string) (common.Hash, error) {
+func (s *PrivateAccountAPI) SendTransaction(args SendTxArgs, passwd string) (hash common.Hash, err error) {
+	if err := args.validate(); err != nil {
+		return common.Hash{}, err
+	}
+	if err := args.resolveNames(s.ens); err != nil {
+		return common.Hash{}, err
+	}
 	args = prepareSendTxArgs(args, s.gpo)
 
-	s.txMu.Lock()
-	defer s.txMu.Unlock()
-
 	if args.Nonce == nil {
-		args.Nonce = rpc.NewHexNumber(s.txPool.State().GetNonce(args.From))
+		nonce := s.nonces.Reserve(args.From)
+		args.Nonce = rpc.NewHexNumber(nonce)
+		defer func() { s.nonces.Settle(args.From, nonce, err) }()
 	}
 
 	var tx *types.Transaction
@@ -373,7 +625,72 @@ func (s *PrivateAccountAPI) SendTransaction(args SendTxArgs, passwd string) (com
 		return common.Hash{}, err
 	}
 
-	return submitTransaction(s.bc, s.txPool, tx, signature)
+	if hash, err = submitTransaction(s.bc, s.txPool, tx, signature); err != nil {
+		return common.Hash{}, err
+	}
+	s.expirer.expire(hash, args.TxExpiryArgs)
+	return hash, nil
+}
+
+// subscriptionLoop listens for events on the global event mux and creates notifications for subscriptions.
+func (s *PrivateAccountAPI) subscriptionLoop() {
+	sub := s.eventMux.Subscribe(core.TxLifecycleEvent{})
+	for event := range sub.Chan() {
+		if ev, ok := event.Data.(core.TxLifecycleEvent); ok {
+			s.notifyLifecycle(ev)
+		}
+	}
+}
+
+// notifyLifecycle notifies the lifecycle subscriptions of a status change of a
+// local transaction.
+func (s *PrivateAccountAPI) notifyLifecycle(ev core.TxLifecycleEvent) {
+	notification := map[string]interface{}{
+		"hash":   ev.Hash,
+		"status": ev.Status,
+	}
+	if ev.Replacement != (common.Hash{}) {
+		notification["replacement"] = ev.Replacement
+	}
+	if ev.Err != nil {
+		notification["error"] = ev.Err.Error()
+	}
+	s.muLifecycleSubs.Lock()
+	for id, sub := range s.lifecycleSubs {
+		if sub.Notify(notification) == rpc.ErrNotificationNotFound {
+			delete(s.lifecycleSubs, id)
+		}
+	}
+	s.muLifecycleSubs.Unlock()
+}
+
+// TransactionLifecycle creates a subscription notified when a local transaction
+// with a time-to-live changes status: it was mined or dropped before expiring
+// ("mined" or "dropped"), it expired and was dropped from the pool ("expired",
+// with an error if it couldn't be cancelled), a cancelling replacement was
+// submitted ("cancelling"), and which of the two was eventually mined ("cancelled"
+// or "mined"), if any ("superseded"). As it reports the transactions of every RPC
+// client, it is only available in the personal namespace.
+func (s *PrivateAccountAPI) TransactionLifecycle(ctx context.Context) (rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	subscription, err := notifier.NewSubscription(func(id string) {
+		s.muLifecycleSubs.Lock()
+		delete(s.lifecycleSubs, id)
+		s.muLifecycleSubs.Unlock()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.muLifecycleSubs.Lock()
+	s.lifecycleSubs[subscription.ID()] = subscription
+	s.muLifecycleSubs.Unlock()
+
+	return subscription, nil
 }
 
 // SignAndSendTransaction was renamed to SendTransaction. This method is deprecated
@@ -392,12 +709,17 @@ type PublicBlockChainAPI struct {
 	eventMux                *event.TypeMux
 	muNewBlockSubscriptions sync.Mutex                             // protects newBlocksSubscriptions
 	newBlockSubscriptions   map[string]func(core.ChainEvent) error // callbacks for new block subscriptions
+	muNewHeadSubscriptions  sync.Mutex                             // protects newHeadSubscriptions
+	newHeadSubscriptions    map[string]*headSubscription           // new head subscriptions
 	am                      *accounts.Manager
 	gpo                     *GasPriceOracle
+	ens                     *registrar.ENS // resolves names given instead of addresses, nil if not configured
+	gasCap                  *big.Int       // gas limit of eth_call and eth_estimateGas executions, none if nil
+	evmTimeout              time.Duration  // wall time limit of eth_call and eth_estimateGas executions, none if zero
 }
 
 // NewPublicBlockChainAPI creates a new Etheruem blockchain API.
-func NewPublicBlockChainAPI(config *core.ChainConfig, bc *core.BlockChain, chainDb ethdb.Database, gpo *GasPriceOracle, eventMux *event.TypeMux, am *accounts.Manager) *PublicBlockChainAPI {
+func NewPublicBlockChainAPI(config *core.ChainConfig, bc *core.BlockChain, chainDb ethdb.Database, gpo *GasPriceOracle, eventMux *event.TypeMux, am *accounts.Manager, ens *registrar.ENS) *PublicBlockChainAPI {
 	api := &PublicBlockChainAPI{
 		config:   config,
 		bc:       bc,
@@ -405,7 +727,9 @@ func NewPublicBlockChainAPI(config *core.ChainConfig, bc *core.BlockChain, chain
 		eventMux: eventMux,
 		am:       am,
 		newBlockSubscriptions: make(map[string]func(core.ChainEvent) error),
+		newHeadSubscriptions:  make(map[string]*headSubscription),
 		gpo: gpo,
+		ens: ens,
 	}
 
 	go api.subscriptionLoop()
@@ -415,41 +739,55 @@ func NewPublicBlockChainAPI(config *core.ChainConfig, bc *core.BlockChain, chain
 
 // subscriptionLoop reads events from the global event mux and creates notifications for the matched subscriptions.
 func (s *PublicBlockChainAPI) subscriptionLoop() {
-	sub := s.eventMux.Subscribe(core.ChainEvent{})
+	sub := s.eventMux.Subscribe(core.ChainEvent{}, core.ChainHeadEvent{})
 	for event := range sub.Chan() {
-		if chainEvent, ok := event.Data.(core.ChainEvent); ok {
+		switch ev := event.Data.(type) {
+		case core.ChainEvent:
 			s.muNewBlockSubscriptions.Lock()
 			for id, notifyOf := range s.newBlockSubscriptions {
-				if notifyOf(chainEvent) == rpc.ErrNotificationNotFound {
+				if notifyOf(ev) == rpc.ErrNotificationNotFound {
 					delete(s.newBlockSubscriptions, id)
 				}
 			}
 			s.muNewBlockSubscriptions.Unlock()
+		case core.ChainHeadEvent:
+			s.muNewHeadSubscriptions.Lock()
+			for id, sub := range s.newHeadSubscriptions {
+				if s.notifyHead(sub, ev.Block) == rpc.ErrNotificationNotFound {
+					delete(s.newHeadSubscriptions, id)
+				}
+			}
+			s.muNewHeadSubscriptions.Unlock()
 		}
 	}
 }
 
 // BlockNumber returns the block number of the chain head.
-func (s *PublicBlockChainAPI) BlockNumber() *big.Int {
-	return s.bc.CurrentHeader().Number
+func (s *PublicBlockChainAPI) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(s.bc.CurrentHeader().Number.Uint64())
 }
 
 // GetBalance returns the amount of wei for the given address in the state of the
 // given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
 // block numbers are also allowed.
-func (s *PublicBlockChainAPI) GetBalance(address common.Address, blockNr rpc.BlockNumber) (*big.Int, error) {
+func (s *PublicBlockChainAPI) GetBalance(arg AddressOrName, blockNr rpc.BlockNumber) (*hexutil.Big, error) {
+	address, err := resolveAddress(s.ens, arg)
+	if err != nil {
+		return nil, err
+	}
 	state, _, err := stateAndBlockByNumber(s.bc, blockNr, s.chainDb)
 	if state == nil || err != nil {
 		return nil, err
 	}
-	return state.GetBalance(address), nil
+	return (*hexutil.Big)(state.GetBalance(address)), nil
 }
 
 // GetBlockByNumber returns the requested block. When blockNr is -1 the chain head is returned. When fullTx is true all
-// transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
-func (s *PublicBlockChainAPI) GetBlockByNumber(blockNr rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+// transactions in the block are returned in full detail, otherwise only the transaction hash is returned. The optional
+// txFields restrict the fully detailed transactions to the given fields (e.g. ["hash", "from", "to", "value"]).
+func (s *PublicBlockChainAPI) GetBlockByNumber(blockNr rpc.BlockNumber, fullTx bool, txFields *[]string) (map[string]interface{}, error) {
 	if block := blockByNumber(s.bc, blockNr); block != nil {
-		response, err := s.rpcOutputBlock(block, true, fullTx)
+		response, err := s.rpcOutputBlockFields(block, true, fullTx, derefFields(txFields))
 		if err == nil && blockNr == rpc.PendingBlockNumber {
 			// Pending blocks need to nil out a few fields
 			for _, field := range []string{"hash", "nonce", "miner"} {
@@ -462,56 +800,67 @@ func (s *PublicBlockChainAPI) GetBlockByNumber(blockNr rpc.BlockNumber, fullTx b
 }
 
 // GetBlockByHash returns the requested block. When fullTx is true all transactions in the block are returned in full
-// detail, otherwise only the transaction hash is returned.
-func (s *PublicBlockChainAPI) GetBlockByHash(blockHash common.Hash, fullTx bool) (map[string]interface{}, error) {
+// detail, otherwise only the transaction hash is returned. The optional txFields restrict the fully detailed
+// transactions to the given fields.
+func (s *PublicBlockChainAPI) GetBlockByHash(blockHash common.Hash, fullTx bool, txFields *[]string) (map[string]interface{}, error) {
 	if block := s.bc.GetBlock(blockHash); block != nil {
-		return s.rpcOutputBlock(block, true, fullTx)
+		return s.rpcOutputBlockFields(block, true, fullTx, derefFields(txFields))
 	}
 	return nil, nil
 }
 
-// GetUncleByBlockNumberAndIndex returns the uncle block for the given block hash and index. When fullTx is true
-// all transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
+// GetUncleByBlockNumberAndIndex returns the uncle block for the given block number and index, or null if
+// either doesn't exist. Uncles are returned as blocks without transactions.
 func (s *PublicBlockChainAPI) GetUncleByBlockNumberAndIndex(blockNr rpc.BlockNumber, index rpc.HexNumber) (map[string]interface{}, error) {
-	if block := blockByNumber(s.bc, blockNr); block != nil {
-		uncles := block.Uncles()
-		if index.Int() < 0 || index.Int() >= len(uncles) {
-			glog.V(logger.Debug).Infof("uncle block on index %d not found for block #%d", index.Int(), blockNr)
-			return nil, nil
-		}
-		block = types.NewBlockWithHeader(uncles[index.Int()])
-		return s.rpcOutputBlock(block, false, false)
-	}
-	return nil, nil
+	return s.rpcOutputUncle(blockByNumber(s.bc, blockNr), index.BigInt())
 }
 
-// GetUncleByBlockHashAndIndex returns the uncle block for the given block hash and index. When fullTx is true
-// all transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
+// GetUncleByBlockHashAndIndex returns the uncle block for the given block hash and index, or null if either
+// doesn't exist. Uncles are returned as blocks without transactions.
 func (s *PublicBlockChainAPI) GetUncleByBlockHashAndIndex(blockHash common.Hash, index rpc.HexNumber) (map[string]interface{}, error) {
-	if block := s.bc.GetBlock(blockHash); block != nil {
-		uncles := block.Uncles()
-		if index.Int() < 0 || index.Int() >= len(uncles) {
-			glog.V(logger.Debug).Infof("uncle block on index %d not found for block %s", index.Int(), blockHash.Hex())
-			return nil, nil
-		}
-		block = types.NewBlockWithHeader(uncles[index.Int()])
-		return s.rpcOutputBlock(block, false, false)
+	return s.rpcOutputUncle(s.bc.GetBlock(blockHash), index.BigInt())
+}
+
+// rpcOutputUncle converts the index'th uncle of a block to the RPC output of a block, with empty transaction and
+// uncle lists as uncle headers carry no body. It returns nil if the block is nil or has no such uncle.
+func (s *PublicBlockChainAPI) rpcOutputUncle(block *types.Block, index *big.Int) (map[string]interface{}, error) {
+	if block == nil {
+		return nil, nil
 	}
-	return nil, nil
+	uncles := block.Uncles()
+	if index.Sign() < 0 || index.Cmp(big.NewInt(int64(len(uncles)))) >= 0 {
+		glog.V(logger.Debug).Infof("uncle block on index %v not found for block #%d [%s]", index, block.NumberU64(), block.Hash().Hex())
+		return nil, nil
+	}
+	return s.rpcOutputBlock(types.NewBlockWithHeader(uncles[index.Int64()]), true, false)
 }
 
-// GetUncleCountByBlockNumber returns number of uncles in the block for the given block number
-func (s *PublicBlockChainAPI) GetUncleCountByBlockNumber(blockNr rpc.BlockNumber) *rpc.HexNumber {
+// GetUncleCountByBlockNumber returns number of uncles in the block for the given block number, or null if the
+// block doesn't exist.
+func (s *PublicBlockChainAPI) GetUncleCountByBlockNumber(blockNr rpc.BlockNumber) *hexutil.Uint {
 	if block := blockByNumber(s.bc, blockNr); block != nil {
-		return rpc.NewHexNumber(len(block.Uncles()))
+		n := hexutil.Uint(len(block.Uncles()))
+		return &n
 	}
 	return nil
 }
 
-// GetUncleCountByBlockHash returns number of uncles in the block for the given block hash
-func (s *PublicBlockChainAPI) GetUncleCountByBlockHash(blockHash common.Hash) *rpc.HexNumber {
+// GetUncleCountByBlockHash returns number of uncles in the block for the given block hash, or null if the
+// block doesn't exist.
+func (s *PublicBlockChainAPI) GetUncleCountByBlockHash(blockHash common.Hash) *hexutil.Uint {
 	if block := s.bc.GetBlock(blockHash); block != nil {
-		return rpc.NewHexNumber(len(block.Uncles()))
+		n := hexutil.Uint(len(block.Uncles()))
+		return &n
+	}
+	return nil
+}
+
+// GetBlockAuthor returns the account that sealed the given block, as resolved by core.BlockAuthor, or null
+// if the block doesn't exist. Under ethash, the only engine of this tree, that is the coinbase.
+func (s *PublicBlockChainAPI) GetBlockAuthor(blockNr rpc.BlockNumber) *common.Address {
+	if block := blockByNumber(s.bc, blockNr); block != nil {
+		author := core.BlockAuthor(block.Header())
+		return &author
 	}
 	return nil
 }
@@ -555,23 +904,74 @@ func (s *PublicBlockChainAPI) NewBlocks(ctx context.Context, args NewBlocksArgs)
 	return subscription, nil
 }
 
+// headSubscription is a new head subscription together with the last head it was notified of.
+type headSubscription struct {
+	sub  rpc.Subscription
+	last *types.Header
+}
+
+// NewHeads triggers a notification each time the chain head changes. Next to the header fields the notification
+// carries the total difficulty and transaction count of the new head and, when the chain was reorganised since the
+// previous notification, the number of blocks that were dropped from it as reorgDepth.
+func (s *PublicBlockChainAPI) NewHeads(ctx context.Context) (rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	subscription, err := notifier.NewSubscription(func(subId string) {
+		s.muNewHeadSubscriptions.Lock()
+		delete(s.newHeadSubscriptions, subId)
+		s.muNewHeadSubscriptions.Unlock()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.muNewHeadSubscriptions.Lock()
+	s.newHeadSubscriptions[subscription.ID()] = &headSubscription{sub: subscription, last: s.bc.CurrentHeader()}
+	s.muNewHeadSubscriptions.Unlock()
+	return subscription, nil
+}
+
+// notifyHead sends the given head to a new head subscriber.
+func (s *PublicBlockChainAPI) notifyHead(sub *headSubscription, head *types.Block) error {
+	fields, err := s.rpcOutputBlock(head, false, false)
+	if err != nil {
+		glog.V(logger.Warn).Infof("unable to format head %v\n", err)
+		return nil
+	}
+	fields["transactionCount"] = hexutil.Uint(len(head.Transactions()))
+	if sub.last != nil {
+		if depth := core.ReorgDepth(s.bc.GetHeader, sub.last, head.Header()); depth > 0 {
+			fields["reorgDepth"] = hexutil.Uint64(depth)
+		}
+	}
+	sub.last = head.Header()
+	return sub.sub.Notify(fields)
+}
+
 // GetCode returns the code stored at the given address in the state for the given block number.
-func (s *PublicBlockChainAPI) GetCode(address common.Address, blockNr rpc.BlockNumber) (string, error) {
+func (s *PublicBlockChainAPI) GetCode(arg AddressOrName, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
+	address, err := resolveAddress(s.ens, arg)
+	if err != nil {
+		return nil, err
+	}
 	state, _, err := stateAndBlockByNumber(s.bc, blockNr, s.chainDb)
 	if state == nil || err != nil {
-		return "", err
-	}
-	res := state.GetCode(address)
-	if len(res) == 0 { // backwards compatibility
-		return "0x", nil
+		return nil, err
 	}
-	return common.ToHex(res), nil
+	return state.GetCode(address), nil
 }
 
 // GetStorageAt returns the storage from the state at the given address, key and
 // block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block
 // numbers are also allowed.
-func (s *PublicBlockChainAPI) GetStorageAt(address common.Address, key string, blockNr rpc.BlockNumber) (string, error) {
+func (s *PublicBlockChainAPI) GetStorageAt(arg AddressOrName, key string, blockNr rpc.BlockNumber) (string, error) {
+	address, err := resolveAddress(s.ens, arg)
+	if err != nil {
+		return "0x", err
+	}
 	state, _, err := stateAndBlockByNumber(s.bc, blockNr, s.chainDb)
 	if state == nil || err != nil {
 		return "0x", err
@@ -579,6 +979,185 @@ func (s *PublicBlockChainAPI) GetStorageAt(address common.Address, key string, b
 	return state.GetState(address, common.HexToHash(key)).Hex(), nil
 }
 
+const (
+	// maxStorageAtManyQueries limits the number of contracts a single GetStorageAtMany request can
+	// read, each possibly resolving a name.
+	maxStorageAtManyQueries = 1000
+
+	// maxStorageAtManySlots limits the number of storage slots, summed over all contracts, a single
+	// GetStorageAtMany request can read.
+	maxStorageAtManySlots = 10000
+)
+
+// StorageQuery selects storage slots of a contract.
+type StorageQuery struct {
+	Address AddressOrName `json:"address"`
+	Keys    []string      `json:"keys"`
+}
+
+// ContractStorage holds the values of the storage slots of a contract selected by a StorageQuery,
+// in the order of its keys.
+type ContractStorage struct {
+	Address common.Address `json:"address"`
+	Values  []common.Hash  `json:"values"`
+}
+
+// BlockStorage holds the storage slots read from the state of a single block.
+type BlockStorage struct {
+	BlockNumber *hexutil.Big      `json:"blockNumber"`
+	BlockHash   common.Hash       `json:"blockHash"`
+	Storage     []ContractStorage `json:"storage"`
+}
+
+// parseStorageKey parses a storage slot given as 0x-prefixed hex of at most 32 bytes, leading zeros
+// being optional.
+func parseStorageKey(key string) (common.Hash, error) {
+	if len(key) < 3 || key[0] != '0' || (key[1] != 'x' && key[1] != 'X') {
+		return common.Hash{}, fmt.Errorf("invalid storage key %q: not 0x-prefixed hex", key)
+	}
+	digits := key[2:]
+	if len(digits) > 2*common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid storage key %q: longer than %d bytes", key, common.HashLength)
+	}
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid storage key %q: not 0x-prefixed hex", key)
+	}
+	return common.BytesToHash(b), nil
+}
+
+// GetStorageAtMany returns the storage slots selected by the given queries, all read from the state
+// of the given block, in the order of the queries. It replaces one GetStorageAt request per slot by
+// a single request reading a consistent snapshot.
+func (s *PublicBlockChainAPI) GetStorageAtMany(queries []StorageQuery, blockNr rpc.BlockNumber) (*BlockStorage, error) {
+	if len(queries) > maxStorageAtManyQueries {
+		return nil, fmt.Errorf("too many queries: %d exceed the limit of %d", len(queries), maxStorageAtManyQueries)
+	}
+	slots := 0
+	for _, q := range queries {
+		slots += len(q.Keys)
+	}
+	if slots > maxStorageAtManySlots {
+		return nil, fmt.Errorf("too many storage slots: %d exceed the limit of %d", slots, maxStorageAtManySlots)
+	}
+	keys := make([][]common.Hash, len(queries))
+	for i, q := range queries {
+		keys[i] = make([]common.Hash, len(q.Keys))
+		for j, key := range q.Keys {
+			hash, err := parseStorageKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("query %d: %v", i, err)
+			}
+			keys[i][j] = hash
+		}
+	}
+	addresses := make([]common.Address, len(queries))
+	for i, q := range queries {
+		address, err := resolveAddress(s.ens, q.Address)
+		if err != nil {
+			return nil, fmt.Errorf("query %d: %v", i, err)
+		}
+		addresses[i] = address
+	}
+	state, block, err := stateAndBlockByNumber(s.bc, blockNr, s.chainDb)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr.Int64())
+	}
+	result := &BlockStorage{
+		BlockNumber: (*hexutil.Big)(block.Number()),
+		BlockHash:   block.Hash(),
+		Storage:     make([]ContractStorage, len(queries)),
+	}
+	for i := range queries {
+		values := make([]common.Hash, len(keys[i]))
+		for j, key := range keys[i] {
+			values[j] = state.GetState(addresses[i], key)
+		}
+		result.Storage[i] = ContractStorage{Address: addresses[i], Values: values}
+	}
+	return result, nil
+}
+
+// AccountResult is the state of an account along with the merkle proofs to verify
+// it against the state root of a block.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []hexutil.Bytes `json:"accountProof"` // Account trie nodes from the state root down
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is a storage slot along with the merkle proof to verify it
+// against the storage root of its account.
+type StorageResult struct {
+	Key   string          `json:"key"`
+	Value *hexutil.Big    `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"` // Storage trie nodes from the storage root down
+}
+
+// GetProof returns the account at the given address and the given storage slots
+// of it in the state of the given block, along with the merkle proofs of both.
+// Accounts and slots that don't exist are proved absent, their values are zero.
+func (s *PublicBlockChainAPI) GetProof(arg AddressOrName, storageKeys []string, blockNr rpc.BlockNumber) (*AccountResult, error) {
+	address, err := resolveAddress(s.ens, arg)
+	if err != nil {
+		return nil, err
+	}
+	state, _, err := stateAndBlockByNumber(s.bc, blockNr, s.chainDb)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	accountProof, err := state.GetProof(address)
+	if err != nil {
+		return nil, err
+	}
+	result := &AccountResult{
+		Address:      address,
+		AccountProof: toHexSlice(accountProof),
+		Balance:      (*hexutil.Big)(state.GetBalance(address)),
+		CodeHash:     state.GetCodeHash(address),
+		Nonce:        hexutil.Uint64(state.GetNonce(address)),
+		StorageHash:  state.GetStorageRoot(address),
+		StorageProof: make([]StorageResult, len(storageKeys)),
+	}
+	if !state.Exist(address) {
+		// Report the hashes an empty account would have
+		result.CodeHash = crypto.Keccak256Hash(nil)
+		result.StorageHash = types.EmptyRootHash
+	}
+	for i, key := range storageKeys {
+		slot := common.HexToHash(key)
+		proof, err := state.GetStorageProof(address, slot)
+		if err != nil {
+			return nil, err
+		}
+		result.StorageProof[i] = StorageResult{
+			Key:   key,
+			Value: (*hexutil.Big)(state.GetState(address, slot).Big()),
+			Proof: toHexSlice(proof),
+		}
+	}
+	return result, nil
+}
+
+// toHexSlice converts a list of byte slices for JSON encoding as hex strings.
+func toHexSlice(b [][]byte) []hexutil.Bytes {
+	r := make([]hexutil.Bytes, len(b))
+	for i := range b {
+		r[i] = b[i]
+	}
+	return r
+}
+
 // callmsg is the message type used for call transactions.
 type callmsg struct {
 	from          *state.StateObject
@@ -606,16 +1185,83 @@ type CallArgs struct {
 	GasPrice *rpc.HexNumber  `json:"gasPrice"`
 	Value    rpc.HexNumber   `json:"value"`
 	Data     string          `json:"data"`
+
+	names ensNames // ENS names given instead of addresses
 }
 
-func (s *PublicBlockChainAPI) doCall(args CallArgs, blockNr rpc.BlockNumber) (string, *big.Int, error) {
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, *big.Int, error) {
+	if err := args.resolveNames(s.ens); err != nil {
+		return nil, nil, err
+	}
+	// Apply the gas and time caps of the node
+	if s.gasCap != nil {
+		if args.Gas == nil {
+			args.Gas = rpc.NewHexNumber(s.gasCap)
+		} else if args.Gas.BigInt().Cmp(s.gasCap) > 0 {
+			return nil, nil, &CapExceededError{Cap: "gas", Limit: s.gasCap.String()}
+		}
+	}
+	// The execution is limited by the EVM timeout or the timeout of the RPC method,
+	// whichever is shorter, and both are reported as the time cap
+	callCtx, limit := ctx, s.evmTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); limit == 0 || remaining < limit {
+			limit = remaining.Round(time.Millisecond)
+		}
+	}
+	if s.evmTimeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, s.evmTimeout)
+		defer cancel()
+	}
 	// Fetch the state associated with the block number
 	stateDb, block, err := stateAndBlockByNumber(s.bc, blockNr, s.chainDb)
 	if stateDb == nil || err != nil {
-		return "0x", nil, err
+		return nil, nil, err
 	}
-	stateDb = stateDb.Copy()
+	res, gas, err := s.callOnState(callCtx, args, stateDb, block)
+	if callCtx.Err() == context.DeadlineExceeded {
+		return nil, nil, &CapExceededError{Cap: "time", Limit: limit.String()}
+	}
+	return res, gas, err
+}
+
+// CapExceededError is returned by eth_call and eth_estimateGas for executions
+// exceeding the gas or wall time cap of the node. The error data names the cap
+// and its limit.
+type CapExceededError struct {
+	Cap   string // "gas" or "time"
+	Limit string // Gas amount or duration
+}
+
+func (e *CapExceededError) Error() string {
+	return fmt.Sprintf("%s cap exceeded: execution limited to %s", e.Cap, e.Limit)
+}
 
+// Code implements rpc.RPCError, reporting a limit exceeded.
+func (e *CapExceededError) Code() int {
+	return -32005
+}
+
+// ErrorData implements rpc.DataError.
+func (e *CapExceededError) ErrorData() interface{} {
+	return map[string]string{"cap": e.Cap, "limit": e.Limit}
+}
+
+// callOnState executes the call on a copy of the given state, leaving the state itself untouched so it can be
+// re-used for further calls.
+func (s *PublicBlockChainAPI) callOnState(ctx context.Context, args CallArgs, stateDb *state.StateDB, block *types.Block) (hexutil.Bytes, *big.Int, error) {
+	return s.applyCall(ctx, args, stateDb.Copy(), block)
+}
+
+// applyCall executes the call on the given state, leaving its changes in place.
+func (s *PublicBlockChainAPI) applyCall(ctx context.Context, args CallArgs, stateDb *state.StateDB, block *types.Block) (hexutil.Bytes, *big.Int, error) {
+	return s.traceCall(ctx, args, stateDb, block, nil)
+}
+
+// traceCall is like applyCall, notifying the tracer, if any, about every executed instruction.
+// The execution is aborted once the context is done.
+func (s *PublicBlockChainAPI) traceCall(ctx context.Context, args CallArgs, stateDb *state.StateDB, block *types.Block, tracer vm.Tracer) (hexutil.Bytes, *big.Int, error) {
 	// Retrieve the account state object to interact with
 	var from *state.StateObject
 	if args.From == (common.Address{}) {
@@ -648,34 +1294,167 @@ func (s *PublicBlockChainAPI) doCall(args CallArgs, blockNr rpc.BlockNumber) (st
 
 	// Execute the call and return
 	vmenv := core.NewEnv(stateDb, s.config, s.bc, msg, block.Header())
+	if tracer != nil {
+		vmenv.SetTracer(tracer)
+	}
 	gp := new(core.GasPool).AddGas(common.MaxBig)
 
+	stop := cancelOnDone(ctx, vmenv)
 	res, requiredGas, _, err := core.NewStateTransition(vmenv, msg, gp).TransitionDb()
-	if len(res) == 0 { // backwards compatibility
-		return "0x", requiredGas, err
+	stop()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, executionAborted(err)
+	}
+	return res, requiredGas, err
+}
+
+// cancelOnDone cancels the execution in the given environment once the context is
+// done. The returned function stops watching the context and must be called once
+// the execution is over.
+func cancelOnDone(ctx context.Context, env *core.VMEnv) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			env.Cancel()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// executionAborted describes the reason a request was aborted with, as reported
+// by the context of the request.
+func executionAborted(err error) error {
+	switch err {
+	case context.DeadlineExceeded:
+		return errors.New("execution timed out")
+	case context.Canceled:
+		return errors.New("execution canceled")
 	}
-	return common.ToHex(res), requiredGas, err
+	return err
 }
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(args CallArgs, blockNr rpc.BlockNumber) (string, error) {
-	result, _, err := s.doCall(args, blockNr)
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
+	result, _, err := s.doCall(ctx, args, blockNr)
 	return result, err
 }
 
+// maxCallManyCalls limits the number of calls, summed over all blocks, a single CallMany request can execute.
+const maxCallManyCalls = 10000
+
+// CallResult is the outcome of a single call executed by CallMany.
+type CallResult struct {
+	Result  hexutil.Bytes `json:"result"`
+	GasUsed *hexutil.Big  `json:"gasUsed,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// BlockCallResults holds the outcome of the calls executed on the state of a single block.
+type BlockCallResults struct {
+	BlockNumber *hexutil.Big `json:"blockNumber"`
+	BlockHash   common.Hash  `json:"blockHash"`
+	Results     []CallResult `json:"results"`
+}
+
+// CallMany executes each of the given calls on the state of each of the given blocks. The state of a block is
+// loaded once and shared by all calls executed on it, every call starts from the unmodified block state. A failing
+// call is reported in its result and doesn't abort the others; blocks whose state isn't available fail the request.
+func (s *PublicBlockChainAPI) CallMany(ctx context.Context, calls []CallArgs, blockNrs []rpc.BlockNumber) ([]*BlockCallResults, error) {
+	if len(calls)*len(blockNrs) > maxCallManyCalls {
+		return nil, fmt.Errorf("too many calls: %d calls on %d blocks exceed the limit of %d", len(calls), len(blockNrs), maxCallManyCalls)
+	}
+	for i := range calls {
+		if err := calls[i].resolveNames(s.ens); err != nil {
+			return nil, fmt.Errorf("call %d: %v", i, err)
+		}
+	}
+	results := make([]*BlockCallResults, 0, len(blockNrs))
+	for _, blockNr := range blockNrs {
+		stateDb, block, err := stateAndBlockByNumber(s.bc, blockNr, s.chainDb)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", blockNr.Int64())
+		}
+		res := &BlockCallResults{
+			BlockNumber: (*hexutil.Big)(block.Number()),
+			BlockHash:   block.Hash(),
+			Results:     make([]CallResult, len(calls)),
+		}
+		for i, args := range calls {
+			output, gas, err := s.callOnState(ctx, args, stateDb, block)
+			res.Results[i] = CallResult{Result: output, GasUsed: (*hexutil.Big)(gas)}
+			if err != nil {
+				res.Results[i].Error = err.Error()
+			}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// Multicall executes the given calls in order on the state of a single block, returning the result and gas used of
+// each. When chained is true every call sees the state changes of the calls before it, otherwise every call starts
+// from the unmodified block state. A failing call is reported in its result and doesn't abort the others.
+func (s *PublicBlockChainAPI) Multicall(ctx context.Context, calls []CallArgs, blockNr rpc.BlockNumber, chained *bool) ([]CallResult, error) {
+	if len(calls) > maxCallManyCalls {
+		return nil, fmt.Errorf("too many calls: %d exceed the limit of %d", len(calls), maxCallManyCalls)
+	}
+	for i := range calls {
+		if err := calls[i].resolveNames(s.ens); err != nil {
+			return nil, fmt.Errorf("call %d: %v", i, err)
+		}
+	}
+	stateDb, block, err := stateAndBlockByNumber(s.bc, blockNr, s.chainDb)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr.Int64())
+	}
+	call := s.callOnState
+	if chained != nil && *chained {
+		stateDb = stateDb.Copy()
+		call = s.applyCall
+	}
+	results := make([]CallResult, len(calls))
+	for i, args := range calls {
+		output, gas, err := call(ctx, args, stateDb, block)
+		results[i] = CallResult{Result: output, GasUsed: (*hexutil.Big)(gas)}
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+	return results, nil
+}
+
 // EstimateGas returns an estimate of the amount of gas needed to execute the given transaction.
-func (s *PublicBlockChainAPI) EstimateGas(args CallArgs) (*rpc.HexNumber, error) {
-	_, gas, err := s.doCall(args, rpc.PendingBlockNumber)
-	return rpc.NewHexNumber(gas), err
+func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (*hexutil.Big, error) {
+	_, gas, err := s.doCall(ctx, args, rpc.PendingBlockNumber)
+	return (*hexutil.Big)(gas), err
 }
 
 // rpcOutputBlock converts the given block to the RPC output which depends on fullTx. If inclTx is true transactions are
 // returned. When fullTx is true the returned block contains full transaction details, otherwise it will only contain
 // transaction hashes.
 func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx bool) (map[string]interface{}, error) {
+	return s.rpcOutputBlockFields(b, inclTx, fullTx, nil)
+}
+
+// rpcOutputBlockFields is like rpcOutputBlock, restricting the fully detailed
+// transactions to the given fields if any are listed.
+func (s *PublicBlockChainAPI) rpcOutputBlockFields(b *types.Block, inclTx bool, fullTx bool, txFields []string) (map[string]interface{}, error) {
+	for _, field := range txFields {
+		if _, ok := rpcTransactionFields[field]; !ok {
+			return nil, fmt.Errorf("unknown transaction field %q", field)
+		}
+	}
 	fields := map[string]interface{}{
-		"number":           rpc.NewHexNumber(b.Number()),
+		"number":           (*hexutil.Big)(b.Number()),
 		"hash":             b.Hash(),
 		"parentHash":       b.ParentHash(),
 		"nonce":            b.Header().Nonce,
@@ -683,13 +1462,13 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 		"sha3Uncles":       b.UncleHash(),
 		"logsBloom":        b.Bloom(),
 		"stateRoot":        b.Root(),
-		"difficulty":       rpc.NewHexNumber(b.Difficulty()),
-		"totalDifficulty":  rpc.NewHexNumber(s.bc.GetTd(b.Hash())),
-		"extraData":        fmt.Sprintf("0x%x", b.Extra()),
-		"size":             rpc.NewHexNumber(b.Size().Int64()),
-		"gasLimit":         rpc.NewHexNumber(b.GasLimit()),
-		"gasUsed":          rpc.NewHexNumber(b.GasUsed()),
-		"timestamp":        rpc.NewHexNumber(b.Time()),
+		"difficulty":       (*hexutil.Big)(b.Difficulty()),
+		"totalDifficulty":  (*hexutil.Big)(s.bc.GetTd(b.Hash())),
+		"extraData":        hexutil.Bytes(b.Extra()),
+		"size":             hexutil.Uint64(b.Size().Int64()),
+		"gasLimit":         (*hexutil.Big)(b.GasLimit()),
+		"gasUsed":          (*hexutil.Big)(b.GasUsed()),
+		"timestamp":        (*hexutil.Big)(b.Time()),
 		"transactionsRoot": b.TxHash(),
 		"receiptsRoot":     b.ReceiptHash(),
 	}
@@ -706,6 +1485,18 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 				}
 				return newRPCTransaction(b, tx.Hash())
 			}
+			if len(txFields) > 0 {
+				formatTx = func(tx *types.Transaction) (interface{}, error) {
+					if tx.Protected() {
+						tx.SetSigner(types.NewChainIdSigner(s.bc.Config().GetChainID()))
+					}
+					rpcTx, err := newRPCTransaction(b, tx.Hash())
+					if rpcTx == nil || err != nil {
+						return nil, err
+					}
+					return rpcTx.project(txFields), nil
+				}
+			}
 		}
 
 		txs := b.Transactions()
@@ -732,21 +1523,58 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
 type RPCTransaction struct {
 	BlockHash        common.Hash     `json:"blockHash"`
-	BlockNumber      *rpc.HexNumber  `json:"blockNumber"`
+	BlockNumber      *hexutil.Big    `json:"blockNumber"`
 	From             common.Address  `json:"from"`
-	Gas              *rpc.HexNumber  `json:"gas"`
-	GasPrice         *rpc.HexNumber  `json:"gasPrice"`
+	Gas              *hexutil.Big    `json:"gas"`
+	GasPrice         *hexutil.Big    `json:"gasPrice"`
 	Hash             common.Hash     `json:"hash"`
-	Input            string          `json:"input"`
-	Nonce            *rpc.HexNumber  `json:"nonce"`
+	Input            hexutil.Bytes   `json:"input"`
+	Nonce            hexutil.Uint64  `json:"nonce"`
 	To               *common.Address `json:"to"`
-	TransactionIndex *rpc.HexNumber  `json:"transactionIndex"`
-	Value            *rpc.HexNumber  `json:"value"`
+	TransactionIndex *hexutil.Uint   `json:"transactionIndex"`
+	Value            *hexutil.Big    `json:"value"`
 	ReplayProtected  bool            `json:"replayProtected"`
-	ChainId          *big.Int        `json:"chainId,omitempty"`
-	V                *rpc.HexNumber  `json:"v"`
-	R                *rpc.HexNumber  `json:"r"`
-	S                *rpc.HexNumber  `json:"s"`
+	ChainId          *hexutil.Big    `json:"chainId,omitempty"`
+	V                *hexutil.Big    `json:"v"`
+	R                *hexutil.Big    `json:"r"`
+	S                *hexutil.Big    `json:"s"`
+}
+
+// rpcTransactionFields maps the JSON fields of an RPCTransaction to their values,
+// for returning only the fields requested.
+var rpcTransactionFields = map[string]func(tx *RPCTransaction) interface{}{
+	"blockHash":        func(tx *RPCTransaction) interface{} { return tx.BlockHash },
+	"blockNumber":      func(tx *RPCTransaction) interface{} { return tx.BlockNumber },
+	"from":             func(tx *RPCTransaction) interface{} { return tx.From },
+	"gas":              func(tx *RPCTransaction) interface{} { return tx.Gas },
+	"gasPrice":         func(tx *RPCTransaction) interface{} { return tx.GasPrice },
+	"hash":             func(tx *RPCTransaction) interface{} { return tx.Hash },
+	"input":            func(tx *RPCTransaction) interface{} { return tx.Input },
+	"nonce":            func(tx *RPCTransaction) interface{} { return tx.Nonce },
+	"to":               func(tx *RPCTransaction) interface{} { return tx.To },
+	"transactionIndex": func(tx *RPCTransaction) interface{} { return tx.TransactionIndex },
+	"value":            func(tx *RPCTransaction) interface{} { return tx.Value },
+	"replayProtected":  func(tx *RPCTransaction) interface{} { return tx.ReplayProtected },
+	"chainId":          func(tx *RPCTransaction) interface{} { return tx.ChainId },
+	"v":                func(tx *RPCTransaction) interface{} { return tx.V },
+	"r":                func(tx *RPCTransaction) interface{} { return tx.R },
+	"s":                func(tx *RPCTransaction) interface{} { return tx.S },
+}
+
+// project returns the given fields of the transaction only.
+func (tx *RPCTransaction) project(fields []string) map[string]interface{} {
+	projected := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		projected[field] = rpcTransactionFields[field](tx)
+	}
+	return projected
+}
+
+func derefFields(fields *[]string) []string {
+	if fields == nil {
+		return nil
+	}
+	return *fields
 }
 
 // newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
@@ -754,21 +1582,21 @@ func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 	from, _ := tx.From()
 
 	var protected bool
-	var chainId *big.Int
+	var chainId *hexutil.Big
 	if tx.Protected() {
 		protected = true
-		chainId = tx.ChainId()
+		chainId = (*hexutil.Big)(tx.ChainId())
 	}
 
 	return &RPCTransaction{
 		From:            from,
-		Gas:             rpc.NewHexNumber(tx.Gas()),
-		GasPrice:        rpc.NewHexNumber(tx.GasPrice()),
+		Gas:             (*hexutil.Big)(tx.Gas()),
+		GasPrice:        (*hexutil.Big)(tx.GasPrice()),
 		Hash:            tx.Hash(),
-		Input:           fmt.Sprintf("0x%x", tx.Data()),
-		Nonce:           rpc.NewHexNumber(tx.Nonce()),
+		Input:           hexutil.Bytes(tx.Data()),
+		Nonce:           hexutil.Uint64(tx.Nonce()),
 		To:              tx.To(),
-		Value:           rpc.NewHexNumber(tx.Value()),
+		Value:           (*hexutil.Big)(tx.Value()),
 		ReplayProtected: protected,
 		ChainId:         chainId,
 	}
@@ -780,33 +1608,34 @@ func newRPCTransactionFromBlockIndex(b *types.Block, txIndex int) (*RPCTransacti
 		tx := b.Transactions()[txIndex]
 		var signer types.Signer = types.BasicSigner{}
 		var protected bool
-		var chainId *big.Int
+		var chainId *hexutil.Big
 		if tx.Protected() {
 			signer = types.NewChainIdSigner(tx.ChainId())
 			protected = true
-			chainId = tx.ChainId()
+			chainId = (*hexutil.Big)(tx.ChainId())
 		}
 		from, _ := types.Sender(signer, tx)
 
 		v, r, s := tx.RawSignatureValues()
+		index := hexutil.Uint(txIndex)
 
 		return &RPCTransaction{
 			BlockHash:        b.Hash(),
-			BlockNumber:      rpc.NewHexNumber(b.Number()),
+			BlockNumber:      (*hexutil.Big)(b.Number()),
 			From:             from,
-			Gas:              rpc.NewHexNumber(tx.Gas()),
-			GasPrice:         rpc.NewHexNumber(tx.GasPrice()),
+			Gas:              (*hexutil.Big)(tx.Gas()),
+			GasPrice:         (*hexutil.Big)(tx.GasPrice()),
 			Hash:             tx.Hash(),
-			Input:            fmt.Sprintf("0x%x", tx.Data()),
-			Nonce:            rpc.NewHexNumber(tx.Nonce()),
+			Input:            hexutil.Bytes(tx.Data()),
+			Nonce:            hexutil.Uint64(tx.Nonce()),
 			To:               tx.To(),
-			TransactionIndex: rpc.NewHexNumber(txIndex),
-			Value:            rpc.NewHexNumber(tx.Value()),
+			TransactionIndex: &index,
+			Value:            (*hexutil.Big)(tx.Value()),
 			ReplayProtected:  protected,
 			ChainId:          chainId,
-			V:                rpc.NewHexNumber(v),
-			R:                rpc.NewHexNumber(r),
-			S:                rpc.NewHexNumber(s),
+			V:                (*hexutil.Big)(v),
+			R:                (*hexutil.Big)(r),
+			S:                (*hexutil.Big)(s),
 		}, nil
 	}
 
@@ -826,50 +1655,31 @@ func newRPCTransaction(b *types.Block, txHash common.Hash) (*RPCTransaction, err
 
 // PublicTransactionPoolAPI exposes methods for the RPC interface
 type PublicTransactionPoolAPI struct {
-	eventMux        *event.TypeMux
-	chainDb         ethdb.Database
-	gpo             *GasPriceOracle
-	bc              *core.BlockChain
-	am              *accounts.Manager
-	txPool          *core.TxPool
-	txMu            *sync.Mutex
-	muPendingTxSubs sync.Mutex
-	pendingTxSubs   map[string]rpc.Subscription
+	eventMux *event.TypeMux
+	chainDb  ethdb.Database
+	gpo      *GasPriceOracle
+	bc       *core.BlockChain
+	am       *accounts.Manager
+	txPool   *core.TxPool
+	txMu     *sync.Mutex
+	nonces   *nonceManager
+	ens      *registrar.ENS
+	expirer  *txExpirer
 }
 
 // NewPublicTransactionPoolAPI creates a new RPC service with methods specific for the transaction pool.
-func NewPublicTransactionPoolAPI(e *Ethereum) *PublicTransactionPoolAPI {
-	api := &PublicTransactionPoolAPI{
-		eventMux:      e.eventMux,
-		gpo:           e.gpo,
-		chainDb:       e.chainDb,
-		bc:            e.blockchain,
-		am:            e.accountManager,
-		txPool:        e.txPool,
-		txMu:          &e.txMu,
-		pendingTxSubs: make(map[string]rpc.Subscription),
-	}
-	go api.subscriptionLoop()
-
-	return api
-}
-
-// subscriptionLoop listens for events on the global event mux and creates notifications for subscriptions.
-func (s *PublicTransactionPoolAPI) subscriptionLoop() {
-	sub := s.eventMux.Subscribe(core.TxPreEvent{})
-	for event := range sub.Chan() {
-		tx := event.Data.(core.TxPreEvent)
-		if from, err := tx.Tx.From(); err == nil {
-			if s.am.HasAddress(from) {
-				s.muPendingTxSubs.Lock()
-				for id, sub := range s.pendingTxSubs {
-					if sub.Notify(tx.Tx.Hash()) == rpc.ErrNotificationNotFound {
-						delete(s.pendingTxSubs, id)
-					}
-				}
-				s.muPendingTxSubs.Unlock()
-			}
-		}
+func NewPublicTransactionPoolAPI(e *Ethereum) *PublicTransactionPoolAPI {
+	return &PublicTransactionPoolAPI{
+		eventMux: e.eventMux,
+		gpo:      e.gpo,
+		chainDb:  e.chainDb,
+		bc:       e.blockchain,
+		am:       e.accountManager,
+		txPool:   e.txPool,
+		txMu:     &e.txMu,
+		nonces:   e.nonces,
+		ens:      e.ens,
+		expirer:  e.txExpiry,
 	}
 }
 
@@ -892,17 +1702,19 @@ func getTransaction(chainDb ethdb.Database, txPool *core.TxPool, txHash common.H
 }
 
 // GetBlockTransactionCountByNumber returns the number of transactions in the block with the given block number.
-func (s *PublicTransactionPoolAPI) GetBlockTransactionCountByNumber(blockNr rpc.BlockNumber) *rpc.HexNumber {
+func (s *PublicTransactionPoolAPI) GetBlockTransactionCountByNumber(blockNr rpc.BlockNumber) *hexutil.Uint {
 	if block := blockByNumber(s.bc, blockNr); block != nil {
-		return rpc.NewHexNumber(len(block.Transactions()))
+		n := hexutil.Uint(len(block.Transactions()))
+		return &n
 	}
 	return nil
 }
 
 // GetBlockTransactionCountByHash returns the number of transactions in the block with the given hash.
-func (s *PublicTransactionPoolAPI) GetBlockTransactionCountByHash(blockHash common.Hash) *rpc.HexNumber {
+func (s *PublicTransactionPoolAPI) GetBlockTransactionCountByHash(blockHash common.Hash) *hexutil.Uint {
 	if block := s.bc.GetBlock(blockHash); block != nil {
-		return rpc.NewHexNumber(len(block.Transactions()))
+		n := hexutil.Uint(len(block.Transactions()))
+		return &n
 	}
 	return nil
 }
@@ -924,12 +1736,17 @@ func (s *PublicTransactionPoolAPI) GetTransactionByBlockHashAndIndex(blockHash c
 }
 
 // GetTransactionCount returns the number of transactions the given address has sent for the given block number
-func (s *PublicTransactionPoolAPI) GetTransactionCount(address common.Address, blockNr rpc.BlockNumber) (*rpc.HexNumber, error) {
+func (s *PublicTransactionPoolAPI) GetTransactionCount(arg AddressOrName, blockNr rpc.BlockNumber) (*hexutil.Uint64, error) {
+	address, err := resolveAddress(s.ens, arg)
+	if err != nil {
+		return nil, err
+	}
 	state, _, err := stateAndBlockByNumber(s.bc, blockNr, s.chainDb)
 	if state == nil || err != nil {
 		return nil, err
 	}
-	return rpc.NewHexNumber(state.GetNonce(address)), nil
+	nonce := hexutil.Uint64(state.GetNonce(address))
+	return &nonce, nil
 }
 
 // getTransactionBlockData fetches the meta data for the given transaction from the chain database. This is useful to
@@ -1003,32 +1820,108 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(txHash common.Hash) (ma
 	}
 
 	if receipt.Status == types.TxStatusUnknown {
-		// To be able to get the proper state for n-th transaction in a block,
-		// all previous transactions has to be executed. Because of that, it is
-		// reasonable to reprocess entire block and update all receipts from
-		// given block.
-		proc := s.bc.Processor()
-		block := s.bc.GetBlock(txBlock)
-		parent := s.bc.GetBlock(block.ParentHash())
-		statedb, err := s.bc.StateAt(parent.Root())
-		if err != nil {
-			return nil, fmt.Errorf("state not found - transaction status is not available for fast synced block: %v", err)
-		}
-
-		receipts, _, _, err := proc.Process(block, statedb)
+		receipts, err := s.reprocessReceipts(txBlock)
 		if err != nil {
 			return nil, err
 		}
+		receipt = receipts[index]
+	}
+	return s.receiptFields(tx, receipt, txBlock, blockIndex, index), nil
+}
 
-		if err := core.WriteReceipts(s.chainDb, receipts); err != nil {
-			glog.V(logger.Warn).Infof("cannot save updated receipts: %v", err)
+// maxReceiptsBatch limits the number of receipts a single GetTransactionReceipts request can retrieve.
+const maxReceiptsBatch = 100
+
+// GetTransactionReceipts returns the receipts of the given transactions, in the
+// same order, with nil for the unknown ones. The receipts of the transactions
+// included in the same block are read from the database at once.
+func (s *PublicTransactionPoolAPI) GetTransactionReceipts(txHashes []common.Hash) ([]map[string]interface{}, error) {
+	if len(txHashes) > maxReceiptsBatch {
+		return nil, fmt.Errorf("too many transactions: %d, at most %d allowed", len(txHashes), maxReceiptsBatch)
+	}
+	type blockReceipts struct {
+		block    *types.Block
+		receipts types.Receipts
+	}
+	var (
+		fields = make([]map[string]interface{}, len(txHashes))
+		blocks = make(map[common.Hash]*blockReceipts)
+	)
+	for i, txHash := range txHashes {
+		entry := core.GetTxLookupEntry(s.chainDb, txHash)
+		if entry == nil {
+			blockHash, blockIndex, index, err := getTransactionBlockData(s.chainDb, txHash)
+			if err != nil {
+				continue
+			}
+			entry = &core.TxLookupEntry{BlockHash: blockHash, BlockIndex: blockIndex, Index: index}
+		}
+		cached, ok := blocks[entry.BlockHash]
+		if !ok {
+			cached = &blockReceipts{block: s.bc.GetBlock(entry.BlockHash)}
+			if cached.block != nil {
+				cached.receipts = core.GetBlockReceipts(s.chainDb, entry.BlockHash)
+			}
+			blocks[entry.BlockHash] = cached
 		}
-		if err := core.WriteBlockReceipts(s.chainDb, block.Hash(), receipts); err != nil {
-			glog.V(logger.Warn).Infof("cannot save updated block receipts: %v", err)
+		if cached.block == nil || entry.Index >= uint64(len(cached.block.Transactions())) {
+			continue
 		}
-		receipt = receipts[index]
+		tx := cached.block.Transactions()[entry.Index]
+		if tx.Hash() != txHash {
+			continue
+		}
+		var receipt *types.Receipt
+		if entry.Index < uint64(len(cached.receipts)) {
+			receipt = cached.receipts[entry.Index]
+		} else if receipt = core.GetReceipt(s.chainDb, txHash); receipt == nil {
+			glog.V(logger.Debug).Infof("receipt not found for transaction %s", txHash.Hex())
+			continue
+		}
+		if receipt.Status == types.TxStatusUnknown {
+			receipts, err := s.reprocessReceipts(entry.BlockHash)
+			if err != nil {
+				return nil, err
+			}
+			cached.receipts, receipt = receipts, receipts[entry.Index]
+		}
+		fields[i] = s.receiptFields(tx, receipt, entry.BlockHash, entry.BlockIndex, entry.Index)
+	}
+	return fields, nil
+}
+
+// reprocessReceipts re-executes the block with the given hash to recover the
+// status of its receipts, which is missing from those stored before it was
+// tracked, and stores the updated receipts.
+func (s *PublicTransactionPoolAPI) reprocessReceipts(blockHash common.Hash) (types.Receipts, error) {
+	// To be able to get the proper state for n-th transaction in a block,
+	// all previous transactions has to be executed. Because of that, it is
+	// reasonable to reprocess entire block and update all receipts from
+	// given block.
+	proc := s.bc.Processor()
+	block := s.bc.GetBlock(blockHash)
+	parent := s.bc.GetBlock(block.ParentHash())
+	statedb, err := s.bc.StateAt(parent.Root())
+	if err != nil {
+		return nil, fmt.Errorf("state not found - transaction status is not available for fast synced block: %v", err)
 	}
 
+	receipts, _, _, err := proc.Process(block, statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := core.WriteReceipts(s.chainDb, receipts); err != nil {
+		glog.V(logger.Warn).Infof("cannot save updated receipts: %v", err)
+	}
+	if err := core.WriteBlockReceipts(s.chainDb, block.Hash(), receipts); err != nil {
+		glog.V(logger.Warn).Infof("cannot save updated block receipts: %v", err)
+	}
+	return receipts, nil
+}
+
+// receiptFields formats the receipt of the given transaction for RPC clients.
+func (s *PublicTransactionPoolAPI) receiptFields(tx *types.Transaction, receipt *types.Receipt, txBlock common.Hash, blockIndex, index uint64) map[string]interface{} {
 	var signer types.Signer = types.BasicSigner{}
 	if tx.Protected() {
 		signer = types.NewChainIdSigner(tx.ChainId())
@@ -1036,15 +1929,15 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(txHash common.Hash) (ma
 	from, _ := types.Sender(signer, tx)
 
 	fields := map[string]interface{}{
-		"root":              common.Bytes2Hex(receipt.PostState),
+		"root":              hexutil.Bytes(receipt.PostState),
 		"blockHash":         txBlock,
-		"blockNumber":       rpc.NewHexNumber(blockIndex),
-		"transactionHash":   txHash,
-		"transactionIndex":  rpc.NewHexNumber(index),
+		"blockNumber":       hexutil.Uint64(blockIndex),
+		"transactionHash":   tx.Hash(),
+		"transactionIndex":  hexutil.Uint64(index),
 		"from":              from,
 		"to":                tx.To(),
-		"gasUsed":           rpc.NewHexNumber(receipt.GasUsed),
-		"cumulativeGasUsed": rpc.NewHexNumber(receipt.CumulativeGasUsed),
+		"gasUsed":           (*hexutil.Big)(receipt.GasUsed),
+		"cumulativeGasUsed": (*hexutil.Big)(receipt.CumulativeGasUsed),
 		"contractAddress":   nil,
 		"logs":              receipt.Logs,
 	}
@@ -1058,13 +1951,18 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(txHash common.Hash) (ma
 		fields["contractAddress"] = receipt.ContractAddress
 	}
 
-	// We're not fully compatible with EIP-609 - just return status for all blocks.
+	// Once EIP-658 is active the consensus post state is the status itself, so only
+	// the status is served, like other clients do. Before that the intermediate state
+	// root is served, along with the status for backwards compatibility.
 	fields["status"] = nil
 	if receipt.Status != types.TxStatusUnknown {
-		fields["status"] = rpc.NewHexNumber(receipt.Status)
+		fields["status"] = hexutil.Uint(receipt.Status)
+	}
+	if s.bc.Config().IsEIP658(new(big.Int).SetUint64(blockIndex)) || receipt.HasStatusPostState() {
+		delete(fields, "root")
 	}
 
-	return fields, nil
+	return fields
 }
 
 // sign is a helper function that signs a transaction with the private key of the given address.
@@ -1087,6 +1985,9 @@ type SendTxArgs struct {
 	Value    *rpc.HexNumber  `json:"value"`
 	Data     string          `json:"data"`
 	Nonce    *rpc.HexNumber  `json:"nonce"`
+	TxExpiryArgs
+
+	names ensNames // ENS names given instead of addresses
 }
 
 // prepareSendTxArgs is a helper function that fills in default values for unspecified tx fields.
@@ -1114,7 +2015,7 @@ func submitTransaction(bc *core.BlockChain, txPool *core.TxPool, tx *types.Trans
 
 	txPool.SetLocal(signedTx)
 	if err := txPool.Add(signedTx); err != nil {
-		return common.Hash{}, err
+		return common.Hash{}, explainTxPoolError(bc.Config(), signedTx, err)
 	}
 
 	if signedTx.To() == nil {
@@ -1128,16 +2029,33 @@ func submitTransaction(bc *core.BlockChain, txPool *core.TxPool, tx *types.Trans
 	return signedTx.Hash(), nil
 }
 
+// explainTxPoolError extends the replay protection errors of the transaction pool
+// with a hint on how to fix the transaction, for tooling predating EIP-155.
+func explainTxPoolError(config *core.ChainConfig, tx *types.Transaction, err error) error {
+	switch err {
+	case core.ErrInvalidChainId:
+		return fmt.Errorf("%v: transaction signed for chain id %v, this node expects chain id %v", err, tx.ChainId(), config.GetChainID())
+	case core.ErrUnprotectedTx:
+		return fmt.Errorf("%v: sign the transaction with chain id %v", err, config.GetChainID())
+	}
+	return err
+}
+
 // SendTransaction creates a transaction for the given argument, sign it and submit it to the
 // transaction pool.
-func (s *PublicTransactionPoolAPI) SendTransaction(args SendTxArgs) (common.Hash, error) {
+func (s *PublicTransactionPoolAPI) SendTransaction(args SendTxArgs) (hash common.Hash, err error) {
+	if err := args.validate(); err != nil {
+		return common.Hash{}, err
+	}
+	if err := args.resolveNames(s.ens); err != nil {
+		return common.Hash{}, err
+	}
 	args = prepareSendTxArgs(args, s.gpo)
 
-	s.txMu.Lock()
-	defer s.txMu.Unlock()
-
 	if args.Nonce == nil {
-		args.Nonce = rpc.NewHexNumber(s.txPool.State().GetNonce(args.From))
+		nonce := s.nonces.Reserve(args.From)
+		args.Nonce = rpc.NewHexNumber(nonce)
+		defer func() { s.nonces.Settle(args.From, nonce, err) }()
 	}
 
 	var tx *types.Transaction
@@ -1155,20 +2073,43 @@ func (s *PublicTransactionPoolAPI) SendTransaction(args SendTxArgs) (common.Hash
 		return common.Hash{}, err
 	}
 
-	return submitTransaction(s.bc, s.txPool, tx, signature)
+	if hash, err = submitTransaction(s.bc, s.txPool, tx, signature); err != nil {
+		return common.Hash{}, err
+	}
+	s.expirer.expire(hash, args.TxExpiryArgs)
+	return hash, nil
 }
 
 // SendRawTransaction will add the signed transaction to the transaction pool.
 // The sender is responsible for signing the transaction and using the correct nonce.
-func (s *PublicTransactionPoolAPI) SendRawTransaction(encodedTx string) (string, error) {
-	tx := new(types.Transaction)
-	if err := rlp.DecodeBytes(common.FromHex(encodedTx), tx); err != nil {
+// The optional expiry sets a time-to-live, though cancelling the transaction once
+// expired requires its sender to be an account of this node.
+func (s *PublicTransactionPoolAPI) SendRawTransaction(encodedTx string, expiry *TxExpiryArgs) (string, error) {
+	tx, err := types.DecodeTransaction(common.FromHex(encodedTx))
+	if err != nil {
 		return "", err
 	}
+	if expiry != nil {
+		if err := expiry.validate(); err != nil {
+			return "", err
+		}
+		if expiry.CancelOnExpiry {
+			from, err := tx.From()
+			if err != nil {
+				return "", err
+			}
+			if !s.am.HasAddress(from) {
+				return "", fmt.Errorf("cancelOnExpiry requires the sender %s to be an account of this node", from.Hex())
+			}
+		}
+	}
 
 	s.txPool.SetLocal(tx)
 	if err := s.txPool.Add(tx); err != nil {
-		return "", err
+		return "", explainTxPoolError(s.bc.Config(), tx, err)
+	}
+	if expiry != nil {
+		s.expirer.expire(tx.Hash(), *expiry)
 	}
 
 	if tx.To() == nil {
@@ -1256,11 +2197,11 @@ type Tx struct {
 
 	To       *common.Address `json:"to"`
 	From     common.Address  `json:"from"`
-	Nonce    *rpc.HexNumber  `json:"nonce"`
-	Value    *rpc.HexNumber  `json:"value"`
-	Data     string          `json:"data"`
-	GasLimit *rpc.HexNumber  `json:"gas"`
-	GasPrice *rpc.HexNumber  `json:"gasPrice"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Value    *hexutil.Big    `json:"value"`
+	Data     hexutil.Bytes   `json:"data"`
+	GasLimit *hexutil.Big    `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
 	Hash     common.Hash     `json:"hash"`
 }
 
@@ -1277,38 +2218,36 @@ func (tx *Tx) UnmarshalJSON(b []byte) (err error) {
 		Hash     common.Hash     `json:"hash"`
 	}{}
 
-	if err := json.Unmarshal(b, &req); err != nil {
+	if err := rpc.UnmarshalStrict(b, &req); err != nil {
 		return err
 	}
 
-	tx.To = req.To
-	tx.From = req.From
-	tx.Nonce = req.Nonce
-	tx.Value = req.Value
-	tx.Data = req.Data
-	tx.GasLimit = req.GasLimit
-	tx.GasPrice = req.GasPrice
-	tx.Hash = req.Hash
-
-	data := common.Hex2Bytes(tx.Data)
-
-	if tx.Nonce == nil {
+	if req.Nonce == nil {
 		return fmt.Errorf("need nonce")
 	}
-	if tx.Value == nil {
-		tx.Value = rpc.NewHexNumber(0)
+	if req.Value == nil {
+		req.Value = rpc.NewHexNumber(0)
 	}
-	if tx.GasLimit == nil {
-		tx.GasLimit = rpc.NewHexNumber(0)
+	if req.GasLimit == nil {
+		req.GasLimit = rpc.NewHexNumber(0)
 	}
-	if tx.GasPrice == nil {
-		tx.GasPrice = rpc.NewHexNumber(int64(50000000000))
+	if req.GasPrice == nil {
+		req.GasPrice = rpc.NewHexNumber(int64(50000000000))
 	}
 
+	tx.To = req.To
+	tx.From = req.From
+	tx.Nonce = hexutil.Uint64(req.Nonce.Uint64())
+	tx.Value = (*hexutil.Big)(req.Value.BigInt())
+	tx.Data = common.FromHex(req.Data)
+	tx.GasLimit = (*hexutil.Big)(req.GasLimit.BigInt())
+	tx.GasPrice = (*hexutil.Big)(req.GasPrice.BigInt())
+	tx.Hash = req.Hash
+
 	if req.To == nil {
-		tx.tx = types.NewContractCreation(tx.Nonce.Uint64(), tx.Value.BigInt(), tx.GasLimit.BigInt(), tx.GasPrice.BigInt(), data)
+		tx.tx = types.NewContractCreation(uint64(tx.Nonce), tx.Value.ToInt(), tx.GasLimit.ToInt(), tx.GasPrice.ToInt(), tx.Data)
 	} else {
-		tx.tx = types.NewTransaction(tx.Nonce.Uint64(), *tx.To, tx.Value.BigInt(), tx.GasLimit.BigInt(), tx.GasPrice.BigInt(), data)
+		tx.tx = types.NewTransaction(uint64(tx.Nonce), *tx.To, tx.Value.ToInt(), tx.GasLimit.ToInt(), tx.GasPrice.ToInt(), tx.Data)
 	}
 
 	return nil
@@ -1316,8 +2255,8 @@ func (tx *Tx) UnmarshalJSON(b []byte) (err error) {
 
 // SignTransactionResult represents a RLP encoded signed transaction.
 type SignTransactionResult struct {
-	Raw string `json:"raw"`
-	Tx  *Tx    `json:"tx"`
+	Raw hexutil.Bytes `json:"raw"`
+	Tx  *Tx           `json:"tx"`
 }
 
 func newTx(t *types.Transaction) *Tx {
@@ -1330,11 +2269,11 @@ func newTx(t *types.Transaction) *Tx {
 		tx:       t,
 		To:       t.To(),
 		From:     from,
-		Value:    rpc.NewHexNumber(t.Value()),
-		Nonce:    rpc.NewHexNumber(t.Nonce()),
-		Data:     "0x" + common.Bytes2Hex(t.Data()),
-		GasLimit: rpc.NewHexNumber(t.Gas()),
-		GasPrice: rpc.NewHexNumber(t.GasPrice()),
+		Value:    (*hexutil.Big)(t.Value()),
+		Nonce:    hexutil.Uint64(t.Nonce()),
+		Data:     hexutil.Bytes(t.Data()),
+		GasLimit: (*hexutil.Big)(t.Gas()),
+		GasPrice: (*hexutil.Big)(t.GasPrice()),
 		Hash:     t.Hash(),
 	}
 }
@@ -1377,50 +2316,17 @@ func (s *PublicTransactionPoolAPI) SignTransaction(args SignTransactionArgs) (*S
 		return nil, err
 	}
 
-	return &SignTransactionResult{"0x" + common.Bytes2Hex(data), newTx(signedTx)}, nil
+	return &SignTransactionResult{data, newTx(signedTx)}, nil
 }
 
 // PendingTransactions returns the transactions that are in the transaction pool and have a from address that is one of
-// the accounts this node manages.
-func (s *PublicTransactionPoolAPI) PendingTransactions() []*RPCTransaction {
-	pending := s.txPool.GetTransactions()
-	transactions := make([]*RPCTransaction, 0, len(pending))
-	for _, tx := range pending {
-		var signer types.Signer = types.BasicSigner{}
-		if tx.Protected() {
-			signer = types.NewChainIdSigner(tx.ChainId())
-		}
-		from, _ := types.Sender(signer, tx)
-		if s.am.HasAddress(from) {
-			transactions = append(transactions, newRPCPendingTransaction(tx))
-		}
-	}
-	return transactions
-}
-
-// NewPendingTransactions creates a subscription that is triggered each time a transaction enters the transaction pool
-// and is send from one of the transactions this nodes manages.
-func (s *PublicTransactionPoolAPI) NewPendingTransactions(ctx context.Context) (rpc.Subscription, error) {
-	notifier, supported := rpc.NotifierFromContext(ctx)
-	if !supported {
-		return nil, rpc.ErrNotificationsUnsupported
-	}
-
-	subscription, err := notifier.NewSubscription(func(id string) {
-		s.muPendingTxSubs.Lock()
-		delete(s.pendingTxSubs, id)
-		s.muPendingTxSubs.Unlock()
-	})
-
-	if err != nil {
-		return nil, err
+// the accounts this node manages. The optional query narrows the result down to a single sender and selects a page
+// of it; transactions are ordered by sender and nonce so pages are stable while the pool content doesn't change.
+func (s *PublicTransactionPoolAPI) PendingTransactions(query *PendingTxQuery) ([]*RPCTransaction, error) {
+	if query != nil && query.From != nil && !s.am.HasAddress(*query.From) {
+		return nil, fmt.Errorf("account %s is not managed by this node", query.From.Hex())
 	}
-
-	s.muPendingTxSubs.Lock()
-	s.pendingTxSubs[subscription.ID()] = subscription
-	s.muPendingTxSubs.Unlock()
-
-	return subscription, nil
+	return filterPendingTransactions(s.txPool.GetTransactions(), query, s.am.HasAddress)
 }
 
 // Resend accepts an existing transaction and a new gas price and limit. It will remove the given transaction from the
@@ -1470,12 +2376,24 @@ func (s *PublicTransactionPoolAPI) Resend(tx Tx, gasPrice, gasLimit *rpc.HexNumb
 // admin endpoint.
 type PrivateAdminAPI struct {
 	eth *Ethereum
+
+	activity     *lru.Cache // Unfinished account activity exports by activityCursorKey of their next chunk
+	activityLock sync.Mutex
 }
 
 // NewPrivateAdminAPI creates a new API definition for the private admin methods
 // of the Ethereum service.
 func NewPrivateAdminAPI(eth *Ethereum) *PrivateAdminAPI {
-	return &PrivateAdminAPI{eth: eth}
+	activity, _ := lru.New(activityCursorCacheSize)
+	return &PrivateAdminAPI{eth: eth, activity: activity}
+}
+
+// SetGpoMinGasPrice sets the lowest gas price suggested by the gas price oracle,
+// independent of the price the pool accepts.
+func (api *PrivateAdminAPI) SetGpoMinGasPrice(price hexutil.Big) bool {
+	api.eth.GasPriceOracle().SetMinPrice((*big.Int)(&price))
+	glog.V(logger.Info).Infof("Gas price oracle minimum set to %v", (*big.Int)(&price))
+	return true
 }
 
 // SetSolc sets the Solidity compiler path to be used by the node.
@@ -1487,6 +2405,109 @@ func (api *PrivateAdminAPI) SetSolc(path string) (string, error) {
 	return solc.Info(), nil
 }
 
+// HttpGet retrieves the document at uri, which may also be a file://, ipfs:// or
+// bzz:// URI. If path is non-empty the document is also saved there.
+func (api *PrivateAdminAPI) HttpGet(uri, path string) (string, error) {
+	content, err := api.eth.HTTPClient().Get(uri, path)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// ResolveENS resolves a name through the configured ENS registry.
+func (api *PrivateAdminAPI) ResolveENS(name string) (common.Address, error) {
+	return resolveName(api.eth.ens, name)
+}
+
+// SetVyper sets the Vyper compiler path to be used by the node.
+func (api *PrivateAdminAPI) SetVyper(path string) (string, error) {
+	vyper, err := api.eth.SetVyper(path)
+	if err != nil {
+		return "", err
+	}
+	return vyper.Info(), nil
+}
+
+// CompilerInfo describes a contract compiler available on the node.
+type CompilerInfo struct {
+	Language string `json:"language"`
+	Version  string `json:"version"`
+	Info     string `json:"info"`
+}
+
+// Compilers lists the contract compilers available on the node, along with
+// their versions.
+func (api *PrivateAdminAPI) Compilers() []*CompilerInfo {
+	infos := []*CompilerInfo{}
+	for _, c := range api.eth.Compilers() {
+		infos = append(infos, &CompilerInfo{Language: c.Language(), Version: c.Version(), Info: c.Info()})
+	}
+	return infos
+}
+
+// Doctor runs the node's self-checks and returns their outcome.
+func (api *PrivateAdminAPI) Doctor() *DoctorReport {
+	return api.eth.Doctor()
+}
+
+// FreezeImport pauses block import once the imports in progress finish, e.g. to take a consistent backup of the
+// database. If timeout (in seconds) is given, import resumes automatically after it elapses.
+func (api *PrivateAdminAPI) FreezeImport(timeout *uint64) (bool, error) {
+	var d time.Duration
+	if timeout != nil {
+		d = time.Duration(*timeout) * time.Second
+	}
+	if err := api.eth.BlockChain().FreezeImport(d); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ThawImport resumes block import paused by FreezeImport.
+func (api *PrivateAdminAPI) ThawImport() (bool, error) {
+	if err := api.eth.BlockChain().ThawImport(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ImportFreezeStatus reports whether block import is frozen.
+func (api *PrivateAdminAPI) ImportFreezeStatus() core.ImportFreezeStatus {
+	return api.eth.BlockChain().ImportFreezeStatus()
+}
+
+// ExportGenesis reconstructs the chain configuration of the node from its database, with the genesis block
+// header fields and allocations, for bootstrapping further nodes of the same network with the --chain flag.
+// The identity of the exported configuration defaults to one derived from the genesis hash.
+func (api *PrivateAdminAPI) ExportGenesis(identity *string) (*core.SufficientChainConfig, error) {
+	genesis, err := core.MakeGenesisDump(api.eth.ChainDb())
+	if err != nil {
+		return nil, err
+	}
+	if genesis == nil {
+		return nil, errors.New("genesis block not found")
+	}
+	config := &core.SufficientChainConfig{
+		Network:     api.eth.netVersionId,
+		Consensus:   api.eth.config.Sealer.Consensus(),
+		Genesis:     genesis,
+		ChainConfig: api.eth.chainConfig,
+	}
+	if identity != nil {
+		config.Identity = *identity
+	} else {
+		config.Identity = fmt.Sprintf("genesis-%x", api.eth.BlockChain().Genesis().Hash().Bytes()[:4])
+	}
+	if state.StartingNonce != 0 {
+		config.State = &core.StateConfig{StartingNonce: state.StartingNonce}
+	}
+	if reason, ok := config.IsValid(); !ok {
+		return nil, fmt.Errorf("invalid chain configuration, missing or malformed %s", reason)
+	}
+	return config, nil
+}
+
 // ExportChain exports the current blockchain into a local file.
 func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
 	// Make sure we can create the file to export into
@@ -1607,6 +2628,108 @@ func (api *PublicGethAPI) GetAddressTransactions(address common.Address, blockSt
 	return list, nil
 }
 
+// ContractCreation is the transaction which created a contract.
+type ContractCreation struct {
+	TransactionHash common.Hash    `json:"transactionHash"`
+	Creator         common.Address `json:"creator"`
+	BlockNumber     hexutil.Uint64 `json:"blockNumber"`
+}
+
+// GetContractCreation returns the transaction which created the given contract, or nil if it isn't indexed.
+// Contracts are indexed along with the address-transaction index, so atxi must be enabled; indexes built
+// before contract creations were tracked have to be rebuilt with geth_buildATXI to cover past blocks.
+func (api *PublicGethAPI) GetContractCreation(contract common.Address) (*ContractCreation, error) {
+	atxi := api.eth.BlockChain().GetAtxi()
+	if atxi == nil {
+		return nil, errors.New("addr-tx indexing not enabled")
+	}
+	creation, err := core.GetContractCreation(atxi.Db, contract)
+	if creation == nil || err != nil {
+		return nil, err
+	}
+	return &ContractCreation{
+		TransactionHash: creation.TxHash,
+		Creator:         creation.Creator,
+		BlockNumber:     hexutil.Uint64(creation.BlockNumber),
+	}, nil
+}
+
+// InternalTransaction is a value transfer made by a contract, as seen from one of the accounts involved.
+type InternalTransaction struct {
+	TransactionHash common.Hash    `json:"transactionHash"`
+	BlockNumber     hexutil.Uint64 `json:"blockNumber"`
+	From            common.Address `json:"from"`
+	To              common.Address `json:"to"`
+	Value           *hexutil.Big   `json:"value"`
+	Type            string         `json:"type"` // CALL, CREATE or SUICIDE
+}
+
+// GetInternalTransactions returns the value transfers made by contracts to or from the given address, newest
+// first. toOrFrom is 't', 'f' or 'b' for both, blockEndN 0 or latest meaning unbounded. The index is built with
+// the 'itxi-build' command and stored along with the address-transaction index, so atxi must be enabled.
+func (api *PublicGethAPI) GetInternalTransactions(address common.Address, blockStartN uint64, blockEndN rpc.BlockNumber, toOrFrom string) ([]*InternalTransaction, error) {
+	atxi := api.eth.BlockChain().GetAtxi()
+	if atxi == nil {
+		return nil, errors.New("addr-tx indexing not enabled")
+	}
+	if blockEndN == rpc.LatestBlockNumber || blockEndN == rpc.PendingBlockNumber {
+		blockEndN = 0
+	}
+	var direction byte
+	if toOrFrom == "tf" || toOrFrom == "ft" {
+		toOrFrom = "b"
+	}
+	if len(toOrFrom) > 0 {
+		direction = toOrFrom[0]
+	}
+	txs, err := core.GetInternalTxs(atxi.Db, address, blockStartN, uint64(blockEndN.Int64()), direction)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*InternalTransaction, len(txs))
+	for i, tx := range txs {
+		results[i] = &InternalTransaction{
+			TransactionHash: tx.TxHash,
+			BlockNumber:     hexutil.Uint64(tx.BlockNumber),
+			From:            address,
+			To:              tx.Counterparty,
+			Value:           (*hexutil.Big)(tx.Value),
+			Type:            tx.Op.String(),
+		}
+		if tx.Direction == 't' {
+			results[i].From, results[i].To = tx.Counterparty, address
+		}
+	}
+	return results, nil
+}
+
+// GetLogCount returns the number of logs emitted in the blocks [fromBlock, toBlock] by the given contract, with the
+// given first topic (the event signature) or both. The counters are kept along with the address-transaction index,
+// so atxi must be enabled; blocks indexed before the counters were introduced have to be rebuilt with
+// geth_buildATXI to be counted.
+func (api *PublicGethAPI) GetLogCount(address *common.Address, topic *common.Hash, fromBlock, toBlock rpc.BlockNumber) (*hexutil.Uint64, error) {
+	atxi := api.eth.BlockChain().GetAtxi()
+	if atxi == nil {
+		return nil, errors.New("addr-tx indexing not enabled")
+	}
+	to := uint64(math.MaxUint64)
+	if toBlock != rpc.LatestBlockNumber && toBlock != rpc.PendingBlockNumber {
+		to = uint64(toBlock.Int64())
+	}
+	from := uint64(0)
+	if fromBlock > 0 {
+		from = uint64(fromBlock.Int64())
+	}
+	if from > to {
+		return nil, fmt.Errorf("invalid block range: #%d is after #%d", from, to)
+	}
+	count, err := core.GetLogCount(atxi.Db, address, topic, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Uint64)(&count), nil
+}
+
 func (api *PublicGethAPI) BuildATXI(start, stop, step rpc.BlockNumber) (bool, error) {
 	glog.V(logger.Debug).Infof("RPC call: geth_buildATXI %v %v %v", start, stop, step)
 
@@ -1671,9 +2794,74 @@ func NewPublicDebugAPI(eth *Ethereum) *PublicDebugAPI {
 	return &PublicDebugAPI{eth: eth}
 }
 
+// PrivateDebugAPI is the collection of Etheruem APIs exposed over the private
+// debugging endpoint.
+type PrivateDebugAPI struct {
+	eth *Ethereum
+}
+
+// NewPrivateDebugAPI creates a new API definition for the private debug methods
+// of the Ethereum service.
+func NewPrivateDebugAPI(eth *Ethereum) *PrivateDebugAPI {
+	return &PrivateDebugAPI{eth: eth}
+}
+
+// DatabaseUsage estimates the disk usage of the chain and index databases by
+// key family (headers, bodies, receipts, state trie nodes, code, indexes, ...).
+// Rather than iterating the whole database, the key space is split into the
+// given number of ranges (1024 if omitted) whose sizes are attributed to the
+// families of the first entries in them, more ranges giving a finer estimate.
+// As the estimate seeks through the whole key space, it is only served on the
+// private debug API.
+func (api *PrivateDebugAPI) DatabaseUsage(samples *rpc.HexNumber) (map[string]*core.DatabaseUsage, error) {
+	if samples == nil {
+		samples = rpc.NewHexNumber(core.DefaultUsageSamples)
+	}
+	n := samples.Uint64()
+	if n == 0 || n > core.MaxUsageSamples {
+		return nil, fmt.Errorf("samples must be within [1, %d]", core.MaxUsageSamples)
+	}
+	usage := make(map[string]*core.DatabaseUsage)
+	chain, err := core.ChainDatabaseUsage(api.eth.chainDb, int(n))
+	if err != nil {
+		return nil, err
+	}
+	usage["chaindata"] = chain
+	if api.eth.indexesDb != nil {
+		indexes, err := core.IndexDatabaseUsage(api.eth.indexesDb, int(n))
+		if err != nil {
+			return nil, err
+		}
+		usage["indexes"] = indexes
+	}
+	return usage, nil
+}
+
+// ChainHeads returns the chain heads advertised by the connected peers, both
+// canonical and on competing branches, with their total difficulties and the
+// peers they were received from.
+func (api *PublicDebugAPI) ChainHeads() []*ChainHead {
+	return api.eth.protocolManager.forks.Heads()
+}
+
+// BlockStats returns the uncle rate over the given number of recent canonical
+// blocks (1000 if omitted) and the average delay with which new blocks arrive
+// from the network.
+func (api *PublicDebugAPI) BlockStats(window *rpc.HexNumber) (*BlockStats, error) {
+	if window == nil {
+		window = rpc.NewHexNumber(maxStatsWindow)
+	}
+	n := window.Uint64()
+	if n == 0 || n > maxStatsWindow {
+		return nil, fmt.Errorf("window must be within [1, %d]", maxStatsWindow)
+	}
+	pm := api.eth.protocolManager
+	return pm.blockStats.stats(pm.blockchain, n), nil
+}
+
 // DumpBlock retrieves the entire state of the database at a given block.
 // TODO: update to be able to dump for specific addresses?
-func (api *PublicDebugAPI) DumpBlock(number uint64) (state.Dump, error) {
+func (api *PublicDebugAPI) DumpBlock(ctx context.Context, number uint64) (state.Dump, error) {
 	block := api.eth.BlockChain().GetBlockByNumber(number)
 	if block == nil {
 		return state.Dump{}, fmt.Errorf("block #%d not found", number)
@@ -1682,7 +2870,11 @@ func (api *PublicDebugAPI) DumpBlock(number uint64) (state.Dump, error) {
 	if err != nil {
 		return state.Dump{}, err
 	}
-	return stateDb.RawDump([]common.Address{}), nil
+	dump, err := stateDb.RawDumpContext(ctx, []common.Address{})
+	if err != nil {
+		return state.Dump{}, executionAborted(err)
+	}
+	return dump, nil
 }
 
 // AccountExist checks whether an address is considered exists at a given block.
@@ -1721,16 +2913,45 @@ func (api *PublicDebugAPI) PrintBlock(number uint64) (string, error) {
 }
 
 // SeedHash retrieves the seed hash of a block.
-func (api *PublicDebugAPI) SeedHash(number uint64) (string, error) {
+func (api *PublicDebugAPI) SeedHash(number uint64) (hexutil.Bytes, error) {
 	block := api.eth.BlockChain().GetBlockByNumber(number)
 	if block == nil {
-		return "", fmt.Errorf("block #%d not found", number)
+		return nil, fmt.Errorf("block #%d not found", number)
 	}
-	hash, err := GetSeedHash(number)
-	if err != nil {
-		return "", err
+	return GetSeedHash(number)
+}
+
+// DatabaseCacheStats reports the usage of the block cache of a database.
+type DatabaseCacheStats struct {
+	Size     int `json:"size"`     // Bytes held in the cache
+	Capacity int `json:"capacity"` // Bytes allotted to the cache, see --cache
+}
+
+// CacheStats are the statistics of the node's caches.
+type CacheStats struct {
+	Chain    map[string]core.CacheStats     `json:"chain"`    // Header, block and receipt caches
+	Senders  core.CacheStats                `json:"senders"`  // Transaction senders
+	Database map[string]*DatabaseCacheStats `json:"database"` // Database block caches, by database
+}
+
+// CacheStats returns the sizes and hit rates of the node's caches, to help
+// tuning the cache allowance.
+func (api *PublicDebugAPI) CacheStats() *CacheStats {
+	hits, misses := types.SenderCacheStats()
+	entries, limit := types.SenderCacheSize()
+	stats := &CacheStats{
+		Chain:    api.eth.BlockChain().CacheStats(),
+		Senders:  core.NewCacheStats(entries, limit, hits, misses),
+		Database: make(map[string]*DatabaseCacheStats),
+	}
+	dbs := map[string]ethdb.Database{"chaindata": api.eth.chainDb, "dapp": api.eth.dappDb, "indexes": api.eth.indexesDb}
+	for name, db := range dbs {
+		if ldb, ok := db.(*ethdb.LDBDatabase); ok {
+			size, capacity := ldb.BlockCacheStats()
+			stats.Database[name] = &DatabaseCacheStats{Size: size, Capacity: capacity}
+		}
 	}
-	return fmt.Sprintf("0x%x", hash), nil
+	return stats
 }
 
 func (api *PublicDebugAPI) SetHead(number uint64) (bool, error) {
@@ -1850,12 +3071,18 @@ func (api *PublicDebugAPI) Vmodule(s string) (string, error) {
 // while replaying a transaction in debug mode as well as the amount of
 // gas used and the return value
 type ExecutionResult struct {
-	Gas         *big.Int `json:"gas"`
-	ReturnValue string   `json:"returnValue"`
+	Gas               *hexutil.Big   `json:"gas"`
+	Failed            bool           `json:"failed"`
+	ReturnValue       hexutil.Bytes  `json:"returnValue"`
+	StructLogs        []vm.StructLog `json:"structLogs,omitempty"`
+	StructLogsDropped int            `json:"structLogsDropped,omitempty"` // Instructions executed beyond the log limit
 }
 
 // TraceCall executes a call and returns the amount of gas and optionally returned values.
-func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber) (*ExecutionResult, error) {
+func (s *PublicBlockChainAPI) TraceCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (*ExecutionResult, error) {
+	if err := args.resolveNames(s.ens); err != nil {
+		return nil, err
+	}
 	// Fetch the state associated with the block number
 	stateDb, block, err := stateAndBlockByNumber(s.bc, blockNr, s.chainDb)
 	if stateDb == nil || err != nil {
@@ -1897,34 +3124,86 @@ func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber)
 	vmenv := core.NewEnv(stateDb, s.config, s.bc, msg, block.Header())
 	gp := new(core.GasPool).AddGas(common.MaxBig)
 
+	stop := cancelOnDone(ctx, vmenv)
 	ret, gas, _, err := core.ApplyMessage(vmenv, msg, gp)
+	stop()
+	if err := ctx.Err(); err != nil {
+		return nil, executionAborted(err)
+	}
 	return &ExecutionResult{
-		Gas:         gas,
-		ReturnValue: fmt.Sprintf("%x", ret),
+		Gas:         (*hexutil.Big)(gas),
+		ReturnValue: ret,
 	}, nil
 }
 
-// TraceTransaction returns the amount of gas and execution result of the given transaction.
-func (s *PublicDebugAPI) TraceTransaction(txHash common.Hash) (*ExecutionResult, error) {
+// TraceTransaction re-executes the given transaction on top of the state it was
+// executed against and returns the amount of gas it used, its result and a log of
+// the instructions it executed, with the stack, memory and storage captured as
+// configured.
+func (s *PublicDebugAPI) TraceTransaction(ctx context.Context, txHash common.Hash, config *TraceConfig) (*ExecutionResult, error) {
 	var result *ExecutionResult
 	tx, blockHash, _, txIndex := core.GetTransaction(s.eth.ChainDb(), txHash)
 	if tx == nil {
 		return result, fmt.Errorf("tx '%x' not found", txHash)
 	}
-
+	logConfig, err := config.logConfig()
+	if err != nil {
+		return nil, err
+	}
 	msg, vmenv, err := s.computeTxEnv(blockHash, int(txIndex))
 	if err != nil {
 		return nil, err
 	}
+	tracer := vm.NewStructLoggerConfig(logConfig)
+	vmenv.SetTracer(tracer)
 
 	gp := new(core.GasPool).AddGas(tx.Gas())
-	ret, gas, _, err := core.ApplyMessage(vmenv, msg, gp)
+	stop := cancelOnDone(ctx, vmenv)
+	ret, gas, failed, err := core.ApplyMessage(vmenv, msg, gp)
+	stop()
+	if err := ctx.Err(); err != nil {
+		return nil, executionAborted(err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tx %x failed: %v", txHash, err)
+	}
 	return &ExecutionResult{
-		Gas:         gas,
-		ReturnValue: fmt.Sprintf("%x", ret),
+		Gas:               (*hexutil.Big)(gas),
+		Failed:            failed,
+		ReturnValue:       ret,
+		StructLogs:        tracer.StructLogs(),
+		StructLogsDropped: tracer.Dropped(),
 	}, nil
 }
 
+// IntermediateRoots replays the block with the given hash on top of its parent's
+// state and returns the state root after each of its transactions, which allows
+// pinpointing the transaction responsible for a state root mismatch.
+func (s *PublicDebugAPI) IntermediateRoots(blockHash common.Hash) ([]common.Hash, error) {
+	block := s.eth.BlockChain().GetBlock(blockHash)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	parent := s.eth.BlockChain().GetBlock(block.ParentHash())
+	if parent == nil {
+		return nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+	}
+	statedb, err := s.eth.BlockChain().StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	roots := make([]common.Hash, 0, len(block.Transactions()))
+	processor := core.NewStateProcessor(s.eth.chainConfig, s.eth.BlockChain())
+	_, _, _, err = processor.ProcessHooked(block, statedb, func(i int, tx *types.Transaction, receipt *types.Receipt) error {
+		roots = append(roots, statedb.IntermediateRoot(false))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
 // computeTxEnv returns the execution environment of a certain transaction.
 func (s *PublicDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int) (core.Message, *core.VMEnv, error) {
 
@@ -1999,8 +3278,8 @@ func (s *PublicNetAPI) Listening() bool {
 }
 
 // PeerCount returns the number of connected peers
-func (s *PublicNetAPI) PeerCount() *rpc.HexNumber {
-	return rpc.NewHexNumber(s.net.PeerCount())
+func (s *PublicNetAPI) PeerCount() hexutil.Uint {
+	return hexutil.Uint(s.net.PeerCount())
 }
 
 // Version returns the current ethereum protocol version.
@@ -0,0 +1,81 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/hexutil"
+	"github.com/openether/ethcore/core/vm"
+	"github.com/openether/ethcore/rpc"
+)
+
+// AccessTuple is an account accessed by a call along with the storage slots of
+// the account it accessed.
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// AccessListResult is the outcome of eth_createAccessList.
+type AccessListResult struct {
+	AccessList []AccessTuple `json:"accessList"`
+	GasUsed    *hexutil.Big  `json:"gasUsed"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// CreateAccessList executes the given call on the state of the given block and
+// returns the accounts and storage slots it accessed, sorted by address and slot.
+// The accounts are those whose code ran, whose storage was read or written and
+// those referenced by balance, code, call or suicide instructions. A call failing
+// in the EVM still returns what it accessed up to the failure, while an invalid
+// call, e.g. one not covering its intrinsic gas, only returns its error.
+func (s *PublicBlockChainAPI) CreateAccessList(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (*AccessListResult, error) {
+	if err := args.resolveNames(s.ens); err != nil {
+		return nil, err
+	}
+	stateDb, block, err := stateAndBlockByNumber(s.bc, blockNr, s.chainDb)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr.Int64())
+	}
+	tracer := vm.NewAccessListTracer()
+	_, gas, err := s.traceCall(ctx, args, stateDb.Copy(), block, tracer)
+
+	result := &AccessListResult{AccessList: accessList(tracer.Accessed()), GasUsed: (*hexutil.Big)(gas)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, nil
+}
+
+// accessList flattens the accessed accounts and slots into a sorted list.
+func accessList(accessed map[common.Address]map[common.Hash]struct{}) []AccessTuple {
+	list := make([]AccessTuple, 0, len(accessed))
+	for addr, slots := range accessed {
+		tuple := AccessTuple{Address: addr, StorageKeys: make([]common.Hash, 0, len(slots))}
+		for slot := range slots {
+			tuple.StorageKeys = append(tuple.StorageKeys, slot)
+		}
+		sort.Sort(hashSlice(tuple.StorageKeys))
+		list = append(list, tuple)
+	}
+	sort.Sort(accessTuples(list))
+	return list
+}
+
+type hashSlice []common.Hash
+
+func (s hashSlice) Len() int           { return len(s) }
+func (s hashSlice) Less(i, j int) bool { return bytes.Compare(s[i][:], s[j][:]) < 0 }
+func (s hashSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type accessTuples []AccessTuple
+
+func (s accessTuples) Len() int           { return len(s) }
+func (s accessTuples) Less(i, j int) bool { return bytes.Compare(s[i].Address[:], s[j].Address[:]) < 0 }
+func (s accessTuples) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
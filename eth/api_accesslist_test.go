@@ -0,0 +1,70 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/crypto"
+	"github.com/ethereumclassic/go-ethereum/rpc"
+)
+
+// Init code deploying a contract which loads slot 1, stores into slot 2 and reads
+// the balance of 0xff..ff:
+// PUSH1 1 SLOAD POP PUSH1 0x2a PUSH1 2 SSTORE PUSH20 0xff..ff BALANCE POP STOP
+var accessListCode = common.FromHex("0x6021600c60003960216000f3" +
+	"6001545060" + "2a600255" + "73ffffffffffffffffffffffffffffffffffffffff315000")
+
+func TestCreateAccessList(t *testing.T) {
+	api, _ := newTestBlockChainAPI(1, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewContractCreation(0, new(big.Int), big.NewInt(100000), big.NewInt(1), accessListCode).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	})
+	contract := crypto.CreateAddress(testBank.Address, 0)
+	other := common.HexToAddress("0xffffffffffffffffffffffffffffffffffffffff")
+
+	tests := []struct {
+		name string
+		gas  int64
+		list []AccessTuple
+		err  bool
+	}{
+		{"complete", 100000, []AccessTuple{
+			{Address: contract, StorageKeys: []common.Hash{common.BigToHash(big.NewInt(1)), common.BigToHash(big.NewInt(2))}},
+			{Address: other, StorageKeys: []common.Hash{}},
+		}, false},
+		// Running out of gas at the store lists what was accessed up to there
+		{"out of gas", 21100, []AccessTuple{
+			{Address: contract, StorageKeys: []common.Hash{common.BigToHash(big.NewInt(1)), common.BigToHash(big.NewInt(2))}},
+		}, false},
+		// A call not covering its intrinsic gas doesn't run, only reporting its error
+		{"invalid", 20000, []AccessTuple{}, true},
+	}
+	for _, test := range tests {
+		args := CallArgs{
+			From:     testBank.Address,
+			To:       &contract,
+			Gas:      rpc.NewHexNumber(test.gas),
+			GasPrice: rpc.NewHexNumber(1),
+		}
+		result, err := api.CreateAccessList(context.Background(), args, rpc.LatestBlockNumber)
+		if err != nil {
+			t.Fatalf("%s: failed to create access list: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(result.AccessList, test.list) {
+			t.Errorf("%s: access list mismatch:\nhave %+v\nwant %+v", test.name, result.AccessList, test.list)
+		}
+		if (result.Error != "") != test.err {
+			t.Errorf("%s: error mismatch: have %q, want error %v", test.name, result.Error, test.err)
+		}
+	}
+	// The call ran on a copy of the state, nothing was stored
+	stateDb, _ := api.bc.State()
+	if value := stateDb.GetState(contract, common.BigToHash(big.NewInt(2))); value != (common.Hash{}) {
+		t.Errorf("access list call modified the state: slot 2 is %x", value)
+	}
+}
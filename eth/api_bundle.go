@@ -0,0 +1,266 @@
+package eth
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/hexutil"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/state"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/core/vm"
+	"github.com/openether/ethcore/crypto"
+	"github.com/openether/ethcore/rpc"
+)
+
+const (
+	maxBundleTxs        = 256    // Maximum number of transactions simulated in a single bundle
+	maxBundleStructLogs = 100000 // Maximum number of instructions traced per transaction
+)
+
+// BundleTx is a transaction of a simulated bundle, either a signed transaction
+// given as its hex encoded RLP or the arguments of an unsigned call.
+type BundleTx struct {
+	Raw  string    // Hex encoded signed transaction
+	Call *CallArgs // Unsigned transaction, executed on behalf of its sender
+}
+
+// UnmarshalJSON decodes a bundle transaction from either a string holding a
+// signed transaction or an object holding call arguments.
+func (tx *BundleTx) UnmarshalJSON(input []byte) error {
+	if len(input) > 0 && input[0] == '"' {
+		return json.Unmarshal(input, &tx.Raw)
+	}
+	tx.Call = new(CallArgs)
+	return json.Unmarshal(input, tx.Call)
+}
+
+// AccountOverride replaces parts of an account's state before a bundle is
+// simulated.
+type AccountOverride struct {
+	Nonce   *rpc.HexNumber              `json:"nonce"`
+	Balance *rpc.HexNumber              `json:"balance"`
+	Code    *string                     `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"` // Storage slots to set, others are left untouched
+}
+
+// BlockOverrides replaces fields of the block header a bundle is simulated in.
+type BlockOverrides struct {
+	Number     *rpc.HexNumber  `json:"number"`
+	Timestamp  *rpc.HexNumber  `json:"timestamp"`
+	Coinbase   *common.Address `json:"coinbase"`
+	Difficulty *rpc.HexNumber  `json:"difficulty"`
+	GasLimit   *rpc.HexNumber  `json:"gasLimit"`
+}
+
+// BundleArgs holds the options of eth_simulateBundle.
+type BundleArgs struct {
+	StateOverrides map[common.Address]AccountOverride `json:"stateOverrides"`
+	BlockOverrides *BlockOverrides                    `json:"blockOverrides"`
+	Trace          bool                               `json:"trace"` // Return the executed instructions of each transaction
+}
+
+// BundleTxResult is the outcome of a single transaction of a simulated bundle.
+type BundleTxResult struct {
+	TxHash            common.Hash     `json:"transactionHash"`
+	From              common.Address  `json:"from"`
+	To                *common.Address `json:"to"`
+	ContractAddress   *common.Address `json:"contractAddress"`
+	GasUsed           *hexutil.Big    `json:"gasUsed"`
+	CumulativeGasUsed *hexutil.Big    `json:"cumulativeGasUsed"`
+	Status            *hexutil.Uint   `json:"status"`
+	ReturnValue       hexutil.Bytes   `json:"returnValue"`
+	Logs              vm.Logs         `json:"logs"`
+	Error             string          `json:"error,omitempty"`
+	StructLogs        []vm.StructLog  `json:"structLogs,omitempty"`
+	StructLogsDropped int             `json:"structLogsDropped,omitempty"`
+}
+
+// BundleResult is the outcome of a simulated bundle.
+type BundleResult struct {
+	BlockNumber *hexutil.Big      `json:"blockNumber"`
+	StateBlock  common.Hash       `json:"stateBlockHash"` // Block whose state the bundle was simulated on
+	GasUsed     *hexutil.Big      `json:"gasUsed"`
+	Results     []*BundleTxResult `json:"results"`
+}
+
+// SimulateBundle executes the given transactions in order on top of the state of
+// the given block, each seeing the changes of the ones before it, and returns
+// their receipts, logs and optionally instruction traces. Nothing is persisted.
+// Transactions failing validation (e.g. a bad nonce or insufficient funds) are
+// reported in their result without affecting the state.
+func (s *PublicBlockChainAPI) SimulateBundle(txs []BundleTx, blockNr rpc.BlockNumber, args *BundleArgs) (*BundleResult, error) {
+	if len(txs) > maxBundleTxs {
+		return nil, fmt.Errorf("too many transactions: %d exceed the limit of %d", len(txs), maxBundleTxs)
+	}
+	if args == nil {
+		args = new(BundleArgs)
+	}
+	for i := range txs {
+		if txs[i].Call != nil {
+			if err := txs[i].Call.resolveNames(s.ens); err != nil {
+				return nil, fmt.Errorf("transaction %d: %v", i, err)
+			}
+		}
+	}
+	statedb, block, err := stateAndBlockByNumber(s.bc, blockNr, s.chainDb)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr.Int64())
+	}
+	statedb = statedb.Copy()
+	applyStateOverrides(statedb, args.StateOverrides)
+
+	header := types.CopyHeader(block.Header())
+	applyBlockOverrides(header, args.BlockOverrides)
+
+	var (
+		signer  = s.config.GetSigner(header.Number)
+		gp      = new(core.GasPool).AddGas(header.GasLimit)
+		usedGas = new(big.Int)
+		result  = &BundleResult{
+			BlockNumber: (*hexutil.Big)(header.Number),
+			StateBlock:  block.Hash(),
+			Results:     make([]*BundleTxResult, len(txs)),
+		}
+	)
+	for i, btx := range txs {
+		msg, hash, err := s.bundleMessage(btx, statedb, header, signer)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+		from, _ := msg.From()
+		nonce := msg.Nonce() // Unsigned messages read the live nonce, which the execution bumps
+		res := &BundleTxResult{TxHash: hash, From: from, To: msg.To()}
+		result.Results[i] = res
+
+		statedb.StartRecord(hash, common.Hash{}, i)
+		vmenv := core.NewEnv(statedb, s.config, s.bc, msg, header)
+		var tracer *vm.StructLogger
+		if args.Trace {
+			tracer = vm.NewStructLogger(maxBundleStructLogs)
+			vmenv.SetTracer(tracer)
+		}
+		// Invalid transactions must not leave partial changes (e.g. bought gas) behind
+		snapshot, gasLeft := statedb.Copy(), new(big.Int).Set((*big.Int)(gp))
+		ret, gas, failed, err := core.NewStateTransition(vmenv, msg, gp).TransitionDb()
+		if tracer != nil {
+			res.StructLogs, res.StructLogsDropped = tracer.StructLogs(), tracer.Dropped()
+		}
+		if err != nil {
+			statedb, gp = snapshot, new(core.GasPool).AddGas(gasLeft)
+			res.Error = err.Error()
+			continue
+		}
+		usedGas.Add(usedGas, gas)
+		statedb.IntermediateRoot(false)
+
+		res.ReturnValue = ret
+		res.GasUsed = (*hexutil.Big)(gas)
+		res.CumulativeGasUsed = (*hexutil.Big)(new(big.Int).Set(usedGas))
+		res.Logs = statedb.GetLogs(hash)
+		if res.Logs == nil {
+			res.Logs = vm.Logs{}
+		}
+		status := hexutil.Uint(types.TxSuccess)
+		if failed {
+			status = hexutil.Uint(types.TxFailure)
+		}
+		res.Status = &status
+		if msg.To() == nil {
+			addr := crypto.CreateAddress(from, nonce)
+			res.ContractAddress = &addr
+		}
+	}
+	result.GasUsed = (*hexutil.Big)(usedGas)
+	return result, nil
+}
+
+// bundleMessage converts a bundle transaction into the message to execute and the
+// hash identifying it. Unsigned transactions are identified by the hash of the
+// unsigned transaction they correspond to.
+func (s *PublicBlockChainAPI) bundleMessage(btx BundleTx, statedb *state.StateDB, header *types.Header, signer types.Signer) (core.Message, common.Hash, error) {
+	if btx.Call == nil {
+		tx, err := types.DecodeTransaction(common.FromHex(btx.Raw))
+		if err != nil {
+			return nil, common.Hash{}, err
+		}
+		tx.SetSigner(signer)
+		if _, err := tx.From(); err != nil {
+			return nil, common.Hash{}, err
+		}
+		return tx, tx.Hash(), nil
+	}
+	args := btx.Call
+	from := args.From
+	if from == (common.Address{}) {
+		if accounts := s.am.Accounts(); len(accounts) > 0 {
+			from = accounts[0].Address
+		}
+	}
+	msg := callmsg{
+		from:     statedb.GetOrNewStateObject(from),
+		to:       args.To,
+		gas:      args.Gas.BigInt(),
+		gasPrice: args.GasPrice.BigInt(),
+		value:    args.Value.BigInt(),
+		data:     common.FromHex(args.Data),
+	}
+	if msg.gas == nil {
+		msg.gas = new(big.Int).Set(header.GasLimit)
+	}
+	if msg.gasPrice == nil {
+		msg.gasPrice = s.gpo.SuggestPrice()
+	}
+	var tx *types.Transaction
+	if msg.to == nil {
+		tx = types.NewContractCreation(msg.Nonce(), msg.value, msg.gas, msg.gasPrice, msg.data)
+	} else {
+		tx = types.NewTransaction(msg.Nonce(), *msg.to, msg.value, msg.gas, msg.gasPrice, msg.data)
+	}
+	return msg, tx.Hash(), nil
+}
+
+// applyStateOverrides replaces the overridden parts of the accounts' state.
+func applyStateOverrides(statedb *state.StateDB, overrides map[common.Address]AccountOverride) {
+	for addr, account := range overrides {
+		if account.Nonce != nil {
+			statedb.SetNonce(addr, account.Nonce.Uint64())
+		}
+		if account.Balance != nil {
+			statedb.SetBalance(addr, account.Balance.BigInt())
+		}
+		if account.Code != nil {
+			statedb.SetCode(addr, common.FromHex(*account.Code))
+		}
+		for key, value := range account.Storage {
+			statedb.SetState(addr, key, value)
+		}
+	}
+}
+
+// applyBlockOverrides replaces the overridden fields of the header.
+func applyBlockOverrides(header *types.Header, overrides *BlockOverrides) {
+	if overrides == nil {
+		return
+	}
+	if overrides.Number != nil {
+		header.Number = overrides.Number.BigInt()
+	}
+	if overrides.Timestamp != nil {
+		header.Time = overrides.Timestamp.BigInt()
+	}
+	if overrides.Coinbase != nil {
+		header.Coinbase = *overrides.Coinbase
+	}
+	if overrides.Difficulty != nil {
+		header.Difficulty = overrides.Difficulty.BigInt()
+	}
+	if overrides.GasLimit != nil {
+		header.GasLimit = overrides.GasLimit.BigInt()
+	}
+}
@@ -0,0 +1,107 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/common/hexutil"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/rpc"
+)
+
+var testContract = common.HexToAddress("0x0000000000000000000000000000000000c0de")
+
+// bundleCall returns a bundle transaction calling to from the test bank.
+func bundleCall(to common.Address, value int64) BundleTx {
+	return BundleTx{Call: &CallArgs{
+		From:     testBank.Address,
+		To:       &to,
+		Gas:      rpc.NewHexNumber(50000),
+		GasPrice: rpc.NewHexNumber(1),
+		Value:    *rpc.NewHexNumber(value),
+	}}
+}
+
+func TestSimulateBundle(t *testing.T) {
+	api, _ := newTestBlockChainAPI(0, nil)
+
+	recipient := common.HexToAddress("0x1234")
+	// The second transfer needs the balance left by the first one
+	txs := []BundleTx{bundleCall(recipient, 900000), bundleCall(recipient, 900000)}
+	result, err := api.SimulateBundle(txs, rpc.LatestBlockNumber, nil)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if len(result.Results) != 2 {
+		t.Fatalf("results: got %d, want 2", len(result.Results))
+	}
+	first, second := result.Results[0], result.Results[1]
+	if first.Error != "" || first.Status == nil || *first.Status != hexutil.Uint(types.TxSuccess) {
+		t.Errorf("first transfer failed: %+v", first)
+	}
+	if first.GasUsed.ToInt().Cmp(big.NewInt(21000)) != 0 {
+		t.Errorf("first transfer gas used: got %v, want 21000", first.GasUsed.ToInt())
+	}
+	if second.Error == "" {
+		t.Errorf("second transfer succeeded despite the spent balance")
+	}
+	if result.GasUsed.ToInt().Cmp(first.GasUsed.ToInt()) != 0 {
+		t.Errorf("bundle gas used: got %v, want %v", result.GasUsed.ToInt(), first.GasUsed.ToInt())
+	}
+}
+
+func TestSimulateBundleOverrides(t *testing.T) {
+	api, _ := newTestBlockChainAPI(0, nil)
+
+	// PUSH1 0x2a PUSH1 0 SSTORE, storing 42 at slot 0
+	code := "0x602a600055"
+	args := &BundleArgs{
+		StateOverrides: map[common.Address]AccountOverride{testContract: {Code: &code}},
+		BlockOverrides: &BlockOverrides{Number: rpc.NewHexNumber(1000)},
+	}
+	result, err := api.SimulateBundle([]BundleTx{bundleCall(testContract, 0)}, rpc.LatestBlockNumber, args)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if result.BlockNumber.ToInt().Int64() != 1000 {
+		t.Errorf("block number: got %v, want 1000", result.BlockNumber.ToInt())
+	}
+	res := result.Results[0]
+	if res.Error != "" || *res.Status != hexutil.Uint(types.TxSuccess) {
+		t.Fatalf("call failed: %+v", res)
+	}
+	// The overrides must not leak into the chain state
+	statedb, err := api.bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statedb.GetCode(testContract)) != 0 {
+		t.Errorf("overridden code persisted")
+	}
+}
+
+func TestSimulateBundleTraceFailedInstruction(t *testing.T) {
+	api, _ := newTestBlockChainAPI(0, nil)
+
+	// A lone ADD underflows the stack, failing before its cost is known
+	code := "0x01"
+	args := &BundleArgs{
+		StateOverrides: map[common.Address]AccountOverride{testContract: {Code: &code}},
+		Trace:          true,
+	}
+	result, err := api.SimulateBundle([]BundleTx{bundleCall(testContract, 0)}, rpc.LatestBlockNumber, args)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	res := result.Results[0]
+	if res.Status == nil || *res.Status != hexutil.Uint(types.TxFailure) {
+		t.Errorf("status: got %v, want failure", res.Status)
+	}
+	if len(res.StructLogs) != 1 {
+		t.Fatalf("traced instructions: got %d, want 1", len(res.StructLogs))
+	}
+	if log := res.StructLogs[0]; log.Op != "ADD" || log.Error == "" || log.GasCost.Sign() != 0 {
+		t.Errorf("traced instruction mismatch: %+v", log)
+	}
+}
@@ -0,0 +1,46 @@
+package eth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/rpc"
+)
+
+// Tests that calls running out of time report the time cap, whether the EVM
+// timeout or the timeout of the RPC method fires first.
+func TestCallTimeCap(t *testing.T) {
+	tests := []struct {
+		evmTimeout    time.Duration
+		methodTimeout time.Duration
+		limit         string
+	}{
+		{evmTimeout: 50 * time.Millisecond, limit: "50ms"},
+		{methodTimeout: 50 * time.Millisecond, limit: "50ms"},
+		{evmTimeout: 50 * time.Millisecond, methodTimeout: 50 * time.Millisecond, limit: "50ms"},
+		{evmTimeout: 50 * time.Millisecond, methodTimeout: time.Second, limit: "50ms"},
+		{evmTimeout: time.Second, methodTimeout: 50 * time.Millisecond, limit: "50ms"},
+	}
+	api, _ := newTestBlockChainAPI(0, nil)
+	for i, test := range tests {
+		api.evmTimeout = test.evmTimeout
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if test.methodTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, test.methodTimeout)
+		}
+		// Deploy code looping forever: JUMPDEST PUSH1 0 JUMP
+		args := CallArgs{From: testBank.Address, Gas: rpc.NewHexNumber(1 << 50), GasPrice: rpc.NewHexNumber(1), Data: "0x5b600056"}
+		_, err := api.Call(ctx, args, rpc.LatestBlockNumber)
+		cancel()
+
+		capErr, ok := err.(*CapExceededError)
+		if !ok {
+			t.Errorf("test %d: error mismatch: have %v, want time cap exceeded", i, err)
+			continue
+		}
+		if capErr.Cap != "time" || capErr.Limit != test.limit {
+			t.Errorf("test %d: cap mismatch: have %s/%s, want time/%s", i, capErr.Cap, capErr.Limit, test.limit)
+		}
+	}
+}
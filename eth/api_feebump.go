@@ -0,0 +1,95 @@
+package eth
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/hexutil"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/rpc"
+)
+
+// replacementPriceBump is the percentage by which the gas price of a transaction
+// must exceed the price of the pending transaction it replaces for other nodes to
+// accept the replacement into their pools.
+const replacementPriceBump = 10
+
+// minReplacementPrice returns the lowest gas price a replacement of a transaction
+// with the given gas price is accepted at.
+func minReplacementPrice(price *big.Int) *big.Int {
+	min := new(big.Int).Mul(price, big.NewInt(100+replacementPriceBump))
+	min.Add(min, big.NewInt(99)) // Round up
+	min.Div(min, big.NewInt(100))
+	if min.Cmp(price) <= 0 {
+		min.Add(price, common.Big1)
+	}
+	return min
+}
+
+// BumpFeeArgs selects the gas price of a replacement transaction: either a given
+// price or a percentage over the replaced price. Without either, the minimum price
+// accepted for replacements is used.
+type BumpFeeArgs struct {
+	GasPrice *rpc.HexNumber `json:"gasPrice"`
+	Percent  *rpc.HexNumber `json:"percent"`
+}
+
+// BumpFeeResult is the outcome of eth_bumpFee.
+type BumpFeeResult struct {
+	Hash     common.Hash  `json:"hash"`     // Hash of the replacement transaction
+	Replaced common.Hash  `json:"replaced"` // Hash of the replaced transaction
+	GasPrice *hexutil.Big `json:"gasPrice"` // Gas price of the replacement
+}
+
+// BumpFee replaces a pooled transaction sent from an unlocked account of this node
+// by the same transaction at a higher gas price, so that a transaction stuck at too
+// low a price gets mined. Replacements must exceed the replaced price by 10% to
+// reach the pools of other nodes; lower prices are refused. The replacement keeps
+// the time-to-live of the replaced transaction.
+func (s *PublicTransactionPoolAPI) BumpFee(hash common.Hash, args *BumpFeeArgs) (*BumpFeeResult, error) {
+	tx := s.txPool.GetTransaction(hash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not pending", hash)
+	}
+	from, err := tx.From()
+	if err != nil {
+		return nil, err
+	}
+	if !s.am.HasAddress(from) {
+		return nil, fmt.Errorf("transaction %x is not sent from an account of this node", hash)
+	}
+
+	min := minReplacementPrice(tx.GasPrice())
+	price := min
+	switch {
+	case args != nil && args.GasPrice != nil && args.Percent != nil:
+		return nil, fmt.Errorf("both gasPrice and percent given")
+	case args != nil && args.GasPrice != nil:
+		price = args.GasPrice.BigInt()
+	case args != nil && args.Percent != nil:
+		price = new(big.Int).Mul(tx.GasPrice(), new(big.Int).Add(big.NewInt(100), args.Percent.BigInt()))
+		price.Div(price, big.NewInt(100))
+	}
+	if price.Cmp(min) < 0 {
+		return nil, fmt.Errorf("gas price %v too low to replace transaction at %v, at least %v (+%d%%) required", price, tx.GasPrice(), min, replacementPriceBump)
+	}
+
+	var replacement *types.Transaction
+	if tx.To() == nil {
+		replacement = types.NewContractCreation(tx.Nonce(), tx.Value(), tx.Gas(), price, tx.Data())
+	} else {
+		replacement = types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), price, tx.Data())
+	}
+	signed, err := s.sign(from, replacement)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.txPool.Replace(hash, signed); err != nil {
+		return nil, explainTxPoolError(s.bc.Config(), signed, err)
+	}
+	s.expirer.replaced(hash, signed)
+
+	return &BumpFeeResult{Hash: signed.Hash(), Replaced: hash, GasPrice: (*hexutil.Big)(price)}, nil
+}
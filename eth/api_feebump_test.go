@@ -0,0 +1,111 @@
+package eth
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/accounts"
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/rpc"
+)
+
+func TestMinReplacementPrice(t *testing.T) {
+	tests := []struct {
+		price, min int64
+	}{
+		{0, 1},
+		{1, 2},
+		{9, 10},
+		{10, 11},
+		{100, 110},
+		{101, 112}, // 111.1 rounded up
+		{20000000000, 22000000000},
+	}
+	for _, tt := range tests {
+		if min := minReplacementPrice(big.NewInt(tt.price)); min.Cmp(big.NewInt(tt.min)) != 0 {
+			t.Errorf("price %d: min replacement price %v, want %d", tt.price, min, tt.min)
+		}
+	}
+}
+
+func TestBumpFee(t *testing.T) {
+	et := newExpiryTester(t)
+	defer et.close()
+	api := &PublicTransactionPoolAPI{txPool: et.expirer.pool, am: et.expirer.am, bc: et.chain, expirer: et.expirer}
+
+	tx := et.send(0, common.HexToAddress("0x1234"), TxExpiryArgs{TTL: rpc.NewHexNumber(10)})
+	pool := et.expirer.pool
+
+	// Invalid requests leave the transaction pooled
+	invalid := []struct {
+		hash common.Hash
+		args *BumpFeeArgs
+	}{
+		{common.Hash{0x01}, nil},
+		{tx.Hash(), &BumpFeeArgs{GasPrice: rpc.NewHexNumber(2), Percent: rpc.NewHexNumber(50)}},
+		{tx.Hash(), &BumpFeeArgs{GasPrice: rpc.NewHexNumber(1)}},
+		{tx.Hash(), &BumpFeeArgs{Percent: rpc.NewHexNumber(5)}},
+	}
+	for i, tt := range invalid {
+		if _, err := api.BumpFee(tt.hash, tt.args); err == nil {
+			t.Errorf("invalid request %d accepted", i)
+		}
+	}
+	if pool.GetTransaction(tx.Hash()) == nil {
+		t.Fatalf("transaction dropped by refused replacements")
+	}
+
+	// A replacement the pool refuses restores the replaced transaction
+	if _, err := api.BumpFee(tx.Hash(), &BumpFeeArgs{GasPrice: rpc.NewHexNumber(1000)}); err == nil {
+		t.Fatalf("unaffordable replacement accepted")
+	}
+	if pool.GetTransaction(tx.Hash()) == nil {
+		t.Fatalf("transaction not restored after its replacement was refused")
+	}
+
+	// Replacements take the place of the replaced transaction, along with its expiry
+	tests := []struct {
+		args  *BumpFeeArgs
+		price int64
+	}{
+		{nil, 2},
+		{&BumpFeeArgs{Percent: rpc.NewHexNumber(100)}, 4},
+		{&BumpFeeArgs{GasPrice: rpc.NewHexNumber(7)}, 7},
+	}
+	for _, tt := range tests {
+		result, err := api.BumpFee(tx.Hash(), tt.args)
+		if err != nil {
+			t.Fatalf("%+v: failed to bump fee: %v", tt.args, err)
+		}
+		replacement := pool.GetTransaction(result.Hash)
+		if replacement == nil || pool.GetTransaction(tx.Hash()) != nil {
+			t.Fatalf("%+v: transaction not replaced in the pool", tt.args)
+		}
+		if result.Replaced != tx.Hash() || result.GasPrice.ToInt().Int64() != tt.price || replacement.GasPrice().Int64() != tt.price {
+			t.Errorf("%+v: result mismatch: have %+v, price %v, want price %d", tt.args, result, replacement.GasPrice(), tt.price)
+		}
+		if replacement.Nonce() != tx.Nonce() || *replacement.To() != *tx.To() || replacement.Value().Cmp(tx.Value()) != 0 {
+			t.Errorf("%+v: replacement differs from the replaced transaction: %v", tt.args, replacement)
+		}
+		if _, ok := et.expirer.tracked[result.Hash]; !ok || len(et.expirer.tracked) != 1 {
+			t.Errorf("%+v: expiry not moved to the replacement: %v", tt.args, et.expirer.tracked)
+		}
+		tx = replacement
+	}
+
+	// Transactions of accounts this node doesn't have can't be replaced
+	keydir, err := ioutil.TempDir("", "bump-fee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(keydir)
+	if api.am, err = accounts.NewManager(keydir, accounts.LightScryptN, accounts.LightScryptP, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.BumpFee(tx.Hash(), nil); err == nil || !strings.Contains(err.Error(), "account of this node") {
+		t.Errorf("foreign transaction replaced: %v", err)
+	}
+}
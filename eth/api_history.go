@@ -0,0 +1,79 @@
+package eth
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/hexutil"
+	"github.com/openether/ethcore/core/state"
+	"github.com/openether/ethcore/rpc"
+)
+
+// maxAccountHistoryBlocks limits the number of blocks a single GetAccountHistory request can span.
+const maxAccountHistoryBlocks = 10000
+
+// AccountAtBlock is the balance and nonce of an account at the end of a block.
+type AccountAtBlock struct {
+	BlockNumber *hexutil.Big   `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Balance     *hexutil.Big   `json:"balance"`
+	Nonce       hexutil.Uint64 `json:"nonce"`
+}
+
+// GetAccountHistory returns the balance and nonce of the given account at every block in the inclusive range
+// [fromBlock, toBlock]. A single state database is used for the whole range, so trie nodes shared between the
+// consecutive states are only loaded once, and blocks leaving the state root unchanged are not looked up again.
+// Blocks whose state isn't available (e.g. pruned) fail the request.
+func (s *PublicBlockChainAPI) GetAccountHistory(arg AddressOrName, fromBlock, toBlock rpc.BlockNumber) ([]*AccountAtBlock, error) {
+	address, err := resolveAddress(s.ens, arg)
+	if err != nil {
+		return nil, err
+	}
+	from, to := s.resolveBlockNumber(fromBlock), s.resolveBlockNumber(toBlock)
+	if from > to {
+		return nil, fmt.Errorf("invalid block range: #%d is after #%d", from, to)
+	}
+	if to-from >= maxAccountHistoryBlocks {
+		return nil, fmt.Errorf("too many blocks: %d exceed the limit of %d", to-from+1, maxAccountHistoryBlocks)
+	}
+	var (
+		statedb *state.StateDB
+		root    common.Hash
+		history = make([]*AccountAtBlock, 0, to-from+1)
+	)
+	for number := from; number <= to; number++ {
+		block := s.bc.GetBlockByNumber(number)
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+		switch {
+		case statedb == nil:
+			if statedb, err = state.New(block.Root(), state.NewDatabase(s.chainDb)); err != nil {
+				return nil, fmt.Errorf("state of block #%d: %v", number, err)
+			}
+		case block.Root() != root:
+			if err = statedb.Reset(block.Root()); err != nil {
+				return nil, fmt.Errorf("state of block #%d: %v", number, err)
+			}
+		}
+		root = block.Root()
+
+		history = append(history, &AccountAtBlock{
+			BlockNumber: (*hexutil.Big)(block.Number()),
+			BlockHash:   block.Hash(),
+			Balance:     (*hexutil.Big)(new(big.Int).Set(statedb.GetBalance(address))),
+			Nonce:       hexutil.Uint64(statedb.GetNonce(address)),
+		})
+	}
+	return history, nil
+}
+
+// resolveBlockNumber converts the given block number to an absolute one, mapping the latest and pending block
+// numbers to the current head.
+func (s *PublicBlockChainAPI) resolveBlockNumber(number rpc.BlockNumber) uint64 {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return s.bc.CurrentBlock().NumberU64()
+	}
+	return uint64(number.Int64())
+}
@@ -0,0 +1,62 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/rpc"
+)
+
+func TestGetAccountHistory(t *testing.T) {
+	// The bank pays the recipient in the first and third block
+	recipient := common.HexToAddress("0x1234")
+	api, chain := newTestBlockChainAPI(4, func(i int, block *core.BlockGen) {
+		if i == 0 || i == 2 {
+			tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), recipient, big.NewInt(1000), core.TxGas, big.NewInt(1), nil).SignECDSA(testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	history, err := api.GetAccountHistory(AddressOrName{Address: recipient}, 0, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	balances := []int64{0, 1000, 1000, 2000, 2000}
+	if len(history) != len(balances) {
+		t.Fatalf("history length mismatch: have %d, want %d", len(history), len(balances))
+	}
+	for i, entry := range history {
+		block := chain.GetBlockByNumber(uint64(i))
+		if entry.BlockNumber.ToInt().Int64() != int64(i) || entry.BlockHash != block.Hash() {
+			t.Errorf("entry %d: block mismatch: have #%v %x, want #%d %x", i, entry.BlockNumber, entry.BlockHash, i, block.Hash())
+		}
+		if entry.Balance.ToInt().Int64() != balances[i] || entry.Nonce != 0 {
+			t.Errorf("entry %d: account mismatch: have %v/%d, want %d/0", i, entry.Balance, entry.Nonce, balances[i])
+		}
+	}
+	// The nonce of the sender follows its transactions
+	history, err = api.GetAccountHistory(AddressOrName{Address: testBank.Address}, 1, 3)
+	if err != nil {
+		t.Fatalf("failed to get sender history: %v", err)
+	}
+	for i, nonce := range []uint64{1, 1, 2} {
+		if uint64(history[i].Nonce) != nonce {
+			t.Errorf("sender entry %d: nonce mismatch: have %d, want %d", i, history[i].Nonce, nonce)
+		}
+	}
+	// Invalid ranges are refused
+	invalid := []struct {
+		from, to rpc.BlockNumber
+	}{
+		{3, 1},                       // Reversed
+		{3, 5},                       // Beyond the head
+		{0, maxAccountHistoryBlocks}, // Too long
+	}
+	for _, r := range invalid {
+		if _, err := api.GetAccountHistory(AddressOrName{Address: recipient}, r.from, r.to); err == nil {
+			t.Errorf("range %d-%d: history returned", r.from, r.to)
+		}
+	}
+}
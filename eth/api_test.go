@@ -0,0 +1,505 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/common/hexutil"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/crypto"
+	"github.com/ethereumclassic/go-ethereum/rpc"
+)
+
+// Tests that the gas of traces is encoded as a hex quantity and the return value
+// as 0x-prefixed hex.
+func TestExecutionResultEncoding(t *testing.T) {
+	api, _ := newTestBlockChainAPI(0, nil)
+
+	// Deploy code returning a single byte: PUSH1 0x2a PUSH1 0 MSTORE8 PUSH1 1 PUSH1 0 RETURN
+	args := CallArgs{From: testBank.Address, Gas: rpc.NewHexNumber(100000), GasPrice: rpc.NewHexNumber(1), Data: "0x602a60005360016000f3"}
+	result, err := api.TraceCall(context.Background(), args, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to trace call: %v", err)
+	}
+	var decoded map[string]interface{}
+	blob, _ := json.Marshal(result)
+	if err := json.Unmarshal(blob, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if gas, ok := decoded["gas"].(string); !ok || !strings.HasPrefix(gas, "0x") || gas != "0x"+result.Gas.ToInt().Text(16) {
+		t.Errorf("gas mismatch: have %v, want hex quantity of %v", decoded["gas"], result.Gas.ToInt())
+	}
+	if ret := decoded["returnValue"]; ret != "0x2a" {
+		t.Errorf("return value mismatch: have %v, want 0x2a", ret)
+	}
+
+	// Empty return values are encoded as empty byte strings
+	blob, _ = json.Marshal(&ExecutionResult{Gas: result.Gas})
+	if !strings.Contains(string(blob), `"returnValue":"0x"`) {
+		t.Errorf("empty return value mismatch: %s", blob)
+	}
+}
+
+// Tests that transactions returned by the API encode their quantities and data
+// as hex, and decode back to the same transaction.
+func TestTxEncoding(t *testing.T) {
+	to := common.HexToAddress("0x1234")
+	signed, _ := types.NewTransaction(5, to, big.NewInt(1000), big.NewInt(21000), big.NewInt(20), []byte{0xca, 0xfe}).SignECDSA(testBankKey)
+
+	blob, err := json.Marshal(newTx(signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(blob, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"nonce": "0x5", "value": "0x3e8", "gas": "0x5208", "gasPrice": "0x14", "data": "0xcafe"}
+	for field, value := range want {
+		if decoded[field] != value {
+			t.Errorf("%s mismatch: have %v, want %s", field, decoded[field], value)
+		}
+	}
+	var tx Tx
+	if err := json.Unmarshal(blob, &tx); err != nil {
+		t.Fatalf("failed to decode transaction: %v", err)
+	}
+	if tx.tx.Nonce() != 5 || tx.tx.Value().Cmp(big.NewInt(1000)) != 0 || tx.tx.Gas().Cmp(big.NewInt(21000)) != 0 ||
+		tx.tx.GasPrice().Cmp(big.NewInt(20)) != 0 || string(tx.tx.Data()) != "\xca\xfe" || *tx.tx.To() != to {
+		t.Errorf("decoded transaction mismatch: %v", tx.tx)
+	}
+}
+
+// Tests that block authors are the coinbases of their blocks, as sealed by ethash.
+func TestGetBlockAuthor(t *testing.T) {
+	author := common.HexToAddress("0x1234")
+	api, _ := newTestBlockChainAPI(2, func(i int, block *core.BlockGen) {
+		if i == 1 {
+			block.SetCoinbase(author)
+		}
+	})
+	if have := api.GetBlockAuthor(2); have == nil || *have != author {
+		t.Errorf("author mismatch: have %v, want %x", have, author)
+	}
+	if have := api.GetBlockAuthor(3); have != nil {
+		t.Errorf("author of missing block: %x", *have)
+	}
+}
+
+// Tests that uncles are looked up by index, with null returned for indexes past
+// the end, negative or too large to fit an int.
+func TestGetUncleByIndex(t *testing.T) {
+	api, chain := newTestBlockChainAPI(2, func(i int, block *core.BlockGen) {
+		if i == 1 {
+			uncle := block.PrevBlock(0).Header()
+			uncle.Extra = []byte("uncle")
+			block.AddUncle(uncle)
+		}
+	})
+	block := chain.GetBlockByNumber(2)
+	want := block.Uncles()[0].Hash()
+
+	tests := []struct {
+		index *big.Int
+		found bool
+	}{
+		{big.NewInt(0), true},
+		{big.NewInt(1), false},
+		{big.NewInt(-1), false},
+		{new(big.Int).Lsh(big.NewInt(1), 64), false}, // truncates to 0
+		{big.NewInt(1 << 32), false},                 // truncates to 0 in 32 bits
+	}
+	for i, tt := range tests {
+		byNumber, err := api.GetUncleByBlockNumberAndIndex(2, *rpc.NewHexNumber(tt.index))
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve uncle by number: %v", i, err)
+		}
+		byHash, err := api.GetUncleByBlockHashAndIndex(block.Hash(), *rpc.NewHexNumber(tt.index))
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve uncle by hash: %v", i, err)
+		}
+		for _, uncle := range []map[string]interface{}{byNumber, byHash} {
+			if (uncle != nil) != tt.found {
+				t.Errorf("test %d: uncle found mismatch: have %v, want %v", i, uncle != nil, tt.found)
+			}
+			if uncle != nil && uncle["hash"] != want {
+				t.Errorf("test %d: uncle hash mismatch: have %v, want %x", i, uncle["hash"], want)
+			}
+		}
+	}
+}
+
+// Tests that the receipts of several transactions are returned in the requested
+// order, with nil for the unknown transactions, and that large requests are refused.
+func TestGetTransactionReceipts(t *testing.T) {
+	var txs []*types.Transaction
+	chain, db, _ := newTestChainWithReceipts(t, 2, func(i int, block *core.BlockGen) {
+		for j := 0; j <= i; j++ {
+			tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.HexToAddress("0x1234"), big.NewInt(1), core.TxGas, big.NewInt(1), nil).SignECDSA(testBankKey)
+			block.AddTx(tx)
+			txs = append(txs, tx)
+		}
+	})
+	api := &PublicTransactionPoolAPI{chainDb: db, bc: chain}
+
+	hashes := []common.Hash{txs[2].Hash(), {0x01}, txs[0].Hash(), txs[1].Hash()}
+	receipts, err := api.GetTransactionReceipts(hashes)
+	if err != nil {
+		t.Fatalf("failed to get receipts: %v", err)
+	}
+	if len(receipts) != len(hashes) {
+		t.Fatalf("receipt count mismatch: have %d, want %d", len(receipts), len(hashes))
+	}
+	want := []struct {
+		block, index uint64
+	}{{2, 1}, {}, {1, 0}, {2, 0}}
+	for i, receipt := range receipts {
+		if hashes[i] == (common.Hash{0x01}) {
+			if receipt != nil {
+				t.Errorf("receipt %d: receipt of unknown transaction: %v", i, receipt)
+			}
+			continue
+		}
+		if receipt == nil {
+			t.Errorf("receipt %d: missing", i)
+			continue
+		}
+		if receipt["transactionHash"] != hashes[i] || receipt["blockNumber"] != hexutil.Uint64(want[i].block) || receipt["transactionIndex"] != hexutil.Uint64(want[i].index) {
+			t.Errorf("receipt %d: mismatch: have %v", i, receipt)
+		}
+		// The receipts match those served one by one
+		single, err := api.GetTransactionReceipt(hashes[i])
+		if err != nil || !reflect.DeepEqual(single, receipt) {
+			t.Errorf("receipt %d: mismatch with single receipt: have %v, want %v (%v)", i, receipt, single, err)
+		}
+	}
+	if _, err := api.GetTransactionReceipts(make([]common.Hash, maxReceiptsBatch+1)); err == nil {
+		t.Errorf("request of %d receipts accepted", maxReceiptsBatch+1)
+	}
+}
+
+// Tests that storage slots of several queries are read from the state of the
+// requested block, in the order of the queries and their keys.
+func TestGetStorageAtMany(t *testing.T) {
+	// Init code storing 1 and 2 in slots 0 and 1: PUSH1 1 PUSH1 0 SSTORE PUSH1 2 PUSH1 1 SSTORE
+	deploy, _ := types.NewContractCreation(0, new(big.Int), big.NewInt(100000), big.NewInt(1), common.FromHex("0x60016000556002600155")).SignECDSA(testBankKey)
+	api, chain := newTestBlockChainAPI(2, func(i int, block *core.BlockGen) {
+		if i == 0 {
+			block.AddTx(deploy)
+		}
+	})
+	contract := crypto.CreateAddress(testBank.Address, 0)
+	queries := []StorageQuery{
+		{Address: AddressOrName{Address: contract}, Keys: []string{"0x1", "0x0", "0x2"}},
+		{Address: AddressOrName{Address: testBank.Address}, Keys: []string{"0x0"}},
+		{Address: AddressOrName{Address: contract}},
+	}
+	tests := []struct {
+		block  rpc.BlockNumber
+		values [][]common.Hash
+	}{
+		{rpc.LatestBlockNumber, [][]common.Hash{{common.BigToHash(big.NewInt(2)), common.BigToHash(big.NewInt(1)), {}}, {{}}, {}}},
+		{0, [][]common.Hash{{{}, {}, {}}, {{}}, {}}},
+	}
+	for _, tt := range tests {
+		result, err := api.GetStorageAtMany(queries, tt.block)
+		if err != nil {
+			t.Fatalf("block %d: failed to read storage: %v", tt.block, err)
+		}
+		block := chain.CurrentBlock()
+		if tt.block != rpc.LatestBlockNumber {
+			block = chain.GetBlockByNumber(uint64(tt.block))
+		}
+		if result.BlockHash != block.Hash() || result.BlockNumber.ToInt().Cmp(block.Number()) != 0 {
+			t.Errorf("block %d: block mismatch: have #%v %x, want #%v %x", tt.block, result.BlockNumber.ToInt(), result.BlockHash, block.Number(), block.Hash())
+		}
+		if len(result.Storage) != len(queries) {
+			t.Fatalf("block %d: result count mismatch: have %d, want %d", tt.block, len(result.Storage), len(queries))
+		}
+		for i, storage := range result.Storage {
+			if storage.Address != queries[i].Address.Address || len(storage.Values) != len(tt.values[i]) {
+				t.Errorf("block %d: query %d mismatch: have %x with %d values", tt.block, i, storage.Address, len(storage.Values))
+				continue
+			}
+			for j, value := range storage.Values {
+				if value != tt.values[i][j] {
+					t.Errorf("block %d: query %d slot %s mismatch: have %x, want %x", tt.block, i, queries[i].Keys[j], value, tt.values[i][j])
+				}
+			}
+		}
+	}
+	if _, err := api.GetStorageAtMany(queries, 3); err == nil {
+		t.Errorf("storage of a missing block read")
+	}
+	if _, err := api.GetStorageAtMany([]StorageQuery{{Address: AddressOrName{Name: "contract.eth"}}}, rpc.LatestBlockNumber); err == nil {
+		t.Errorf("name resolved without a registrar")
+	}
+	many := make([]StorageQuery, 2)
+	many[0].Keys, many[1].Keys = make([]string, maxStorageAtManySlots/2), make([]string, maxStorageAtManySlots/2+1)
+	if _, err := api.GetStorageAtMany(many, rpc.LatestBlockNumber); err == nil || !strings.Contains(err.Error(), "too many storage slots") {
+		t.Errorf("slots over the limit read: %v", err)
+	}
+	if _, err := api.GetStorageAtMany(make([]StorageQuery, maxStorageAtManyQueries+1), rpc.LatestBlockNumber); err == nil || !strings.Contains(err.Error(), "too many queries") {
+		t.Errorf("queries over the limit read: %v", err)
+	}
+	// Malformed keys are refused rather than read as another slot
+	for _, key := range []string{"", "1", "0x", "0xg1", "0x" + strings.Repeat("00", 32) + "1"} {
+		malformed := []StorageQuery{{Address: AddressOrName{Address: contract}, Keys: []string{"0x0", key}}}
+		if _, err := api.GetStorageAtMany(malformed, rpc.LatestBlockNumber); err == nil || !strings.Contains(err.Error(), "invalid storage key") {
+			t.Errorf("key %q: malformed key read: %v", key, err)
+		}
+	}
+	full := []StorageQuery{{Address: AddressOrName{Address: contract}, Keys: []string{"0x" + strings.Repeat("0", 63) + "1", "0X00"}}}
+	result, err := api.GetStorageAtMany(full, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to read padded keys: %v", err)
+	}
+	if values := result.Storage[0].Values; values[0] != common.BigToHash(big.NewInt(2)) || values[1] != common.BigToHash(big.NewInt(1)) {
+		t.Errorf("padded keys mismatch: have %x", values)
+	}
+}
+
+// Init code deploying a counter which increments slot 0 and returns its new value:
+// PUSH1 0 SLOAD PUSH1 1 ADD DUP1 PUSH1 0 SSTORE PUSH1 0 MSTORE PUSH1 0x20 PUSH1 0 RETURN
+var counterCode = common.FromHex("0x6012600c60003960126000f3" + "600054600101806000556000526020" + "6000f3")
+
+// newCounterChain creates a test chain of three blocks, the first deploying the
+// counter and the second incrementing it.
+func newCounterChain() (*PublicBlockChainAPI, common.Address) {
+	contract := crypto.CreateAddress(testBank.Address, 0)
+	api, _ := newTestBlockChainAPI(3, func(i int, block *core.BlockGen) {
+		var tx *types.Transaction
+		switch i {
+		case 0:
+			tx, _ = types.NewContractCreation(0, new(big.Int), big.NewInt(100000), big.NewInt(1), counterCode).SignECDSA(testBankKey)
+		case 1:
+			tx, _ = types.NewTransaction(1, contract, new(big.Int), big.NewInt(100000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		default:
+			return
+		}
+		block.AddTx(tx)
+	})
+	return api, contract
+}
+
+// counterCall returns a call of the counter by the bank with the given gas.
+func counterCall(contract common.Address, gas int64) CallArgs {
+	return CallArgs{From: testBank.Address, To: &contract, Gas: rpc.NewHexNumber(gas), GasPrice: rpc.NewHexNumber(1)}
+}
+
+// Tests that every call runs on the unmodified state of every requested block.
+func TestCallMany(t *testing.T) {
+	api, contract := newCounterChain()
+	calls := []CallArgs{
+		counterCall(contract, 100000),
+		counterCall(contract, 100000),
+		counterCall(contract, 20000), // Not covering the intrinsic gas
+	}
+	blocks := []rpc.BlockNumber{0, 1, rpc.LatestBlockNumber, 2}
+	results, err := api.CallMany(context.Background(), calls, blocks)
+	if err != nil {
+		t.Fatalf("failed to execute calls: %v", err)
+	}
+	if len(results) != len(blocks) {
+		t.Fatalf("block results count mismatch: have %d, want %d", len(results), len(blocks))
+	}
+	// Before the deployment there is no code to run, then the counter is incremented from its stored value
+	outputs := [][]byte{nil, common.BigToHash(big.NewInt(1)).Bytes(), common.BigToHash(big.NewInt(2)).Bytes(), common.BigToHash(big.NewInt(2)).Bytes()}
+	numbers := []int64{0, 1, 3, 2}
+	for i, res := range results {
+		block := api.bc.GetBlockByNumber(uint64(numbers[i]))
+		if res.BlockHash != block.Hash() || res.BlockNumber.ToInt().Int64() != numbers[i] {
+			t.Errorf("block %d: block mismatch: have #%v %x, want #%d %x", blocks[i], res.BlockNumber.ToInt(), res.BlockHash, numbers[i], block.Hash())
+		}
+		if len(res.Results) != len(calls) {
+			t.Fatalf("block %d: results count mismatch: have %d, want %d", blocks[i], len(res.Results), len(calls))
+		}
+		for j := 0; j < 2; j++ {
+			if r := res.Results[j]; !bytes.Equal(r.Result, outputs[i]) || r.Error != "" || r.GasUsed == nil {
+				t.Errorf("block %d: call %d mismatch: have %x (gas %v, error %q), want %x", blocks[i], j, r.Result, r.GasUsed, r.Error, outputs[i])
+			}
+		}
+		if r := res.Results[2]; r.Error == "" {
+			t.Errorf("block %d: call without intrinsic gas succeeded: %x", blocks[i], r.Result)
+		}
+	}
+	if _, err := api.CallMany(context.Background(), calls, []rpc.BlockNumber{1, 4}); err == nil {
+		t.Errorf("calls on a missing block executed")
+	}
+	many := make([]CallArgs, maxCallManyCalls/len(blocks)+1)
+	if _, err := api.CallMany(context.Background(), many, blocks); err == nil {
+		t.Errorf("%d calls on %d blocks executed", len(many), len(blocks))
+	}
+}
+
+// Tests that the fully detailed transactions of a block can be restricted to
+// selected fields, which are those of the complete transactions.
+func TestGetBlockTxFields(t *testing.T) {
+	api, chain := newTestBlockChainAPI(1, func(i int, block *core.BlockGen) {
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := types.NewTransaction(nonce, common.HexToAddress("0x1234"), big.NewInt(1000), core.TxGas, big.NewInt(1), nil).SignECDSA(testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	// Every field of a transaction can be projected
+	typ := reflect.TypeOf(RPCTransaction{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if _, ok := rpcTransactionFields[field]; !ok {
+			t.Errorf("transaction field %q can't be projected", field)
+		}
+	}
+	full, err := api.GetBlockByNumber(1, true, nil)
+	if err != nil {
+		t.Fatalf("failed to get block: %v", err)
+	}
+	fields := []string{"hash", "from", "to", "value", "transactionIndex"}
+	projected, err := api.GetBlockByNumber(1, true, &fields)
+	if err != nil {
+		t.Fatalf("failed to get projected block: %v", err)
+	}
+	decode := func(txs interface{}) []map[string]json.RawMessage {
+		blob, err := json.Marshal(txs)
+		if err != nil {
+			t.Fatalf("failed to encode transactions: %v", err)
+		}
+		var decoded []map[string]json.RawMessage
+		if err := json.Unmarshal(blob, &decoded); err != nil {
+			t.Fatalf("failed to decode transactions: %v", err)
+		}
+		return decoded
+	}
+	fullTxs, projectedTxs := decode(full["transactions"]), decode(projected["transactions"])
+	if len(fullTxs) != 2 || len(projectedTxs) != 2 {
+		t.Fatalf("transaction count mismatch: have %d full and %d projected, want 2", len(fullTxs), len(projectedTxs))
+	}
+	for i, tx := range projectedTxs {
+		if len(tx) != len(fields) {
+			t.Errorf("tx %d: field count mismatch: have %d, want %d", i, len(tx), len(fields))
+		}
+		for _, field := range fields {
+			if string(tx[field]) != string(fullTxs[i][field]) {
+				t.Errorf("tx %d: field %s mismatch: have %s, want %s", i, field, tx[field], fullTxs[i][field])
+			}
+		}
+	}
+	// The projection doesn't affect the rest of the block, nor blocks listing hashes only
+	if projected["hash"] != full["hash"] || len(projected) != len(full) {
+		t.Errorf("projected block mismatch: have %v, want %v", projected, full)
+	}
+	block := chain.GetBlockByNumber(1)
+	hashes, err := api.GetBlockByHash(block.Hash(), false, &fields)
+	if err != nil {
+		t.Fatalf("failed to get block by hash: %v", err)
+	}
+	if txs := hashes["transactions"].([]interface{}); len(txs) != 2 || txs[0] != block.Transactions()[0].Hash() {
+		t.Errorf("transaction hashes mismatch: have %v", txs)
+	}
+	unknown := []string{"hash", "sender"}
+	if _, err := api.GetBlockByNumber(1, true, &unknown); err == nil {
+		t.Errorf("unknown transaction field projected")
+	}
+}
+
+// Tests that chained calls see the state changes of the calls before them, and
+// unchained ones the block state only.
+func TestMulticall(t *testing.T) {
+	api, contract := newCounterChain()
+	calls := []CallArgs{
+		counterCall(contract, 100000),
+		counterCall(contract, 20000), // Not covering the intrinsic gas
+		counterCall(contract, 100000),
+	}
+	chained, unchained := true, false
+	tests := []struct {
+		name    string
+		chained *bool
+		outputs []int64
+	}{
+		{"default", nil, []int64{2, 0, 2}},
+		{"unchained", &unchained, []int64{2, 0, 2}},
+		{"chained", &chained, []int64{2, 0, 3}},
+	}
+	for _, test := range tests {
+		results, err := api.Multicall(context.Background(), calls, rpc.LatestBlockNumber, test.chained)
+		if err != nil {
+			t.Fatalf("%s: failed to execute calls: %v", test.name, err)
+		}
+		for i, res := range results {
+			if i == 1 {
+				if res.Error == "" {
+					t.Errorf("%s: call without intrinsic gas succeeded: %x", test.name, res.Result)
+				}
+				continue
+			}
+			if want := common.BigToHash(big.NewInt(test.outputs[i])).Bytes(); !bytes.Equal(res.Result, want) || res.Error != "" {
+				t.Errorf("%s: call %d mismatch: have %x (error %q), want %x", test.name, i, res.Result, res.Error, want)
+			}
+		}
+	}
+	// Chained calls don't modify the state of the block
+	stateDb, _ := api.bc.State()
+	if value := stateDb.GetState(contract, common.Hash{}); value != common.BigToHash(big.NewInt(1)) {
+		t.Errorf("chained calls modified the state: counter is %x", value)
+	}
+	if _, err := api.Multicall(context.Background(), calls, 4, &chained); err == nil {
+		t.Errorf("calls on a missing block executed")
+	}
+	if _, err := api.Multicall(context.Background(), make([]CallArgs, maxCallManyCalls+1), 1, nil); err == nil {
+		t.Errorf("%d calls executed", maxCallManyCalls+1)
+	}
+}
+
+// Tests that pending transactions are paged by offset and limit, and that offsets
+// and limits beyond the matches are clamped rather than overflowing.
+func TestFilterPendingTransactions(t *testing.T) {
+	var txs types.Transactions
+	for nonce := uint64(0); nonce < 5; nonce++ {
+		tx, _ := types.NewTransaction(nonce, common.HexToAddress("0x1234"), big.NewInt(1), core.TxGas, big.NewInt(1), nil).SignECDSA(testBankKey)
+		txs = append(txs, tx)
+	}
+	huge, _ := new(big.Int).SetString("0x10000000000000000", 0)
+	tests := []struct {
+		offset, limit interface{}
+		nonces        []uint64
+	}{
+		{nil, nil, []uint64{0, 1, 2, 3, 4}},
+		{1, 2, []uint64{1, 2}},
+		{3, 5, []uint64{3, 4}},
+		{5, 1, nil},
+		{6, nil, nil},
+		{huge, 1, nil},
+		{1, int64(0x7fffffffffffffff), []uint64{1, 2, 3, 4}},
+		{0, huge, []uint64{0, 1, 2, 3, 4}},
+	}
+	for i, tt := range tests {
+		query := &PendingTxQuery{Offset: rpc.NewHexNumber(tt.offset), Limit: rpc.NewHexNumber(tt.limit)}
+		result, err := filterPendingTransactions(txs, query, nil)
+		if err != nil {
+			t.Errorf("test %d: failed to filter: %v", i, err)
+			continue
+		}
+		if len(result) != len(tt.nonces) {
+			t.Errorf("test %d: result length mismatch: have %d, want %d", i, len(result), len(tt.nonces))
+			continue
+		}
+		for j, tx := range result {
+			if uint64(tx.Nonce) != tt.nonces[j] {
+				t.Errorf("test %d, tx %d: nonce mismatch: have %d, want %d", i, j, uint64(tx.Nonce), tt.nonces[j])
+			}
+		}
+	}
+	// Negative offsets and empty pages are rejected
+	for i, query := range []*PendingTxQuery{{Offset: rpc.NewHexNumber(big.NewInt(-1))}, {Limit: rpc.NewHexNumber(0)}} {
+		if _, err := filterPendingTransactions(txs, query, nil); err == nil {
+			t.Errorf("query %d: expected error", i)
+		}
+	}
+}
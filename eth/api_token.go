@@ -0,0 +1,207 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/hexutil"
+	"github.com/openether/ethcore/core/state"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/rpc"
+)
+
+const (
+	tokenInfoCacheSize    = 1024  // Number of token metadata entries to cache
+	tokenBalanceCacheSize = 16384 // Number of token balances to cache
+	maxTokenBalances      = 1000  // Maximum number of tokens queried in a single Balances request
+)
+
+// ERC-20 function selectors, the first 4 bytes of the hash of their signatures.
+const (
+	selectorBalanceOf = "0x70a08231" // balanceOf(address)
+	selectorDecimals  = "0x313ce567" // decimals()
+	selectorSymbol    = "0x95d89b41" // symbol()
+	selectorName      = "0x06fdde03" // name()
+)
+
+var errNotToken = errors.New("contract does not implement ERC-20 balanceOf")
+
+// TokenInfo is the metadata of an ERC-20 token. The optional fields are left
+// empty if the token doesn't implement them.
+type TokenInfo struct {
+	Address  common.Address `json:"address"`
+	Name     string         `json:"name"`
+	Symbol   string         `json:"symbol"`
+	Decimals *hexutil.Big   `json:"decimals"`
+}
+
+// TokenBalance is the balance of a holder in a single token.
+type TokenBalance struct {
+	*TokenInfo
+	Balance *hexutil.Big `json:"balance"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// tokenBalanceKey identifies a cached balance. Balances are cached per block
+// hash, as the balance at a block never changes.
+type tokenBalanceKey struct {
+	block         common.Hash
+	token, holder common.Address
+}
+
+// PublicTokenAPI offers ERC-20 token queries, sparing clients from encoding and
+// decoding the contract calls themselves. It is served in the token namespace,
+// which isn't enabled by default.
+type PublicTokenAPI struct {
+	chain    *PublicBlockChainAPI
+	infos    *lru.Cache // Token metadata by token address
+	balances *lru.Cache // Balances by tokenBalanceKey
+}
+
+// NewPublicTokenAPI creates a new token API executing its calls through the given
+// blockchain API.
+func NewPublicTokenAPI(chain *PublicBlockChainAPI) *PublicTokenAPI {
+	infos, _ := lru.New(tokenInfoCacheSize)
+	balances, _ := lru.New(tokenBalanceCacheSize)
+	return &PublicTokenAPI{chain: chain, infos: infos, balances: balances}
+}
+
+// Info returns the name, symbol and decimals of the given token, as of the latest
+// block.
+func (api *PublicTokenAPI) Info(token common.Address) (*TokenInfo, error) {
+	statedb, block, err := stateAndBlockByNumber(api.chain.bc, rpc.LatestBlockNumber, api.chain.chainDb)
+	if err != nil {
+		return nil, err
+	}
+	return api.info(token, statedb, block), nil
+}
+
+// BalanceOf returns the balance of the holder in the given token at the given
+// block.
+func (api *PublicTokenAPI) BalanceOf(token common.Address, holder AddressOrName, blockNr rpc.BlockNumber) (*hexutil.Big, error) {
+	address, err := resolveAddress(api.chain.ens, holder)
+	if err != nil {
+		return nil, err
+	}
+	statedb, block, err := stateAndBlockByNumber(api.chain.bc, blockNr, api.chain.chainDb)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr.Int64())
+	}
+	balance, err := api.balanceOf(token, address, statedb, block)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(balance), nil
+}
+
+// Balances returns the balance of the holder in each of the given tokens at the
+// given block, along with the tokens' metadata. The state of the block is loaded
+// once for all tokens. A token failing the query is reported in its result and
+// doesn't affect the others.
+func (api *PublicTokenAPI) Balances(holder AddressOrName, tokens []common.Address, blockNr rpc.BlockNumber) ([]*TokenBalance, error) {
+	if len(tokens) > maxTokenBalances {
+		return nil, fmt.Errorf("too many tokens: %d exceed the limit of %d", len(tokens), maxTokenBalances)
+	}
+	address, err := resolveAddress(api.chain.ens, holder)
+	if err != nil {
+		return nil, err
+	}
+	statedb, block, err := stateAndBlockByNumber(api.chain.bc, blockNr, api.chain.chainDb)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr.Int64())
+	}
+	results := make([]*TokenBalance, len(tokens))
+	for i, token := range tokens {
+		results[i] = &TokenBalance{TokenInfo: api.info(token, statedb, block)}
+		if balance, err := api.balanceOf(token, address, statedb, block); err != nil {
+			results[i].Error = err.Error()
+		} else {
+			results[i].Balance = (*hexutil.Big)(balance)
+		}
+	}
+	return results, nil
+}
+
+// balanceOf returns the balance of the holder in the token, from the cache if
+// available.
+func (api *PublicTokenAPI) balanceOf(token, holder common.Address, statedb *state.StateDB, block *types.Block) (*big.Int, error) {
+	key := tokenBalanceKey{block: block.Hash(), token: token, holder: holder}
+	if balance, ok := api.balances.Get(key); ok {
+		return new(big.Int).Set(balance.(*big.Int)), nil
+	}
+	ret, err := api.call(token, selectorBalanceOf+common.Bytes2Hex(common.LeftPadBytes(holder[:], 32)), statedb, block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ret) < 32 {
+		return nil, errNotToken
+	}
+	balance := new(big.Int).SetBytes(ret[:32])
+	api.balances.Add(key, balance)
+	return new(big.Int).Set(balance), nil
+}
+
+// info returns the metadata of the token, from the cache if available. Only the
+// metadata of deployed contracts is cached, so an address without code is looked
+// up again once a contract is deployed to it.
+func (api *PublicTokenAPI) info(token common.Address, statedb *state.StateDB, block *types.Block) *TokenInfo {
+	if info, ok := api.infos.Get(token); ok {
+		return info.(*TokenInfo)
+	}
+	info := &TokenInfo{Address: token}
+	if ret, err := api.call(token, selectorName, statedb, block); err == nil {
+		info.Name = decodeABIString(ret)
+	}
+	if ret, err := api.call(token, selectorSymbol, statedb, block); err == nil {
+		info.Symbol = decodeABIString(ret)
+	}
+	if ret, err := api.call(token, selectorDecimals, statedb, block); err == nil && len(ret) >= 32 {
+		info.Decimals = (*hexutil.Big)(new(big.Int).SetBytes(ret[:32]))
+	}
+	if statedb.GetCodeSize(token) > 0 {
+		api.infos.Add(token, info)
+	}
+	return info
+}
+
+// call executes a call to the token with the given hex encoded input on a copy
+// of the state, returning the output.
+func (api *PublicTokenAPI) call(token common.Address, input string, statedb *state.StateDB, block *types.Block) ([]byte, error) {
+	out, _, err := api.chain.callOnState(context.Background(), CallArgs{To: &token, Data: input}, statedb, block)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// decodeABIString decodes a string returned by a contract call, either ABI
+// encoded or, as returned by some early tokens, a zero padded bytes32.
+func decodeABIString(ret []byte) string {
+	if len(ret) == 32 {
+		return string(bytes.TrimRight(ret, "\x00"))
+	}
+	if len(ret) < 64 {
+		return ""
+	}
+	offset := new(big.Int).SetBytes(ret[:32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(ret)-32) {
+		return ""
+	}
+	start := offset.Uint64() + 32
+	size := new(big.Int).SetBytes(ret[start-32 : start])
+	if !size.IsUint64() || size.Uint64() > uint64(len(ret))-start {
+		return ""
+	}
+	return string(ret[start : start+size.Uint64()])
+}
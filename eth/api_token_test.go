@@ -0,0 +1,122 @@
+package eth
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/accounts"
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/crypto"
+	"github.com/ethereumclassic/go-ethereum/rpc"
+)
+
+// Init code deploying a token crediting 1000 to 0x00..aa, whose symbol "TKN" is
+// ABI encoded and whose name "Token" is returned as a bytes32, with 18 decimals.
+var testTokenCode = common.FromHex("0x6103e87300000000000000000000000000000000000000aa5560c2602560003960c26000f36000357c01000000000000000000000000000000000000000000000000000000009004806370a0823114604c578063313ce56714605957806395d89b4114606457806306fdde0314609857005b6004355460005260206000f35b601260005260206000f35b602060005260036020527f544b4e000000000000000000000000000000000000000000000000000000000060405260606000f35b7f546f6b656e00000000000000000000000000000000000000000000000000000060005260206000f3")
+
+// newTokenTester creates a token API over a chain whose first block deploys the
+// test token, with a keystore without accounts.
+func newTokenTester(t *testing.T) (*PublicTokenAPI, common.Address, func()) {
+	deploy, _ := types.NewContractCreation(0, new(big.Int), big.NewInt(300000), big.NewInt(1), testTokenCode).SignECDSA(testBankKey)
+	api, chain := newTestBlockChainAPI(2, func(i int, block *core.BlockGen) {
+		if i == 0 {
+			block.AddTx(deploy)
+		}
+	})
+	keydir, err := ioutil.TempDir("", "token-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.am, err = accounts.NewManager(keydir, accounts.LightScryptN, accounts.LightScryptP, false); err != nil {
+		t.Fatal(err)
+	}
+	api.gpo = NewGasPriceOracle(&Ethereum{blockchain: chain, chainDb: api.chainDb, eventMux: api.eventMux})
+
+	return NewPublicTokenAPI(api), crypto.CreateAddress(testBank.Address, 0), func() { os.RemoveAll(keydir) }
+}
+
+func TestTokenBalances(t *testing.T) {
+	api, token, done := newTokenTester(t)
+	defer done()
+
+	holder := AddressOrName{Address: common.HexToAddress("0xaa")}
+	if balance, err := api.BalanceOf(token, holder, rpc.LatestBlockNumber); err != nil || balance.ToInt().Int64() != 1000 {
+		t.Errorf("holder balance mismatch: have %v, %v, want 1000", balance, err)
+	}
+	if balance, err := api.BalanceOf(token, AddressOrName{Address: testBank.Address}, rpc.LatestBlockNumber); err != nil || balance.ToInt().Sign() != 0 {
+		t.Errorf("other balance mismatch: have %v, %v, want 0", balance, err)
+	}
+	// Before the deployment, or at an address without code, there is no token
+	if _, err := api.BalanceOf(token, holder, 0); err != errNotToken {
+		t.Errorf("balance before deployment: have error %v, want %v", err, errNotToken)
+	}
+	if _, err := api.BalanceOf(token, holder, 3); err == nil {
+		t.Errorf("balance at a missing block returned")
+	}
+	results, err := api.Balances(holder, []common.Address{token, testBank.Address}, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to get balances: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("results count mismatch: have %d, want 2", len(results))
+	}
+	if r := results[0]; r.Address != token || r.Balance.ToInt().Int64() != 1000 || r.Symbol != "TKN" || r.Error != "" {
+		t.Errorf("token balance mismatch: %+v %+v", r, r.TokenInfo)
+	}
+	if r := results[1]; r.Address != testBank.Address || r.Balance != nil || r.Error != errNotToken.Error() || r.Symbol != "" {
+		t.Errorf("non-token balance mismatch: %+v %+v", r, r.TokenInfo)
+	}
+	if _, err := api.Balances(holder, make([]common.Address, maxTokenBalances+1), rpc.LatestBlockNumber); err == nil {
+		t.Errorf("balances of %d tokens returned", maxTokenBalances+1)
+	}
+	// Only successfully queried balances are cached, and metadata only of contracts
+	if api.balances.Len() != 2 {
+		t.Errorf("cached balances mismatch: have %d, want 2", api.balances.Len())
+	}
+	if !api.infos.Contains(token) || api.infos.Contains(testBank.Address) {
+		t.Errorf("cached metadata mismatch: have %v", api.infos.Keys())
+	}
+}
+
+func TestTokenInfo(t *testing.T) {
+	api, token, done := newTokenTester(t)
+	defer done()
+
+	info, err := api.Info(token)
+	if err != nil {
+		t.Fatalf("failed to get token info: %v", err)
+	}
+	if info.Address != token || info.Name != "Token" || info.Symbol != "TKN" || info.Decimals.ToInt().Int64() != 18 {
+		t.Errorf("token info mismatch: %+v", info)
+	}
+	if info, err := api.Info(testBank.Address); err != nil || info.Name != "" || info.Symbol != "" || info.Decimals != nil {
+		t.Errorf("non-token info mismatch: have %+v, %v", info, err)
+	}
+}
+
+func TestDecodeABIString(t *testing.T) {
+	abi := func(offset, size int, data string) []byte {
+		ret := append(common.BigToHash(big.NewInt(int64(offset))).Bytes(), common.BigToHash(big.NewInt(int64(size))).Bytes()...)
+		return append(ret, common.RightPadBytes([]byte(data), 32)...)
+	}
+	tests := []struct {
+		ret  []byte
+		want string
+	}{
+		{abi(32, 3, "TKN"), "TKN"},
+		{common.RightPadBytes([]byte("Token"), 32), "Token"},
+		{abi(64, 3, "TKN"), ""},  // Offset beyond the output
+		{abi(32, 33, "TKN"), ""}, // Size beyond the output
+		{nil, ""},
+		{make([]byte, 40), ""},
+	}
+	for i, tt := range tests {
+		if have := decodeABIString(tt.ret); have != tt.want {
+			t.Errorf("test %d: have %q, want %q", i, have, tt.want)
+		}
+	}
+}
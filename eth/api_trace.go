@@ -0,0 +1,370 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/hexutil"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/core/vm"
+	"github.com/openether/ethcore/rpc"
+)
+
+const (
+	traceCacheSize       = 128   // Number of blocks whose traces are cached
+	maxTraceFilterBlocks = 1000  // Maximum number of blocks searched by a single trace_filter request
+	maxTraceFilterTxs    = 10000 // Maximum number of transactions replayed by a single trace_filter request
+)
+
+// traceErrFailed is the error of calls which failed, reverting their changes.
+const traceErrFailed = "execution failed"
+
+// TraceAction describes a call, create or suicide. Calls set the call type, the
+// sender, the recipient, the gas, the input and the value; creates the sender,
+// the gas, the init code and the value; suicides the suicided contract, the
+// beneficiary and the balance sent to it.
+type TraceAction struct {
+	CallType      string          `json:"callType,omitempty"` // call, callcode or delegatecall
+	From          *common.Address `json:"from,omitempty"`
+	To            *common.Address `json:"to,omitempty"`
+	Gas           *hexutil.Big    `json:"gas,omitempty"`
+	Input         *hexutil.Bytes  `json:"input,omitempty"`
+	Init          *hexutil.Bytes  `json:"init,omitempty"`
+	Value         *hexutil.Big    `json:"value,omitempty"`
+	Address       *common.Address `json:"address,omitempty"`
+	RefundAddress *common.Address `json:"refundAddress,omitempty"`
+	Balance       *hexutil.Big    `json:"balance,omitempty"`
+}
+
+// TraceResult is the outcome of a successful call or create.
+type TraceResult struct {
+	GasUsed *hexutil.Big    `json:"gasUsed"`
+	Output  *hexutil.Bytes  `json:"output,omitempty"`  // Calls only
+	Address *common.Address `json:"address,omitempty"` // Creates only
+	Code    *hexutil.Bytes  `json:"code,omitempty"`    // Creates only
+}
+
+// Trace is a call, create or suicide of a transaction, in the format of the
+// trace namespace of Parity. The transaction itself is the trace with an empty
+// trace address, followed by the calls its contracts made, depth first.
+type Trace struct {
+	Action       TraceAction  `json:"action"`
+	Result       *TraceResult `json:"result"` // Nil for failed calls and suicides
+	Error        string       `json:"error,omitempty"`
+	TraceAddress []int        `json:"traceAddress"`
+	Subtraces    int          `json:"subtraces"`
+	Type         string       `json:"type"` // call, create or suicide
+	BlockNumber  uint64       `json:"blockNumber"`
+	BlockHash    common.Hash  `json:"blockHash"`
+	TxHash       common.Hash  `json:"transactionHash"`
+	TxPosition   int          `json:"transactionPosition"`
+}
+
+// from returns the account the traced action originates from.
+func (trace *Trace) from() common.Address {
+	if trace.Action.Address != nil {
+		return *trace.Action.Address
+	}
+	return *trace.Action.From
+}
+
+// to returns the account the traced action is directed at, the created contract
+// for creates.
+func (trace *Trace) to() (common.Address, bool) {
+	switch {
+	case trace.Action.To != nil:
+		return *trace.Action.To, true
+	case trace.Action.RefundAddress != nil:
+		return *trace.Action.RefundAddress, true
+	case trace.Result != nil && trace.Result.Address != nil:
+		return *trace.Result.Address, true
+	}
+	return common.Address{}, false
+}
+
+// TraceFilterArgs selects the traces returned by trace_filter.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`   // Latest block if nil
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`     // Latest block if nil
+	FromAddress []common.Address `json:"fromAddress"` // Any sender if empty
+	ToAddress   []common.Address `json:"toAddress"`   // Any recipient if empty
+	After       uint64           `json:"after"`       // Number of matching traces skipped
+	Count       uint64           `json:"count"`       // Maximum number of traces returned, all if 0
+}
+
+func containsAddress(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether a trace has one of the senders and one of the
+// recipients selected, if any are.
+func (args *TraceFilterArgs) matches(trace *Trace) bool {
+	if len(args.FromAddress) > 0 && !containsAddress(args.FromAddress, trace.from()) {
+		return false
+	}
+	if len(args.ToAddress) > 0 {
+		to, ok := trace.to()
+		if !ok || !containsAddress(args.ToAddress, to) {
+			return false
+		}
+	}
+	return true
+}
+
+// PublicTraceAPI serves the calls, value transfers, creates and suicides made by
+// transactions, including those made by contracts, compatible with the trace
+// namespace of Parity. Blocks aren't executed on import, so the traces of a block
+// are recorded by replaying it on top of its parent's state the first time they
+// are requested, which requires that state to be available, and then cached.
+type PublicTraceAPI struct {
+	eth       *Ethereum
+	traces    *lru.Cache // Traces of a block by block hash
+	filterTxs int        // Maximum number of transactions replayed by a filter
+}
+
+// NewPublicTraceAPI creates a new trace API.
+func NewPublicTraceAPI(eth *Ethereum) *PublicTraceAPI {
+	traces, _ := lru.New(traceCacheSize)
+	return &PublicTraceAPI{eth: eth, traces: traces, filterTxs: maxTraceFilterTxs}
+}
+
+// Block returns the traces of all transactions of the canonical block with the
+// given number, or nil if there is no such block.
+func (api *PublicTraceAPI) Block(ctx context.Context, number rpc.BlockNumber) ([]*Trace, error) {
+	block := blockByNumber(api.eth.BlockChain(), number)
+	if block == nil {
+		return nil, nil
+	}
+	return api.blockTraces(ctx, block)
+}
+
+// Transaction returns the traces of the transaction with the given hash, or nil
+// if the transaction isn't known.
+func (api *PublicTraceAPI) Transaction(ctx context.Context, hash common.Hash) ([]*Trace, error) {
+	tx, blockHash, _, _ := core.GetTransaction(api.eth.ChainDb(), hash)
+	if tx == nil {
+		return nil, nil
+	}
+	block := api.eth.BlockChain().GetBlock(blockHash)
+	if block == nil {
+		return nil, fmt.Errorf("block %x of tx %x not found", blockHash, hash)
+	}
+	traces, err := api.blockTraces(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	var result []*Trace
+	for _, trace := range traces {
+		if trace.TxHash == hash {
+			result = append(result, trace)
+		}
+	}
+	return result, nil
+}
+
+// Filter returns the traces of the canonical blocks in [fromBlock, toBlock] from
+// and to the given addresses, oldest first.
+//
+// Traces aren't indexed, so every block searched whose traces aren't cached is
+// replayed, costing as much as executing its transactions. A single request
+// searches at most 1000 blocks and replays at most 10000 transactions, failing
+// once either is exceeded; as only the traces of the last 128 blocks traced are
+// cached, repeating a query over a wide range replays it again.
+func (api *PublicTraceAPI) Filter(ctx context.Context, args TraceFilterArgs) ([]*Trace, error) {
+	head := api.eth.BlockChain().CurrentBlock().NumberU64()
+	from, to := head, head
+	if args.FromBlock != nil && *args.FromBlock >= 0 {
+		from = uint64(*args.FromBlock)
+	}
+	if args.ToBlock != nil && *args.ToBlock >= 0 {
+		to = uint64(*args.ToBlock)
+	}
+	if to > head {
+		to = head
+	}
+	if to < from {
+		return nil, fmt.Errorf("toBlock %d before fromBlock %d", to, from)
+	}
+	if to-from >= maxTraceFilterBlocks {
+		return nil, fmt.Errorf("block range %d-%d exceeds the maximum of %d blocks", from, to, maxTraceFilterBlocks)
+	}
+	var (
+		result   = []*Trace{}
+		skipped  uint64
+		replayed int
+	)
+	for n := from; n <= to; n++ {
+		block := api.eth.BlockChain().GetBlockByNumber(n)
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", n)
+		}
+		if !api.traces.Contains(block.Hash()) {
+			if replayed += len(block.Transactions()); replayed > api.filterTxs {
+				return nil, fmt.Errorf("blocks %d-%d exceed the maximum of %d transactions replayed", from, n, api.filterTxs)
+			}
+		}
+		traces, err := api.blockTraces(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+		for _, trace := range traces {
+			if !args.matches(trace) {
+				continue
+			}
+			if skipped < args.After {
+				skipped++
+				continue
+			}
+			result = append(result, trace)
+			if args.Count > 0 && uint64(len(result)) == args.Count {
+				return result, nil
+			}
+		}
+	}
+	return result, nil
+}
+
+// blockTraces returns the traces of the transactions of a block, replaying it
+// unless they are cached.
+func (api *PublicTraceAPI) blockTraces(ctx context.Context, block *types.Block) ([]*Trace, error) {
+	if cached, ok := api.traces.Get(block.Hash()); ok {
+		return cached.([]*Trace), nil
+	}
+	if len(block.Transactions()) == 0 {
+		return []*Trace{}, nil
+	}
+	bc := api.eth.BlockChain()
+	parent := bc.GetBlock(block.ParentHash())
+	if parent == nil {
+		return nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+	}
+	statedb, err := bc.StateAt(parent.Root())
+	if err != nil {
+		return nil, fmt.Errorf("state of block #%d unavailable: %v", parent.NumberU64(), err)
+	}
+	var (
+		tracer    = vm.NewCallTracer()
+		processor = core.NewStateProcessor(api.eth.chainConfig, bc)
+		homestead = api.eth.chainConfig.IsHomestead(block.Number())
+		traces    []*Trace
+	)
+	_, _, _, err = processor.ProcessTraced(block, statedb, tracer, func(i int, tx *types.Transaction, receipt *types.Receipt) error {
+		if err := ctx.Err(); err != nil {
+			return executionAborted(err)
+		}
+		frames := tracer.Finish(receipt.Status == types.TxSuccess)
+		traces = append(traces, txTraces(block, i, tx, receipt, frames, homestead)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	api.traces.Add(block.Hash(), traces)
+	return traces, nil
+}
+
+// txTraces converts the frames of a transaction into traces, the first of them
+// being the transaction's own.
+func txTraces(block *types.Block, index int, tx *types.Transaction, receipt *types.Receipt, frames []*vm.CallFrame, homestead bool) []*Trace {
+	traces := make([]*Trace, len(frames))
+	for i, frame := range frames {
+		if i == 0 {
+			traces[i] = rootTrace(tx, receipt, frame, homestead)
+		} else {
+			traces[i] = frameTrace(frame)
+		}
+		traces[i].TraceAddress = frame.TraceAddress
+		traces[i].Subtraces = frame.Subtraces
+		traces[i].BlockNumber = block.NumberU64()
+		traces[i].BlockHash = block.Hash()
+		traces[i].TxHash = tx.Hash()
+		traces[i].TxPosition = index
+	}
+	return traces
+}
+
+// rootTrace describes a transaction. Its gas excludes the intrinsic gas of the
+// transaction, as that of the calls it makes excludes the cost of making them.
+func rootTrace(tx *types.Transaction, receipt *types.Receipt, frame *vm.CallFrame, homestead bool) *Trace {
+	from, _ := tx.From()
+	gas, gasUsed := frame.Gas, frame.GasUsed
+	if gas == nil {
+		// No code was executed
+		gas = new(big.Int).Sub(tx.Gas(), core.IntrinsicGas(tx.Data(), tx.To() == nil, homestead))
+		gasUsed = new(big.Int)
+	}
+	data := hexutil.Bytes(tx.Data())
+	trace := &Trace{
+		Action: TraceAction{From: &from, Gas: (*hexutil.Big)(gas), Value: (*hexutil.Big)(tx.Value())},
+	}
+	if frame.Failed {
+		trace.Error = traceErrFailed
+	} else {
+		trace.Result = &TraceResult{GasUsed: (*hexutil.Big)(gasUsed)}
+	}
+	output := hexutil.Bytes(frame.Output)
+	if tx.To() == nil {
+		trace.Type = "create"
+		trace.Action.Init = &data
+		if trace.Result != nil {
+			address := receipt.ContractAddress
+			trace.Result.Address, trace.Result.Code = &address, &output
+		}
+		return trace
+	}
+	trace.Type = "call"
+	trace.Action.CallType = "call"
+	trace.Action.To, trace.Action.Input = tx.To(), &data
+	if trace.Result != nil {
+		trace.Result.Output = &output
+	}
+	return trace
+}
+
+// frameTrace describes a call made by a contract.
+func frameTrace(frame *vm.CallFrame) *Trace {
+	from, to := frame.From, frame.To
+	if frame.Op == vm.SUICIDE {
+		return &Trace{
+			Type:   "suicide",
+			Action: TraceAction{Address: &from, RefundAddress: &to, Balance: (*hexutil.Big)(frame.Value)},
+		}
+	}
+	var (
+		input  = hexutil.Bytes(frame.Input)
+		output = hexutil.Bytes(frame.Output)
+		trace  = &Trace{
+			Action: TraceAction{From: &from, Gas: (*hexutil.Big)(frame.Gas), Value: (*hexutil.Big)(frame.Value)},
+		}
+	)
+	if frame.Failed {
+		trace.Error = traceErrFailed
+	} else {
+		trace.Result = &TraceResult{GasUsed: (*hexutil.Big)(frame.GasUsed)}
+	}
+	if frame.Op == vm.CREATE {
+		trace.Type = "create"
+		trace.Action.Init = &input
+		if trace.Result != nil {
+			trace.Result.Address, trace.Result.Code = &to, &output
+		}
+		return trace
+	}
+	trace.Type = "call"
+	trace.Action.CallType = strings.ToLower(frame.Op.String())
+	trace.Action.To, trace.Action.Input = &to, &input
+	if trace.Result != nil {
+		trace.Result.Output = &output
+	}
+	return trace
+}
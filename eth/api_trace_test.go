@@ -0,0 +1,100 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/rpc"
+)
+
+// newTraceTestAPI creates a trace API over a chain whose blocks each hold a
+// transfer from the test bank to the address of the block's number.
+func newTraceTestAPI(t *testing.T, blocks int) (*PublicTraceAPI, *core.BlockChain) {
+	chain, db, _ := newTestChainWithReceipts(t, blocks, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.Address{byte(i + 1)}, big.NewInt(1000), core.TxGas, nil, nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	})
+	return NewPublicTraceAPI(&Ethereum{blockchain: chain, chainConfig: chain.Config(), chainDb: db}), chain
+}
+
+func TestTraceFilter(t *testing.T) {
+	api, _ := newTraceTestAPI(t, 3)
+	first, last := rpc.BlockNumber(1), rpc.BlockNumber(3)
+
+	tests := []struct {
+		args TraceFilterArgs
+		want []byte // Recipients of the traces returned
+	}{
+		{TraceFilterArgs{FromBlock: &first, ToBlock: &last}, []byte{1, 2, 3}},
+		{TraceFilterArgs{}, []byte{3}},
+		{TraceFilterArgs{FromBlock: &first, ToBlock: &last, ToAddress: []common.Address{{2}}}, []byte{2}},
+		{TraceFilterArgs{FromBlock: &first, ToBlock: &last, FromAddress: []common.Address{{2}}}, []byte{}},
+		{TraceFilterArgs{FromBlock: &first, ToBlock: &last, After: 1, Count: 1}, []byte{2}},
+	}
+	for i, tt := range tests {
+		traces, err := api.Filter(context.Background(), tt.args)
+		if err != nil {
+			t.Fatalf("test %d: failed to filter traces: %v", i, err)
+		}
+		if len(traces) != len(tt.want) {
+			t.Fatalf("test %d: trace count mismatch: have %d, want %d", i, len(traces), len(tt.want))
+		}
+		for j, trace := range traces {
+			if trace.Type != "call" || *trace.Action.From != testBank.Address || *trace.Action.To != (common.Address{tt.want[j]}) {
+				t.Errorf("test %d, trace %d: mismatch: %+v", i, j, trace.Action)
+			}
+			if trace.BlockNumber != uint64(tt.want[j]) {
+				t.Errorf("test %d, trace %d: block mismatch: have %d, want %d", i, j, trace.BlockNumber, tt.want[j])
+			}
+		}
+	}
+	if _, err := api.Filter(context.Background(), TraceFilterArgs{FromBlock: &last, ToBlock: &first}); err == nil {
+		t.Errorf("reversed block range accepted")
+	}
+}
+
+// Tests that a filter fails once it would replay too many transactions, traces
+// already cached not counting towards the limit.
+func TestTraceFilterReplayLimit(t *testing.T) {
+	api, chain := newTraceTestAPI(t, 3)
+	api.filterTxs = 2
+	first, last := rpc.BlockNumber(1), rpc.BlockNumber(3)
+
+	if _, err := api.Filter(context.Background(), TraceFilterArgs{FromBlock: &first, ToBlock: &last}); err == nil {
+		t.Fatalf("filter replaying 3 transactions accepted")
+	}
+	// Tracing the blocks caches their traces, the filter must not replay them
+	if _, err := api.Block(context.Background(), 2); err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if !api.traces.Contains(chain.GetBlockByNumber(2).Hash()) {
+		t.Fatalf("block traces not cached")
+	}
+	traces, err := api.Filter(context.Background(), TraceFilterArgs{FromBlock: &first, ToBlock: &last})
+	if err != nil {
+		t.Fatalf("failed to filter traces: %v", err)
+	}
+	if len(traces) != 3 {
+		t.Errorf("trace count mismatch: have %d, want 3", len(traces))
+	}
+}
+
+func TestTraceTransaction(t *testing.T) {
+	api, chain := newTraceTestAPI(t, 2)
+	tx := chain.GetBlockByNumber(2).Transactions()[0]
+
+	traces, err := api.Transaction(context.Background(), tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	if len(traces) != 1 || traces[0].TxHash != tx.Hash() || traces[0].Result == nil || traces[0].Result.GasUsed.ToInt().Sign() != 0 {
+		t.Fatalf("trace mismatch: %+v", traces)
+	}
+	if traces, err := api.Transaction(context.Background(), common.Hash{1}); traces != nil || err != nil {
+		t.Errorf("trace of unknown transaction: %v, %v", traces, err)
+	}
+}
@@ -0,0 +1,216 @@
+package eth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/hexutil"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/core/vm"
+	"github.com/openether/ethcore/rpc"
+)
+
+const (
+	maxTraceStructLogs = 100000           // Maximum and default number of instructions logged by debug_traceTransaction, or for a whole block
+	maxTraceSize       = 64 * 1024 * 1024 // Maximum bytes of stack, memory and storage captured by debug_traceTransaction
+)
+
+// TraceConfig holds the options of debug_traceTransaction. The stack and the
+// storage accessed are captured by default, the memory is not.
+type TraceConfig struct {
+	DisableStack   bool           `json:"disableStack"`
+	DisableStorage bool           `json:"disableStorage"`
+	EnableMemory   bool           `json:"enableMemory"`
+	Limit          *rpc.HexNumber `json:"limit"` // Maximum number of instructions logged, over a whole traced block, 100000 if nil or 0
+}
+
+// logConfig returns the struct logger configuration selected by the options,
+// the defaults if there are none. The number of instructions and bytes captured
+// are always bounded, as traces are served on the public API.
+func (config *TraceConfig) logConfig() (vm.LogConfig, error) {
+	cfg := vm.LogConfig{EnableStorage: true, Limit: maxTraceStructLogs, MaxSize: maxTraceSize}
+	if config == nil {
+		return cfg, nil
+	}
+	cfg.DisableStack = config.DisableStack
+	cfg.EnableStorage = !config.DisableStorage
+	cfg.EnableMemory = config.EnableMemory
+	if config.Limit != nil {
+		switch limit := config.Limit.BigInt(); {
+		case limit.Sign() < 0 || limit.Cmp(big.NewInt(maxTraceStructLogs)) > 0:
+			return cfg, fmt.Errorf("limit %v out of range, the maximum is %d", limit, maxTraceStructLogs)
+		case limit.Sign() > 0:
+			cfg.Limit = int(limit.Int64())
+		}
+	}
+	return cfg, nil
+}
+
+// TxTraceResult is the trace of a transaction of a traced block, or the reason
+// it couldn't be executed.
+type TxTraceResult struct {
+	TxHash common.Hash      `json:"txHash"`
+	Result *ExecutionResult `json:"result,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// TraceBlockByNumber replays the canonical block with the given number and
+// returns the traces of its transactions in order, as debug_traceTransaction
+// would for each of them.
+func (s *PublicDebugAPI) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceConfig) ([]*TxTraceResult, error) {
+	block := blockByNumber(s.eth.BlockChain(), number)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return s.traceBlock(ctx, block, config)
+}
+
+// TraceBlockByHash replays the block with the given hash, which needn't be
+// canonical, and returns the traces of its transactions in order, as
+// debug_traceTransaction would for each of them.
+func (s *PublicDebugAPI) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceConfig) ([]*TxTraceResult, error) {
+	block := s.eth.BlockChain().GetBlock(hash)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", hash)
+	}
+	return s.traceBlock(ctx, block, config)
+}
+
+// traceBlock executes the transactions of a block one after the other on top of
+// its parent's state, tracing each of them. The limits of the trace apply to the
+// block as a whole: once reached, the instructions of the remaining transactions
+// are only counted.
+func (s *PublicDebugAPI) traceBlock(ctx context.Context, block *types.Block, config *TraceConfig) ([]*TxTraceResult, error) {
+	parent := s.eth.BlockChain().GetBlock(block.ParentHash())
+	if parent == nil {
+		return nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+	}
+	logConfig, err := config.logConfig()
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := s.eth.BlockChain().StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		header  = block.Header()
+		signer  = s.eth.chainConfig.GetSigner(header.Number)
+		gp      = new(core.GasPool).AddGas(block.GasLimit())
+		tracer  = vm.NewStructLoggerConfig(logConfig)
+		results = make([]*TxTraceResult, len(block.Transactions()))
+	)
+	for i, tx := range block.Transactions() {
+		statedb.StartRecord(tx.Hash(), block.Hash(), i)
+		tx.SetSigner(signer)
+		vmenv := core.NewEnv(statedb, s.eth.chainConfig, s.eth.BlockChain(), tx, header)
+		vmenv.SetTracer(tracer)
+		logged, dropped := len(tracer.StructLogs()), tracer.Dropped()
+
+		stop := cancelOnDone(ctx, vmenv)
+		ret, gas, failed, err := core.ApplyMessage(vmenv, tx, gp)
+		stop()
+		if err := ctx.Err(); err != nil {
+			return nil, executionAborted(err)
+		}
+		results[i] = &TxTraceResult{TxHash: tx.Hash()}
+		if err != nil {
+			// The block is invalid from here on, later transactions can't be traced
+			results[i].Error = err.Error()
+			return results[:i+1], nil
+		}
+		results[i].Result = &ExecutionResult{
+			Gas:               (*hexutil.Big)(gas),
+			Failed:            failed,
+			ReturnValue:       ret,
+			StructLogs:        tracer.StructLogs()[logged:],
+			StructLogsDropped: tracer.Dropped() - dropped,
+		}
+		// Finalise the transaction's changes before replaying the next one
+		statedb.IntermediateRoot(false)
+	}
+	return results, nil
+}
+
+// StdTraceConfig holds the options of debug_standardTraceBlockToFile.
+type StdTraceConfig struct {
+	// TxHash restricts tracing to a single transaction of the block.
+	TxHash *common.Hash `json:"txHash"`
+}
+
+// StandardTraceBlockToFile replays the block with the given hash and writes a
+// standard JSON trace of each of its transactions to a file in the temporary
+// directory, returning the paths of the files written. This keeps the traces of
+// big blocks out of the RPC response.
+func (s *PublicDebugAPI) StandardTraceBlockToFile(blockHash common.Hash, config *StdTraceConfig) ([]string, error) {
+	block := s.eth.BlockChain().GetBlock(blockHash)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	parent := s.eth.BlockChain().GetBlock(block.ParentHash())
+	if parent == nil {
+		return nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+	}
+	statedb, err := s.eth.BlockChain().StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	var txHash common.Hash
+	if config != nil && config.TxHash != nil {
+		txHash = *config.TxHash
+		if block.Transaction(txHash) == nil {
+			return nil, fmt.Errorf("tx %x not found in block %x", txHash, blockHash)
+		}
+	}
+
+	var (
+		header = block.Header()
+		signer = s.eth.chainConfig.GetSigner(header.Number)
+		gp     = new(core.GasPool).AddGas(block.GasLimit())
+		files  []string
+	)
+	for i, tx := range block.Transactions() {
+		statedb.StartRecord(tx.Hash(), blockHash, i)
+		tx.SetSigner(signer)
+		vmenv := core.NewEnv(statedb, s.eth.chainConfig, s.eth.BlockChain(), tx, header)
+
+		var (
+			dump   *os.File
+			writer *bufio.Writer
+			tracer *vm.JSONLogger
+		)
+		if txHash == (common.Hash{}) || txHash == tx.Hash() {
+			prefix := fmt.Sprintf("block_%#x-%d-%#x-", blockHash.Bytes()[:4], i, tx.Hash().Bytes()[:4])
+			if dump, err = ioutil.TempFile(os.TempDir(), prefix); err != nil {
+				return nil, err
+			}
+			files = append(files, dump.Name())
+			writer = bufio.NewWriter(dump)
+			tracer = vm.NewJSONLogger(writer)
+			vmenv.SetTracer(tracer)
+		}
+		start := time.Now()
+		ret, gas, _, err := core.ApplyMessage(vmenv, tx, gp)
+		if tracer != nil {
+			tracer.CaptureEnd(ret, gas, time.Since(start), err)
+			writer.Flush()
+			dump.Close()
+		}
+		if err != nil {
+			return files, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+		}
+		if txHash == tx.Hash() {
+			break
+		}
+		// Finalise the transaction's changes before replaying the next one
+		statedb.IntermediateRoot(false)
+	}
+	return files, nil
+}
@@ -0,0 +1,86 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/rpc"
+)
+
+func TestTraceConfigLimits(t *testing.T) {
+	tests := []struct {
+		limit *rpc.HexNumber
+		want  int
+		fails bool
+	}{
+		{nil, maxTraceStructLogs, false},
+		{rpc.NewHexNumber(0), maxTraceStructLogs, false},
+		{rpc.NewHexNumber(10), 10, false},
+		{rpc.NewHexNumber(maxTraceStructLogs + 1), 0, true},
+		{rpc.NewHexNumber(-1), 0, true},
+	}
+	for _, tt := range tests {
+		cfg, err := (&TraceConfig{Limit: tt.limit, EnableMemory: true}).logConfig()
+		if tt.fails {
+			if err == nil {
+				t.Errorf("limit %v: expected error", tt.limit)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("limit %v: unexpected error: %v", tt.limit, err)
+			continue
+		}
+		if cfg.Limit != tt.want || cfg.MaxSize != maxTraceSize {
+			t.Errorf("limit %v: got limit %d, size %d, want %d, %d", tt.limit, cfg.Limit, cfg.MaxSize, tt.want, maxTraceSize)
+		}
+	}
+	if cfg, _ := (*TraceConfig)(nil).logConfig(); cfg.Limit != maxTraceStructLogs || cfg.MaxSize != maxTraceSize || !cfg.EnableStorage {
+		t.Errorf("default config mismatch: %+v", cfg)
+	}
+}
+
+// Tests that the instruction limit of a block trace applies to all transactions
+// of the block together.
+func TestTraceBlockLimit(t *testing.T) {
+	// Two deployments running ten JUMPDESTs each
+	code := bytes.Repeat([]byte{0x5b}, 10)
+	_, chain := newTestBlockChainAPI(1, func(i int, block *core.BlockGen) {
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := types.NewContractCreation(nonce, new(big.Int), big.NewInt(100000), big.NewInt(1), code).SignECDSA(testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	api := NewPublicDebugAPI(&Ethereum{blockchain: chain, chainConfig: chain.Config()})
+
+	full, err := api.TraceBlockByNumber(context.Background(), 1, nil)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(full) != 2 || full[0].Result == nil || full[1].Result == nil {
+		t.Fatalf("traces mismatch: %+v", full)
+	}
+	first, second := len(full[0].Result.StructLogs), len(full[1].Result.StructLogs)
+	if first < 10 || second < 10 || full[0].Result.StructLogsDropped != 0 || full[1].Result.StructLogsDropped != 0 {
+		t.Fatalf("full trace mismatch: logged %d and %d", first, second)
+	}
+	limit := first + 3
+	traces, err := api.TraceBlockByNumber(context.Background(), 1, &TraceConfig{Limit: rpc.NewHexNumber(limit)})
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if have := len(traces[0].Result.StructLogs); have != first || traces[0].Result.StructLogsDropped != 0 {
+		t.Errorf("first trace mismatch: logged %d, dropped %d, want %d, 0", have, traces[0].Result.StructLogsDropped, first)
+	}
+	if have := len(traces[1].Result.StructLogs); have != 3 || traces[1].Result.StructLogsDropped != second-3 {
+		t.Errorf("second trace mismatch: logged %d, dropped %d, want 3, %d", have, traces[1].Result.StructLogsDropped, second-3)
+	}
+	if !reflect.DeepEqual(traces[1].Result.StructLogs, full[1].Result.StructLogs[:3]) {
+		t.Errorf("second trace doesn't start with the transaction's first instructions")
+	}
+}
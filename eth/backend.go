@@ -13,9 +13,12 @@ import (
 	"github.com/openether/ethcore/common"
 	"github.com/openether/ethcore/common/compiler"
 	"github.com/openether/ethcore/common/httpclient"
+	"github.com/openether/ethcore/common/memwatch"
+	"github.com/openether/ethcore/common/registrar"
 	"github.com/openether/ethcore/common/registrar/ethreg"
 	"github.com/openether/ethcore/core"
 	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/eth/dappstore"
 	"github.com/openether/ethcore/eth/downloader"
 	"github.com/openether/ethcore/eth/filters"
 	"github.com/openether/ethcore/ethdb"
@@ -24,6 +27,8 @@ import (
 	"github.com/openether/ethcore/logger/glog"
 	"github.com/openether/ethcore/node"
 	"github.com/openether/ethcore/p2p"
+	"github.com/openether/ethcore/p2p/discover"
+	"github.com/openether/ethcore/pow"
 	"github.com/openether/ethcore/rlp"
 	"github.com/openether/ethcore/rpc"
 )
@@ -34,6 +39,8 @@ const (
 
 	autoDAGcheckInterval = 10 * time.Hour
 	autoDAGepochHeight   = epochLength / 2
+
+	nameCacheSize = 1024 // Number of registrar name and reverse lookups to cache
 )
 
 type Config struct {
@@ -44,21 +51,58 @@ type Config struct {
 	SyncMode  downloader.SyncMode // Enables the state download based fast synchronisation algorithm
 	MaxPeers  int
 
+	SyncMinPeers int      // Minimum number of peers ahead of us on the same head required before accepting a sync target
+	SyncTDMargin *big.Int // Minimum total difficulty lead a sync target must have over the local chain
+
+	ServeLimits     *ServeLimits // Limits on the chain data served to each peer, nil for DefaultServeLimits
+	NodeDataCache   int          // Number of state entries cached for serving fast syncing peers, 0 to disable
+	NodeDataReaders int          // Number of state requests served from disk concurrently
+
+	ForkAlertDepth uint64 // Depth beyond which competing branches are reported, 0 for the default
+
 	BlockChainVersion  int
 	SkipBcVersionCheck bool // e.g. blockchain export
 	DatabaseCache      int
 	DatabaseHandles    int
 
+	CompactionWindows []ethdb.CompactionWindow // Daily windows the chain database is compacted in, if deferred
+	CompactionIdle    time.Duration            // Import idle time after which the chain database is compacted, if deferred
+
+	TxLookupLimit uint64 // Number of recent blocks whose transactions are indexed by hash, zero for all
+	MinFreeDisk   uint64 // Free bytes in the datadir below which block import stops, zero to not watch storage
+	ArchiveDir    string // Directory of block archives imported ahead of fast sync, if any
+
+	ArchiveMirrors     []string               // HTTPS base URLs of block archive mirrors
+	ArchiveCheckpoints map[uint64]common.Hash // Last block hashes of the archives to download from mirrors, by first block
+
 	NatSpec   bool
 	DocRoot   string
+
+	IPFSGateway string // HTTP gateway to resolve ipfs:// URIs through, none if empty
+	BzzGateway  string // HTTP gateway to resolve bzz:// URIs through, none if empty
+
+	HTTPClient httpclient.Config // Proxy, timeout, size limit and TLS settings for fetching offchain docs
+	DappQuota  uint64            // Bytes each origin can keep in the dapp key-value store, default if 0
 	AutoDAG   bool
-	PowTest   bool
-	PowShared bool
+	Sealer    pow.Config // Proof-of-work blocks are sealed with, full ethash by default
 
 	AccountManager *accounts.Manager
 	Etherbase      common.Address
 	GasPrice       *big.Int
 	SolcPath       string
+	VyperPath      string
+
+	UnprotectedTxs  core.UnprotectedTxPolicy // Acceptance and relay of transactions without replay protection
+	TxOrdering      string                   // Name of the strategy ordering transactions for inclusion, price if empty
+	TxMinGasPrice   *big.Int                 // Lowest gas price of transactions from the network accepted into the pool, none if nil
+	PrivateTxs      bool                     // Keeps local transactions from the public network, see PrivateTxRelays
+	PrivateTxRelays []*discover.Node         // Peers private transactions are sent to, held until released if none
+	PayoutSplit     []PayoutShare            // Shares of the rewards of blocks credited to local accounts paid out to other addresses
+	ENSRegistry     common.Address           // Address of the ENS registry to resolve names with, none if zero
+	Filters         filters.Config           // Lifetime and capacity limits of installed filters
+	RPCGasCap       *big.Int                 // Gas limit of eth_call and eth_estimateGas executions, none if nil
+	RPCEVMTimeout   time.Duration            // Wall time limit of eth_call and eth_estimateGas executions, none if zero
+	StaleHead       time.Duration            // Head block age beyond which the node reports itself not ready, never if zero
 
 	UseAddrTxIndex bool
 
@@ -87,13 +131,21 @@ type Ethereum struct {
 	// Handlers
 	txPool          *core.TxPool
 	txMu            sync.Mutex
+	nonces          *nonceManager
+	nameCache       *registrar.Cache
+	dappStore       *dappstore.Store
+	ens             *registrar.ENS
 	blockchain      *core.BlockChain
 	accountManager  *accounts.Manager
 	//pow             *Ethash
 	protocolManager *ProtocolManager
 	SolcPath        string
 	solc            *compiler.Solidity
+	VyperPath       string
+	vyper           *compiler.Vyper
 	gpo             *GasPriceOracle
+	payouts         *payoutSplitter // Splits the rewards of blocks of local accounts, nil if not configured
+	txExpiry        *txExpirer      // Drops or cancels local transactions once their time-to-live elapses
 
 	GpoMinGasPrice          *big.Int
 	GpoMaxGasPrice          *big.Int
@@ -109,9 +161,20 @@ type Ethereum struct {
 	NatSpec       bool
 	netVersionId  int
 	netRPCService *PublicNetAPI
+
+	datadir   string      // Data directory of the node, empty if ephemeral
+	p2pServer *p2p.Server // Set once the service is started
+
+	unwatchMemory func()           // Stops relieving memory pressure, set once the service is started
+	storageWatch  *storageWatchdog // Stops import when disk space or file descriptors run out, nil if disabled
+	headWatch     *headWatch       // Tracks the age of the head block, set once the service is started
 }
 
 func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
+	// Defer the chain database compactions to the configured windows or idle periods
+	deferCompaction := len(config.CompactionWindows) > 0 || config.CompactionIdle > 0
+	ethdb.SetDeferCompaction("chaindata", deferCompaction)
+
 	// Open the chain database and perform any upgrades needed
 	chainDb, err := ctx.OpenDatabase("chaindata", config.DatabaseCache, config.DatabaseHandles)
 	if err != nil {
@@ -123,6 +186,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if err := addMipmapBloomBins(chainDb); err != nil {
 		return nil, err
 	}
+	if err := compactReceipts(chainDb); err != nil {
+		return nil, err
+	}
 
 	dappDb, err := ctx.OpenDatabase("dapp", config.DatabaseCache, config.DatabaseHandles)
 	if err != nil {
@@ -176,13 +242,25 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		netVersionId:            config.NetworkId,
 		NatSpec:                 config.NatSpec,
 		SolcPath:                config.SolcPath,
+		VyperPath:               config.VyperPath,
 		GpoMinGasPrice:          config.GpoMinGasPrice,
 		GpoMaxGasPrice:          config.GpoMaxGasPrice,
 		GpoFullBlockRatio:       config.GpoFullBlockRatio,
 		GpobaseStepDown:         config.GpobaseStepDown,
 		GpobaseStepUp:           config.GpobaseStepUp,
 		GpobaseCorrectionFactor: config.GpobaseCorrectionFactor,
-		httpclient:              httpclient.New(config.DocRoot),
+		datadir:                 ctx.DataDir(),
+	}
+	if eth.httpclient, err = httpclient.NewWithConfig(config.DocRoot, config.HTTPClient); err != nil {
+		return nil, err
+	}
+	for scheme, gateway := range map[string]string{"ipfs": config.IPFSGateway, "bzz": config.BzzGateway} {
+		if gateway == "" {
+			continue
+		}
+		if err := eth.httpclient.RegisterGateway(scheme, gateway); err != nil {
+			return nil, err
+		}
 	}
 
 	// Initialize indexes db if enabled
@@ -240,6 +318,14 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		}
 		return nil, err
 	}
+	if ldb, ok := chainDb.(*ethdb.LDBDatabase); ok && deferCompaction {
+		var idle func() bool
+		if config.CompactionIdle > 0 {
+			idle = func() bool { return eth.blockchain.ImportIdleTime() >= config.CompactionIdle }
+		}
+		ldb.ScheduleCompaction(config.CompactionWindows, idle)
+	}
+	eth.blockchain.SetTxLookupLimit(config.TxLookupLimit)
 	// Configure enabled atxi for blockchain
 	if config.UseAddrTxIndex {
 		eth.blockchain.SetAtxi(&core.AtxiT{
@@ -250,11 +336,66 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	eth.gpo = NewGasPriceOracle(eth)
 
 	newPool := core.NewTxPool(eth.chainConfig, eth.EventMux(), eth.blockchain.State, eth.blockchain.GasLimit)
+	newPool.Reset(eth.blockchain.CurrentBlock())
+	newPool.SetUnprotectedTxPolicy(config.UnprotectedTxs)
+	if config.TxMinGasPrice != nil {
+		newPool.SetMinGasPrice(config.TxMinGasPrice)
+	}
+	ordering, err := core.GetTxOrdering(config.TxOrdering)
+	if err != nil {
+		return nil, err
+	}
+	newPool.SetOrdering(ordering)
+	newPool.SetPrivateLocal(config.PrivateTxs)
 	eth.txPool = newPool
+	eth.nameCache = registrar.NewCache(nameCacheSize)
+	eth.dappStore = dappstore.New(dappDb, config.DappQuota)
+	ethreg.InvalidateCacheOnLogs(eth.nameCache, eth.eventMux)
+	if !config.ENSRegistry.IsEmpty() {
+		eth.ens = registrar.NewENS(ethreg.NewBackend(eth.chainConfig, eth.blockchain, chainDb, newPool, eth.accountManager), config.ENSRegistry)
+	}
+	eth.nonces = newNonceManager(func(addr common.Address) uint64 { return newPool.State().GetNonce(addr) })
+	if len(config.PayoutSplit) > 0 {
+		eth.payouts = newPayoutSplitter(eth.blockchain, chainDb, newPool, eth.accountManager, eth.nonces, eth.gpo.SuggestPrice, eth.eventMux, config.PayoutSplit)
+	}
+	eth.txExpiry = newTxExpirer(eth.blockchain, chainDb, newPool, eth.accountManager, eth.eventMux)
 
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, uint64(config.NetworkId), eth.eventMux, eth.txPool, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
+	var archives downloader.ArchiveSources
+	if config.ArchiveDir != "" {
+		archives = append(archives, downloader.NewDirArchiveSource(config.ArchiveDir))
+	}
+	if len(config.ArchiveMirrors) > 0 {
+		client := eth.httpclient.Client()
+		client.Timeout = downloader.ArchiveDownloadTimeout
+		mirrors, err := downloader.NewHTTPArchiveSource(client, config.ArchiveMirrors, config.ArchiveCheckpoints)
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, mirrors)
+	}
+	if len(archives) > 0 && eth.protocolManager.downloader != nil {
+		eth.protocolManager.downloader.SetArchiveSource(archives)
+	}
+	if config.SyncMinPeers > 0 {
+		eth.protocolManager.syncMinPeers = config.SyncMinPeers
+	}
+	if config.SyncTDMargin != nil {
+		eth.protocolManager.syncTdMargin = new(big.Int).Set(config.SyncTDMargin)
+	}
+	if config.ServeLimits != nil {
+		eth.protocolManager.serveLimits = *config.ServeLimits
+	}
+	if config.ForkAlertDepth > 0 {
+		eth.protocolManager.forks.depth = config.ForkAlertDepth
+	}
+	eth.protocolManager.nodeData = newNodeDataServer(chainDb, config.NodeDataCache, config.NodeDataReaders)
+	eth.protocolManager.privateRelays = make(map[discover.NodeID]bool, len(config.PrivateTxRelays))
+	for _, relay := range config.PrivateTxRelays {
+		eth.protocolManager.privateRelays[relay.ID] = true
+	}
 
 	return eth, nil
 }
@@ -262,6 +403,10 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 // APIs returns the collection of RPC services the ethereum package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *Ethereum) APIs() []rpc.API {
+	filterAPI := filters.NewPublicFilterAPI(s.chainDb, s.eventMux, s.config.Filters)
+	filterAPI.SetTransactionFormatter(func(tx *types.Transaction) interface{} { return newRPCPendingTransaction(tx) })
+	chainAPI := NewPublicBlockChainAPI(s.chainConfig, s.blockchain, s.chainDb, s.gpo, s.eventMux, s.accountManager, s.ens)
+	chainAPI.gasCap, chainAPI.evmTimeout = s.config.RPCGasCap, s.config.RPCEVMTimeout
 	return []rpc.API{
 		{
 			Namespace: "eth",
@@ -281,13 +426,18 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   NewPublicBlockChainAPI(s.chainConfig, s.blockchain, s.chainDb, s.gpo, s.eventMux, s.accountManager),
+			Service:   chainAPI,
 			Public:    true,
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
 			Service:   NewPublicTransactionPoolAPI(s),
 			Public:    true,
+		}, {
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   NewPublicBalanceAPI(s.blockchain, s.chainDb, s.txPool, s.eventMux),
+			Public:    true,
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
@@ -298,11 +448,19 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicTxPoolAPI(s),
 			Public:    true,
+		}, {
+			Namespace: "txpool",
+			Version:   "1.0",
+			Service:   NewPrivateTxPoolAPI(s),
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.chainDb, s.eventMux),
+			Service:   filterAPI,
 			Public:    true,
+		}, {
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   filters.NewPrivateFilterAPI(filterAPI),
 		}, {
 			Namespace: "admin",
 			Version:   "1.0",
@@ -312,6 +470,20 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicDebugAPI(s),
 			Public:    true,
+		}, {
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   NewPrivateDebugAPI(s),
+		}, {
+			Namespace: "token",
+			Version:   "1.0",
+			Service:   NewPublicTokenAPI(chainAPI),
+			Public:    true,
+		}, {
+			Namespace: "trace",
+			Version:   "1.0",
+			Service:   NewPublicTraceAPI(s),
+			Public:    true,
 		}, {
 			Namespace: "net",
 			Version:   "1.0",
@@ -320,12 +492,20 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "admin",
 			Version:   "1.0",
-			Service:   ethreg.NewPrivateRegistarAPI(s.chainConfig, s.blockchain, s.chainDb, s.txPool, s.accountManager),
+			Service:   ethreg.NewPrivateRegistarAPI(s.chainConfig, s.blockchain, s.chainDb, s.txPool, s.accountManager, s.nameCache),
 		}, {
 			Namespace: "geth",
 			Version:   "1.0",
 			Service:   NewPublicGethAPI(s),
 			Public:    true,
+		}, {
+			Namespace: "dapp",
+			Version:   "1.0",
+			Service:   dappstore.NewPrivateDappAPI(s.dappStore),
+		}, {
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   dappstore.NewPrivateDappAdminAPI(s.dappStore),
 		},
 	}
 }
@@ -344,6 +524,7 @@ func (s *Ethereum) IsListening() bool                  { return true } // Always
 func (s *Ethereum) EthVersion() int                    { return int(s.protocolManager.SubProtocols[0].Version) }
 func (s *Ethereum) NetVersion() int                    { return s.netVersionId }
 func (s *Ethereum) ChainConfig() *core.ChainConfig     { return s.chainConfig }
+func (s *Ethereum) GasPriceOracle() *GasPriceOracle    { return s.gpo }
 func (s *Ethereum) Downloader() *downloader.Downloader { return s.protocolManager.downloader }
 
 // Protocols implements node.Service, returning all the currently configured
@@ -356,13 +537,59 @@ func (s *Ethereum) Protocols() []p2p.Protocol {
 // Ethereum protocol implementation.
 func (s *Ethereum) Start(srvr *p2p.Server) error {
 	s.protocolManager.Start(s.config.MaxPeers)
+	if s.payouts != nil {
+		s.payouts.start()
+	}
+	s.txExpiry.start()
 	s.netRPCService = NewPublicNetAPI(srvr, s.NetVersion())
+	s.p2pServer = srvr
+	for _, relay := range s.config.PrivateTxRelays {
+		srvr.AddPeer(relay)
+	}
+	s.unwatchMemory = memwatch.OnPressure(s.relieveMemory)
+	if s.config.MinFreeDisk > 0 {
+		s.storageWatch = newStorageWatchdog(s.blockchain, s.eventMux, s.datadir, s.config.MinFreeDisk)
+		s.storageWatch.start()
+	}
+	s.headWatch = newHeadWatch(s.blockchain, s.config.StaleHead)
+	s.headWatch.start()
 	return nil
 }
 
+// Ready implements node.ReadinessReporter, failing while the head block is
+// older than the configured stale threshold.
+func (s *Ethereum) Ready() error {
+	if s.headWatch == nil {
+		return errors.New("not started")
+	}
+	return s.headWatch.stale()
+}
+
+// relieveMemory drops the caches of the chain and of the state served to peers
+// under memory pressure.
+func (s *Ethereum) relieveMemory(level memwatch.Level) {
+	s.blockchain.PurgeCaches()
+	if nodeData := s.protocolManager.nodeData; nodeData != nil {
+		nodeData.purge()
+	}
+}
+
 // Stop implements node.Service, terminating all internal goroutines used by the
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
+	if s.unwatchMemory != nil {
+		s.unwatchMemory()
+	}
+	if s.storageWatch != nil {
+		s.storageWatch.stop()
+	}
+	if s.headWatch != nil {
+		s.headWatch.stop()
+	}
+	if s.payouts != nil {
+		s.payouts.stop()
+	}
+	s.txExpiry.stop()
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	s.txPool.Stop()
@@ -401,6 +628,33 @@ func (self *Ethereum) SetSolc(solcPath string) (*compiler.Solidity, error) {
 	return self.Solc()
 }
 
+func (self *Ethereum) Vyper() (*compiler.Vyper, error) {
+	var err error
+	if self.vyper == nil {
+		self.vyper, err = compiler.NewVyper(self.VyperPath)
+	}
+	return self.vyper, err
+}
+
+// set in js console via admin interface or wrapper from cli flags
+func (self *Ethereum) SetVyper(vyperPath string) (*compiler.Vyper, error) {
+	self.VyperPath = vyperPath
+	self.vyper = nil
+	return self.Vyper()
+}
+
+// Compilers returns the contract compilers available on the node.
+func (self *Ethereum) Compilers() []compiler.Compiler {
+	var compilers []compiler.Compiler
+	if solc, err := self.Solc(); err == nil && solc != nil {
+		compilers = append(compilers, solc)
+	}
+	if vyper, err := self.Vyper(); err == nil && vyper != nil {
+		compilers = append(compilers, vyper)
+	}
+	return compilers
+}
+
 // dagFiles(epoch) returns the two alternative DAG filenames (not a path)
 // 1) <revision>-<hex(seedhash[8])> 2) full-R<revision>-<hex(seedhash[8])>
 func dagFiles(epoch uint64) (string, string) {
@@ -512,3 +766,87 @@ func addMipmapBloomBins(db ethdb.Database) (err error) {
 	glog.V(logger.Info).Infoln("upgrade completed in", time.Since(tstart))
 	return nil
 }
+
+// compactReceipts rewrites the receipts stored in the earlier full encoding in
+// the compact one, which leaves out the fields derivable from their blocks. The
+// rewrite is idempotent, so an interrupted upgrade resumes on the next start.
+func compactReceipts(db ethdb.Database) error {
+	const receiptsVersion uint = 1
+
+	versionKey := []byte("setting-receipts-version")
+	if data, _ := db.Get(versionKey); len(data) > 0 {
+		var version uint
+		if err := rlp.DecodeBytes(data, &version); err == nil && version == receiptsVersion {
+			return nil
+		}
+	}
+	ldb, ok := db.(*ethdb.LDBDatabase)
+	if !ok {
+		return nil
+	}
+	var (
+		tstart             = time.Now()
+		batch              = db.NewBatch()
+		count, size, saved int
+
+		// Block receipts are keyed receipts-block-<hash>, single ones receipts-<txhash>
+		blockKeyLen = len("receipts-block-") + common.HashLength
+	)
+	glog.V(logger.Info).Infoln("upgrading db receipt storage")
+
+	it := ldb.NewIteratorRange(ethdb.NewBytesPrefix([]byte("receipts-")))
+	for it.Next() {
+		var (
+			enc []byte
+			err error
+		)
+		if len(it.Key()) == blockKeyLen {
+			var receipts []*types.ReceiptForStorage
+			if err = rlp.DecodeBytes(it.Value(), &receipts); err == nil {
+				enc, err = rlp.EncodeToBytes(receipts)
+			}
+		} else {
+			var receipt types.ReceiptForStorage
+			if err = rlp.DecodeBytes(it.Value(), &receipt); err == nil {
+				enc, err = rlp.EncodeToBytes(&receipt)
+			}
+		}
+		if err != nil {
+			glog.V(logger.Warn).Infof("skipping undecodable receipts %x: %v", it.Key(), err)
+			continue
+		}
+		size += len(it.Value())
+		if len(enc) >= len(it.Value()) {
+			continue // Compact already
+		}
+		if err := batch.Put(common.CopyBytes(it.Key()), enc); err != nil {
+			it.Release()
+			return err
+		}
+		count++
+		saved += len(it.Value()) - len(enc)
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				it.Release()
+				return err
+			}
+			batch = db.NewBatch()
+		}
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	version, err := rlp.EncodeToBytes(receiptsVersion)
+	if err != nil {
+		return err
+	}
+	if err := db.Put(versionKey, version); err != nil {
+		return err
+	}
+	glog.V(logger.Info).Infof("upgrade completed in %v: %d receipt entries compacted, %v of %v saved", time.Since(tstart), count, common.StorageSize(saved), common.StorageSize(size))
+	return nil
+}
@@ -0,0 +1,248 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/hexutil"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/state"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/event"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/rpc"
+)
+
+// maxWatchedAddresses limits the number of addresses a single balance subscription can watch.
+const maxWatchedAddresses = 1024
+
+var (
+	errNoWatchedAddresses      = errors.New("no addresses to watch")
+	errTooManyWatchedAddresses = errors.New("too many addresses to watch")
+)
+
+// BalanceChange is the notification sent to balance subscribers when the balance or nonce of a watched
+// address changed. Pending notifications are based on the transaction pool's view of the account and
+// carry no block number or hash.
+type BalanceChange struct {
+	Address     common.Address `json:"address"`
+	Balance     *hexutil.Big   `json:"balance"`
+	Nonce       hexutil.Uint64 `json:"nonce"`
+	BlockNumber *hexutil.Big   `json:"blockNumber,omitempty"`
+	BlockHash   *common.Hash   `json:"blockHash,omitempty"`
+	Pending     bool           `json:"pending"`
+}
+
+// accountSnapshot is the last balance and nonce reported to a subscriber for an address.
+type accountSnapshot struct {
+	balance *big.Int
+	nonce   uint64
+}
+
+func (a accountSnapshot) equal(b accountSnapshot) bool {
+	return a.nonce == b.nonce && a.balance.Cmp(b.balance) == 0
+}
+
+// balanceSubscription tracks the accounts watched by a single subscriber.
+type balanceSubscription struct {
+	sub     rpc.Subscription
+	pending bool
+	head    map[common.Address]accountSnapshot // as of the last head
+	pool    map[common.Address]accountSnapshot // as of the last pending notification
+}
+
+// PublicBalanceAPI offers subscriptions on balance and nonce changes of accounts so clients don't have to
+// poll eth_getBalance and eth_getTransactionCount on every block.
+type PublicBalanceAPI struct {
+	bc      *core.BlockChain
+	chainDb ethdb.Database
+	txPool  *core.TxPool
+	mux     *event.TypeMux
+
+	mu       sync.Mutex
+	lastHead common.Hash
+	subs     map[string]*balanceSubscription
+}
+
+// NewPublicBalanceAPI creates a new balance subscription API.
+func NewPublicBalanceAPI(bc *core.BlockChain, chainDb ethdb.Database, txPool *core.TxPool, mux *event.TypeMux) *PublicBalanceAPI {
+	api := &PublicBalanceAPI{
+		bc:       bc,
+		chainDb:  chainDb,
+		txPool:   txPool,
+		mux:      mux,
+		lastHead: bc.CurrentBlock().Hash(),
+		subs:     make(map[string]*balanceSubscription),
+	}
+	go api.run()
+	return api
+}
+
+// BalanceChanges creates a subscription that notifies when the balance or nonce of one of the given addresses
+// changed as of a new chain head. Only the accounts touched by the new head block are checked, unless the
+// head was reached through a reorganisation in which case all watched accounts are. When includePending is
+// set, changes in the transaction pool's pending view of the accounts are reported as well.
+func (api *PublicBalanceAPI) BalanceChanges(ctx context.Context, addresses []common.Address, includePending bool) (rpc.Subscription, error) {
+	if len(addresses) == 0 {
+		return nil, errNoWatchedAddresses
+	}
+	if len(addresses) > maxWatchedAddresses {
+		return nil, errTooManyWatchedAddresses
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	head := api.bc.CurrentBlock()
+	statedb, err := state.New(head.Root(), state.NewDatabase(api.chainDb))
+	if err != nil {
+		return nil, err
+	}
+
+	subscription, err := notifier.NewSubscription(func(id string) {
+		api.mu.Lock()
+		delete(api.subs, id)
+		api.mu.Unlock()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bs := &balanceSubscription{
+		sub:     subscription,
+		pending: includePending,
+		head:    make(map[common.Address]accountSnapshot, len(addresses)),
+		pool:    make(map[common.Address]accountSnapshot, len(addresses)),
+	}
+	for _, addr := range addresses {
+		snap := accountSnapshot{statedb.GetBalance(addr), statedb.GetNonce(addr)}
+		bs.head[addr] = snap
+		bs.pool[addr] = snap
+	}
+
+	api.mu.Lock()
+	api.subs[subscription.ID()] = bs
+	api.mu.Unlock()
+
+	return subscription, nil
+}
+
+// run dispatches chain head and transaction pool events to the balance subscriptions.
+func (api *PublicBalanceAPI) run() {
+	sub := api.mux.Subscribe(core.ChainHeadEvent{}, core.TxPreEvent{})
+	for event := range sub.Chan() {
+		switch ev := event.Data.(type) {
+		case core.ChainHeadEvent:
+			api.onHead(ev.Block)
+		case core.TxPreEvent:
+			api.onPending(ev.Tx)
+		}
+	}
+}
+
+// onHead notifies subscribers about watched accounts whose balance or nonce differ in the state of the new head.
+func (api *PublicBalanceAPI) onHead(block *types.Block) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	reorg := block.ParentHash() != api.lastHead
+	api.lastHead = block.Hash()
+	if len(api.subs) == 0 {
+		return
+	}
+
+	var touched []common.Address
+	if !reorg {
+		touched = core.TouchedAccounts(block, api.bc.GetReceiptsByHash(block.Hash()))
+	}
+	statedb, err := state.New(block.Root(), state.NewDatabase(api.chainDb))
+	if err != nil {
+		glog.V(logger.Warn).Infof("balance subscriptions: unable to open state of block #%d [%x]: %v", block.NumberU64(), block.Hash().Bytes()[:4], err)
+		return
+	}
+	number, hash := (*hexutil.Big)(block.Number()), block.Hash()
+
+	for id, bs := range api.subs {
+		check := touched
+		if reorg {
+			check = make([]common.Address, 0, len(bs.head))
+			for addr := range bs.head {
+				check = append(check, addr)
+			}
+		}
+		for _, addr := range check {
+			last, watched := bs.head[addr]
+			if !watched {
+				continue
+			}
+			snap := accountSnapshot{statedb.GetBalance(addr), statedb.GetNonce(addr)}
+			if snap.equal(last) {
+				continue
+			}
+			bs.head[addr] = snap
+			bs.pool[addr] = snap
+			change := &BalanceChange{
+				Address:     addr,
+				Balance:     (*hexutil.Big)(snap.balance),
+				Nonce:       hexutil.Uint64(snap.nonce),
+				BlockNumber: number,
+				BlockHash:   &hash,
+			}
+			if bs.sub.Notify(change) == rpc.ErrNotificationNotFound {
+				delete(api.subs, id)
+				break
+			}
+		}
+	}
+}
+
+// onPending notifies pending subscribers about watched accounts involved in a transaction that entered the pool.
+func (api *PublicBalanceAPI) onPending(tx *types.Transaction) {
+	var involved []common.Address
+	if from, err := tx.From(); err == nil {
+		involved = append(involved, from)
+	}
+	if to := tx.To(); to != nil {
+		involved = append(involved, *to)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	if len(api.subs) == 0 {
+		return
+	}
+	pending := api.txPool.State()
+	for id, bs := range api.subs {
+		if !bs.pending {
+			continue
+		}
+		for _, addr := range involved {
+			last, watched := bs.pool[addr]
+			if !watched {
+				continue
+			}
+			snap := accountSnapshot{new(big.Int).Set(pending.GetBalance(addr)), pending.GetNonce(addr)}
+			if snap.equal(last) {
+				continue
+			}
+			bs.pool[addr] = snap
+			change := &BalanceChange{
+				Address: addr,
+				Balance: (*hexutil.Big)(snap.balance),
+				Nonce:   hexutil.Uint64(snap.nonce),
+				Pending: true,
+			}
+			if bs.sub.Notify(change) == rpc.ErrNotificationNotFound {
+				delete(api.subs, id)
+				break
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package eth
 
 import (
+	"context"
 	"math/big"
 
 	"github.com/openether/ethcore/common"
@@ -27,7 +28,7 @@ type ContractBackend struct {
 func NewContractBackend(eth *Ethereum) *ContractBackend {
 	return &ContractBackend{
 		eapi:  NewPublicEthereumAPI(eth),
-		bcapi: NewPublicBlockChainAPI(eth.chainConfig, eth.blockchain, eth.chainDb, eth.gpo, eth.eventMux, eth.accountManager),
+		bcapi: NewPublicBlockChainAPI(eth.chainConfig, eth.blockchain, eth.chainDb, eth.gpo, eth.eventMux, eth.accountManager, eth.ens),
 		txapi: NewPublicTransactionPoolAPI(eth),
 	}
 }
@@ -39,8 +40,8 @@ func (b *ContractBackend) HasCode(contract common.Address, pending bool) (bool,
 	if pending {
 		block = rpc.PendingBlockNumber
 	}
-	out, err := b.bcapi.GetCode(contract, block)
-	return len(common.FromHex(out)) > 0, err
+	out, err := b.bcapi.GetCode(AddressOrName{Address: contract}, block)
+	return len(out) > 0, err
 }
 
 // ContractCall implements bind.ContractCaller executing an Ethereum contract
@@ -57,21 +58,23 @@ func (b *ContractBackend) ContractCall(contract common.Address, data []byte, pen
 		block = rpc.PendingBlockNumber
 	}
 	// Execute the call and convert the output back to Go types
-	out, err := b.bcapi.Call(args, block)
-	return common.FromHex(out), err
+	return b.bcapi.Call(context.Background(), args, block)
 }
 
 // PendingAccountNonce implements bind.ContractTransactor retrieving the current
 // pending nonce associated with an account.
 func (b *ContractBackend) PendingAccountNonce(account common.Address) (uint64, error) {
-	out, err := b.txapi.GetTransactionCount(account, rpc.PendingBlockNumber)
-	return out.Uint64(), err
+	out, err := b.txapi.GetTransactionCount(AddressOrName{Address: account}, rpc.PendingBlockNumber)
+	if out == nil {
+		return 0, err
+	}
+	return uint64(*out), err
 }
 
 // SuggestGasPrice implements bind.ContractTransactor retrieving the currently
 // suggested gas price to allow a timely execution of a transaction.
 func (b *ContractBackend) SuggestGasPrice() (*big.Int, error) {
-	return b.eapi.GasPrice(), nil
+	return b.eapi.GasPrice().ToInt(), nil
 }
 
 // EstimateGasLimit implements bind.ContractTransactor triing to estimate the gas
@@ -80,19 +83,19 @@ func (b *ContractBackend) SuggestGasPrice() (*big.Int, error) {
 // requirement as other transactions may be added or removed by miners, but it
 // should provide a basis for setting a reasonable default.
 func (b *ContractBackend) EstimateGasLimit(sender common.Address, contract *common.Address, value *big.Int, data []byte) (*big.Int, error) {
-	out, err := b.bcapi.EstimateGas(CallArgs{
+	out, err := b.bcapi.EstimateGas(context.Background(), CallArgs{
 		From:  sender,
 		To:    contract,
 		Value: *rpc.NewHexNumber(value),
 		Data:  common.ToHex(data),
 	})
-	return out.BigInt(), err
+	return out.ToInt(), err
 }
 
 // SendTransaction implements bind.ContractTransactor injects the transaction
 // into the pending pool for execution.
 func (b *ContractBackend) SendTransaction(tx *types.Transaction) error {
 	raw, _ := rlp.EncodeToBytes(tx)
-	_, err := b.txapi.SendRawTransaction(common.ToHex(raw))
+	_, err := b.txapi.SendRawTransaction(common.ToHex(raw), nil)
 	return err
 }
@@ -0,0 +1,105 @@
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/types"
+)
+
+const (
+	maxStatsWindow     = 1000 // Maximum and default number of recent canonical blocks the uncle rate is computed over
+	propagationSamples = 256  // Number of recent block arrivals the propagation delay is averaged over
+)
+
+// BlockStats summarises the uncle rate of the canonical chain and how late new
+// blocks arrive from the network.
+type BlockStats struct {
+	Window    uint64  `json:"window"`    // Number of canonical blocks the uncle rate is computed over
+	Uncles    int     `json:"uncles"`    // Uncles included in the window
+	UncleRate float64 `json:"uncleRate"` // Uncles per canonical block
+
+	PropagationSamples int     `json:"propagationSamples"`
+	PropagationDelay   float64 `json:"propagationDelay"`    // Average delay between block timestamp and arrival, in seconds
+	PropagationMax     float64 `json:"propagationDelayMax"` // Maximum delay in the samples, in seconds
+}
+
+// blockStats collects the propagation delays of new blocks received from peers.
+type blockStats struct {
+	lock    sync.Mutex
+	delays  []time.Duration // Ring buffer of propagation delays
+	next    int             // Next slot to overwrite in delays
+	arrived *knownCache     // Blocks whose arrival was already sampled
+}
+
+func newBlockStats() *blockStats {
+	return &blockStats{
+		delays:  make([]time.Duration, 0, propagationSamples),
+		arrived: newKnownCache(propagationSamples, time.Hour),
+	}
+}
+
+// arrival records the propagation delay of a block received from the network,
+// the first time it's seen.
+func (s *blockStats) arrival(header *types.Header, at time.Time) {
+	hash := header.Hash()
+	if s.arrived.Has(hash) {
+		return
+	}
+	s.arrived.Add(hash)
+
+	delay := at.Sub(time.Unix(header.Time.Int64(), 0))
+	if delay < 0 {
+		delay = 0
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.delays) < propagationSamples {
+		s.delays = append(s.delays, delay)
+	} else {
+		s.delays[s.next] = delay
+	}
+	s.next = (s.next + 1) % propagationSamples
+}
+
+// stats computes the statistics against the given chain, with the uncle rate
+// taken over the last window canonical blocks. Only the bodies of the blocks
+// with uncles are read.
+func (s *blockStats) stats(chain *core.BlockChain, window uint64) *BlockStats {
+	head := chain.CurrentBlock().NumberU64()
+	if window > head {
+		window = head
+	}
+	stats := &BlockStats{Window: window}
+	for n := head; n > head-window; n-- {
+		header := chain.GetHeaderByNumber(n)
+		if header == nil || header.UncleHash == types.EmptyUncleHash {
+			continue
+		}
+		if body := chain.GetBody(header.Hash()); body != nil {
+			stats.Uncles += len(body.Uncles)
+		}
+	}
+	if window > 0 {
+		stats.UncleRate = float64(stats.Uncles) / float64(window)
+	}
+
+	s.lock.Lock()
+	delays := append([]time.Duration(nil), s.delays...)
+	s.lock.Unlock()
+
+	stats.PropagationSamples = len(delays)
+	var total time.Duration
+	for _, delay := range delays {
+		total += delay
+		if d := delay.Seconds(); d > stats.PropagationMax {
+			stats.PropagationMax = d
+		}
+	}
+	if len(delays) > 0 {
+		stats.PropagationDelay = (total / time.Duration(len(delays))).Seconds()
+	}
+	return stats
+}
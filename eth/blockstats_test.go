@@ -0,0 +1,72 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+)
+
+// Tests that the uncle rate is computed over the requested window of canonical
+// blocks, capped at the chain length.
+func TestBlockStatsUncles(t *testing.T) {
+	_, chain := newTestBlockChainAPI(10, func(i int, block *core.BlockGen) {
+		// Blocks #3 and #9 include one and two siblings of their parents as uncles
+		uncles := map[int]int{2: 1, 8: 2}[i]
+		for j := 0; j < uncles; j++ {
+			uncle := types.CopyHeader(block.PrevBlock(i - 1).Header())
+			uncle.Extra = []byte{byte(j + 1)}
+			block.AddUncle(uncle)
+		}
+	})
+	tests := []struct {
+		window uint64
+		have   uint64
+		uncles int
+	}{
+		{1, 1, 0},
+		{2, 2, 2},
+		{8, 8, 3},
+		{100, 10, 3},
+	}
+	s := newBlockStats()
+	for _, tt := range tests {
+		stats := s.stats(chain, tt.window)
+		if stats.Window != tt.have || stats.Uncles != tt.uncles {
+			t.Errorf("window %d: have %d blocks, %d uncles, want %d, %d", tt.window, stats.Window, stats.Uncles, tt.have, tt.uncles)
+		}
+		if rate := float64(tt.uncles) / float64(tt.have); stats.UncleRate != rate {
+			t.Errorf("window %d: uncle rate mismatch: have %v, want %v", tt.window, stats.UncleRate, rate)
+		}
+	}
+}
+
+// Tests that the propagation delay is sampled once per block, over the most
+// recent arrivals.
+func TestBlockStatsPropagation(t *testing.T) {
+	_, chain := newTestBlockChainAPI(0, nil)
+	s := newBlockStats()
+
+	header := func(n int64, at time.Time) *types.Header {
+		return &types.Header{Number: big.NewInt(n), Time: big.NewInt(at.Unix())}
+	}
+	now := time.Unix(1500000000, 0)
+	s.arrival(header(1, now), now.Add(2*time.Second))
+	s.arrival(header(1, now), now.Add(10*time.Second)) // Already sampled
+	s.arrival(header(2, now), now.Add(4*time.Second))
+	s.arrival(header(3, now), now.Add(-time.Second)) // Clock skew counts as no delay
+
+	stats := s.stats(chain, 1)
+	if stats.PropagationSamples != 3 || stats.PropagationDelay != 2 || stats.PropagationMax != 4 {
+		t.Errorf("propagation mismatch: have %d samples, %vs average, %vs max, want 3, 2s, 4s", stats.PropagationSamples, stats.PropagationDelay, stats.PropagationMax)
+	}
+	// Only the most recent arrivals are averaged
+	for i := 0; i < propagationSamples; i++ {
+		s.arrival(header(int64(100+i), now), now.Add(time.Second))
+	}
+	if stats := s.stats(chain, 1); stats.PropagationSamples != propagationSamples || stats.PropagationDelay != 1 || stats.PropagationMax != 1 {
+		t.Errorf("propagation mismatch: have %d samples, %vs average, %vs max, want %d, 1s, 1s", stats.PropagationSamples, stats.PropagationDelay, stats.PropagationMax, propagationSamples)
+	}
+}
@@ -0,0 +1,97 @@
+package dappstore
+
+import (
+	"github.com/openether/ethcore/common/hexutil"
+)
+
+// PrivateDappAPI exposes the dapp key-value store. Dapps are trusted to identify
+// themselves with their origin, so the API must only be made available to
+// wallets and browsers which enforce that.
+type PrivateDappAPI struct {
+	store *Store
+}
+
+// NewPrivateDappAPI creates an API serving the given store.
+func NewPrivateDappAPI(store *Store) *PrivateDappAPI {
+	return &PrivateDappAPI{store: store}
+}
+
+// Get returns the value stored under key by origin, or nil if none.
+func (api *PrivateDappAPI) Get(origin, key string) (hexutil.Bytes, error) {
+	value, ok, err := api.store.Get(origin, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetString returns the value stored under key by origin as string, or nil if none.
+func (api *PrivateDappAPI) GetString(origin, key string) (*string, error) {
+	value, ok, err := api.store.Get(origin, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	s := string(value)
+	return &s, nil
+}
+
+// Put stores value under key for origin.
+func (api *PrivateDappAPI) Put(origin, key string, value hexutil.Bytes) (bool, error) {
+	if err := api.store.Put(origin, key, value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PutString stores the string value under key for origin.
+func (api *PrivateDappAPI) PutString(origin, key, value string) (bool, error) {
+	if err := api.store.Put(origin, key, []byte(value)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes the value stored under key by origin.
+func (api *PrivateDappAPI) Delete(origin, key string) (bool, error) {
+	if err := api.store.Delete(origin, key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Keys lists the keys stored by origin.
+func (api *PrivateDappAPI) Keys(origin string) ([]string, error) {
+	return api.store.Keys(origin)
+}
+
+// Clear removes everything stored by origin.
+func (api *PrivateDappAPI) Clear(origin string) (bool, error) {
+	if err := api.store.Clear(origin); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Usage returns the storage used by origin and its quota.
+func (api *PrivateDappAPI) Usage(origin string) (*Usage, error) {
+	return api.store.Usage(origin)
+}
+
+// PrivateDappAdminAPI manages the dapp key-value store. It is served in the admin
+// namespace as dapps must not be able to raise their own quota.
+type PrivateDappAdminAPI struct {
+	store *Store
+}
+
+// NewPrivateDappAdminAPI creates an API managing the given store.
+func NewPrivateDappAdminAPI(store *Store) *PrivateDappAdminAPI {
+	return &PrivateDappAdminAPI{store: store}
+}
+
+// SetDappQuota sets the number of bytes origin can store, 0 for the default.
+func (api *PrivateDappAdminAPI) SetDappQuota(origin string, quota uint64) (bool, error) {
+	if err := api.store.SetQuota(origin, quota); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,280 @@
+// Package dappstore implements a key-value store for dapps, kept in the node's
+// dapp database. Entries are namespaced by the origin of the dapp storing them
+// and each origin can only store up to its quota.
+package dappstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/openether/ethcore/ethdb"
+)
+
+// DefaultQuota is the number of bytes an origin can store unless configured otherwise.
+const DefaultQuota = 5 * 1024 * 1024
+
+const (
+	maxOriginLength = 256
+	maxKeyLength    = 1024
+)
+
+var (
+	entryPrefix = []byte("dapp-kv-")    // entryPrefix + origin + 0 + key -> value
+	indexPrefix = []byte("dapp-index-") // indexPrefix + origin -> index (JSON)
+
+	ErrInvalidOrigin = errors.New("invalid origin")
+	ErrInvalidKey    = errors.New("invalid key")
+)
+
+// QuotaError is returned when a write would exceed the quota of an origin.
+type QuotaError struct {
+	Origin string
+	Used   uint64
+	Quota  uint64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota of %s exceeded: %d of %d bytes used", e.Origin, e.Used, e.Quota)
+}
+
+// index tracks the keys stored by an origin, along with their size.
+type index struct {
+	Sizes map[string]uint64 `json:"sizes"`
+	Quota uint64            `json:"quota,omitempty"` // 0 for the default quota
+}
+
+func (idx *index) used() (used uint64) {
+	for _, size := range idx.Sizes {
+		used += size
+	}
+	return used
+}
+
+// Usage is the storage used by an origin.
+type Usage struct {
+	Keys  int    `json:"keys"`
+	Used  uint64 `json:"used"`
+	Quota uint64 `json:"quota"`
+}
+
+// Store is a key-value store namespaced by origin.
+type Store struct {
+	db    ethdb.Database
+	quota uint64 // default quota of origins
+	lock  sync.Mutex
+}
+
+// New creates a store keeping its entries in db. Origins can store quota bytes
+// (keys and values) by default, DefaultQuota if 0.
+func New(db ethdb.Database, quota uint64) *Store {
+	if quota == 0 {
+		quota = DefaultQuota
+	}
+	return &Store{db: db, quota: quota}
+}
+
+func entryKey(origin, key string) []byte {
+	k := append(append([]byte{}, entryPrefix...), origin...)
+	return append(append(k, 0), key...)
+}
+
+func indexKey(origin string) []byte {
+	return append(append([]byte{}, indexPrefix...), origin...)
+}
+
+// validOrigin reports whether origin can be stored. Origins are separated from
+// keys by a NUL byte in the database, so they can't contain one themselves.
+func validOrigin(origin string) bool {
+	return len(origin) > 0 && len(origin) <= maxOriginLength && strings.IndexByte(origin, 0) < 0
+}
+
+func validate(origin, key string) error {
+	if !validOrigin(origin) {
+		return ErrInvalidOrigin
+	}
+	if len(key) == 0 || len(key) > maxKeyLength {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// readIndex loads the index of an origin. The caller must hold the lock.
+func (s *Store) readIndex(origin string) (*index, error) {
+	idx := &index{Sizes: make(map[string]uint64)}
+	blob, err := s.db.Get(indexKey(origin))
+	if err != nil {
+		return idx, nil // not found
+	}
+	if err := json.Unmarshal(blob, idx); err != nil {
+		return nil, fmt.Errorf("corrupt dapp index of %s: %v", origin, err)
+	}
+	if idx.Sizes == nil {
+		idx.Sizes = make(map[string]uint64)
+	}
+	return idx, nil
+}
+
+// writeIndex stores the index of an origin. The caller must hold the lock.
+func (s *Store) writeIndex(origin string, idx *index) error {
+	if len(idx.Sizes) == 0 && idx.Quota == 0 {
+		return s.db.Delete(indexKey(origin))
+	}
+	blob, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(indexKey(origin), blob)
+}
+
+func (s *Store) quotaOf(idx *index) uint64 {
+	if idx.Quota != 0 {
+		return idx.Quota
+	}
+	return s.quota
+}
+
+// Get returns the value stored under key by origin, and whether it exists.
+func (s *Store) Get(origin, key string) ([]byte, bool, error) {
+	if err := validate(origin, key); err != nil {
+		return nil, false, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idx, err := s.readIndex(origin)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, ok := idx.Sizes[key]; !ok {
+		return nil, false, nil
+	}
+	value, err := s.db.Get(entryKey(origin, key))
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Put stores value under key for origin, failing with a QuotaError if that would
+// exceed the quota of origin.
+func (s *Store) Put(origin, key string, value []byte) error {
+	if err := validate(origin, key); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idx, err := s.readIndex(origin)
+	if err != nil {
+		return err
+	}
+	size := uint64(len(key) + len(value))
+	used := idx.used() - idx.Sizes[key] + size
+	if quota := s.quotaOf(idx); used > quota {
+		return &QuotaError{Origin: origin, Used: idx.used(), Quota: quota}
+	}
+	if err := s.db.Put(entryKey(origin, key), value); err != nil {
+		return err
+	}
+	idx.Sizes[key] = size
+	return s.writeIndex(origin, idx)
+}
+
+// Delete removes the value stored under key by origin, if any.
+func (s *Store) Delete(origin, key string) error {
+	if err := validate(origin, key); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idx, err := s.readIndex(origin)
+	if err != nil {
+		return err
+	}
+	if _, ok := idx.Sizes[key]; !ok {
+		return nil
+	}
+	if err := s.db.Delete(entryKey(origin, key)); err != nil {
+		return err
+	}
+	delete(idx.Sizes, key)
+	return s.writeIndex(origin, idx)
+}
+
+// Keys returns the sorted keys stored by origin.
+func (s *Store) Keys(origin string) ([]string, error) {
+	if !validOrigin(origin) {
+		return nil, ErrInvalidOrigin
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idx, err := s.readIndex(origin)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(idx.Sizes))
+	for key := range idx.Sizes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Clear removes all entries stored by origin. Its quota is kept.
+func (s *Store) Clear(origin string) error {
+	if !validOrigin(origin) {
+		return ErrInvalidOrigin
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idx, err := s.readIndex(origin)
+	if err != nil {
+		return err
+	}
+	for key := range idx.Sizes {
+		if err := s.db.Delete(entryKey(origin, key)); err != nil {
+			return err
+		}
+	}
+	idx.Sizes = make(map[string]uint64)
+	return s.writeIndex(origin, idx)
+}
+
+// Usage returns the storage used by origin.
+func (s *Store) Usage(origin string) (*Usage, error) {
+	if !validOrigin(origin) {
+		return nil, ErrInvalidOrigin
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idx, err := s.readIndex(origin)
+	if err != nil {
+		return nil, err
+	}
+	return &Usage{Keys: len(idx.Sizes), Used: idx.used(), Quota: s.quotaOf(idx)}, nil
+}
+
+// SetQuota sets the quota of origin, 0 resetting it to the default. Entries
+// already stored beyond a lowered quota are kept, but no new ones accepted.
+func (s *Store) SetQuota(origin string, quota uint64) error {
+	if !validOrigin(origin) {
+		return ErrInvalidOrigin
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idx, err := s.readIndex(origin)
+	if err != nil {
+		return err
+	}
+	idx.Quota = quota
+	return s.writeIndex(origin, idx)
+}
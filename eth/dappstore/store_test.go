@@ -0,0 +1,74 @@
+package dappstore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openether/ethcore/ethdb"
+)
+
+func TestStore(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	store := New(db, 16)
+
+	if err := store.Put("https://a.example", "k1", []byte("value1")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if err := store.Put("https://b.example", "k1", []byte("other")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	// Origins are isolated from each other
+	if value, ok, _ := store.Get("https://a.example", "k1"); !ok || string(value) != "value1" {
+		t.Errorf("wrong value: %q (found %v)", value, ok)
+	}
+	if _, ok, _ := store.Get("https://a.example", "k2"); ok {
+		t.Errorf("unexpected value for missing key")
+	}
+	// Writes beyond the quota are refused, overwrites count only once
+	if err := store.Put("https://a.example", "k2", []byte("toolong")); err == nil {
+		t.Errorf("expected quota error")
+	} else if _, ok := err.(*QuotaError); !ok {
+		t.Errorf("wrong error: %v", err)
+	}
+	if err := store.Put("https://a.example", "k1", []byte("value2")); err != nil {
+		t.Errorf("overwrite failed: %v", err)
+	}
+	if usage, _ := store.Usage("https://a.example"); usage.Used != 8 || usage.Keys != 1 || usage.Quota != 16 {
+		t.Errorf("wrong usage: %+v", usage)
+	}
+	if err := store.SetQuota("https://a.example", 32); err != nil {
+		t.Fatalf("set quota failed: %v", err)
+	}
+	if err := store.Put("https://a.example", "k2", []byte("toolong")); err != nil {
+		t.Errorf("put after raising quota failed: %v", err)
+	}
+	if keys, _ := store.Keys("https://a.example"); !reflect.DeepEqual(keys, []string{"k1", "k2"}) {
+		t.Errorf("wrong keys: %v", keys)
+	}
+	if err := store.Delete("https://a.example", "k1"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, ok, _ := store.Get("https://a.example", "k1"); ok {
+		t.Errorf("value found after delete")
+	}
+	// Clearing keeps the quota and other origins
+	if err := store.Clear("https://a.example"); err != nil {
+		t.Fatalf("clear failed: %v", err)
+	}
+	if usage, _ := store.Usage("https://a.example"); usage.Used != 0 || usage.Keys != 0 || usage.Quota != 32 {
+		t.Errorf("wrong usage after clear: %+v", usage)
+	}
+	if _, ok, _ := store.Get("https://b.example", "k1"); !ok {
+		t.Errorf("other origin cleared")
+	}
+	if err := store.Put("", "k", nil); err != ErrInvalidOrigin {
+		t.Errorf("expected invalid origin error, got %v", err)
+	}
+	// Origins can't collide with the keys of other origins
+	if err := store.Put("https://a.example\x00k1", "x", nil); err != ErrInvalidOrigin {
+		t.Errorf("expected invalid origin error for NUL byte, got %v", err)
+	}
+	if err := store.Put("https://a.example", "", nil); err != ErrInvalidKey {
+		t.Errorf("expected invalid key error, got %v", err)
+	}
+}
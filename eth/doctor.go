@@ -0,0 +1,237 @@
+package eth
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/p2p/ntp"
+)
+
+// Outcomes of a single doctor check.
+const (
+	DoctorOK      = "ok"
+	DoctorWarning = "warning"
+	DoctorFailed  = "failed"
+	DoctorSkipped = "skipped"
+)
+
+const (
+	doctorMinDiskFree    = 10 * 1024 * 1024 * 1024 // Free bytes in the datadir below which a warning is reported
+	doctorFailedDiskFree = 1024 * 1024 * 1024      // Free bytes in the datadir below which the check fails
+	doctorMinFdLimit     = 2048                    // File descriptor allowance below which a warning is reported
+	doctorClockDrift     = 10 * time.Second        // Clock drift beyond which a warning is reported
+	doctorNTPChecks      = 3                       // Number of measurements to do against the NTP server
+	doctorReachTimeout   = 3 * time.Second         // Timeout for dialing the node's own listener
+)
+
+// DoctorCheck is the outcome of a single self-check.
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// DoctorReport is the outcome of all self-checks run by Ethereum.Doctor.
+type DoctorReport struct {
+	Time    time.Time      `json:"time"`
+	Healthy bool           `json:"healthy"` // No check failed
+	Checks  []*DoctorCheck `json:"checks"`
+}
+
+// Doctor checks the basics support issues usually boil down to: the versions
+// of the databases, consistency of the stored chain with the genesis and chain
+// configuration, free disk space, file descriptor limits, clock drift and
+// reachability of the p2p listener.
+func (s *Ethereum) Doctor() *DoctorReport {
+	report := &DoctorReport{Time: time.Now(), Healthy: true}
+	for _, check := range []func() *DoctorCheck{
+		s.doctorDatabase,
+		s.doctorGenesis,
+		s.doctorForks,
+		s.doctorDiskSpace,
+		doctorFdLimit,
+		doctorClock,
+		s.doctorListener,
+	} {
+		result := check()
+		if result.Status == DoctorFailed {
+			report.Healthy = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report
+}
+
+// LogDoctorReport logs the outcome of each check of report.
+func LogDoctorReport(report *DoctorReport) {
+	for _, check := range report.Checks {
+		switch check.Status {
+		case DoctorFailed, DoctorWarning:
+			glog.V(logger.Warn).Warnf("Doctor: %s %s: %s", check.Name, check.Status, check.Detail)
+		default:
+			glog.V(logger.Info).Infof("Doctor: %s %s: %s", check.Name, check.Status, check.Detail)
+		}
+	}
+}
+
+func (s *Ethereum) doctorDatabase() *DoctorCheck {
+	check := &DoctorCheck{Name: "database"}
+	version := core.GetBlockChainVersion(s.chainDb)
+	switch {
+	case s.config.SkipBcVersionCheck:
+		check.Status, check.Detail = DoctorSkipped, fmt.Sprintf("version check disabled, stored version %d", version)
+	case version != s.config.BlockChainVersion:
+		check.Status, check.Detail = DoctorFailed, fmt.Sprintf("blockchain version %d, want %d; run geth upgradedb", version, s.config.BlockChainVersion)
+	default:
+		check.Status, check.Detail = DoctorOK, fmt.Sprintf("blockchain version %d", version)
+	}
+	return check
+}
+
+func (s *Ethereum) doctorGenesis() *DoctorCheck {
+	check := &DoctorCheck{Name: "genesis"}
+	stored := core.GetCanonicalHash(s.chainDb, 0)
+	if stored == (common.Hash{}) {
+		check.Status, check.Detail = DoctorFailed, "no genesis block in database"
+		return check
+	}
+	if s.config.Genesis == nil {
+		check.Status, check.Detail = DoctorOK, fmt.Sprintf("stored genesis %x, none configured", stored)
+		return check
+	}
+	// The genesis hash covers its state root, so build it aside to compare
+	db, _ := ethdb.NewMemDatabase()
+	genesis, err := core.WriteGenesisBlock(db, s.config.Genesis)
+	if err != nil {
+		check.Status, check.Detail = DoctorFailed, fmt.Sprintf("invalid configured genesis: %v", err)
+		return check
+	}
+	if genesis.Hash() != stored {
+		check.Status, check.Detail = DoctorFailed, fmt.Sprintf("stored genesis %x doesn't match configured genesis %x", stored, genesis.Hash())
+		return check
+	}
+	check.Status, check.Detail = DoctorOK, fmt.Sprintf("genesis %x matches configuration", stored)
+	return check
+}
+
+func (s *Ethereum) doctorForks() *DoctorCheck {
+	check := &DoctorCheck{Name: "forks"}
+	head := s.blockchain.CurrentHeader().Number.Uint64()
+
+	checked := 0
+	for _, fork := range s.chainConfig.Forks {
+		if fork.RequiredHash.IsEmpty() || fork.Block == nil || fork.Block.Uint64() > head {
+			continue
+		}
+		checked++
+		if hash := core.GetCanonicalHash(s.chainDb, fork.Block.Uint64()); hash != fork.RequiredHash {
+			check.Status = DoctorFailed
+			check.Detail = fmt.Sprintf("block #%d is %x, fork %q requires %x", fork.Block, hash, fork.Name, fork.RequiredHash)
+			return check
+		}
+	}
+	check.Status, check.Detail = DoctorOK, fmt.Sprintf("%d fork hashes match the chain configuration", checked)
+	return check
+}
+
+func (s *Ethereum) doctorDiskSpace() *DoctorCheck {
+	check := &DoctorCheck{Name: "disk"}
+	if s.datadir == "" {
+		check.Status, check.Detail = DoctorSkipped, "ephemeral node"
+		return check
+	}
+	free, err := diskFree(s.datadir)
+	if err != nil {
+		check.Status, check.Detail = DoctorSkipped, err.Error()
+		return check
+	}
+	check.Detail = fmt.Sprintf("%s free in %s", common.StorageSize(free), s.datadir)
+	switch {
+	case free < doctorFailedDiskFree:
+		check.Status = DoctorFailed
+	case free < doctorMinDiskFree:
+		check.Status = DoctorWarning
+	default:
+		check.Status = DoctorOK
+	}
+	return check
+}
+
+func doctorFdLimit() *DoctorCheck {
+	check := &DoctorCheck{Name: "fdlimit"}
+	limit, err := fdLimit()
+	if err != nil {
+		check.Status, check.Detail = DoctorSkipped, err.Error()
+		return check
+	}
+	check.Detail = fmt.Sprintf("%d file descriptors allowed", limit)
+	if limit < doctorMinFdLimit {
+		check.Status = DoctorWarning
+		check.Detail += fmt.Sprintf(", at least %d recommended", doctorMinFdLimit)
+	} else {
+		check.Status = DoctorOK
+	}
+	return check
+}
+
+func doctorClock() *DoctorCheck {
+	check := &DoctorCheck{Name: "clock"}
+	drift, err := ntp.Drift(ntp.DefaultServer, doctorNTPChecks)
+	if err != nil {
+		check.Status, check.Detail = DoctorSkipped, fmt.Sprintf("NTP query failed: %v", err)
+		return check
+	}
+	check.Detail = fmt.Sprintf("local clock off by %v", drift)
+	if drift < -doctorClockDrift || drift > doctorClockDrift {
+		check.Status = DoctorWarning
+	} else {
+		check.Status = DoctorOK
+	}
+	return check
+}
+
+func (s *Ethereum) doctorListener() *DoctorCheck {
+	check := &DoctorCheck{Name: "listener"}
+	if s.p2pServer == nil || s.p2pServer.ListenAddr == "" {
+		check.Status, check.Detail = DoctorSkipped, "not listening for peers"
+		return check
+	}
+	_, port, err := net.SplitHostPort(s.p2pServer.ListenAddr)
+	if err != nil {
+		check.Status, check.Detail = DoctorSkipped, err.Error()
+		return check
+	}
+	if err := dialCheck(net.JoinHostPort("127.0.0.1", port)); err != nil {
+		check.Status, check.Detail = DoctorFailed, fmt.Sprintf("listener on port %s unreachable locally: %v", port, err)
+		return check
+	}
+	// Try the advertised endpoint as well, which may be behind a NAT
+	self := s.p2pServer.Self()
+	if self == nil || self.IP == nil || self.IP.IsLoopback() || self.IP.IsUnspecified() {
+		check.Status, check.Detail = DoctorOK, fmt.Sprintf("listening on port %s, no external address known", port)
+		return check
+	}
+	external := net.JoinHostPort(self.IP.String(), strconv.Itoa(int(self.TCP)))
+	if err := dialCheck(external); err != nil {
+		check.Status, check.Detail = DoctorWarning, fmt.Sprintf("advertised endpoint %s unreachable: %v", external, err)
+		return check
+	}
+	check.Status, check.Detail = DoctorOK, fmt.Sprintf("advertised endpoint %s reachable", external)
+	return check
+}
+
+// dialCheck reports whether a TCP connection to addr can be established.
+func dialCheck(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, doctorReachTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,109 @@
+package eth
+
+import (
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/ethdb"
+	"github.com/ethereumclassic/go-ethereum/p2p"
+)
+
+// newDoctorTester creates a node over a test chain of a few blocks, skipping
+// the checks which require anything beyond the chain.
+func newDoctorTester() *Ethereum {
+	api, chain := newTestBlockChainAPI(3, nil)
+	core.WriteBlockChainVersion(api.chainDb, 3)
+
+	return &Ethereum{
+		config:      &Config{BlockChainVersion: 3},
+		chainConfig: chain.Config(),
+		chainDb:     api.chainDb,
+		blockchain:  chain,
+	}
+}
+
+func checkDoctor(t *testing.T, check *DoctorCheck, name, status string) {
+	if check.Name != name || check.Status != status {
+		t.Errorf("check mismatch: have %s %s (%s), want %s %s", check.Name, check.Status, check.Detail, name, status)
+	}
+}
+
+func TestDoctorDatabase(t *testing.T) {
+	eth := newDoctorTester()
+	checkDoctor(t, eth.doctorDatabase(), "database", DoctorOK)
+
+	eth.config.BlockChainVersion = 4
+	checkDoctor(t, eth.doctorDatabase(), "database", DoctorFailed)
+
+	eth.config.SkipBcVersionCheck = true
+	checkDoctor(t, eth.doctorDatabase(), "database", DoctorSkipped)
+}
+
+func TestDoctorGenesis(t *testing.T) {
+	eth := newDoctorTester()
+	checkDoctor(t, eth.doctorGenesis(), "genesis", DoctorOK)
+
+	// The test genesis differs from the Morden one
+	eth.config.Genesis = core.DefaultConfigMorden.Genesis
+	checkDoctor(t, eth.doctorGenesis(), "genesis", DoctorFailed)
+
+	// A database seeded with the configured genesis matches
+	eth.chainDb, _ = ethdb.NewMemDatabase()
+	if _, err := core.WriteGenesisBlock(eth.chainDb, core.DefaultConfigMorden.Genesis); err != nil {
+		t.Fatalf("failed to write genesis: %v", err)
+	}
+	checkDoctor(t, eth.doctorGenesis(), "genesis", DoctorOK)
+}
+
+func TestDoctorForks(t *testing.T) {
+	eth := newDoctorTester()
+	block := eth.blockchain.GetBlockByNumber(2)
+
+	// Only the forks up to the head are checked against their required hash
+	config := *eth.chainConfig
+	config.Forks = []*core.Fork{
+		{Name: "match", Block: big.NewInt(2), RequiredHash: block.Hash()},
+		{Name: "future", Block: big.NewInt(10), RequiredHash: common.Hash{0x01}},
+	}
+	eth.chainConfig = &config
+	checkDoctor(t, eth.doctorForks(), "forks", DoctorOK)
+
+	config.Forks = append(config.Forks, &core.Fork{Name: "mismatch", Block: big.NewInt(1), RequiredHash: block.Hash()})
+	checkDoctor(t, eth.doctorForks(), "forks", DoctorFailed)
+}
+
+func TestDoctorDiskSpace(t *testing.T) {
+	eth := newDoctorTester()
+	checkDoctor(t, eth.doctorDiskSpace(), "disk", DoctorSkipped)
+
+	dir, err := ioutil.TempDir("", "doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	eth.datadir = dir
+	if check := eth.doctorDiskSpace(); check.Status == DoctorSkipped {
+		t.Errorf("disk space of the datadir not checked: %s", check.Detail)
+	}
+}
+
+func TestDoctorListener(t *testing.T) {
+	eth := newDoctorTester()
+	checkDoctor(t, eth.doctorListener(), "listener", DoctorSkipped)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth.p2pServer = &p2p.Server{Config: p2p.Config{ListenAddr: listener.Addr().String()}}
+	checkDoctor(t, eth.doctorListener(), "listener", DoctorOK)
+
+	listener.Close()
+	checkDoctor(t, eth.doctorListener(), "listener", DoctorFailed)
+}
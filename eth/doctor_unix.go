@@ -0,0 +1,44 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || solaris
+// +build linux darwin freebsd netbsd openbsd solaris
+
+package eth
+
+import (
+	"os"
+	"syscall"
+)
+
+// diskFree returns the number of bytes available to unprivileged users on the
+// file system holding path.
+func diskFree(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+// fdLimit retrieves the number of file descriptors allowed to be opened by this
+// process.
+func fdLimit() (uint64, error) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, err
+	}
+	return uint64(limit.Cur), nil
+}
+
+// openFds returns the number of file descriptors opened by this process.
+func openFds() (uint64, error) {
+	dir, err := os.Open("/dev/fd")
+	if err != nil {
+		return 0, err
+	}
+	defer dir.Close()
+
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(len(names) - 1), nil // Don't count the descriptor reading the list
+}
@@ -0,0 +1,21 @@
+package eth
+
+import "errors"
+
+var errDoctorUnsupported = errors.New("not supported on windows")
+
+// diskFree is not implemented on windows.
+func diskFree(path string) (uint64, error) {
+	return 0, errDoctorUnsupported
+}
+
+// fdLimit is not implemented on windows, where the database handle allowance is
+// fixed.
+func fdLimit() (uint64, error) {
+	return 0, errDoctorUnsupported
+}
+
+// openFds is not implemented on windows.
+func openFds() (uint64, error) {
+	return 0, errDoctorUnsupported
+}
@@ -0,0 +1,235 @@
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+// ArchiveSource provides block archives, see core.BlockArchive, to seed the
+// chain with before syncing from peers.
+type ArchiveSource interface {
+	// Archive returns the verified archive starting at the given block, nil if
+	// the source doesn't have it.
+	Archive(first uint64) (*core.BlockArchive, error)
+}
+
+// DirArchiveSource provides the archives of a local directory. The directory
+// is listed once, when the first archive is requested.
+type DirArchiveSource struct {
+	dir      string
+	list     sync.Once
+	archives map[uint64]string
+	err      error
+}
+
+// NewDirArchiveSource creates an archive source reading from the given directory.
+func NewDirArchiveSource(dir string) *DirArchiveSource {
+	return &DirArchiveSource{dir: dir}
+}
+
+// Archive implements ArchiveSource.
+func (s *DirArchiveSource) Archive(first uint64) (*core.BlockArchive, error) {
+	s.list.Do(func() {
+		s.archives, s.err = core.ListArchives(s.dir)
+	})
+	if s.err != nil {
+		return nil, s.err
+	}
+	path, ok := s.archives[first]
+	if !ok {
+		return nil, nil
+	}
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return core.ReadArchive(fh)
+}
+
+const (
+	ArchiveDownloadTimeout = 30 * time.Minute       // Time limit for downloading an archive from a mirror
+	maxArchiveSize         = 4 * 1024 * 1024 * 1024 // Maximum size of an archive downloaded from a mirror
+)
+
+// ArchiveSources provides the archives of the first of several sources having them.
+type ArchiveSources []ArchiveSource
+
+// Archive implements ArchiveSource.
+func (sources ArchiveSources) Archive(first uint64) (*core.BlockArchive, error) {
+	for _, source := range sources {
+		if archive, err := source.Archive(first); err != nil || archive != nil {
+			return archive, err
+		}
+	}
+	return nil, nil
+}
+
+// HTTPArchiveSource downloads archives from HTTPS mirrors. Only the archives
+// ending with a checkpointed block hash are downloaded, and an archive is only
+// accepted if it verifies against its checkpoint, so the mirrors need not be
+// trusted.
+type HTTPArchiveSource struct {
+	client      *http.Client
+	mirrors     []string
+	checkpoints map[uint64]common.Hash // Hashes of the last blocks of archives, by first block
+}
+
+// NewHTTPArchiveSource creates an archive source downloading from the given
+// mirror base URLs, which must be HTTPS.
+func NewHTTPArchiveSource(client *http.Client, mirrors []string, checkpoints map[uint64]common.Hash) (*HTTPArchiveSource, error) {
+	for _, mirror := range mirrors {
+		u, err := url.Parse(mirror)
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive mirror %q: %v", mirror, err)
+		}
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("archive mirror %q is not HTTPS", mirror)
+		}
+	}
+	for first := range checkpoints {
+		if first%core.ArchiveBlocks != 0 {
+			return nil, fmt.Errorf("archive checkpoint #%d not a multiple of %d", first, core.ArchiveBlocks)
+		}
+	}
+	return &HTTPArchiveSource{client: client, mirrors: mirrors, checkpoints: checkpoints}, nil
+}
+
+// Archive implements ArchiveSource. The mirrors are tried in turn, starting
+// with a different one for every archive to spread the load.
+func (s *HTTPArchiveSource) Archive(first uint64) (*core.BlockArchive, error) {
+	last, ok := s.checkpoints[first]
+	if !ok || len(s.mirrors) == 0 {
+		return nil, nil
+	}
+	var err error
+	for i := range s.mirrors {
+		mirror := s.mirrors[(int(first/core.ArchiveBlocks)+i)%len(s.mirrors)]
+		uri := strings.TrimSuffix(mirror, "/") + "/" + core.ArchiveName(first, last)
+
+		var archive *core.BlockArchive
+		if archive, err = s.download(uri); err != nil {
+			glog.V(logger.Debug).Infof("Archive download from %s failed: %v", uri, err)
+			continue
+		}
+		if archive.First != first || archive.Last != last {
+			err = fmt.Errorf("archive from %s ends with %x, want %x", uri, archive.Last, last)
+			glog.V(logger.Warn).Warnln(err)
+			continue
+		}
+		return archive, nil
+	}
+	return nil, err
+}
+
+// download retrieves and verifies an archive.
+func (s *HTTPArchiveSource) download(uri string) (*core.BlockArchive, error) {
+	resp, err := s.client.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP status %s", resp.Status)
+	}
+	return core.ReadArchive(io.LimitReader(resp.Body, maxArchiveSize))
+}
+
+// LoadArchiveCheckpoints reads a JSON file of archive checkpoints, an object
+// mapping the decimal first block numbers of archives to the hashes of their
+// last blocks.
+func LoadArchiveCheckpoints(path string) (map[uint64]common.Hash, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]common.Hash
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	checkpoints := make(map[uint64]common.Hash, len(raw))
+	for key, hash := range raw {
+		first, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive checkpoint block number %q", key)
+		}
+		checkpoints[first] = hash
+	}
+	return checkpoints, nil
+}
+
+// SetArchiveSource makes fast sync import the archives of the given source
+// before downloading the rest of the chain from peers.
+func (d *Downloader) SetArchiveSource(source ArchiveSource) {
+	d.archives = source
+}
+
+// importArchives imports the archives following the head fast block until the
+// source runs out of them. Failures are logged and leave the rest of the chain
+// to be synced from peers.
+func (d *Downloader) importArchives() {
+	if d.archives == nil || d.mode != FastSync || atomic.LoadInt32(&d.archivesDone) == 1 {
+		return
+	}
+	for {
+		select {
+		case <-d.cancelCh:
+			return
+		default:
+		}
+		head := d.blockchain.CurrentFastBlock().NumberU64()
+		first := (head + 1) - (head+1)%core.ArchiveBlocks
+
+		archive, err := d.archives.Archive(first)
+		if err != nil {
+			glog.V(logger.Warn).Warnf("Failed to retrieve archive of blocks #%d-#%d: %v", first, first+core.ArchiveBlocks-1, err)
+			return
+		}
+		if archive == nil {
+			glog.V(logger.Info).Infof("No archive of blocks #%d-#%d, syncing from peers", first, first+core.ArchiveBlocks-1)
+			atomic.StoreInt32(&d.archivesDone, 1)
+			return
+		}
+		if err := d.insertArchive(archive, head+1-first); err != nil {
+			glog.V(logger.Warn).Warnf("Failed to import archive of blocks #%d-#%d: %v", first, first+core.ArchiveBlocks-1, err)
+			atomic.StoreInt32(&d.archivesDone, 1)
+			return
+		}
+		glog.V(logger.Info).Infof("Imported archive of blocks #%d-#%d", first, first+core.ArchiveBlocks-1)
+	}
+}
+
+// insertArchive imports the headers, blocks and receipts of an archive, skipping
+// the given number of blocks already in the chain.
+func (d *Downloader) insertArchive(archive *core.BlockArchive, skip uint64) error {
+	blocks, receipts := archive.Blocks[skip:], archive.Receipts[skip:]
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if res := d.lightchain.InsertHeaderChain(headers, fsHeaderCheckFrequency); res.Error != nil {
+		return res.Error
+	}
+	if res := d.blockchain.InsertReceiptChain(blocks, receipts); res.Error != nil {
+		return res.Error
+	}
+	return nil
+}
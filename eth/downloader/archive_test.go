@@ -0,0 +1,110 @@
+package downloader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/ethdb"
+)
+
+// writeTestArchive writes an archive of the first blocks of a generated chain,
+// returning it with the hash of its last block.
+func writeTestArchive(t *testing.T) ([]byte, common.Hash) {
+	db, _ := ethdb.NewMemDatabase()
+	var parent common.Hash
+	for i := 0; i < core.ArchiveBlocks; i++ {
+		header := &types.Header{ParentHash: parent, Number: big.NewInt(int64(i)), Difficulty: big.NewInt(1), GasLimit: big.NewInt(5000), GasUsed: new(big.Int), Time: big.NewInt(int64(i))}
+		block := types.NewBlock(header, nil, nil, nil)
+		core.WriteBlock(db, block)
+		core.WriteBlockReceipts(db, block.Hash(), nil)
+		core.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		parent = block.Hash()
+	}
+	var archive bytes.Buffer
+	last, err := core.WriteArchive(db, &archive, 0)
+	if err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	return archive.Bytes(), last
+}
+
+// Tests that archives are only downloaded from mirrors if checkpointed, and only
+// accepted if they match their checkpoint.
+func TestHTTPArchiveSource(t *testing.T) {
+	archive, last := writeTestArchive(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/archives/"+core.ArchiveName(0, last) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	if _, err := NewHTTPArchiveSource(server.Client(), []string{"http://example.com"}, nil); err == nil {
+		t.Errorf("plain HTTP mirror accepted")
+	}
+	tests := []struct {
+		checkpoints map[uint64]common.Hash
+		found       bool
+		fails       bool
+	}{
+		{map[uint64]common.Hash{0: last}, true, false},
+		{map[uint64]common.Hash{core.ArchiveBlocks: last}, false, false},
+		{map[uint64]common.Hash{0: {0x01}}, false, true},
+	}
+	for i, tt := range tests {
+		source, err := NewHTTPArchiveSource(server.Client(), []string{server.URL + "/archives/"}, tt.checkpoints)
+		if err != nil {
+			t.Fatalf("test %d: failed to create source: %v", i, err)
+		}
+		archive, err := source.Archive(0)
+		if (err != nil) != tt.fails {
+			t.Errorf("test %d: error mismatch: have %v, want failure %v", i, err, tt.fails)
+		}
+		if (archive != nil) != tt.found {
+			t.Errorf("test %d: archive found %v, want %v", i, archive != nil, tt.found)
+		}
+		if archive != nil && archive.Last != last {
+			t.Errorf("test %d: archive last hash mismatch: have %x, want %x", i, archive.Last, last)
+		}
+	}
+}
+
+// Tests that the archives of a directory are found by first block, listing the
+// directory only once.
+func TestDirArchiveSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archives")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	archive, last := writeTestArchive(t)
+	if err := ioutil.WriteFile(filepath.Join(dir, core.ArchiveName(0, last)), archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+	source := NewDirArchiveSource(dir)
+	if archive, err := source.Archive(0); err != nil || archive == nil || archive.Last != last {
+		t.Fatalf("archive not found: %v %v", archive, err)
+	}
+	if archive, err := source.Archive(core.ArchiveBlocks); err != nil || archive != nil {
+		t.Fatalf("missing archive found: %v %v", archive, err)
+	}
+	// Archives added after the listing aren't seen
+	if err := ioutil.WriteFile(filepath.Join(dir, core.ArchiveName(core.ArchiveBlocks, last)), archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if archive, err := source.Archive(core.ArchiveBlocks); err != nil || archive != nil {
+		t.Fatalf("directory listed again: %v %v", archive, err)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/openether/ethcore/core/types"
 	"github.com/openether/ethcore/ethdb"
 	"github.com/openether/ethcore/event"
+	"github.com/openether/ethcore/internal/tracing"
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
 	"github.com/openether/ethcore/metrics"
@@ -139,6 +140,9 @@ type Downloader struct {
 	lightchain LightChain
 	blockchain BlockChain
 
+	archives     ArchiveSource // Source of block archives imported ahead of fast sync, if any
+	archivesDone int32         // Flag whether the archive source ran out (atomic)
+
 	// Callbacks
 	dropPeer peerDropFn // Drops a peer for misbehaving
 
@@ -146,6 +150,7 @@ type Downloader struct {
 	synchroniseMock func(id string, hash common.Hash) error // Replacement for synchronise during testing
 	synchronising   int32
 	committed       int32
+	fetchSpan       atomic.Value // *tracing.Span of the fetch stage of the sync in progress, parent of the imports
 
 	// Channels
 	headerCh      chan dataPack        // [eth/62] Channel receiving inbound block headers
@@ -451,6 +456,9 @@ func (d *Downloader) synchronise(id string, hash common.Hash, td *big.Int, mode
 	if p == nil {
 		return errUnknownPeer
 	}
+	// Seed the chain from archives first if available
+	d.importArchives()
+
 	return d.syncWithPeer(p, hash, td)
 }
 
@@ -500,19 +508,34 @@ func (d *Downloader) syncWithPeer(p *peer, hash common.Hash, td *big.Int) (err e
 		}
 	}(time.Now())
 
+	span := tracing.Start("downloader.sync")
+	span.SetAttribute("peer", p.id)
+	span.SetAttribute("mode", d.mode.String())
+	span.SetAttribute("td", td)
+	defer func() {
+		span.SetAttribute("origin", d.syncStatsChainOrigin)
+		span.SetAttribute("height", d.syncStatsChainHeight)
+		span.SetAttribute("pivot", pivot)
+		span.End(err)
+	}()
+
 	if p.version < 62 {
 		glog.V(logger.Debug).Warnf("download: peer %q protocol %d too old", p.id, p.version)
 		return errTooOld
 	}
 
 	// Look up the sync boundaries: the common ancestor and the target block
+	stage := span.Child("downloader.fetchHeight")
 	latest, err := d.fetchHeight(p)
+	stage.End(err)
 	if err != nil {
 		return err
 	}
 	height := latest.Number.Uint64()
 
+	stage = span.Child("downloader.findAncestor")
 	origin, err := d.findAncestor(p, height)
+	stage.End(err)
 	if err != nil {
 		return err
 	}
@@ -555,7 +578,31 @@ func (d *Downloader) syncWithPeer(p *peer, hash common.Hash, td *big.Int) (err e
 	} else if d.mode == FullSync {
 		fetchers = append(fetchers, d.processFullSyncContent)
 	}
-	return d.spawnSync(fetchers)
+	stage = span.Child("downloader.fetch")
+	d.fetchSpan.Store(stage)
+	err = d.spawnSync(fetchers)
+	stage.End(err)
+	return err
+}
+
+// traceImport begins the span of the import of the given blocks, nested in the
+// fetch stage of the sync. The blocks are bound to it, so that the stages of
+// their import in the chain nest in it too.
+func (d *Downloader) traceImport(name string, headers []*types.Header) *tracing.Span {
+	parent, _ := d.fetchSpan.Load().(*tracing.Span)
+	span := parent.Child(name)
+	if span == nil || len(headers) == 0 {
+		return span
+	}
+	span.SetAttribute("first", headers[0].Number.Uint64())
+	span.SetAttribute("items", len(headers))
+
+	hashes := make([]interface{}, len(headers))
+	for i, header := range headers {
+		hashes[i] = header.Hash()
+	}
+	span.Bind(hashes...)
+	return span
 }
 
 // spawnSync runs d.process and all given fetcher functions to completion in
@@ -1338,7 +1385,9 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 					if chunk[len(chunk)-1].Number.Uint64()+uint64(fsHeaderForceVerify) > pivot {
 						frequency = 1
 					}
+					span := d.traceImport("downloader.importHeaders", chunk)
 					res := d.lightchain.InsertHeaderChain(chunk, frequency)
+					span.End(res.Error)
 					// TODO(whilei): again, send error to events
 					if res.Error != nil {
 						// If some headers were inserted, add them too to the rollback list
@@ -1427,11 +1476,15 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 		"lastnum", last.Number, "lasthash", last.Hash().Hex()[:9],
 	)
 	blocks := make([]*types.Block, len(results))
+	headers := make([]*types.Header, len(results))
 	for i, result := range results {
 		blocks[i] = types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles)
+		headers[i] = result.Header
 	}
 
+	span := d.traceImport("downloader.importBlocks", headers)
 	res := d.blockchain.InsertChain(blocks)
+	span.End(res.Error)
 	if res.Error != nil {
 		glog.V(logger.Debug).Infoln("Downloaded item processing failed", "number", results[res.Index].Header.Number, "hash", results[res.Index].Header.Hash(), "err", res.Error)
 		return errInvalidChain
@@ -1571,12 +1624,16 @@ func (d *Downloader) commitFastSyncData(results []*fetchResult, stateSync *state
 		"lastnumn", last.Number, "lasthash", last.Hash().Hex(),
 	)
 	blocks := make([]*types.Block, len(results))
+	headers := make([]*types.Header, len(results))
 	receipts := make([]types.Receipts, len(results))
 	for i, result := range results {
 		blocks[i] = types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles)
+		headers[i] = result.Header
 		receipts[i] = result.Receipts
 	}
+	span := d.traceImport("downloader.importReceipts", headers)
 	res := d.blockchain.InsertReceiptChain(blocks, receipts)
+	span.End(res.Error)
 	if res.Error != nil {
 		glog.V(logger.Debug).Infoln("Downloaded item processing failed", "number", results[res.Index].Header.Number, "hash", results[res.Index].Header.Hash(), "err", res.Error)
 		return errInvalidChain
@@ -1589,7 +1646,9 @@ func (d *Downloader) commitFastSyncData(results []*fetchResult, stateSync *state
 func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 	block := types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles)
 	glog.V(logger.Debug).Infoln("Committing fast sync pivot as new head", "number", block.Number(), "hash", block.Hash())
+	span := d.traceImport("downloader.commitPivot", []*types.Header{result.Header})
 	res := d.blockchain.InsertReceiptChain([]*types.Block{block}, []types.Receipts{result.Receipts})
+	span.End(res.Error)
 	if res.Error != nil {
 		return res.Error
 	}
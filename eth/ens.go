@@ -0,0 +1,168 @@
+package eth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/registrar"
+	"github.com/openether/ethcore/rpc"
+)
+
+var errNoENSRegistry = errors.New("ENS names not supported: no ENS registry configured (see --ens-registry)")
+
+// AddressOrName is an RPC argument accepting either a hex encoded address or an
+// ENS name, which is resolved through the configured ENS registry.
+type AddressOrName struct {
+	Address common.Address
+	Name    string // ENS name, if not given an address
+}
+
+// UnmarshalJSON decodes an address or an ENS name.
+func (a *AddressOrName) UnmarshalJSON(input []byte) error {
+	var s string
+	if err := json.Unmarshal(input, &s); err != nil {
+		return err
+	}
+	switch {
+	case registrar.IsName(s):
+		a.Address, a.Name = common.Address{}, s
+	case common.IsHexAddress(s):
+		a.Address, a.Name = common.HexToAddress(s), ""
+	default:
+		return fmt.Errorf("invalid address or ENS name %q", s)
+	}
+	return nil
+}
+
+// resolveName resolves an ENS name using the configured registry.
+func resolveName(ens *registrar.ENS, name string) (common.Address, error) {
+	if ens == nil {
+		return common.Address{}, errNoENSRegistry
+	}
+	return ens.Resolve(name)
+}
+
+// resolveAddress returns the address given as argument, resolving it first if an
+// ENS name was given.
+func resolveAddress(ens *registrar.ENS, arg AddressOrName) (common.Address, error) {
+	if arg.Name == "" {
+		return arg.Address, nil
+	}
+	return resolveName(ens, arg.Name)
+}
+
+// ensNames holds the ENS names given instead of the sender and recipient
+// addresses of call or transaction arguments, until they're resolved.
+type ensNames struct {
+	from, to string
+}
+
+// set stores the decoded sender and recipient into the given address fields,
+// remembering any names to be resolved.
+func (n *ensNames) set(from, to *AddressOrName, fromAddr *common.Address, toAddr **common.Address) {
+	if from != nil {
+		*fromAddr, n.from = from.Address, from.Name
+	}
+	if to != nil {
+		*toAddr, n.to = &to.Address, to.Name
+	}
+}
+
+// resolve resolves the remembered names into the given address fields.
+func (n *ensNames) resolve(ens *registrar.ENS, fromAddr *common.Address, toAddr **common.Address) error {
+	if n.from != "" {
+		from, err := resolveName(ens, n.from)
+		if err != nil {
+			return err
+		}
+		*fromAddr = from
+	}
+	if n.to != "" {
+		to, err := resolveName(ens, n.to)
+		if err != nil {
+			return err
+		}
+		*toAddr = &to
+	}
+	return nil
+}
+
+// txObjectFields are the fields of the transaction objects sent by other clients
+// which the call and transaction arguments accept beside their own.
+type txObjectFields struct {
+	Input   *string        `json:"input"`   // Alias of data
+	ChainId *rpc.HexNumber `json:"chainId"` // Ignored, transactions are signed for the chain of the node
+	Type    *rpc.HexNumber `json:"type"`    // Only legacy transactions are supported
+}
+
+// data merges the input field into the data given, and checks the type.
+func (f *txObjectFields) data(data string) (string, error) {
+	if f.Type != nil && f.Type.BigInt().Sign() != 0 {
+		return "", fmt.Errorf("unsupported transaction type %v", f.Type.BigInt())
+	}
+	if f.Input == nil {
+		return data, nil
+	}
+	if data != "" && !strings.EqualFold(data, *f.Input) {
+		return "", errors.New(`both "data" and "input" are set and not equal`)
+	}
+	return *f.Input, nil
+}
+
+// UnmarshalJSON decodes call arguments, accepting ENS names for addresses.
+func (args *CallArgs) UnmarshalJSON(input []byte) error {
+	type callArgs CallArgs
+	var dec struct {
+		callArgs
+		txObjectFields
+		From  *AddressOrName `json:"from"`
+		To    *AddressOrName `json:"to"`
+		Nonce *rpc.HexNumber `json:"nonce"` // Ignored, calls use the nonce of the state
+	}
+	if err := rpc.UnmarshalStrict(input, &dec); err != nil {
+		return err
+	}
+	data, err := dec.txObjectFields.data(dec.Data)
+	if err != nil {
+		return err
+	}
+	*args = CallArgs(dec.callArgs)
+	args.Data = data
+	args.names.set(dec.From, dec.To, &args.From, &args.To)
+	return nil
+}
+
+// resolveNames resolves the ENS names given instead of addresses.
+func (args *CallArgs) resolveNames(ens *registrar.ENS) error {
+	return args.names.resolve(ens, &args.From, &args.To)
+}
+
+// UnmarshalJSON decodes transaction arguments, accepting ENS names for addresses.
+func (args *SendTxArgs) UnmarshalJSON(input []byte) error {
+	type sendTxArgs SendTxArgs
+	var dec struct {
+		sendTxArgs
+		txObjectFields
+		From *AddressOrName `json:"from"`
+		To   *AddressOrName `json:"to"`
+	}
+	if err := rpc.UnmarshalStrict(input, &dec); err != nil {
+		return err
+	}
+	data, err := dec.txObjectFields.data(dec.Data)
+	if err != nil {
+		return err
+	}
+	*args = SendTxArgs(dec.sendTxArgs)
+	args.Data = data
+	args.names.set(dec.From, dec.To, &args.From, &args.To)
+	return nil
+}
+
+// resolveNames resolves the ENS names given instead of addresses.
+func (args *SendTxArgs) resolveNames(ens *registrar.ENS) error {
+	return args.names.resolve(ens, &args.From, &args.To)
+}
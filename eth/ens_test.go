@@ -0,0 +1,74 @@
+package eth
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/openether/ethcore/common"
+)
+
+// Tests that the fields of the transaction objects of other clients are accepted
+// by the call and transaction arguments.
+func TestTxArgsObjectFields(t *testing.T) {
+	var call CallArgs
+	if err := json.Unmarshal([]byte(`{"to": "0x0000000000000000000000000000000000001234", "input": "0x01", "nonce": "0x5", "chainId": "0x3d", "type": "0x0"}`), &call); err != nil {
+		t.Fatalf("call args rejected: %v", err)
+	}
+	if call.Data != "0x01" {
+		t.Errorf("call data mismatch: have %q, want %q", call.Data, "0x01")
+	}
+	var send SendTxArgs
+	if err := json.Unmarshal([]byte(`{"from": "0x0000000000000000000000000000000000001234", "data": "0xAB", "input": "0xab", "nonce": "0x5", "chainId": "0x3d"}`), &send); err != nil {
+		t.Fatalf("transaction args rejected: %v", err)
+	}
+	if send.Data != "0xab" || send.Nonce == nil || send.Nonce.Int64() != 5 {
+		t.Errorf("transaction args mismatch: data %q, nonce %v", send.Data, send.Nonce)
+	}
+
+	tests := []string{
+		`{"data": "0x01", "input": "0x02"}`,
+		`{"type": "0x2"}`,
+		`{"maxFeePerGas": "0x1"}`,
+	}
+	for i, input := range tests {
+		if err := json.Unmarshal([]byte(input), &call); err == nil {
+			t.Errorf("test %d: call args accepted", i)
+		}
+		if err := json.Unmarshal([]byte(input), &send); err == nil {
+			t.Errorf("test %d: transaction args accepted", i)
+		}
+	}
+}
+
+// Tests that the call and transaction arguments remember the ENS names given for
+// their addresses, failing to resolve them without a registry.
+func TestTxArgsENSNames(t *testing.T) {
+	var call CallArgs
+	if err := json.Unmarshal([]byte(`{"from": "alice.eth", "to": "0x0000000000000000000000000000000000001234"}`), &call); err != nil {
+		t.Fatalf("call args rejected: %v", err)
+	}
+	if call.names != (ensNames{from: "alice.eth"}) || call.To == nil || *call.To != common.HexToAddress("0x1234") {
+		t.Errorf("call args mismatch: names %+v, to %v", call.names, call.To)
+	}
+	if err := call.resolveNames(nil); err != errNoENSRegistry {
+		t.Errorf("call name resolution error mismatch: have %v, want %v", err, errNoENSRegistry)
+	}
+	var send SendTxArgs
+	if err := json.Unmarshal([]byte(`{"from": "0x0000000000000000000000000000000000001234", "to": "bob.eth"}`), &send); err != nil {
+		t.Fatalf("transaction args rejected: %v", err)
+	}
+	if send.names != (ensNames{to: "bob.eth"}) || send.From != common.HexToAddress("0x1234") {
+		t.Errorf("transaction args mismatch: names %+v, from %v", send.names, send.From)
+	}
+	if err := send.resolveNames(nil); err != errNoENSRegistry {
+		t.Errorf("transaction name resolution error mismatch: have %v, want %v", err, errNoENSRegistry)
+	}
+	// Arguments without names need no registry
+	send = SendTxArgs{}
+	if err := json.Unmarshal([]byte(`{"from": "0x0000000000000000000000000000000000001234"}`), &send); err != nil {
+		t.Fatalf("transaction args rejected: %v", err)
+	}
+	if err := send.resolveNames(nil); err != nil {
+		t.Errorf("resolving arguments without names failed: %v", err)
+	}
+}
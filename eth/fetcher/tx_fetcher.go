@@ -0,0 +1,302 @@
+package fetcher
+
+import (
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+const (
+	txArriveTimeout = 500 * time.Millisecond // Time allowance before an announced transaction is explicitly requested
+	txFetchTimeout  = 5 * time.Second        // Maximum allotted time to return an explicitly requested transaction
+	txHashLimit     = 4096                   // Maximum number of unique transactions a peer may have announced
+	MaxTxFetch      = 256                    // Maximum number of transactions requested from a peer in one go
+)
+
+// txRequesterFn is a callback type for sending a transaction retrieval request.
+type txRequesterFn func([]common.Hash) error
+
+// txKnownFn is a callback type to check whether a transaction is already known
+// locally and hence doesn't need to be fetched.
+type txKnownFn func(common.Hash) bool
+
+// txAnnounce is the hash notification of the availability of a new transaction
+// in the network.
+type txAnnounce struct {
+	hash   common.Hash // Hash of the transaction being announced
+	time   time.Time   // Timestamp of the announcement
+	origin string      // Identifier of the peer originating the notification
+
+	fetchTxs txRequesterFn // Fetcher function to retrieve the announced transaction
+}
+
+// txNotify is a batch of transaction announcements from a single peer.
+type txNotify struct {
+	origin   string
+	hashes   []common.Hash
+	time     time.Time
+	fetchTxs txRequesterFn
+}
+
+// TxFetcher is responsible for retrieving transactions which were only
+// announced by hash. Peers receiving a transaction directly relay the full body
+// only to a subset of their peers, so the rest learn about it through
+// announcements and request the body from one of the announcers, falling back
+// to another if the request goes unanswered.
+type TxFetcher struct {
+	notify  chan *txNotify
+	deliver chan []common.Hash
+	drop    chan string
+	quit    chan struct{}
+
+	announces map[string]int                // Per peer announce counts to prevent memory exhaustion
+	announced map[common.Hash][]*txAnnounce // Announced transactions, scheduled for fetching
+	fetching  map[common.Hash]*txAnnounce   // Announced transactions, currently fetching
+
+	isKnown txKnownFn // Checks whether a transaction is already known locally
+
+	// Testing hooks
+	fetchingHook func(string, []common.Hash) // Method to call upon starting a transaction fetch
+}
+
+// NewTxFetcher creates a transaction fetcher to retrieve transactions based on
+// hash announcements.
+func NewTxFetcher(isKnown txKnownFn) *TxFetcher {
+	return &TxFetcher{
+		notify:    make(chan *txNotify),
+		deliver:   make(chan []common.Hash),
+		drop:      make(chan string),
+		quit:      make(chan struct{}),
+		announces: make(map[string]int),
+		announced: make(map[common.Hash][]*txAnnounce),
+		fetching:  make(map[common.Hash]*txAnnounce),
+		isKnown:   isKnown,
+	}
+}
+
+// Start boots up the transaction fetcher, accepting announcements and
+// deliveries until termination requested.
+func (f *TxFetcher) Start() {
+	go f.loop()
+}
+
+// Stop terminates the transaction fetcher, canceling all pending operations.
+func (f *TxFetcher) Stop() {
+	close(f.quit)
+}
+
+// Notify announces the fetcher of the potential availability of a batch of new
+// transactions in the network.
+func (f *TxFetcher) Notify(peer string, hashes []common.Hash, time time.Time, fetchTxs txRequesterFn) error {
+	op := &txNotify{
+		origin:   peer,
+		hashes:   hashes,
+		time:     time,
+		fetchTxs: fetchTxs,
+	}
+	select {
+	case f.notify <- op:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Deliver notifies the fetcher that the given transactions arrived, be it
+// through an explicit request or a direct broadcast, so that no (further)
+// retrievals are scheduled for them.
+func (f *TxFetcher) Deliver(hashes []common.Hash) error {
+	select {
+	case f.deliver <- hashes:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Drop removes all the announcements originating from a disconnected peer.
+func (f *TxFetcher) Drop(peer string) error {
+	select {
+	case f.drop <- peer:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// loop is the main fetcher loop, checking and processing various notification
+// events.
+func (f *TxFetcher) loop() {
+	fetchTimer := time.NewTimer(0)
+
+	for {
+		// Clean up any expired transaction fetches, falling back to the next
+		// announcer of the same transaction if there's any
+		for hash, announce := range f.fetching {
+			if time.Since(announce.time) > txFetchTimeout {
+				glog.V(logger.Detail).Infof("Peer %s: transaction %x fetch timed out", announce.origin, hash[:4])
+				delete(f.fetching, hash)
+				f.forgetAnnounce(hash, announce.origin)
+			}
+		}
+		select {
+		case <-f.quit:
+			// Fetcher terminating, abort all operations
+			return
+
+		case op := <-f.notify:
+			added := 0
+			for _, hash := range op.hashes {
+				if f.isKnown(hash) || f.announcedBy(hash, op.origin) {
+					continue
+				}
+				if f.announces[op.origin] >= txHashLimit {
+					glog.V(logger.Debug).Infof("Peer %s: exceeded outstanding transaction announces (%d)", op.origin, txHashLimit)
+					break
+				}
+				f.announces[op.origin]++
+				f.announced[hash] = append(f.announced[hash], &txAnnounce{
+					hash:     hash,
+					time:     op.time,
+					origin:   op.origin,
+					fetchTxs: op.fetchTxs,
+				})
+				added++
+			}
+			if added > 0 {
+				f.rescheduleFetch(fetchTimer)
+			}
+
+		case hashes := <-f.deliver:
+			for _, hash := range hashes {
+				delete(f.fetching, hash)
+				f.forgetHash(hash)
+			}
+
+		case peer := <-f.drop:
+			for hash, announces := range f.announced {
+				for _, announce := range announces {
+					if announce.origin == peer {
+						if fetch := f.fetching[hash]; fetch != nil && fetch.origin == peer {
+							delete(f.fetching, hash)
+						}
+						f.forgetAnnounce(hash, peer)
+						break
+					}
+				}
+			}
+
+		case <-fetchTimer.C:
+			// At least one transaction's timer ran out, check for needing retrieval
+			request := make(map[string][]common.Hash)
+			fetchers := make(map[string]txRequesterFn)
+
+			for hash, announces := range f.announced {
+				if _, ok := f.fetching[hash]; ok {
+					continue
+				}
+				if time.Since(announces[0].time) < txArriveTimeout-gatherSlack {
+					continue
+				}
+				if f.isKnown(hash) {
+					f.forgetHash(hash)
+					continue
+				}
+				// Pick the first announcer not yet saturated with requests
+				for _, announce := range announces {
+					if len(request[announce.origin]) >= MaxTxFetch {
+						continue
+					}
+					request[announce.origin] = append(request[announce.origin], hash)
+					fetchers[announce.origin] = announce.fetchTxs
+
+					announce.time = time.Now()
+					f.fetching[hash] = announce
+					break
+				}
+			}
+			// Send out all transaction requests
+			for peer, hashes := range request {
+				glog.V(logger.Detail).Infof("Peer %s: fetching %d transactions", peer, len(hashes))
+				if f.fetchingHook != nil {
+					f.fetchingHook(peer, hashes)
+				}
+				go fetchers[peer](hashes)
+			}
+			// Schedule the next fetch if transactions are still pending
+			f.rescheduleFetch(fetchTimer)
+		}
+	}
+}
+
+// rescheduleFetch resets the specified fetch timer to the next announce timeout,
+// or to the fetch timeout if only in-flight retrievals remain.
+func (f *TxFetcher) rescheduleFetch(fetch *time.Timer) {
+	// Short circuit if no transactions are announced
+	if len(f.announced) == 0 {
+		return
+	}
+	// Otherwise find the earliest expiring announcement
+	earliest := time.Now().Add(txFetchTimeout)
+	for hash, announces := range f.announced {
+		if _, ok := f.fetching[hash]; ok {
+			continue
+		}
+		if announces[0].time.Before(earliest) {
+			earliest = announces[0].time
+		}
+	}
+	for _, announce := range f.fetching {
+		if deadline := announce.time.Add(txFetchTimeout - txArriveTimeout); deadline.Before(earliest) {
+			earliest = deadline
+		}
+	}
+	fetch.Reset(txArriveTimeout - time.Since(earliest))
+}
+
+// announcedBy reports whether the given peer has already announced a transaction.
+func (f *TxFetcher) announcedBy(hash common.Hash, peer string) bool {
+	for _, announce := range f.announced[hash] {
+		if announce.origin == peer {
+			return true
+		}
+	}
+	return false
+}
+
+// forgetAnnounce removes a single peer's announcement of a transaction, dropping
+// the transaction altogether if nobody else announced it.
+func (f *TxFetcher) forgetAnnounce(hash common.Hash, peer string) {
+	announces := f.announced[hash]
+	for i, announce := range announces {
+		if announce.origin != peer {
+			continue
+		}
+		f.decAnnounces(peer)
+		announces = append(announces[:i], announces[i+1:]...)
+		break
+	}
+	if len(announces) == 0 {
+		delete(f.announced, hash)
+	} else {
+		f.announced[hash] = announces
+	}
+}
+
+// forgetHash removes all traces of a transaction announcement from the fetcher's
+// internal state.
+func (f *TxFetcher) forgetHash(hash common.Hash) {
+	for _, announce := range f.announced[hash] {
+		f.decAnnounces(announce.origin)
+	}
+	delete(f.announced, hash)
+}
+
+func (f *TxFetcher) decAnnounces(peer string) {
+	f.announces[peer]--
+	if f.announces[peer] <= 0 {
+		delete(f.announces, peer)
+	}
+}
@@ -0,0 +1,132 @@
+// +build !deterministic
+
+package fetcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openether/ethcore/common"
+)
+
+// txFetcherTester is a test simulator for mocking out the transaction pool.
+type txFetcherTester struct {
+	fetcher *TxFetcher
+	known   map[common.Hash]bool
+	fetches chan txFetch
+}
+
+type txFetch struct {
+	peer   string
+	hashes []common.Hash
+}
+
+func newTxFetcherTester(known ...common.Hash) *txFetcherTester {
+	tester := &txFetcherTester{
+		known:   make(map[common.Hash]bool),
+		fetches: make(chan txFetch, 16),
+	}
+	for _, hash := range known {
+		tester.known[hash] = true
+	}
+	// The known set is only read from the fetcher's own goroutine
+	tester.fetcher = NewTxFetcher(func(hash common.Hash) bool { return tester.known[hash] })
+	tester.fetcher.fetchingHook = func(peer string, hashes []common.Hash) {
+		tester.fetches <- txFetch{peer, hashes}
+	}
+	return tester
+}
+
+func nopTxRequester([]common.Hash) error { return nil }
+
+func (tt *txFetcherTester) expectFetch(t *testing.T, peer string, hashes ...common.Hash) {
+	select {
+	case fetch := <-tt.fetches:
+		if fetch.peer != peer {
+			t.Fatalf("fetch peer mismatch: have %s, want %s", fetch.peer, peer)
+		}
+		if len(fetch.hashes) != len(hashes) {
+			t.Fatalf("fetch count mismatch: have %d, want %d", len(fetch.hashes), len(hashes))
+		}
+		want := make(map[common.Hash]bool)
+		for _, hash := range hashes {
+			want[hash] = true
+		}
+		for _, hash := range fetch.hashes {
+			if !want[hash] {
+				t.Fatalf("unexpected fetch of %x", hash)
+			}
+		}
+	case <-time.After(txArriveTimeout + time.Second):
+		t.Fatalf("fetch from %s timeout", peer)
+	}
+}
+
+func (tt *txFetcherTester) expectNoFetch(t *testing.T) {
+	select {
+	case fetch := <-tt.fetches:
+		t.Fatalf("unexpected fetch from %s: %x", fetch.peer, fetch.hashes)
+	case <-time.After(txArriveTimeout + 200*time.Millisecond):
+	}
+}
+
+// Tests that announced transactions are fetched from the announcer once the
+// arrival timeout expires, skipping the ones already known.
+func TestTxFetcherAnnounce(t *testing.T) {
+	known := common.Hash{0x01}
+	tester := newTxFetcherTester(known)
+	tester.fetcher.Start()
+	defer tester.fetcher.Stop()
+
+	hashes := []common.Hash{known, {0x02}, {0x03}}
+	tester.fetcher.Notify("A", hashes, time.Now(), nopTxRequester)
+	tester.expectFetch(t, "A", hashes[1:]...)
+}
+
+// Tests that transactions delivered before the arrival timeout expires are not
+// fetched.
+func TestTxFetcherDeliverBeforeFetch(t *testing.T) {
+	tester := newTxFetcherTester()
+	tester.fetcher.Start()
+	defer tester.fetcher.Stop()
+
+	hashes := []common.Hash{{0x01}, {0x02}}
+	tester.fetcher.Notify("A", hashes, time.Now(), nopTxRequester)
+	tester.fetcher.Deliver(hashes[:1])
+	tester.expectFetch(t, "A", hashes[1])
+	tester.fetcher.Deliver(hashes[1:])
+	tester.expectNoFetch(t)
+}
+
+// Tests that if the first announcer of a transaction disconnects, the
+// transaction is fetched from another announcer instead.
+func TestTxFetcherDropAnnouncer(t *testing.T) {
+	tester := newTxFetcherTester()
+	tester.fetcher.Start()
+	defer tester.fetcher.Stop()
+
+	hash := common.Hash{0x01}
+	tester.fetcher.Notify("A", []common.Hash{hash}, time.Now(), nopTxRequester)
+	tester.fetcher.Notify("B", []common.Hash{hash}, time.Now(), nopTxRequester)
+	tester.fetcher.Drop("A")
+	tester.expectFetch(t, "B", hash)
+}
+
+// Tests that a peer can't make the fetcher track an unbounded number of
+// announcements.
+func TestTxFetcherAnnounceLimit(t *testing.T) {
+	tester := newTxFetcherTester()
+	tester.fetcher.Start()
+	defer tester.fetcher.Stop()
+
+	hashes := make([]common.Hash, txHashLimit+16)
+	for i := range hashes {
+		hashes[i][0], hashes[i][1] = byte(i>>8), byte(i)
+	}
+	tester.fetcher.Notify("A", hashes, time.Now(), nopTxRequester)
+	tester.fetcher.Drop("B") // Synchronise with the fetcher loop
+
+	if have := len(tester.fetcher.announced); have != txHashLimit {
+		t.Fatalf("announced count mismatch: have %d, want %d", have, txHashLimit)
+	}
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/openether/ethcore/common"
@@ -20,8 +21,40 @@ import (
 
 var (
 	filterTickerTime = 5 * time.Minute
+
+	errTooManyFilters = errors.New("too many installed filters")
 )
 
+// Config holds the limits applied to installed filters.
+type Config struct {
+	Timeout     time.Duration // filters that aren't polled for this long are uninstalled
+	MaxPerOwner int           // max number of filters a single client can install, 0 for no limit
+	BufferSize  int           // max number of changes buffered per filter between polls, 0 for no limit
+}
+
+// DefaultConfig contains the default filter limits.
+var DefaultConfig = Config{
+	Timeout: filterTickerTime,
+}
+
+// FilterInfo describes an installed filter.
+type FilterInfo struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Owner    string    `json:"owner"`
+	Created  time.Time `json:"created"`
+	LastPoll time.Time `json:"lastPoll"`
+	Buffered int       `json:"buffered"`
+}
+
+// filterOwner records who installed a filter and when.
+type filterOwner struct {
+	externalId string
+	kind       string
+	owner      string
+	created    time.Time
+}
+
 // byte will be inferred
 const (
 	unknownFilterTy = iota
@@ -37,11 +70,15 @@ type PublicFilterAPI struct {
 
 	quit    chan struct{}
 	chainDb ethdb.Database
+	config  Config
 
 	filterManager *FilterSystem
 
+	installMu sync.Mutex // held from the limit check to the registration of an installed filter
+
 	filterMapMu   sync.RWMutex
-	filterMapping map[string]int // maps between filter internal filter identifiers and external filter identifiers
+	filterMapping map[string]int       // maps between filter internal filter identifiers and external filter identifiers
+	filterOwners  map[int]*filterOwner // owners of installed filters by internal identifier
 
 	logMu    sync.RWMutex
 	logQueue map[int]*logQueue
@@ -51,15 +88,22 @@ type PublicFilterAPI struct {
 
 	transactionMu    sync.RWMutex
 	transactionQueue map[int]*hashQueue
+
+	formatTx func(*types.Transaction) interface{} // full transaction format of pending transaction subscriptions
 }
 
 // NewPublicFilterAPI returns a new PublicFilterAPI instance.
-func NewPublicFilterAPI(chainDb ethdb.Database, mux *event.TypeMux) *PublicFilterAPI {
+func NewPublicFilterAPI(chainDb ethdb.Database, mux *event.TypeMux, config Config) *PublicFilterAPI {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultConfig.Timeout
+	}
 	svc := &PublicFilterAPI{
 		mux:              mux,
 		chainDb:          chainDb,
+		config:           config,
 		filterManager:    NewFilterSystem(mux),
 		filterMapping:    make(map[string]int),
+		filterOwners:     make(map[int]*filterOwner),
 		logQueue:         make(map[int]*logQueue),
 		blockQueue:       make(map[int]*hashQueue),
 		transactionQueue: make(map[int]*hashQueue),
@@ -68,6 +112,13 @@ func NewPublicFilterAPI(chainDb ethdb.Database, mux *event.TypeMux) *PublicFilte
 	return svc
 }
 
+// SetTransactionFormatter sets the function formatting the transactions pending
+// transaction subscriptions are notified of when they ask for full transactions.
+// Without it they are notified of hashes only.
+func (s *PublicFilterAPI) SetTransactionFormatter(format func(*types.Transaction) interface{}) {
+	s.formatTx = format
+}
+
 // Stop quits the work loop.
 func (s *PublicFilterAPI) Stop() {
 	close(s.quit)
@@ -82,32 +133,45 @@ done:
 		select {
 		case <-timer.C:
 			s.filterManager.Lock() // lock order like filterLoop()
+			var expired []int
 			s.logMu.Lock()
 			for id, filter := range s.logQueue {
-				if time.Since(filter.timeout) > filterTickerTime {
+				if time.Since(filter.timeout) > s.config.Timeout {
 					s.filterManager.Remove(id)
 					delete(s.logQueue, id)
+					expired = append(expired, id)
 				}
 			}
 			s.logMu.Unlock()
 
 			s.blockMu.Lock()
 			for id, filter := range s.blockQueue {
-				if time.Since(filter.timeout) > filterTickerTime {
+				if time.Since(filter.timeout) > s.config.Timeout {
 					s.filterManager.Remove(id)
 					delete(s.blockQueue, id)
+					expired = append(expired, id)
 				}
 			}
 			s.blockMu.Unlock()
 
 			s.transactionMu.Lock()
 			for id, filter := range s.transactionQueue {
-				if time.Since(filter.timeout) > filterTickerTime {
+				if time.Since(filter.timeout) > s.config.Timeout {
 					s.filterManager.Remove(id)
 					delete(s.transactionQueue, id)
+					expired = append(expired, id)
 				}
 			}
 			s.transactionMu.Unlock()
+
+			s.filterMapMu.Lock()
+			for _, id := range expired {
+				if owner := s.filterOwners[id]; owner != nil {
+					delete(s.filterMapping, owner.externalId)
+					delete(s.filterOwners, id)
+				}
+			}
+			s.filterMapMu.Unlock()
 			s.filterManager.Unlock()
 		case <-s.quit:
 			break done
@@ -116,8 +180,58 @@ done:
 
 }
 
+// filterOwnerFromContext returns the client that sends the request in ctx.
+func filterOwnerFromContext(ctx context.Context) string {
+	if c, ok := rpc.ConnectionFromContext(ctx); ok {
+		return c.Owner()
+	}
+	return ""
+}
+
+// checkFilterLimit returns an error when the given client can't install another filter.
+// Subscriptions don't count towards the limit, they end with the client's connection.
+// The caller must hold installMu until the filter is registered.
+func (s *PublicFilterAPI) checkFilterLimit(owner string) error {
+	if s.config.MaxPerOwner <= 0 {
+		return nil
+	}
+	s.filterMapMu.RLock()
+	defer s.filterMapMu.RUnlock()
+
+	installed := 0
+	for _, f := range s.filterOwners {
+		if f.owner == owner && !isSubscription(f.kind) {
+			installed++
+		}
+	}
+	if installed >= s.config.MaxPerOwner {
+		return errTooManyFilters
+	}
+	return nil
+}
+
+// isSubscription reports whether filters of the given kind serve a subscription.
+func isSubscription(kind string) bool {
+	return kind == "logSubscription" || kind == "pendingTransactionSubscription"
+}
+
+// registerFilter exposes the filter with the given internal identifier under externalId.
+func (s *PublicFilterAPI) registerFilter(externalId string, id int, kind, owner string) {
+	s.filterMapMu.Lock()
+	s.filterMapping[externalId] = id
+	s.filterOwners[id] = &filterOwner{externalId: externalId, kind: kind, owner: owner, created: time.Now()}
+	s.filterMapMu.Unlock()
+}
+
 // NewBlockFilter create a new filter that returns blocks that are included into the canonical chain.
-func (s *PublicFilterAPI) NewBlockFilter() (string, error) {
+func (s *PublicFilterAPI) NewBlockFilter(ctx context.Context) (string, error) {
+	owner := filterOwnerFromContext(ctx)
+	s.installMu.Lock()
+	defer s.installMu.Unlock()
+
+	if err := s.checkFilterLimit(owner); err != nil {
+		return "", err
+	}
 	// protect filterManager.Add() and setting of filter fields
 	s.filterManager.Lock()
 	defer s.filterManager.Unlock()
@@ -134,7 +248,7 @@ func (s *PublicFilterAPI) NewBlockFilter() (string, error) {
 	}
 
 	s.blockMu.Lock()
-	s.blockQueue[id] = &hashQueue{timeout: time.Now()}
+	s.blockQueue[id] = &hashQueue{timeout: time.Now(), limit: s.config.BufferSize}
 	s.blockMu.Unlock()
 
 	filter.BlockCallback = func(block *types.Block, logs vm.Logs) {
@@ -146,15 +260,20 @@ func (s *PublicFilterAPI) NewBlockFilter() (string, error) {
 		}
 	}
 
-	s.filterMapMu.Lock()
-	s.filterMapping[externalId] = id
-	s.filterMapMu.Unlock()
+	s.registerFilter(externalId, id, "block", owner)
 
 	return externalId, nil
 }
 
 // NewPendingTransactionFilter creates a filter that returns new pending transactions.
-func (s *PublicFilterAPI) NewPendingTransactionFilter() (string, error) {
+func (s *PublicFilterAPI) NewPendingTransactionFilter(ctx context.Context) (string, error) {
+	owner := filterOwnerFromContext(ctx)
+	s.installMu.Lock()
+	defer s.installMu.Unlock()
+
+	if err := s.checkFilterLimit(owner); err != nil {
+		return "", err
+	}
 	// protect filterManager.Add() and setting of filter fields
 	s.filterManager.Lock()
 	defer s.filterManager.Unlock()
@@ -171,7 +290,7 @@ func (s *PublicFilterAPI) NewPendingTransactionFilter() (string, error) {
 	}
 
 	s.transactionMu.Lock()
-	s.transactionQueue[id] = &hashQueue{timeout: time.Now()}
+	s.transactionQueue[id] = &hashQueue{timeout: time.Now(), limit: s.config.BufferSize}
 	s.transactionMu.Unlock()
 
 	filter.TransactionCallback = func(tx *types.Transaction) {
@@ -183,13 +302,58 @@ func (s *PublicFilterAPI) NewPendingTransactionFilter() (string, error) {
 		}
 	}
 
-	s.filterMapMu.Lock()
-	s.filterMapping[externalId] = id
-	s.filterMapMu.Unlock()
+	s.registerFilter(externalId, id, "pendingTransaction", owner)
 
 	return externalId, nil
 }
 
+// NewPendingTransactions creates a subscription notified of each transaction entering the transaction
+// pool, whether sent through this node or relayed by its peers. Notifications carry the transaction
+// hash, or the full transaction object when fullTx is set.
+func (s *PublicFilterAPI) NewPendingTransactions(ctx context.Context, fullTx *bool) (rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	externalId, err := newFilterId()
+	if err != nil {
+		return nil, err
+	}
+	// uninstall filter when subscription is unsubscribed/cancelled
+	subscription, err := notifier.NewSubscription(func(string) {
+		s.UninstallFilter(externalId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	format := func(tx *types.Transaction) interface{} { return tx.Hash() }
+	if fullTx != nil && *fullTx && s.formatTx != nil {
+		format = s.formatTx
+	}
+
+	// protect filterManager.Add() and setting of filter fields
+	s.filterManager.Lock()
+	defer s.filterManager.Unlock()
+
+	filter := New(s.chainDb)
+	id, err := s.filterManager.Add(filter, PendingTxFilter)
+	if err != nil {
+		subscription.Cancel()
+		return nil, err
+	}
+	var cancelled int32
+	filter.TransactionCallback = func(tx *types.Transaction) {
+		// The filter system holds its lock while calling back, the subscription is
+		// cancelled in the background as uninstalling the filter needs that lock.
+		if err := subscription.Notify(format(tx)); err != nil && atomic.CompareAndSwapInt32(&cancelled, 0, 1) {
+			go subscription.Cancel()
+		}
+	}
+	s.registerFilter(externalId, id, "pendingTransactionSubscription", filterOwnerFromContext(ctx))
+
+	return subscription, nil
+}
+
 // newLogFilter creates a new log filter.
 func (s *PublicFilterAPI) newLogFilter(earliest, latest int64, addresses []common.Address, topics [][]common.Hash, callback func(log *vm.Log, removed bool)) (int, error) {
 	// protect filterManager.Add() and setting of filter fields
@@ -203,7 +367,7 @@ func (s *PublicFilterAPI) newLogFilter(earliest, latest int64, addresses []commo
 	}
 
 	s.logMu.Lock()
-	s.logQueue[id] = &logQueue{timeout: time.Now()}
+	s.logQueue[id] = &logQueue{timeout: time.Now(), limit: s.config.BufferSize}
 	s.logMu.Unlock()
 
 	filter.SetBeginBlock(earliest)
@@ -236,6 +400,7 @@ func (s *PublicFilterAPI) Logs(ctx context.Context, args NewFilterArgs) (rpc.Sub
 		externalId   string
 		subscription rpc.Subscription
 		err          error
+		owner        = filterOwnerFromContext(ctx)
 	)
 
 	if externalId, err = newFilterId(); err != nil {
@@ -249,10 +414,12 @@ func (s *PublicFilterAPI) Logs(ctx context.Context, args NewFilterArgs) (rpc.Sub
 		return nil, err
 	}
 
+	var cancelled int32
 	notifySubscriber := func(log *vm.Log, removed bool) {
 		rpcLog := toRPCLogs(vm.Logs{log}, removed)
-		if err := subscription.Notify(rpcLog); err != nil {
-			subscription.Cancel()
+		// Cancelled in the background, see NewPendingTransactions
+		if err := subscription.Notify(rpcLog); err != nil && atomic.CompareAndSwapInt32(&cancelled, 0, 1) {
+			go subscription.Cancel()
 		}
 	}
 
@@ -269,9 +436,7 @@ func (s *PublicFilterAPI) Logs(ctx context.Context, args NewFilterArgs) (rpc.Sub
 		return nil, err
 	}
 
-	s.filterMapMu.Lock()
-	s.filterMapping[externalId] = id
-	s.filterMapMu.Unlock()
+	s.registerFilter(externalId, id, "logSubscription", owner)
 
 	return subscription, err
 }
@@ -294,7 +459,7 @@ func (args *NewFilterArgs) UnmarshalJSON(data []byte) error {
 	}
 
 	var raw input
-	if err := json.Unmarshal(data, &raw); err != nil {
+	if err := rpc.UnmarshalStrict(data, &raw); err != nil {
 		return err
 	}
 
@@ -399,7 +564,14 @@ func (args *NewFilterArgs) UnmarshalJSON(data []byte) error {
 }
 
 // NewFilter creates a new filter and returns the filter id. It can be uses to retrieve logs.
-func (s *PublicFilterAPI) NewFilter(args NewFilterArgs) (string, error) {
+func (s *PublicFilterAPI) NewFilter(ctx context.Context, args NewFilterArgs) (string, error) {
+	owner := filterOwnerFromContext(ctx)
+	s.installMu.Lock()
+	defer s.installMu.Unlock()
+
+	if err := s.checkFilterLimit(owner); err != nil {
+		return "", err
+	}
 	externalId, err := newFilterId()
 	if err != nil {
 		return "", err
@@ -415,22 +587,25 @@ func (s *PublicFilterAPI) NewFilter(args NewFilterArgs) (string, error) {
 		return "", err
 	}
 
-	s.filterMapMu.Lock()
-	s.filterMapping[externalId] = id
-	s.filterMapMu.Unlock()
+	s.registerFilter(externalId, id, "log", owner)
 
 	return externalId, nil
 }
 
-// GetLogs returns the logs matching the given argument.
-func (s *PublicFilterAPI) GetLogs(args NewFilterArgs) []vmlog {
+// GetLogs returns the logs matching the given argument. The search is aborted
+// once the request times out or its client disconnects.
+func (s *PublicFilterAPI) GetLogs(ctx context.Context, args NewFilterArgs) ([]vmlog, error) {
 	filter := New(s.chainDb)
 	filter.SetBeginBlock(args.FromBlock.Int64())
 	filter.SetEndBlock(args.ToBlock.Int64())
 	filter.SetAddresses(args.Addresses)
 	filter.SetTopics(args.Topics)
 
-	return toRPCLogs(filter.Find(), false)
+	logs, err := filter.FindContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toRPCLogs(logs, false), nil
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -445,6 +620,7 @@ func (s *PublicFilterAPI) UninstallFilter(filterId string) bool {
 		return false
 	}
 	delete(s.filterMapping, filterId)
+	delete(s.filterOwners, id)
 	s.filterMapMu.Unlock()
 
 	s.filterManager.Remove(id)
@@ -572,6 +748,7 @@ type logQueue struct {
 
 	logs    []vmlog
 	timeout time.Time
+	limit   int // max number of buffered logs, oldest are dropped first; 0 for no limit
 }
 
 func (l *logQueue) add(logs ...vmlog) {
@@ -579,6 +756,16 @@ func (l *logQueue) add(logs ...vmlog) {
 	defer l.mu.Unlock()
 
 	l.logs = append(l.logs, logs...)
+	if l.limit > 0 && len(l.logs) > l.limit {
+		l.logs = append([]vmlog(nil), l.logs[len(l.logs)-l.limit:]...)
+	}
+}
+
+func (l *logQueue) info() (time.Time, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.timeout, len(l.logs)
 }
 
 func (l *logQueue) get() []vmlog {
@@ -596,6 +783,7 @@ type hashQueue struct {
 
 	hashes  []common.Hash
 	timeout time.Time
+	limit   int // max number of buffered hashes, oldest are dropped first; 0 for no limit
 }
 
 func (l *hashQueue) add(hashes ...common.Hash) {
@@ -603,6 +791,16 @@ func (l *hashQueue) add(hashes ...common.Hash) {
 	defer l.mu.Unlock()
 
 	l.hashes = append(l.hashes, hashes...)
+	if l.limit > 0 && len(l.hashes) > l.limit {
+		l.hashes = append([]common.Hash(nil), l.hashes[len(l.hashes)-l.limit:]...)
+	}
+}
+
+func (l *hashQueue) info() (time.Time, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.timeout, len(l.hashes)
 }
 
 func (l *hashQueue) get() []common.Hash {
@@ -615,6 +813,65 @@ func (l *hashQueue) get() []common.Hash {
 	return tmp
 }
 
+// installedFilters returns the currently installed filters together with the clients that installed them.
+func (s *PublicFilterAPI) installedFilters() []FilterInfo {
+	s.filterMapMu.RLock()
+	filters := make([]FilterInfo, 0, len(s.filterOwners))
+	ids := make([]int, 0, len(s.filterOwners))
+	for id, f := range s.filterOwners {
+		filters = append(filters, FilterInfo{ID: f.externalId, Type: f.kind, Owner: f.owner, Created: f.created})
+		ids = append(ids, id)
+	}
+	s.filterMapMu.RUnlock()
+
+	for i, id := range ids {
+		var queue interface {
+			info() (time.Time, int)
+		}
+		s.logMu.RLock()
+		if q := s.logQueue[id]; q != nil {
+			queue = q
+		}
+		s.logMu.RUnlock()
+		s.blockMu.RLock()
+		if q := s.blockQueue[id]; q != nil {
+			queue = q
+		}
+		s.blockMu.RUnlock()
+		s.transactionMu.RLock()
+		if q := s.transactionQueue[id]; q != nil {
+			queue = q
+		}
+		s.transactionMu.RUnlock()
+		if queue != nil {
+			filters[i].LastPoll, filters[i].Buffered = queue.info()
+		}
+	}
+	sort.Sort(filtersByCreation(filters))
+	return filters
+}
+
+// PrivateFilterAPI offers an administrative view on the filters installed through a PublicFilterAPI.
+type PrivateFilterAPI struct {
+	api *PublicFilterAPI
+}
+
+// NewPrivateFilterAPI creates an administrative API for the filters of the given filter API.
+func NewPrivateFilterAPI(api *PublicFilterAPI) *PrivateFilterAPI {
+	return &PrivateFilterAPI{api: api}
+}
+
+// Filters returns the currently installed filters, oldest first, together with the clients that installed them.
+func (s *PrivateFilterAPI) Filters() []FilterInfo {
+	return s.api.installedFilters()
+}
+
+type filtersByCreation []FilterInfo
+
+func (f filtersByCreation) Len() int           { return len(f) }
+func (f filtersByCreation) Less(i, j int) bool { return f[i].Created.Before(f[j].Created) }
+func (f filtersByCreation) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
 // newFilterId generates a new random filter identifier that can be exposed to the outer world. By publishing random
 // identifiers it is not feasible for DApp's to guess filter id's for other DApp's and uninstall or poll for them
 // causing the affected DApp to miss data.
@@ -196,3 +196,10 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 		)
 	}
 }
+
+func TestUnmarshalJSONNewFilterArgsUnknownField(t *testing.T) {
+	var args filters.NewFilterArgs
+	if err := json.Unmarshal([]byte(`{"fromBlock": "0x1", "blockhash": "0x00"}`), &args); err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+}
@@ -1,6 +1,7 @@
 package filters
 
 import (
+	"context"
 	"math"
 	"time"
 
@@ -56,9 +57,16 @@ func (self *Filter) SetTopics(topics [][]common.Hash) {
 
 // Run filters logs with the current parameters set
 func (self *Filter) Find() vm.Logs {
+	logs, _ := self.FindContext(context.Background())
+	return logs
+}
+
+// FindContext is like Find, aborting the search with the error of the context
+// once it is done.
+func (self *Filter) FindContext(ctx context.Context) (vm.Logs, error) {
 	latestBlock := core.GetBlock(self.db, core.GetHeadBlockHash(self.db))
 	if latestBlock == nil {
-		return vm.Logs{}
+		return vm.Logs{}, nil
 	}
 	var beginBlockNo uint64 = uint64(self.begin)
 	if self.begin == -1 {
@@ -73,16 +81,19 @@ func (self *Filter) Find() vm.Logs {
 	// uses the mipmap bloom filters to check for fast inclusion and uses
 	// higher range probability in order to ensure at least a false positive
 	if len(self.addresses) == 0 {
-		return self.getLogs(beginBlockNo, endBlockNo)
+		return self.getLogs(ctx, beginBlockNo, endBlockNo)
 	}
-	return self.mipFind(beginBlockNo, endBlockNo, 0)
+	return self.mipFind(ctx, beginBlockNo, endBlockNo, 0)
 }
 
-func (self *Filter) mipFind(start, end uint64, depth int) (logs vm.Logs) {
+func (self *Filter) mipFind(ctx context.Context, start, end uint64, depth int) (logs vm.Logs, err error) {
 	level := core.MIPMapLevels[depth]
 	// normalise numerator so we can work in level specific batches and
 	// work with the proper range checks
 	for num := start / level * level; num <= end; num += level {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		// find addresses in bloom filters
 		bloom := core.GetMipmapBloom(self.db, num, level)
 		for _, addr := range self.addresses {
@@ -92,11 +103,16 @@ func (self *Filter) mipFind(start, end uint64, depth int) (logs vm.Logs) {
 				// normalised values.
 				start := uint64(math.Max(float64(num), float64(start)))
 				end := uint64(math.Min(float64(num+level-1), float64(end)))
+				var found vm.Logs
 				if depth+1 == len(core.MIPMapLevels) {
-					logs = append(logs, self.getLogs(start, end)...)
+					found, err = self.getLogs(ctx, start, end)
 				} else {
-					logs = append(logs, self.mipFind(start, end, depth+1)...)
+					found, err = self.mipFind(ctx, start, end, depth+1)
 				}
+				if err != nil {
+					return nil, err
+				}
+				logs = append(logs, found...)
 				// break so we don't check the same range for each
 				// possible address. Checks on multiple addresses
 				// are handled further down the stack.
@@ -105,18 +121,21 @@ func (self *Filter) mipFind(start, end uint64, depth int) (logs vm.Logs) {
 		}
 	}
 
-	return logs
+	return logs, nil
 }
 
-func (self *Filter) getLogs(start, end uint64) (logs vm.Logs) {
+func (self *Filter) getLogs(ctx context.Context, start, end uint64) (logs vm.Logs, err error) {
 	for i := start; i <= end; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		var block *types.Block
 		hash := core.GetCanonicalHash(self.db, i)
 		if hash != (common.Hash{}) {
 			block = core.GetBlock(self.db, hash)
 		}
 		if block == nil { // block not found/written
-			return logs
+			return logs, nil
 		}
 
 		// Use bloom filtering to see if this block is interesting given the
@@ -134,7 +153,7 @@ func (self *Filter) getLogs(start, end uint64) (logs vm.Logs) {
 		}
 	}
 
-	return logs
+	return logs, nil
 }
 
 func includes(addresses []common.Address, a common.Address) bool {
@@ -0,0 +1,236 @@
+package filters
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/crypto"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/event"
+	"github.com/openether/ethcore/rpc"
+)
+
+func TestFilterLimits(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	api := NewPublicFilterAPI(db, new(event.TypeMux), Config{MaxPerOwner: 2, BufferSize: 3})
+	if api.config.Timeout != DefaultConfig.Timeout {
+		t.Errorf("timeout not defaulted: %v", api.config.Timeout)
+	}
+	ctx := context.Background()
+
+	blockId, err := api.NewBlockFilter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.NewPendingTransactionFilter(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.NewFilter(ctx, NewFilterArgs{}); err != errTooManyFilters {
+		t.Fatalf("expected %v, got %v", errTooManyFilters, err)
+	}
+
+	filters := NewPrivateFilterAPI(api).Filters()
+	if len(filters) != 2 {
+		t.Fatalf("unexpected installed filters: %+v", filters)
+	}
+	for _, f := range filters {
+		if (f.Type == "block") != (f.ID == blockId) || (f.Type != "block" && f.Type != "pendingTransaction") {
+			t.Errorf("unexpected installed filter: %+v", f)
+		}
+	}
+
+	// Uninstalling makes room for another filter.
+	if !api.UninstallFilter(blockId) {
+		t.Fatal("block filter not uninstalled")
+	}
+	if _, err := api.NewFilter(ctx, NewFilterArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	filters = NewPrivateFilterAPI(api).Filters()
+	if len(filters) != 2 || (filters[0].Type != "log" && filters[1].Type != "log") {
+		t.Fatalf("unexpected installed filters: %+v", filters)
+	}
+}
+
+// Tests that concurrent installs of a client can't exceed its limit.
+func TestFilterLimitConcurrency(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	api := NewPublicFilterAPI(db, new(event.TypeMux), Config{MaxPerOwner: 5})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			api.NewBlockFilter(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	if filters := NewPrivateFilterAPI(api).Filters(); len(filters) != 5 {
+		t.Errorf("installed filters mismatch: have %d, want 5", len(filters))
+	}
+}
+
+func TestFilterQueueLimit(t *testing.T) {
+	queue := &hashQueue{timeout: time.Now(), limit: 3}
+	for i := byte(0); i < 5; i++ {
+		queue.add(common.Hash{i})
+	}
+	if _, buffered := queue.info(); buffered != 3 {
+		t.Fatalf("buffered mismatch: got %d, want 3", buffered)
+	}
+	hashes := queue.get()
+	for i, hash := range hashes {
+		if want := (common.Hash{byte(i + 2)}); hash != want {
+			t.Errorf("hash %d mismatch: got %x, want %x", i, hash, want)
+		}
+	}
+
+	logs := &logQueue{timeout: time.Now(), limit: 1}
+	logs.add(vmlog{}, vmlog{Removed: true})
+	if got := logs.get(); len(got) != 1 || !got[0].Removed {
+		t.Errorf("unexpected logs: %+v", got)
+	}
+}
+
+// Tests that pending transaction subscriptions are notified of the hashes, or of
+// the full transactions when the fullTx option is set.
+func TestPendingTransactionSubscription(t *testing.T) {
+	testPendingTransactionSubscription(t, true)
+}
+
+// Tests that without a transaction formatter, subscriptions asking for full
+// transactions are notified of the hashes.
+func TestPendingTransactionSubscriptionNoFormatter(t *testing.T) {
+	testPendingTransactionSubscription(t, false)
+}
+
+func testPendingTransactionSubscription(t *testing.T, formatter bool) {
+	db, _ := ethdb.NewMemDatabase()
+	mux := new(event.TypeMux)
+	api := NewPublicFilterAPI(db, mux, Config{MaxPerOwner: 1})
+	if formatter {
+		api.SetTransactionFormatter(func(tx *types.Transaction) interface{} {
+			return map[string]interface{}{"hash": tx.Hash(), "nonce": tx.Nonce()}
+		})
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(rpc.NewJSONCodec(serverConn), rpc.OptionMethodInvocation|rpc.OptionSubscriptions)
+
+	// Subscribe without the fullTx option, with it unset and with it set
+	out, in := json.NewEncoder(clientConn), json.NewDecoder(clientConn)
+	wantFull := make(map[string]bool)
+	for i, params := range [][]interface{}{{"newPendingTransactions"}, {"newPendingTransactions", false}, {"newPendingTransactions", true}} {
+		request := map[string]interface{}{"id": i, "method": "eth_subscribe", "jsonrpc": "2.0", "params": params}
+		if err := out.Encode(request); err != nil {
+			t.Fatal(err)
+		}
+		var response struct {
+			Result string         `json:"result"`
+			Error  *rpc.JSONError `json:"error"`
+		}
+		if err := in.Decode(&response); err != nil || response.Error != nil {
+			t.Fatalf("subscription %v failed: %v %+v", params, err, response.Error)
+		}
+		wantFull[response.Result] = formatter && len(params) > 1 && params[1] == true
+	}
+	// Subscriptions don't count towards the filter limit
+	if _, err := api.NewPendingTransactionFilter(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Transactions are notified whatever their sender, not only those of local accounts
+	key, _ := crypto.GenerateKey()
+	tx, _ := types.NewTransaction(7, common.Address{}, big.NewInt(0), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(key)
+	mux.Post(core.TxPreEvent{Tx: tx})
+
+	for range wantFull {
+		var notification struct {
+			Params struct {
+				Subscription string          `json:"subscription"`
+				Result       json.RawMessage `json:"result"`
+			} `json:"params"`
+		}
+		if err := in.Decode(&notification); err != nil {
+			t.Fatal(err)
+		}
+		full, ok := wantFull[notification.Params.Subscription]
+		if !ok {
+			t.Fatalf("notification of unknown subscription %q", notification.Params.Subscription)
+		}
+		delete(wantFull, notification.Params.Subscription)
+
+		if full {
+			var result struct {
+				Hash  common.Hash `json:"hash"`
+				Nonce uint64      `json:"nonce"`
+			}
+			if err := json.Unmarshal(notification.Params.Result, &result); err != nil || result.Hash != tx.Hash() || result.Nonce != 7 {
+				t.Errorf("full notification mismatch: %s", notification.Params.Result)
+			}
+		} else {
+			var hash common.Hash
+			if err := json.Unmarshal(notification.Params.Result, &hash); err != nil || hash != tx.Hash() {
+				t.Errorf("hash notification mismatch: have %s, want %x", notification.Params.Result, tx.Hash())
+			}
+		}
+	}
+}
+
+// Tests that a pending transaction subscription whose client doesn't keep up is
+// cancelled without blocking the filter system.
+func TestPendingTransactionSubscriptionSlowConsumer(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	mux := new(event.TypeMux)
+	api := NewPublicFilterAPI(db, mux, Config{})
+	server := rpc.NewServer()
+	server.SetSubscriptionLimits(1, rpc.DisconnectSlowConsumer)
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(rpc.NewJSONCodec(serverConn), rpc.OptionMethodInvocation|rpc.OptionSubscriptions)
+
+	request := map[string]interface{}{"id": 1, "method": "eth_subscribe", "jsonrpc": "2.0", "params": []interface{}{"newPendingTransactions"}}
+	if err := json.NewEncoder(clientConn).Encode(request); err != nil {
+		t.Fatal(err)
+	}
+	var response rpc.JSONResponse
+	if err := json.NewDecoder(clientConn).Decode(&response); err != nil || response.Error != nil {
+		t.Fatalf("subscription failed: %v %+v", err, response.Error)
+	}
+	// The client stops reading: the first notification blocks on the connection,
+	// the second is buffered and the third exceeds the buffer
+	posted := make(chan struct{})
+	go func() {
+		for i := uint64(0); i < 4; i++ {
+			mux.Post(core.TxPreEvent{Tx: types.NewTransaction(i, common.Address{}, big.NewInt(0), big.NewInt(21000), big.NewInt(1), nil)})
+		}
+		close(posted)
+	}()
+	select {
+	case <-posted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("filter system blocked by the slow subscriber")
+	}
+	for start := time.Now(); len(NewPrivateFilterAPI(api).Filters()) != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("slow subscription not cancelled: %+v", NewPrivateFilterAPI(api).Filters())
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package eth
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/event"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+const (
+	defaultForkAlertDepth = 2    // Branch depth beyond which a ForkEvent is posted
+	maxForkHeads          = 256  // Maximum number of chain heads tracked
+	maxForkWalk           = 1024 // Maximum number of ancestors looked up to find a branch's fork point
+	forkHeadLifetime      = time.Hour
+)
+
+// ChainHead is a chain head advertised by one or more peers, possibly on a
+// branch competing with the local canonical chain.
+type ChainHead struct {
+	Hash      common.Hash `json:"hash"`
+	Number    uint64      `json:"number"`
+	TD        *big.Int    `json:"td"`
+	Canonical bool        `json:"canonical"` // Head is part of the local canonical chain
+	ForkPoint uint64      `json:"forkPoint"` // Last block shared with the local canonical chain
+	Depth     uint64      `json:"depth"`     // Number of blocks on the branch since the fork point
+	FirstSeen time.Time   `json:"firstSeen"`
+	FirstPeer string      `json:"firstPeer"` // Peer the head was first received from
+	Peers     []string    `json:"peers"`     // Peers currently advertising the head
+
+	reported bool // Whether the branch was already reported as a fork
+}
+
+// ForkEvent is posted once per branch, when a branch competing with the local
+// canonical chain grows beyond the alert depth of the fork monitor.
+type ForkEvent struct {
+	Head *ChainHead
+}
+
+// forkMonitor tracks the chain heads advertised by peers, following branches
+// as they grow so forks can be observed while they happen rather than after the
+// local chain reorganises (or refuses to).
+type forkMonitor struct {
+	chain *core.BlockChain
+	db    ethdb.Database
+	mux   *event.TypeMux
+	depth uint64 // Branch depth beyond which a ForkEvent is posted
+
+	lock    sync.Mutex
+	heads   map[common.Hash]*ChainHead
+	peers   map[string]common.Hash // Head currently advertised by each peer
+	pending map[string]pendingHead // Head advertised by each peer whose header was requested
+}
+
+// pendingHead is a head advertised by a peer which is unknown locally, whose
+// header was requested from the peer.
+type pendingHead struct {
+	hash common.Hash
+	td   *big.Int
+}
+
+func newForkMonitor(chain *core.BlockChain, db ethdb.Database, mux *event.TypeMux, depth uint64) *forkMonitor {
+	return &forkMonitor{
+		chain:   chain,
+		db:      db,
+		mux:     mux,
+		depth:   depth,
+		heads:   make(map[common.Hash]*ChainHead),
+		peers:   make(map[string]common.Hash),
+		pending: make(map[string]pendingHead),
+	}
+}
+
+// observe records the head advertised by a peer along with the total difficulty
+// the peer claims for it.
+func (m *forkMonitor) observe(peer string, header *types.Header, td *big.Int) {
+	hash := header.Hash()
+	number := header.Number.Uint64()
+
+	m.lock.Lock()
+	head, known := m.heads[hash]
+	if !known {
+		head = &ChainHead{
+			Hash:      hash,
+			Number:    number,
+			TD:        new(big.Int).Set(td),
+			FirstSeen: time.Now(),
+			FirstPeer: peer,
+		}
+		head.ForkPoint = m.forkPoint(header)
+		if number > head.ForkPoint {
+			head.Depth = number - head.ForkPoint
+		}
+		if parent, ok := m.heads[header.ParentHash]; ok {
+			head.reported = parent.reported
+		}
+		m.heads[hash] = head
+	}
+	m.advertise(peer, head)
+
+	// The branch grew, the parent is no longer a head unless still advertised
+	if parent, ok := m.heads[header.ParentHash]; ok && len(parent.Peers) == 0 {
+		delete(m.heads, header.ParentHash)
+	}
+	m.prune()
+
+	// Only branches competing with a local block are forks, a branch ahead of the
+	// local head is merely not yet imported
+	var alert *ChainHead
+	if !head.reported && head.Depth > m.depth && m.chain.CurrentHeader().Number.Uint64() > head.ForkPoint &&
+		core.GetCanonicalHash(m.db, number) != hash {
+		head.reported = true
+		alert = head.copy()
+	}
+	m.lock.Unlock()
+
+	if alert != nil {
+		glog.V(logger.Warn).Warnf("Fork detected: branch of %d blocks from #%d to #%d [%x…] first seen from peer %s", alert.Depth, alert.ForkPoint, alert.Number, alert.Hash[:4], alert.FirstPeer)
+		if logger.MlogEnabled() {
+			mlogSyncForkDetected.AssignDetails(
+				alert.FirstPeer,
+				alert.Hash.Hex(),
+				alert.Number,
+				alert.TD,
+				alert.ForkPoint,
+				alert.Depth,
+			).Send(mlogSyncComponent)
+		}
+		m.mux.Post(ForkEvent{Head: alert})
+	}
+}
+
+// expect records the head advertised by a peer which is unknown locally, so
+// the header requested for it is observed when the peer delivers it.
+func (m *forkMonitor) expect(peer string, hash common.Hash, td *big.Int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.pending[peer] = pendingHead{hash: hash, td: new(big.Int).Set(td)}
+}
+
+// deliver observes a header delivered by a peer if it is the head expected from
+// the peer, reporting whether it was.
+func (m *forkMonitor) deliver(peer string, header *types.Header) bool {
+	m.lock.Lock()
+	pending, ok := m.pending[peer]
+	if !ok || pending.hash != header.Hash() {
+		m.lock.Unlock()
+		return false
+	}
+	delete(m.pending, peer)
+	m.lock.Unlock()
+
+	m.observe(peer, header, pending.td)
+	return true
+}
+
+// forkPoint returns the number of the last block the branch ending in header
+// shares with the local canonical chain. Branches unknown locally are followed
+// through the tracked heads.
+func (m *forkMonitor) forkPoint(header *types.Header) uint64 {
+	if header.Number.Sign() == 0 {
+		return 0
+	}
+	if core.GetCanonicalHash(m.db, header.Number.Uint64()) == header.Hash() {
+		return header.Number.Uint64()
+	}
+	hash, number := header.ParentHash, header.Number.Uint64()-1
+	for i := 0; i < maxForkWalk && number > 0; i++ {
+		if core.GetCanonicalHash(m.db, number) == hash {
+			return number
+		}
+		parent := m.chain.GetHeader(hash)
+		if parent == nil {
+			if tracked, ok := m.heads[hash]; ok {
+				return tracked.ForkPoint
+			}
+			return number
+		}
+		hash, number = parent.ParentHash, number-1
+	}
+	return number
+}
+
+// advertise moves the peer over to the given head.
+func (m *forkMonitor) advertise(peer string, head *ChainHead) {
+	if prev, ok := m.peers[peer]; ok {
+		if prev == head.Hash {
+			return
+		}
+		if old, ok := m.heads[prev]; ok {
+			old.Peers = removeString(old.Peers, peer)
+		}
+	}
+	m.peers[peer] = head.Hash
+	head.Peers = append(head.Peers, peer)
+}
+
+// drop forgets the head advertised by a disconnected peer.
+func (m *forkMonitor) drop(peer string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if hash, ok := m.peers[peer]; ok {
+		if head, ok := m.heads[hash]; ok {
+			head.Peers = removeString(head.Peers, peer)
+		}
+		delete(m.peers, peer)
+	}
+	delete(m.pending, peer)
+}
+
+// prune removes the heads no longer advertised by any peer once they expire,
+// and the oldest such heads while too many are tracked.
+func (m *forkMonitor) prune() {
+	for hash, head := range m.heads {
+		if len(head.Peers) == 0 && time.Since(head.FirstSeen) > forkHeadLifetime {
+			delete(m.heads, hash)
+		}
+	}
+	for len(m.heads) > maxForkHeads {
+		var oldest *ChainHead
+		for _, head := range m.heads {
+			if len(head.Peers) == 0 && (oldest == nil || head.FirstSeen.Before(oldest.FirstSeen)) {
+				oldest = head
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		delete(m.heads, oldest.Hash)
+	}
+}
+
+// Heads returns the tracked chain heads, highest total difficulty first.
+func (m *forkMonitor) Heads() []*ChainHead {
+	m.lock.Lock()
+	heads := make([]*ChainHead, 0, len(m.heads))
+	for _, head := range m.heads {
+		heads = append(heads, head.copy())
+	}
+	m.lock.Unlock()
+
+	for _, head := range heads {
+		head.Canonical = core.GetCanonicalHash(m.db, head.Number) == head.Hash
+	}
+	sort.Sort(chainHeadsByTD(heads))
+	return heads
+}
+
+// chainHeadsByTD sorts chain heads by descending total difficulty and number.
+type chainHeadsByTD []*ChainHead
+
+func (h chainHeadsByTD) Len() int      { return len(h) }
+func (h chainHeadsByTD) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h chainHeadsByTD) Less(i, j int) bool {
+	if c := h[i].TD.Cmp(h[j].TD); c != 0 {
+		return c > 0
+	}
+	return h[i].Number > h[j].Number
+}
+
+func (h *ChainHead) copy() *ChainHead {
+	cpy := *h
+	cpy.TD = new(big.Int).Set(h.TD)
+	cpy.Peers = append([]string(nil), h.Peers...)
+	return &cpy
+}
+
+func removeString(list []string, s string) []string {
+	for i, item := range list {
+		if item == s {
+			return append(list[:i:i], list[i+1:]...)
+		}
+	}
+	return list
+}
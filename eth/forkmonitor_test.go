@@ -0,0 +1,163 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/event"
+)
+
+// newForkMonitorTester creates a fork monitor over a local chain of the given
+// length, along with a competing branch of the given length forking off after
+// the block numbered forkPoint. The branch isn't known locally.
+func newForkMonitorTester(t *testing.T, blocks, forkPoint, branch int) (*forkMonitor, []*types.Block, []*types.Block, event.Subscription) {
+	api, chain := newTestBlockChainAPI(blocks, nil)
+
+	parent := chain.Genesis()
+	if forkPoint > 0 {
+		parent = chain.GetBlockByNumber(uint64(forkPoint))
+	}
+	fork, _ := core.GenerateChain(chain.Config(), parent, api.chainDb, branch, func(i int, block *core.BlockGen) {
+		block.SetCoinbase(common.Address{0x01})
+	})
+	local := make([]*types.Block, blocks)
+	for i := range local {
+		local[i] = chain.GetBlockByNumber(uint64(i + 1))
+	}
+	mux := new(event.TypeMux)
+	return newForkMonitor(chain, api.chainDb, mux, defaultForkAlertDepth), local, fork, mux.Subscribe(ForkEvent{})
+}
+
+// observeHead observes a head advertised by a peer, returning the fork events
+// posted meanwhile.
+func observeHead(monitor *forkMonitor, events event.Subscription, peer string, header *types.Header, td *big.Int) []*ChainHead {
+	done := make(chan struct{})
+	go func() {
+		monitor.observe(peer, header, td)
+		close(done)
+	}()
+	var alerts []*ChainHead
+	for {
+		select {
+		case ev := <-events.Chan():
+			alerts = append(alerts, ev.Data.(ForkEvent).Head)
+		case <-done:
+			return alerts
+		}
+	}
+}
+
+// Tests that a branch unknown locally is followed as it grows, and reported once
+// it gets deeper than the alert depth.
+func TestForkMonitorBranch(t *testing.T) {
+	monitor, local, fork, events := newForkMonitorTester(t, 10, 5, 4)
+	defer events.Unsubscribe()
+
+	observeHead(monitor, events, "local", local[9].Header(), big.NewInt(100))
+	for i, block := range fork {
+		alerts := observeHead(monitor, events, "forker", block.Header(), big.NewInt(int64(90+i)))
+		switch {
+		case uint64(i) == defaultForkAlertDepth:
+			if len(alerts) != 1 {
+				t.Fatalf("block %d: fork events mismatch: have %d, want 1", i, len(alerts))
+			}
+			if head := alerts[0]; head.Hash != block.Hash() || head.ForkPoint != 5 || head.Depth != defaultForkAlertDepth+1 || head.FirstPeer != "forker" {
+				t.Errorf("fork event mismatch: %+v", head)
+			}
+		case len(alerts) != 0:
+			t.Fatalf("block %d: unexpected fork events: %+v", i, alerts)
+		}
+	}
+	// The branch is a single head, listed after the heavier canonical one
+	heads := monitor.Heads()
+	if len(heads) != 2 {
+		t.Fatalf("heads count mismatch: have %d, want 2", len(heads))
+	}
+	if heads[0].Hash != local[9].Hash() || !heads[0].Canonical || heads[0].Depth != 0 {
+		t.Errorf("canonical head mismatch: %+v", heads[0])
+	}
+	if heads[1].Hash != fork[3].Hash() || heads[1].Canonical || heads[1].ForkPoint != 5 || heads[1].Depth != 4 {
+		t.Errorf("branch head mismatch: %+v", heads[1])
+	}
+}
+
+// Tests that a branch ahead of the local head isn't reported, as it only wasn't
+// imported yet.
+func TestForkMonitorAhead(t *testing.T) {
+	monitor, _, ahead, events := newForkMonitorTester(t, 2, 2, 5)
+	defer events.Unsubscribe()
+
+	for i, block := range ahead {
+		if alerts := observeHead(monitor, events, "peer", block.Header(), block.Difficulty()); len(alerts) != 0 {
+			t.Fatalf("block %d: unexpected fork events: %+v", i, alerts)
+		}
+	}
+	if heads := monitor.Heads(); len(heads) != 1 || heads[0].ForkPoint != 2 || heads[0].Depth != 5 {
+		t.Errorf("heads mismatch: %+v", heads)
+	}
+}
+
+// Tests that a head advertised in a handshake but unknown locally is tracked
+// once the peer delivers its header.
+func TestForkMonitorDeliver(t *testing.T) {
+	monitor, _, fork, _ := newForkMonitorTester(t, 6, 2, 3)
+
+	monitor.expect("peer", fork[2].Hash(), big.NewInt(50))
+	if monitor.deliver("peer", fork[1].Header()) {
+		t.Error("header other than the advertised head delivered")
+	}
+	if monitor.deliver("other", fork[2].Header()) {
+		t.Error("head delivered by a peer not advertising it")
+	}
+	if len(monitor.Heads()) != 0 {
+		t.Fatal("head tracked before its header was delivered")
+	}
+	if !monitor.deliver("peer", fork[2].Header()) {
+		t.Fatal("advertised head not delivered")
+	}
+	// The head's parent was never seen, so it is assumed to be the fork point
+	heads := monitor.Heads()
+	if len(heads) != 1 || heads[0].Hash != fork[2].Hash() || heads[0].TD.Int64() != 50 || heads[0].ForkPoint != 4 || heads[0].Depth != 1 {
+		t.Fatalf("heads mismatch: %+v", heads)
+	}
+	if monitor.deliver("peer", fork[2].Header()) {
+		t.Error("head delivered twice")
+	}
+}
+
+// Tests that heads follow the peers advertising them, and are forgotten once
+// unadvertised for long enough.
+func TestForkMonitorPeers(t *testing.T) {
+	monitor, local, fork, events := newForkMonitorTester(t, 4, 2, 2)
+	defer events.Unsubscribe()
+
+	observeHead(monitor, events, "a", local[3].Header(), big.NewInt(10))
+	observeHead(monitor, events, "b", local[3].Header(), big.NewInt(10))
+	observeHead(monitor, events, "b", fork[1].Header(), big.NewInt(9))
+	monitor.expect("c", fork[1].Hash(), big.NewInt(9))
+
+	heads := monitor.Heads()
+	if len(heads) != 2 || len(heads[0].Peers) != 1 || heads[0].Peers[0] != "a" || len(heads[1].Peers) != 1 || heads[1].Peers[0] != "b" {
+		t.Fatalf("heads mismatch: %+v", heads)
+	}
+	monitor.drop("b")
+	monitor.drop("c")
+	if monitor.deliver("c", fork[1].Header()) {
+		t.Error("head delivered by a dropped peer")
+	}
+	if heads := monitor.Heads(); len(heads) != 2 || len(heads[1].Peers) != 0 {
+		t.Fatalf("heads mismatch after drop: %+v", heads)
+	}
+	// Expired heads are pruned only if no peer advertises them anymore
+	for _, head := range monitor.heads {
+		head.FirstSeen = time.Now().Add(-2 * forkHeadLifetime)
+	}
+	monitor.prune()
+	if heads := monitor.Heads(); len(heads) != 1 || heads[0].Hash != local[3].Hash() {
+		t.Errorf("heads mismatch after pruning: %+v", heads)
+	}
+}
@@ -1,10 +1,14 @@
 package eth
 
 import (
+	"fmt"
 	"math/big"
 	"math/rand"
+	"sort"
 	"sync"
 
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/hexutil"
 	"github.com/openether/ethcore/core"
 	"github.com/openether/ethcore/core/types"
 	"github.com/openether/ethcore/logger"
@@ -15,25 +19,72 @@ const (
 	gpoProcessPastBlocks = 100
 
 	gpoDefaultMinGasPrice = 10000000000000
+
+	gpoFeeHistoryBlocks = 1024 // Number of recent blocks whose gas price statistics are kept
+	maxFeeHistory       = 1024 // Maximum number of blocks a fee history can span
+	maxFeePercentiles   = 100  // Maximum number of percentiles a fee history can report
 )
 
 type blockPriceInfo struct {
 	baseGasPrice *big.Int
 }
 
+// txGasPrice is the gas price a transaction paid and the gas it used.
+type txGasPrice struct {
+	price   *big.Int
+	gasUsed *big.Int
+}
+
+// blockFees are the gas prices paid in a block, cheapest first.
+type blockFees struct {
+	hash         common.Hash
+	gasUsedRatio float64
+	gasUsed      *big.Int
+	txs          []txGasPrice
+}
+
+// percentile returns the gas price paid for the given percentile of the gas used
+// in the block, zero for empty blocks.
+func (f *blockFees) percentile(p float64) *big.Int {
+	if len(f.txs) == 0 {
+		return new(big.Int)
+	}
+	threshold := new(big.Float).Mul(new(big.Float).SetInt(f.gasUsed), big.NewFloat(p/100))
+	sum := new(big.Int)
+	for _, tx := range f.txs {
+		sum.Add(sum, tx.gasUsed)
+		if new(big.Float).SetInt(sum).Cmp(threshold) >= 0 {
+			return tx.price
+		}
+	}
+	return f.txs[len(f.txs)-1].price
+}
+
+// FeeHistory is the gas price history of a range of blocks: the gas prices paid
+// at the requested percentiles of the gas used in each block, and how full each
+// block was.
+type FeeHistory struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"` // Gas prices by block, then percentile
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
 // GasPriceOracle recommends gas prices based on the content of recent
 // blocks.
 type GasPriceOracle struct {
 	eth           *Ethereum
 	initOnce      sync.Once
 	minPrice      *big.Int
-	lastBaseMutex sync.Mutex
+	lastBaseMutex sync.Mutex // Also protects minPrice and minBase, which can change at runtime
 	lastBase      *big.Int
 
 	// state of listenLoop
 	blocks                        map[uint64]*blockPriceInfo
 	firstProcessed, lastProcessed uint64
 	minBase                       *big.Int
+
+	feesLock sync.RWMutex
+	fees     map[uint64]*blockFees // Gas price statistics of recent blocks, by number
 }
 
 // NewGasPriceOracle returns a new oracle.
@@ -42,19 +93,44 @@ func NewGasPriceOracle(eth *Ethereum) *GasPriceOracle {
 	if minprice == nil {
 		minprice = big.NewInt(gpoDefaultMinGasPrice)
 	}
-	minbase := new(big.Int).Mul(minprice, big.NewInt(100))
-	if eth.GpobaseCorrectionFactor > 0 {
-		minbase = minbase.Div(minbase, big.NewInt(int64(eth.GpobaseCorrectionFactor)))
-	}
 	return &GasPriceOracle{
 		eth:      eth,
 		blocks:   make(map[uint64]*blockPriceInfo),
-		minBase:  minbase,
+		fees:     make(map[uint64]*blockFees),
+		minBase:  minBasePrice(minprice, eth.GpobaseCorrectionFactor),
 		minPrice: minprice,
 		lastBase: minprice,
 	}
 }
 
+// minBasePrice returns the base price below which the base price doesn't
+// step down, so that the corrected suggestion stays above minPrice.
+func minBasePrice(minPrice *big.Int, correctionFactor int) *big.Int {
+	minbase := new(big.Int).Mul(minPrice, big.NewInt(100))
+	if correctionFactor > 0 {
+		minbase = minbase.Div(minbase, big.NewInt(int64(correctionFactor)))
+	}
+	return minbase
+}
+
+// SetMinPrice sets the lowest gas price the oracle suggests. It only affects
+// suggestions, the transaction pool has its own floor, see core.TxPool.SetMinGasPrice.
+func (self *GasPriceOracle) SetMinPrice(price *big.Int) {
+	self.lastBaseMutex.Lock()
+	defer self.lastBaseMutex.Unlock()
+
+	self.minPrice = new(big.Int).Set(price)
+	self.minBase = minBasePrice(self.minPrice, self.eth.GpobaseCorrectionFactor)
+}
+
+// MinPrice returns the lowest gas price the oracle suggests.
+func (self *GasPriceOracle) MinPrice() *big.Int {
+	self.lastBaseMutex.Lock()
+	defer self.lastBaseMutex.Unlock()
+
+	return new(big.Int).Set(self.minPrice)
+}
+
 func (gpo *GasPriceOracle) init() {
 	gpo.initOnce.Do(func() {
 		gpo.processPastBlocks(gpo.eth.BlockChain())
@@ -101,8 +177,12 @@ func (self *GasPriceOracle) processBlock(block *types.Block) {
 	if i > self.lastProcessed {
 		self.lastProcessed = i
 	}
+	self.recordFees(block)
+
+	self.lastBaseMutex.Lock()
+	lastBase, minBase := self.minPrice, self.minBase
+	self.lastBaseMutex.Unlock()
 
-	lastBase := self.minPrice
 	bpl := self.blocks[i-1]
 	if bpl != nil {
 		lastBase = bpl.baseGasPrice
@@ -127,8 +207,8 @@ func (self *GasPriceOracle) processBlock(block *types.Block) {
 	newBase := new(big.Int).Mul(lastBase, big.NewInt(1000000+crand))
 	newBase.Div(newBase, big.NewInt(1000000))
 
-	if newBase.Cmp(self.minBase) < 0 {
-		newBase = self.minBase
+	if newBase.Cmp(minBase) < 0 {
+		newBase = minBase
 	}
 
 	bpi := self.blocks[i]
@@ -181,14 +261,128 @@ func (self *GasPriceOracle) SuggestPrice() *big.Int {
 	self.init()
 	self.lastBaseMutex.Lock()
 	price := new(big.Int).Set(self.lastBase)
+	minPrice := self.minPrice
 	self.lastBaseMutex.Unlock()
 
 	price.Mul(price, big.NewInt(int64(self.eth.GpobaseCorrectionFactor)))
 	price.Div(price, big.NewInt(100))
-	if price.Cmp(self.minPrice) < 0 {
-		price.Set(self.minPrice)
+	if price.Cmp(minPrice) < 0 {
+		price.Set(minPrice)
 	} else if self.eth.GpoMaxGasPrice != nil && price.Cmp(self.eth.GpoMaxGasPrice) > 0 {
 		price.Set(self.eth.GpoMaxGasPrice)
 	}
 	return price
 }
+
+// recordFees keeps the gas price statistics of a new block, dropping those of
+// blocks too old to be asked for often.
+func (self *GasPriceOracle) recordFees(block *types.Block) {
+	fees, err := self.computeFees(block)
+	if err != nil {
+		return
+	}
+	number := block.NumberU64()
+
+	self.feesLock.Lock()
+	defer self.feesLock.Unlock()
+
+	self.fees[number] = fees
+	if number >= gpoFeeHistoryBlocks {
+		delete(self.fees, number-gpoFeeHistoryBlocks)
+	}
+}
+
+// blockFees returns the gas price statistics of a block, as recorded if it's
+// recent enough or else computed from its receipts.
+func (self *GasPriceOracle) blockFees(block *types.Block) (*blockFees, error) {
+	self.feesLock.RLock()
+	fees := self.fees[block.NumberU64()]
+	self.feesLock.RUnlock()
+
+	if fees != nil && fees.hash == block.Hash() {
+		return fees, nil
+	}
+	return self.computeFees(block)
+}
+
+func (self *GasPriceOracle) computeFees(block *types.Block) (*blockFees, error) {
+	txs := block.Transactions()
+	receipts := core.GetBlockReceipts(self.eth.ChainDb(), block.Hash())
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("receipts of block #%d missing", block.NumberU64())
+	}
+	fees := &blockFees{hash: block.Hash(), gasUsed: new(big.Int), txs: make([]txGasPrice, len(txs))}
+	for i, tx := range txs {
+		used := new(big.Int).Set(receipts[i].CumulativeGasUsed)
+		if i > 0 {
+			used.Sub(used, receipts[i-1].CumulativeGasUsed)
+		}
+		fees.txs[i] = txGasPrice{price: tx.GasPrice(), gasUsed: used}
+		fees.gasUsed.Add(fees.gasUsed, used)
+	}
+	sort.SliceStable(fees.txs, func(i, j int) bool { return fees.txs[i].price.Cmp(fees.txs[j].price) < 0 })
+
+	if limit := block.GasLimit(); limit.Sign() > 0 {
+		fees.gasUsedRatio, _ = new(big.Float).Quo(new(big.Float).SetInt(fees.gasUsed), new(big.Float).SetInt(limit)).Float64()
+	}
+	return fees, nil
+}
+
+// FeeHistory returns the gas price statistics of the count blocks up to and
+// including newest, with the gas prices paid at the given percentiles of the gas
+// used in each block. Percentiles must be ascending, between 0 and 100.
+func (self *GasPriceOracle) FeeHistory(count uint64, newest *types.Block, percentiles []float64) (*FeeHistory, error) {
+	self.init()
+
+	if count == 0 {
+		return nil, fmt.Errorf("block count must be positive")
+	}
+	if count > maxFeeHistory {
+		return nil, fmt.Errorf("block count %d above maximum %d", count, maxFeeHistory)
+	}
+	if len(percentiles) > maxFeePercentiles {
+		return nil, fmt.Errorf("%d percentiles above maximum %d", len(percentiles), maxFeePercentiles)
+	}
+	for i, p := range percentiles {
+		if p < 0 || p > 100 {
+			return nil, fmt.Errorf("percentile %v out of range [0, 100]", p)
+		}
+		if i > 0 && p < percentiles[i-1] {
+			return nil, fmt.Errorf("percentiles not ascending: %v after %v", p, percentiles[i-1])
+		}
+	}
+	if count > newest.NumberU64()+1 {
+		count = newest.NumberU64() + 1
+	}
+	oldest := newest.NumberU64() + 1 - count
+
+	history := &FeeHistory{
+		OldestBlock:  (*hexutil.Big)(new(big.Int).SetUint64(oldest)),
+		GasUsedRatio: make([]float64, count),
+	}
+	if len(percentiles) > 0 {
+		history.Reward = make([][]*hexutil.Big, count)
+	}
+	chain := self.eth.BlockChain()
+	for i, block := uint64(count), newest; i > 0; i-- {
+		if block == nil {
+			return nil, fmt.Errorf("block #%d missing", oldest+i-1)
+		}
+		fees, err := self.blockFees(block)
+		if err != nil {
+			return nil, err
+		}
+		history.GasUsedRatio[i-1] = fees.gasUsedRatio
+		if history.Reward != nil {
+			rewards := make([]*hexutil.Big, len(percentiles))
+			for j, p := range percentiles {
+				rewards[j] = (*hexutil.Big)(fees.percentile(p))
+			}
+			history.Reward[i-1] = rewards
+		}
+		if i > 1 {
+			block = chain.GetBlock(block.ParentHash())
+		}
+	}
+	return history, nil
+}
@@ -0,0 +1,204 @@
+package eth
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/common/hexutil"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/rpc"
+)
+
+func TestBlockFeesPercentile(t *testing.T) {
+	fees := &blockFees{
+		gasUsed: big.NewInt(100000),
+		txs: []txGasPrice{
+			{big.NewInt(1), big.NewInt(21000)},
+			{big.NewInt(5), big.NewInt(50000)},
+			{big.NewInt(9), big.NewInt(29000)},
+		},
+	}
+	tests := []struct {
+		percentile float64
+		price      int64
+	}{
+		{0, 1},
+		{21, 1},
+		{21.5, 5},
+		{71, 5},
+		{90, 9},
+		{100, 9},
+	}
+	for _, tt := range tests {
+		if price := fees.percentile(tt.percentile); price.Cmp(big.NewInt(tt.price)) != 0 {
+			t.Errorf("percentile %v: price %v, want %d", tt.percentile, price, tt.price)
+		}
+	}
+	if price := new(blockFees).percentile(50); price.Sign() != 0 {
+		t.Errorf("empty block: price %v, want 0", price)
+	}
+}
+
+// newFeeHistoryOracle creates a gas price oracle over a test chain of the given
+// length, whose block #n holds n transfers from the bank paying 1, 2, ... wei of
+// gas price.
+func newFeeHistoryOracle(t *testing.T, blocks int) (*GasPriceOracle, *core.BlockChain) {
+	chain, db, evmux := newTestChainWithReceipts(t, blocks, func(i int, block *core.BlockGen) {
+		for j := 0; j <= i; j++ {
+			tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.HexToAddress("0x1234"), big.NewInt(1), core.TxGas, big.NewInt(int64(j+1)), nil).SignECDSA(testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	return NewGasPriceOracle(&Ethereum{blockchain: chain, chainDb: db, eventMux: evmux}), chain
+}
+
+// Tests that fee histories span the requested blocks, clamped at the genesis
+// block, and report the gas prices paid at the requested percentiles.
+func TestFeeHistory(t *testing.T) {
+	gpo, chain := newFeeHistoryOracle(t, 3)
+
+	tests := []struct {
+		count   uint64
+		newest  uint64
+		oldest  int64
+		rewards [][]int64 // Gas prices at the 0th and 100th percentiles
+	}{
+		{1, 3, 3, [][]int64{{1, 3}}},
+		{2, 3, 2, [][]int64{{1, 2}, {1, 3}}},
+		{2, 1, 0, [][]int64{{0, 0}, {1, 1}}},
+		{10, 3, 0, [][]int64{{0, 0}, {1, 1}, {1, 2}, {1, 3}}},
+		{maxFeeHistory, 2, 0, [][]int64{{0, 0}, {1, 1}, {1, 2}}},
+	}
+	for _, tt := range tests {
+		history, err := gpo.FeeHistory(tt.count, chain.GetBlockByNumber(tt.newest), []float64{0, 100})
+		if err != nil {
+			t.Errorf("%d up to #%d: failed to get history: %v", tt.count, tt.newest, err)
+			continue
+		}
+		if history.OldestBlock.ToInt().Int64() != tt.oldest {
+			t.Errorf("%d up to #%d: oldest block mismatch: have %v, want %d", tt.count, tt.newest, history.OldestBlock.ToInt(), tt.oldest)
+		}
+		if len(history.GasUsedRatio) != len(tt.rewards) || len(history.Reward) != len(tt.rewards) {
+			t.Errorf("%d up to #%d: history length mismatch: have %d ratios, %d rewards, want %d", tt.count, tt.newest, len(history.GasUsedRatio), len(history.Reward), len(tt.rewards))
+			continue
+		}
+		for i, want := range tt.rewards {
+			number := uint64(tt.oldest) + uint64(i)
+			for j, price := range want {
+				if have := history.Reward[i][j].ToInt(); have.Cmp(big.NewInt(price)) != 0 {
+					t.Errorf("%d up to #%d: block #%d reward %d mismatch: have %v, want %d", tt.count, tt.newest, number, j, have, price)
+				}
+			}
+			limit := new(big.Float).SetInt(chain.GetBlockByNumber(number).GasLimit())
+			ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(new(big.Int).Mul(core.TxGas, big.NewInt(int64(number)))), limit).Float64()
+			if history.GasUsedRatio[i] != ratio {
+				t.Errorf("%d up to #%d: block #%d gas used ratio mismatch: have %v, want %v", tt.count, tt.newest, number, history.GasUsedRatio[i], ratio)
+			}
+		}
+	}
+	// Without percentiles, only the gas used ratios are reported
+	history, err := gpo.FeeHistory(2, chain.CurrentBlock(), nil)
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if history.Reward != nil || len(history.GasUsedRatio) != 2 {
+		t.Errorf("history without percentiles mismatch: %d rewards, %d ratios", len(history.Reward), len(history.GasUsedRatio))
+	}
+}
+
+// Tests that invalid fee history requests are refused.
+func TestFeeHistoryInvalid(t *testing.T) {
+	gpo, chain := newFeeHistoryOracle(t, 1)
+
+	tooMany := make([]float64, maxFeePercentiles+1)
+	tests := []struct {
+		count       uint64
+		percentiles []float64
+		err         string
+	}{
+		{0, nil, "block count must be positive"},
+		{maxFeeHistory + 1, nil, "above maximum"},
+		{1, tooMany, "percentiles above maximum"},
+		{1, []float64{-1}, "out of range"},
+		{1, []float64{101}, "out of range"},
+		{1, []float64{50, 10}, "not ascending"},
+	}
+	for _, tt := range tests {
+		if _, err := gpo.FeeHistory(tt.count, chain.CurrentBlock(), tt.percentiles); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%d blocks at %v: error mismatch: have %v, want %q", tt.count, tt.percentiles, err, tt.err)
+		}
+	}
+}
+
+// Tests that the API refuses block counts out of range instead of truncating them.
+func TestFeeHistoryCountRange(t *testing.T) {
+	gpo, _ := newFeeHistoryOracle(t, 1)
+	api := &PublicEthereumAPI{e: gpo.eth, gpo: gpo}
+
+	tests := []struct {
+		count *big.Int
+		err   string
+	}{
+		{big.NewInt(-1), "must be positive"},
+		{new(big.Int).Lsh(big.NewInt(1), 63), "above maximum"},
+		{new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 64), big.NewInt(1)), "above maximum"}, // truncates to 1
+	}
+	for _, tt := range tests {
+		if _, err := api.FeeHistory(*rpc.NewHexNumber(tt.count), rpc.LatestBlockNumber, nil); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("count %v: error mismatch: have %v, want %q", tt.count, err, tt.err)
+		}
+	}
+	history, err := api.FeeHistory(*rpc.NewHexNumber(1), rpc.LatestBlockNumber, nil)
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if len(history.GasUsedRatio) != 1 {
+		t.Errorf("history length mismatch: have %d, want 1", len(history.GasUsedRatio))
+	}
+}
+
+// Tests that the history of blocks whose receipts are missing fails, instead of
+// reporting them empty.
+func TestFeeHistoryReceiptsMissing(t *testing.T) {
+	gpo, chain := newFeeHistoryOracle(t, 3)
+	core.DeleteBlockReceipts(gpo.eth.ChainDb(), chain.GetBlockByNumber(2).Hash())
+
+	if _, err := gpo.FeeHistory(1, chain.CurrentBlock(), []float64{50}); err != nil {
+		t.Errorf("history of a block with receipts failed: %v", err)
+	}
+	if _, err := gpo.FeeHistory(2, chain.CurrentBlock(), []float64{50}); err == nil || !strings.Contains(err.Error(), "receipts of block #2 missing") {
+		t.Errorf("error mismatch: have %v, want missing receipts of block #2", err)
+	}
+}
+
+// Tests that the minimum price of the oracle and the floor of the pool are set
+// independently of each other.
+func TestGasPriceFloorSeparation(t *testing.T) {
+	gpo, chain := newFeeHistoryOracle(t, 0)
+	ethereum := gpo.eth
+	ethereum.gpo = gpo
+	ethereum.txPool = core.NewTxPool(chain.Config(), ethereum.EventMux(), chain.State, func() *big.Int { return chain.CurrentBlock().GasLimit() })
+	ethereum.txPool.SetMinGasPrice(big.NewInt(10))
+
+	NewPrivateAdminAPI(ethereum).SetGpoMinGasPrice(hexutil.Big(*big.NewInt(5000)))
+	if min := gpo.MinPrice(); min.Int64() != 5000 {
+		t.Errorf("oracle minimum mismatch: have %v, want 5000", min)
+	}
+	if price := gpo.SuggestPrice(); price.Int64() < 5000 {
+		t.Errorf("suggested price %v below the oracle minimum 5000", price)
+	}
+	if floor := ethereum.txPool.MinGasPrice(); floor.Int64() != 10 {
+		t.Errorf("pool floor changed by the oracle minimum: have %v, want 10", floor)
+	}
+
+	NewPrivateTxPoolAPI(ethereum).SetMinGasPrice(hexutil.Big(*big.NewInt(20)))
+	if floor := NewPublicTxPoolAPI(ethereum).MinGasPrice(); floor.ToInt().Int64() != 20 {
+		t.Errorf("pool floor mismatch: have %v, want 20", floor.ToInt())
+	}
+	if min := gpo.MinPrice(); min.Int64() != 5000 {
+		t.Errorf("oracle minimum changed by the pool floor: have %v, want 5000", min)
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/openether/ethcore/common"
 	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/forkid"
 	"github.com/openether/ethcore/core/types"
 	"github.com/openether/ethcore/eth/downloader"
 	"github.com/openether/ethcore/eth/fetcher"
@@ -28,6 +29,9 @@ const (
 	softResponseLimit = 2 * 1024 * 1024 // Target maximum size of returned blocks, headers or node data.
 	estHeaderRlpSize  = 500             // Approximate size of an RLP encoded block header
 
+	maxRecentTxs      = 65536           // Maximum transaction hashes kept in the shared recently-seen cache
+	recentTxsLifetime = 5 * time.Minute // Time after which a transaction may be processed again
+
 	// txChanSize is the size of channel listening to NewTxsEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
@@ -41,8 +45,56 @@ var (
 // not compatible (low protocol version restrictions and high requirements).
 var errIncompatibleConfig = errors.New("incompatible configuration")
 
+// respError is an error handling a message of a peer, classified by code.
+type respError struct {
+	code  errCode
+	msg   string
+	cause error // Last error formatted into the message, if any
+}
+
+func (e *respError) Error() string { return e.msg }
+
+// Cause returns the last error formatted into the message, or nil.
+func (e *respError) Cause() error { return e.cause }
+
 func errResp(code errCode, format string, v ...interface{}) error {
-	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+	err := &respError{code: code, msg: fmt.Sprintf("%v - %v", code, fmt.Sprintf(format, v...))}
+	for _, arg := range v {
+		if cause, ok := arg.(error); ok {
+			err.cause = cause
+		}
+	}
+	return err
+}
+
+// reportMsgFault records a message rejected as undecodable or oversized against
+// the peer that sent it. Well formed encodings which just aren't supported, like
+// typed transactions, aren't faults.
+func reportMsgFault(p *peer, err error) {
+	if err, ok := err.(*respError); ok {
+		switch err.code {
+		case ErrDecode:
+			if _, unsupported := errCause(err).(*types.TxTypeError); unsupported {
+				return
+			}
+			p.ReportMsgFault(p2p.MsgMalformed)
+		case ErrMsgTooLarge:
+			p.ReportMsgFault(p2p.MsgOversized)
+		}
+	}
+}
+
+// errCause returns the innermost error err originates from.
+func errCause(err error) error {
+	for {
+		wrapper, ok := err.(interface {
+			Cause() error
+		})
+		if !ok || wrapper.Cause() == nil {
+			return err
+		}
+		err = wrapper.Cause()
+	}
 }
 
 type ProtocolManager struct {
@@ -59,7 +111,19 @@ type ProtocolManager struct {
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
+	txFetcher  *fetcher.TxFetcher
 	peers      *peerSet
+	recentTxs  *knownCache // Transactions recently received from any peer, shared to avoid reprocessing
+
+	syncMinPeers int             // Minimum number of peers ahead of us on the same head before accepting a sync target
+	syncTdMargin *big.Int        // Minimum total difficulty lead a sync target must have over us
+	serveLimits  ServeLimits     // Limits on the chain data served to each peer
+	nodeData     *nodeDataServer // Read path serving the state entries requested by peers
+	forks        *forkMonitor    // Tracker of the chain heads advertised by peers
+	forkFilter   forkid.Filter   // Check of the fork IDs advertised by eth/65 peers
+	blockStats   *blockStats     // Propagation delays of new blocks
+
+	privateRelays map[discover.NodeID]bool // Peers trusted with the private transactions, set before starting
 
 	SubProtocols []p2p.Protocol
 
@@ -83,18 +147,28 @@ type ProtocolManager struct {
 func NewProtocolManager(config *core.ChainConfig, mode downloader.SyncMode, networkId uint64, mux *event.TypeMux, txpool txPool, blockchain *core.BlockChain, chaindb ethdb.Database) (*ProtocolManager, error) {
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
-		networkId:   networkId,
-		eventMux:    mux,
-		txpool:      txpool,
-		blockchain:  blockchain,
-		chaindb:     chaindb,
-		chainConfig: config,
-		peers:       newPeerSet(),
-		newPeerCh:   make(chan *peer),
-		noMorePeers: make(chan struct{}),
-		txsyncCh:    make(chan *txsync),
-		quitSync:    make(chan struct{}),
+		networkId:    networkId,
+		eventMux:     mux,
+		txpool:       txpool,
+		blockchain:   blockchain,
+		chaindb:      chaindb,
+		chainConfig:  config,
+		peers:        newPeerSet(),
+		recentTxs:    newKnownCache(maxRecentTxs, recentTxsLifetime),
+		syncMinPeers: defaultSyncMinPeers,
+		syncTdMargin: new(big.Int),
+		serveLimits:  DefaultServeLimits,
+		nodeData:     newNodeDataServer(chaindb, defaultNodeDataCache, defaultNodeDataReaders),
+		forks:        newForkMonitor(blockchain, chaindb, mux, defaultForkAlertDepth),
+		blockStats:   newBlockStats(),
+		newPeerCh:    make(chan *peer),
+		noMorePeers:  make(chan struct{}),
+		txsyncCh:     make(chan *txsync),
+		quitSync:     make(chan struct{}),
 	}
+	manager.forkFilter = forkid.NewFilter(config, blockchain.Genesis().Hash(), func() uint64 {
+		return blockchain.CurrentHeader().Number.Uint64()
+	})
 
 	// Figure out whether to allow fast sync or not
 	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
@@ -177,6 +251,7 @@ func NewProtocolManager(config *core.ChainConfig, mode downloader.SyncMode, netw
 		return nil
 	}
 	manager.fetcher = fetcher.New(mux, blockchain.GetBlock, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
+	manager.txFetcher = fetcher.NewTxFetcher(manager.knownTx)
 
 	return manager, nil
 }
@@ -196,6 +271,8 @@ func (pm *ProtocolManager) removePeer(id string) {
 
 	// Unregister the peer from the downloader and Ethereum peer set
 	pm.downloader.UnregisterPeer(id)
+	pm.txFetcher.Drop(id)
+	pm.forks.drop(id)
 	if err := pm.peers.Unregister(id); err != nil {
 		glog.V(logger.Error).Infoln("Removal failed:", err)
 	}
@@ -246,7 +323,7 @@ func (pm *ProtocolManager) Stop() {
 }
 
 func (pm *ProtocolManager) newPeer(pv int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
-	return newPeer(pv, p, newMeteredMsgWriter(rw))
+	return newPeer(pv, p, newMeteredMsgWriter(rw), pm.serveLimits)
 }
 
 // handle is the callback invoked to manage the life cycle of an eth peer. When
@@ -261,7 +338,8 @@ func (pm *ProtocolManager) handle(p *peer) error {
 
 	// Execute the Ethereum handshake
 	td, head, genesis := pm.blockchain.Status()
-	if err := p.Handshake(pm.networkId, td, head, genesis); err != nil {
+	forkID := forkid.NewID(pm.chainConfig, genesis, pm.blockchain.CurrentHeader().Number.Uint64())
+	if err := p.Handshake(pm.networkId, td, head, genesis, forkID, pm.forkFilter); err != nil {
 		glog.V(logger.Debug).Infof("handler: %s ->handshakefailed err=%v", p, err)
 		return err
 	}
@@ -292,7 +370,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	// after this will be sent via broadcasts.
 	pm.syncTransactions(p)
 
-	pHead, _ := p.Head()
+	pHead, pTd := p.Head()
 	if headerN, doValidate := pm.getRequiredHashBlockNumber(head, pHead); doValidate {
 		// Request the peer's fork block header for extra-dat
 		if err := p.RequestHeadersByNumber(headerN, 1, 0, false); err != nil {
@@ -313,10 +391,22 @@ func (pm *ProtocolManager) handle(p *peer) error {
 			}
 		}()
 	}
+	// Track the peer's head, fetching its header if unknown so branches never
+	// imported locally are followed too
+	if header := pm.blockchain.GetHeader(pHead); header != nil {
+		pm.forks.observe(p.id, header, pTd)
+	} else {
+		pm.forks.expect(p.id, pHead, pTd)
+		if err := p.RequestHeadersByHash(pHead, 1, 0, false); err != nil {
+			glog.V(logger.Debug).Infof("handler: %s ->headersbyhash err=%v", p, err)
+			return err
+		}
+	}
 
 	// main loop. handle incoming messages.
 	for {
 		if err := pm.handleMsg(p); err != nil {
+			reportMsgFault(p, err)
 			glog.V(logger.Debug).Infof("handler: %s ->msghandlefailed err=%v", p, err)
 			return err
 		}
@@ -382,13 +472,14 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 		mlogWireDelegate(p, "receive", GetBlockHeadersMsg, intSize, &query, err)
 		hashMode := query.Origin.Hash != (common.Hash{})
 
-		// Gather headers until the fetch or network limits is reached
+		// Gather headers until the fetch, network or serving limits is reached
 		var (
 			bytes   common.StorageSize
 			headers []*types.Header
 			unknown bool
+			allowed = p.serveThrottle.allowance(serveHeaders, time.Now())
 		)
-		for !unknown && len(headers) < int(query.Amount) && bytes < softResponseLimit && len(headers) < downloader.MaxHeaderFetch {
+		for !unknown && len(headers) < int(query.Amount) && bytes < softResponseLimit && len(headers) < allowed {
 			// Retrieve the next header satisfying the query
 			var origin *types.Header
 			if hashMode {
@@ -448,6 +539,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 				query.Origin.Number += (query.Skip + 1)
 			}
 		}
+		p.serveThrottle.served(serveHeaders, len(headers), allowed)
 		return p.SendBlockHeaders(headers)
 
 	case p.version >= eth62 && msg.Code == BlockHeadersMsg:
@@ -473,7 +565,9 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 		// Filter out any explicitly requested headers, deliver the rest to the downloader
 		filter := len(headers) == 1
 		if filter {
-			if p.forkDrop != nil {
+			// The advertised head requested for the fork monitor doesn't answer the fork check
+			head := pm.forks.deliver(p.id, headers[0])
+			if p.forkDrop != nil && !head {
 				// Disable the fork drop timeout
 				p.forkDrop.Stop()
 				p.forkDrop = nil
@@ -485,6 +579,9 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 			}
 			// Irrelevant of the fork checks, send the header to the fetcher just in case
 			headers = pm.fetcher.FilterHeaders(p.id, headers, time.Now())
+			if head {
+				return nil
+			}
 		}
 		if len(headers) > 0 || !filter {
 			err := pm.downloader.DeliverHeaders(p.id, headers)
@@ -499,13 +596,14 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 		if _, err = msgStream.List(); err != nil {
 			return err
 		}
-		// Gather blocks until the fetch or network limits is reached
+		// Gather blocks until the fetch, network or serving limits is reached
 		var (
 			hash   common.Hash
 			bytes  int
 			bodies []rlp.RawValue
 		)
-		for bytes < softResponseLimit && len(bodies) < downloader.MaxBlockFetch {
+		allowed := p.serveThrottle.allowance(serveBodies, time.Now())
+		for bytes < softResponseLimit && len(bodies) < allowed {
 			// Retrieve the hash of the next block
 			if e := msgStream.Decode(&hash); e == rlp.EOL {
 				break
@@ -521,6 +619,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 			}
 		}
 		mlogWireDelegate(p, "receive", GetBlockBodiesMsg, intSize, bodies, err)
+		p.serveThrottle.served(serveBodies, len(bodies), allowed)
 		return p.SendBlockBodiesRLP(bodies)
 
 	case p.version >= eth62 && msg.Code == BlockBodiesMsg:
@@ -559,28 +658,33 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 			mlogWireDelegate(p, "receive", GetNodeDataMsg, intSize, [][]byte{}, err)
 			return err
 		}
-		// Gather state data until the fetch or network limits is reached
+		// Gather state data until the fetch, network or serving limits is reached
 		var (
 			hash  common.Hash
 			bytes int
 			data  [][]byte
 		)
-		for bytes < softResponseLimit && len(data) < downloader.MaxStateFetch {
+		allowed := p.serveThrottle.allowance(serveNodeData, time.Now())
+		release := pm.nodeData.acquire()
+		for bytes < softResponseLimit && len(data) < allowed {
 			// Retrieve the hash of the next state entry
 			if e := msgStream.Decode(&hash); e == rlp.EOL {
 				break
 			} else if e != nil {
+				release()
 				err = errResp(ErrDecode, "msg %v: %v", msg, e)
 				mlogWireDelegate(p, "receive", GetNodeDataMsg, intSize, data, err)
 				return
 			}
 			// Retrieve the requested state entry, stopping if enough was found
-			if entry, e := pm.chaindb.Get(hash.Bytes()); e == nil {
+			if entry, e := pm.nodeData.get(hash); e == nil {
 				data = append(data, entry)
 				bytes += len(entry)
 			}
 		}
+		release()
 		mlogWireDelegate(p, "receive", GetNodeDataMsg, intSize, data, err)
+		p.serveThrottle.served(serveNodeData, len(data), allowed)
 		return p.SendNodeData(data)
 
 	case p.version >= eth63 && msg.Code == NodeDataMsg:
@@ -605,13 +709,14 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 			mlogWireDelegate(p, "receive", GetReceiptsMsg, intSize, []rlp.RawValue{}, err)
 			return err
 		}
-		// Gather state data until the fetch or network limits is reached
+		// Gather state data until the fetch, network or serving limits is reached
 		var (
 			hash     common.Hash
 			bytes    int
 			receipts []rlp.RawValue
 		)
-		for bytes < softResponseLimit && len(receipts) < downloader.MaxReceiptFetch {
+		allowed := p.serveThrottle.allowance(serveReceipts, time.Now())
+		for bytes < softResponseLimit && len(receipts) < allowed {
 			// Retrieve the hash of the next block
 			if e := msgStream.Decode(&hash); e == rlp.EOL {
 				break
@@ -621,21 +726,19 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 				return
 			}
 			// Retrieve the requested block's receipts, skipping if unknown to us
-			results := core.GetBlockReceipts(pm.chaindb, hash)
-			if results == nil {
+			encoded := pm.blockchain.GetReceiptsRLP(hash)
+			if encoded == nil {
 				if header := pm.blockchain.GetHeader(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
 					continue
 				}
+				encoded = rlp.EmptyList
 			}
-			// If known, encode and queue for response packet
-			if encoded, err := rlp.EncodeToBytes(results); err != nil {
-				glog.V(logger.Error).Infof("failed to encode receipt: %v", err)
-			} else {
-				receipts = append(receipts, encoded)
-				bytes += len(encoded)
-			}
+			// If known, queue for response packet
+			receipts = append(receipts, encoded)
+			bytes += len(encoded)
 		}
 		mlogWireDelegate(p, "receive", GetReceiptsMsg, intSize, receipts, err)
+		p.serveThrottle.served(serveReceipts, len(receipts), allowed)
 		return p.SendReceiptsRLP(receipts)
 
 	case p.version >= eth63 && msg.Code == ReceiptsMsg:
@@ -719,6 +822,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 		// Mark the peer as owning the block and schedule it for import
 		p.MarkBlock(request.Block.Hash())
 		pm.fetcher.Enqueue(p.id, request.Block)
+		pm.forks.observe(p.id, request.Block.Header(), request.TD)
+		pm.blockStats.arrival(request.Block.Header(), msg.ReceivedAt)
 
 		// Assuming the block is importable by the peer, but possibly not yet done so,
 		// calculate the head hash and TD that the peer truly must have.
@@ -761,14 +866,89 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 			return
 		}
 		mlogWireDelegate(p, "receive", TxMsg, intSize, txs, err)
-		for i, tx := range txs {
-			// Validate and mark the remote transaction
-			if tx == nil {
-				return errResp(ErrDecode, "transaction %d is nil", i)
+		return pm.handleTxs(p, txs)
+
+	case p.version >= eth65 && msg.Code == NewPooledTransactionHashesMsg:
+		// Transactions were announced, skip them unless we can process them
+		if atomic.LoadUint32(&pm.acceptsTxs) == 0 {
+			mlogWireDelegate(p, "receive", NewPooledTransactionHashesMsg, intSize, []common.Hash{}, errors.New("not synced"))
+			break
+		}
+		var hashes []common.Hash
+		if e := msg.Decode(&hashes); e != nil {
+			err = errResp(ErrDecode, "msg %v: %v", msg, e)
+			mlogWireDelegate(p, "receive", NewPooledTransactionHashesMsg, intSize, hashes, err)
+			return
+		}
+		mlogWireDelegate(p, "receive", NewPooledTransactionHashesMsg, intSize, hashes, err)
+		unknown := make([]common.Hash, 0, len(hashes))
+		for _, hash := range hashes {
+			p.MarkTransaction(hash)
+			if !pm.knownTx(hash) {
+				unknown = append(unknown, hash)
 			}
-			p.MarkTransaction(tx.Hash())
 		}
-		pm.txpool.AddTransactions(txs)
+		if len(unknown) > 0 {
+			pm.txFetcher.Notify(p.id, unknown, time.Now(), p.RequestTxs)
+		}
+
+	case p.version >= eth65 && msg.Code == GetPooledTransactionsMsg:
+		// Decode the retrieval message
+		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+		if _, err = msgStream.List(); err != nil {
+			mlogWireDelegate(p, "receive", GetPooledTransactionsMsg, intSize, []common.Hash{}, err)
+			return err
+		}
+		// Gather transactions until the fetch or network limits is reached
+		var (
+			hash   common.Hash
+			bytes  int
+			hashes []common.Hash
+			txs    []rlp.RawValue
+		)
+		for bytes < softResponseLimit && len(txs) < fetcher.MaxTxFetch {
+			// Retrieve the hash of the next transaction
+			if e := msgStream.Decode(&hash); e == rlp.EOL {
+				break
+			} else if e != nil {
+				err = errResp(ErrDecode, "msg %v: %v", msg, e)
+				mlogWireDelegate(p, "receive", GetPooledTransactionsMsg, intSize, hashes, err)
+				return
+			}
+			// Retrieve the requested transaction, skipping if unknown to us or not to be relayed
+			tx := pm.txpool.GetTransaction(hash)
+			if tx == nil || !pm.txShareable(p, tx) {
+				continue
+			}
+			if encoded, err := rlp.EncodeToBytes(tx); err != nil {
+				glog.V(logger.Error).Infof("failed to encode transaction: %v", err)
+			} else {
+				hashes = append(hashes, hash)
+				txs = append(txs, encoded)
+				bytes += len(encoded)
+			}
+		}
+		mlogWireDelegate(p, "receive", GetPooledTransactionsMsg, intSize, hashes, err)
+		return p.SendPooledTransactionsRLP(hashes, txs)
+
+	case p.version >= eth65 && msg.Code == PooledTransactionsMsg:
+		// A batch of transactions arrived to one of our previous requests
+		var txs []*types.Transaction
+		if e := msg.Decode(&txs); e != nil {
+			err = errResp(ErrDecode, "msg %v: %v", msg, e)
+			mlogWireDelegate(p, "receive", PooledTransactionsMsg, intSize, txs, err)
+			return
+		}
+		if e := p.deliverTxs(txs); e != nil {
+			err = errResp(ErrUnrequestedResponse, "%v", e)
+			mlogWireDelegate(p, "receive", PooledTransactionsMsg, intSize, txs, err)
+			return
+		}
+		mlogWireDelegate(p, "receive", PooledTransactionsMsg, intSize, txs, err)
+		if atomic.LoadUint32(&pm.acceptsTxs) == 0 {
+			break
+		}
+		return pm.handleTxs(p, txs)
 
 	default:
 		err = errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -778,6 +958,44 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 	return nil
 }
 
+// handleTxs marks a batch of transactions received from a peer as known and
+// hands the ones not seen recently to the pool, subject to the peer's throttle.
+func (pm *ProtocolManager) handleTxs(p *peer, txs []*types.Transaction) error {
+	hashes := make([]common.Hash, 0, len(txs))
+	unseen := make([]*types.Transaction, 0, len(txs))
+	for i, tx := range txs {
+		// Validate and mark the remote transaction
+		if tx == nil {
+			return errResp(ErrDecode, "transaction %d is nil", i)
+		}
+		hash := tx.Hash()
+		p.MarkTransaction(hash)
+		hashes = append(hashes, hash)
+
+		// Skip transactions another peer delivered recently
+		if !pm.recentTxs.Has(hash) {
+			unseen = append(unseen, tx)
+		}
+	}
+	// Whether accepted or not, there's no point in fetching these anymore
+	pm.txFetcher.Deliver(hashes)
+
+	pending := func(hash common.Hash) bool { return pm.txpool.GetTransaction(hash) != nil }
+	if txs = p.txThrottle.filter(unseen, pending, time.Now()); len(txs) > 0 {
+		for _, tx := range txs {
+			pm.recentTxs.Add(tx.Hash())
+		}
+		pm.txpool.AddTransactions(txs)
+	}
+	return nil
+}
+
+// knownTx reports whether a transaction was recently processed or is already
+// pending in the pool, in which case there's no need to fetch it.
+func (pm *ProtocolManager) knownTx(hash common.Hash) bool {
+	return pm.recentTxs.Has(hash) || pm.txpool.GetTransaction(hash) != nil
+}
+
 // BroadcastBlock will either propagate a block to a subset of it's peers, or
 // will only announce it's availability (depending what's requested).
 func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
@@ -810,16 +1028,56 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 	}
 }
 
-// BroadcastTx will propagate a transaction to all peers which are not known to
-// already have the given transaction.
+// BroadcastTx will propagate a relayable transaction to a square root subset of the peers
+// not known to already have it, and announce its hash to the remaining ones. The
+// announced peers retrieve the transaction on demand if nobody else sent it to
+// them in the meantime. Peers predating eth/65 can't request transactions, so
+// they are always sent the full transaction.
 func (pm *ProtocolManager) BroadcastTx(hash common.Hash, tx *types.Transaction) {
-	// Broadcast transaction to a batch of peers not knowing about it
+	if pm.txpool.Private(hash) {
+		pm.relayPrivateTx(hash, tx)
+		return
+	}
+	if !pm.txpool.Relayable(tx) {
+		glog.V(logger.Detail).Infof("not relaying unprotected tx [%s]", hash.Hex())
+		return
+	}
 	peers := pm.peers.PeersWithoutTx(hash)
-	//FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
-	for _, peer := range peers {
-		peer.AsyncSendTransactions(types.Transactions{tx})
+	direct := int(math.Sqrt(float64(len(peers))))
+
+	var sent, announced int
+	for i, peer := range peers {
+		if i < direct || peer.version < eth65 {
+			peer.AsyncSendTransactions(types.Transactions{tx})
+			sent++
+		} else {
+			peer.AsyncSendTransactionHashes([]common.Hash{hash})
+			announced++
+		}
+	}
+	glog.V(logger.Detail).Infof("broadcast tx [%s] to %d peers, announced to %d", hash.Hex(), sent, announced)
+}
+
+// relayPrivateTx sends a private transaction to the connected private relays only,
+// holding it back if there are none.
+func (pm *ProtocolManager) relayPrivateTx(hash common.Hash, tx *types.Transaction) {
+	var relayed int
+	for _, peer := range pm.peers.PeersWithoutTx(hash) {
+		if pm.privateRelays[peer.ID()] {
+			peer.AsyncSendTransactions(types.Transactions{tx})
+			relayed++
+		}
+	}
+	glog.V(logger.Detail).Infof("relayed private tx [%s] to %d peers", hash.Hex(), relayed)
+}
+
+// txShareable reports whether a transaction may be sent to the given peer: private
+// transactions only go to the private relays, the others if they are relayable.
+func (pm *ProtocolManager) txShareable(p *peer, tx *types.Transaction) bool {
+	if pm.txpool.Private(tx.Hash()) {
+		return pm.privateRelays[p.ID()]
 	}
-	glog.V(logger.Detail).Infof("broadcast tx [%s] to %d peers", tx.Hash().Hex(), len(peers))
+	return pm.txpool.Relayable(tx)
 }
 
 // Mined broadcast loop
@@ -849,14 +1107,17 @@ type EthNodeInfo struct {
 	Difficulty *big.Int    `json:"difficulty"` // Total difficulty of the host's blockchain
 	Genesis    common.Hash `json:"genesis"`    // SHA3 hash of the host's genesis block
 	Head       common.Hash `json:"head"`       // SHA3 hash of the host's best owned block
+	Number     uint64      `json:"number"`     // Number of the host's best owned block
 }
 
 // NodeInfo retrieves some protocol metadata about the running host node.
 func (self *ProtocolManager) NodeInfo() *EthNodeInfo {
+	head := self.blockchain.CurrentBlock()
 	return &EthNodeInfo{
 		Network:    int(self.networkId),
-		Difficulty: self.blockchain.GetTd(self.blockchain.CurrentBlock().Hash()),
+		Difficulty: self.blockchain.GetTd(head.Hash()),
 		Genesis:    self.blockchain.Genesis().Hash(),
-		Head:       self.blockchain.CurrentBlock().Hash(),
+		Head:       head.Hash(),
+		Number:     head.NumberU64(),
 	}
 }
@@ -30,6 +30,7 @@ import (
 	"github.com/ethereumclassic/go-ethereum/eth/downloader"
 	"github.com/ethereumclassic/go-ethereum/ethdb"
 	"github.com/ethereumclassic/go-ethereum/p2p"
+	"github.com/ethereumclassic/go-ethereum/p2p/discover"
 )
 
 // Tests that protocol versions and modes of operations are matched up properly.
@@ -455,3 +456,97 @@ func testGetReceipt(t *testing.T, protocol int) {
 		t.Errorf("receipts mismatch: %v", err)
 	}
 }
+
+// Tests that transactions of unsupported types aren't counted as malformed
+// messages, while undecodable ones are.
+func TestTxTypeNotMsgFault(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	pm.acceptsTxs = 1
+	app, net := p2p.MsgPipe()
+	defer app.Close()
+	p := pm.newPeer(63, p2p.NewPeer(discover.NodeID{}, "peer", nil), net)
+
+	tests := []struct {
+		envelope  []byte
+		malformed uint64
+	}{
+		{[]byte{0x03, 0xc0}, 0},       // unknown transaction type
+		{[]byte{0x01, 0xc0}, 1},       // access list transaction missing its fields
+		{[]byte{0x02, 0x01, 0x02}, 2}, // dynamic fee transaction with a broken payload
+	}
+	for i, tt := range tests {
+		go p2p.Send(app, TxMsg, [][]byte{tt.envelope})
+		err := pm.handleMsg(p)
+		if err == nil {
+			t.Fatalf("test %d: typed transaction accepted", i)
+		}
+		reportMsgFault(p, err)
+		if malformed := p.Peer.Info().MalformedMsgs; malformed != tt.malformed {
+			t.Errorf("test %d: malformed message count mismatch: have %d, want %d", i, malformed, tt.malformed)
+		}
+	}
+}
+
+// Tests that private transactions are only broadcast and served to the private
+// relays, while the other transactions go to every peer.
+func TestPrivateTxRelay(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	pool := pm.txpool.(*testTxPool)
+
+	private, public := newTestTransaction(testAccount, 0, 0), newTestTransaction(testAccount, 1, 0)
+	pool.private = map[common.Hash]bool{private.Hash(): true}
+	pool.AddTransactions([]*types.Transaction{private, public})
+
+	relayID, otherID := discover.NodeID{1}, discover.NodeID{2}
+	pm.privateRelays = map[discover.NodeID]bool{relayID: true}
+
+	var (
+		peers = make(map[discover.NodeID]*peer)
+		apps  = make(map[discover.NodeID]*p2p.MsgPipeRW)
+	)
+	for _, id := range []discover.NodeID{relayID, otherID} {
+		app, net := p2p.MsgPipe()
+		defer app.Close()
+		p := pm.newPeer(eth65, p2p.NewPeer(id, "peer", nil), net)
+		pm.peers.peers[p.id] = p
+		peers[id], apps[id] = p, app
+	}
+	// Broadcasting the private transaction must only reach the relay
+	pm.BroadcastTx(private.Hash(), private)
+	if len(peers[relayID].queuedTxs) != 1 {
+		t.Errorf("private transaction not sent to the relay")
+	}
+	if len(peers[otherID].queuedTxs) != 0 || len(peers[otherID].queuedTxAnns) != 0 {
+		t.Errorf("private transaction propagated to a public peer")
+	}
+	// Only the relay may retrieve the private transaction, all may the public one
+	for id, want := range map[discover.NodeID]int{relayID: 2, otherID: 1} {
+		go p2p.Send(apps[id], GetPooledTransactionsMsg, []common.Hash{private.Hash(), public.Hash()})
+		served := make(chan error, 1)
+		go func() { served <- pm.handleMsg(peers[id]) }()
+
+		msg, err := apps[id].ReadMsg()
+		if err != nil {
+			t.Fatalf("peer %x: failed to read response: %v", id[:1], err)
+		}
+		var txs []*types.Transaction
+		if err := msg.Decode(&txs); err != nil {
+			t.Fatalf("peer %x: failed to decode response: %v", id[:1], err)
+		}
+		if err := <-served; err != nil {
+			t.Fatalf("peer %x: failed to serve request: %v", id[:1], err)
+		}
+		if len(txs) != want {
+			t.Fatalf("peer %x: served transaction count mismatch: have %d, want %d", id[:1], len(txs), want)
+		}
+		if txs[len(txs)-1].Hash() != public.Hash() {
+			t.Errorf("peer %x: public transaction not served", id[:1])
+		}
+	}
+	// Transactions synced to new peers leave the private one out for non-relays
+	for id, want := range map[discover.NodeID]bool{relayID: true, otherID: false} {
+		if shareable := pm.txShareable(peers[id], private); shareable != want {
+			t.Errorf("peer %x: private transaction shareable mismatch: have %v, want %v", id[:1], shareable, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package eth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/metrics"
+)
+
+const headAgeInterval = 10 * time.Second // Interval between updates of the head age metric
+
+// headWatch tracks how old the head block of the chain is relative to the wall
+// clock. A node can be done syncing while following a chain that stopped growing,
+// which the age exposes: past the stale threshold, the node reports itself as
+// not ready.
+type headWatch struct {
+	head      func() *types.Block // Current head block of the chain
+	now       func() time.Time    // Wall clock, replaced in tests
+	threshold time.Duration       // Age beyond which the head is stale, never if zero
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newHeadWatch(chain *core.BlockChain, threshold time.Duration) *headWatch {
+	return &headWatch{
+		head:      chain.CurrentBlock,
+		now:       time.Now,
+		threshold: threshold,
+		quit:      make(chan struct{}),
+	}
+}
+
+func (w *headWatch) start() {
+	w.wg.Add(1)
+	go w.loop()
+}
+
+func (w *headWatch) stop() {
+	close(w.quit)
+	w.wg.Wait()
+}
+
+// loop keeps the head age metric up to date, as the age grows between blocks.
+func (w *headWatch) loop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(headAgeInterval)
+	defer ticker.Stop()
+
+	for {
+		_, age := w.age()
+		metrics.ChainHeadAge.Update(int64(age / time.Second))
+		select {
+		case <-ticker.C:
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// age returns the head block and the time elapsed since its timestamp. Heads
+// timestamped in the future are of age zero.
+func (w *headWatch) age() (*types.Block, time.Duration) {
+	head := w.head()
+	age := w.now().Sub(time.Unix(head.Time().Int64(), 0))
+	if age < 0 {
+		age = 0
+	}
+	return head, age
+}
+
+// stale returns an error describing the head if it is older than the threshold.
+func (w *headWatch) stale() error {
+	if w.threshold == 0 {
+		return nil
+	}
+	head, age := w.age()
+	if age <= w.threshold {
+		return nil
+	}
+	return fmt.Errorf("head block #%d is %v old, stale beyond %v", head.NumberU64(), age/time.Second*time.Second, w.threshold)
+}
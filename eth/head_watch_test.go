@@ -0,0 +1,44 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/core/types"
+)
+
+func TestHeadWatchStale(t *testing.T) {
+	var (
+		head = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(10), Time: big.NewInt(1000)})
+		now  = time.Unix(1000, 0)
+	)
+	w := &headWatch{
+		head:      func() *types.Block { return head },
+		now:       func() time.Time { return now },
+		threshold: time.Minute,
+	}
+	tests := []struct {
+		now   int64
+		age   time.Duration
+		stale bool
+	}{
+		{990, 0, false}, // Head timestamped in the future
+		{1000, 0, false},
+		{1060, time.Minute, false},
+		{1061, 61 * time.Second, true},
+	}
+	for i, tt := range tests {
+		now = time.Unix(tt.now, 0)
+		if _, age := w.age(); age != tt.age {
+			t.Errorf("test %d: age %v, want %v", i, age, tt.age)
+		}
+		if stale := w.stale() != nil; stale != tt.stale {
+			t.Errorf("test %d: stale %v, want %v", i, stale, tt.stale)
+		}
+	}
+	w.threshold = 0
+	if err := w.stale(); err != nil {
+		t.Errorf("head stale without threshold: %v", err)
+	}
+}
@@ -28,6 +28,7 @@ import (
 
 	"github.com/ethereumclassic/go-ethereum/common"
 	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/forkid"
 	"github.com/ethereumclassic/go-ethereum/core/types"
 	"github.com/ethereumclassic/go-ethereum/crypto"
 	"github.com/ethereumclassic/go-ethereum/eth/downloader"
@@ -51,7 +52,6 @@ var (
 func newTestProtocolManager(mode downloader.SyncMode, blocks int, generator func(int, *core.BlockGen), newtx chan<- []*types.Transaction) (*ProtocolManager, *ethdb.MemDatabase, error) {
 	var (
 		evmux       = new(event.TypeMux)
-		pow         = new(core.FakePow)
 		db, _       = ethdb.NewMemDatabase()
 		genesis     = core.WriteGenesisBlockForTesting(db, testBank)
 		chainConfig = &core.ChainConfig{
@@ -62,15 +62,17 @@ func newTestProtocolManager(mode downloader.SyncMode, blocks int, generator func
 				},
 			},
 		}
-		blockchain, _ = core.NewBlockChain(db, chainConfig, pow, evmux)
+		blockchain, _ = core.NewBlockChain(db, chainConfig, evmux)
 	)
 
 	chain, _ := core.GenerateChain(core.DefaultConfigMorden.ChainConfig, genesis, db, blocks, generator)
-	if res := blockchain.InsertChain(chain); res.Error != nil {
-		panic(res.Error)
+	for _, block := range chain {
+		if _, err := blockchain.WriteBlock(block); err != nil {
+			panic(err)
+		}
 	}
 
-	pm, err := NewProtocolManager(chainConfig, mode, NetworkId, evmux, &testTxPool{added: newtx}, pow, blockchain, db)
+	pm, err := NewProtocolManager(chainConfig, mode, NetworkId, evmux, &testTxPool{added: newtx}, blockchain, db)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -78,6 +80,56 @@ func newTestProtocolManager(mode downloader.SyncMode, blocks int, generator func
 	return pm, db, nil
 }
 
+// newTestBlockChainAPI creates a blockchain API on a chain of the given number of
+// blocks on top of a genesis funding the test bank.
+func newTestBlockChainAPI(blocks int, generator func(int, *core.BlockGen)) (*PublicBlockChainAPI, *core.BlockChain) {
+	var (
+		evmux         = new(event.TypeMux)
+		db, _         = ethdb.NewMemDatabase()
+		genesis       = core.WriteGenesisBlockForTesting(db, testBank)
+		config        = core.DefaultConfigMorden.ChainConfig
+		blockchain, _ = core.NewBlockChain(db, config, evmux)
+	)
+	chain, _ := core.GenerateChain(config, genesis, db, blocks, generator)
+	for _, block := range chain {
+		if _, err := blockchain.WriteBlock(block); err != nil {
+			panic(err)
+		}
+	}
+	return NewPublicBlockChainAPI(config, blockchain, db, nil, evmux, nil, nil), blockchain
+}
+
+// newTestChainWithReceipts creates a test chain like newTestBlockChainAPI,
+// storing the transactions and receipts of its blocks as block import does.
+func newTestChainWithReceipts(t *testing.T, blocks int, generator func(int, *core.BlockGen)) (*core.BlockChain, ethdb.Database, *event.TypeMux) {
+	var (
+		evmux      = new(event.TypeMux)
+		db, _      = ethdb.NewMemDatabase()
+		genesis    = core.WriteGenesisBlockForTesting(db, testBank)
+		config     = core.DefaultConfigMorden.ChainConfig
+		chain, err = core.NewBlockChain(db, config, evmux)
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated, receipts := core.GenerateChain(config, genesis, db, blocks, generator)
+	for i, block := range generated {
+		if _, err := chain.WriteBlock(block); err != nil {
+			t.Fatal(err)
+		}
+		if err := core.WriteTransactions(db, block); err != nil {
+			t.Fatal(err)
+		}
+		if err := core.WriteReceipts(db, receipts[i]); err != nil {
+			t.Fatal(err)
+		}
+		if err := core.WriteBlockReceipts(db, block.Hash(), receipts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chain, db, evmux
+}
+
 // newTestProtocolManagerMust creates a new protocol manager for testing purposes,
 // with the given number of blocks already known, and potential notification
 // channels for different events. In case of an error, the constructor force-
@@ -92,9 +144,10 @@ func newTestProtocolManagerMust(t *testing.T, mode downloader.SyncMode, blocks i
 
 // testTxPool is a fake, helper transaction pool for testing purposes
 type testTxPool struct {
-	txFeed event.Feed
-	pool   []*types.Transaction        // Collection of all transactions
-	added  chan<- []*types.Transaction // Notification channel for new transactions
+	txFeed  event.Feed
+	pool    []*types.Transaction        // Collection of all transactions
+	private map[common.Hash]bool        // Transactions kept private
+	added   chan<- []*types.Transaction // Notification channel for new transactions
 
 	lock sync.RWMutex // Protects the transaction pool
 }
@@ -122,6 +175,32 @@ func (p *testTxPool) GetTransactions() types.Transactions {
 	return txs
 }
 
+// GetTransaction returns the transaction with the given hash, if known to the pool
+func (p *testTxPool) GetTransaction(hash common.Hash) *types.Transaction {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, tx := range p.pool {
+		if tx.Hash() == hash {
+			return tx
+		}
+	}
+	return nil
+}
+
+// Relayable reports every transaction as relayable
+func (p *testTxPool) Relayable(tx *types.Transaction) bool {
+	return true
+}
+
+// Private reports whether the transaction with the given hash was marked private
+func (p *testTxPool) Private(hash common.Hash) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.private[hash]
+}
+
 // newTestTransaction create a new dummy transaction.
 func newTestTransaction(from *ecdsa.PrivateKey, nonce uint64, datasize int) *types.Transaction {
 	tx := types.NewTransaction(nonce, common.Address{}, big.NewInt(0), big.NewInt(100000), big.NewInt(0), make([]byte, datasize))
@@ -161,21 +240,32 @@ func newTestPeer(name string, version int, pm *ProtocolManager, shake bool) (*te
 	// Execute any implicitly requested handshakes and return
 	if shake {
 		td, head, genesis := pm.blockchain.Status()
-		tp.handshake(nil, td, head, genesis)
+		forkID := forkid.NewID(pm.chainConfig, genesis, pm.blockchain.CurrentHeader().Number.Uint64())
+		tp.handshake(nil, td, head, genesis, forkID)
 	}
 	return tp, errc
 }
 
 // handshake simulates a trivial handshake that expects the same state from the
 // remote side as we are simulating locally.
-func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash) {
-	msg := &statusData{
+func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID) {
+	var msg interface{} = &statusData{
 		ProtocolVersion: uint32(p.version),
 		NetworkId:       uint32(NetworkId),
 		TD:              td,
 		CurrentBlock:    head,
 		GenesisBlock:    genesis,
 	}
+	if p.version >= eth65 {
+		msg = &statusData65{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       NetworkId,
+			TD:              td,
+			CurrentBlock:    head,
+			GenesisBlock:    genesis,
+			ForkID:          forkID,
+		}
+	}
 	if err := p2p.ExpectMsg(p.app, StatusMsg, msg); err != nil {
 		t.Fatalf("status recv: %v", err)
 	}
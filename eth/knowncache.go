@@ -0,0 +1,90 @@
+package eth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/common"
+)
+
+// knownCache is a bounded set of hashes whose entries expire after a fixed
+// lifetime. When the cache is full, the oldest entries are evicted first, so
+// memory use stays constant no matter how long a peer stays connected.
+type knownCache struct {
+	limit    int           // maximum number of hashes kept
+	lifetime time.Duration // time after which a hash is forgotten
+
+	lock  sync.Mutex
+	order *list.List                    // entries ordered by insertion time, oldest first
+	items map[common.Hash]*list.Element // hash -> element in order
+}
+
+type knownEntry struct {
+	hash  common.Hash
+	added time.Time
+}
+
+func newKnownCache(limit int, lifetime time.Duration) *knownCache {
+	return &knownCache{
+		limit:    limit,
+		lifetime: lifetime,
+		order:    list.New(),
+		items:    make(map[common.Hash]*list.Element),
+	}
+}
+
+// Add marks the given hash as known, refreshing its lifetime if it was already.
+func (c *knownCache) Add(hash common.Hash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	c.expire(now)
+
+	if elem, ok := c.items[hash]; ok {
+		elem.Value.(*knownEntry).added = now
+		c.order.MoveToBack(elem)
+		return
+	}
+	for c.order.Len() >= c.limit {
+		c.remove(c.order.Front())
+	}
+	c.items[hash] = c.order.PushBack(&knownEntry{hash: hash, added: now})
+}
+
+// Has reports whether the given hash is known and not yet expired.
+func (c *knownCache) Has(hash common.Hash) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.items[hash]
+	if !ok {
+		return false
+	}
+	return time.Now().Sub(elem.Value.(*knownEntry).added) < c.lifetime
+}
+
+// Len returns the number of hashes currently tracked, including expired ones
+// not yet evicted.
+func (c *knownCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.order.Len()
+}
+
+// expire drops all entries older than the cache lifetime.
+func (c *knownCache) expire(now time.Time) {
+	for elem := c.order.Front(); elem != nil; elem = c.order.Front() {
+		if now.Sub(elem.Value.(*knownEntry).added) < c.lifetime {
+			return
+		}
+		c.remove(elem)
+	}
+}
+
+func (c *knownCache) remove(elem *list.Element) {
+	delete(c.items, elem.Value.(*knownEntry).hash)
+	c.order.Remove(elem)
+}
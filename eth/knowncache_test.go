@@ -0,0 +1,72 @@
+package eth
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+)
+
+// Tests that the oldest hashes are evicted once the cache is full, with marking
+// a known hash again refreshing it.
+func TestKnownCacheEviction(t *testing.T) {
+	cache := newKnownCache(3, time.Hour)
+	for i := byte(1); i <= 3; i++ {
+		cache.Add(common.Hash{i})
+	}
+	cache.Add(common.Hash{1}) // Refresh, hash 2 is now the oldest
+	cache.Add(common.Hash{4})
+
+	if cache.Len() != 3 {
+		t.Errorf("cache length mismatch: have %d, want 3", cache.Len())
+	}
+	for i, known := range []bool{false, true, false, true, true} {
+		if cache.Has(common.Hash{byte(i)}) != known {
+			t.Errorf("hash %d: known mismatch: have %v, want %v", i, !known, known)
+		}
+	}
+}
+
+// Tests that hashes are forgotten after the cache lifetime, and dropped by the
+// next marking.
+func TestKnownCacheExpiry(t *testing.T) {
+	cache := newKnownCache(10, 50*time.Millisecond)
+	cache.Add(common.Hash{1})
+	if !cache.Has(common.Hash{1}) {
+		t.Fatalf("hash not known after marking")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if cache.Has(common.Hash{1}) {
+		t.Errorf("hash known after its lifetime")
+	}
+	cache.Add(common.Hash{2})
+	if cache.Len() != 1 || !cache.Has(common.Hash{2}) {
+		t.Errorf("expired hash not dropped: %d hashes tracked", cache.Len())
+	}
+}
+
+// Tests that the cache can be marked and looked up concurrently, staying within
+// its limit.
+func TestKnownCacheConcurrency(t *testing.T) {
+	cache := newKnownCache(100, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				hash := common.Hash{byte(i), byte(j), byte(j >> 8)}
+				cache.Add(hash)
+				cache.Has(hash)
+				cache.Len()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if cache.Len() != 100 {
+		t.Errorf("cache length mismatch: have %d, want 100", cache.Len())
+	}
+}
@@ -46,6 +46,8 @@ func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
 		messages, bytes = metrics.MsgBlockIn, metrics.MsgBlockInBytes
 	case msg.Code == TxMsg:
 		messages, bytes = metrics.MsgTXNIn, metrics.MsgTXNInBytes
+	case rw.version >= eth65 && msg.Code == PooledTransactionsMsg:
+		messages, bytes = metrics.MsgTXNIn, metrics.MsgTXNInBytes
 	}
 	messages.Mark(1)
 	bytes.Mark(int64(msg.Size))
@@ -70,6 +72,8 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 		messages, bytes = metrics.MsgBlockOut, metrics.MsgBlockOutBytes
 	case msg.Code == TxMsg:
 		messages, bytes = metrics.MsgTXNOut, metrics.MsgTXNOutBytes
+	case rw.version >= eth65 && msg.Code == PooledTransactionsMsg:
+		messages, bytes = metrics.MsgTXNOut, metrics.MsgTXNOutBytes
 	}
 	messages.Mark(1)
 	bytes.Mark(int64(msg.Size))
@@ -13,6 +13,13 @@ import (
 
 var mlogWwireProtocol = logger.MLogRegisterAvailable("wire", mlogLinesWire)
 
+var mlogSyncComponent = logger.MLogRegisterAvailable("sync", mlogLinesSync)
+
+var mlogLinesSync = []*logger.MLogT{
+	mlogSyncTargetDecision,
+	mlogSyncForkDetected,
+}
+
 var mlogLinesWire = []*logger.MLogT{
 	mlogWireSendHandshake,
 	mlogWireReceiveHandshake,
@@ -36,6 +43,12 @@ var mlogLinesWire = []*logger.MLogT{
 	mlogWireReceiveGetReceipts,
 	mlogWireSendReceipts,
 	mlogWireReceiveReceipts,
+	mlogWireSendNewPooledTransactionHashes,
+	mlogWireReceiveNewPooledTransactionHashes,
+	mlogWireSendGetPooledTransactions,
+	mlogWireReceiveGetPooledTransactions,
+	mlogWireSendPooledTransactions,
+	mlogWireReceivePooledTransactions,
 	mlogWireReceiveInvalid,
 }
 
@@ -371,6 +384,49 @@ func mlogWireDelegate(p *peer, direction string, msgCode uint64, size int, data
 			}
 		}
 
+	case NewPooledTransactionHashesMsg, GetPooledTransactionsMsg:
+		if payload, ok := data.([]common.Hash); ok {
+			details = append(details, len(payload))
+		} else if err != nil {
+			details = append(details, 0)
+		} else {
+			glog.Fatal("cant cast: ", ProtocolMessageStringer(uint(msgCode)), direction)
+		}
+		if msgCode == NewPooledTransactionHashesMsg {
+			if direction == "send" {
+				line = mlogWireSendNewPooledTransactionHashes
+			} else {
+				line = mlogWireReceiveNewPooledTransactionHashes
+			}
+		} else {
+			if direction == "send" {
+				line = mlogWireSendGetPooledTransactions
+			} else {
+				line = mlogWireReceiveGetPooledTransactions
+			}
+		}
+
+	case PooledTransactionsMsg:
+		if direction == "send" {
+			line = mlogWireSendPooledTransactions
+			if payload, ok := data.([]rlp.RawValue); ok {
+				details = append(details, len(payload))
+			} else if err != nil {
+				details = append(details, 0)
+			} else {
+				glog.Fatal("cant cast: PooledTransactionsMsg", direction)
+			}
+		} else {
+			line = mlogWireReceivePooledTransactions
+			if payload, ok := data.([]*types.Transaction); ok {
+				details = append(details, len(payload))
+			} else if err != nil {
+				details = append(details, 0)
+			} else {
+				glog.Fatal("cant cast: PooledTransactionsMsg", direction)
+			}
+		}
+
 	default:
 		line = mlogWireReceiveInvalid
 	}
@@ -665,6 +721,66 @@ var mlogWireReceiveReceipts = &logger.MLogT{
 	}...),
 }
 
+var mlogWireSendNewPooledTransactionHashes = &logger.MLogT{
+	Description: "Called once for each outgoing NewPooledTransactionHashesMsg message.",
+	Receiver:    "WIRE",
+	Verb:        "SEND",
+	Subject:     strings.ToUpper(ProtocolMessageStringer(NewPooledTransactionHashesMsg)),
+	Details: append(mlogWireCommonDetails, []logger.MLogDetailT{
+		{Owner: "MSG", Key: "LEN_ITEMS", Value: "INT"},
+	}...),
+}
+
+var mlogWireReceiveNewPooledTransactionHashes = &logger.MLogT{
+	Description: "Called once for each incoming NewPooledTransactionHashesMsg message.",
+	Receiver:    "WIRE",
+	Verb:        "RECEIVE",
+	Subject:     strings.ToUpper(ProtocolMessageStringer(NewPooledTransactionHashesMsg)),
+	Details: append(mlogWireCommonDetails, []logger.MLogDetailT{
+		{Owner: "MSG", Key: "LEN_ITEMS", Value: "INT"},
+	}...),
+}
+
+var mlogWireSendGetPooledTransactions = &logger.MLogT{
+	Description: "Called once for each outgoing GetPooledTransactionsMsg message.",
+	Receiver:    "WIRE",
+	Verb:        "SEND",
+	Subject:     strings.ToUpper(ProtocolMessageStringer(GetPooledTransactionsMsg)),
+	Details: append(mlogWireCommonDetails, []logger.MLogDetailT{
+		{Owner: "MSG", Key: "LEN_ITEMS", Value: "INT"},
+	}...),
+}
+
+var mlogWireReceiveGetPooledTransactions = &logger.MLogT{
+	Description: "Called once for each incoming GetPooledTransactionsMsg message.",
+	Receiver:    "WIRE",
+	Verb:        "RECEIVE",
+	Subject:     strings.ToUpper(ProtocolMessageStringer(GetPooledTransactionsMsg)),
+	Details: append(mlogWireCommonDetails, []logger.MLogDetailT{
+		{Owner: "MSG", Key: "LEN_ITEMS", Value: "INT"},
+	}...),
+}
+
+var mlogWireSendPooledTransactions = &logger.MLogT{
+	Description: "Called once for each outgoing PooledTransactionsMsg message.",
+	Receiver:    "WIRE",
+	Verb:        "SEND",
+	Subject:     strings.ToUpper(ProtocolMessageStringer(PooledTransactionsMsg)),
+	Details: append(mlogWireCommonDetails, []logger.MLogDetailT{
+		{Owner: "MSG", Key: "LEN_ITEMS", Value: "INT"},
+	}...),
+}
+
+var mlogWireReceivePooledTransactions = &logger.MLogT{
+	Description: "Called once for each incoming PooledTransactionsMsg message.",
+	Receiver:    "WIRE",
+	Verb:        "RECEIVE",
+	Subject:     strings.ToUpper(ProtocolMessageStringer(PooledTransactionsMsg)),
+	Details: append(mlogWireCommonDetails, []logger.MLogDetailT{
+		{Owner: "MSG", Key: "LEN_ITEMS", Value: "INT"},
+	}...),
+}
+
 var mlogWireReceiveInvalid = &logger.MLogT{
 	Description: "Called once for each incoming wire message that is invalid.",
 	Receiver:    "WIRE",
@@ -672,3 +788,41 @@ var mlogWireReceiveInvalid = &logger.MLogT{
 	Subject:     "INVALID",
 	Details:     mlogWireCommonDetails,
 }
+
+var mlogSyncTargetDecision = &logger.MLogT{
+	Description: `Called when the protocol manager evaluates a peer as a candidate sync target.
+
+AGREEING reports how many peers advertise a total difficulty above LOCAL_TD + TD_MARGIN, including the candidate.
+If ACCEPTED is false, REASON explains why the candidate was rejected.`,
+	Receiver: "SYNC",
+	Verb:     "SELECT",
+	Subject:  "TARGET",
+	Details: []logger.MLogDetailT{
+		{Owner: "TARGET", Key: "PEER_ID", Value: "STRING"},
+		{Owner: "TARGET", Key: "HASH", Value: "STRING"},
+		{Owner: "TARGET", Key: "TD", Value: "BIGINT"},
+		{Owner: "SYNC", Key: "LOCAL_TD", Value: "BIGINT"},
+		{Owner: "SYNC", Key: "TD_MARGIN", Value: "BIGINT"},
+		{Owner: "SYNC", Key: "AGREEING", Value: "INT"},
+		{Owner: "SYNC", Key: "MIN_PEERS", Value: "INT"},
+		{Owner: "SELECT", Key: "ACCEPTED", Value: "BOOL"},
+		{Owner: "SELECT", Key: "REASON", Value: "STRING_OR_NULL"},
+	},
+}
+
+var mlogSyncForkDetected = &logger.MLogT{
+	Description: `Called when a branch competing with the local canonical chain grows beyond the alert depth of the fork monitor.
+
+FORK_POINT is the number of the last block the branch shares with the local chain, DEPTH the number of blocks on the branch since.`,
+	Receiver: "SYNC",
+	Verb:     "DETECT",
+	Subject:  "FORK",
+	Details: []logger.MLogDetailT{
+		{Owner: "FORK", Key: "PEER_ID", Value: "STRING"},
+		{Owner: "FORK", Key: "HASH", Value: "STRING"},
+		{Owner: "FORK", Key: "NUMBER", Value: "INT"},
+		{Owner: "FORK", Key: "TD", Value: "BIGINT"},
+		{Owner: "FORK", Key: "FORK_POINT", Value: "INT"},
+		{Owner: "FORK", Key: "DEPTH", Value: "INT"},
+	},
+}
@@ -0,0 +1,82 @@
+package eth
+
+import (
+	"github.com/hashicorp/golang-lru"
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/metrics"
+)
+
+const (
+	defaultNodeDataCache   = 65536 // Number of state entries cached for serving node data requests
+	defaultNodeDataReaders = 4     // Number of node data requests served from disk concurrently
+)
+
+// nodeDataServer retrieves the state entries requested by fast syncing peers.
+// It reads the database bypassing its block cache and keeps a cache of its own,
+// so serving state to others doesn't evict the entries used by local block
+// processing. The number of requests read from disk concurrently is limited,
+// bounding the IO the node spends on helping others sync.
+type nodeDataServer struct {
+	db      ethdb.Database
+	cache   *lru.Cache    // Recently served entries, nil if caching is disabled
+	readers chan struct{} // Semaphore limiting the concurrent requests
+}
+
+// newNodeDataServer creates a node data server with a cache of the given number
+// of entries (0 disabling caching), allowing the given number of requests to be
+// served concurrently.
+func newNodeDataServer(db ethdb.Database, cache int, readers int) *nodeDataServer {
+	if readers <= 0 {
+		readers = defaultNodeDataReaders
+	}
+	s := &nodeDataServer{
+		db:      db,
+		readers: make(chan struct{}, readers),
+	}
+	if cache > 0 {
+		s.cache, _ = lru.New(cache)
+	}
+	return s
+}
+
+// acquire blocks until a request may be served, returning the function that
+// releases the slot once done.
+func (s *nodeDataServer) acquire() func() {
+	s.readers <- struct{}{}
+	return func() { <-s.readers }
+}
+
+// get retrieves the state entry with the given hash.
+func (s *nodeDataServer) get(hash common.Hash) ([]byte, error) {
+	if s.cache != nil {
+		if entry, ok := s.cache.Get(hash); ok {
+			metrics.ServeNodeDataCacheHits.Mark(1)
+			return entry.([]byte), nil
+		}
+		metrics.ServeNodeDataCacheMisses.Mark(1)
+	}
+	var (
+		entry []byte
+		err   error
+	)
+	if db, ok := s.db.(ethdb.UncachedGetter); ok {
+		entry, err = db.GetUncached(hash.Bytes())
+	} else {
+		entry, err = s.db.Get(hash.Bytes())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.cache != nil {
+		s.cache.Add(hash, entry)
+	}
+	return entry, nil
+}
+
+// purge drops the cached state entries.
+func (s *nodeDataServer) purge() {
+	if s.cache != nil {
+		s.cache.Purge()
+	}
+}
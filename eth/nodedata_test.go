@@ -0,0 +1,147 @@
+package eth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/crypto"
+	"github.com/ethereumclassic/go-ethereum/eth/downloader"
+	"github.com/ethereumclassic/go-ethereum/ethdb"
+	"github.com/ethereumclassic/go-ethereum/p2p"
+	"github.com/ethereumclassic/go-ethereum/p2p/discover"
+)
+
+// newNodeDataTestDb creates a database holding the given number of entries,
+// returning their hashes.
+func newNodeDataTestDb(n int) (*ethdb.MemDatabase, []common.Hash) {
+	db, _ := ethdb.NewMemDatabase()
+	hashes := make([]common.Hash, n)
+	for i := range hashes {
+		entry := []byte{0x80, byte(i)}
+		hashes[i] = crypto.Keccak256Hash(entry)
+		db.Put(hashes[i].Bytes(), entry)
+	}
+	return db, hashes
+}
+
+// Tests that the served entries are cached up to the cache limit, with the
+// least recently served ones evicted.
+func TestNodeDataServerCache(t *testing.T) {
+	db, hashes := newNodeDataTestDb(3)
+	server := newNodeDataServer(db, 2, 1)
+
+	for _, hash := range hashes {
+		if _, err := server.get(hash); err != nil {
+			t.Fatalf("failed to retrieve entry %x: %v", hash, err)
+		}
+	}
+	if server.cache.Len() != 2 {
+		t.Fatalf("cache length mismatch: have %d, want 2", server.cache.Len())
+	}
+	// Drop the entries from disk, only the cached ones should be served
+	for _, hash := range hashes {
+		db.Delete(hash.Bytes())
+	}
+	for i, hash := range hashes {
+		entry, err := server.get(hash)
+		if cached := i > 0; cached != (err == nil) {
+			t.Errorf("entry %d: served mismatch: have %v, want %v", i, err == nil, cached)
+		}
+		if err == nil && crypto.Keccak256Hash(entry) != hash {
+			t.Errorf("entry %d: content mismatch: have %x", i, entry)
+		}
+	}
+	// Missing entries aren't cached
+	if _, err := server.get(common.Hash{1}); err == nil {
+		t.Errorf("missing entry served")
+	}
+	if server.cache.Contains(common.Hash{1}) {
+		t.Errorf("missing entry cached")
+	}
+}
+
+// Tests that a cache size of 0 disables caching.
+func TestNodeDataServerNoCache(t *testing.T) {
+	db, hashes := newNodeDataTestDb(1)
+	server := newNodeDataServer(db, 0, 1)
+	if server.cache != nil {
+		t.Fatalf("cache created with caching disabled")
+	}
+	if _, err := server.get(hashes[0]); err != nil {
+		t.Fatalf("failed to retrieve entry: %v", err)
+	}
+	db.Delete(hashes[0].Bytes())
+	if _, err := server.get(hashes[0]); err == nil {
+		t.Errorf("entry served from disabled cache")
+	}
+}
+
+// Tests that no more than the allowed number of requests are served at once.
+func TestNodeDataServerReaders(t *testing.T) {
+	db, _ := newNodeDataTestDb(0)
+	server := newNodeDataServer(db, 0, 2)
+
+	release := server.acquire()
+	server.acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		server.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatalf("reader limit exceeded")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("released slot not reused")
+	}
+}
+
+// Tests that node data requests from peers are served through the cache, and
+// wait for a free reader once the limit is reached.
+func TestServeNodeDataLimits(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 1, nil, nil)
+	pm.nodeData = newNodeDataServer(pm.chaindb, 1, 1)
+	app, net := p2p.MsgPipe()
+	defer app.Close()
+	p := pm.newPeer(eth63, p2p.NewPeer(discover.NodeID{}, "peer", nil), net)
+
+	root := pm.blockchain.CurrentBlock().Root()
+
+	// Block the only reader, the request must wait for it
+	release := pm.nodeData.acquire()
+	go p2p.Send(app, GetNodeDataMsg, []common.Hash{root})
+
+	served := make(chan error, 1)
+	go func() { served <- pm.handleMsg(p) }()
+	select {
+	case <-served:
+		t.Fatalf("request served above the reader limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+
+	msg, err := app.ReadMsg()
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	var data [][]byte
+	if err := msg.Decode(&data); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("failed to serve request: %v", err)
+	}
+	if len(data) != 1 || crypto.Keccak256Hash(data[0]) != root {
+		t.Fatalf("response mismatch: have %x, want state root %x", data, root)
+	}
+	if !pm.nodeData.cache.Contains(root) {
+		t.Errorf("served entry not cached")
+	}
+}
@@ -0,0 +1,123 @@
+package eth
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/openether/ethcore/common"
+)
+
+// nonceManager hands out nonces for transactions created through the RPC API.
+// Nonces are reserved per account until the transaction is either accepted by
+// the pool or abandoned, so concurrent submissions from the same account get
+// sequential nonces without having to wait for each other to be signed and
+// added to the pool.
+type nonceManager struct {
+	poolNonce func(common.Address) uint64 // Next nonce of an account according to the pool
+
+	lock     sync.Mutex
+	accounts map[common.Address]*accountNonces
+}
+
+// accountNonces tracks the nonce reservations of a single account.
+type accountNonces struct {
+	next        uint64              // Next nonce to hand out if none was released
+	outstanding map[uint64]struct{} // Reserved nonces not yet committed or released
+	released    []uint64            // Abandoned nonces to hand out again, sorted
+}
+
+func newNonceManager(poolNonce func(common.Address) uint64) *nonceManager {
+	return &nonceManager{
+		poolNonce: poolNonce,
+		accounts:  make(map[common.Address]*accountNonces),
+	}
+}
+
+// Reserve returns the next nonce to use for a transaction from addr. The caller
+// must either Commit or Release the nonce (or Settle it) once the transaction
+// was submitted.
+func (m *nonceManager) Reserve(addr common.Address) uint64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	base := m.poolNonce(addr)
+	acc := m.accounts[addr]
+	if acc == nil {
+		acc = &accountNonces{next: base, outstanding: make(map[uint64]struct{})}
+		m.accounts[addr] = acc
+	}
+	// Forget about released nonces the pool already moved past
+	for len(acc.released) > 0 && acc.released[0] < base {
+		acc.released = acc.released[1:]
+	}
+	var nonce uint64
+	if len(acc.released) > 0 {
+		nonce, acc.released = acc.released[0], acc.released[1:]
+	} else {
+		if acc.next < base {
+			acc.next = base
+		}
+		nonce = acc.next
+		acc.next++
+	}
+	acc.outstanding[nonce] = struct{}{}
+	return nonce
+}
+
+// Commit marks a reserved nonce as used by a transaction accepted by the pool.
+func (m *nonceManager) Commit(addr common.Address, nonce uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if acc := m.accounts[addr]; acc != nil {
+		delete(acc.outstanding, nonce)
+		m.cleanup(addr, acc)
+	}
+}
+
+// Release hands a reserved nonce back if its transaction couldn't be submitted,
+// so that it is reused by the next reservation instead of leaving a gap.
+func (m *nonceManager) Release(addr common.Address, nonce uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	acc := m.accounts[addr]
+	if acc == nil {
+		return
+	}
+	if _, ok := acc.outstanding[nonce]; !ok {
+		return
+	}
+	delete(acc.outstanding, nonce)
+	if nonce+1 == acc.next {
+		acc.next--
+	} else {
+		acc.released = append(acc.released, nonce)
+		sort.Sort(nonceSlice(acc.released))
+	}
+	m.cleanup(addr, acc)
+}
+
+// Settle commits or releases a reserved nonce depending on whether submitting
+// its transaction failed.
+func (m *nonceManager) Settle(addr common.Address, nonce uint64, err error) {
+	if err != nil {
+		m.Release(addr, nonce)
+	} else {
+		m.Commit(addr, nonce)
+	}
+}
+
+// cleanup drops the tracking of an account without pending reservations, after
+// which the pool is again the single source of truth for its nonce.
+func (m *nonceManager) cleanup(addr common.Address, acc *accountNonces) {
+	if len(acc.outstanding) == 0 && len(acc.released) == 0 {
+		delete(m.accounts, addr)
+	}
+}
+
+type nonceSlice []uint64
+
+func (s nonceSlice) Len() int           { return len(s) }
+func (s nonceSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s nonceSlice) Less(i, j int) bool { return s[i] < s[j] }
@@ -0,0 +1,119 @@
+package eth
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+)
+
+func TestNonceManagerReserve(t *testing.T) {
+	var (
+		addr      = common.Address{0x01}
+		poolNonce = uint64(3)
+	)
+	m := newNonceManager(func(common.Address) uint64 { return poolNonce })
+
+	// Reservations are sequential from the pool nonce, and per account
+	for want := uint64(3); want < 6; want++ {
+		if nonce := m.Reserve(addr); nonce != want {
+			t.Fatalf("reservation mismatch: have %d, want %d", nonce, want)
+		}
+	}
+	if nonce := m.Reserve(common.Address{0x02}); nonce != 3 {
+		t.Errorf("other account reservation mismatch: have %d, want 3", nonce)
+	}
+	// Released nonces are handed out again, lowest first, before new ones
+	m.Release(addr, 4)
+	m.Release(addr, 3)
+	m.Release(addr, 3)
+	for _, want := range []uint64{3, 4, 6} {
+		if nonce := m.Reserve(addr); nonce != want {
+			t.Fatalf("reservation after release mismatch: have %d, want %d", nonce, want)
+		}
+	}
+	// Releasing the last reserved nonce doesn't leave a gap
+	m.Release(addr, 6)
+	if nonce := m.Reserve(addr); nonce != 6 {
+		t.Errorf("reservation after releasing the last mismatch: have %d, want 6", nonce)
+	}
+}
+
+func TestNonceManagerSettle(t *testing.T) {
+	var (
+		addr      = common.Address{0x01}
+		poolNonce = uint64(0)
+	)
+	m := newNonceManager(func(common.Address) uint64 { return poolNonce })
+
+	first, second := m.Reserve(addr), m.Reserve(addr)
+	m.Settle(addr, second, errors.New("rejected"))
+	m.Settle(addr, first, nil)
+	poolNonce = 1
+
+	// Once nothing is reserved the account is forgotten, the pool being authoritative
+	if len(m.accounts) != 0 {
+		t.Fatalf("settled account still tracked: %+v", m.accounts[addr])
+	}
+	if nonce := m.Reserve(addr); nonce != 1 {
+		t.Errorf("reservation after settling mismatch: have %d, want 1", nonce)
+	}
+	// Released nonces the pool moved past are skipped, as are pending reservations
+	m.Reserve(addr)
+	m.Reserve(addr)
+	m.Release(addr, 1)
+	poolNonce = 5
+	if nonce := m.Reserve(addr); nonce != 5 {
+		t.Errorf("reservation after pool advanced mismatch: have %d, want 5", nonce)
+	}
+}
+
+// Tests that concurrent reservations of an account never share a nonce.
+func TestNonceManagerConcurrency(t *testing.T) {
+	var (
+		addr = common.Address{0x01}
+		m    = newNonceManager(func(common.Address) uint64 { return 0 })
+		lock sync.Mutex
+		seen = make(map[uint64]bool)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				nonce := m.Reserve(addr)
+				lock.Lock()
+				if seen[nonce] {
+					t.Errorf("nonce %d reserved twice", nonce)
+				}
+				seen[nonce] = true
+				lock.Unlock()
+
+				// Release a few to exercise the reuse, forgetting them as handed out again
+				if (i+j)%7 == 0 {
+					lock.Lock()
+					delete(seen, nonce)
+					lock.Unlock()
+					m.Release(addr, nonce)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Every nonce below the next one is either reserved or released
+	acc := m.accounts[addr]
+	for _, nonce := range acc.released {
+		seen[nonce] = true
+	}
+	if uint64(len(seen)) != acc.next {
+		t.Errorf("nonces handed out mismatch: have %d, want %d", len(seen), acc.next)
+	}
+	for nonce := uint64(0); nonce < acc.next; nonce++ {
+		if !seen[nonce] {
+			t.Errorf("nonce %d skipped", nonce)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package eth
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/openether/ethcore/accounts"
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/event"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+// payoutConfirmations is the number of blocks on top of a block before its reward
+// is paid out, so rewards of blocks that end up orphaned aren't split.
+const payoutConfirmations = 7
+
+// PayoutShare is the percentage of the rewards of mined blocks paid to an address.
+type PayoutShare struct {
+	Address common.Address
+	Percent uint64
+}
+
+// ParsePayoutSplit parses a comma separated list of address:percent pairs. The
+// percentages may add up to at most 100, the remainder stays with the coinbase.
+func ParsePayoutSplit(s string) ([]PayoutShare, error) {
+	var (
+		shares []PayoutShare
+		total  uint64
+	)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 2 || !common.IsHexAddress(parts[0]) {
+			return nil, fmt.Errorf("invalid payout share %q, want address:percent", entry)
+		}
+		percent, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil || percent == 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid percentage in payout share %q", entry)
+		}
+		if total += percent; total > 100 {
+			return nil, fmt.Errorf("payout shares add up to more than 100%%")
+		}
+		shares = append(shares, PayoutShare{Address: common.HexToAddress(parts[0]), Percent: percent})
+	}
+	return shares, nil
+}
+
+// maxPayoutScan is the number of confirmed blocks checked for payouts on a new
+// head at most, bounding the work when the head jumps far ahead.
+const maxPayoutScan = 1024
+
+// payoutSplitter pays out shares of the rewards of the blocks credited to a local
+// account to the configured addresses, once the blocks are confirmed in the
+// canonical chain. There's no local miner in this client, so the blocks are
+// recognised by their coinbase rather than by who sealed them. The payout
+// transactions are signed by the coinbase, whose account must be unlocked.
+//
+// Payouts aren't persisted: on start, only the blocks not yet confirmed at the
+// current head are paid out once confirmed.
+type payoutSplitter struct {
+	chain   *core.BlockChain
+	chainDb ethdb.Database
+	pool    *core.TxPool
+	am      *accounts.Manager
+	nonces  *nonceManager
+	price   func() *big.Int // Gas price of the payout transactions
+	mux     *event.TypeMux
+	shares  []PayoutShare
+
+	next uint64 // Number of the next canonical block whose reward may be paid out
+	sub  event.Subscription
+	wg   sync.WaitGroup
+}
+
+func newPayoutSplitter(chain *core.BlockChain, chainDb ethdb.Database, pool *core.TxPool, am *accounts.Manager, nonces *nonceManager, price func() *big.Int, mux *event.TypeMux, shares []PayoutShare) *payoutSplitter {
+	return &payoutSplitter{
+		chain:   chain,
+		chainDb: chainDb,
+		pool:    pool,
+		am:      am,
+		nonces:  nonces,
+		price:   price,
+		mux:     mux,
+		shares:  shares,
+	}
+}
+
+// start begins paying out the blocks confirmed from the current head on.
+func (p *payoutSplitter) start() {
+	p.next = 1
+	if head := p.chain.CurrentFastBlock().NumberU64(); head >= payoutConfirmations {
+		p.next = head - payoutConfirmations + 1
+	}
+	p.sub = p.mux.Subscribe(core.ReceiptChainInsertEvent{})
+	p.wg.Add(1)
+	go p.loop()
+}
+
+// stop terminates the payouts.
+func (p *payoutSplitter) stop() {
+	p.sub.Unsubscribe()
+	p.wg.Wait()
+}
+
+func (p *payoutSplitter) loop() {
+	defer p.wg.Done()
+
+	// Blocks aren't executed on import, they arrive along with their receipts
+	for range p.sub.Chan() {
+		p.confirm(p.chain.CurrentFastBlock().NumberU64())
+	}
+}
+
+// confirm pays out the rewards of the canonical blocks of local accounts which
+// became deep enough below the head.
+func (p *payoutSplitter) confirm(head uint64) {
+	if head < payoutConfirmations {
+		return
+	}
+	last := head - payoutConfirmations
+	if p.next+maxPayoutScan <= last {
+		glog.V(logger.Warn).Infof("Skipping payouts of blocks #%d-#%d", p.next, last-maxPayoutScan)
+		p.next = last - maxPayoutScan + 1
+	}
+	for ; p.next <= last; p.next++ {
+		block := p.chain.GetBlockByNumber(p.next)
+		if block == nil || !p.am.HasAddress(block.Coinbase()) {
+			continue
+		}
+		reward := blockReward(p.chain.Config(), p.chainDb, block)
+		for _, share := range p.shares {
+			amount := new(big.Int).Mul(reward, new(big.Int).SetUint64(share.Percent))
+			amount.Div(amount, big.NewInt(100))
+
+			hash, err := p.pay(block.Coinbase(), share.Address, amount)
+			if err != nil {
+				glog.V(logger.Error).Errorf("Failed to pay %d%% of block #%d reward to %x: %v", share.Percent, block.NumberU64(), share.Address, err)
+				continue
+			}
+			glog.V(logger.Info).Infof("Paid %v wei (%d%% of block #%d reward) to %x in tx %x", amount, share.Percent, block.NumberU64(), share.Address, hash)
+		}
+	}
+}
+
+// pay submits a transaction transferring amount from the coinbase to the given
+// address.
+func (p *payoutSplitter) pay(from, to common.Address, amount *big.Int) (hash common.Hash, err error) {
+	nonce := p.nonces.Reserve(from)
+	defer func() { p.nonces.Settle(from, nonce, err) }()
+
+	tx := types.NewTransaction(nonce, to, amount, big.NewInt(21000), p.price(), nil)
+	signer := p.chain.Config().GetSigner(p.chain.CurrentBlock().Number())
+	tx.SetSigner(signer)
+
+	signature, err := p.am.Sign(from, signer.Hash(tx).Bytes())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return submitTransaction(p.chain, p.pool, tx, signature)
+}
+
+// blockReward returns the total reward a block earned its coinbase: the block and
+// uncle inclusion rewards plus the fees of its transactions.
+func blockReward(config *core.ChainConfig, db ethdb.Database, block *types.Block) *big.Int {
+	reward, _ := core.BlockRewards(config, block.Header(), block.Uncles())
+
+	receipts := core.GetBlockReceipts(db, block.Hash())
+	for i, tx := range block.Transactions() {
+		if i < len(receipts) && receipts[i].GasUsed != nil {
+			reward.Add(reward, new(big.Int).Mul(receipts[i].GasUsed, tx.GasPrice()))
+		}
+	}
+	return reward
+}
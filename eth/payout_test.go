@@ -0,0 +1,192 @@
+package eth
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/accounts"
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/state"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/ethdb"
+	"github.com/ethereumclassic/go-ethereum/event"
+)
+
+func TestParsePayoutSplit(t *testing.T) {
+	addr1, addr2 := "0x0000000000000000000000000000000000000001", "0x0000000000000000000000000000000000000002"
+	tests := []struct {
+		input string
+		want  []PayoutShare
+		fails bool
+	}{
+		{input: "", want: nil},
+		{input: addr1 + ":30, " + addr2 + ":70", want: []PayoutShare{{common.HexToAddress(addr1), 30}, {common.HexToAddress(addr2), 70}}},
+		{input: addr1 + ":60," + addr2 + ":41", fails: true},
+		{input: addr1 + ":0", fails: true},
+		{input: addr1, fails: true},
+		{input: "0x12:10", fails: true},
+	}
+	for i, tt := range tests {
+		shares, err := ParsePayoutSplit(tt.input)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if len(shares) != len(tt.want) {
+			t.Errorf("test %d: shares mismatch: have %v, want %v", i, shares, tt.want)
+			continue
+		}
+		for j := range shares {
+			if shares[j] != tt.want[j] {
+				t.Errorf("test %d: share %d mismatch: have %v, want %v", i, j, shares[j], tt.want[j])
+			}
+		}
+	}
+}
+
+// Tests that the rewards of the confirmed canonical blocks credited to a local
+// account are split, once each.
+// newPayoutTestAccounts creates an account manager holding the unlocked test bank.
+func newPayoutTestAccounts(t *testing.T) (*accounts.Manager, func()) {
+	keydir, err := ioutil.TempDir("", "payout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	am, err := accounts.NewManager(keydir, accounts.LightScryptN, accounts.LightScryptP, false)
+	if err != nil {
+		os.RemoveAll(keydir)
+		t.Fatal(err)
+	}
+	account, err := am.ImportECDSA(testBankKey, "")
+	if err == nil {
+		err = am.Unlock(account, "")
+	}
+	if err != nil {
+		os.RemoveAll(keydir)
+		t.Fatal(err)
+	}
+	return am, func() { os.RemoveAll(keydir) }
+}
+
+func TestPayoutSplitter(t *testing.T) {
+	other := common.HexToAddress("0x1234")
+	api, chain := newTestBlockChainAPI(12, func(i int, block *core.BlockGen) {
+		if i == 1 || i == 2 || i == 10 {
+			block.SetCoinbase(testBank.Address)
+		} else {
+			block.SetCoinbase(other)
+		}
+	})
+	pool := core.NewTxPool(chain.Config(), new(event.TypeMux), chain.State, func() *big.Int { return chain.CurrentBlock().GasLimit() })
+	pool.Reset(chain.CurrentBlock())
+
+	am, cleanup := newPayoutTestAccounts(t)
+	defer cleanup()
+
+	var (
+		shareA = common.HexToAddress("0xaaaa")
+		shareB = common.HexToAddress("0xbbbb")
+		nonces = newNonceManager(func(addr common.Address) uint64 { return pool.State().GetNonce(addr) })
+		price  = func() *big.Int { return big.NewInt(1) }
+		shares = []PayoutShare{{shareA, 30}, {shareB, 20}}
+	)
+	p := newPayoutSplitter(chain, api.chainDb, pool, am, nonces, price, new(event.TypeMux), shares)
+	p.next = 1
+
+	// Blocks #2 and #3 are confirmed at head #10, #11 only at #18
+	p.confirm(10)
+	p.confirm(10)
+	txs := pool.GetTransactions()
+	if len(txs) != 4 {
+		t.Fatalf("payouts mismatch: have %d transactions, want 4", len(txs))
+	}
+	paid := make(map[common.Address]*big.Int)
+	for _, tx := range txs {
+		if from, _ := tx.From(); from != testBank.Address {
+			t.Errorf("payout from %x, want %x", from, testBank.Address)
+		}
+		if paid[*tx.To()] == nil {
+			paid[*tx.To()] = new(big.Int)
+		}
+		paid[*tx.To()].Add(paid[*tx.To()], tx.Value())
+	}
+	reward := new(big.Int).Add(blockReward(chain.Config(), api.chainDb, chain.GetBlockByNumber(2)), blockReward(chain.Config(), api.chainDb, chain.GetBlockByNumber(3)))
+	for _, share := range shares {
+		want := new(big.Int).Mul(reward, new(big.Int).SetUint64(share.Percent))
+		want.Div(want, big.NewInt(100))
+		// Each block's share is rounded down on its own
+		if have := paid[share.Address]; have == nil || new(big.Int).Sub(want, have).Cmp(big.NewInt(1)) > 0 || have.Cmp(want) > 0 {
+			t.Errorf("payout to %x mismatch: have %v, want %v", share.Address, have, want)
+		}
+	}
+	if p.next != 4 {
+		t.Errorf("next block mismatch: have %d, want 4", p.next)
+	}
+	p.confirm(18)
+	if txs := pool.GetTransactions(); len(txs) != 6 {
+		t.Errorf("payouts mismatch: have %d transactions, want 6", len(txs))
+	}
+}
+
+// Tests that the payouts follow the blocks imported into the chain, as the node
+// imports them along with their receipts.
+func TestPayoutSplitterImport(t *testing.T) {
+	var (
+		evmux      = new(event.TypeMux)
+		db, _      = ethdb.NewMemDatabase()
+		genesis    = core.WriteGenesisBlockForTesting(db, testBank)
+		config     = core.DefaultConfigMorden.ChainConfig
+		chain, err = core.NewBlockChain(db, config, evmux)
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, receipts := core.GenerateChain(config, genesis, db, payoutConfirmations+2, func(i int, block *core.BlockGen) {
+		if i == 1 {
+			block.SetCoinbase(testBank.Address)
+		}
+	})
+	// The bank only affords the payouts with the rewards of the imported blocks
+	head := blocks[len(blocks)-1]
+	pool := core.NewTxPool(chain.Config(), new(event.TypeMux), func() (*state.StateDB, error) { return chain.StateAt(head.Root()) }, head.GasLimit)
+	pool.Reset(head)
+
+	am, cleanup := newPayoutTestAccounts(t)
+	defer cleanup()
+
+	nonces := newNonceManager(func(addr common.Address) uint64 { return pool.State().GetNonce(addr) })
+	price := func() *big.Int { return big.NewInt(1) }
+	p := newPayoutSplitter(chain, db, pool, am, nonces, price, evmux, []PayoutShare{{common.HexToAddress("0xaaaa"), 50}})
+	p.start()
+	defer p.stop()
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if res := chain.InsertHeaderChain(headers, 1); res.Error != nil {
+		t.Fatalf("failed to insert header %d: %v", res.Index, res.Error)
+	}
+	if res := chain.InsertReceiptChain(blocks, receipts); res.Error != nil {
+		t.Fatalf("failed to insert receipts %d: %v", res.Index, res.Error)
+	}
+	// Block #2 is confirmed at the imported head #9
+	for start := time.Now(); len(pool.GetTransactions()) == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("imported block not paid out")
+		}
+	}
+	if txs := pool.GetTransactions(); len(txs) != 1 || *txs[0].To() != common.HexToAddress("0xaaaa") {
+		t.Errorf("payouts mismatch: %v", txs)
+	}
+}
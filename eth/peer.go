@@ -3,35 +3,44 @@ package eth
 import (
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"sync"
 	"time"
 
 	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/forkid"
 	"github.com/openether/ethcore/core/types"
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
 	"github.com/openether/ethcore/p2p"
 	"github.com/openether/ethcore/rlp"
-
-	"gopkg.in/fatih/set.v0"
 )
 
 var (
 	errClosed            = errors.New("peer set is closed")
 	errAlreadyRegistered = errors.New("peer is already registered")
 	errNotRegistered     = errors.New("peer is not registered")
+	errTooManyTxRequests = errors.New("too many pending transaction requests")
 )
 
 const (
 	maxKnownTxs    = 32768 // Maximum transactions hashes to keep in the known list (prevent DOS)
 	maxKnownBlocks = 1024  // Maximum block hashes to keep in the known list (prevent DOS)
 
+	knownTxLifetime    = 10 * time.Minute // Time after which a transaction is no longer considered known by a peer
+	knownBlockLifetime = time.Hour        // Time after which a block is no longer considered known by a peer
+
 	// maxQueuedTxs is the maximum number of transaction lists to queue up before
 	// dropping broadcasts. This is a sensitive number as a transaction list might
 	// contain a single transaction, or thousands.
 	maxQueuedTxs = 128
 
+	// maxQueuedTxAnns is the maximum number of transaction announcement lists to
+	// queue up before dropping broadcasts. Announcements are small, so it's fine
+	// to be as generous as with full transactions.
+	maxQueuedTxAnns = 128
+
 	// maxQueuedProps is the maximum number of block propagations to queue up before
 	// dropping broadcasts. There's not much point in queueing stale blocks, so a few
 	// that might cover uncles should be enough.
@@ -42,6 +51,11 @@ const (
 	// above some healthy uncle limit, so use that.
 	maxQueuedAnns = 4
 
+	// maxTxRequests is the maximum number of transaction batches requested from a
+	// peer that weren't delivered yet. Deliveries answer requests in order, so
+	// each has to be remembered to tell whether the transactions were requested.
+	maxTxRequests = 16
+
 	handshakeTimeout = 5 * time.Second
 )
 
@@ -72,29 +86,39 @@ type peer struct {
 	td   *big.Int
 	lock sync.RWMutex
 
-	knownTxs    *set.Set // Set of transaction hashes known to be known by this peer
-	knownBlocks *set.Set // Set of block hashes known to be known by this peer
+	knownTxs    *knownCache // Set of transaction hashes known to be known by this peer
+	knownBlocks *knownCache // Set of block hashes known to be known by this peer
+
+	txThrottle    *txThrottle    // Limits the transaction gossip accepted from this peer
+	serveThrottle *serveThrottle // Limits the chain data served to this peer
 
-	queuedTxs   chan []*types.Transaction // Queue of transactions to broadcast to the peer
-	queuedProps chan *propEvent           // Queue of blocks to broadcast to the peer
-	queuedAnns  chan *types.Block         // Queue of blocks to announce to the peer
-	term        chan struct{}             // Termination channel to stop the broadcaster
+	txRequests    []*txRequest // Batches of transactions requested but not delivered yet, oldest first
+	txRequestLock sync.Mutex
+
+	queuedTxs    chan []*types.Transaction // Queue of transactions to broadcast to the peer
+	queuedTxAnns chan []common.Hash        // Queue of transaction hashes to announce to the peer
+	queuedProps  chan *propEvent           // Queue of blocks to broadcast to the peer
+	queuedAnns   chan *types.Block         // Queue of blocks to announce to the peer
+	term         chan struct{}             // Termination channel to stop the broadcaster
 }
 
-func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter, limits ServeLimits) *peer {
 	id := p.ID()
 
 	return &peer{
-		Peer:        p,
-		rw:          rw,
-		version:     version,
-		id:          fmt.Sprintf("%x", id[:8]),
-		knownTxs:    set.New(),
-		knownBlocks: set.New(),
-		queuedTxs:   make(chan []*types.Transaction, maxQueuedTxs),
-		queuedProps: make(chan *propEvent, maxQueuedProps),
-		queuedAnns:  make(chan *types.Block, maxQueuedAnns),
-		term:        make(chan struct{}),
+		Peer:          p,
+		rw:            rw,
+		version:       version,
+		id:            fmt.Sprintf("%x", id[:8]),
+		knownTxs:      newKnownCache(maxKnownTxs, knownTxLifetime),
+		knownBlocks:   newKnownCache(maxKnownBlocks, knownBlockLifetime),
+		txThrottle:    newTxThrottle(txGossipRate, txGossipBurst, maxTxRelayedPerPeer),
+		serveThrottle: newServeThrottle(limits),
+		queuedTxs:     make(chan []*types.Transaction, maxQueuedTxs),
+		queuedTxAnns:  make(chan []common.Hash, maxQueuedTxAnns),
+		queuedProps:   make(chan *propEvent, maxQueuedProps),
+		queuedAnns:    make(chan *types.Block, maxQueuedAnns),
+		term:          make(chan struct{}),
 	}
 }
 
@@ -110,6 +134,12 @@ func (p *peer) broadcast() {
 			}
 			glog.V(logger.Detail).Infoln("Broadcast transactions", "count", len(txs))
 
+		case hashes := <-p.queuedTxAnns:
+			if err := p.SendTransactionHashes(hashes); err != nil {
+				return
+			}
+			glog.V(logger.Detail).Infoln("Announced transactions", "count", len(hashes))
+
 		case prop := <-p.queuedProps:
 			if err := p.SendNewBlock(prop.block, prop.td); err != nil {
 				return
@@ -166,20 +196,12 @@ func (p *peer) SetHead(hash common.Hash, td *big.Int) {
 // MarkBlock marks a block as known for the peer, ensuring that the block will
 // never be propagated to this particular peer.
 func (p *peer) MarkBlock(hash common.Hash) {
-	// If we reached the memory allowance, drop a previously known block hash
-	for p.knownBlocks.Size() >= maxKnownBlocks {
-		p.knownBlocks.Pop()
-	}
 	p.knownBlocks.Add(hash)
 }
 
 // MarkTransaction marks a transaction as known for the peer, ensuring that it
 // will never be propagated to this particular peer.
 func (p *peer) MarkTransaction(hash common.Hash) {
-	// If we reached the memory allowance, drop a previously known transaction hash
-	for p.knownTxs.Size() >= maxKnownTxs {
-		p.knownTxs.Pop()
-	}
 	p.knownTxs.Add(hash)
 }
 
@@ -207,6 +229,43 @@ func (p *peer) AsyncSendTransactions(txs []*types.Transaction) {
 	}
 }
 
+// SendTransactionHashes announces the availability of a number of transactions
+// to the peer, without sending their bodies. The peer is expected to request
+// them if it doesn't know them yet.
+func (p *peer) SendTransactionHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	s, e := p2p.Send(p.rw, NewPooledTransactionHashesMsg, hashes)
+	mlogWireDelegate(p, "send", NewPooledTransactionHashesMsg, s, hashes, nil)
+	return e
+}
+
+// AsyncSendTransactionHashes queues a list of transaction announcements to a
+// remote peer. If the peer's broadcast queue is full, the event is silently
+// dropped.
+func (p *peer) AsyncSendTransactionHashes(hashes []common.Hash) {
+	select {
+	case p.queuedTxAnns <- hashes:
+		for _, hash := range hashes {
+			p.knownTxs.Add(hash)
+		}
+	default:
+		glog.V(logger.Debug).Infoln("Dropping transaction announcement", "count", len(hashes))
+	}
+}
+
+// SendPooledTransactionsRLP sends a batch of requested transactions to the
+// remote peer from an already RLP encoded format.
+func (p *peer) SendPooledTransactionsRLP(hashes []common.Hash, txs []rlp.RawValue) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	s, e := p2p.Send(p.rw, PooledTransactionsMsg, txs)
+	mlogWireDelegate(p, "send", PooledTransactionsMsg, s, txs, nil)
+	return e
+}
+
 // SendNewBlockHashes announces the availability of a number of blocks through
 // a hash notification.
 func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error {
@@ -292,6 +351,85 @@ func (p *peer) SendReceiptsRLP(receipts []rlp.RawValue) error {
 	return e
 }
 
+// txRequest is a batch of transactions requested from a peer.
+type txRequest struct {
+	hashes map[common.Hash]bool
+}
+
+// RequestTxs fetches a batch of announced transactions from the remote peer.
+// It is used solely by the transaction fetcher.
+func (p *peer) RequestTxs(hashes []common.Hash) error {
+	req, err := p.trackTxRequest(hashes)
+	if err != nil {
+		return err
+	}
+	glog.V(logger.Debug).Infof("fetching from: %v req=pooledtxs count=%d", p, len(hashes))
+	s, e := p2p.Send(p.rw, GetPooledTransactionsMsg, hashes)
+	mlogWireDelegate(p, "send", GetPooledTransactionsMsg, s, hashes, nil)
+	if e != nil {
+		p.untrackTxRequest(req)
+	}
+	return e
+}
+
+// trackTxRequest records a batch of transactions as requested from the peer.
+func (p *peer) trackTxRequest(hashes []common.Hash) (*txRequest, error) {
+	p.txRequestLock.Lock()
+	defer p.txRequestLock.Unlock()
+
+	if len(p.txRequests) >= maxTxRequests {
+		return nil, errTooManyTxRequests
+	}
+	req := &txRequest{hashes: make(map[common.Hash]bool, len(hashes))}
+	for _, hash := range hashes {
+		req.hashes[hash] = true
+	}
+	p.txRequests = append(p.txRequests, req)
+	return req, nil
+}
+
+// untrackTxRequest forgets a batch of transactions which won't be delivered.
+func (p *peer) untrackTxRequest(req *txRequest) {
+	p.txRequestLock.Lock()
+	defer p.txRequestLock.Unlock()
+
+	for i, pending := range p.txRequests {
+		if pending == req {
+			p.txRequests = append(p.txRequests[:i], p.txRequests[i+1:]...)
+			return
+		}
+	}
+}
+
+// deliverTxs matches delivered transactions with the oldest outstanding batch
+// containing all of them, as the fetcher's requests may be sent in any order.
+// It fails if there was no request or a transaction wasn't part of any, in which
+// case the oldest request is dropped.
+func (p *peer) deliverTxs(txs []*types.Transaction) error {
+	p.txRequestLock.Lock()
+	defer p.txRequestLock.Unlock()
+
+	if len(p.txRequests) == 0 {
+		return errors.New("no transactions requested")
+	}
+	for i, req := range p.txRequests {
+		matched := true
+		for _, tx := range txs {
+			if !req.hashes[tx.Hash()] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			p.txRequests = append(p.txRequests[:i], p.txRequests[i+1:]...)
+			return nil
+		}
+	}
+	p.txRequests = p.txRequests[1:]
+
+	return fmt.Errorf("delivery of %d transactions matches no request", len(txs))
+}
+
 // RequestHeaders is a wrapper around the header query functions to fetch a
 // single header. It is used solely by the fetcher.
 func (p *peer) RequestOneHeader(hash common.Hash) error {
@@ -349,8 +487,9 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 }
 
 // Handshake executes the eth protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
+// network IDs, difficulties, head and genesis blocks, and from eth/65 on the
+// fork identifiers.
+func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID, forkFilter forkid.Filter) error {
 	// Send out own handshake in a new thread
 	sendErrc := make(chan error, 1)
 	recErrc := make(chan error, 1)
@@ -365,16 +504,27 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 		CurrentBlock:    head,
 		GenesisBlock:    genesis,
 	}
+	var packet interface{} = d
+	if p.version >= eth65 {
+		packet = &statusData65{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       network,
+			TD:              td,
+			CurrentBlock:    head,
+			GenesisBlock:    genesis,
+			ForkID:          forkID,
+		}
+	}
 
 	go func() {
 		var e error
-		sendSize, e = p2p.Send(p.rw, StatusMsg, d)
+		sendSize, e = p2p.Send(p.rw, StatusMsg, packet)
 		sendErrc <- e
 	}()
 	go func() {
 		var e error
 		var s uint32
-		s, e = p.readStatusReturnSize(network, &status, genesis)
+		s, e = p.readStatusReturnSize(network, &status, genesis, forkFilter)
 		recSize = int(s)
 		recErrc <- e
 	}()
@@ -405,7 +555,7 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 	return nil
 }
 
-func (p *peer) readStatusReturnSize(network uint64, status *statusData, genesis common.Hash) (size uint32, err error) {
+func (p *peer) readStatusReturnSize(network uint64, status *statusData, genesis common.Hash, forkFilter forkid.Filter) (size uint32, err error) {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
 		return msg.Size, err
@@ -417,7 +567,24 @@ func (p *peer) readStatusReturnSize(network uint64, status *statusData, genesis
 		return msg.Size, errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
 	}
 	// Decode the handshake and make sure everything matches
-	if err := msg.Decode(&status); err != nil {
+	var forkID *forkid.ID
+	if p.version >= eth65 {
+		var status65 statusData65
+		if err := msg.Decode(&status65); err != nil {
+			return msg.Size, errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if status65.NetworkId > math.MaxUint32 {
+			return msg.Size, errResp(ErrNetworkIdMismatch, "%d (!= %d)", status65.NetworkId, network)
+		}
+		*status = statusData{
+			ProtocolVersion: status65.ProtocolVersion,
+			NetworkId:       uint32(status65.NetworkId),
+			TD:              status65.TD,
+			CurrentBlock:    status65.CurrentBlock,
+			GenesisBlock:    status65.GenesisBlock,
+		}
+		forkID = &status65.ForkID
+	} else if err := msg.Decode(&status); err != nil {
 		return msg.Size, errResp(ErrDecode, "msg %v: %v", msg, err)
 	}
 	if status.GenesisBlock != genesis {
@@ -429,11 +596,16 @@ func (p *peer) readStatusReturnSize(network uint64, status *statusData, genesis
 	if int(status.ProtocolVersion) != p.version {
 		return msg.Size, errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
 	}
+	if forkID != nil {
+		if err := forkFilter(*forkID); err != nil {
+			return msg.Size, errResp(ErrForkIDRejected, "%x/%d: %v", forkID.Hash, forkID.Next, err)
+		}
+	}
 	return msg.Size, nil
 }
 
-func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
-	_, err = p.readStatusReturnSize(network, status, genesis)
+func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash, forkFilter forkid.Filter) (err error) {
+	_, err = p.readStatusReturnSize(network, status, genesis, forkFilter)
 	return
 }
 
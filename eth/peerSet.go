@@ -119,6 +119,21 @@ func (ps *peerSet) BestPeer() *peer {
 	return bestPeer
 }
 
+// CountHead returns the number of known peers advertising the given head with a
+// total difficulty strictly greater than the given one.
+func (ps *peerSet) CountHead(head common.Hash, td *big.Int) int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	count := 0
+	for _, p := range ps.peers {
+		if phead, ptd := p.Head(); phead == head && ptd.Cmp(td) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
 // Close disconnects all peers.
 // No new peers can be registered after Close has returned.
 func (ps *peerSet) Close() {
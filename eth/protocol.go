@@ -6,6 +6,7 @@ import (
 	"math/big"
 
 	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/forkid"
 	"github.com/openether/ethcore/core/types"
 	"github.com/openether/ethcore/rlp"
 )
@@ -14,16 +15,17 @@ import (
 const (
 	eth62 = 62
 	eth63 = 63
+	eth65 = 65
 )
 
 // Official short name of the protocol used during capability negotiation.
 var ProtocolName = "eth"
 
 // Supported versions of the eth protocol (first is primary).
-var ProtocolVersions = []uint{eth63, eth62}
+var ProtocolVersions = []uint{eth65, eth63, eth62}
 
 // Number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{17, 8}
+var ProtocolLengths = []uint64{17, 17, 8}
 
 const (
 	NetworkId          = 1
@@ -47,6 +49,11 @@ const (
 	NodeDataMsg    = 0x0e
 	GetReceiptsMsg = 0x0f
 	ReceiptsMsg    = 0x10
+
+	// Protocol messages belonging to eth/65
+	NewPooledTransactionHashesMsg = 0x08
+	GetPooledTransactionsMsg      = 0x09
+	PooledTransactionsMsg         = 0x0a
 )
 
 func ProtocolMessageStringer(m uint) string {
@@ -75,6 +82,12 @@ func ProtocolMessageStringer(m uint) string {
 		return "GetReceipts"
 	case ReceiptsMsg:
 		return "Receipts"
+	case NewPooledTransactionHashesMsg:
+		return "NewPooledTransactionHashes"
+	case GetPooledTransactionsMsg:
+		return "GetPooledTransactions"
+	case PooledTransactionsMsg:
+		return "PooledTransactions"
 	default:
 		return "Unknown"
 	}
@@ -92,6 +105,8 @@ const (
 	ErrNoStatusMsg
 	ErrExtraStatusMsg
 	ErrSuspendedPeer
+	ErrForkIDRejected
+	ErrUnrequestedResponse
 )
 
 func (e errCode) String() string {
@@ -109,6 +124,8 @@ var errorToString = map[int]string{
 	ErrNoStatusMsg:             "No status message",
 	ErrExtraStatusMsg:          "Extra status message",
 	ErrSuspendedPeer:           "Suspended peer",
+	ErrForkIDRejected:          "Fork ID rejected",
+	ErrUnrequestedResponse:     "Unrequested response",
 }
 
 type txPool interface {
@@ -118,6 +135,18 @@ type txPool interface {
 	// GetTransactions should return pending transactions.
 	// The slice should be modifiable by the caller.
 	GetTransactions() types.Transactions
+
+	// GetTransaction should return the transaction with the given hash if it
+	// is contained in the pool, nil otherwise.
+	GetTransaction(hash common.Hash) *types.Transaction
+
+	// Relayable should report whether the given transaction may be propagated
+	// to other peers.
+	Relayable(tx *types.Transaction) bool
+
+	// Private should report whether the transaction with the given hash is kept
+	// from the public network, only to be sent to the private relays.
+	Private(hash common.Hash) bool
 }
 
 // statusData is the network packet for the status message.
@@ -129,6 +158,17 @@ type statusData struct {
 	GenesisBlock    common.Hash
 }
 
+// statusData65 is the network packet for the status message of eth/65, which
+// carries the fork identifier of EIP-2124.
+type statusData65 struct {
+	ProtocolVersion uint32
+	NetworkId       uint64
+	TD              *big.Int
+	CurrentBlock    common.Hash
+	GenesisBlock    common.Hash
+	ForkID          forkid.ID
+}
+
 // newBlockData is the network packet for the block propagation message.
 type newBlockData struct {
 	Block *types.Block
@@ -23,10 +23,12 @@ import (
 	"time"
 
 	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core/forkid"
 	"github.com/ethereumclassic/go-ethereum/core/types"
 	"github.com/ethereumclassic/go-ethereum/crypto"
 	"github.com/ethereumclassic/go-ethereum/eth/downloader"
 	"github.com/ethereumclassic/go-ethereum/p2p"
+	"github.com/ethereumclassic/go-ethereum/p2p/discover"
 	"github.com/ethereumclassic/go-ethereum/rlp"
 )
 
@@ -90,6 +92,134 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that eth/65 handshakes check the fork ID advertised by the remote side.
+func TestStatusMsgErrors65(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	td, currentBlock, genesis := pm.blockchain.Status()
+	defer pm.Stop()
+
+	forkID := forkid.NewID(pm.chainConfig, genesis, 0)
+	stale := forkid.ID{Hash: forkID.Hash, Next: 0}
+	stale.Hash[0] ^= 0xff
+
+	tests := []struct {
+		code      uint64
+		data      interface{}
+		wantError error
+	}{
+		{
+			code: StatusMsg, data: statusData65{10, NetworkId, td, currentBlock, genesis, forkID},
+			wantError: errResp(ErrProtocolVersionMismatch, "10 (!= %d)", eth65),
+		},
+		{
+			code: StatusMsg, data: statusData65{eth65, 999, td, currentBlock, genesis, forkID},
+			wantError: errResp(ErrNetworkIdMismatch, "999 (!= 1)"),
+		},
+		{
+			code: StatusMsg, data: statusData65{eth65, NetworkId, td, currentBlock, common.Hash{3}, forkID},
+			wantError: errResp(ErrGenesisBlockMismatch, "0300000000000000000000000000000000000000000000000000000000000000 (!= %x…)", genesis.Bytes()[:8]),
+		},
+		{
+			code: StatusMsg, data: statusData65{eth65, NetworkId, td, currentBlock, genesis, stale},
+			wantError: errResp(ErrForkIDRejected, "%x/%d: %v", stale.Hash, stale.Next, forkid.ErrLocalIncompatibleOrStale),
+		},
+	}
+
+	for i, test := range tests {
+		p, errc := newTestPeer("peer", eth65, pm, false)
+		// The send call might hang until reset because
+		// the protocol might not read the payload.
+		go p2p.Send(p.app, test.code, test.data)
+
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Errorf("test %d: protocol returned nil error, want %q", i, test.wantError)
+			} else if err.Error() != test.wantError.Error() {
+				t.Errorf("test %d: wrong error: got %q, want %q", i, err, test.wantError)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("protocol did not shut down withing 2 seconds")
+		}
+		p.close()
+	}
+}
+
+// Tests that pooled transactions are only accepted in answer to a request.
+func TestDeliverTxs(t *testing.T) {
+	p := newPeer(eth65, p2p.NewPeer(discover.NodeID{}, "peer", nil), new(p2p.MsgPipeRW), DefaultServeLimits)
+	tx := newTestTransaction(testAccount, 0, 0)
+	other := newTestTransaction(testAccount, 1, 0)
+
+	if err := p.deliverTxs([]*types.Transaction{tx}); err == nil {
+		t.Fatalf("unrequested delivery accepted")
+	}
+	p.trackTxRequest([]common.Hash{tx.Hash()})
+	if err := p.deliverTxs([]*types.Transaction{other}); err == nil {
+		t.Fatalf("delivery of an unrequested transaction accepted")
+	}
+	if len(p.txRequests) != 0 {
+		t.Fatalf("request not consumed by the delivery: %d left", len(p.txRequests))
+	}
+	p.trackTxRequest([]common.Hash{tx.Hash(), other.Hash()})
+	if err := p.deliverTxs([]*types.Transaction{tx}); err != nil {
+		t.Fatalf("partial delivery rejected: %v", err)
+	}
+	for i := 0; i < maxTxRequests; i++ {
+		p.trackTxRequest(nil)
+	}
+	if err := p.RequestTxs([]common.Hash{tx.Hash()}); err != errTooManyTxRequests {
+		t.Fatalf("request error mismatch: have %v, want %v", err, errTooManyTxRequests)
+	}
+}
+
+// Tests that pooled transactions are matched with any outstanding request, as the
+// fetcher's requests may go out and be answered in any order.
+func TestDeliverTxsOutOfOrder(t *testing.T) {
+	app, net := p2p.MsgPipe()
+	defer app.Close()
+	p := newPeer(eth65, p2p.NewPeer(discover.NodeID{}, "peer", nil), net, DefaultServeLimits)
+	first := newTestTransaction(testAccount, 0, 0)
+	second := newTestTransaction(testAccount, 1, 0)
+
+	// Send the two requests concurrently and drain them on the remote end
+	errc := make(chan error, 2)
+	for _, tx := range []*types.Transaction{first, second} {
+		go func(hash common.Hash) { errc <- p.RequestTxs([]common.Hash{hash}) }(tx.Hash())
+	}
+	for i := 0; i < 2; i++ {
+		msg, err := app.ReadMsg()
+		if err != nil {
+			t.Fatalf("failed to read request: %v", err)
+		}
+		msg.Discard()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			t.Fatalf("failed to request transactions: %v", err)
+		}
+	}
+	// Answer whichever request went out last first
+	last := first
+	if p.txRequests[1].hashes[second.Hash()] {
+		last = second
+	}
+	if err := p.deliverTxs([]*types.Transaction{last}); err != nil {
+		t.Fatalf("out of order delivery rejected: %v", err)
+	}
+	if len(p.txRequests) != 1 || p.txRequests[0].hashes[last.Hash()] {
+		t.Fatalf("wrong request consumed by the delivery")
+	}
+	// Requests failing to be sent aren't left outstanding
+	app.Close()
+	if err := p.RequestTxs([]common.Hash{first.Hash()}); err == nil {
+		t.Fatalf("request over a closed connection succeeded")
+	}
+	if len(p.txRequests) != 1 {
+		t.Fatalf("failed request left outstanding: %d requests", len(p.txRequests))
+	}
+}
+
 // This test checks that received transactions are added to the local pool.
 func TestRecvTransactions61(t *testing.T) { testRecvTransactions(t, 61) }
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
@@ -0,0 +1,200 @@
+package eth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/eth/downloader"
+	"github.com/openether/ethcore/metrics"
+)
+
+// ServeLimit caps the number of items of a single kind served to remote peers.
+type ServeLimit struct {
+	PerRequest int // Maximum number of items returned in a single response
+	PerSecond  int // Maximum number of items served to a single peer per second, 0 for unlimited
+}
+
+// ServeLimits caps the chain data served to remote peers, so that a node used
+// as a sync source can't be turned into a disk read amplifier by a few peers
+// repeatedly requesting large amounts of data.
+type ServeLimits struct {
+	Headers  ServeLimit
+	Bodies   ServeLimit
+	Receipts ServeLimit
+	NodeData ServeLimit
+}
+
+// DefaultServeLimits serves full sized responses to every request, but bounds
+// the sustained rate a single peer may pull data at.
+var DefaultServeLimits = ServeLimits{
+	Headers:  ServeLimit{PerRequest: downloader.MaxHeaderFetch, PerSecond: 16 * downloader.MaxHeaderFetch},
+	Bodies:   ServeLimit{PerRequest: downloader.MaxBlockFetch, PerSecond: 4 * downloader.MaxBlockFetch},
+	Receipts: ServeLimit{PerRequest: downloader.MaxReceiptFetch, PerSecond: 4 * downloader.MaxReceiptFetch},
+	NodeData: ServeLimit{PerRequest: downloader.MaxStateFetch, PerSecond: 16 * downloader.MaxStateFetch},
+}
+
+// serveKind identifies the kind of data a serving limit applies to.
+type serveKind int
+
+const (
+	serveHeaders serveKind = iota
+	serveBodies
+	serveReceipts
+	serveNodeData
+	serveKinds
+)
+
+// serveKindNames are the names used for the kinds of served data in flags.
+var serveKindNames = [serveKinds]string{"headers", "bodies", "receipts", "nodedata"}
+
+// protocolLimits are the maximum number of items of each kind a response may
+// contain, beyond which the requesting downloader would reject it anyway.
+var protocolLimits = [serveKinds]int{downloader.MaxHeaderFetch, downloader.MaxBlockFetch, downloader.MaxReceiptFetch, downloader.MaxStateFetch}
+
+func (l *ServeLimits) limit(kind serveKind) *ServeLimit {
+	switch kind {
+	case serveHeaders:
+		return &l.Headers
+	case serveBodies:
+		return &l.Bodies
+	case serveReceipts:
+		return &l.Receipts
+	default:
+		return &l.NodeData
+	}
+}
+
+// ParseRequestLimits overrides the per request limits with a comma separated
+// list of kind=count pairs, e.g. "headers=96,nodedata=128". Counts must be
+// positive and can't exceed what the protocol allows in a single response.
+func (l *ServeLimits) ParseRequestLimits(s string) error {
+	return l.parse(s, func(kind serveKind, n int) error {
+		if n <= 0 || n > protocolLimits[kind] {
+			return fmt.Errorf("%s request limit %d out of range [1, %d]", serveKindNames[kind], n, protocolLimits[kind])
+		}
+		l.limit(kind).PerRequest = n
+		return nil
+	})
+}
+
+// ParseRateLimits overrides the per peer per second limits with a comma
+// separated list of kind=count pairs, e.g. "bodies=256,receipts=256". A count
+// of 0 disables rate limiting of the kind.
+func (l *ServeLimits) ParseRateLimits(s string) error {
+	return l.parse(s, func(kind serveKind, n int) error {
+		if n < 0 {
+			return fmt.Errorf("negative %s rate limit %d", serveKindNames[kind], n)
+		}
+		l.limit(kind).PerSecond = n
+		return nil
+	})
+}
+
+func (l *ServeLimits) parse(s string, set func(serveKind, int) error) error {
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid limit %q, want kind=count", field)
+		}
+		kind := serveKinds
+		for k, name := range serveKindNames {
+			if strings.TrimSpace(parts[0]) == name {
+				kind = serveKind(k)
+			}
+		}
+		if kind == serveKinds {
+			return fmt.Errorf("unknown kind %q, want one of %s", parts[0], strings.Join(serveKindNames[:], ", "))
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return fmt.Errorf("invalid %s limit %q", serveKindNames[kind], parts[1])
+		}
+		if err := set(kind, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serveThrottle tracks the data served to a single peer, limiting the items of
+// each kind returned per response and per second (token bucket, holding at most
+// a second's worth of items or a single full response, whichever is larger).
+type serveThrottle struct {
+	limits ServeLimits
+
+	lock   sync.Mutex
+	tokens [serveKinds]float64
+	last   [serveKinds]time.Time
+}
+
+func newServeThrottle(limits ServeLimits) *serveThrottle {
+	t := &serveThrottle{limits: limits}
+	for kind := serveKind(0); kind < serveKinds; kind++ {
+		t.tokens[kind] = t.burst(kind)
+	}
+	return t
+}
+
+func (t *serveThrottle) burst(kind serveKind) float64 {
+	limit := t.limits.limit(kind)
+	if limit.PerSecond > limit.PerRequest {
+		return float64(limit.PerSecond)
+	}
+	return float64(limit.PerRequest)
+}
+
+// allowance returns the number of items of kind that may be served in a single
+// response at the given time.
+func (t *serveThrottle) allowance(kind serveKind, now time.Time) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	limit := t.limits.limit(kind)
+	if limit.PerSecond == 0 {
+		return limit.PerRequest
+	}
+	// Refill the token bucket
+	if !t.last[kind].IsZero() {
+		t.tokens[kind] += now.Sub(t.last[kind]).Seconds() * float64(limit.PerSecond)
+		if burst := t.burst(kind); t.tokens[kind] > burst {
+			t.tokens[kind] = burst
+		}
+	}
+	t.last[kind] = now
+
+	if available := int(t.tokens[kind]); available < limit.PerRequest {
+		return available
+	}
+	return limit.PerRequest
+}
+
+// served accounts for the items of kind served in a response. Responses cut
+// short by the rate limit are counted as throttled, those of requests simply
+// asking for more than allowed per response are not.
+func (t *serveThrottle) served(kind serveKind, items int, allowance int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	limit := t.limits.limit(kind)
+	if limit.PerSecond > 0 {
+		t.tokens[kind] -= float64(items)
+	}
+	if items == allowance && allowance < limit.PerRequest {
+		switch kind {
+		case serveHeaders:
+			metrics.ServeThrottledHeaders.Mark(1)
+		case serveBodies:
+			metrics.ServeThrottledBodies.Mark(1)
+		case serveReceipts:
+			metrics.ServeThrottledReceipts.Mark(1)
+		case serveNodeData:
+			metrics.ServeThrottledNodeData.Mark(1)
+		}
+	}
+}
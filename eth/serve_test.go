@@ -0,0 +1,77 @@
+package eth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseServeLimits(t *testing.T) {
+	limits := DefaultServeLimits
+	if err := limits.ParseRequestLimits("headers=96, nodedata=128"); err != nil {
+		t.Fatalf("failed to parse request limits: %v", err)
+	}
+	if err := limits.ParseRateLimits("bodies=256,receipts=0"); err != nil {
+		t.Fatalf("failed to parse rate limits: %v", err)
+	}
+	want := DefaultServeLimits
+	want.Headers.PerRequest, want.NodeData.PerRequest = 96, 128
+	want.Bodies.PerSecond, want.Receipts.PerSecond = 256, 0
+	if limits != want {
+		t.Errorf("limits mismatch: have %+v, want %+v", limits, want)
+	}
+
+	invalidRequests := []string{
+		"headers=0",
+		"bodies=-1",
+		"receipts=100000",
+		"blocks=10",
+		"headers",
+		"headers=many",
+	}
+	for _, s := range invalidRequests {
+		if err := limits.ParseRequestLimits(s); err == nil {
+			t.Errorf("invalid request limits %q accepted", s)
+		}
+	}
+	if err := limits.ParseRateLimits("headers=-1"); err == nil {
+		t.Errorf("negative rate limit accepted")
+	}
+}
+
+// Tests that a peer is served up to a response worth of items at a time, and no
+// more than its rate allows over time.
+func TestServeThrottle(t *testing.T) {
+	limits := DefaultServeLimits
+	limits.Headers = ServeLimit{PerRequest: 10, PerSecond: 20}
+	limits.Bodies = ServeLimit{PerRequest: 10}
+	throttle := newServeThrottle(limits)
+
+	now := time.Unix(1500000000, 0)
+	steps := []struct {
+		elapsed time.Duration
+		served  int // Headers served after checking the allowance
+		allowed int
+	}{
+		{0, 10, 10},                    // Full burst of a second's worth
+		{0, 10, 10},                    // Second half of the burst
+		{0, 0, 0},                      // Burst used up
+		{250 * time.Millisecond, 5, 5}, // Refilled at the rate
+		{10 * time.Second, 10, 10},     // Capped at a single response
+		{0, 10, 10},                    // Refilled up to the burst only
+		{0, 0, 0},
+	}
+	for i, step := range steps {
+		now = now.Add(step.elapsed)
+		if allowed := throttle.allowance(serveHeaders, now); allowed != step.allowed {
+			t.Fatalf("step %d: allowance mismatch: have %d, want %d", i, allowed, step.allowed)
+		}
+		throttle.served(serveHeaders, step.served, step.allowed)
+	}
+	// Kinds without a rate limit are only limited per response
+	for i := 0; i < 3; i++ {
+		if allowed := throttle.allowance(serveBodies, now); allowed != 10 {
+			t.Fatalf("unlimited bodies: allowance %d, want 10", allowed)
+		}
+		throttle.served(serveBodies, 10, 10)
+	}
+}
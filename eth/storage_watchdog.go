@@ -0,0 +1,154 @@
+package eth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/event"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+const (
+	storageCheckInterval = 30 * time.Second // Interval between disk space and file descriptor checks
+
+	fdDegradeRatio    = 0.95 // Fraction of the file descriptor allowance in use beyond which import stops
+	fdRecoverRatio    = 0.85 // Fraction of the file descriptor allowance in use below which import resumes
+	diskRecoverFactor = 2    // Multiple of the minimum free disk space required for import to resume
+)
+
+// storageWatchdog puts the node in a degraded, read-only mode when the disk
+// holding the datadir fills up or the file descriptors run out: block import is
+// frozen, so the database isn't left with half written batches, while RPC keeps
+// being served from what's stored. Import resumes once the resources recover
+// with some headroom.
+type storageWatchdog struct {
+	chain   *core.BlockChain
+	mux     *event.TypeMux
+	datadir string // Directory whose file system is checked, empty to skip the disk check
+	minFree uint64 // Free bytes below which import is frozen
+
+	diskFree func(string) (uint64, error) // Platform helpers, replaced in tests
+	openFds  func() (uint64, error)
+	fdLimit  func() (uint64, error)
+
+	degraded string // Reason of the degradation, empty if healthy
+	freeze   uint64 // Identifier of the import freeze made by the degradation, zero if none
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newStorageWatchdog(chain *core.BlockChain, mux *event.TypeMux, datadir string, minFree uint64) *storageWatchdog {
+	return &storageWatchdog{
+		chain:    chain,
+		mux:      mux,
+		datadir:  datadir,
+		minFree:  minFree,
+		diskFree: diskFree,
+		openFds:  openFds,
+		fdLimit:  fdLimit,
+		quit:     make(chan struct{}),
+	}
+}
+
+func (w *storageWatchdog) start() {
+	w.wg.Add(1)
+	go w.loop()
+}
+
+// stop terminates the checks, resuming import if it was frozen by them.
+func (w *storageWatchdog) stop() {
+	close(w.quit)
+	w.wg.Wait()
+
+	if w.freeze != 0 {
+		w.chain.ThawImportID(w.freeze)
+	}
+}
+
+func (w *storageWatchdog) loop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(storageCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		w.check()
+		select {
+		case <-ticker.C:
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// check enters or leaves the degraded mode depending on the resources left.
+func (w *storageWatchdog) check() {
+	reason := w.shortage()
+	switch {
+	case reason != "" && w.degraded == "":
+		glog.V(logger.Error).Errorf("Storage degraded, block import stopped: %s", reason)
+		w.degraded = reason
+		w.freezeImport()
+		w.mux.Post(core.StorageDegradedEvent{Reason: reason})
+
+	case reason != "" && w.freeze == 0:
+		// Import was frozen by someone else when degrading, freeze it once they thaw
+		w.freezeImport()
+
+	case reason == "" && w.degraded != "":
+		glog.V(logger.Warn).Warnf("Storage recovered, block import resumed")
+		if w.freeze != 0 {
+			w.chain.ThawImportID(w.freeze)
+		}
+		w.degraded, w.freeze = "", 0
+		w.mux.Post(core.StorageRecoveredEvent{})
+	}
+}
+
+// freezeImport freezes block import for the degradation, unless it's frozen by
+// someone else already.
+func (w *storageWatchdog) freezeImport() {
+	id, err := w.chain.FreezeImportID(0)
+	if err != nil {
+		glog.V(logger.Debug).Infof("Storage degraded, block import not frozen: %v", err)
+		return
+	}
+	w.freeze = id
+}
+
+// shortage returns the resource running out, if any. While degraded, the
+// resources must recover past higher thresholds to avoid flapping.
+func (w *storageWatchdog) shortage() string {
+	if w.datadir != "" {
+		if free, err := w.diskFree(w.datadir); err == nil {
+			required := w.minFree
+			if w.degraded != "" {
+				required *= diskRecoverFactor
+			}
+			if free < required {
+				return fmt.Sprintf("%s free in %s, %s required", common.StorageSize(free), w.datadir, common.StorageSize(required))
+			}
+		}
+	}
+	open, err := w.openFds()
+	if err != nil {
+		return ""
+	}
+	limit, err := w.fdLimit()
+	if err != nil || limit == 0 {
+		return ""
+	}
+	ratio := fdDegradeRatio
+	if w.degraded != "" {
+		ratio = fdRecoverRatio
+	}
+	if float64(open) >= ratio*float64(limit) {
+		return fmt.Sprintf("%d of %d file descriptors open", open, limit)
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+package eth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/event"
+)
+
+func TestStorageWatchdog(t *testing.T) {
+	var (
+		chain = new(core.BlockChain)
+		mux   = new(event.TypeMux)
+		sub   = mux.Subscribe(core.StorageDegradedEvent{}, core.StorageRecoveredEvent{})
+	)
+	defer sub.Unsubscribe()
+
+	events := make(chan interface{}, 10)
+	go func() {
+		for ev := range sub.Chan() {
+			events <- ev.Data
+		}
+	}()
+
+	var free, open uint64 = 10000, 10
+	w := newStorageWatchdog(chain, mux, "datadir", 1000)
+	w.diskFree = func(string) (uint64, error) { return free, nil }
+	w.openFds = func() (uint64, error) { return open, nil }
+	w.fdLimit = func() (uint64, error) { return 100, nil }
+
+	tests := []struct {
+		free, open uint64
+		frozen     bool
+		event      interface{}
+	}{
+		{10000, 10, false, nil},
+		{999, 10, true, core.StorageDegradedEvent{}},
+		{1500, 10, true, nil}, // Below the recovery threshold
+		{2000, 10, false, core.StorageRecoveredEvent{}},
+		{2000, 95, true, core.StorageDegradedEvent{}},
+		{2000, 90, true, nil},
+		{2000, 80, false, core.StorageRecoveredEvent{}},
+	}
+	for i, tt := range tests {
+		free, open = tt.free, tt.open
+		w.check()
+
+		if frozen := chain.ImportFreezeStatus().Frozen; frozen != tt.frozen {
+			t.Errorf("test %d: import frozen %v, want %v", i, frozen, tt.frozen)
+		}
+		select {
+		case ev := <-events:
+			switch ev := ev.(type) {
+			case core.StorageDegradedEvent:
+				if _, ok := tt.event.(core.StorageDegradedEvent); !ok || ev.Reason == "" {
+					t.Errorf("test %d: unexpected event %#v", i, ev)
+				}
+			case core.StorageRecoveredEvent:
+				if _, ok := tt.event.(core.StorageRecoveredEvent); !ok {
+					t.Errorf("test %d: unexpected event %#v", i, ev)
+				}
+			}
+		case <-time.After(100 * time.Millisecond):
+			if tt.event != nil {
+				t.Errorf("test %d: missing event %T", i, tt.event)
+			}
+		}
+	}
+}
+
+// Tests that import frozen by an operator is not thawed when storage recovers.
+func TestStorageWatchdogKeepsFreeze(t *testing.T) {
+	chain := new(core.BlockChain)
+	if err := chain.FreezeImport(0); err != nil {
+		t.Fatal(err)
+	}
+	var free uint64 = 0
+	w := newStorageWatchdog(chain, new(event.TypeMux), "datadir", 1000)
+	w.diskFree = func(string) (uint64, error) { return free, nil }
+	w.openFds = func() (uint64, error) { return 0, nil }
+	w.fdLimit = func() (uint64, error) { return 100, nil }
+
+	w.check()
+	free = 10000
+	w.check()
+	if w.degraded != "" {
+		t.Fatalf("still degraded: %s", w.degraded)
+	}
+	if !chain.ImportFreezeStatus().Frozen {
+		t.Errorf("operator freeze thawed")
+	}
+}
+
+// Tests that a freeze made by an operator after thawing the watchdog's freeze is
+// kept when storage recovers.
+func TestStorageWatchdogFreezeOwner(t *testing.T) {
+	chain := new(core.BlockChain)
+	var free uint64 = 0
+	w := newStorageWatchdog(chain, new(event.TypeMux), "datadir", 1000)
+	w.diskFree = func(string) (uint64, error) { return free, nil }
+	w.openFds = func() (uint64, error) { return 0, nil }
+	w.fdLimit = func() (uint64, error) { return 100, nil }
+
+	w.check()
+	if !chain.ImportFreezeStatus().Frozen {
+		t.Fatalf("import not frozen on degradation")
+	}
+	if err := chain.ThawImport(); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.FreezeImport(0); err != nil {
+		t.Fatal(err)
+	}
+	free = 10000
+	w.check()
+	if w.degraded != "" {
+		t.Fatalf("still degraded: %s", w.degraded)
+	}
+	if !chain.ImportFreezeStatus().Frozen {
+		t.Errorf("operator freeze thawed")
+	}
+	w.stop()
+	if !chain.ImportFreezeStatus().Frozen {
+		t.Errorf("operator freeze thawed on stop")
+	}
+}
+
+// Tests that import frozen by an operator when storage degrades is frozen by the
+// watchdog once the operator thaws it, while storage is still short.
+func TestStorageWatchdogRetriesFreeze(t *testing.T) {
+	chain := new(core.BlockChain)
+	if err := chain.FreezeImport(0); err != nil {
+		t.Fatal(err)
+	}
+	var free uint64 = 0
+	w := newStorageWatchdog(chain, new(event.TypeMux), "datadir", 1000)
+	w.diskFree = func(string) (uint64, error) { return free, nil }
+	w.openFds = func() (uint64, error) { return 0, nil }
+	w.fdLimit = func() (uint64, error) { return 100, nil }
+
+	w.check()
+	if w.degraded == "" || w.freeze != 0 {
+		t.Fatalf("degradation mismatch: reason %q, freeze %d", w.degraded, w.freeze)
+	}
+	if err := chain.ThawImport(); err != nil {
+		t.Fatal(err)
+	}
+	w.check()
+	if !chain.ImportFreezeStatus().Frozen {
+		t.Fatalf("import not frozen while storage is short")
+	}
+	free = 10000
+	w.check()
+	if chain.ImportFreezeStatus().Frozen {
+		t.Errorf("import still frozen after storage recovered")
+	}
+}
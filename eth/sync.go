@@ -1,6 +1,7 @@
 package eth
 
 import (
+	"math/big"
 	"math/rand"
 	"sync/atomic"
 	"time"
@@ -17,6 +18,8 @@ const (
 	forceSyncCycle      = 10 * time.Second // Time interval to force syncs, even if few peers are available
 	minDesiredPeerCount = 5                // Amount of peers desired to start syncing
 
+	defaultSyncMinPeers = 1 // Amount of peers which must be ahead of us on the same head before a sync target is accepted
+
 	// This is the target size for the packs of transactions sent by txsyncLoop.
 	// A pack can get larger than this if a single transactions exceeds this size.
 	txsyncPackSize = 100 * 1024
@@ -29,7 +32,12 @@ type txsync struct {
 
 // syncTransactions starts sending all currently pending transactions to the given peer.
 func (pm *ProtocolManager) syncTransactions(p *peer) {
-	txs := pm.txpool.GetTransactions()
+	var txs types.Transactions
+	for _, tx := range pm.txpool.GetTransactions() {
+		if pm.txShareable(p, tx) {
+			txs = append(txs, tx)
+		}
+	}
 	if len(txs) == 0 {
 		return
 	}
@@ -116,6 +124,8 @@ func (pm *ProtocolManager) syncer() {
 	// Start and ensure cleanup of sync mechanisms
 	pm.fetcher.Start()
 	defer pm.fetcher.Stop()
+	pm.txFetcher.Start()
+	defer pm.txFetcher.Stop()
 	defer pm.downloader.Terminate()
 
 	// Wait for different events to fire synchronisation operations
@@ -160,7 +170,7 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 		glog.Fatalf("Found invalid TD=%v for current block in database. Exiting.\nCheck available disk space and restart to attempt database recovery.", td)
 	}
 	pHead, pTd := peer.Head()
-	if pTd.Cmp(td) <= 0 {
+	if !pm.acceptSyncTarget(peer, td) {
 		return
 	}
 
@@ -200,3 +210,51 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 		go pm.BroadcastBlock(head, false)
 	}
 }
+
+// acceptSyncTarget applies the sync target selection policy to the given peer.
+// A peer is only accepted if its total difficulty exceeds ours by more than the
+// configured margin, and at least syncMinPeers peers (including the candidate)
+// advertise the same head with a total difficulty clearing that same bar. This
+// prevents a single peer, or a group of peers each on its own chain, advertising
+// a bogus total difficulty from dragging us into pointless sync cycles.
+func (pm *ProtocolManager) acceptSyncTarget(peer *peer, localTd *big.Int) bool {
+	margin := pm.syncTdMargin
+	if margin == nil {
+		margin = new(big.Int)
+	}
+	threshold := new(big.Int).Add(localTd, margin)
+
+	pHead, pTd := peer.Head()
+	agreeing := pm.peers.CountHead(pHead, threshold)
+
+	var reason string
+	switch {
+	case pTd.Cmp(threshold) <= 0:
+		reason = "insufficient td"
+	case agreeing < pm.syncMinPeers:
+		reason = "insufficient agreeing peers"
+	}
+	accepted := reason == ""
+
+	if logger.MlogEnabled() {
+		var r interface{}
+		if !accepted {
+			r = reason
+		}
+		mlogSyncTargetDecision.AssignDetails(
+			peer.id,
+			pHead.Hex(),
+			pTd,
+			localTd,
+			margin,
+			agreeing,
+			pm.syncMinPeers,
+			accepted,
+			r,
+		).Send(mlogSyncComponent)
+	}
+	if !accepted {
+		glog.V(logger.Debug).Infof("Rejected sync target peer=%s td=%v local=%v margin=%v agreeing=%d/%d: %s", peer.id, pTd, localTd, margin, agreeing, pm.syncMinPeers, reason)
+	}
+	return accepted
+}
@@ -17,10 +17,13 @@
 package eth
 
 import (
+	"fmt"
+	"math/big"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/ethereumclassic/go-ethereum/common"
 	"github.com/ethereumclassic/go-ethereum/eth/downloader"
 	"github.com/ethereumclassic/go-ethereum/logger/glog"
 	"github.com/ethereumclassic/go-ethereum/p2p"
@@ -59,3 +62,45 @@ func TestFastSyncDisabling(t *testing.T) {
 		t.Fatalf("fast sync not disabled after successful synchronisation")
 	}
 }
+
+// Tests that a sync target is only accepted if it is far enough ahead and enough
+// peers agree with it on the head.
+func TestAcceptSyncTarget(t *testing.T) {
+	var (
+		head  = common.HexToHash("0x01")
+		other = common.HexToHash("0x02")
+		local = big.NewInt(100)
+	)
+	type advert struct {
+		head common.Hash
+		td   int64
+	}
+	tests := []struct {
+		peers    []advert // The first peer is the candidate
+		accepted bool
+	}{
+		{[]advert{{head, 200}, {head, 200}}, true},
+		{[]advert{{head, 200}, {head, 150}, {other, 300}}, true},
+		{[]advert{{head, 200}}, false},                             // too few peers
+		{[]advert{{head, 200}, {other, 200}, {other, 300}}, false}, // peers on other heads
+		{[]advert{{head, 200}, {head, 100}}, false},                // agreeing peer not ahead
+		{[]advert{{head, 105}, {head, 105}}, false},                // within the margin
+	}
+	for i, tt := range tests {
+		pm := &ProtocolManager{peers: newPeerSet(), syncMinPeers: 2, syncTdMargin: big.NewInt(10)}
+		var candidate *peer
+		for j, ad := range tt.peers {
+			var id discover.NodeID
+			id[0] = byte(j)
+			p := newPeer(eth63, p2p.NewPeer(id, fmt.Sprintf("peer%d", j), nil), new(p2p.MsgPipeRW), DefaultServeLimits)
+			p.head, p.td = ad.head, big.NewInt(ad.td)
+			pm.peers.peers[p.id] = p
+			if candidate == nil {
+				candidate = p
+			}
+		}
+		if accepted := pm.acceptSyncTarget(candidate, local); accepted != tt.accepted {
+			t.Errorf("test %d: acceptance mismatch: have %v, want %v", i, accepted, tt.accepted)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/metrics"
+)
+
+const (
+	txGossipRate        = 200  // Transactions per second accepted from a single peer on average
+	txGossipBurst       = 2048 // Transactions accepted from a single peer in a single burst
+	maxTxRelayedPerPeer = 4096 // Transactions relayed by a single peer allowed to be pending in the pool
+)
+
+// txThrottle limits the transaction gossip accepted from a single peer, both in
+// terms of rate (token bucket) and in terms of the number of transactions the
+// peer relayed that are still pending in the pool. Transactions dropped by the
+// throttle never reach the pool, so a spamming peer can't force it to churn CPU
+// on signature recovery.
+type txThrottle struct {
+	rate       float64 // tokens added per second
+	burst      float64 // bucket capacity
+	maxRelayed int     // maximum number of tracked relayed transactions
+
+	lock    sync.Mutex
+	tokens  float64
+	last    time.Time
+	relayed map[common.Hash]struct{} // transactions accepted from the peer, possibly still pending
+}
+
+func newTxThrottle(rate, burst, maxRelayed int) *txThrottle {
+	return &txThrottle{
+		rate:       float64(rate),
+		burst:      float64(burst),
+		maxRelayed: maxRelayed,
+		tokens:     float64(burst),
+		relayed:    make(map[common.Hash]struct{}),
+	}
+}
+
+// filter returns the subset of txs which may be handed to the pool and updates
+// the drop meters for the rest. The pending callback reports whether a
+// previously accepted transaction is still pending in the pool; it's used to
+// release relay slots once transactions are mined or evicted.
+func (t *txThrottle) filter(txs []*types.Transaction, pending func(common.Hash) bool, now time.Time) []*types.Transaction {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Refill the token bucket
+	if !t.last.IsZero() {
+		t.tokens += now.Sub(t.last).Seconds() * t.rate
+		if t.tokens > t.burst {
+			t.tokens = t.burst
+		}
+	}
+	t.last = now
+
+	accepted := make([]*types.Transaction, 0, len(txs))
+	var (
+		rateDrops, relayDrops int64
+		pruned                bool
+	)
+	for i, tx := range txs {
+		if t.tokens < 1 {
+			rateDrops = int64(len(txs) - i)
+			break
+		}
+		hash := tx.Hash()
+		if _, ok := t.relayed[hash]; ok {
+			continue
+		}
+		// Nothing leaves the pool while filtering, so the relay slots are only
+		// released once per batch rather than rescanned for every transaction
+		if len(t.relayed) >= t.maxRelayed && !pruned {
+			t.prune(pending)
+			pruned = true
+		}
+		if len(t.relayed) >= t.maxRelayed {
+			relayDrops++
+			continue
+		}
+		t.tokens--
+		t.relayed[hash] = struct{}{}
+		accepted = append(accepted, tx)
+	}
+	if rateDrops > 0 {
+		metrics.TxGossipRateDrops.Mark(rateDrops)
+	}
+	if relayDrops > 0 {
+		metrics.TxGossipRelayDrops.Mark(relayDrops)
+	}
+	return accepted
+}
+
+// prune releases the relay slots of transactions no longer pending in the pool.
+func (t *txThrottle) prune(pending func(common.Hash) bool) {
+	for hash := range t.relayed {
+		if !pending(hash) {
+			delete(t.relayed, hash)
+		}
+	}
+}
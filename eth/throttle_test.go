@@ -0,0 +1,76 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+)
+
+func newThrottleTxs(from, count int) []*types.Transaction {
+	txs := make([]*types.Transaction, count)
+	for i := range txs {
+		txs[i] = types.NewTransaction(uint64(from+i), common.Address{}, new(big.Int), big.NewInt(21000), big.NewInt(1), nil)
+	}
+	return txs
+}
+
+// Tests that the gossip of a peer is accepted in bursts refilled at the rate.
+func TestTxThrottleRate(t *testing.T) {
+	throttle := newTxThrottle(10, 20, 1000)
+	pending := func(common.Hash) bool { return true }
+	now := time.Unix(1500000000, 0)
+
+	if accepted := throttle.filter(newThrottleTxs(0, 15), pending, now); len(accepted) != 15 {
+		t.Fatalf("first burst: accepted %d, want 15", len(accepted))
+	}
+	// Transactions accepted before are skipped without using up the allowance
+	if accepted := throttle.filter(newThrottleTxs(10, 10), pending, now); len(accepted) != 5 {
+		t.Fatalf("rest of the burst: accepted %d, want 5", len(accepted))
+	}
+	if accepted := throttle.filter(newThrottleTxs(20, 10), pending, now); len(accepted) != 0 {
+		t.Fatalf("beyond the burst: accepted %d, want 0", len(accepted))
+	}
+	now = now.Add(500 * time.Millisecond)
+	if accepted := throttle.filter(newThrottleTxs(20, 10), pending, now); len(accepted) != 5 {
+		t.Fatalf("after refill: accepted %d, want 5", len(accepted))
+	}
+	// The bucket doesn't refill beyond the burst
+	now = now.Add(time.Hour)
+	if accepted := throttle.filter(newThrottleTxs(100, 30), pending, now); len(accepted) != 20 {
+		t.Fatalf("after a long pause: accepted %d, want 20", len(accepted))
+	}
+}
+
+// Tests that the transactions relayed by a peer and still pending are limited,
+// the relay slots being released once per batch as the pool drops them.
+func TestTxThrottleRelayed(t *testing.T) {
+	throttle := newTxThrottle(1000, 1000, 10)
+	var (
+		gone   = make(map[common.Hash]bool)
+		checks int
+	)
+	pending := func(hash common.Hash) bool {
+		checks++
+		return !gone[hash]
+	}
+	now := time.Unix(1500000000, 0)
+
+	first := newThrottleTxs(0, 10)
+	if accepted := throttle.filter(first, pending, now); len(accepted) != 10 || checks != 0 {
+		t.Fatalf("first batch: accepted %d with %d pool checks, want 10 with none", len(accepted), checks)
+	}
+	if accepted := throttle.filter(newThrottleTxs(10, 100), pending, now); len(accepted) != 0 || checks != 10 {
+		t.Fatalf("full relay: accepted %d with %d pool checks, want 0 with 10", len(accepted), checks)
+	}
+	// Transactions leaving the pool release their slots
+	for _, tx := range first[:4] {
+		gone[tx.Hash()] = true
+	}
+	checks = 0
+	if accepted := throttle.filter(newThrottleTxs(10, 100), pending, now); len(accepted) != 4 || checks != 10 {
+		t.Fatalf("after release: accepted %d with %d pool checks, want 4 with 10", len(accepted), checks)
+	}
+}
@@ -0,0 +1,225 @@
+package eth
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/accounts"
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/ethdb"
+	"github.com/openether/ethcore/event"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/rpc"
+)
+
+const txExpiryInterval = 5 * time.Second // Interval between checks of the local transaction deadlines
+
+// TxExpiryArgs sets the time-to-live of a transaction submitted over RPC.
+type TxExpiryArgs struct {
+	TTL            *rpc.HexNumber `json:"ttl"`            // Seconds the transaction is kept in the pool for, forever if nil
+	CancelOnExpiry bool           `json:"cancelOnExpiry"` // Whether to cancel the transaction once expired, not just drop it
+}
+
+// txExpiry is a local transaction with a time-to-live.
+type txExpiry struct {
+	tx       *types.Transaction
+	from     common.Address
+	deadline time.Time
+	cancel   bool // Whether to cancel the transaction once expired, not just drop it
+
+	replacement common.Hash // Cancelling transaction, set once submitted
+}
+
+// txExpirer drops local transactions from the pool once their time-to-live
+// elapses, so they are no longer offered to peers or miners. Expired transactions
+// may already have reached other nodes though: on request they are cancelled by
+// a self-transfer with the same nonce and a higher gas price, which is followed
+// until either transaction is mined. Outcomes are posted as TxLifecycleEvents.
+type txExpirer struct {
+	chain   *core.BlockChain
+	chainDb ethdb.Database
+	pool    *core.TxPool
+	am      *accounts.Manager
+	mux     *event.TypeMux
+
+	lock    sync.Mutex
+	tracked map[common.Hash]*txExpiry // Transactions awaiting expiry or the outcome of their cancellation
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newTxExpirer(chain *core.BlockChain, chainDb ethdb.Database, pool *core.TxPool, am *accounts.Manager, mux *event.TypeMux) *txExpirer {
+	return &txExpirer{
+		chain:   chain,
+		chainDb: chainDb,
+		pool:    pool,
+		am:      am,
+		mux:     mux,
+		tracked: make(map[common.Hash]*txExpiry),
+		quit:    make(chan struct{}),
+	}
+}
+
+func (e *txExpirer) start() {
+	e.wg.Add(1)
+	go e.loop()
+}
+
+func (e *txExpirer) stop() {
+	close(e.quit)
+	e.wg.Wait()
+}
+
+// validate checks the time-to-live is positive, if set.
+func (args *TxExpiryArgs) validate() error {
+	if args.TTL != nil && args.TTL.Int64() <= 0 {
+		return errors.New("ttl must be positive")
+	}
+	if args.TTL == nil && args.CancelOnExpiry {
+		return errors.New("cancelOnExpiry requires a ttl")
+	}
+	return nil
+}
+
+// expire sets the time-to-live of a pooled local transaction, if the arguments
+// give one. Cancelling requires the sending account to be unlocked at expiry.
+func (e *txExpirer) expire(hash common.Hash, args TxExpiryArgs) {
+	if args.TTL == nil {
+		return
+	}
+	tx := e.pool.GetTransaction(hash)
+	if tx == nil {
+		return // Already gone
+	}
+	from, err := tx.From()
+	if err != nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.tracked[hash] = &txExpiry{
+		tx:       tx,
+		from:     from,
+		deadline: time.Now().Add(time.Duration(args.TTL.Int64()) * time.Second),
+		cancel:   args.CancelOnExpiry,
+	}
+}
+
+// replaced moves the time-to-live of a transaction to its replacement, if it
+// has one.
+func (e *txExpirer) replaced(hash common.Hash, replacement *types.Transaction) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if exp, ok := e.tracked[hash]; ok && exp.replacement == (common.Hash{}) {
+		delete(e.tracked, hash)
+		exp.tx = replacement
+		e.tracked[replacement.Hash()] = exp
+	}
+}
+
+func (e *txExpirer) loop() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(txExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.check(time.Now())
+		case <-e.quit:
+			return
+		}
+	}
+}
+
+// check expires the transactions past their deadline and follows up on the
+// cancelled ones.
+func (e *txExpirer) check(now time.Time) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for hash, exp := range e.tracked {
+		if exp.replacement != (common.Hash{}) {
+			e.settle(hash, exp)
+			continue
+		}
+		if e.pool.GetTransaction(hash) == nil {
+			delete(e.tracked, hash)
+			if e.mined(hash) {
+				e.mux.Post(core.TxLifecycleEvent{Hash: hash, Status: core.TxMined})
+			} else {
+				e.mux.Post(core.TxLifecycleEvent{Hash: hash, Status: core.TxDropped})
+			}
+			continue
+		}
+		if now.Before(exp.deadline) {
+			continue
+		}
+		e.pool.RemoveTx(hash)
+		if !exp.cancel {
+			glog.V(logger.Info).Infof("Dropped expired tx [%s]", hash.Hex())
+			delete(e.tracked, hash)
+			e.mux.Post(core.TxLifecycleEvent{Hash: hash, Status: core.TxExpired})
+			continue
+		}
+		replacement, err := e.cancel(exp)
+		if err != nil {
+			glog.V(logger.Warn).Warnf("Dropped expired tx [%s], failed to cancel it: %v", hash.Hex(), err)
+			delete(e.tracked, hash)
+			e.mux.Post(core.TxLifecycleEvent{Hash: hash, Status: core.TxExpired, Err: err})
+			continue
+		}
+		glog.V(logger.Info).Infof("Dropped expired tx [%s], cancelling it with tx [%s]", hash.Hex(), replacement.Hex())
+		exp.replacement = replacement
+		e.mux.Post(core.TxLifecycleEvent{Hash: hash, Status: core.TxCancelling, Replacement: replacement})
+	}
+}
+
+// cancel submits a transfer of nothing from the sender of an expired transaction
+// to itself, with the same nonce and a higher gas price so that it replaces the
+// expired transaction in the pools of other nodes too.
+func (e *txExpirer) cancel(exp *txExpiry) (common.Hash, error) {
+	price := minReplacementPrice(exp.tx.GasPrice())
+	tx := types.NewTransaction(exp.tx.Nonce(), exp.from, new(big.Int), big.NewInt(21000), price, nil)
+	signer := e.chain.Config().GetSigner(e.chain.CurrentBlock().Number())
+	tx.SetSigner(signer)
+
+	signature, err := e.am.Sign(exp.from, signer.Hash(tx).Bytes())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return submitTransaction(e.chain, e.pool, tx, signature)
+}
+
+// settle reports which of a cancelled transaction and its replacement was mined,
+// if any, once the nonce they share is used on chain.
+func (e *txExpirer) settle(hash common.Hash, exp *txExpiry) {
+	state, err := e.chain.State()
+	if err != nil || state.GetNonce(exp.from) <= exp.tx.Nonce() {
+		return
+	}
+	delete(e.tracked, hash)
+
+	switch {
+	case e.mined(exp.replacement):
+		e.mux.Post(core.TxLifecycleEvent{Hash: hash, Status: core.TxCancelled, Replacement: exp.replacement})
+	case e.mined(hash):
+		e.mux.Post(core.TxLifecycleEvent{Hash: hash, Status: core.TxMined, Replacement: exp.replacement})
+	default:
+		e.mux.Post(core.TxLifecycleEvent{Hash: hash, Status: core.TxSuperseded, Replacement: exp.replacement})
+	}
+}
+
+func (e *txExpirer) mined(hash common.Hash) bool {
+	tx, _, _, _ := core.GetTransaction(e.chainDb, hash)
+	return tx != nil
+}
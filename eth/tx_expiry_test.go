@@ -0,0 +1,217 @@
+package eth
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/accounts"
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/event"
+	"github.com/ethereumclassic/go-ethereum/rlp"
+	"github.com/ethereumclassic/go-ethereum/rpc"
+)
+
+// expiryTester drives a txExpirer over a test chain whose bank account is
+// unlocked in the account manager.
+type expiryTester struct {
+	t       *testing.T
+	expirer *txExpirer
+	chain   *core.BlockChain
+	api     *PublicBlockChainAPI
+	events  event.Subscription
+	keydir  string
+}
+
+func newExpiryTester(t *testing.T) *expiryTester {
+	api, chain := newTestBlockChainAPI(0, nil)
+	pool := core.NewTxPool(chain.Config(), new(event.TypeMux), chain.State, func() *big.Int { return chain.CurrentBlock().GasLimit() })
+
+	keydir, err := ioutil.TempDir("", "tx-expiry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	am, err := accounts.NewManager(keydir, accounts.LightScryptN, accounts.LightScryptP, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := am.ImportECDSA(testBankKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Unlock(account, ""); err != nil {
+		t.Fatal(err)
+	}
+	mux := new(event.TypeMux)
+	return &expiryTester{
+		t:       t,
+		expirer: newTxExpirer(chain, api.chainDb, pool, am, mux),
+		chain:   chain,
+		api:     api,
+		events:  mux.Subscribe(core.TxLifecycleEvent{}),
+		keydir:  keydir,
+	}
+}
+
+func (et *expiryTester) close() {
+	et.events.Unsubscribe()
+	os.RemoveAll(et.keydir)
+}
+
+// send adds a transfer from the bank to the pool, expiring with the given arguments.
+func (et *expiryTester) send(nonce uint64, to common.Address, args TxExpiryArgs) *types.Transaction {
+	tx, _ := types.NewTransaction(nonce, to, big.NewInt(1000), core.TxGas, big.NewInt(1), nil).SignECDSA(testBankKey)
+	et.expirer.pool.SetLocal(tx)
+	if err := et.expirer.pool.Add(tx); err != nil {
+		et.t.Fatalf("failed to add transaction: %v", err)
+	}
+	et.expirer.expire(tx.Hash(), args)
+	return tx
+}
+
+// mine writes a block with the given transactions, removing them from the pool.
+func (et *expiryTester) mine(txs ...*types.Transaction) {
+	blocks, _ := core.GenerateChain(et.chain.Config(), et.chain.CurrentBlock(), et.api.chainDb, 1, func(i int, block *core.BlockGen) {
+		for _, tx := range txs {
+			block.AddTx(tx)
+		}
+	})
+	if _, err := et.chain.WriteBlock(blocks[0]); err != nil {
+		et.t.Fatalf("failed to write block: %v", err)
+	}
+	if err := core.WriteTransactions(et.api.chainDb, blocks[0]); err != nil {
+		et.t.Fatalf("failed to write transactions: %v", err)
+	}
+	for _, tx := range txs {
+		et.expirer.pool.RemoveTx(tx.Hash())
+	}
+}
+
+// check runs a check at the given time, returning the events it posted.
+func (et *expiryTester) check(now time.Time) []core.TxLifecycleEvent {
+	done := make(chan struct{})
+	go func() {
+		et.expirer.check(now)
+		close(done)
+	}()
+	var events []core.TxLifecycleEvent
+	for {
+		select {
+		case ev := <-et.events.Chan():
+			events = append(events, ev.Data.(core.TxLifecycleEvent))
+		case <-done:
+			return events
+		}
+	}
+}
+
+func (et *expiryTester) expect(events []core.TxLifecycleEvent, hash common.Hash, status string) core.TxLifecycleEvent {
+	if len(events) != 1 || events[0].Hash != hash || events[0].Status != status {
+		et.t.Fatalf("events mismatch: have %+v, want %s for %x", events, status, hash)
+	}
+	return events[0]
+}
+
+func TestTxExpirerDrop(t *testing.T) {
+	et := newExpiryTester(t)
+	defer et.close()
+
+	tx := et.send(0, common.HexToAddress("0x1234"), TxExpiryArgs{TTL: rpc.NewHexNumber(10)})
+	if events := et.check(time.Now()); len(events) != 0 {
+		t.Fatalf("events before the deadline: %+v", events)
+	}
+	et.expect(et.check(time.Now().Add(11*time.Second)), tx.Hash(), core.TxExpired)
+	if et.expirer.pool.GetTransaction(tx.Hash()) != nil {
+		t.Errorf("expired transaction still pooled")
+	}
+	if len(et.expirer.tracked) != 0 {
+		t.Errorf("expired transaction still tracked")
+	}
+}
+
+func TestTxExpirerBeforeDeadline(t *testing.T) {
+	et := newExpiryTester(t)
+	defer et.close()
+
+	mined := et.send(0, common.HexToAddress("0x1234"), TxExpiryArgs{TTL: rpc.NewHexNumber(10)})
+	et.mine(mined)
+	et.expect(et.check(time.Now()), mined.Hash(), core.TxMined)
+
+	dropped := et.send(1, common.HexToAddress("0x1234"), TxExpiryArgs{TTL: rpc.NewHexNumber(10)})
+	et.expirer.pool.RemoveTx(dropped.Hash())
+	et.expect(et.check(time.Now()), dropped.Hash(), core.TxDropped)
+
+	if len(et.expirer.tracked) != 0 {
+		t.Errorf("transactions still tracked: %v", et.expirer.tracked)
+	}
+}
+
+func TestTxExpirerCancel(t *testing.T) {
+	tests := []struct {
+		name   string
+		mine   func(original, replacement *types.Transaction) *types.Transaction
+		status string
+	}{
+		{"replacement", func(original, replacement *types.Transaction) *types.Transaction { return replacement }, core.TxCancelled},
+		{"original", func(original, replacement *types.Transaction) *types.Transaction { return original }, core.TxMined},
+		{"other", func(original, replacement *types.Transaction) *types.Transaction {
+			other, _ := types.NewTransaction(0, common.HexToAddress("0x5678"), big.NewInt(1), core.TxGas, big.NewInt(1), nil).SignECDSA(testBankKey)
+			return other
+		}, core.TxSuperseded},
+	}
+	for _, test := range tests {
+		et := newExpiryTester(t)
+
+		tx := et.send(0, common.HexToAddress("0x1234"), TxExpiryArgs{TTL: rpc.NewHexNumber(10), CancelOnExpiry: true})
+		ev := et.expect(et.check(time.Now().Add(11*time.Second)), tx.Hash(), core.TxCancelling)
+
+		// The replacement is a self-transfer of nothing outbidding the original
+		replacement := et.expirer.pool.GetTransaction(ev.Replacement)
+		if replacement == nil {
+			t.Fatalf("%s: replacement not pooled", test.name)
+		}
+		if replacement.Nonce() != tx.Nonce() || *replacement.To() != testBank.Address || replacement.Value().Sign() != 0 ||
+			replacement.GasPrice().Cmp(minReplacementPrice(tx.GasPrice())) < 0 {
+			t.Fatalf("%s: invalid replacement: %v", test.name, replacement)
+		}
+		// Nothing is reported until the nonce is used
+		if events := et.check(time.Now()); len(events) != 0 {
+			t.Fatalf("%s: events before mining: %+v", test.name, events)
+		}
+		et.mine(test.mine(tx, replacement))
+		if ev := et.expect(et.check(time.Now()), tx.Hash(), test.status); ev.Replacement != replacement.Hash() {
+			t.Errorf("%s: replacement mismatch: have %x, want %x", test.name, ev.Replacement, replacement.Hash())
+		}
+		if len(et.expirer.tracked) != 0 {
+			t.Errorf("%s: transaction still tracked", test.name)
+		}
+		et.close()
+	}
+}
+
+// Tests that raw transactions can only be cancelled on expiry if the node can
+// sign the cancellation.
+func TestSendRawTransactionCancelOnExpiry(t *testing.T) {
+	keydir, err := ioutil.TempDir("", "tx-expiry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(keydir)
+	am, err := accounts.NewManager(keydir, accounts.LightScryptN, accounts.LightScryptP, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := &PublicTransactionPoolAPI{am: am}
+
+	tx, _ := types.NewTransaction(0, common.HexToAddress("0x1234"), big.NewInt(1000), core.TxGas, big.NewInt(1), nil).SignECDSA(testBankKey)
+	raw, _ := rlp.EncodeToBytes(tx)
+	_, err = api.SendRawTransaction(common.ToHex(raw), &TxExpiryArgs{TTL: rpc.NewHexNumber(10), CancelOnExpiry: true})
+	if err == nil || !strings.Contains(err.Error(), "account of this node") {
+		t.Fatalf("cancellable raw transaction of a foreign sender accepted: %v", err)
+	}
+}
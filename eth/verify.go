@@ -0,0 +1,196 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/common/compiler"
+	"github.com/openether/ethcore/common/hexutil"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/crypto"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+// contractVerificationPrefix + address -> verification result (JSON), in the dapp db
+var contractVerificationPrefix = []byte("contract-verification-")
+
+// verificationGas is the gas available to constructors run during verification.
+var verificationGas = big.NewInt(50000000)
+
+// VerifyContractArgs are the arguments of a contract source verification.
+type VerifyContractArgs struct {
+	Address         common.Address `json:"address"`
+	Source          string         `json:"source"`
+	Language        string         `json:"language"`        // defaults to Solidity
+	CompilerVersion string         `json:"compilerVersion"` // optional, must match the node's compiler
+	ContractName    string         `json:"contractName"`    // optional if the source has a single match
+	ConstructorArgs hexutil.Bytes  `json:"constructorArgs"` // ABI encoded constructor arguments
+}
+
+// ContractVerification is the result of verifying a contract source against the
+// code deployed at an address.
+type ContractVerification struct {
+	Address         common.Address `json:"address"`
+	Verified        bool           `json:"verified"`
+	ExactMatch      bool           `json:"exactMatch"` // the source metadata hash matches as well
+	ContractName    string         `json:"contractName,omitempty"`
+	Language        string         `json:"language,omitempty"`
+	CompilerVersion string         `json:"compilerVersion,omitempty"`
+	CompilerOptions string         `json:"compilerOptions,omitempty"`
+	Source          string         `json:"source,omitempty"`
+	ConstructorArgs hexutil.Bytes  `json:"constructorArgs,omitempty"`
+	AbiDefinition   interface{}    `json:"abiDefinition,omitempty"`
+	CodeHash        common.Hash    `json:"codeHash"`
+	BlockNumber     uint64         `json:"blockNumber"`
+	VerifiedAt      int64          `json:"verifiedAt,omitempty"`
+	Reason          string         `json:"reason,omitempty"`
+}
+
+// VerifyContract recompiles the given source and checks whether deploying it with
+// the given constructor arguments yields the code found at the address. Successful
+// verifications are stored and can be retrieved with GetContractVerification;
+// failed ones are only returned, so they can't replace an earlier success.
+func (api *PublicGethAPI) VerifyContract(args VerifyContractArgs) (*ContractVerification, error) {
+	if len(args.Source) == 0 {
+		return nil, errors.New("no source given")
+	}
+	comp, err := api.compiler(args.Language)
+	if err != nil {
+		return nil, err
+	}
+	if args.CompilerVersion != "" && args.CompilerVersion != comp.Version() {
+		return nil, fmt.Errorf("%s compiler version %s not available (have %s)", comp.Language(), args.CompilerVersion, comp.Version())
+	}
+	bc := api.eth.BlockChain()
+	block := bc.CurrentBlock()
+	statedb, err := bc.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	code := statedb.GetCode(args.Address)
+	if len(code) == 0 {
+		return nil, fmt.Errorf("no contract code at %x", args.Address)
+	}
+	contracts, err := comp.Compile(args.Source)
+	if err != nil {
+		return nil, err
+	}
+	if args.ContractName != "" {
+		contract, ok := contracts[args.ContractName]
+		if !ok {
+			return nil, fmt.Errorf("contract %q not found in source", args.ContractName)
+		}
+		contracts = map[string]*compiler.Contract{args.ContractName: contract}
+	}
+
+	result := &ContractVerification{
+		Address:         args.Address,
+		Language:        comp.Language(),
+		CompilerVersion: comp.Version(),
+		ConstructorArgs: args.ConstructorArgs,
+		CodeHash:        crypto.Keccak256Hash(code),
+		BlockNumber:     block.NumberU64(),
+		Reason:          "deployed code doesn't match any contract in the source",
+	}
+	for name, contract := range contracts {
+		deployed, err := api.deploy(append(common.FromHex(contract.Code), args.ConstructorArgs...))
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(compiler.StripMetadata(deployed), compiler.StripMetadata(code)) {
+			continue
+		}
+		result.Verified, result.ExactMatch, result.Reason = true, bytes.Equal(deployed, code), ""
+		result.ContractName = name
+		result.CompilerOptions = contract.Info.CompilerOptions
+		result.Source = args.Source
+		result.AbiDefinition = contract.Info.AbiDefinition
+		result.VerifiedAt = time.Now().Unix()
+		break
+	}
+	if !result.Verified {
+		return result, nil
+	}
+	blob, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.eth.DappDb().Put(append(contractVerificationPrefix, args.Address[:]...), blob); err != nil {
+		return nil, err
+	}
+	glog.V(logger.Info).Infof("Verified source of contract %s at %x", result.ContractName, args.Address)
+	return result, nil
+}
+
+// GetContractVerification returns the stored source verification of the contract
+// at the given address, or nil if it wasn't verified. A verification is reported
+// as failed if the code at the address changed since, ie. the contract suicided.
+func (api *PublicGethAPI) GetContractVerification(address common.Address) (*ContractVerification, error) {
+	key := append(contractVerificationPrefix, address[:]...)
+	if ok, err := api.eth.DappDb().Has(key); err != nil || !ok {
+		return nil, err
+	}
+	blob, err := api.eth.DappDb().Get(key)
+	if err != nil {
+		return nil, err
+	}
+	result := new(ContractVerification)
+	if err := json.Unmarshal(blob, result); err != nil {
+		return nil, err
+	}
+	statedb, err := api.eth.BlockChain().State()
+	if err != nil {
+		return nil, err
+	}
+	if crypto.Keccak256Hash(statedb.GetCode(address)) != result.CodeHash {
+		result.Verified, result.ExactMatch = false, false
+		result.Reason = "code at address changed since verification"
+	}
+	return result, nil
+}
+
+// compiler returns the available compiler of the given language.
+func (api *PublicGethAPI) compiler(language string) (compiler.Compiler, error) {
+	if language == "" {
+		language = "Solidity"
+	}
+	for _, c := range api.eth.Compilers() {
+		if strings.EqualFold(c.Language(), language) {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s compiler available", language)
+}
+
+// deploy runs the given contract creation code on top of the current state,
+// returning the code the contract would be deployed with.
+func (api *PublicGethAPI) deploy(code []byte) ([]byte, error) {
+	bc := api.eth.BlockChain()
+	block := bc.CurrentBlock()
+	statedb, err := bc.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	from := statedb.GetOrNewStateObject(common.Address{})
+	from.SetBalance(common.MaxBig)
+
+	msg := callmsg{
+		from:     from,
+		gas:      verificationGas,
+		gasPrice: new(big.Int),
+		value:    new(big.Int),
+		data:     code,
+	}
+	vmenv := core.NewEnv(statedb, api.eth.chainConfig, bc, msg, block.Header())
+	gp := new(core.GasPool).AddGas(common.MaxBig)
+
+	deployed, _, _, err := core.NewStateTransition(vmenv, msg, gp).TransitionDb()
+	return deployed, err
+}
@@ -0,0 +1,117 @@
+package eth
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/crypto"
+	"github.com/ethereumclassic/go-ethereum/ethdb"
+)
+
+// Contract creation code returning the runtime code 600160005260206000f3.
+const verifyTestCode = "69600160005260206000f3600052600a6016f3"
+
+// fakeVerifySolc creates a script mimicking solc 0.4.24, answering any standard
+// JSON request with a single contract of the given creation code.
+func fakeVerifySolc(t *testing.T, code string) (string, func()) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake solc needs a unix shell")
+	}
+	dir, err := ioutil.TempDir("", "solc-test")
+	if err != nil {
+		t.Fatal("cannot create temporary directory:", err)
+	}
+	output := `{"contracts":{"<stdin>":{"Test":{"abi":[],"metadata":"{}","evm":{"bytecode":{"object":"` + code + `"},"deployedBytecode":{"object":""}}}}}}`
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = \"--version\" ]; then echo 'solc, the solidity compiler commandline interface'; echo 'Version: 0.4.24+commit.deadbeef.Linux.g++'; exit 0; fi\n" +
+		"cat > /dev/null\n" +
+		"cat <<'EOF'\n" + output + "\nEOF\n"
+	path := filepath.Join(dir, "solc")
+	if err := ioutil.WriteFile(path, []byte(script), 0700); err != nil {
+		t.Fatal("could not write fake solc:", err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+// newVerifyTestAPI creates an API over a chain with the test contract deployed,
+// compiling sources with a fake solc producing the given creation code.
+func newVerifyTestAPI(t *testing.T, code string, db ethdb.Database) (*PublicGethAPI, common.Address, func()) {
+	_, chain := newTestBlockChainAPI(1, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewContractCreation(0, new(big.Int), big.NewInt(100000), big.NewInt(1), common.FromHex(verifyTestCode)).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	})
+	solc, cleanup := fakeVerifySolc(t, code)
+	eth := &Ethereum{blockchain: chain, chainConfig: chain.Config(), dappDb: db, SolcPath: solc}
+	return NewPublicGethAPI(eth), crypto.CreateAddress(testBank.Address, 0), cleanup
+}
+
+func TestVerifyContract(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	api, address, cleanup := newVerifyTestAPI(t, verifyTestCode, db)
+	defer cleanup()
+
+	if result, err := api.GetContractVerification(address); result != nil || err != nil {
+		t.Fatalf("verification of unverified contract: %v, %v", result, err)
+	}
+	result, err := api.VerifyContract(VerifyContractArgs{Address: address, Source: "contract Test {}"})
+	if err != nil {
+		t.Fatalf("failed to verify contract: %v", err)
+	}
+	if !result.Verified || !result.ExactMatch || result.ContractName != "Test" || result.CompilerVersion != "0.4.24" {
+		t.Fatalf("verification mismatch: %+v", result)
+	}
+	stored, err := api.GetContractVerification(address)
+	if err != nil {
+		t.Fatalf("failed to look up verification: %v", err)
+	}
+	if stored == nil || !stored.Verified || stored.CodeHash != result.CodeHash || stored.Source != "contract Test {}" {
+		t.Fatalf("stored verification mismatch: have %+v, want %+v", stored, result)
+	}
+	if _, err := api.VerifyContract(VerifyContractArgs{Address: common.Address{1}, Source: "contract Test {}"}); err == nil {
+		t.Errorf("verified contract at an address without code")
+	}
+}
+
+func TestVerifyContractMismatch(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	api, address, cleanup := newVerifyTestAPI(t, "69600260005260206000f3600052600a6016f3", db)
+	defer cleanup()
+
+	result, err := api.VerifyContract(VerifyContractArgs{Address: address, Source: "contract Test {}"})
+	if err != nil {
+		t.Fatalf("failed to verify contract: %v", err)
+	}
+	if result.Verified || result.Reason == "" {
+		t.Fatalf("mismatching source verified: %+v", result)
+	}
+	// Failed verifications aren't stored
+	if stored, err := api.GetContractVerification(address); stored != nil || err != nil {
+		t.Errorf("failed verification stored: %v, %v", stored, err)
+	}
+}
+
+// failingDb is a database failing every read.
+type failingDb struct {
+	*ethdb.MemDatabase
+}
+
+func (db failingDb) Has(key []byte) (bool, error)   { return false, errors.New("read failed") }
+func (db failingDb) Get(key []byte) ([]byte, error) { return nil, errors.New("read failed") }
+
+func TestGetContractVerificationReadError(t *testing.T) {
+	mem, _ := ethdb.NewMemDatabase()
+	api, address, cleanup := newVerifyTestAPI(t, verifyTestCode, failingDb{mem})
+	defer cleanup()
+
+	if result, err := api.GetContractVerification(address); err == nil {
+		t.Errorf("read error not reported, got %v", result)
+	}
+}
@@ -0,0 +1,191 @@
+// Package ethclient provides a Go API to an Ethereum node running in the same
+// process, for programs embedding ethcore as a library.
+//
+// The API deals in the core types (blocks, headers, receipts, big integers and
+// byte slices) rather than the hex encoded values of the RPC interface.
+package ethclient
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/eth"
+	"github.com/openether/ethcore/event"
+	"github.com/openether/ethcore/rpc"
+)
+
+// ErrNotFound is returned by the retrieval methods for unknown blocks and
+// transactions.
+var ErrNotFound = errors.New("not found")
+
+// Client gives access to the chain and state of an in-process Ethereum node.
+type Client struct {
+	eth   *eth.Ethereum
+	bcapi *eth.PublicBlockChainAPI // Executes the contract calls
+}
+
+// NewClient creates a client for the given Ethereum object.
+func NewClient(ethereum *eth.Ethereum) *Client {
+	return &Client{
+		eth:   ethereum,
+		bcapi: eth.NewPublicBlockChainAPI(ethereum.ChainConfig(), ethereum.BlockChain(), ethereum.ChainDb(), ethereum.GasPriceOracle(), ethereum.EventMux(), ethereum.AccountManager(), nil),
+	}
+}
+
+// BlockByHash returns the block with the given hash.
+func (c *Client) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	if block := c.eth.BlockChain().GetBlock(hash); block != nil {
+		return block, nil
+	}
+	return nil, ErrNotFound
+}
+
+// BlockByNumber returns the canonical block with the given number, or the head
+// block if number is nil.
+func (c *Client) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	bc := c.eth.BlockChain()
+	if number == nil {
+		return bc.CurrentBlock(), nil
+	}
+	if !number.IsUint64() {
+		return nil, ErrNotFound
+	}
+	if block := bc.GetBlockByNumber(number.Uint64()); block != nil {
+		return block, nil
+	}
+	return nil, ErrNotFound
+}
+
+// HeaderByNumber returns the canonical header with the given number, or the head
+// header if number is nil.
+func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	bc := c.eth.BlockChain()
+	if number == nil {
+		return bc.CurrentHeader(), nil
+	}
+	if !number.IsUint64() {
+		return nil, ErrNotFound
+	}
+	if header := bc.GetHeaderByNumber(number.Uint64()); header != nil {
+		return header, nil
+	}
+	return nil, ErrNotFound
+}
+
+// TransactionReceipt returns the receipt of a mined transaction. Receipts stored
+// before the transaction status was tracked have the status TxStatusUnknown.
+func (c *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	if receipt := core.GetReceipt(c.eth.ChainDb(), txHash); receipt != nil {
+		return receipt, nil
+	}
+	return nil, ErrNotFound
+}
+
+// CallMsg contains the parameters of a contract call.
+type CallMsg struct {
+	From     common.Address  // Sender of the call, the first account if zero
+	To       *common.Address // Called contract, nil for contract creation
+	Gas      *big.Int        // Gas limit of the call, the default of the node if nil
+	GasPrice *big.Int        // Gas price of the call, the suggested price if nil
+	Value    *big.Int        // Amount of wei sent along with the call
+	Data     []byte          // Input data of the call
+}
+
+// CallContract executes a message call on the state of the canonical block with
+// the given number, or of the head block if number is nil, and returns its output.
+// The state is left unchanged.
+func (c *Client) CallContract(ctx context.Context, msg CallMsg, number *big.Int) ([]byte, error) {
+	blockNr := rpc.LatestBlockNumber
+	if number != nil {
+		if !number.IsInt64() {
+			return nil, ErrNotFound
+		}
+		blockNr = rpc.BlockNumber(number.Int64())
+	}
+	args := eth.CallArgs{
+		From: msg.From,
+		To:   msg.To,
+		Data: common.ToHex(msg.Data),
+	}
+	if msg.Gas != nil {
+		args.Gas = rpc.NewHexNumber(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		args.GasPrice = rpc.NewHexNumber(msg.GasPrice)
+	}
+	if msg.Value != nil {
+		args.Value = *rpc.NewHexNumber(msg.Value)
+	}
+	return c.bcapi.Call(ctx, args, blockNr)
+}
+
+// Subscription is a subscription to chain events. The delivery of events stops
+// once it is unsubscribed or its context is done.
+type Subscription interface {
+	// Unsubscribe stops the delivery of events. It can be called more than once.
+	Unsubscribe()
+	// Err returns a channel closed once the subscription ends, carrying the
+	// context error if it ended because its context was done.
+	Err() <-chan error
+}
+
+// SubscribeNewHead sends the header of each new head block of the chain to ch.
+// Headers are dropped while ch isn't ready to receive them, so that a slow
+// subscriber doesn't hold up the import of blocks.
+func (c *Client) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (Subscription, error) {
+	sub := &headSubscription{
+		events: c.eth.EventMux().Subscribe(core.ChainHeadEvent{}),
+		quit:   make(chan struct{}),
+		err:    make(chan error, 1),
+	}
+	go sub.loop(ctx, ch)
+	return sub, nil
+}
+
+// headSubscription forwards chain head events to the channel of a subscriber.
+type headSubscription struct {
+	events event.Subscription
+	quit   chan struct{}
+	once   sync.Once
+	err    chan error
+}
+
+func (s *headSubscription) loop(ctx context.Context, ch chan<- *types.Header) {
+	defer close(s.err)
+	defer s.events.Unsubscribe()
+
+	for {
+		select {
+		case ev, ok := <-s.events.Chan():
+			if !ok {
+				return
+			}
+			head, ok := ev.Data.(core.ChainHeadEvent)
+			if !ok || head.Block == nil {
+				continue
+			}
+			select {
+			case ch <- head.Block.Header():
+			default:
+			}
+		case <-ctx.Done():
+			s.err <- ctx.Err()
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *headSubscription) Unsubscribe() {
+	s.once.Do(func() { close(s.quit) })
+}
+
+func (s *headSubscription) Err() <-chan error {
+	return s.err
+}
@@ -0,0 +1,225 @@
+package ethclient
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/accounts"
+	"github.com/ethereumclassic/go-ethereum/common"
+	"github.com/ethereumclassic/go-ethereum/core"
+	"github.com/ethereumclassic/go-ethereum/core/types"
+	"github.com/ethereumclassic/go-ethereum/crypto"
+	"github.com/ethereumclassic/go-ethereum/eth"
+	"github.com/ethereumclassic/go-ethereum/ethdb"
+	"github.com/ethereumclassic/go-ethereum/logger/glog"
+	"github.com/ethereumclassic/go-ethereum/node"
+	"github.com/ethereumclassic/go-ethereum/pow"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+
+	// Init code deploying a contract which returns 42 as a word:
+	// PUSH1 0x2a PUSH1 0 MSTORE PUSH1 0x20 PUSH1 0 RETURN
+	testContractCode = common.FromHex("0x69602a60005260206000f3600052600a6016f3")
+)
+
+func init() {
+	glog.SetD(0)
+	glog.SetV(0)
+}
+
+// tester is a client over a networkless node, whose chain holds a block
+// deploying the test contract followed by an empty block.
+type tester struct {
+	workspace string
+	stack     *node.Node
+	ethereum  *eth.Ethereum
+	client    *Client
+	blocks    []*types.Block
+	deploy    *types.Transaction
+}
+
+func newTester(t *testing.T) *tester {
+	workspace, err := ioutil.TempDir("", "ethclient-tester-")
+	if err != nil {
+		t.Fatalf("failed to create workspace: %v", err)
+	}
+	accman, err := accounts.NewManager(filepath.Join(workspace, "keystore"), accounts.LightScryptN, accounts.LightScryptP, false)
+	if err != nil {
+		t.Fatalf("failed to create account manager: %v", err)
+	}
+	db, _ := ethdb.NewMemDatabase()
+	genesis := core.WriteGenesisBlockForTesting(db, core.GenesisAccount{Address: testAddress, Balance: big.NewInt(1000000)})
+
+	stack, err := node.New(&node.Config{DataDir: workspace, Name: "ethclient-tester", NoDiscovery: true})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	ethConf := &eth.Config{
+		ChainConfig:      core.DefaultConfigMorden.ChainConfig,
+		AccountManager:   accman,
+		Sealer:           pow.Config{Mode: pow.ModeTest},
+		TestGenesisState: db,
+	}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) { return eth.New(ctx, ethConf) }); err != nil {
+		t.Fatalf("failed to register Ethereum protocol: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start test stack: %v", err)
+	}
+	var ethereum *eth.Ethereum
+	stack.Service(&ethereum)
+
+	deploy, _ := types.NewContractCreation(0, new(big.Int), big.NewInt(100000), big.NewInt(1), testContractCode).SignECDSA(testKey)
+	blocks, receipts := core.GenerateChain(ethConf.ChainConfig, genesis, db, 2, func(i int, block *core.BlockGen) {
+		if i == 0 {
+			block.AddTx(deploy)
+		}
+	})
+	for i, block := range blocks {
+		if _, err := ethereum.BlockChain().WriteBlock(block); err != nil {
+			t.Fatalf("failed to write block: %v", err)
+		}
+		if err := core.WriteTransactions(db, block); err != nil {
+			t.Fatalf("failed to write transactions: %v", err)
+		}
+		if err := core.WriteReceipts(db, receipts[i]); err != nil {
+			t.Fatalf("failed to write receipts: %v", err)
+		}
+		if err := core.WriteBlockReceipts(db, block.Hash(), receipts[i]); err != nil {
+			t.Fatalf("failed to write block receipts: %v", err)
+		}
+	}
+	return &tester{
+		workspace: workspace,
+		stack:     stack,
+		ethereum:  ethereum,
+		client:    NewClient(ethereum),
+		blocks:    blocks,
+		deploy:    deploy,
+	}
+}
+
+func (env *tester) close(t *testing.T) {
+	if err := env.stack.Stop(); err != nil {
+		t.Errorf("failed to stop node: %v", err)
+	}
+	os.RemoveAll(env.workspace)
+}
+
+func TestBlockRetrieval(t *testing.T) {
+	env := newTester(t)
+	defer env.close(t)
+	ctx := context.Background()
+
+	if block, err := env.client.BlockByHash(ctx, env.blocks[0].Hash()); err != nil || block.Hash() != env.blocks[0].Hash() {
+		t.Errorf("block by hash mismatch: have %v, %v", block, err)
+	}
+	if _, err := env.client.BlockByHash(ctx, common.Hash{0x01}); err != ErrNotFound {
+		t.Errorf("unknown block by hash: have error %v, want %v", err, ErrNotFound)
+	}
+	tests := []struct {
+		number *big.Int
+		hash   common.Hash
+		err    error
+	}{
+		{nil, env.blocks[1].Hash(), nil},
+		{big.NewInt(1), env.blocks[0].Hash(), nil},
+		{big.NewInt(3), common.Hash{}, ErrNotFound},
+		{big.NewInt(-1), common.Hash{}, ErrNotFound},
+	}
+	for _, tt := range tests {
+		block, err := env.client.BlockByNumber(ctx, tt.number)
+		if err != tt.err || (err == nil && block.Hash() != tt.hash) {
+			t.Errorf("block #%v: have %v, %v, want %x, %v", tt.number, block, err, tt.hash, tt.err)
+		}
+		header, err := env.client.HeaderByNumber(ctx, tt.number)
+		if err != tt.err || (err == nil && header.Hash() != tt.hash) {
+			t.Errorf("header #%v: have %v, %v, want %x, %v", tt.number, header, err, tt.hash, tt.err)
+		}
+	}
+}
+
+func TestTransactionReceipt(t *testing.T) {
+	env := newTester(t)
+	defer env.close(t)
+	ctx := context.Background()
+
+	receipt, err := env.client.TransactionReceipt(ctx, env.deploy.Hash())
+	if err != nil {
+		t.Fatalf("failed to get receipt: %v", err)
+	}
+	if receipt.TxHash != env.deploy.Hash() || receipt.ContractAddress != crypto.CreateAddress(testAddress, 0) {
+		t.Errorf("receipt mismatch: have %v", receipt)
+	}
+	if _, err := env.client.TransactionReceipt(ctx, common.Hash{0x01}); err != ErrNotFound {
+		t.Errorf("unknown receipt: have error %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestCallContract(t *testing.T) {
+	env := newTester(t)
+	defer env.close(t)
+	ctx := context.Background()
+
+	contract := crypto.CreateAddress(testAddress, 0)
+	msg := CallMsg{From: testAddress, To: &contract, GasPrice: big.NewInt(1)}
+
+	out, err := env.client.CallContract(ctx, msg, nil)
+	if err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	if new(big.Int).SetBytes(out).Int64() != 42 {
+		t.Errorf("call output mismatch: have %x, want 42", out)
+	}
+	// Before the deployment there is no code to run
+	if out, err := env.client.CallContract(ctx, msg, big.NewInt(0)); err != nil || len(out) != 0 {
+		t.Errorf("call before deployment: have %x, %v, want no output", out, err)
+	}
+}
+
+func TestSubscribeNewHead(t *testing.T) {
+	env := newTester(t)
+	defer env.close(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	heads := make(chan *types.Header, 1)
+	sub, err := env.client.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	env.ethereum.EventMux().Post(core.ChainHeadEvent{Block: env.blocks[1]})
+	select {
+	case head := <-heads:
+		if head.Hash() != env.blocks[1].Hash() {
+			t.Errorf("head mismatch: have %x, want %x", head.Hash(), env.blocks[1].Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("head not delivered")
+	}
+	// Heads are dropped while the subscriber isn't receiving them
+	heads <- nil
+	env.ethereum.EventMux().Post(core.ChainHeadEvent{Block: env.blocks[0]})
+
+	cancel()
+	select {
+	case err := <-sub.Err():
+		if err != context.Canceled {
+			t.Errorf("subscription error mismatch: have %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription not ended by its context")
+	}
+	if head := <-heads; head != nil {
+		t.Errorf("head delivered to a full channel: %x", head.Hash())
+	}
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+}
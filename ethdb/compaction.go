@@ -0,0 +1,191 @@
+package ethdb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/metrics"
+	ldbutil "github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// Level-0 table counts of databases with deferred compaction, in place of
+	// the LevelDB defaults of 4, 8 and 12. Automatic compaction only kicks in
+	// once the scheduled ones fell far behind, and writes are slowed down later.
+	deferredL0Trigger         = 16
+	deferredL0SlowdownTrigger = 32
+	deferredL0PauseTrigger    = 48
+
+	compactionCheckInterval = time.Minute // Interval between checks for compaction debt
+	compactionChunks        = 16          // Number of key ranges the scheduled compaction is split into
+)
+
+// deferCompaction lists the databases whose compactions are deferred to the
+// windows given to ScheduleCompaction.
+var deferCompaction = map[string]bool{}
+
+// SetDeferCompaction sets whether the compactions of the given database are
+// deferred. It must be set before the database is opened.
+func SetDeferCompaction(db string, deferred bool) {
+	deferCompaction[db] = deferred
+}
+
+// CompactionWindow is a daily period of local time, such as off-peak hours.
+type CompactionWindow struct {
+	Start time.Duration // Offset from midnight
+	End   time.Duration // Offset from midnight, before Start if the window spans midnight
+}
+
+// Contains returns whether the time of day of t is within the window.
+func (w CompactionWindow) Contains(t time.Time) bool {
+	h, m, s := t.Clock()
+	offset := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+func (w CompactionWindow) String() string {
+	format := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	return format(w.Start) + "-" + format(w.End)
+}
+
+// ParseCompactionWindows parses comma separated daily windows of local time,
+// eg. "01:00-05:00,13:30-14:00". A window may span midnight, eg. "22:00-02:00".
+func ParseCompactionWindows(s string) ([]CompactionWindow, error) {
+	var windows []CompactionWindow
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		bounds := strings.Split(field, "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid window %q, want HH:MM-HH:MM", field)
+		}
+		var (
+			window CompactionWindow
+			err    error
+		)
+		if window.Start, err = parseTimeOfDay(bounds[0]); err != nil {
+			return nil, fmt.Errorf("invalid window %q: %v", field, err)
+		}
+		if window.End, err = parseTimeOfDay(bounds[1]); err != nil {
+			return nil, fmt.Errorf("invalid window %q: %v", field, err)
+		}
+		if window.Start == window.End {
+			return nil, fmt.Errorf("invalid window %q: empty", field)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// parseTimeOfDay parses a HH:MM time of day into its offset from midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	h, err := strconv.Atoi(parts[0])
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid hour in %q", s)
+	}
+	m, err := strconv.Atoi(parts[1])
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid minute in %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// CompactionDebt returns the number of level-0 tables, which are awaiting
+// compaction into the deeper levels.
+func (self *LDBDatabase) CompactionDebt() int {
+	value, err := self.db.GetProperty("leveldb.num-files-at-level0")
+	if err != nil {
+		return 0
+	}
+	debt, _ := strconv.Atoi(value)
+	return debt
+}
+
+// ScheduleCompaction compacts the database whenever it has compaction debt and
+// either the local time is within one of the windows or idle returns true. Each
+// compaction is split into key ranges and stops early once neither holds.
+// Scheduling stops when the database is closed.
+func (self *LDBDatabase) ScheduleCompaction(windows []CompactionWindow, idle func() bool) {
+	allowed := func() bool {
+		now := time.Now()
+		for _, w := range windows {
+			if w.Contains(now) {
+				return true
+			}
+		}
+		return idle != nil && idle()
+	}
+	self.quitLock.Lock()
+	defer self.quitLock.Unlock()
+	if self.quitChan != nil {
+		return
+	}
+	self.quitChan = make(chan chan error)
+	go self.compactionLoop(allowed)
+}
+
+func (self *LDBDatabase) compactionLoop(allowed func() bool) {
+	ticker := time.NewTicker(compactionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case errc := <-self.quitChan:
+			errc <- nil
+			return
+		case <-ticker.C:
+		}
+		debt := self.CompactionDebt()
+		metrics.DBCompactionDebt.Update(int64(debt))
+		if debt == 0 {
+			continue
+		}
+		if !allowed() {
+			metrics.DBCompactionDeferred.Mark(1)
+			continue
+		}
+		glog.V(logger.Info).Infof("Compacting %s, %d level-0 tables pending", self.file, debt)
+		start := time.Now()
+		for i := 0; i < compactionChunks; i++ {
+			if i > 0 && !allowed() {
+				metrics.DBCompactionAborts.Mark(1)
+				glog.V(logger.Info).Infof("Compaction of %s interrupted after %v", self.file, time.Since(start))
+				break
+			}
+			if err := self.db.CompactRange(compactionChunk(i)); err != nil {
+				glog.V(logger.Error).Errorf("Compaction of %s failed: %v", self.file, err)
+				break
+			}
+		}
+		metrics.DBCompactionTimer.UpdateSince(start)
+		metrics.DBCompactionDebt.Update(int64(self.CompactionDebt()))
+	}
+}
+
+// compactionChunk returns the i-th of the key ranges the key space is split
+// into by leading byte, unbounded at both ends.
+func compactionChunk(i int) ldbutil.Range {
+	var r ldbutil.Range
+	if i > 0 {
+		r.Start = []byte{byte(i * 256 / compactionChunks)}
+	}
+	if i < compactionChunks-1 {
+		r.Limit = []byte{byte((i + 1) * 256 / compactionChunks)}
+	}
+	return r
+}
@@ -0,0 +1,41 @@
+package ethdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCompactionWindows(t *testing.T) {
+	windows, err := ParseCompactionWindows("01:00-05:30, 22:00-02:00")
+	if err != nil {
+		t.Fatalf("failed to parse windows: %v", err)
+	}
+	if len(windows) != 2 || windows[0].String() != "01:00-05:30" || windows[1].String() != "22:00-02:00" {
+		t.Fatalf("windows mismatch: %v", windows)
+	}
+	day := time.Date(2017, 1, 1, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		window int
+		at     time.Duration
+		want   bool
+	}{
+		{0, 0, false},
+		{0, time.Hour, true},
+		{0, 5*time.Hour + 29*time.Minute, true},
+		{0, 5*time.Hour + 30*time.Minute, false},
+		{1, 21 * time.Hour, false},
+		{1, 23 * time.Hour, true},
+		{1, time.Hour, true},
+		{1, 3 * time.Hour, false},
+	}
+	for i, tt := range tests {
+		if have := windows[tt.window].Contains(day.Add(tt.at)); have != tt.want {
+			t.Errorf("test %d: %v contains %v: have %v, want %v", i, windows[tt.window], tt.at, have, tt.want)
+		}
+	}
+	for _, invalid := range []string{"01:00", "01:00-01:00", "25:00-01:00", "01:60-02:00", "a-b"} {
+		if _, err := ParseCompactionWindows(invalid); err == nil {
+			t.Errorf("invalid windows %q accepted", invalid)
+		}
+	}
+}
@@ -46,6 +46,8 @@ type LDBDatabase struct {
 	file string
 	db   *leveldb.DB
 
+	cacheCapacity int // Capacity of the block cache in bytes
+
 	quitLock sync.Mutex      // Mutex protecting the quit channel access
 	quitChan chan chan error // Quit channel to stop the metrics collection before closing the database
 }
@@ -64,13 +66,19 @@ func NewLDBDatabase(file string, cache int, handles int) (*LDBDatabase, error) {
 	glog.V(logger.Info).Infof("Allotted %dMB cache and %d file handles to %s", cache, handles, file)
 	glog.D(logger.Warn).Infof("Allotted %s cache and %s file handles to %s", logger.ColorGreen(strconv.Itoa(cache)+"MB"), logger.ColorGreen(strconv.Itoa(handles)), logger.ColorGreen(file))
 
-	// Open the db and recover any potential corruptions
-	db, err := leveldb.OpenFile(file, &opt.Options{
+	options := &opt.Options{
 		OpenFilesCacheCapacity: handles,
 		BlockCacheCapacity:     cache / 2 * opt.MiB,
 		WriteBuffer:            cache / 4 * opt.MiB, // Two of these are used internally
 		Filter:                 filter.NewBloomFilter(10),
-	})
+	}
+	if deferCompaction[filepath.Base(file)] {
+		options.CompactionL0Trigger = deferredL0Trigger
+		options.WriteL0SlowdownTrigger = deferredL0SlowdownTrigger
+		options.WriteL0PauseTrigger = deferredL0PauseTrigger
+	}
+	// Open the db and recover any potential corruptions
+	db, err := leveldb.OpenFile(file, options)
 	if _, corrupted := err.(*errors.ErrCorrupted); corrupted {
 		db, err = leveldb.RecoverFile(file, nil)
 	}
@@ -79,8 +87,9 @@ func NewLDBDatabase(file string, cache int, handles int) (*LDBDatabase, error) {
 		return nil, err
 	}
 	return &LDBDatabase{
-		file: file,
-		db:   db,
+		file:          file,
+		db:            db,
+		cacheCapacity: cache / 2 * opt.MiB,
 	}, nil
 }
 
@@ -104,6 +113,13 @@ func (self *LDBDatabase) Get(key []byte) ([]byte, error) {
 	return dat, nil
 }
 
+// GetUncached returns the given key if it's present, without filling the block
+// cache with the blocks read. It's meant for bulk reads on behalf of others that
+// shouldn't evict the data used by local processing.
+func (self *LDBDatabase) GetUncached(key []byte) ([]byte, error) {
+	return self.db.Get(key, &opt.ReadOptions{DontFillCache: true})
+}
+
 func (db *LDBDatabase) Has(key []byte) (bool, error) {
 	return db.db.Has(key, nil)
 }
@@ -127,6 +143,16 @@ func NewBytesPrefix(prefix []byte) *ldbutil.Range {
 }
 
 func (self *LDBDatabase) Close() {
+	// Stop the scheduled compactions, if any
+	self.quitLock.Lock()
+	if self.quitChan != nil {
+		errc := make(chan error)
+		self.quitChan <- errc
+		<-errc
+		self.quitChan = nil
+	}
+	self.quitLock.Unlock()
+
 	if err := self.db.Close(); err != nil {
 		glog.Errorf("eth: DB %s: %s", self.file, err)
 	}
@@ -136,6 +162,15 @@ func (self *LDBDatabase) LDB() *leveldb.DB {
 	return self.db
 }
 
+// BlockCacheStats returns the number of bytes held in the block cache of the
+// database, and its capacity.
+func (self *LDBDatabase) BlockCacheStats() (size, capacity int) {
+	if value, err := self.db.GetProperty("leveldb.cachedblock"); err == nil {
+		size, _ = strconv.Atoi(value)
+	}
+	return size, self.cacheCapacity
+}
+
 // TODO: remove this stuff and expose leveldb directly
 
 func (db *LDBDatabase) NewBatch() Batch {
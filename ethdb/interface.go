@@ -18,6 +18,12 @@ type Database interface {
 	NewBatch() Batch
 }
 
+// UncachedGetter is implemented by databases able to read entries bypassing
+// their internal caches.
+type UncachedGetter interface {
+	GetUncached(key []byte) ([]byte, error)
+}
+
 type Batch interface {
 	Putter
 	ValueSize() int // amount of data in the batch
@@ -0,0 +1,543 @@
+// Package ethstats implements the network stats reporting service, pushing the
+// state of the local node to an ethstats compatible dashboard server.
+package ethstats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openether/ethcore/common"
+	"github.com/openether/ethcore/core"
+	"github.com/openether/ethcore/core/types"
+	"github.com/openether/ethcore/eth"
+	"github.com/openether/ethcore/event"
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/node"
+	"github.com/openether/ethcore/p2p"
+	"github.com/openether/ethcore/rpc"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// historyUpdateRange is the number of blocks a node should report upon login or
+	// history request.
+	historyUpdateRange = 50
+
+	reportInterval = 15 * time.Second // Time between full stats reports
+	retryInterval  = 10 * time.Second // Time to wait before reconnecting after a failure
+	pingTimeout    = 5 * time.Second  // Time to wait for the server to answer a latency ping
+	txReportDelay  = time.Second      // Minimum time between pending transaction reports
+)
+
+// Service implements an Ethereum netstats reporting daemon that pushes local
+// chain statistics up to a monitoring server.
+type Service struct {
+	server *p2p.Server   // Peer-to-peer server to retrieve networking infos
+	eth    *eth.Ethereum // Ethereum service to retrieve chain and pool infos
+	mux    *event.TypeMux
+
+	node string // Name of the node to display on the monitoring page
+	pass string // Password to authorize access to the monitoring page
+	host string // Remote address of the monitoring service
+
+	pongCh chan struct{} // Pong notifications are fed into this channel
+	histCh chan []uint64 // History request block numbers are fed into this channel
+	quit   chan struct{}
+}
+
+// New returns a monitoring service ready for stats reporting. The url is of the
+// form nodename:secret@host:port.
+func New(url string, ethServ *eth.Ethereum) (*Service, error) {
+	name, pass, host, err := parseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		eth:    ethServ,
+		mux:    ethServ.EventMux(),
+		node:   name,
+		pass:   pass,
+		host:   host,
+		pongCh: make(chan struct{}),
+		histCh: make(chan []uint64, 1),
+		quit:   make(chan struct{}),
+	}, nil
+}
+
+// urlRegexp matches reporting URLs of the form nodename:secret@host:port.
+var urlRegexp = regexp.MustCompile("^([^:@]*)(:([^@]*))?@(.+)$")
+
+// parseURL splits a reporting URL into the node name, secret and server address.
+func parseURL(url string) (name, pass, host string, err error) {
+	parts := urlRegexp.FindStringSubmatch(url)
+	if len(parts) != 5 {
+		return "", "", "", fmt.Errorf("invalid netstats url: \"%s\", should be nodename:secret@host:port", url)
+	}
+	return parts[1], parts[3], parts[4], nil
+}
+
+// NewService returns a constructor registering the reporting service with the
+// protocol stack, attached to the Ethereum service.
+func NewService(url string) node.ServiceConstructor {
+	return func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		return New(url, ethServ)
+	}
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the stats service (nil as it doesn't use the devp2p overlay network).
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// stats service (nil as it doesn't provide any user callable APIs).
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting up the monitoring and reporting daemon.
+func (s *Service) Start(server *p2p.Server) error {
+	s.server = server
+	go s.loop()
+
+	glog.V(logger.Info).Infof("Stats daemon started, reporting to %s as %q", s.host, s.node)
+	return nil
+}
+
+// Stop implements node.Service, terminating the monitoring and reporting daemon.
+func (s *Service) Stop() error {
+	close(s.quit)
+	glog.V(logger.Info).Infoln("Stats daemon stopped")
+	return nil
+}
+
+// loop keeps trying to connect to the netstats server, reporting chain events
+// until termination.
+func (s *Service) loop() {
+	sub := s.mux.Subscribe(core.ChainHeadEvent{}, core.TxPreEvent{})
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-s.quit:
+			return
+		default:
+		}
+		if err := s.session(sub); err != nil {
+			glog.V(logger.Warn).Warnf("Stats server %s unreachable: %v", s.host, err)
+		}
+		select {
+		case <-s.quit:
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// session connects and logs in to the stats server, then reports the node's
+// state until the connection breaks or the service is stopped.
+func (s *Service) session(sub event.Subscription) error {
+	// Resolve the URL, defaulting to TLS, but falling back to none too
+	path := fmt.Sprintf("%s/api", s.host)
+	urls := []string{path}
+	if !strings.Contains(path, "://") {
+		urls = []string{"wss://" + path, "ws://" + path}
+	}
+	var (
+		conn *websocket.Conn
+		err  error
+	)
+	for _, url := range urls {
+		if conn, err = websocket.Dial(url, "", "http://localhost/"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err = s.login(conn); err != nil {
+		return err
+	}
+	readErr := make(chan error, 1)
+	go func() { readErr <- s.readLoop(conn) }()
+
+	if err = s.report(conn); err != nil {
+		return err
+	}
+	var (
+		ticker  = time.NewTicker(reportInterval)
+		lastTxs time.Time
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.quit:
+			return nil
+
+		case err := <-readErr:
+			return err
+
+		case <-ticker.C:
+			if err = s.report(conn); err != nil {
+				return err
+			}
+
+		case list := <-s.histCh:
+			if err = s.reportHistory(conn, list); err != nil {
+				return err
+			}
+
+		case ev, ok := <-sub.Chan():
+			if !ok {
+				return nil
+			}
+			switch ev.Data.(type) {
+			case core.ChainHeadEvent:
+				if err = s.reportBlock(conn, nil); err != nil {
+					return err
+				}
+				if err = s.reportPending(conn); err != nil {
+					return err
+				}
+			case core.TxPreEvent:
+				// Transactions arrive in bursts, don't flood the server
+				if time.Since(lastTxs) < txReportDelay {
+					continue
+				}
+				lastTxs = time.Now()
+				if err = s.reportPending(conn); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// readLoop processes the messages sent by the stats server, answering its pings
+// and passing on latency pongs and history requests.
+func (s *Service) readLoop(conn *websocket.Conn) error {
+	for {
+		var msg map[string][]interface{}
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			return err
+		}
+		if len(msg["emit"]) == 0 {
+			return fmt.Errorf("invalid stats message: %v", msg)
+		}
+		command, ok := msg["emit"][0].(string)
+		if !ok {
+			return fmt.Errorf("invalid stats command: %v", msg["emit"][0])
+		}
+		switch command {
+		case "node-ping":
+			// The server pinged us, reply with its own timestamp
+			if len(msg["emit"]) < 2 {
+				continue
+			}
+			pong := map[string][]interface{}{"emit": {"node-pong", map[string]interface{}{
+				"id":         s.node,
+				"clientTime": msg["emit"][1],
+			}}}
+			if err := websocket.JSON.Send(conn, pong); err != nil {
+				return err
+			}
+
+		case "node-pong":
+			// The server answered our latency ping
+			select {
+			case s.pongCh <- struct{}{}:
+			default:
+			}
+
+		case "history":
+			// The server wants blocks it's missing, if it didn't ask for any in
+			// particular, send the most recent ones
+			var list []uint64
+			if len(msg["emit"]) > 1 {
+				if request, ok := msg["emit"][1].(map[string]interface{}); ok {
+					if numbers, ok := request["list"].([]interface{}); ok {
+						for _, number := range numbers {
+							if n, ok := number.(float64); ok {
+								list = append(list, uint64(n))
+							}
+						}
+					}
+				}
+			}
+			select {
+			case s.histCh <- list:
+			default:
+			}
+		}
+	}
+}
+
+// nodeInfo is the collection of metainformation about a node that is displayed
+// on the monitoring page.
+type nodeInfo struct {
+	Name     string `json:"name"`
+	Node     string `json:"node"`
+	Port     int    `json:"port"`
+	Network  string `json:"net"`
+	Protocol string `json:"protocol"`
+	API      string `json:"api"`
+	Os       string `json:"os"`
+	OsVer    string `json:"os_v"`
+	Client   string `json:"client"`
+	History  bool   `json:"canUpdateHistory"`
+}
+
+// authMsg is the authentication infos needed to login to a monitoring server.
+type authMsg struct {
+	Id     string   `json:"id"`
+	Info   nodeInfo `json:"info"`
+	Secret string   `json:"secret"`
+}
+
+// login tries to authorize the client at the remote server.
+func (s *Service) login(conn *websocket.Conn) error {
+	info := s.server.NodeInfo()
+
+	var protocols []string
+	for _, proto := range s.server.Protocols {
+		protocols = append(protocols, fmt.Sprintf("%s/%d", proto.Name, proto.Version))
+	}
+	auth := &authMsg{
+		Id: s.node,
+		Info: nodeInfo{
+			Name:     s.node,
+			Node:     info.Name,
+			Port:     info.Ports.Listener,
+			Network:  strconv.Itoa(s.eth.NetVersion()),
+			Protocol: strings.Join(protocols, ", "),
+			API:      "No",
+			Os:       runtime.GOOS,
+			OsVer:    runtime.GOARCH,
+			Client:   "0.1.1",
+			History:  true,
+		},
+		Secret: s.pass,
+	}
+	login := map[string][]interface{}{"emit": {"hello", auth}}
+	if err := websocket.JSON.Send(conn, login); err != nil {
+		return err
+	}
+	// Retrieve the remote ack or connection termination
+	var ack map[string][]string
+	if err := websocket.JSON.Receive(conn, &ack); err != nil || len(ack["emit"]) != 1 || ack["emit"][0] != "ready" {
+		return errors.New("unauthorized")
+	}
+	return nil
+}
+
+// report collects all possible data to report and sends it to the stats server.
+// This should only be used on reconnects or rarely to avoid overloading the
+// server. Use the individual methods for reporting subscribed events.
+func (s *Service) report(conn *websocket.Conn) error {
+	if err := s.reportLatency(conn); err != nil {
+		return err
+	}
+	if err := s.reportBlock(conn, nil); err != nil {
+		return err
+	}
+	if err := s.reportPending(conn); err != nil {
+		return err
+	}
+	return s.reportStats(conn)
+}
+
+// reportLatency sends a ping request to the server, measures the RTT time and
+// finally sends a latency update.
+func (s *Service) reportLatency(conn *websocket.Conn) error {
+	start := time.Now()
+
+	ping := map[string][]interface{}{"emit": {"node-ping", map[string]string{
+		"id":         s.node,
+		"clientTime": start.String(),
+	}}}
+	if err := websocket.JSON.Send(conn, ping); err != nil {
+		return err
+	}
+	select {
+	case <-s.pongCh:
+	case <-time.After(pingTimeout):
+		return errors.New("ping timed out")
+	}
+	latency := strconv.Itoa(int((time.Since(start) / time.Duration(2)).Nanoseconds() / 1000000))
+
+	glog.V(logger.Detail).Infof("Sending measured latency to ethstats: %s ms", latency)
+	stats := map[string][]interface{}{"emit": {"latency", map[string]string{
+		"id":      s.node,
+		"latency": latency,
+	}}}
+	return websocket.JSON.Send(conn, stats)
+}
+
+// blockStats is the information to report about individual blocks.
+type blockStats struct {
+	Number     *big.Int       `json:"number"`
+	Hash       common.Hash    `json:"hash"`
+	ParentHash common.Hash    `json:"parentHash"`
+	Timestamp  *big.Int       `json:"timestamp"`
+	Miner      common.Address `json:"miner"`
+	GasUsed    *big.Int       `json:"gasUsed"`
+	GasLimit   *big.Int       `json:"gasLimit"`
+	Diff       string         `json:"difficulty"`
+	TotalDiff  string         `json:"totalDifficulty"`
+	Txs        []txStats      `json:"transactions"`
+	TxHash     common.Hash    `json:"transactionsRoot"`
+	Root       common.Hash    `json:"stateRoot"`
+	Uncles     uncleStats     `json:"uncles"`
+}
+
+// txStats is the information to report about individual transactions.
+type txStats struct {
+	Hash common.Hash `json:"hash"`
+}
+
+// uncleStats is a custom wrapper around an uncle array to force serializing
+// empty arrays instead of returning null for them.
+type uncleStats []*types.Header
+
+func (s uncleStats) MarshalJSON() ([]byte, error) {
+	if uncles := ([]*types.Header)(s); len(uncles) > 0 {
+		return json.Marshal(uncles)
+	}
+	return []byte("[]"), nil
+}
+
+// reportBlock retrieves the current chain head (or the given block) and reports
+// it to the stats server.
+func (s *Service) reportBlock(conn *websocket.Conn, block *types.Block) error {
+	details := s.assembleBlockStats(block)
+
+	glog.V(logger.Detail).Infof("Sending new block to ethstats: #%v [%x…]", details.Number, details.Hash[:4])
+	stats := map[string]interface{}{
+		"id":    s.node,
+		"block": details,
+	}
+	report := map[string][]interface{}{"emit": {"block", stats}}
+	return websocket.JSON.Send(conn, report)
+}
+
+// assembleBlockStats retrieves any required metadata to report a single block
+// and assembles the block stats. If block is nil, the current head is processed.
+func (s *Service) assembleBlockStats(block *types.Block) *blockStats {
+	chain := s.eth.BlockChain()
+	if block == nil {
+		block = chain.CurrentBlock()
+	}
+	td := chain.GetTd(block.Hash())
+	if td == nil {
+		td = new(big.Int)
+	}
+	txs := make([]txStats, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		txs[i].Hash = tx.Hash()
+	}
+	return &blockStats{
+		Number:     block.Number(),
+		Hash:       block.Hash(),
+		ParentHash: block.ParentHash(),
+		Timestamp:  block.Time(),
+		Miner:      core.BlockAuthor(block.Header()),
+		GasUsed:    block.GasUsed(),
+		GasLimit:   block.GasLimit(),
+		Diff:       block.Difficulty().String(),
+		TotalDiff:  td.String(),
+		Txs:        txs,
+		TxHash:     block.TxHash(),
+		Root:       block.Root(),
+		Uncles:     block.Uncles(),
+	}
+}
+
+// reportHistory retrieves the most recent batch of blocks (or those requested)
+// and reports them to the stats server.
+func (s *Service) reportHistory(conn *websocket.Conn, list []uint64) error {
+	indexes := list
+	if len(indexes) == 0 {
+		head := s.eth.BlockChain().CurrentBlock().NumberU64()
+		start := uint64(0)
+		if head >= historyUpdateRange {
+			start = head - historyUpdateRange + 1
+		}
+		for i := head; ; i-- {
+			indexes = append(indexes, i)
+			if i == start {
+				break
+			}
+		}
+	}
+	history := make([]*blockStats, 0, len(indexes))
+	for _, number := range indexes {
+		block := s.eth.BlockChain().GetBlockByNumber(number)
+		if block == nil {
+			break
+		}
+		history = append(history, s.assembleBlockStats(block))
+	}
+	glog.V(logger.Detail).Infof("Sending %d historical blocks to ethstats", len(history))
+
+	stats := map[string]interface{}{
+		"id":      s.node,
+		"history": history,
+	}
+	report := map[string][]interface{}{"emit": {"history", stats}}
+	return websocket.JSON.Send(conn, report)
+}
+
+// reportPending retrieves the current number of pending transactions and
+// reports it to the stats server.
+func (s *Service) reportPending(conn *websocket.Conn) error {
+	pending, _ := s.eth.TxPool().Stats()
+
+	stats := map[string]interface{}{
+		"id": s.node,
+		"stats": map[string]interface{}{
+			"pending": pending,
+		},
+	}
+	report := map[string][]interface{}{"emit": {"pending", stats}}
+	return websocket.JSON.Send(conn, report)
+}
+
+// nodeStats is the information to report about the local node.
+type nodeStats struct {
+	Active   bool `json:"active"`
+	Syncing  bool `json:"syncing"`
+	Mining   bool `json:"mining"`
+	Hashrate int  `json:"hashrate"`
+	Peers    int  `json:"peers"`
+	GasPrice int  `json:"gasPrice"`
+	Uptime   int  `json:"uptime"`
+}
+
+// reportStats retrieves various stats about the node at the networking layer
+// and reports it to the stats server.
+func (s *Service) reportStats(conn *websocket.Conn) error {
+	stats := map[string]interface{}{
+		"id": s.node,
+		"stats": &nodeStats{
+			Active:   true,
+			Syncing:  s.eth.Downloader().Synchronising(),
+			Peers:    s.server.PeerCount(),
+			GasPrice: int(s.eth.GasPriceOracle().SuggestPrice().Int64()),
+			Uptime:   100,
+		},
+	}
+	report := map[string][]interface{}{"emit": {"stats", stats}}
+	return websocket.JSON.Send(conn, report)
+}
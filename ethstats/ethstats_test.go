@@ -0,0 +1,32 @@
+package ethstats
+
+import "testing"
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url              string
+		name, pass, host string
+		fail             bool
+	}{
+		{url: "mynode:s3cr3t@stats.example.org:3000", name: "mynode", pass: "s3cr3t", host: "stats.example.org:3000"},
+		{url: "mynode@stats.example.org", name: "mynode", host: "stats.example.org"},
+		{url: "stats.example.org:3000", fail: true},
+		{url: "mynode:secret@", fail: true},
+	}
+	for _, tt := range tests {
+		name, pass, host, err := parseURL(tt.url)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%q: expected error", tt.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.url, err)
+			continue
+		}
+		if name != tt.name || pass != tt.pass || host != tt.host {
+			t.Errorf("%q: got (%q, %q, %q), want (%q, %q, %q)", tt.url, name, pass, host, tt.name, tt.pass, tt.host)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Package debug implements the debug API methods for inspecting the Go runtime of
+// a running node: CPU profiling, heap, goroutine, block and mutex profile dumps,
+// garbage collector and memory statistics.
+package debug
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+var (
+	errCPUProfileRunning    = errors.New("CPU profiling already in progress")
+	errCPUProfileNotRunning = errors.New("CPU profiling not in progress")
+)
+
+// Handler is the global debugging handler.
+var Handler = new(HandlerT)
+
+// HandlerT implements the debugging API.
+// Do not create values of this type, use the one
+// in the Handler variable instead.
+type HandlerT struct {
+	mu       sync.Mutex
+	cpuW     io.WriteCloser
+	cpuFile  string
+	cpuStart time.Time
+}
+
+// MemStats returns detailed runtime memory statistics.
+func (*HandlerT) MemStats() *runtime.MemStats {
+	s := new(runtime.MemStats)
+	runtime.ReadMemStats(s)
+	return s
+}
+
+// GcStats returns GC statistics.
+func (*HandlerT) GcStats() *debug.GCStats {
+	s := new(debug.GCStats)
+	debug.ReadGCStats(s)
+	return s
+}
+
+// FreeOSMemory forces a garbage collection and returns as much memory as
+// possible to the operating system.
+func (*HandlerT) FreeOSMemory() {
+	debug.FreeOSMemory()
+}
+
+// SetGCPercent sets the garbage collection target percentage. It returns the
+// previous setting. A negative value disables GC.
+func (*HandlerT) SetGCPercent(v int) int {
+	return debug.SetGCPercent(v)
+}
+
+// CpuProfile turns on CPU profiling for nsec seconds and writes
+// profile data to file.
+func (h *HandlerT) CpuProfile(file string, nsec uint) error {
+	if err := h.StartCPUProfile(file); err != nil {
+		return err
+	}
+	time.Sleep(time.Duration(nsec) * time.Second)
+	h.StopCPUProfile()
+	return nil
+}
+
+// StartCPUProfile turns on CPU profiling, writing to the given file.
+func (h *HandlerT) StartCPUProfile(file string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cpuW != nil {
+		return errCPUProfileRunning
+	}
+	f, err := os.Create(expandHome(file))
+	if err != nil {
+		return err
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	h.cpuW, h.cpuFile, h.cpuStart = f, file, time.Now()
+	glog.V(logger.Info).Infof("CPU profiling started, writing to %s", h.cpuFile)
+	return nil
+}
+
+// StopCPUProfile stops an ongoing CPU profile.
+func (h *HandlerT) StopCPUProfile() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	pprof.StopCPUProfile()
+	if h.cpuW == nil {
+		return errCPUProfileNotRunning
+	}
+	glog.V(logger.Info).Infof("CPU profiling stopped after %v, wrote %s", time.Since(h.cpuStart), h.cpuFile)
+	err := h.cpuW.Close()
+	h.cpuW, h.cpuFile = nil, ""
+	return err
+}
+
+// BlockProfile turns on goroutine blocking profiling for nsec seconds and writes
+// profile data to file. It uses a profile rate of 1 for most accurate
+// information. If a different rate is desired, set the rate and write the
+// profile manually.
+func (h *HandlerT) BlockProfile(file string, nsec uint) error {
+	h.SetBlockProfileRate(1)
+	time.Sleep(time.Duration(nsec) * time.Second)
+	defer h.SetBlockProfileRate(0)
+	return writeProfile("block", file)
+}
+
+// SetBlockProfileRate sets the rate of goroutine block profile data collection.
+// rate 0 disables block profiling.
+func (*HandlerT) SetBlockProfileRate(rate int) {
+	runtime.SetBlockProfileRate(rate)
+}
+
+// WriteBlockProfile writes a goroutine blocking profile to the given file.
+func (*HandlerT) WriteBlockProfile(file string) error {
+	return writeProfile("block", file)
+}
+
+// MutexProfile turns on mutex profiling for nsec seconds and writes profile
+// data to file. It uses a profile fraction of 1 for most accurate information.
+func (h *HandlerT) MutexProfile(file string, nsec uint) error {
+	h.SetMutexProfileFraction(1)
+	time.Sleep(time.Duration(nsec) * time.Second)
+	defer h.SetMutexProfileFraction(0)
+	return writeProfile("mutex", file)
+}
+
+// SetMutexProfileFraction sets the rate of mutex contention profile data
+// collection. rate 0 disables mutex profiling.
+func (*HandlerT) SetMutexProfileFraction(rate int) {
+	runtime.SetMutexProfileFraction(rate)
+}
+
+// WriteMutexProfile writes a mutex contention profile to the given file.
+func (*HandlerT) WriteMutexProfile(file string) error {
+	return writeProfile("mutex", file)
+}
+
+// WriteMemProfile writes an allocation profile to the given file.
+// Note that the profiling rate cannot be set through the API,
+// it must be set on the command line.
+func (*HandlerT) WriteMemProfile(file string) error {
+	return writeProfile("heap", file)
+}
+
+// WriteGoroutineProfile writes a goroutine profile to the given file.
+func (*HandlerT) WriteGoroutineProfile(file string) error {
+	return writeProfile("goroutine", file)
+}
+
+// Stacks returns a printed representation of the stacks of all goroutines.
+func (*HandlerT) Stacks() string {
+	buf := new(bytes.Buffer)
+	pprof.Lookup("goroutine").WriteTo(buf, 2)
+	return buf.String()
+}
+
+func writeProfile(name, file string) error {
+	p := pprof.Lookup(name)
+	glog.V(logger.Info).Infof("writing %d %s profile records to %s", p.Count(), name, file)
+	f, err := os.Create(expandHome(file))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.WriteTo(f, 0)
+}
+
+// expandHome expands home directory in file paths.
+// ~someuser/tmp will not be expanded.
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~/") || strings.HasPrefix(p, "~\\") {
+		home := os.Getenv("HOME")
+		if home == "" {
+			if usr, err := user.Current(); err == nil {
+				home = usr.HomeDir
+			}
+		}
+		if home != "" {
+			p = home + p[1:]
+		}
+	}
+	return filepath.Clean(p)
+}
@@ -0,0 +1,63 @@
+package debug
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCPUProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "debug-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := new(HandlerT)
+	if err := h.StopCPUProfile(); err != errCPUProfileNotRunning {
+		t.Errorf("stopping idle profile: got %v, want %v", err, errCPUProfileNotRunning)
+	}
+	file := filepath.Join(dir, "cpu.prof")
+	if err := h.StartCPUProfile(file); err != nil {
+		t.Fatalf("failed to start CPU profile: %v", err)
+	}
+	if err := h.StartCPUProfile(file); err != errCPUProfileRunning {
+		t.Errorf("starting second profile: got %v, want %v", err, errCPUProfileRunning)
+	}
+	if err := h.StopCPUProfile(); err != nil {
+		t.Fatalf("failed to stop CPU profile: %v", err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Size() == 0 {
+		t.Errorf("CPU profile not written: %v", err)
+	}
+}
+
+func TestWriteProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "debug-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writers := map[string]func(string) error{
+		"heap":      Handler.WriteMemProfile,
+		"block":     Handler.WriteBlockProfile,
+		"mutex":     Handler.WriteMutexProfile,
+		"goroutine": Handler.WriteGoroutineProfile,
+	}
+	for name, write := range writers {
+		file := filepath.Join(dir, name+".prof")
+		if err := write(file); err != nil {
+			t.Errorf("%s: failed to write profile: %v", name, err)
+			continue
+		}
+		if info, err := os.Stat(file); err != nil || info.Size() == 0 {
+			t.Errorf("%s: profile not written: %v", name, err)
+		}
+	}
+	if stacks := Handler.Stacks(); !strings.Contains(stacks, "TestWriteProfiles") {
+		t.Errorf("goroutine stacks don't contain the running test:\n%s", stacks)
+	}
+}
@@ -3953,6 +3953,9 @@ var inputAddressFormatter = function (address) {
         return address;
     } else if (utils.isAddress(address)) {
         return '0x' + address;
+    } else if (/^([a-z0-9-]+\.)+[a-z0-9-]+$/i.test(address)) {
+        // ENS name, resolved by the node
+        return address;
     }
     throw new Error('invalid address');
 };
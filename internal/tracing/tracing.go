@@ -0,0 +1,391 @@
+// Package tracing emits OpenTelemetry compatible spans around the RPC request
+// handling, block import and sync pipelines, exporting them in batches to an
+// OTLP/HTTP collector using the JSON encoding.
+//
+// Tracing is disabled until Enable is called, in which case starting a span
+// returns nil and all span methods are no-ops, keeping the overhead on the hot
+// paths negligible.
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+const (
+	exportBatchSize = 512             // Maximum number of spans exported in a single request
+	exportInterval  = 5 * time.Second // Maximum time spans are held before exporting
+	exportTimeout   = 10 * time.Second
+	queueSize       = 4096 // Finished spans waiting for export, beyond which spans are dropped
+)
+
+// Span kinds, as defined by the OTLP protocol.
+const (
+	kindInternal = 1
+	kindServer   = 2
+)
+
+// Config configures the exporting of spans.
+type Config struct {
+	Endpoint    string  // OTLP/HTTP traces endpoint, e.g. http://localhost:4318/v1/traces
+	ServiceName string  // Name of the service reported with the spans
+	SampleRate  float64 // Fraction of traces recorded, in [0, 1]
+}
+
+// tracer is the currently enabled exporter, nil if tracing is disabled.
+var tracer atomic.Value
+
+// Tracer batches finished spans and exports them to the collector.
+type Tracer struct {
+	config Config
+	client *http.Client
+
+	queue   chan *Span
+	dropped uint64 // Spans dropped due to a full queue, accessed atomically
+	quit    chan chan struct{}
+}
+
+// Enable starts exporting the spans to the collector configured.
+func Enable(config Config) (*Tracer, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("no OTLP endpoint configured")
+	}
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("sample rate %v out of range [0, 1]", config.SampleRate)
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = "ethcore"
+	}
+	t := &Tracer{
+		config: config,
+		client: &http.Client{Timeout: exportTimeout},
+		queue:  make(chan *Span, queueSize),
+		quit:   make(chan chan struct{}),
+	}
+	go t.loop()
+	tracer.Store(t)
+
+	glog.V(logger.Info).Infof("Exporting traces to %s (sample rate %v)", config.Endpoint, config.SampleRate)
+	return t, nil
+}
+
+// Stop disables tracing, flushing the spans still queued.
+func (t *Tracer) Stop() {
+	tracer.Store((*Tracer)(nil))
+
+	done := make(chan struct{})
+	t.quit <- done
+	<-done
+}
+
+func current() *Tracer {
+	t, _ := tracer.Load().(*Tracer)
+	return t
+}
+
+// Span is a timed operation within a trace. A nil span is valid and ignores all
+// calls, which is what Start returns if tracing is disabled or the trace isn't
+// sampled.
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+
+	lock  sync.Mutex
+	end   time.Time
+	attrs []attribute
+	err   string
+	ended bool
+	keys  []interface{} // Keys the span is bound to until it ends
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// Start begins the root span of a new trace.
+func Start(name string) *Span {
+	return start(name, kindInternal)
+}
+
+// StartServer begins the root span of a new trace handling a remote request.
+func StartServer(name string) *Span {
+	return start(name, kindServer)
+}
+
+func start(name string, kind int) *Span {
+	t := current()
+	if t == nil || (t.config.SampleRate < 1 && mrand.Float64() >= t.config.SampleRate) {
+		return nil
+	}
+	span := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	rand.Read(span.traceID[:])
+	rand.Read(span.spanID[:])
+	return span
+}
+
+// bound maps the keys of the operations in progress to their span, see Bind.
+var (
+	boundLock sync.Mutex
+	bound     = make(map[interface{}]*Span)
+)
+
+// StartFor begins a span nested in the one bound to key, or the root span of a
+// new trace if none is. It lets the stages of an operation, started deep in the
+// stack, nest in the span of the operation, e.g. by the hash of a block import.
+func StartFor(key interface{}, name string) *Span {
+	if current() == nil {
+		return nil
+	}
+	boundLock.Lock()
+	parent := bound[key]
+	boundLock.Unlock()
+
+	if parent != nil {
+		return parent.Child(name)
+	}
+	return Start(name)
+}
+
+// Bind makes s the parent of the spans started for any of the given keys until
+// it ends.
+func (s *Span) Bind(keys ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.keys = append(s.keys, keys...)
+	s.lock.Unlock()
+
+	boundLock.Lock()
+	for _, key := range keys {
+		bound[key] = s
+	}
+	boundLock.Unlock()
+}
+
+// Child begins a span nested in s.
+func (s *Span) Child(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	span := &Span{tracer: s.tracer, traceID: s.traceID, parentID: s.spanID, name: name, kind: kindInternal, start: time.Now()}
+	rand.Read(span.spanID[:])
+	return span
+}
+
+// SetAttribute attaches a key/value pair to the span. Values of types other than
+// strings, booleans, integers and floats are recorded in their string form.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.attrs = append(s.attrs, attribute{key, value})
+	s.lock.Unlock()
+}
+
+// End finishes the span, marking it failed if err is not nil, and queues it for
+// export. Calls after the first are ignored.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended, s.end = true, time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+	keys := s.keys
+	s.lock.Unlock()
+
+	if len(keys) > 0 {
+		boundLock.Lock()
+		for _, key := range keys {
+			if bound[key] == s {
+				delete(bound, key)
+			}
+		}
+		boundLock.Unlock()
+	}
+
+	select {
+	case s.tracer.queue <- s:
+	default:
+		atomic.AddUint64(&s.tracer.dropped, 1)
+	}
+}
+
+// loop exports the finished spans in batches until stopped.
+func (t *Tracer) loop() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.export(batch); err != nil {
+			glog.V(logger.Debug).Warnf("Failed to export %d spans: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case span := <-t.queue:
+			if batch = append(batch, span); len(batch) >= exportBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+			if dropped := atomic.SwapUint64(&t.dropped, 0); dropped > 0 {
+				glog.V(logger.Debug).Warnf("Dropped %d spans, export queue full", dropped)
+			}
+		case done := <-t.quit:
+			for len(t.queue) > 0 {
+				batch = append(batch, <-t.queue)
+			}
+			flush()
+			close(done)
+			return
+		}
+	}
+}
+
+// export posts a batch of spans to the collector.
+func (t *Tracer) export(spans []*Span) error {
+	body, err := json.Marshal(t.encode(spans))
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Post(t.config.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP/JSON encoding of the exported spans.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            *otlpStatus     `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"` // 2 is STATUS_CODE_ERROR
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+)
+
+func (t *Tracer) encode(spans []*Span) *otlpRequest {
+	encoded := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		s.lock.Lock()
+		encoded[i] = otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parentID != ([8]byte{}) {
+			encoded[i].ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		for _, attr := range s.attrs {
+			encoded[i].Attributes = append(encoded[i].Attributes, encodeAttribute(attr.key, attr.value))
+		}
+		if s.err != "" {
+			encoded[i].Status = &otlpStatus{Code: 2, Message: s.err}
+		}
+		s.lock.Unlock()
+	}
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			encodeAttribute("service.name", t.config.ServiceName),
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/openether/ethcore"},
+			Spans: encoded,
+		}},
+	}}}
+}
+
+func encodeAttribute(key string, value interface{}) otlpAttribute {
+	var v map[string]interface{}
+	switch value := value.(type) {
+	case string:
+		v = map[string]interface{}{"stringValue": value}
+	case bool:
+		v = map[string]interface{}{"boolValue": value}
+	case int:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(int64(value), 10)}
+	case int64:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+	case uint64:
+		v = map[string]interface{}{"intValue": strconv.FormatUint(value, 10)}
+	case float64:
+		v = map[string]interface{}{"doubleValue": value}
+	case *big.Int:
+		v = map[string]interface{}{"stringValue": value.String()}
+	default:
+		v = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+	}
+	return otlpAttribute{Key: key, Value: v}
+}
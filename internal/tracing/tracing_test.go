@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDisabled(t *testing.T) {
+	span := Start("disabled")
+	if span != nil {
+		t.Fatalf("span started while tracing disabled")
+	}
+	// Nil spans must be safe to use
+	span.SetAttribute("key", "value")
+	span.Child("child").End(nil)
+	span.Bind("key")
+	span.End(errors.New("failure"))
+
+	if span := StartFor("key", "disabled"); span != nil {
+		t.Fatalf("span started for key while tracing disabled")
+	}
+}
+
+func TestExport(t *testing.T) {
+	requests := make(chan otlpRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var req otlpRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("invalid export payload: %v", err)
+		}
+		requests <- req
+	}))
+	defer server.Close()
+
+	tr, err := Enable(Config{Endpoint: server.URL, ServiceName: "test", SampleRate: 1})
+	if err != nil {
+		t.Fatalf("failed to enable tracing: %v", err)
+	}
+	root := StartServer("eth_call")
+	root.SetAttribute("number", uint64(42))
+	child := root.Child("process")
+	child.End(errors.New("failure"))
+	root.End(nil)
+	tr.Stop()
+
+	if span := Start("stopped"); span != nil {
+		t.Fatalf("span started after tracing stopped")
+	}
+	req := <-requests
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected payload layout: %+v", req)
+	}
+	if attrs := req.ResourceSpans[0].Resource.Attributes; len(attrs) != 1 || attrs[0].Key != "service.name" || attrs[0].Value["stringValue"] != "test" {
+		t.Errorf("service name mismatch: %+v", attrs)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("span count mismatch: have %d, want 2", len(spans))
+	}
+	c, r := spans[0], spans[1]
+	if r.Name != "eth_call" || r.Kind != kindServer || r.ParentSpanID != "" || r.Status != nil {
+		t.Errorf("root span mismatch: %+v", r)
+	}
+	if len(r.Attributes) != 1 || r.Attributes[0].Value["intValue"] != "42" {
+		t.Errorf("root attributes mismatch: %+v", r.Attributes)
+	}
+	if c.TraceID != r.TraceID || c.ParentSpanID != r.SpanID {
+		t.Errorf("child not linked to root: %+v", c)
+	}
+	if c.Status == nil || c.Status.Code != 2 || c.Status.Message != "failure" {
+		t.Errorf("child status mismatch: %+v", c.Status)
+	}
+}
+
+// Tests that spans started for a key nest in the span bound to it while it runs.
+func TestBind(t *testing.T) {
+	requests := make(chan otlpRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var req otlpRequest
+		json.Unmarshal(body, &req)
+		requests <- req
+	}))
+	defer server.Close()
+
+	tr, err := Enable(Config{Endpoint: server.URL, SampleRate: 1})
+	if err != nil {
+		t.Fatalf("failed to enable tracing: %v", err)
+	}
+	parent := Start("import")
+	parent.Bind("block1", "block2")
+	StartFor("block2", "write").End(nil)
+	StartFor("block3", "unbound").End(nil)
+	parent.End(nil)
+	StartFor("block1", "ended").End(nil)
+	tr.Stop()
+
+	spans := make(map[string]otlpSpan)
+	for _, span := range (<-requests).ResourceSpans[0].ScopeSpans[0].Spans {
+		spans[span.Name] = span
+	}
+	if len(spans) != 4 {
+		t.Fatalf("span count mismatch: have %d, want 4", len(spans))
+	}
+	if s := spans["write"]; s.TraceID != spans["import"].TraceID || s.ParentSpanID != spans["import"].SpanID {
+		t.Errorf("span of bound key not nested: %+v", s)
+	}
+	for _, name := range []string{"unbound", "ended"} {
+		if s := spans[name]; s.ParentSpanID != "" || s.TraceID == spans["import"].TraceID {
+			t.Errorf("span %q nested in an unrelated span: %+v", name, s)
+		}
+	}
+	if len(bound) != 0 {
+		t.Errorf("keys still bound after the span ended: %v", bound)
+	}
+}
+
+func TestEnableInvalid(t *testing.T) {
+	if _, err := Enable(Config{}); err == nil {
+		t.Errorf("enabled without endpoint")
+	}
+	if _, err := Enable(Config{Endpoint: "http://localhost", SampleRate: 2}); err == nil {
+		t.Errorf("enabled with sample rate out of range")
+	}
+}
@@ -19,6 +19,7 @@ package web3ext
 
 var Modules = map[string]string{
 	"admin":    Admin_JS,
+	"dapp":     Dapp_JS,
 	"debug":    Debug_JS,
 	"eth":      Eth_JS,
 	"miner":    Miner_JS,
@@ -26,6 +27,8 @@ var Modules = map[string]string{
 	"personal": Personal_JS,
 	"rpc":      RPC_JS,
 	"shh":      Shh_JS,
+	"token":    Token_JS,
+	"trace":    Trace_JS,
 	"txpool":   TxPool_JS,
 	"geth":     Geth_JS,
 }
@@ -40,6 +43,27 @@ web3._extend({
 			call: 'admin_addPeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'exportNodeKey',
+			call: 'admin_exportNodeKey',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'importNodeKey',
+			call: 'admin_importNodeKey',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'rotateNodeKey',
+			call: 'admin_rotateNodeKey',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'exportGenesis',
+			call: 'admin_exportGenesis',
+			params: 1,
+			inputFormatter: [null]
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -56,11 +80,48 @@ web3._extend({
 			call: 'admin_sleepBlocks',
 			params: 2
 		}),
+		new web3._extend.Method({
+			name: 'freezeImport',
+			call: 'admin_freezeImport',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'thawImport',
+			call: 'admin_thawImport',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'doctor',
+			call: 'admin_doctor',
+			params: 0
+		}),
 		new web3._extend.Method({
 			name: 'setSolc',
 			call: 'admin_setSolc',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'setGpoMinGasPrice',
+			call: 'admin_setGpoMinGasPrice',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'setVyper',
+			call: 'admin_setVyper',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setDappQuota',
+			call: 'admin_setDappQuota',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'compilers',
+			call: 'admin_compilers',
+			params: 0
+		}),
 		new web3._extend.Method({
 			name: 'startRPC',
 			call: 'admin_startRPC',
@@ -111,6 +172,32 @@ web3._extend({
 			call: 'admin_registerUrl',
 			params: 3
 		}),
+		new web3._extend.Method({
+			name: 'resolveName',
+			call: 'admin_resolveName',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'reverseResolve',
+			call: 'admin_reverseResolve',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'resolveNames',
+			call: 'admin_resolveNames',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'reverseResolveAddresses',
+			call: 'admin_reverseResolveAddresses',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'resolveENS',
+			call: 'admin_resolveENS',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'startNatSpec',
 			call: 'admin_startNatSpec',
@@ -130,6 +217,10 @@ web3._extend({
 			name: 'httpGet',
 			call: 'admin_httpGet',
 			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'remapNAT',
+			call: 'admin_remapNAT'
 		})
 	],
 	properties:
@@ -142,9 +233,33 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'peerStats',
+			getter: 'admin_peerStats'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
+		}),
+		new web3._extend.Property({
+			name: 'natStatus',
+			getter: 'admin_natStatus'
+		}),
+		new web3._extend.Property({
+			name: 'dialHistory',
+			getter: 'admin_dialHistory'
+		}),
+		new web3._extend.Property({
+			name: 'catalog',
+			getter: 'admin_catalog'
+		}),
+		new web3._extend.Property({
+			name: 'importFreezeStatus',
+			getter: 'admin_importFreezeStatus'
+		}),
+		new web3._extend.Property({
+			name: 'filters',
+			getter: 'admin_filters'
 		})
 	]
 });
@@ -167,6 +282,24 @@ web3._extend({
 			params: 8,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputDefaultBlockNumberFormatter, null, null, null, null, null]
 		}),
+		new web3._extend.Method({
+			name: 'getContractCreation',
+			call: 'geth_getContractCreation',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getInternalTransactions',
+			call: 'geth_getInternalTransactions',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputDefaultBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'getLogCount',
+			call: 'geth_getLogCount',
+			params: 4,
+			inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'buildATXI',
 			call: 'geth_buildATXI',
@@ -177,6 +310,67 @@ web3._extend({
 			name: 'getATXIBuildStatus',
 			call: 'geth_getATXIBuildStatus',
 			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'verifyContract',
+			call: 'geth_verifyContract',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getContractVerification',
+			call: 'geth_getContractVerification',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		})
+	],
+	properties: []
+});
+`
+
+const Dapp_JS = `
+web3._extend({
+	property: 'dapp',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'get',
+			call: 'dapp_get',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'getString',
+			call: 'dapp_getString',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'put',
+			call: 'dapp_put',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'putString',
+			call: 'dapp_putString',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'delete',
+			call: 'dapp_delete',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'keys',
+			call: 'dapp_keys',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'clear',
+			call: 'dapp_clear',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'usage',
+			call: 'dapp_usage',
+			params: 1
 		})
 	],
 	properties: []
@@ -188,6 +382,17 @@ web3._extend({
 	property: 'debug',
 	methods:
 	[
+		new web3._extend.Method({
+			name: 'cacheStats',
+			call: 'debug_cacheStats',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'databaseUsage',
+			call: 'debug_databaseUsage',
+			params: 1,
+			inputFormatter: [null]
+		}),
 		new web3._extend.Method({
 			name: 'printBlock',
 			call: 'debug_printBlock',
@@ -234,6 +439,121 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'traceTransaction',
 			call: 'debug_traceTransaction',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'traceBlockByNumber',
+			call: 'debug_traceBlockByNumber',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'traceBlockByHash',
+			call: 'debug_traceBlockByHash',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'blockStats',
+			call: 'debug_blockStats',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'chainHeads',
+			call: 'debug_chainHeads',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'intermediateRoots',
+			call: 'debug_intermediateRoots',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'standardTraceBlockToFile',
+			call: 'debug_standardTraceBlockToFile',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'cpuProfile',
+			call: 'debug_cpuProfile',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'startCPUProfile',
+			call: 'debug_startCPUProfile',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'stopCPUProfile',
+			call: 'debug_stopCPUProfile',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'blockProfile',
+			call: 'debug_blockProfile',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'setBlockProfileRate',
+			call: 'debug_setBlockProfileRate',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'writeBlockProfile',
+			call: 'debug_writeBlockProfile',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'mutexProfile',
+			call: 'debug_mutexProfile',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'setMutexProfileFraction',
+			call: 'debug_setMutexProfileFraction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'writeMutexProfile',
+			call: 'debug_writeMutexProfile',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'writeMemProfile',
+			call: 'debug_writeMemProfile',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'writeGoroutineProfile',
+			call: 'debug_writeGoroutineProfile',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'stacks',
+			call: 'debug_stacks',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'memStats',
+			call: 'debug_memStats',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'gcStats',
+			call: 'debug_gcStats',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'freeOSMemory',
+			call: 'debug_freeOSMemory',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'setGCPercent',
+			call: 'debug_setGCPercent',
 			params: 1
 		}),
 		new web3._extend.Method({
@@ -269,6 +589,16 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'compileVyper',
+			call: 'eth_compileVyper',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'compileSolidityStandard',
+			call: 'eth_compileSolidityStandard',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'signTransaction',
 			call: 'eth_signTransaction',
@@ -285,6 +615,58 @@ web3._extend({
 			name: 'chainId',
 			call: 'eth_chainId',
 			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getTransactionReceipts',
+			call: 'eth_getTransactionReceipts',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'callMany',
+			call: 'eth_callMany',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'multicall',
+			call: 'eth_multicall',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'simulateBundle',
+			call: 'eth_simulateBundle',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'createAccessList',
+			call: 'eth_createAccessList',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getAccountHistory',
+			call: 'eth_getAccountHistory',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getStorageAtMany',
+			call: 'eth_getStorageAtMany',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getProof',
+			call: 'eth_getProof',
+			params: 3,
+			inputFormatter: [null, null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getPendingTransactions',
+			call: 'eth_pendingTransactions',
+			params: 1,
+			inputFormatter: [null]
 		})
 	],
 	properties:
@@ -433,10 +815,76 @@ web3._extend({
 });
 `
 
+const Token_JS = `
+web3._extend({
+	property: 'token',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'info',
+			call: 'token_info',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'balanceOf',
+			call: 'token_balanceOf',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'balances',
+			call: 'token_balances',
+			params: 3,
+			inputFormatter: [null, null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		})
+	]
+});
+`
+
+const Trace_JS = `
+web3._extend({
+	property: 'trace',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'block',
+			call: 'trace_block',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'transaction',
+			call: 'trace_transaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'filter',
+			call: 'trace_filter',
+			params: 1
+		})
+	]
+});
+`
+
 const TxPool_JS = `
 web3._extend({
 	property: 'txpool',
-	methods: [],
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'pendingTransactions',
+			call: 'txpool_pendingTransactions',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'setMinGasPrice',
+			call: 'txpool_setMinGasPrice',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		})
+	],
 	properties:
 	[
 		new web3._extend.Property({
@@ -447,6 +895,15 @@ web3._extend({
 			name: 'inspect',
 			getter: 'txpool_inspect'
 		}),
+		new web3._extend.Property({
+			name: 'nonceGaps',
+			getter: 'txpool_nonceGaps'
+		}),
+		new web3._extend.Property({
+			name: 'minGasPrice',
+			getter: 'txpool_minGasPrice',
+			outputFormatter: web3._extend.utils.toBigNumber
+		}),
 		new web3._extend.Property({
 			name: 'status',
 			getter: 'txpool_status',
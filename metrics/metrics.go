@@ -96,11 +96,53 @@ var (
 	FetchBroadcastDOS   = metrics.NewRegisteredMeter("fetch/broadcast/dos", reg)
 )
 
+var (
+	ChainHeadAge = metrics.GetOrRegisterGauge("chain/head/age", reg) // Seconds since the timestamp of the head block
+)
+
+var (
+	TxGossipRateDrops  = metrics.NewRegisteredMeter("txpool/gossip/drop/rate", reg)
+	TxGossipRelayDrops = metrics.NewRegisteredMeter("txpool/gossip/drop/relayed", reg)
+)
+
+var (
+	ServeThrottledHeaders  = metrics.NewRegisteredMeter("serve/throttled/header", reg)
+	ServeThrottledBodies   = metrics.NewRegisteredMeter("serve/throttled/body", reg)
+	ServeThrottledReceipts = metrics.NewRegisteredMeter("serve/throttled/receipt", reg)
+	ServeThrottledNodeData = metrics.NewRegisteredMeter("serve/throttled/state", reg)
+
+	ServeNodeDataCacheHits   = metrics.NewRegisteredMeter("serve/state/cache/hit", reg)
+	ServeNodeDataCacheMisses = metrics.NewRegisteredMeter("serve/state/cache/miss", reg)
+)
+
+var (
+	DBCompactionDebt     = metrics.GetOrRegisterGauge("db/compaction/debt", reg) // Level-0 tables awaiting compaction
+	DBCompactionDeferred = metrics.NewRegisteredMeter("db/compaction/deferred", reg)
+	DBCompactionAborts   = metrics.NewRegisteredMeter("db/compaction/abort", reg)
+	DBCompactionTimer    = metrics.NewRegisteredTimer("db/compaction", reg)
+)
+
+var (
+	RPCNotificationDrops       = metrics.NewRegisteredMeter("rpc/notification/drop", reg)
+	RPCSlowConsumerDisconnects = metrics.NewRegisteredMeter("rpc/notification/disconnect", reg)
+	RPCBatchRefusals           = metrics.NewRegisteredMeter("rpc/batch/refuse", reg)   // Batches with too many requests
+	RPCBatchTruncations        = metrics.NewRegisteredMeter("rpc/batch/truncate", reg) // Batches exceeding the response size limit
+	RPCBatchTimeouts           = metrics.NewRegisteredMeter("rpc/batch/timeout", reg)  // Batches exceeding the execution time limit
+)
+
 var (
 	P2PIn       = metrics.NewRegisteredMeter("p2p/in", reg)
 	P2PInBytes  = metrics.NewRegisteredMeter("p2p/in/bytes", reg)
 	P2POut      = metrics.NewRegisteredMeter("p2p/out", reg)
 	P2POutBytes = metrics.NewRegisteredMeter("p2p/out/bytes", reg)
+
+	P2PHandshakeTimeouts = metrics.NewRegisteredMeter("p2p/handshake/timeouts", reg)
+	P2PMalformedMsgs     = metrics.NewRegisteredMeter("p2p/in/malformed", reg)
+	P2POversizedMsgs     = metrics.NewRegisteredMeter("p2p/in/oversized", reg)
+
+	DiscBadPackets      = metrics.NewRegisteredMeter("p2p/discover/in/bad", reg)
+	DiscReplayedPackets = metrics.NewRegisteredMeter("p2p/discover/in/replayed", reg)
+	DiscSigCacheHits    = metrics.NewRegisteredMeter("p2p/discover/in/sigcache/hits", reg)
 )
 
 var (
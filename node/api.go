@@ -1,6 +1,8 @@
 package node
 
 import (
+	"crypto/ecdsa"
+	"encoding/hex"
 	"fmt"
 	"strings"
 
@@ -8,6 +10,7 @@ import (
 	"github.com/openether/ethcore/crypto"
 	"github.com/openether/ethcore/p2p"
 	"github.com/openether/ethcore/p2p/discover"
+	"github.com/openether/ethcore/p2p/nat"
 	"github.com/openether/ethcore/rpc"
 )
 
@@ -40,6 +43,69 @@ func (api *PrivateAdminAPI) AddPeer(url string) (bool, error) {
 	return true, nil
 }
 
+// RemapNAT forces the NAT port mappings of the p2p server to be re-added, e.g.
+// after the gateway was rebooted and lost its mapping table.
+func (api *PrivateAdminAPI) RemapNAT() (bool, error) {
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	if err := server.RemapNAT(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NodeKeyInfo describes the identity of the node after its key was replaced.
+type NodeKeyInfo struct {
+	Enode   string `json:"enode"`   // Node URL under the new key
+	KeyFile string `json:"keyFile"` // File the key was stored in, empty if the key was given on the command line or there is no data directory
+}
+
+// ExportNodeKey returns the hex encoded private key identifying the node on the
+// network, e.g. to move the identity to another machine.
+func (api *PrivateAdminAPI) ExportNodeKey() (string, error) {
+	key := api.node.NodeKey()
+	if key == nil {
+		return "", ErrNodeStopped
+	}
+	return hex.EncodeToString(crypto.FromECDSA(key)), nil
+}
+
+// ImportNodeKey replaces the key identifying the node on the network with the
+// given hex encoded private key, as exported by ExportNodeKey. Peers are
+// disconnected to handshake again under the new identity.
+func (api *PrivateAdminAPI) ImportNodeKey(hexkey string) (*NodeKeyInfo, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(hexkey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid node key: %v", err)
+	}
+	return api.setNodeKey(key)
+}
+
+// RotateNodeKey replaces the key identifying the node on the network with a
+// newly generated one. Peers are disconnected to handshake again under the new
+// identity; nodes knowing the old identity can't dial the node anymore.
+func (api *PrivateAdminAPI) RotateNodeKey() (*NodeKeyInfo, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return api.setNodeKey(key)
+}
+
+func (api *PrivateAdminAPI) setNodeKey(key *ecdsa.PrivateKey) (*NodeKeyInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	keyfile, err := api.node.SetNodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &NodeKeyInfo{Enode: server.NodeInfo().Enode, KeyFile: keyfile}, nil
+}
+
 // StartRPC starts the HTTP RPC API server.
 func (api *PrivateAdminAPI) StartRPC(host *string, port *rpc.HexNumber, cors *string, apis *string) (bool, error) {
 	api.node.lock.Lock()
@@ -160,6 +226,27 @@ func (api *PublicAdminAPI) Peers() ([]*p2p.PeerInfo, error) {
 	return server.PeersInfo(), nil
 }
 
+// PeerStats aggregates the connected peers by client software, version,
+// platform and, if a GeoIP database is configured, country.
+func (api *PublicAdminAPI) PeerStats() (*p2p.PeerStats, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.PeerStats(), nil
+}
+
+// DialHistory retrieves the most recent attempts of the node to connect to
+// other nodes, most recent first, with their outcome and the error that failed
+// them.
+func (api *PublicAdminAPI) DialHistory() ([]p2p.DialAttempt, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.DialHistory(), nil
+}
+
 // NodeInfo retrieves all the information we know about the host node at the
 // protocol granularity.
 func (api *PublicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
@@ -170,6 +257,30 @@ func (api *PublicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
 	return server.NodeInfo(), nil
 }
 
+// NatStatus retrieves the state of the NAT port mappings, including the
+// external IP and ports currently advertised to the network.
+func (api *PublicAdminAPI) NatStatus() ([]nat.MappingStatus, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.NATStatus(), nil
+}
+
+// Catalog retrieves the methods and subscriptions of all APIs of the node by
+// namespace, with the schemas of their parameters and results, so that tools
+// can discover what the node offers. Which of the namespaces an endpoint serves
+// is up to its configuration.
+func (api *PublicAdminAPI) Catalog() (map[string][]rpc.MethodInfo, error) {
+	api.node.lock.RLock()
+	defer api.node.lock.RUnlock()
+
+	if api.node.inprocHandler == nil {
+		return nil, ErrNodeStopped
+	}
+	return api.node.inprocHandler.Methods(), nil
+}
+
 // Datadir retrieves the current data directory the node is using.
 func (api *PublicAdminAPI) Datadir() string {
 	return api.node.DataDir()
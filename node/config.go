@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/spf13/afero"
 
@@ -17,7 +18,9 @@ import (
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
 	"github.com/openether/ethcore/p2p/discover"
+	"github.com/openether/ethcore/p2p/distip"
 	"github.com/openether/ethcore/p2p/nat"
+	"github.com/openether/ethcore/rpc"
 )
 
 var (
@@ -87,6 +90,30 @@ type Config struct {
 	// If NoDial is true, the node will not dial any peers.
 	NoDial bool
 
+	// IPMode selects the address families used for listening and dialing peers,
+	// e.g. to prefer or require IPv6.
+	IPMode distip.IPMode
+
+	// ClockCheckInterval is the interval at which the local clock is checked for
+	// drift against an NTP server. Zero disables the periodic checks.
+	ClockCheckInterval time.Duration
+
+	// ClockDriftThreshold is the clock drift beyond which a warning is logged and
+	// reported in the node info. Zero selects the default threshold.
+	ClockDriftThreshold time.Duration
+
+	// GeoIPDatabase is the path of an offline MaxMind country or city database
+	// used to tag peers with their country. Empty disables geo tagging.
+	GeoIPDatabase string
+
+	// HandshakeTimeout is the time allowed for the handshakes of a peer
+	// connection. Zero selects the default.
+	HandshakeTimeout time.Duration
+
+	// FrameReadTimeout is the time a peer connection may take to deliver a
+	// complete message, and thus stay idle. Zero selects the default.
+	FrameReadTimeout time.Duration
+
 	// MaxPeers is the maximum number of peers that can be connected. If this is
 	// set to zero, then only the configured static and trusted peers can connect.
 	MaxPeers int
@@ -133,6 +160,28 @@ type Config struct {
 	// If the module list is empty, all RPC API endpoints designated public will be
 	// exposed.
 	WSModules []string
+
+	// SubscriptionBuffer limits the number of notifications buffered for a single
+	// subscription of an IPC, in-process or websocket client. Zero leaves only the
+	// connection wide limit in place.
+	SubscriptionBuffer int
+
+	// SubscriptionPolicy determines what happens to a subscription whose client
+	// falls behind by more than SubscriptionBuffer notifications.
+	SubscriptionPolicy rpc.SlowConsumerPolicy
+
+	// RPCTimeouts limits the execution time of RPC methods on all endpoints.
+	// Methods without a limit run until they complete or their client disconnects.
+	RPCTimeouts rpc.MethodTimeouts
+
+	// RPCBatchLimits bounds the number of requests, response size and execution
+	// time of batch requests on all endpoints.
+	RPCBatchLimits rpc.BatchLimits
+
+	// MemoryLimit is the heap size in bytes the node should stay within. Beyond
+	// fractions of it, caches are dropped, heavy RPC requests refused with a
+	// retryable error and transactions from peers throttled. Zero disables it.
+	MemoryLimit uint64
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
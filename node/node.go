@@ -1,18 +1,25 @@
 package node
 
 import (
+	"crypto/ecdsa"
 	"errors"
+	"fmt"
 	"github.com/spf13/afero"
 	"net"
+	"os"
 	"path/filepath"
 	"reflect"
 	"sync"
 	"syscall"
 
+	"github.com/openether/ethcore/common/memwatch"
+	"github.com/openether/ethcore/crypto"
 	"github.com/openether/ethcore/event"
+	"github.com/openether/ethcore/internal/debug"
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
 	"github.com/openether/ethcore/p2p"
+	"github.com/openether/ethcore/p2p/discover"
 	"github.com/openether/ethcore/rpc"
 )
 
@@ -33,6 +40,8 @@ type Node struct {
 
 	serverConfig p2p.Config
 	server       *p2p.Server // Currently running P2P networking layer
+	nodeKeyFile  string      // File the node key is stored in, empty if given explicitly or ephemeral
+	fs           *fs
 
 	serviceFuncs []ServiceConstructor     // Service constructors (in dependency order)
 	services     map[reflect.Type]Service // Currently running services
@@ -60,6 +69,15 @@ type Node struct {
 	wsListener  net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler   *rpc.Server  // Websocket RPC request handler to process the API requests
 
+	subBuffer int                    // Notifications buffered per subscription (0 = connection limit only)
+	subPolicy rpc.SlowConsumerPolicy // Policy applied to subscriptions exceeding subBuffer
+
+	rpcTimeouts rpc.MethodTimeouts // Execution time limits of RPC methods
+	batchLimits rpc.BatchLimits    // Resource limits of RPC batch requests
+
+	memoryLimit uint64             // Heap size in bytes beyond which load is shed (0 = unlimited)
+	watchdog    *memwatch.Watchdog // Memory watchdog of the running node, nil if unlimited
+
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
 }
@@ -81,8 +99,14 @@ func New(conf *Config) (*Node, error) {
 	if conf.DataDir != "" {
 		nodeDbPath = filepath.Join(conf.DataDir, datadirNodeDatabase)
 	}
+	nodeKeyFile := ""
+	if conf.PrivateKey == nil && conf.DataDir != "" {
+		nodeKeyFile = filepath.Join(conf.DataDir, datadirPrivateKey)
+	}
 	return &Node{
-		datadir: conf.DataDir,
+		datadir:     conf.DataDir,
+		nodeKeyFile: nodeKeyFile,
+		fs:          conf.fs,
 		serverConfig: p2p.Config{
 			PrivateKey:      conf.NodeKey(),
 			Name:            conf.Name,
@@ -95,8 +119,15 @@ func New(conf *Config) (*Node, error) {
 			NAT:             conf.NAT,
 			Dialer:          conf.Dialer,
 			NoDial:          conf.NoDial,
+			IPMode:          conf.IPMode,
 			MaxPeers:        conf.MaxPeers,
 			MaxPendingPeers: conf.MaxPendingPeers,
+
+			ClockCheckInterval:  conf.ClockCheckInterval,
+			ClockDriftThreshold: conf.ClockDriftThreshold,
+			GeoIPDatabase:       conf.GeoIPDatabase,
+			HandshakeTimeout:    conf.HandshakeTimeout,
+			FrameReadTimeout:    conf.FrameReadTimeout,
 		},
 		serviceFuncs:  []ServiceConstructor{},
 		ipcEndpoint:   conf.IPCEndpoint(),
@@ -110,6 +141,11 @@ func New(conf *Config) (*Node, error) {
 		wsEndpoint:    conf.WSEndpoint(),
 		wsWhitelist:   conf.WSModules,
 		wsOrigins:     conf.WSOrigins,
+		subBuffer:     conf.SubscriptionBuffer,
+		subPolicy:     conf.SubscriptionPolicy,
+		rpcTimeouts:   conf.RPCTimeouts,
+		batchLimits:   conf.RPCBatchLimits,
+		memoryLimit:   conf.MemoryLimit,
 		eventmux:      new(event.TypeMux),
 	}, nil
 }
@@ -198,6 +234,12 @@ func (n *Node) Start() error {
 	n.server = running
 	n.stop = make(chan struct{})
 
+	if n.memoryLimit > 0 {
+		n.watchdog = memwatch.New(n.memoryLimit, 0)
+		n.watchdog.Start()
+		memwatch.SetDefault(n.watchdog)
+	}
+
 	return nil
 }
 
@@ -234,10 +276,68 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	return nil
 }
 
+// heavyMethods are the RPC methods executing code or scanning large parts of the
+// chain, which are refused under memory pressure.
+var heavyMethods = map[string]bool{
+	"eth_call":                       true,
+	"eth_callMany":                   true,
+	"eth_multicall":                  true,
+	"eth_simulateBundle":             true,
+	"eth_estimateGas":                true,
+	"eth_createAccessList":           true,
+	"eth_traceCall":                  true,
+	"eth_getLogs":                    true,
+	"eth_getAccountHistory":          true,
+	"eth_getTransactionReceipts":     true,
+	"eth_getStorageAtMany":           true,
+	"eth_feeHistory":                 true,
+	"debug_traceTransaction":         true,
+	"debug_traceBlockByNumber":       true,
+	"debug_traceBlockByHash":         true,
+	"debug_standardTraceBlockToFile": true,
+	"debug_dumpBlock":                true,
+	"trace_block":                    true,
+	"trace_transaction":              true,
+	"trace_filter":                   true,
+}
+
+// heavyMethod reports whether a method is refused under memory pressure: one of
+// the heavyMethods, or any method the operator limited the execution time of.
+func (n *Node) heavyMethod(method string) bool {
+	return heavyMethods[method] || n.rpcTimeouts[method] > 0
+}
+
+// newServer creates an RPC server configured with the node's method timeouts and
+// batch limits. If the node has a memory limit, heavy methods are refused under
+// memory pressure.
+func (n *Node) newServer() *rpc.Server {
+	handler := rpc.NewServer()
+	handler.SetMethodTimeouts(n.rpcTimeouts)
+	handler.SetBatchLimits(n.batchLimits)
+	handler.SetReadiness(n.Ready)
+	if n.memoryLimit > 0 {
+		handler.SetAdmission(func(method string) error {
+			if n.heavyMethod(method) {
+				return memwatch.Overloaded()
+			}
+			return nil
+		})
+	}
+	return handler
+}
+
+// newSubscriptionServer creates an RPC server for an endpoint supporting subscriptions,
+// configured with the node's subscription limits.
+func (n *Node) newSubscriptionServer() *rpc.Server {
+	handler := n.newServer()
+	handler.SetSubscriptionLimits(n.subBuffer, n.subPolicy)
+	return handler
+}
+
 // startInProc initializes an in-process RPC endpoint.
 func (n *Node) startInProc(apis []rpc.API) error {
 	// Register all the APIs exposed by the services
-	handler := rpc.NewServer()
+	handler := n.newSubscriptionServer()
 	for _, api := range apis {
 		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 			return err
@@ -263,7 +363,7 @@ func (n *Node) startIPC(apis []rpc.API) error {
 		return nil
 	}
 	// Register all the APIs exposed by the services
-	handler := rpc.NewServer()
+	handler := n.newSubscriptionServer()
 	for _, api := range apis {
 		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 			return err
@@ -333,7 +433,7 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 		whitelist[module] = true
 	}
 	// Register all the APIs exposed by the services
-	handler := rpc.NewServer()
+	handler := n.newServer()
 	for _, api := range apis {
 		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
@@ -390,7 +490,7 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 		whitelist[module] = true
 	}
 	// Register all the APIs exposed by the services
-	handler := rpc.NewServer()
+	handler := n.newSubscriptionServer()
 	for _, api := range apis {
 		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
@@ -446,6 +546,11 @@ func (n *Node) Stop() error {
 		return ErrNodeStopped
 	}
 	// Otherwise terminate the API, all services and the P2P server too
+	if n.watchdog != nil {
+		memwatch.SetDefault(nil)
+		n.watchdog.Stop()
+		n.watchdog = nil
+	}
 	n.stopWS()
 	n.stopHTTP()
 	n.stopIPC()
@@ -509,6 +614,55 @@ func (n *Node) Attach() (rpc.Client, error) {
 	return rpc.NewInProcRPCClient(n.inprocHandler), nil
 }
 
+// NodeKey retrieves the private key identifying the node on the network.
+func (n *Node) NodeKey() *ecdsa.PrivateKey {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	if n.server != nil {
+		return n.server.NodeKey()
+	}
+	return n.serverConfig.PrivateKey
+}
+
+// SetNodeKey replaces the private key identifying the node on the network. The
+// key is stored in the data directory if the previous one was loaded from there,
+// in which case the path of the key file is returned. The peers of a running node
+// are disconnected to handshake again under the new identity.
+func (n *Node) SetNodeKey(key *ecdsa.PrivateKey) (string, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.nodeKeyFile != "" {
+		// Replace the key file atomically, so a crash never loses the identity
+		tmp := n.nodeKeyFile + ".tmp"
+		f, err := n.fs.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return "", err
+		}
+		_, err = crypto.WriteECDSAKey(f, key)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = n.fs.Rename(tmp, n.nodeKeyFile)
+		}
+		if err != nil {
+			n.fs.Remove(tmp)
+			return "", fmt.Errorf("failed to store node key: %v", err)
+		}
+	}
+	n.serverConfig.PrivateKey = key
+	if n.server != nil {
+		if err := n.server.SetNodeKey(key); err != nil {
+			// The key is in use nonetheless
+			return n.nodeKeyFile, fmt.Errorf("node key replaced, but %v", err)
+		}
+	}
+	glog.V(logger.Info).Infof("Node key replaced, node ID now %x", discover.PubkeyID(&key.PublicKey))
+	return n.nodeKeyFile, nil
+}
+
 // Server retrieves the currently running P2P network layer. This method is meant
 // only to inspect fields of the currently running server, life cycle management
 // should be left to this Node entity.
@@ -537,6 +691,25 @@ func (n *Node) Service(service interface{}) error {
 	return ErrServiceUnknown
 }
 
+// Ready returns why the node isn't ready to serve: it is stopped or one of its
+// services implementing ReadinessReporter isn't ready.
+func (n *Node) Ready() error {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	if n.server == nil {
+		return ErrNodeStopped
+	}
+	for _, service := range n.services {
+		if reporter, ok := service.(ReadinessReporter); ok {
+			if err := reporter.Ready(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // DataDir retrieves the current datadir used by the protocol stack.
 func (n *Node) DataDir() string {
 	return n.datadir
@@ -580,6 +753,10 @@ func (n *Node) apis() []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicWeb3API(n),
 			Public:    true,
+		}, {
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   debug.Handler,
 		},
 	}
 }
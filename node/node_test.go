@@ -25,6 +25,7 @@ import (
 	"github.com/ethereumclassic/go-ethereum/crypto"
 	"github.com/ethereumclassic/go-ethereum/logger/glog"
 	"github.com/ethereumclassic/go-ethereum/p2p"
+	"github.com/ethereumclassic/go-ethereum/p2p/discover"
 	"github.com/ethereumclassic/go-ethereum/rpc"
 	"github.com/spf13/afero"
 )
@@ -110,6 +111,46 @@ func TestNodeUsedDataDir(t *testing.T) {
 	}
 }
 
+// Tests that a replaced node key is taken into use by the running server and
+// stored in the data directory in place of the old one.
+func TestNodeKeyReplacement(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	dir, err := afero.TempDir(afs, "", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer afs.RemoveAll(dir)
+
+	stack, err := New(&Config{DataDir: dir, fs: &fs{afs}})
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	key, _ := crypto.GenerateKey()
+	file, err := stack.SetNodeKey(key)
+	if err != nil {
+		t.Fatalf("failed to replace node key: %v", err)
+	}
+	if have, want := stack.Server().Self().ID, discover.PubkeyID(&key.PublicKey); have != want {
+		t.Errorf("node ID mismatch: have %x, want %x", have, want)
+	}
+	blob, err := afero.ReadFile(afs, file)
+	if err != nil {
+		t.Fatalf("failed to read node key file: %v", err)
+	}
+	stored, err := crypto.HexToECDSA(string(blob))
+	if err != nil {
+		t.Fatalf("invalid node key file: %v", err)
+	}
+	if !reflect.DeepEqual(stored.D, key.D) {
+		t.Errorf("stored node key mismatch: have %x, want %x", crypto.FromECDSA(stored), crypto.FromECDSA(key))
+	}
+}
+
 // Tests whether services can be registered and duplicates caught.
 func TestServiceRegistry(t *testing.T) {
 	stack, err := New(testNodeConfig())
@@ -598,3 +639,29 @@ func TestAPIGather(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the methods refused under memory pressure are the heavy ones and
+// those the operator limited the execution time of.
+func TestHeavyMethods(t *testing.T) {
+	conf := testNodeConfig()
+	conf.RPCTimeouts = rpc.MethodTimeouts{"eth_getBalance": time.Second, "eth_call": 0}
+	stack, err := New(conf)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	tests := map[string]bool{
+		"eth_call":              true,
+		"eth_callMany":          true,
+		"eth_multicall":         true,
+		"eth_simulateBundle":    true,
+		"eth_getAccountHistory": true,
+		"eth_getBalance":        true,
+		"eth_blockNumber":       false,
+		"net_version":           false,
+	}
+	for method, heavy := range tests {
+		if have := stack.heavyMethod(method); have != heavy {
+			t.Errorf("%s: heavy mismatch: have %v, want %v", method, have, heavy)
+		}
+	}
+}
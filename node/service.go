@@ -29,6 +29,12 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (et
 	return ethdb.NewLDBDatabase(filepath.Join(ctx.datadir, name), cache, handles)
 }
 
+// DataDir returns the data directory of the node, empty if the node is an
+// ephemeral one.
+func (ctx *ServiceContext) DataDir() string {
+	return ctx.datadir
+}
+
 // Service retrieves a currently running service registered of a specific type.
 func (ctx *ServiceContext) Service(service interface{}) error {
 	element := reflect.ValueOf(service).Elem()
@@ -66,3 +72,11 @@ type Service interface {
 	// are all terminated.
 	Stop() error
 }
+
+// ReadinessReporter is implemented by services able to tell whether they are fit
+// to serve, such as a chain service following a stalled chain. The node is ready
+// when all of its services reporting readiness are.
+type ReadinessReporter interface {
+	// Ready returns why the service isn't ready to serve, nil if it is.
+	Ready() error
+}
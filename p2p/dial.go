@@ -3,6 +3,7 @@ package p2p
 import (
 	"container/heap"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"net"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
 	"github.com/openether/ethcore/p2p/discover"
+	"github.com/openether/ethcore/p2p/distip"
 )
 
 const (
@@ -39,6 +41,8 @@ type dialstate struct {
 	randomNodes   []*discover.Node // filled from Table
 	static        map[discover.NodeID]*dialTask
 	hist          *dialHistory
+	ipMode        distip.IPMode // address families allowed for dynamic dials
+	faults        *faultRecords // nodes banned from dynamic dials, nil if none
 }
 
 type discoverTable interface {
@@ -113,6 +117,12 @@ func (s *dialstate) removeStatic(n *discover.Node) {
 	s.hist.remove(n.ID)
 }
 
+// setTable replaces the discovery table nodes are found through, nil if
+// discovery is off.
+func (s *dialstate) setTable(ntab discoverTable) {
+	s.ntab = ntab
+}
+
 func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now time.Time) []task {
 	var newtasks []task
 	isDialing := func(id discover.NodeID) bool {
@@ -120,7 +130,10 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 		return found || peers[id] != nil || s.hist.contains(id)
 	}
 	addDial := func(flag connFlag, n *discover.Node) bool {
-		if isDialing(n.ID) {
+		if isDialing(n.ID) || !s.ipMode.Allows(n.IP) {
+			return false
+		}
+		if s.faults != nil && s.faults.banned(n.ID, now) {
 			return false
 		}
 		s.dialing[n.ID] = flag
@@ -155,8 +168,9 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	// Use random nodes from the table for half of the necessary
 	// dynamic dials.
 	randomCandidates := needDynDials / 2
-	if randomCandidates > 0 {
+	if randomCandidates > 0 && s.ntab != nil {
 		n := s.ntab.ReadRandomNodes(s.randomNodes)
+		s.sortCandidates(s.randomNodes[:n])
 		for i := 0; i < randomCandidates && i < n; i++ {
 			if addDial(dynDialedConn, s.randomNodes[i]) {
 				needDynDials--
@@ -165,6 +179,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	}
 	// Create dynamic dials from random lookup results, removing tried
 	// items from the result buffer.
+	s.sortCandidates(s.lookupBuf)
 	i := 0
 	for ; i < len(s.lookupBuf) && needDynDials > 0; i++ {
 		if addDial(dynDialedConn, s.lookupBuf[i]) {
@@ -173,7 +188,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	}
 	s.lookupBuf = s.lookupBuf[:copy(s.lookupBuf, s.lookupBuf[i:])]
 	// Launch a discovery lookup if more candidates are needed.
-	if len(s.lookupBuf) < needDynDials && !s.lookupRunning {
+	if len(s.lookupBuf) < needDynDials && !s.lookupRunning && s.ntab != nil {
 		s.lookupRunning = true
 		newtasks = append(newtasks, &discoverTask{})
 	}
@@ -189,6 +204,26 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	return newtasks
 }
 
+// sortCandidates moves IPv6 candidates ahead of IPv4 ones if IPv6 is preferred,
+// keeping the relative order within each address family.
+func (s *dialstate) sortCandidates(nodes []*discover.Node) {
+	if s.ipMode != distip.IPPrefer6 {
+		return
+	}
+	sorted := make([]*discover.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.IP.To4() == nil {
+			sorted = append(sorted, n)
+		}
+	}
+	for _, n := range nodes {
+		if n.IP.To4() != nil {
+			sorted = append(sorted, n)
+		}
+	}
+	copy(nodes, sorted)
+}
+
 func (s *dialstate) taskDone(t task, now time.Time) {
 	switch t := t.(type) {
 	case *dialTask:
@@ -222,8 +257,10 @@ func (t *dialTask) Do(srv *Server) {
 // discovery network with useless queries for nodes that don't exist.
 // The backoff delay resets when the node is found.
 func (t *dialTask) resolve(srv *Server) bool {
-	if srv.ntab == nil {
+	ntab := srv.table()
+	if ntab == nil {
 		glog.V(logger.Debug).Infof("can't resolve node %x: discovery is disabled", t.dest.ID[:6])
+		srv.dialAttempts.add(t.dest, t.flags, time.Now(), DialUnresolved, errors.New("discovery is disabled"))
 		return false
 	}
 	if t.resolveDelay == 0 {
@@ -232,9 +269,11 @@ func (t *dialTask) resolve(srv *Server) bool {
 	if time.Since(t.lastResolved) < t.resolveDelay {
 		return false
 	}
-	resolved := srv.ntab.Resolve(t.dest.ID)
+	start := time.Now()
+	resolved := ntab.Resolve(t.dest.ID)
 	t.lastResolved = time.Now()
 	if resolved == nil {
+		srv.dialAttempts.add(t.dest, t.flags, start, DialUnresolved, nil)
 		t.resolveDelay *= 2
 		if t.resolveDelay > maxResolveDelay {
 			t.resolveDelay = maxResolveDelay
@@ -253,13 +292,16 @@ func (t *dialTask) resolve(srv *Server) bool {
 func (t *dialTask) dial(srv *Server, dest *discover.Node) bool {
 	addr := &net.TCPAddr{IP: dest.IP, Port: int(dest.TCP)}
 	glog.V(logger.Detail).Infof("dial tcp %v (%x)\n", addr, dest.ID[:6])
+	start := time.Now()
 	fd, err := srv.Dialer.Dial("tcp", addr.String())
 	if err != nil {
 		glog.V(logger.Detail).Infof("%v", err)
+		srv.dialAttempts.add(dest, t.flags, start, DialUnreachable, err)
 		return false
 	}
 	mfd := newMeteredConn(fd, false)
-	srv.setupConn(mfd, t.flags, dest)
+	err = srv.setupConn(mfd, t.flags, dest)
+	srv.dialAttempts.add(dest, t.flags, start, dialOutcome(err), err)
 	return true
 }
 
@@ -278,7 +320,9 @@ func (t *discoverTask) Do(srv *Server) {
 	srv.lastLookup = time.Now()
 	var target discover.NodeID
 	rand.Read(target[:])
-	t.results = srv.ntab.Lookup(target)
+	if ntab := srv.table(); ntab != nil {
+		t.results = ntab.Lookup(target)
+	}
 }
 
 func (t *discoverTask) String() string {
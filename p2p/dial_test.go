@@ -25,6 +25,7 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereumclassic/go-ethereum/p2p/discover"
+	"github.com/ethereumclassic/go-ethereum/p2p/distip"
 )
 
 func init() {
@@ -83,6 +84,37 @@ func (t fakeTable) Lookup(discover.NodeID) []*discover.Node  { return nil }
 func (t fakeTable) Resolve(discover.NodeID) *discover.Node   { return nil }
 func (t fakeTable) ReadRandomNodes(buf []*discover.Node) int { return copy(buf, t) }
 
+// This test checks that IPv6 candidates are dialed first when preferred, and
+// that IPv4 candidates are skipped entirely when IPv6 is required.
+func TestDialStateIPMode(t *testing.T) {
+	table := fakeTable{
+		{ID: uintID(1), IP: net.ParseIP("10.0.0.1")},
+		{ID: uintID(2), IP: net.ParseIP("2001:db8::2")},
+		{ID: uintID(3), IP: net.ParseIP("10.0.0.3")},
+		{ID: uintID(4), IP: net.ParseIP("2001:db8::4")},
+	}
+	dialed := func(mode distip.IPMode) []discover.NodeID {
+		s := newDialState(nil, table, 8)
+		s.ipMode = mode
+		var ids []discover.NodeID
+		for _, task := range s.newTasks(0, nil, time.Time{}) {
+			if t, ok := task.(*dialTask); ok {
+				ids = append(ids, t.dest.ID)
+			}
+		}
+		return ids
+	}
+	if got, want := dialed(distip.IPPrefer6), []discover.NodeID{uintID(2), uintID(4), uintID(1), uintID(3)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("prefer6 dialed %v, want %v", got, want)
+	}
+	if got, want := dialed(distip.IPOnly6), []discover.NodeID{uintID(2), uintID(4)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("only6 dialed %v, want %v", got, want)
+	}
+	if got, want := dialed(distip.IPAny), []discover.NodeID{uintID(1), uintID(2), uintID(3), uintID(4)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("any dialed %v, want %v", got, want)
+	}
+}
+
 // This test checks that dynamic dials are launched from discovery results.
 func TestDialStateDynDial(t *testing.T) {
 	runDialTest(t, dialtest{
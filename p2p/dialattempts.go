@@ -0,0 +1,88 @@
+package p2p
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/p2p/discover"
+)
+
+const dialAttemptsKept = 256 // Number of recent dial attempts kept for DialHistory
+
+// Outcomes of dial attempts.
+const (
+	DialConnected       = "connected"        // Added as a peer
+	DialUnresolved      = "unresolved"       // Endpoint not found through discovery
+	DialUnreachable     = "unreachable"      // TCP connection failed
+	DialHandshakeFailed = "handshake failed" // Encryption or protocol handshake failed
+	DialRejected        = "rejected"         // Disconnected by either side after the handshakes, e.g. too many peers
+)
+
+// DialAttempt is an attempt of the server to connect to a node.
+type DialAttempt struct {
+	ID       discover.NodeID `json:"id"`
+	Addr     string          `json:"addr"`
+	Flags    string          `json:"flags"` // Why the node was dialed, e.g. "staticdial"
+	Time     time.Time       `json:"time"`
+	Duration string          `json:"duration"`
+	Outcome  string          `json:"outcome"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// dialOutcome classifies the error a connection attempt ended with.
+func dialOutcome(err error) string {
+	switch err := err.(type) {
+	case nil:
+		return DialConnected
+	case DiscReason:
+		if err == DiscUnexpectedIdentity {
+			return DialHandshakeFailed
+		}
+		return DialRejected
+	}
+	return DialHandshakeFailed
+}
+
+// dialAttempts is a ring buffer of the most recent dial attempts.
+type dialAttempts struct {
+	lock     sync.Mutex
+	attempts []DialAttempt
+	next     int // Index the next attempt is written to once the buffer is full
+}
+
+// add records an attempt to dial dest started at start.
+func (d *dialAttempts) add(dest *discover.Node, flags connFlag, start time.Time, outcome string, err error) {
+	attempt := DialAttempt{
+		ID:       dest.ID,
+		Addr:     (&net.TCPAddr{IP: dest.IP, Port: int(dest.TCP)}).String(),
+		Flags:    flags.String(),
+		Time:     start,
+		Duration: time.Since(start).String(),
+		Outcome:  outcome,
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if len(d.attempts) < dialAttemptsKept {
+		d.attempts = append(d.attempts, attempt)
+		return
+	}
+	d.attempts[d.next] = attempt
+	d.next = (d.next + 1) % dialAttemptsKept
+}
+
+// list returns the attempts kept, most recent first.
+func (d *dialAttempts) list() []DialAttempt {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	list := make([]DialAttempt, 0, len(d.attempts))
+	for i := len(d.attempts) - 1; i >= 0; i-- {
+		list = append(list, d.attempts[(d.next+i)%len(d.attempts)])
+	}
+	return list
+}
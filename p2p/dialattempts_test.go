@@ -0,0 +1,48 @@
+package p2p
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/p2p/discover"
+)
+
+func TestDialAttemptsRing(t *testing.T) {
+	var d dialAttempts
+	for i := 0; i < dialAttemptsKept+3; i++ {
+		dest := &discover.Node{IP: net.IP{10, 0, 0, 1}, TCP: uint16(i)}
+		d.add(dest, dynDialedConn, time.Now(), DialUnreachable, errors.New("refused"))
+	}
+	list := d.list()
+	if len(list) != dialAttemptsKept {
+		t.Fatalf("attempts kept: got %d, want %d", len(list), dialAttemptsKept)
+	}
+	if want := "10.0.0.1:258"; list[0].Addr != want {
+		t.Errorf("most recent attempt: got %s, want %s", list[0].Addr, want)
+	}
+	if want := "10.0.0.1:3"; list[len(list)-1].Addr != want {
+		t.Errorf("oldest attempt: got %s, want %s", list[len(list)-1].Addr, want)
+	}
+	if list[0].Flags != "dyndial" || list[0].Error != "refused" {
+		t.Errorf("attempt mismatch: got %+v", list[0])
+	}
+}
+
+func TestDialOutcome(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, DialConnected},
+		{DiscTooManyPeers, DialRejected},
+		{DiscUnexpectedIdentity, DialHandshakeFailed},
+		{errors.New("EOF"), DialHandshakeFailed},
+	}
+	for _, tt := range tests {
+		if got := dialOutcome(tt.err); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -18,6 +18,7 @@ var mLogLines = []*logger.MLogT{
 	mlogFindNodeSendTo,
 	mlogNeighborsHandleFrom,
 	mlogNeighborsSendTo,
+	mlogPacketDropFrom,
 }
 
 // Collect and document available mlog lines.
@@ -124,3 +125,18 @@ var mlogNeighborsSendTo = &logger.MLogT{
 		{Owner: "NEIGHBORS", Key: "BYTES_TRANSFERRED", Value: "INT"},
 	},
 }
+
+// DROPPED PACKETS
+// mlogPacketDropFrom is called once for each packet dropped before handling
+var mlogPacketDropFrom = &logger.MLogT{
+	Description: `Called once for each received packet from peer FROM dropped for failing validation or being a replayed request.`,
+	Receiver:    "PACKET",
+	Verb:        "DROP",
+	Subject:     "FROM",
+	Details: []logger.MLogDetailT{
+		{Owner: "FROM", Key: "UDP_ADDRESS", Value: "STRING"},
+		{Owner: "FROM", Key: "ID", Value: "STRING"},
+		{Owner: "PACKET", Key: "REASON", Value: "STRING"},
+		{Owner: "PACKET", Key: "DROPPED_TOTAL", Value: "INT"},
+	},
+}
@@ -0,0 +1,63 @@
+package discover
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/openether/ethcore/metrics"
+)
+
+const packetCacheSize = 4096 // Number of validated packets whose sender is remembered
+
+var errReplayed = errors.New("replayed")
+
+// seenPacket is a validated packet.
+type seenPacket struct {
+	from NodeID
+	time time.Time // When the packet was first received
+}
+
+// packetCache remembers the sender of recently validated packets by packet hash,
+// which covers the signature, so that packets received again don't need their
+// signature recovered. Requests received again from the same sender while they
+// could still be answered, ie. within the expiration window, are replays and
+// dropped before they cost a reply.
+type packetCache struct {
+	seen *lru.Cache // seenPacket by packet hash
+}
+
+func newPacketCache() *packetCache {
+	seen, _ := lru.New(packetCacheSize)
+	return &packetCache{seen: seen}
+}
+
+// sender returns the sender of a packet seen before, or errReplayed if the packet
+// is a request replayed within the expiration window.
+func (c *packetCache) sender(hash []byte, ptype byte, now time.Time) (NodeID, bool, error) {
+	if c == nil {
+		return NodeID{}, false, nil
+	}
+	v, ok := c.seen.Get(string(hash))
+	if !ok {
+		return NodeID{}, false, nil
+	}
+	seen := v.(seenPacket)
+	if isRequest(ptype) && now.Sub(seen.time) < expiration {
+		return seen.from, true, errReplayed
+	}
+	metrics.DiscSigCacheHits.Mark(1)
+	return seen.from, true, nil
+}
+
+// add remembers the sender of a validated packet.
+func (c *packetCache) add(hash []byte, from NodeID, now time.Time) {
+	if c != nil {
+		c.seen.Add(string(hash), seenPacket{from: from, time: now})
+	}
+}
+
+// isRequest reports whether packets of the given type are answered.
+func isRequest(ptype byte) bool {
+	return ptype == pingPacket || ptype == findnodePacket
+}
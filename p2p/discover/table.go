@@ -14,6 +14,7 @@ import (
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
 	"github.com/openether/ethcore/p2p/distip"
+	"github.com/openether/ethcore/p2p/nat"
 )
 
 const (
@@ -130,6 +131,15 @@ func newTable(t transport, ourID NodeID, ourAddr *net.UDPAddr, nodeDBPath string
 	return tab, nil
 }
 
+// NATMapper returns the port mapper keeping the discovery port reachable,
+// or nil if no NAT traversal is configured.
+func (tab *Table) NATMapper() *nat.Mapper {
+	if u, ok := tab.net.(*udp); ok {
+		return u.natMapper
+	}
+	return nil
+}
+
 // Self returns the local node.
 // The returned node should not be modified by the caller.
 func (tab *Table) Self() *Node {
@@ -12,6 +12,7 @@ import (
 	"github.com/openether/ethcore/crypto"
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/metrics"
 	"github.com/openether/ethcore/p2p/distip"
 	"github.com/openether/ethcore/p2p/nat"
 	"github.com/openether/ethcore/rlp"
@@ -180,6 +181,8 @@ type udp struct {
 	netrestrict *distip.Netlist
 	priv        *ecdsa.PrivateKey
 	ourEndpoint rpcEndpoint
+	natMapper   *nat.Mapper // keeps the discovery port mapped, nil if NAT is disabled
+	packets     *packetCache
 
 	addpending chan *pending
 	gotreply   chan reply
@@ -228,11 +231,18 @@ type reply struct {
 
 // ListenUDP returns a new table that listens for UDP packets on laddr.
 func ListenUDP(priv *ecdsa.PrivateKey, laddr string, natm nat.Interface, nodeDBPath string) (*Table, error) {
-	addr, err := net.ResolveUDPAddr("udp", laddr)
+	return ListenUDPNetwork("udp", priv, laddr, natm, nodeDBPath)
+}
+
+// ListenUDPNetwork is like ListenUDP, but listens on the given network, which
+// must be one of "udp", "udp4" or "udp6". Using "udp" with an unspecified
+// host address listens on both IPv4 and IPv6 where the platform supports it.
+func ListenUDPNetwork(network string, priv *ecdsa.PrivateKey, laddr string, natm nat.Interface, nodeDBPath string) (*Table, error) {
+	addr, err := net.ResolveUDPAddr(network, laddr)
 	if err != nil {
 		return nil, err
 	}
-	conn, err := net.ListenUDP("udp", addr)
+	conn, err := net.ListenUDP(network, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -253,11 +263,13 @@ func newUDP(priv *ecdsa.PrivateKey, c conn, natm nat.Interface, nodeDBPath strin
 		closing:    make(chan struct{}),
 		gotreply:   make(chan reply),
 		addpending: make(chan *pending),
+		packets:    newPacketCache(),
 	}
 	realaddr := c.LocalAddr().(*net.UDPAddr)
 	if natm != nil {
 		if !realaddr.IP.IsLoopback() {
-			go nat.Map(natm, udp.closing, "udp", realaddr.Port, realaddr.Port, "ethereum discovery")
+			udp.natMapper = nat.NewMapper(natm, "udp", realaddr.Port, realaddr.Port, "ethereum discovery")
+			go udp.natMapper.Run(udp.closing)
 		}
 		// TODO: react to external IP changes over time.
 		if ext, err := natm.ExternalIP(); err == nil {
@@ -587,9 +599,9 @@ func (t *udp) readLoop() {
 }
 
 func (t *udp) handlePacket(from *net.UDPAddr, buf []byte) error {
-	packet, fromID, hash, err := decodePacket(buf)
+	packet, fromID, hash, err := decodePacketCached(buf, t.packets)
 	if err != nil {
-		glog.V(logger.Debug).Infof("Bad packet from %v: %v\n", from, err)
+		t.dropPacket(from, fromID, err)
 		return err
 	}
 	status := "ok"
@@ -632,7 +644,34 @@ func (t *udp) handlePacket(from *net.UDPAddr, buf []byte) error {
 	return err
 }
 
+// dropPacket counts and logs a packet that failed validation or was replayed.
+func (t *udp) dropPacket(from *net.UDPAddr, fromID NodeID, err error) {
+	meter := metrics.DiscBadPackets
+	if err == errReplayed {
+		meter = metrics.DiscReplayedPackets
+		glog.V(logger.Detail).Infof("Replayed packet from %v", from)
+	} else {
+		glog.V(logger.Debug).Infof("Bad packet from %v: %v\n", from, err)
+	}
+	meter.Mark(1)
+	if logger.MlogEnabled() {
+		mlogPacketDropFrom.AssignDetails(
+			from.String(),
+			fromID.String(),
+			err.Error(),
+			meter.Count(),
+		).Send(mlogDiscover)
+	}
+}
+
 func decodePacket(buf []byte) (packet, NodeID, []byte, error) {
+	return decodePacketCached(buf, nil)
+}
+
+// decodePacketCached is like decodePacket, taking the sender of packets seen
+// before from the cache instead of recovering it. It fails with errReplayed for
+// requests replayed within the expiration window.
+func decodePacketCached(buf []byte, cache *packetCache) (packet, NodeID, []byte, error) {
 	if len(buf) < headSize+1 {
 		return nil, NodeID{}, nil, errPacketTooSmall
 	}
@@ -641,9 +680,16 @@ func decodePacket(buf []byte) (packet, NodeID, []byte, error) {
 	if !bytes.Equal(hash, shouldhash) {
 		return nil, NodeID{}, nil, errBadHash
 	}
-	fromID, err := recoverNodeID(crypto.Keccak256(buf[headSize:]), sig)
+	now := time.Now()
+	fromID, seen, err := cache.sender(hash, sigdata[0], now)
 	if err != nil {
-		return nil, NodeID{}, hash, err
+		return nil, fromID, hash, err
+	}
+	if !seen {
+		if fromID, err = recoverNodeID(crypto.Keccak256(buf[headSize:]), sig); err != nil {
+			return nil, NodeID{}, hash, err
+		}
+		cache.add(hash, fromID, now)
 	}
 	var req packet
 	switch ptype := sigdata[0]; ptype {
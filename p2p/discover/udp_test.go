@@ -179,6 +179,34 @@ func TestUDP_packetErrors(t *testing.T) {
 	test.packetIn(errUnsolicitedReply, neighborsPacket, &neighbors{Expiration: futureExp})
 }
 
+func TestUDP_replayedPacket(t *testing.T) {
+	key := newkey()
+	ping, _ := encodePacket(key, pingPacket, &ping{From: testRemote, To: testLocalAnnounced, Version: Version, Expiration: futureExp})
+	pong, _ := encodePacket(key, pongPacket, &pong{ReplyTok: []byte{}, Expiration: futureExp})
+
+	cache := newPacketCache()
+	for i, want := range []error{nil, errReplayed} {
+		_, id, _, err := decodePacketCached(ping, cache)
+		if err != want {
+			t.Errorf("ping #%d: error mismatch: got %v, want %v", i, err, want)
+		}
+		if id != PubkeyID(&key.PublicKey) {
+			t.Errorf("ping #%d: sender mismatch: got %x", i, id[:8])
+		}
+	}
+	// Replies are only taken from the cache
+	for i := 0; i < 2; i++ {
+		if _, id, _, err := decodePacketCached(pong, cache); err != nil || id != PubkeyID(&key.PublicKey) {
+			t.Errorf("pong #%d: got %x, %v", i, id[:8], err)
+		}
+	}
+	// Past the expiration window the ping's own expiration rejects it
+	hash := ping[:macSize]
+	if _, seen, err := cache.sender(hash, pingPacket, time.Now().Add(expiration)); !seen || err != nil {
+		t.Errorf("ping after expiration window: seen %v, error %v", seen, err)
+	}
+}
+
 func TestUDP_pingTimeout(t *testing.T) {
 	t.Parallel()
 	test := newUDPTest(t)
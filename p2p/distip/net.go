@@ -235,3 +235,53 @@ func (s DistinctNetSet) String() string {
 	buf.WriteString("}")
 	return buf.String()
 }
+
+// IPMode selects the address families used for listening and dialing.
+type IPMode int
+
+const (
+	IPAny     IPMode = iota // listen dual-stack, dial any address family
+	IPPrefer6               // listen dual-stack, dial IPv6 candidates ahead of IPv4 ones
+	IPOnly6                 // listen and dial on IPv6 only
+)
+
+// ParseIPMode parses an IP mode name as accepted on the command line.
+func ParseIPMode(s string) (IPMode, error) {
+	switch s {
+	case "any":
+		return IPAny, nil
+	case "prefer6":
+		return IPPrefer6, nil
+	case "only6":
+		return IPOnly6, nil
+	}
+	return IPAny, fmt.Errorf("unknown IP mode %q, want any|prefer6|only6", s)
+}
+
+func (m IPMode) String() string {
+	switch m {
+	case IPPrefer6:
+		return "prefer6"
+	case IPOnly6:
+		return "only6"
+	}
+	return "any"
+}
+
+// Allows reports whether connections to the given IP are permitted in this mode.
+func (m IPMode) Allows(ip net.IP) bool {
+	if m == IPOnly6 {
+		return ip.To4() == nil
+	}
+	return true
+}
+
+// Network returns the network name to listen on for the given base network
+// ("tcp" or "udp"). IPv6-only mode uses the v6 variant, which disables
+// dual-stack sockets.
+func (m IPMode) Network(base string) string {
+	if m == IPOnly6 {
+		return base + "6"
+	}
+	return base
+}
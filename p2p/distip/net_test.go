@@ -116,3 +116,33 @@ func TestCheckRelayIP(t *testing.T) {
 		}
 	}
 }
+
+func TestIPMode(t *testing.T) {
+	for _, s := range []string{"any", "prefer6", "only6"} {
+		mode, err := ParseIPMode(s)
+		if err != nil {
+			t.Fatalf("ParseIPMode(%q): %v", s, err)
+		}
+		if mode.String() != s {
+			t.Errorf("ParseIPMode(%q).String() = %q", s, mode.String())
+		}
+	}
+	for _, s := range []string{"only4", "", "dual", "require6"} {
+		if _, err := ParseIPMode(s); err == nil {
+			t.Errorf("ParseIPMode(%q): expected error for unknown mode", s)
+		}
+	}
+
+	checkContains(t, IPOnly6.Allows,
+		[]string{"2001:db8::1", "fe80::1"},
+		[]string{"127.0.0.1", "::ffff:10.0.0.1"},
+	)
+	checkContains(t, IPPrefer6.Allows, []string{"2001:db8::1", "127.0.0.1"}, nil)
+
+	if n := IPOnly6.Network("tcp"); n != "tcp6" {
+		t.Errorf("IPOnly6.Network(tcp) = %q", n)
+	}
+	if n := IPAny.Network("udp"); n != "udp" {
+		t.Errorf("IPAny.Network(udp) = %q", n)
+	}
+}
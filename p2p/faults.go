@@ -0,0 +1,103 @@
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/p2p/discover"
+)
+
+const (
+	faultBanBase    = 30 * time.Second // Ban of a node after its first faulty message, doubled for every further one
+	faultBanMax     = 24 * time.Hour   // Longest ban of a node
+	faultMemory     = 24 * time.Hour   // Time a node's faults are remembered after its ban ended
+	maxFaultRecords = 4096             // Number of nodes whose faults are remembered
+)
+
+// faultRecord counts the faulty messages a node sent over all its connections.
+type faultRecord struct {
+	malformed, oversized uint64
+	bannedUntil          time.Time
+}
+
+// faultRecords remembers the nodes which sent faulty messages by node ID, so they
+// are kept off for a while as they would just be dropped again: they aren't
+// dialed and their connections are refused until their ban ends, which grows
+// with every fault. Static and trusted nodes are exempt from bans.
+type faultRecords struct {
+	lock  sync.Mutex
+	nodes map[discover.NodeID]*faultRecord
+}
+
+// add records a faulty message sent by a node, banning it.
+func (f *faultRecords) add(id discover.NodeID, fault MsgFault, now time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.nodes == nil {
+		f.nodes = make(map[discover.NodeID]*faultRecord)
+	}
+	record := f.nodes[id]
+	if record == nil {
+		f.makeRoom(now)
+		record = new(faultRecord)
+		f.nodes[id] = record
+	}
+	switch fault {
+	case MsgMalformed:
+		record.malformed++
+	case MsgOversized:
+		record.oversized++
+	}
+	ban := faultBanMax
+	if faults := record.malformed + record.oversized; faults < 32 {
+		if ban = faultBanBase << (faults - 1); ban > faultBanMax {
+			ban = faultBanMax
+		}
+	}
+	record.bannedUntil = now.Add(ban)
+}
+
+// makeRoom forgets the nodes whose faults are old enough, and the one banned the
+// shortest if still too many are remembered. The caller must hold the lock.
+func (f *faultRecords) makeRoom(now time.Time) {
+	if len(f.nodes) < maxFaultRecords {
+		return
+	}
+	var (
+		oldest    discover.NodeID
+		oldestEnd time.Time
+	)
+	for id, record := range f.nodes {
+		if now.Sub(record.bannedUntil) > faultMemory {
+			delete(f.nodes, id)
+			continue
+		}
+		if oldestEnd.IsZero() || record.bannedUntil.Before(oldestEnd) {
+			oldest, oldestEnd = id, record.bannedUntil
+		}
+	}
+	if len(f.nodes) >= maxFaultRecords {
+		delete(f.nodes, oldest)
+	}
+}
+
+// banned reports whether a node is banned for sending faulty messages.
+func (f *faultRecords) banned(id discover.NodeID, now time.Time) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	record := f.nodes[id]
+	return record != nil && now.Before(record.bannedUntil)
+}
+
+// counts returns the number of malformed and oversized messages a node sent.
+func (f *faultRecords) counts(id discover.NodeID) (malformed, oversized uint64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if record := f.nodes[id]; record != nil {
+		return record.malformed, record.oversized
+	}
+	return 0, 0
+}
@@ -0,0 +1,95 @@
+package p2p
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereumclassic/go-ethereum/p2p/discover"
+)
+
+func TestFaultRecordsBan(t *testing.T) {
+	var (
+		f   faultRecords
+		id  = uintID(1)
+		now = time.Now()
+	)
+	if f.banned(id, now) {
+		t.Fatal("node banned before any fault")
+	}
+	// Bans double with every fault and the counts add up over connections
+	f.add(id, MsgMalformed, now)
+	if !f.banned(id, now.Add(faultBanBase-time.Second)) || f.banned(id, now.Add(faultBanBase)) {
+		t.Errorf("first ban mismatch")
+	}
+	f.add(id, MsgOversized, now)
+	f.add(id, MsgMalformed, now)
+	if !f.banned(id, now.Add(4*faultBanBase-time.Second)) || f.banned(id, now.Add(4*faultBanBase)) {
+		t.Errorf("third ban mismatch")
+	}
+	if malformed, oversized := f.counts(id); malformed != 2 || oversized != 1 {
+		t.Errorf("counts mismatch: have %d malformed, %d oversized, want 2, 1", malformed, oversized)
+	}
+	for i := 0; i < 64; i++ {
+		f.add(id, MsgMalformed, now)
+	}
+	if !f.banned(id, now.Add(faultBanMax-time.Second)) || f.banned(id, now.Add(faultBanMax)) {
+		t.Errorf("longest ban mismatch")
+	}
+}
+
+func TestFaultRecordsLimit(t *testing.T) {
+	var (
+		f   faultRecords
+		now = time.Now()
+	)
+	// Records beyond their memory are dropped first
+	f.add(uintID(0), MsgMalformed, now.Add(-faultMemory-time.Hour))
+	for i := 1; i < maxFaultRecords; i++ {
+		f.add(uintID(uint32(i)), MsgMalformed, now)
+	}
+	f.add(uintID(maxFaultRecords), MsgMalformed, now)
+	if len(f.nodes) != maxFaultRecords {
+		t.Fatalf("records mismatch: have %d, want %d", len(f.nodes), maxFaultRecords)
+	}
+	if malformed, _ := f.counts(uintID(0)); malformed != 0 {
+		t.Errorf("expired record kept")
+	}
+	// Otherwise the one banned the shortest
+	f.add(uintID(1), MsgMalformed, now)
+	f.add(uintID(maxFaultRecords+1), MsgMalformed, now)
+	if len(f.nodes) != maxFaultRecords {
+		t.Fatalf("records mismatch: have %d, want %d", len(f.nodes), maxFaultRecords)
+	}
+	if malformed, _ := f.counts(uintID(1)); malformed != 2 {
+		t.Errorf("record with the longest ban dropped")
+	}
+}
+
+// This test checks that nodes banned for faulty messages aren't dialed or
+// accepted, unless static or trusted.
+func TestFaultBannedNodes(t *testing.T) {
+	table := fakeTable{{ID: uintID(1)}, {ID: uintID(2)}}
+	s := newDialState(nil, table, 8)
+	s.faults = new(faultRecords)
+	s.faults.add(uintID(1), MsgMalformed, time.Now())
+
+	var dialed []discover.NodeID
+	for _, task := range s.newTasks(0, nil, time.Now()) {
+		if t, ok := task.(*dialTask); ok {
+			dialed = append(dialed, t.dest.ID)
+		}
+	}
+	if want := []discover.NodeID{uintID(2)}; !reflect.DeepEqual(dialed, want) {
+		t.Errorf("dialed %v, want %v", dialed, want)
+	}
+
+	srv := &Server{Config: Config{MaxPeers: 10}, ntab: fakeTable{}}
+	srv.faults.add(uintID(1), MsgMalformed, time.Now())
+	if err := srv.encHandshakeChecks(nil, 0, &conn{id: uintID(1), flags: inboundConn}); err != DiscProtocolError {
+		t.Errorf("banned node accepted: %v", err)
+	}
+	if err := srv.encHandshakeChecks(nil, 0, &conn{id: uintID(1), flags: inboundConn | trustedConn}); err != nil {
+		t.Errorf("trusted node refused: %v", err)
+	}
+}
@@ -0,0 +1,380 @@
+// Package geoip implements a minimal reader for MaxMind DB (mmdb) files, as used
+// by the GeoLite2 and GeoIP2 country and city databases, sufficient to tag peers
+// with their country without any online lookups.
+package geoip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net"
+)
+
+// metadataMarker precedes the metadata section at the end of the file.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+const (
+	metadataMaxSize = 128 * 1024 // The metadata section is within the last 128KiB
+	dataSeparator   = 16         // Zero bytes between the search tree and the data section
+	maxDecodeDepth  = 32         // Nesting limit guarding against malformed files
+)
+
+var (
+	errInvalidDatabase = errors.New("invalid MaxMind DB")
+	errNotFound        = errors.New("address not found")
+)
+
+// Metadata describes the layout of a MaxMind DB.
+type Metadata struct {
+	DatabaseType string
+	IPVersion    uint
+	NodeCount    uint
+	RecordSize   uint
+	BuildEpoch   uint64
+}
+
+// Reader looks up records in a MaxMind DB held in memory.
+type Reader struct {
+	Metadata Metadata
+
+	tree      []byte
+	data      []byte
+	ipv4Start uint
+}
+
+// Open reads the MaxMind DB at path.
+func Open(path string) (*Reader, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(buf)
+}
+
+// New creates a reader of the MaxMind DB contained in buf.
+func New(buf []byte) (*Reader, error) {
+	start := 0
+	if len(buf) > metadataMaxSize {
+		start = len(buf) - metadataMaxSize
+	}
+	idx := bytes.LastIndex(buf[start:], metadataMarker)
+	if idx < 0 {
+		return nil, fmt.Errorf("%v: metadata not found", errInvalidDatabase)
+	}
+	metaStart := start + idx + len(metadataMarker)
+	raw, _, err := (&decoder{buf: buf[metaStart:]}).decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%v: bad metadata: %v", errInvalidDatabase, err)
+	}
+	meta, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%v: bad metadata", errInvalidDatabase)
+	}
+	r := &Reader{Metadata: Metadata{
+		DatabaseType: stringField(meta, "database_type"),
+		IPVersion:    uint(uintField(meta, "ip_version")),
+		NodeCount:    uint(uintField(meta, "node_count")),
+		RecordSize:   uint(uintField(meta, "record_size")),
+		BuildEpoch:   uintField(meta, "build_epoch"),
+	}}
+	switch r.Metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%v: unsupported record size %d", errInvalidDatabase, r.Metadata.RecordSize)
+	}
+	if r.Metadata.IPVersion != 4 && r.Metadata.IPVersion != 6 {
+		return nil, fmt.Errorf("%v: unsupported IP version %d", errInvalidDatabase, r.Metadata.IPVersion)
+	}
+	treeSize := r.Metadata.NodeCount * r.Metadata.RecordSize / 4
+	dataStart := treeSize + dataSeparator
+	if dataStart > uint(start+idx) {
+		return nil, fmt.Errorf("%v: search tree exceeds file", errInvalidDatabase)
+	}
+	r.tree = buf[:treeSize]
+	r.data = buf[dataStart : start+idx]
+
+	// IPv4 addresses are looked up below ::/96 in IPv6 databases
+	if r.Metadata.IPVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.Metadata.NodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// Lookup returns the record stored for ip.
+func (r *Reader) Lookup(ip net.IP) (map[string]interface{}, error) {
+	node, bits := r.ipv4Start, 32
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	} else if r.Metadata.IPVersion == 4 {
+		return nil, fmt.Errorf("IPv6 address %v in IPv4 database", ip)
+	} else {
+		node, bits = 0, 128
+	}
+	for i := 0; i < bits && node < r.Metadata.NodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i%8))) & 1
+		node = r.record(node, bit)
+	}
+	if node <= r.Metadata.NodeCount {
+		return nil, errNotFound
+	}
+	offset := node - r.Metadata.NodeCount - dataSeparator
+	if offset >= uint(len(r.data)) {
+		return nil, fmt.Errorf("%v: data pointer out of range", errInvalidDatabase)
+	}
+	raw, _, err := (&decoder{buf: r.data}).decode(offset, 0)
+	if err != nil {
+		return nil, err
+	}
+	record, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%v: record is not a map", errInvalidDatabase)
+	}
+	return record, nil
+}
+
+// Country returns the ISO 3166-1 country code of ip, falling back to the
+// country the address block is registered in.
+func (r *Reader) Country(ip net.IP) (string, error) {
+	record, err := r.Lookup(ip)
+	if err != nil {
+		return "", err
+	}
+	for _, key := range []string{"country", "registered_country"} {
+		if country, ok := record[key].(map[string]interface{}); ok {
+			if code := stringField(country, "iso_code"); code != "" {
+				return code, nil
+			}
+		}
+	}
+	return "", errNotFound
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a search tree node.
+func (r *Reader) record(node, bit uint) uint {
+	switch r.Metadata.RecordSize {
+	case 24:
+		b := r.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		b := r.tree[node*8+bit*4:]
+		return uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3])
+	}
+}
+
+// Data section field types.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// decoder decodes values of a MaxMind DB data section.
+type decoder struct {
+	buf []byte
+}
+
+// decode decodes the value at offset, returning it along with the offset of the
+// value following it.
+func (d *decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, fmt.Errorf("%v: data nested too deeply", errInvalidDatabase)
+	}
+	ctrl, err := d.byteAt(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset++
+
+	kind := uint(ctrl >> 5)
+	if kind == typeExtended {
+		ext, err := d.byteAt(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		kind, offset = 7+uint(ext), offset+1
+	}
+	if kind == typePointer {
+		pointer, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(pointer, depth+1)
+		return value, next, err
+	}
+	size, offset, err := d.size(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch kind {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var key, value interface{}
+			if key, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%v: map key is not a string", errInvalidDatabase)
+			}
+			if value, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			m[name] = value
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var value interface{}
+			if value, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+	b, err := d.bytes(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += size
+
+	switch kind {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte(nil), b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%v: invalid double size %d", errInvalidDatabase, size)
+		}
+		return math.Float64frombits(uint64(beUint(b))), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%v: invalid float size %d", errInvalidDatabase, size)
+		}
+		return float64(math.Float32frombits(uint32(beUint(b)))), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("%v: invalid integer size %d", errInvalidDatabase, size)
+		}
+		return beUint(b), offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%v: invalid integer size %d", errInvalidDatabase, size)
+		}
+		return int64(int32(uint32(beUint(b)))), offset, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(b), offset, nil
+	}
+	return nil, 0, fmt.Errorf("%v: unknown data type %d", errInvalidDatabase, kind)
+}
+
+// size decodes the payload size encoded in the control byte and the bytes
+// following it.
+func (d *decoder) size(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1F)
+	if size < 29 {
+		return size, offset, nil
+	}
+	n := size - 28
+	b, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch n {
+	case 1:
+		size = 29 + uint(beUint(b))
+	case 2:
+		size = 285 + uint(beUint(b))
+	default:
+		size = 65821 + uint(beUint(b))
+	}
+	return size, offset + n, nil
+}
+
+// pointer decodes a pointer into the data section.
+func (d *decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3)&0x3 + 1
+	b, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	prefix := uint(ctrl & 0x7)
+	var pointer uint
+	switch n {
+	case 1:
+		pointer = prefix<<8 | uint(beUint(b))
+	case 2:
+		pointer = (prefix<<16 | uint(beUint(b))) + 2048
+	case 3:
+		pointer = (prefix<<24 | uint(beUint(b))) + 526336
+	default:
+		pointer = uint(beUint(b))
+	}
+	return pointer, offset + n, nil
+}
+
+func (d *decoder) byteAt(offset uint) (byte, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, fmt.Errorf("%v: unexpected end of data", errInvalidDatabase)
+	}
+	return d.buf[offset], nil
+}
+
+func (d *decoder) bytes(offset, size uint) ([]byte, error) {
+	if offset+size > uint(len(d.buf)) {
+		return nil, fmt.Errorf("%v: unexpected end of data", errInvalidDatabase)
+	}
+	return d.buf[offset : offset+size], nil
+}
+
+// beUint decodes a big endian unsigned integer of up to 8 bytes.
+func beUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func uintField(m map[string]interface{}, key string) uint64 {
+	v, _ := m[key].(uint64)
+	return v
+}
@@ -0,0 +1,138 @@
+package geoip
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"testing"
+)
+
+// encode serializes a value in the MaxMind DB data section format. Only the
+// types needed by the tests are supported.
+func encode(v interface{}) []byte {
+	ctrl := func(kind, size int) []byte {
+		if kind > 7 {
+			return []byte{byte(size), byte(kind - 7)}
+		}
+		return []byte{byte(kind<<5 | size)}
+	}
+	switch v := v.(type) {
+	case string:
+		return append(ctrl(typeString, len(v)), v...)
+	case uint16:
+		return append(ctrl(typeUint16, 2), byte(v>>8), byte(v))
+	case uint32:
+		return append(ctrl(typeUint32, 4), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := ctrl(typeMap, len(v))
+		for _, k := range keys {
+			out = append(out, encode(k)...)
+			out = append(out, encode(v[k])...)
+		}
+		return out
+	}
+	panic("unsupported type")
+}
+
+// buildDatabase creates an IPv4 database with 24 bit records mapping each of
+// the given /8 networks to a record with the given country code.
+func buildDatabase(networks map[byte]string) []byte {
+	// Data section: one record per country
+	var (
+		data    []byte
+		offsets = make(map[string]int)
+	)
+	for _, country := range networks {
+		if _, ok := offsets[country]; ok {
+			continue
+		}
+		offsets[country] = len(data)
+		data = append(data, encode(map[string]interface{}{
+			"country": map[string]interface{}{"iso_code": country},
+		})...)
+	}
+	// Search tree: a full binary tree over the first 8 bits
+	const nodeCount = 255
+	tree := make([]byte, nodeCount*6)
+	put := func(node, bit, value int) {
+		b := tree[node*6+bit*3:]
+		b[0], b[1], b[2] = byte(value>>16), byte(value>>8), byte(value)
+	}
+	for node := 0; node < 127; node++ {
+		put(node, 0, 2*node+1)
+		put(node, 1, 2*node+2)
+	}
+	for node := 127; node < nodeCount; node++ {
+		for bit := 0; bit < 2; bit++ {
+			prefix := byte((node-127)<<1 | bit)
+			if country, ok := networks[prefix]; ok {
+				put(node, bit, nodeCount+dataSeparator+offsets[country])
+			} else {
+				put(node, bit, nodeCount)
+			}
+		}
+	}
+	meta := encode(map[string]interface{}{
+		"database_type": "Test-Country",
+		"ip_version":    uint16(4),
+		"node_count":    uint32(nodeCount),
+		"record_size":   uint16(24),
+	})
+	var buf bytes.Buffer
+	buf.Write(tree)
+	buf.Write(make([]byte, dataSeparator))
+	buf.Write(data)
+	buf.Write(metadataMarker)
+	buf.Write(meta)
+	return buf.Bytes()
+}
+
+func TestCountry(t *testing.T) {
+	db, err := New(buildDatabase(map[byte]string{1: "DE", 5: "US", 200: "DE"}))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if db.Metadata.DatabaseType != "Test-Country" || db.Metadata.NodeCount != 255 {
+		t.Errorf("metadata mismatch: %+v", db.Metadata)
+	}
+	tests := []struct {
+		ip      string
+		country string
+	}{
+		{"1.2.3.4", "DE"},
+		{"5.255.0.1", "US"},
+		{"200.0.0.0", "DE"},
+		{"2.2.3.4", ""},
+		{"::ffff:5.1.1.1", "US"},
+	}
+	for _, tt := range tests {
+		country, err := db.Country(net.ParseIP(tt.ip))
+		if tt.country == "" {
+			if err != errNotFound {
+				t.Errorf("%s: expected not found, got %q, %v", tt.ip, country, err)
+			}
+			continue
+		}
+		if err != nil || country != tt.country {
+			t.Errorf("%s: country mismatch: got %q, %v, want %q", tt.ip, country, err, tt.country)
+		}
+	}
+	if _, err := db.Country(net.ParseIP("2001:db8::1")); err == nil {
+		t.Error("expected error looking up IPv6 address in IPv4 database")
+	}
+}
+
+func TestInvalidDatabase(t *testing.T) {
+	if _, err := New([]byte("not a database")); err == nil {
+		t.Error("expected error for missing metadata")
+	}
+	valid := buildDatabase(map[byte]string{1: "DE"})
+	if _, err := New(valid[1000:]); err == nil {
+		t.Error("expected error for truncated search tree")
+	}
+}
@@ -86,44 +86,103 @@ func Parse(spec string) (Interface, error) {
 }
 
 const (
-	mapTimeout        = 20 * time.Minute
-	mapUpdateInterval = 15 * time.Minute
+	mapTimeout          = 20 * time.Minute
+	mapUpdateInterval   = 15 * time.Minute
+	mapValidateInterval = 2 * time.Minute
 )
 
+// MappingStatus describes the last known state of a port mapping.
+type MappingStatus struct {
+	Mechanism   string    `json:"mechanism"`   // Name of the port mapping mechanism in use
+	Protocol    string    `json:"protocol"`    // "tcp" or "udp"
+	Name        string    `json:"name"`        // Display name of the mapping
+	ExternalIP  string    `json:"externalIP"`  // External IP last reported by the gateway
+	ExtPort     int       `json:"extPort"`     // Port advertised to the Internet
+	IntPort     int       `json:"intPort"`     // Locally bound port
+	LastMapped  time.Time `json:"lastMapped"`  // Time the mapping was last (re-)added successfully
+	LastChecked time.Time `json:"lastChecked"` // Time the mapping was last validated
+	Error       string    `json:"error"`       // Last mapping error, empty if healthy
+}
+
+// Mapper keeps a single port mapping alive. Besides refreshing the mapping before
+// its lifetime ends, it periodically re-validates the external IP reported by the
+// gateway and re-adds the mapping whenever the gateway appears to have lost it
+// (e.g. after a router reboot) or the last attempt to add it failed.
+type Mapper struct {
+	m        Interface
+	protocol string
+	extport  int
+	intport  int
+	name     string
+
+	remap chan chan error
+
+	mu     sync.Mutex
+	status MappingStatus
+	mapped bool // Whether the last attempt to add the mapping succeeded
+}
+
+// NewMapper creates a mapper for the given port. The mapping is only added
+// once Run is called.
+func NewMapper(m Interface, protocol string, extport, intport int, name string) *Mapper {
+	return &Mapper{
+		m:        m,
+		protocol: protocol,
+		extport:  extport,
+		intport:  intport,
+		name:     name,
+		remap:    make(chan chan error),
+		status: MappingStatus{
+			Mechanism: m.String(),
+			Protocol:  protocol,
+			Name:      name,
+			ExtPort:   extport,
+			IntPort:   intport,
+		},
+	}
+}
+
 // Map adds a port mapping on m and keeps it alive until c is closed.
 // This function is typically invoked in its own goroutine.
 func Map(m Interface, c chan struct{}, protocol string, extport, intport int, name string) {
+	NewMapper(m, protocol, extport, intport, name).Run(c)
+}
+
+// Status returns a snapshot of the mapping state.
+func (mp *Mapper) Status() MappingStatus {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	status := mp.status
+	status.Mechanism = mp.m.String()
+	return status
+}
+
+// Remap forces the mapping to be re-added immediately. It returns an error if
+// the mapper isn't running or the gateway rejected the mapping.
+func (mp *Mapper) Remap() error {
+	errc := make(chan error, 1)
+	select {
+	case mp.remap <- errc:
+		return <-errc
+	case <-time.After(time.Second):
+		return errors.New("port mapper not running")
+	}
+}
+
+// Run adds the port mapping and keeps it alive until c is closed.
+func (mp *Mapper) Run(c chan struct{}) {
 	refresh := time.NewTimer(mapUpdateInterval)
+	validate := time.NewTicker(mapValidateInterval)
 
 	defer func() {
 		refresh.Stop()
-		glog.V(logger.Debug).Infof("Deleting port mapping: %s %d -> %d (%s) using %s\n", protocol, extport, intport, name, m)
-		m.DeleteMapping(protocol, extport, intport)
+		validate.Stop()
+		glog.V(logger.Debug).Infof("Deleting port mapping: %s %d -> %d (%s) using %s\n", mp.protocol, mp.extport, mp.intport, mp.name, mp.m)
+		mp.m.DeleteMapping(mp.protocol, mp.extport, mp.intport)
 	}()
 
-	refreshPortMappingLabel := "Refresh port mapping"
-
-	handleIfAddMappingErr := func(label string, err error) {
-		if err == nil {
-			glog.V(logger.Debug).Infof("%s %s:%d -> %d (%s) using %s\n", label, protocol, extport, intport, name, m)
-			// eg on start up
-			if label != refreshPortMappingLabel {
-				glog.D(logger.Info).Infof("%s %s:%s -> %s (%s) using %s\n", label, logger.ColorGreen(protocol), logger.ColorGreen(strconv.Itoa(extport)), logger.ColorGreen(strconv.Itoa(intport)), name, m)
-			}
-		} else {
-			switch m.String() {
-			// if upnp error, not critical
-			case autoDiscUPnPOrNatPMP:
-				glog.V(logger.Debug).Infof("%s: Network port %s:%d could not be mapped: %v\n", label, protocol, intport, err)
-				return
-			default:
-				glog.V(logger.Error).Errorf("%s: Network port %s:%d could not be mapped: %v\n", label, protocol, intport, err)
-			}
-		}
-	}
-
-	err := m.AddMapping(protocol, intport, extport, name, mapTimeout)
-	handleIfAddMappingErr("Mapping network port", err)
+	mp.add("Mapping network port")
 
 	for {
 		select {
@@ -132,14 +191,87 @@ func Map(m Interface, c chan struct{}, protocol string, extport, intport int, na
 				return
 			}
 		case <-refresh.C:
-			glog.V(logger.Debug).Infof("Refresh port mapping %s:%d -> %d (%s) using %s\n", protocol, extport, intport, name, m)
-			err := m.AddMapping(protocol, intport, extport, name, mapTimeout)
-			handleIfAddMappingErr(refreshPortMappingLabel, err)
+			glog.V(logger.Debug).Infof("Refresh port mapping %s:%d -> %d (%s) using %s\n", mp.protocol, mp.extport, mp.intport, mp.name, mp.m)
+			mp.add(refreshPortMappingLabel)
+			refresh.Reset(mapUpdateInterval)
+		case <-validate.C:
+			if !mp.validate() {
+				mp.add("Re-mapping network port")
+				refresh.Reset(mapUpdateInterval)
+			}
+		case errc := <-mp.remap:
+			errc <- mp.add("Re-mapping network port")
 			refresh.Reset(mapUpdateInterval)
 		}
 	}
 }
 
+const refreshPortMappingLabel = "Refresh port mapping"
+
+// add (re-)adds the port mapping and records the outcome.
+func (mp *Mapper) add(label string) error {
+	err := mp.m.AddMapping(mp.protocol, mp.intport, mp.extport, mp.name, mapTimeout)
+
+	mp.mu.Lock()
+	now := time.Now()
+	mp.status.LastChecked = now
+	mp.mapped = err == nil
+	if err == nil {
+		mp.status.LastMapped = now
+		mp.status.Error = ""
+	} else {
+		mp.status.Error = err.Error()
+	}
+	mp.mu.Unlock()
+
+	if err == nil {
+		glog.V(logger.Debug).Infof("%s %s:%d -> %d (%s) using %s\n", label, mp.protocol, mp.extport, mp.intport, mp.name, mp.m)
+		// eg on start up
+		if label != refreshPortMappingLabel {
+			glog.D(logger.Info).Infof("%s %s:%s -> %s (%s) using %s\n", label, logger.ColorGreen(mp.protocol), logger.ColorGreen(strconv.Itoa(mp.extport)), logger.ColorGreen(strconv.Itoa(mp.intport)), mp.name, mp.m)
+		}
+		mp.validate()
+		return nil
+	}
+	switch mp.m.String() {
+	// if upnp error, not critical
+	case autoDiscUPnPOrNatPMP:
+		glog.V(logger.Debug).Infof("%s: Network port %s:%d could not be mapped: %v\n", label, mp.protocol, mp.intport, err)
+	default:
+		glog.V(logger.Error).Errorf("%s: Network port %s:%d could not be mapped: %v\n", label, mp.protocol, mp.intport, err)
+	}
+	return err
+}
+
+// validate queries the gateway's external IP and reports whether the mapping
+// still looks healthy. A changed external IP is taken as a sign that the gateway
+// dropped its state and the mapping needs to be re-added. A failed query is only
+// recorded: the gateway may just be slow to answer, and re-adding the mapping on
+// every check wouldn't help, the regular refresh keeps it alive meanwhile.
+func (mp *Mapper) validate() bool {
+	ip, err := mp.m.ExternalIP()
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.status.LastChecked = time.Now()
+	if err != nil {
+		mp.status.Error = err.Error()
+		return true
+	}
+	prev := mp.status.ExternalIP
+	mp.status.ExternalIP = ip.String()
+	if prev != "" && prev != mp.status.ExternalIP {
+		glog.V(logger.Warn).Warnf("External IP changed from %s to %s (%s)", prev, mp.status.ExternalIP, mp.m)
+		glog.D(logger.Warn).Warnf("External IP changed from %s to %s", logger.ColorYellow(prev), logger.ColorGreen(mp.status.ExternalIP))
+		return false
+	}
+	if mp.mapped {
+		mp.status.Error = ""
+	}
+	return mp.mapped
+}
+
 // ExtIP assumes that the local machine is reachable on the given
 // external IP address, and that any required ports were mapped manually.
 // Mapping operations will not return an error but won't actually do anything.
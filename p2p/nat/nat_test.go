@@ -18,6 +18,7 @@ package nat
 
 import (
 	"bytes"
+	"errors"
 	"net"
 	"testing"
 	"time"
@@ -62,3 +63,78 @@ func TestAutoDiscRace(t *testing.T) {
 		}
 	}
 }
+
+type countingMapper struct {
+	extIP
+	added chan struct{}
+}
+
+func (m *countingMapper) AddMapping(string, int, int, string, time.Duration) error {
+	m.added <- struct{}{}
+	return nil
+}
+
+func TestMapperRemap(t *testing.T) {
+	m := &countingMapper{extIP: extIP{33, 44, 55, 66}, added: make(chan struct{}, 2)}
+	mp := NewMapper(m, "tcp", 30303, 30303, "test")
+
+	quit := make(chan struct{})
+	defer close(quit)
+	go mp.Run(quit)
+
+	<-m.added
+	if err := mp.Remap(); err != nil {
+		t.Fatalf("remap failed: %v", err)
+	}
+	<-m.added
+
+	status := mp.Status()
+	if status.ExternalIP != "33.44.55.66" {
+		t.Errorf("external IP mismatch: got %q", status.ExternalIP)
+	}
+	if status.LastMapped.IsZero() || status.Error != "" {
+		t.Errorf("mapping not recorded as healthy: %+v", status)
+	}
+}
+
+// failingIPMapper is a mapper whose gateway fails to report its external IP.
+type failingIPMapper struct {
+	countingMapper
+	fail bool
+}
+
+func (m *failingIPMapper) ExternalIP() (net.IP, error) {
+	if m.fail {
+		return nil, errors.New("gateway timeout")
+	}
+	return m.countingMapper.ExternalIP()
+}
+
+// Tests that a failing external IP query keeps the existing mapping instead of
+// re-adding it on every validation.
+func TestMapperValidateIPFailure(t *testing.T) {
+	m := &failingIPMapper{countingMapper: countingMapper{extIP: extIP{33, 44, 55, 66}, added: make(chan struct{}, 1)}}
+	mp := NewMapper(m, "tcp", 30303, 30303, "test")
+
+	if err := mp.add("Mapping network port"); err != nil {
+		t.Fatalf("failed to add mapping: %v", err)
+	}
+	<-m.added
+
+	m.fail = true
+	for i := 0; i < 3; i++ {
+		if !mp.validate() {
+			t.Fatalf("validation %d: mapping re-added after failed IP query", i)
+		}
+	}
+	if status := mp.Status(); status.Error != "gateway timeout" || status.ExternalIP != "33.44.55.66" {
+		t.Errorf("failed IP query not recorded: %+v", status)
+	}
+	m.fail = false
+	if !mp.validate() {
+		t.Fatalf("mapping re-added after IP query recovered")
+	}
+	if status := mp.Status(); status.Error != "" {
+		t.Errorf("recovered mapping not recorded as healthy: %+v", status)
+	}
+}
@@ -0,0 +1,23 @@
+package ntp
+
+import (
+	"github.com/openether/ethcore/logger"
+)
+
+var mlogClock = logger.MLogRegisterAvailable("clock", mLogLinesClock)
+
+var mLogLinesClock = []*logger.MLogT{
+	mlogClockDrift,
+}
+
+var mlogClockDrift = &logger.MLogT{
+	Description: "Called when a periodic NTP check finds the local clock drifting beyond the allowed threshold.",
+	Receiver:    "CLOCK",
+	Verb:        "DETECT",
+	Subject:     "DRIFT",
+	Details: []logger.MLogDetailT{
+		{Owner: "CLOCK", Key: "SERVER", Value: "STRING"},
+		{Owner: "DRIFT", Key: "DURATION", Value: "DURATION"},
+		{Owner: "DRIFT", Key: "THRESHOLD", Value: "DURATION"},
+	},
+}
@@ -0,0 +1,124 @@
+package ntp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openether/ethcore/logger"
+	"github.com/openether/ethcore/logger/glog"
+)
+
+const monitorChecks = 3 // Number of measurements to do against the NTP server per check
+
+// Status is the outcome of the last clock drift check of a Monitor.
+type Status struct {
+	Server    string        `json:"server"`
+	Drift     time.Duration `json:"drift"`     // Measured offset of the local clock, positive if ahead
+	Threshold time.Duration `json:"threshold"` // Allowed drift before warning
+	Skewed    bool          `json:"skewed"`    // Drift exceeded the threshold
+	Checked   time.Time     `json:"checked"`   // Time of the last check, zero if none completed yet
+	Error     string        `json:"error,omitempty"`
+}
+
+// Monitor periodically measures the drift of the local clock against an NTP
+// server and warns when it exceeds a threshold, since clock skew silently
+// breaks block timestamp validation and peer handshakes.
+type Monitor struct {
+	server    string
+	interval  time.Duration
+	threshold time.Duration
+	drift     func() (time.Duration, error)
+
+	lock   sync.RWMutex
+	status Status
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewMonitor creates a clock drift monitor checking against server every
+// interval. It must be started with Start.
+func NewMonitor(server string, interval, threshold time.Duration) *Monitor {
+	m := &Monitor{
+		server:    server,
+		interval:  interval,
+		threshold: threshold,
+		status:    Status{Server: server, Threshold: threshold},
+	}
+	m.drift = func() (time.Duration, error) { return Drift(server, monitorChecks) }
+	return m
+}
+
+// Start launches the background checks, the first one immediately.
+func (m *Monitor) Start() {
+	m.quit = make(chan struct{})
+	m.wg.Add(1)
+	go m.loop()
+}
+
+// Stop terminates the background checks.
+func (m *Monitor) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// Status returns the outcome of the last check.
+func (m *Monitor) Status() Status {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.status
+}
+
+func (m *Monitor) loop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.check()
+		select {
+		case <-ticker.C:
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// check measures the clock drift once, updating the status and warning if the
+// drift is beyond the threshold.
+func (m *Monitor) check() {
+	drift, err := m.drift()
+
+	m.lock.Lock()
+	if err != nil {
+		// Keep the last measurement, NTP may just be unreachable for now
+		m.status.Error = err.Error()
+		m.lock.Unlock()
+		glog.V(logger.Debug).Infof("NTP clock drift check against %s failed: %v", m.server, err)
+		return
+	}
+	skewed := drift < -m.threshold || drift > m.threshold
+	m.status.Drift, m.status.Skewed, m.status.Checked, m.status.Error = drift, skewed, time.Now(), ""
+	m.lock.Unlock()
+
+	if !skewed {
+		glog.V(logger.Debug).Infof("NTP clock drift check reported %v drift, all ok", drift)
+		return
+	}
+	warning := fmt.Sprintf("System clock seems off by %v, which can prevent network connectivity and block validation", drift)
+	separator := strings.Repeat("-", len(warning))
+
+	glog.V(logger.Warn).Warnln(separator)
+	glog.V(logger.Warn).Warnln(warning)
+	glog.V(logger.Warn).Warnln("Please enable network time synchronisation in system settings")
+	glog.V(logger.Warn).Warnln(separator)
+
+	if logger.MlogEnabled() {
+		mlogClockDrift.AssignDetails(
+			m.server,
+			drift,
+			m.threshold,
+		).Send(mlogClock)
+	}
+}
@@ -0,0 +1,67 @@
+package ntp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMonitorCheck(t *testing.T) {
+	m := NewMonitor("ntp.example.org", time.Hour, 10*time.Second)
+
+	var (
+		drift time.Duration
+		err   error
+	)
+	m.drift = func() (time.Duration, error) { return drift, err }
+
+	if status := m.Status(); !status.Checked.IsZero() || status.Skewed {
+		t.Fatalf("unexpected status before first check: %+v", status)
+	}
+
+	drift = 2 * time.Second
+	m.check()
+	if status := m.Status(); status.Drift != drift || status.Skewed || status.Checked.IsZero() {
+		t.Errorf("status mismatch after small drift: %+v", status)
+	}
+
+	drift = -time.Minute
+	m.check()
+	if status := m.Status(); status.Drift != drift || !status.Skewed {
+		t.Errorf("status mismatch after large drift: %+v", status)
+	}
+
+	// Failing checks keep the last measurement.
+	err = errors.New("unreachable")
+	m.check()
+	if status := m.Status(); status.Drift != -time.Minute || !status.Skewed || status.Error != "unreachable" {
+		t.Errorf("status mismatch after failed check: %+v", status)
+	}
+
+	err, drift = nil, 0
+	m.check()
+	if status := m.Status(); status.Skewed || status.Error != "" {
+		t.Errorf("status mismatch after recovery: %+v", status)
+	}
+}
+
+func TestMonitorLoop(t *testing.T) {
+	m := NewMonitor("ntp.example.org", 10*time.Millisecond, time.Second)
+	checks := make(chan struct{}, 16)
+	m.drift = func() (time.Duration, error) {
+		select {
+		case checks <- struct{}{}:
+		default:
+		}
+		return 0, nil
+	}
+	m.Start()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-checks:
+		case <-time.After(time.Second):
+			t.Fatalf("check %d not run", i)
+		}
+	}
+	m.Stop()
+}
@@ -7,11 +7,13 @@ import (
 	"net"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/openether/ethcore/event"
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/metrics"
 	"github.com/openether/ethcore/p2p/discover"
 	"github.com/openether/ethcore/rlp"
 )
@@ -78,8 +80,19 @@ type PeerEvent struct {
 	MsgSize  *uint32         `json:"msg_size,omitempty"`
 }
 
+// MsgFault classifies the messages of a peer that couldn't be handled.
+type MsgFault int
+
+const (
+	MsgMalformed MsgFault = iota // Undecodable or invalid for its message code
+	MsgOversized                 // Above the size limit of its protocol
+)
+
 // Peer represents a connected remote node.
 type Peer struct {
+	malformed, oversized uint64        // Faulty messages received, accessed atomically
+	faults               *faultRecords // Faults of the node over all its connections, nil if not tracked
+
 	rw      *conn
 	running map[string]*protoRW
 
@@ -140,6 +153,23 @@ func (p *Peer) String() string {
 	return fmt.Sprintf("Peer id=%x addr=%v name=%s", p.rw.id[:8], p.RemoteAddr(), p.Name())
 }
 
+// ReportMsgFault records that the peer sent a message that couldn't be handled,
+// for protocols to report the messages they reject. The node is banned for a
+// while unless it's static or trusted.
+func (p *Peer) ReportMsgFault(fault MsgFault) {
+	if p.faults != nil && !p.rw.is(trustedConn|staticDialedConn) {
+		p.faults.add(p.ID(), fault, time.Now())
+	}
+	switch fault {
+	case MsgMalformed:
+		atomic.AddUint64(&p.malformed, 1)
+		metrics.P2PMalformedMsgs.Mark(1)
+	case MsgOversized:
+		atomic.AddUint64(&p.oversized, 1)
+		metrics.P2POversizedMsgs.Mark(1)
+	}
+}
+
 // Inbound returns true if the peer is an inbound connection
 func (p *Peer) Inbound() bool {
 	return p.rw.flags&inboundConn != 0
@@ -237,6 +267,9 @@ func (p *Peer) readLoop(errc chan<- error) {
 	for {
 		msg, err := p.rw.ReadMsg()
 		if err != nil {
+			if _, ok := err.(*malformedMsgError); ok {
+				p.ReportMsgFault(MsgMalformed)
+			}
 			errc <- err
 			return
 		}
@@ -266,6 +299,7 @@ func (p *Peer) handle(msg Msg) error {
 		// it's a subprotocol message
 		proto, err := p.getProto(msg.Code)
 		if err != nil {
+			p.ReportMsgFault(MsgMalformed)
 			return fmt.Errorf("msg code out of range: %v", msg.Code)
 		}
 		select {
@@ -391,9 +425,11 @@ func (rw *protoRW) ReadMsg() (Msg, error) {
 // peer. Sub-protocol independent fields are contained and initialized here, with
 // protocol specifics delegated to all connected sub-protocols.
 type PeerInfo struct {
-	ID      string   `json:"id"`   // Unique node identifier (also the encryption key)
-	Name    string   `json:"name"` // Name of the node, including client type, version, OS, custom data
-	Caps    []string `json:"caps"` // Sum-protocols advertised by this particular peer
+	ID      string     `json:"id"`                // Unique node identifier (also the encryption key)
+	Name    string     `json:"name"`              // Name of the node, including client type, version, OS, custom data
+	Client  ClientInfo `json:"client"`            // Client software parsed from the name
+	Country string     `json:"country,omitempty"` // Country of the remote address, if a GeoIP database is configured
+	Caps    []string   `json:"caps"`              // Sum-protocols advertised by this particular peer
 	Network struct {
 		LocalAddress  string `json:"localAddress"`  // Local endpoint of the TCP data connection
 		RemoteAddress string `json:"remoteAddress"` // Remote endpoint of the TCP data connection
@@ -401,7 +437,9 @@ type PeerInfo struct {
 		Trusted       bool   `json:"trusted"`
 		Static        bool   `json:"static"`
 	} `json:"network"`
-	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
+	MalformedMsgs uint64                 `json:"malformedMsgs"` // Messages received that couldn't be decoded, over all connections of the node
+	OversizedMsgs uint64                 `json:"oversizedMsgs"` // Messages received above the size limit, over all connections of the node
+	Protocols     map[string]interface{} `json:"protocols"`     // Sub-protocol specific metadata fields
 }
 
 // Info gathers and returns a collection of metadata known about a peer.
@@ -415,6 +453,7 @@ func (p *Peer) Info() *PeerInfo {
 	info := &PeerInfo{
 		ID:        p.ID().String(),
 		Name:      p.Name(),
+		Client:    ParseClientName(p.Name()),
 		Caps:      caps,
 		Protocols: make(map[string]interface{}),
 	}
@@ -423,6 +462,14 @@ func (p *Peer) Info() *PeerInfo {
 	info.Network.Inbound = p.rw.is(inboundConn)
 	info.Network.Trusted = p.rw.is(trustedConn)
 	info.Network.Static = p.rw.is(staticDialedConn)
+	info.MalformedMsgs = atomic.LoadUint64(&p.malformed)
+	info.OversizedMsgs = atomic.LoadUint64(&p.oversized)
+	if p.faults != nil {
+		// Include the faults of earlier connections
+		if malformed, oversized := p.faults.counts(p.ID()); malformed+oversized > 0 {
+			info.MalformedMsgs, info.OversizedMsgs = malformed, oversized
+		}
+	}
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
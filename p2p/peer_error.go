@@ -33,6 +33,7 @@ var errorToString = map[int]string{
 type peerError struct {
 	code    int
 	message string
+	cause   error // Error the peer error originates from, if any
 }
 
 func newPeerError(code int, format string, v ...interface{}) *peerError {
@@ -40,7 +41,7 @@ func newPeerError(code int, format string, v ...interface{}) *peerError {
 	if !ok {
 		panic("invalid error code")
 	}
-	err := &peerError{code, desc}
+	err := &peerError{code: code, message: desc}
 	if format != "" {
 		err.message += ": " + fmt.Sprintf(format, v...)
 	}
@@ -51,6 +52,11 @@ func (self *peerError) Error() string {
 	return self.message
 }
 
+// Cause returns the error the peer error originates from, or nil.
+func (self *peerError) Cause() error {
+	return self.cause
+}
+
 type DiscReason uint
 
 const (
@@ -111,6 +111,22 @@ func TestPeerDisconnect(t *testing.T) {
 	}
 }
 
+func TestPeerMalformedMsg(t *testing.T) {
+	closer, rw, peer, disc := testPeer(nil)
+	defer closer()
+	if err := SendItems(rw, baseProtocolLength+1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-disc:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("peer did not return")
+	}
+	if info := peer.Info(); info.MalformedMsgs != 1 || info.OversizedMsgs != 0 {
+		t.Errorf("faults mismatch: got %d malformed, %d oversized, want 1, 0", info.MalformedMsgs, info.OversizedMsgs)
+	}
+}
+
 // This test is supposed to verify that Peer can reliably handle
 // multiple causes of disconnection occurring at the same time.
 func TestPeerDisconnectRace(t *testing.T) {
@@ -0,0 +1,111 @@
+package p2p
+
+import (
+	"net"
+	"strings"
+)
+
+// ClientInfo describes the client software of a peer, as parsed from the name
+// it advertised in the protocol handshake, e.g. "Geth/v1.8.2-stable/linux-amd64/go1.10".
+type ClientInfo struct {
+	Name    string `json:"name"`    // Client implementation, e.g. "Geth"
+	Version string `json:"version"` // Release version, e.g. "v1.8.2-stable"
+	OS      string `json:"os"`      // Platform the client was built for
+	Runtime string `json:"runtime"` // Language runtime, e.g. "go1.10"
+}
+
+// ParseClientName parses the name advertised by a peer. Custom identities
+// inserted between the client name and version are skipped; unknown parts are
+// left empty.
+func ParseClientName(name string) ClientInfo {
+	parts := strings.Split(name, "/")
+	info := ClientInfo{Name: parts[0]}
+	for i := 1; i < len(parts); i++ {
+		if !isVersion(parts[i]) {
+			continue
+		}
+		info.Version = parts[i]
+		if i+1 < len(parts) {
+			info.OS = parts[i+1]
+		}
+		if i+2 < len(parts) {
+			info.Runtime = parts[i+2]
+		}
+		break
+	}
+	return info
+}
+
+// isVersion reports whether s looks like a release version, e.g. "v1.8.2".
+func isVersion(s string) bool {
+	s = strings.TrimPrefix(s, "v")
+	return len(s) > 0 && s[0] >= '0' && s[0] <= '9' && strings.Contains(s, ".")
+}
+
+// shortVersion strips build metadata from a version, e.g. "v1.8.2-stable-b8b9f7f4"
+// becomes "v1.8.2".
+func shortVersion(version string) string {
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		return version[:i]
+	}
+	return version
+}
+
+// PeerStats aggregates the connected peers by client software and location.
+type PeerStats struct {
+	Peers     int            `json:"peers"`
+	Inbound   int            `json:"inbound"`
+	Clients   map[string]int `json:"clients"`             // Peer count by client name
+	Versions  map[string]int `json:"versions"`            // Peer count by client name and version
+	OS        map[string]int `json:"os"`                  // Peer count by client platform
+	Countries map[string]int `json:"countries,omitempty"` // Peer count by country, if a GeoIP database is configured
+}
+
+// PeerStats returns the aggregate client diversity of the connected peers.
+func (srv *Server) PeerStats() *PeerStats {
+	stats := &PeerStats{
+		Clients:  make(map[string]int),
+		Versions: make(map[string]int),
+		OS:       make(map[string]int),
+	}
+	if srv.geoip != nil {
+		stats.Countries = make(map[string]int)
+	}
+	count := func(m map[string]int, key string) {
+		if key == "" {
+			key = "unknown"
+		}
+		m[key]++
+	}
+	for _, peer := range srv.Peers() {
+		if peer == nil {
+			continue
+		}
+		stats.Peers++
+		if peer.rw.is(inboundConn) {
+			stats.Inbound++
+		}
+		client := ParseClientName(peer.Name())
+		count(stats.Clients, client.Name)
+		count(stats.Versions, client.Name+"/"+shortVersion(client.Version))
+		count(stats.OS, client.OS)
+		if stats.Countries != nil {
+			count(stats.Countries, srv.peerCountry(peer))
+		}
+	}
+	return stats
+}
+
+// peerCountry returns the country code of the peer's remote address, or an
+// empty string if unknown or no GeoIP database is configured.
+func (srv *Server) peerCountry(p *Peer) string {
+	if srv.geoip == nil {
+		return ""
+	}
+	addr, ok := p.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return ""
+	}
+	country, _ := srv.geoip.Country(addr.IP)
+	return country
+}
@@ -0,0 +1,36 @@
+package p2p
+
+import "testing"
+
+func TestParseClientName(t *testing.T) {
+	tests := []struct {
+		name string
+		want ClientInfo
+	}{
+		{"Geth/v1.8.2-stable-b8b9f7f4/linux-amd64/go1.10", ClientInfo{"Geth", "v1.8.2-stable-b8b9f7f4", "linux-amd64", "go1.10"}},
+		{"Geth/my-node/v1.7.3-stable/darwin-amd64/go1.9.2", ClientInfo{"Geth", "v1.7.3-stable", "darwin-amd64", "go1.9.2"}},
+		{"Parity-Ethereum//v2.0.1-beta-e9b8e2d-20180809/x86_64-linux-gnu/rustc1.28.0", ClientInfo{"Parity-Ethereum", "v2.0.1-beta-e9b8e2d-20180809", "x86_64-linux-gnu", "rustc1.28.0"}},
+		{"ethcore/v5.5.0", ClientInfo{"ethcore", "v5.5.0", "", ""}},
+		{"ethereumjs-devp2p", ClientInfo{"ethereumjs-devp2p", "", "", ""}},
+		{"", ClientInfo{}},
+	}
+	for _, tt := range tests {
+		if got := ParseClientName(tt.name); got != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShortVersion(t *testing.T) {
+	tests := map[string]string{
+		"v1.8.2-stable-b8b9f7f4": "v1.8.2",
+		"v4.0.0+build.1":         "v4.0.0",
+		"v1.2.3":                 "v1.2.3",
+		"":                       "",
+	}
+	for version, want := range tests {
+		if got := shortVersion(version); got != want {
+			t.Errorf("%q: got %q, want %q", version, got, want)
+		}
+	}
+}
@@ -52,24 +52,37 @@ const (
 	discWriteTimeout = 1 * time.Second
 )
 
+// malformedMsgError is a read error caused by the remote end sending data that
+// isn't a valid message, rather than by the connection failing.
+type malformedMsgError struct{ err error }
+
+func (e *malformedMsgError) Error() string { return e.err.Error() }
+
 // rlpx is the transport protocol used by actual (non-test) connections.
 // It wraps the frame encoder with locks and read/write deadlines.
 type rlpx struct {
-	fd net.Conn
+	fd          net.Conn
+	readTimeout time.Duration // Time allowed for reading a complete message
 
 	rmu, wmu sync.Mutex
 	rw       *rlpxFrameRW
 }
 
 func newRLPX(fd net.Conn) transport {
-	fd.SetDeadline(time.Now().Add(handshakeTimeout))
-	return &rlpx{fd: fd}
+	return newRLPXTimeouts(fd, handshakeTimeout, frameReadTimeout)
+}
+
+// newRLPXTimeouts creates a transport allowing the given time for both
+// handshakes, and for reading each message afterwards.
+func newRLPXTimeouts(fd net.Conn, handshake, read time.Duration) transport {
+	fd.SetDeadline(time.Now().Add(handshake))
+	return &rlpx{fd: fd, readTimeout: read}
 }
 
 func (t *rlpx) ReadMsg() (Msg, error) {
 	t.rmu.Lock()
 	defer t.rmu.Unlock()
-	t.fd.SetReadDeadline(time.Now().Add(frameReadTimeout))
+	t.fd.SetReadDeadline(time.Now().Add(t.readTimeout))
 	return t.rw.ReadMsg()
 }
 
@@ -608,7 +621,7 @@ func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
 	// verify header mac
 	shouldMAC := updateMAC(rw.ingressMAC, rw.macCipher, headbuf[:16])
 	if !hmac.Equal(shouldMAC, headbuf[16:]) {
-		return msg, errors.New("bad header MAC")
+		return msg, &malformedMsgError{errors.New("bad header MAC")}
 	}
 	rw.dec.XORKeyStream(headbuf[:16], headbuf[:16]) // first half is now decrypted
 	fsize := readInt24(headbuf)
@@ -632,7 +645,7 @@ func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
 	}
 	shouldMAC = updateMAC(rw.ingressMAC, rw.macCipher, fmacseed)
 	if !hmac.Equal(shouldMAC, headbuf[:16]) {
-		return msg, errors.New("bad frame MAC")
+		return msg, &malformedMsgError{errors.New("bad frame MAC")}
 	}
 
 	// decrypt frame content
@@ -641,7 +654,7 @@ func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
 	// decode message code
 	content := bytes.NewReader(framebuf[:fsize])
 	if err := rlp.Decode(content, &msg.Code); err != nil {
-		return msg, err
+		return msg, &malformedMsgError{err}
 	}
 	msg.Size = uint32(content.Len())
 	msg.Payload = content
@@ -257,7 +257,7 @@ func TestProtocolHandshakeErrors(t *testing.T) {
 		p1, p2 := MsgPipe()
 		go Send(p1, test.code, test.msg)
 		_, err := readProtocolHandshake(p2, our)
-		if !reflect.DeepEqual(err, test.err) {
+		if reflect.TypeOf(err) != reflect.TypeOf(test.err) || err.Error() != test.err.Error() {
 			t.Errorf("test %d: error mismatch: got %q, want %q", i, err, test.err)
 		}
 	}
@@ -5,14 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/openether/ethcore/event"
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/metrics"
 	"github.com/openether/ethcore/p2p/discover"
+	"github.com/openether/ethcore/p2p/distip"
+	"github.com/openether/ethcore/p2p/geoip"
 	"github.com/openether/ethcore/p2p/nat"
+	"github.com/openether/ethcore/p2p/ntp"
 )
 
 const (
@@ -29,6 +34,9 @@ const (
 
 	// Maximum amount of time allowed for writing a complete message.
 	frameWriteTimeout = 20 * time.Second
+
+	// Clock drift allowed by default before warning the user.
+	defaultClockDriftThreshold = 10 * time.Second
 )
 
 var errServerStopped = errors.New("server stopped")
@@ -101,6 +109,31 @@ type Config struct {
 
 	// If NoDial is true, the server will not dial any peers.
 	NoDial bool
+
+	// IPMode selects the address families used for listening and for
+	// dialing dynamic peers. The zero value listens dual-stack and dials
+	// any address family.
+	IPMode distip.IPMode
+
+	// ClockCheckInterval is the interval at which the local clock is checked
+	// for drift against an NTP server. Zero disables the periodic checks.
+	ClockCheckInterval time.Duration
+
+	// ClockDriftThreshold is the clock drift beyond which a warning is logged.
+	// Zero defaults to the threshold used by discovery.
+	ClockDriftThreshold time.Duration
+
+	// GeoIPDatabase is the path of an offline MaxMind country or city database
+	// used to tag peers with their country. Empty disables geo tagging.
+	GeoIPDatabase string
+
+	// HandshakeTimeout is the time allowed for both handshakes of a connection,
+	// after which it's dropped. Zero selects the default.
+	HandshakeTimeout time.Duration
+
+	// FrameReadTimeout is the time allowed for reading a complete message, which
+	// bounds how long a connection can stay idle. Zero selects the default.
+	FrameReadTimeout time.Duration
 }
 
 // Server manages all peer connections.
@@ -118,8 +151,13 @@ type Server struct {
 
 	ntab         discoverTable
 	listener     net.Listener
+	natMapper    *nat.Mapper   // keeps the RLPx listener port mapped, nil if NAT is disabled
+	clock        *ntp.Monitor  // periodic clock drift checks, nil if disabled
+	geoip        *geoip.Reader // peer country lookups, nil if disabled
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
+	dialAttempts dialAttempts // recent dial attempts, for DialHistory
+	faults       faultRecords // nodes banned for sending faulty messages
 
 	// These are for Peers, PeerCount (and nothing else).
 	peerOp     chan peerOpFunc
@@ -128,6 +166,7 @@ type Server struct {
 	quit          chan struct{}
 	addstatic     chan *discover.Node
 	removestatic  chan *discover.Node
+	rekeyed       chan discoverTable // discovery table under a new node key
 	posthandshake chan *conn
 	addpeer       chan *conn
 	delpeer       chan peerDrop
@@ -212,6 +251,12 @@ func (c *conn) is(f connFlag) bool {
 	return c.flags&f != 0
 }
 
+// DialHistory returns the most recent attempts to connect to nodes, most recent
+// first, whether they succeeded or not.
+func (srv *Server) DialHistory() []DialAttempt {
+	return srv.dialAttempts.list()
+}
+
 // Peers returns all connected peers.
 func (srv *Server) Peers() []*Peer {
 	var ps []*Peer
@@ -291,6 +336,107 @@ func (srv *Server) Self() *discover.Node {
 	return srv.ntab.Self()
 }
 
+// NATStatus returns the state of all active port mappings, i.e. the RLPx
+// listener and the discovery port. It returns an empty list if NAT traversal
+// is disabled or the server is not running.
+func (srv *Server) NATStatus() []nat.MappingStatus {
+	var status []nat.MappingStatus
+	for _, m := range srv.natMappers() {
+		status = append(status, m.Status())
+	}
+	return status
+}
+
+// RemapNAT forces all active port mappings to be re-added immediately.
+func (srv *Server) RemapNAT() error {
+	mappers := srv.natMappers()
+	if len(mappers) == 0 {
+		return errors.New("no active NAT port mappings")
+	}
+	for _, m := range mappers {
+		if err := m.Remap(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (srv *Server) natMappers() []*nat.Mapper {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if !srv.running {
+		return nil
+	}
+	var mappers []*nat.Mapper
+	if srv.natMapper != nil {
+		mappers = append(mappers, srv.natMapper)
+	}
+	if tab, ok := srv.ntab.(interface {
+		NATMapper() *nat.Mapper
+	}); ok {
+		if m := tab.NATMapper(); m != nil {
+			mappers = append(mappers, m)
+		}
+	}
+	return mappers
+}
+
+// NodeKey returns the private key identifying the server.
+func (srv *Server) NodeKey() *ecdsa.PrivateKey {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	return srv.PrivateKey
+}
+
+// SetNodeKey replaces the private key identifying the server. If the server is
+// running, discovery is restarted under the new identity, on the same port, and
+// all peers are disconnected to handshake again; static and trusted nodes are
+// redialed right away. Discovery remains off if it fails to restart.
+func (srv *Server) SetNodeKey(key *ecdsa.PrivateKey) error {
+	srv.lock.Lock()
+	if !srv.running {
+		srv.PrivateKey = key
+		srv.lock.Unlock()
+		return nil
+	}
+	var err error
+	if srv.ntab != nil {
+		srv.ntab.Close()
+		srv.ntab = nil
+		var ntab *discover.Table
+		if ntab, err = discover.ListenUDPNetwork(srv.IPMode.Network("udp"), key, srv.ListenAddr, srv.NAT, srv.NodeDatabase); err == nil {
+			if err = ntab.SetFallbackNodes(srv.BootstrapNodes); err != nil {
+				ntab.Close()
+			} else {
+				srv.ntab = ntab
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to restart discovery: %v", err)
+		}
+	}
+	srv.PrivateKey = key
+	handshake := *srv.ourHandshake
+	handshake.ID = discover.PubkeyID(&key.PublicKey)
+	srv.ourHandshake = &handshake
+	ntab := srv.ntab
+	srv.lock.Unlock()
+
+	select {
+	case srv.rekeyed <- ntab:
+	case <-srv.quit:
+	}
+	return err
+}
+
+// table returns the discovery table, nil if discovery is off.
+func (srv *Server) table() discoverTable {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	return srv.ntab
+}
+
 // Stop terminates the server and all active peer connections.
 // It blocks until all active connections have been closed.
 func (srv *Server) Stop() {
@@ -306,6 +452,9 @@ func (srv *Server) Stop() {
 	}
 	close(srv.quit)
 	srv.loopWG.Wait()
+	if srv.clock != nil {
+		srv.clock.Stop()
+	}
 }
 
 // Start starts running the server.
@@ -325,22 +474,36 @@ func (srv *Server) Start() (err error) {
 		return fmt.Errorf("Server.PrivateKey must be set to a non-nil key")
 	}
 	if srv.newTransport == nil {
-		srv.newTransport = newRLPX
+		handshake, read := srv.HandshakeTimeout, srv.FrameReadTimeout
+		if handshake == 0 {
+			handshake = handshakeTimeout
+		}
+		if read == 0 {
+			read = frameReadTimeout
+		}
+		srv.newTransport = func(fd net.Conn) transport { return newRLPXTimeouts(fd, handshake, read) }
 	}
 	if srv.Dialer == nil {
 		srv.Dialer = &net.Dialer{Timeout: defaultDialTimeout}
 	}
+	if srv.GeoIPDatabase != "" {
+		if srv.geoip, err = geoip.Open(srv.GeoIPDatabase); err != nil {
+			return fmt.Errorf("failed to open GeoIP database: %v", err)
+		}
+		glog.V(logger.Info).Infof("Loaded %s GeoIP database from %s", srv.geoip.Metadata.DatabaseType, srv.GeoIPDatabase)
+	}
 	srv.quit = make(chan struct{})
 	srv.addpeer = make(chan *conn)
 	srv.delpeer = make(chan peerDrop)
 	srv.posthandshake = make(chan *conn)
 	srv.addstatic = make(chan *discover.Node)
+	srv.rekeyed = make(chan discoverTable)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
 
 	// node table
 	if srv.Discovery {
-		ntab, err := discover.ListenUDP(srv.PrivateKey, srv.ListenAddr, srv.NAT, srv.NodeDatabase)
+		ntab, err := discover.ListenUDPNetwork(srv.IPMode.Network("udp"), srv.PrivateKey, srv.ListenAddr, srv.NAT, srv.NodeDatabase)
 		if err != nil {
 			return err
 		}
@@ -352,6 +515,8 @@ func (srv *Server) Start() (err error) {
 
 	dynPeers := srv.maxDialedConns()
 	dialer := newDialState(srv.StaticNodes, srv.ntab, dynPeers)
+	dialer.ipMode = srv.IPMode
+	dialer.faults = &srv.faults
 
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
@@ -369,6 +534,15 @@ func (srv *Server) Start() (err error) {
 		glog.V(logger.Warn).Warnln("Server will be kind of useless, neither dialing nor listening.")
 	}
 
+	if srv.ClockCheckInterval > 0 {
+		threshold := srv.ClockDriftThreshold
+		if threshold == 0 {
+			threshold = defaultClockDriftThreshold
+		}
+		srv.clock = ntp.NewMonitor(ntp.DefaultServer, srv.ClockCheckInterval, threshold)
+		srv.clock.Start()
+	}
+
 	srv.loopWG.Add(1)
 	go srv.run(dialer)
 	srv.running = true
@@ -377,7 +551,7 @@ func (srv *Server) Start() (err error) {
 
 func (srv *Server) startListening() error {
 	// Launch the TCP listener.
-	listener, err := net.Listen("tcp", srv.ListenAddr)
+	listener, err := net.Listen(srv.IPMode.Network("tcp"), srv.ListenAddr)
 	if err != nil {
 		return err
 	}
@@ -388,9 +562,10 @@ func (srv *Server) startListening() error {
 	go srv.listenLoop()
 	// Map the TCP listening port if NAT is configured.
 	if !laddr.IP.IsLoopback() && srv.NAT != nil {
+		srv.natMapper = nat.NewMapper(srv.NAT, "tcp", laddr.Port, laddr.Port, "ethereum p2p")
 		srv.loopWG.Add(1)
 		go func() {
-			nat.Map(srv.NAT, srv.quit, "tcp", laddr.Port, laddr.Port, "ethereum p2p")
+			srv.natMapper.Run(srv.quit)
 			srv.loopWG.Done()
 		}()
 	}
@@ -475,6 +650,19 @@ running:
 			if p, ok := peers[n.ID]; ok {
 				p.Disconnect(DiscRequested)
 			}
+		case ntab := <-srv.rekeyed:
+			// The node key was replaced. Look up nodes through the new
+			// discovery table and have the peers handshake again, the
+			// static and trusted ones are redialed.
+			glog.V(logger.Detail).Infoln("<-rekeyed")
+			if d, ok := dialstate.(interface {
+				setTable(discoverTable)
+			}); ok {
+				d.setTable(ntab)
+			}
+			for _, p := range peers {
+				p.Disconnect(DiscRequested)
+			}
 		case op := <-srv.peerOp:
 			// This channel is used by Peers and PeerCount.
 			op(peers)
@@ -510,6 +698,7 @@ running:
 			} else {
 				// The handshakes are done and it passed all checks.
 				p := newPeer(c, srv.Protocols)
+				p.faults = &srv.faults
 				go srv.runPeer(p)
 				peers[c.id] = p
 				if p.Inbound() {
@@ -573,6 +762,8 @@ func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*Peer, inboundCo
 		return DiscAlreadyConnected
 	case c.id == srv.Self().ID:
 		return DiscSelf
+	case !c.is(trustedConn|staticDialedConn) && srv.faults.banned(c.id, time.Now()):
+		return DiscProtocolError
 	default:
 		return nil
 	}
@@ -645,55 +836,66 @@ func (srv *Server) listenLoop() {
 
 // setupConn runs the handshakes and attempts to add the connection
 // as a peer. It returns when the connection has been added as a peer
-// or the handshakes have failed.
-func (srv *Server) setupConn(fd net.Conn, flags connFlag, dialDest *discover.Node) {
+// or the handshakes have failed, with the reason they failed.
+func (srv *Server) setupConn(fd net.Conn, flags connFlag, dialDest *discover.Node) error {
 	// Prevent leftover pending conns from entering the handshake.
 	srv.lock.Lock()
-	running := srv.running
+	running, key, ourHandshake := srv.running, srv.PrivateKey, srv.ourHandshake
 	srv.lock.Unlock()
 	c := &conn{fd: fd, transport: srv.newTransport(fd), flags: flags, cont: make(chan error)}
 	if !running {
 		c.close(errServerStopped)
-		return
+		return errServerStopped
 	}
 	// Run the encryption handshake.
 	var err error
-	if c.id, err = c.doEncHandshake(srv.PrivateKey, dialDest); err != nil {
+	if c.id, err = c.doEncHandshake(key, dialDest); err != nil {
+		markHandshakeTimeout(err)
 		glog.V(logger.Debug).Warnf("%v faild enc handshake: %v", c, err)
 		c.close(err)
-		return
+		return err
 	}
 	// For dialed connections, check that the remote public key matches.
 	if dialDest != nil && c.id != dialDest.ID {
 		c.close(DiscUnexpectedIdentity)
 		glog.V(logger.Debug).Warnf("%v dialed identity mismatch, want %x", c, dialDest.ID[:8])
-		return
+		return DiscUnexpectedIdentity
 	}
 	if err := srv.checkpoint(c, srv.posthandshake); err != nil {
 		glog.V(logger.Debug).Warnf("%v failed checkpoint posthandshake: %v", c, err)
 		c.close(err)
-		return
+		return err
 	}
 	// Run the protocol handshake
-	phs, err := c.doProtoHandshake(srv.ourHandshake)
+	phs, err := c.doProtoHandshake(ourHandshake)
 	if err != nil {
+		markHandshakeTimeout(err)
 		glog.V(logger.Debug).Warnf("%v failed proto handshake: %v", c, err)
 		c.close(err)
-		return
+		return err
 	}
 	if phs.ID != c.id {
 		glog.V(logger.Debug).Warnf("%v wrong proto handshake identity: %x", c, phs.ID[:8])
 		c.close(DiscUnexpectedIdentity)
-		return
+		return DiscUnexpectedIdentity
 	}
 	c.caps, c.name = phs.Caps, phs.Name
 	if err := srv.checkpoint(c, srv.addpeer); err != nil {
 		glog.V(logger.Debug).Warnf("%v failed checkpoint addpeer: %v", c, err)
 		c.close(err)
-		return
+		return err
 	}
 	// If the checks completed successfully, runPeer has now been
 	// launched by run.
+	return nil
+}
+
+// markHandshakeTimeout counts handshakes that failed for the remote end not
+// completing them in time.
+func markHandshakeTimeout(err error) {
+	if err, ok := err.(net.Error); ok && err.Timeout() {
+		metrics.P2PHandshakeTimeouts.Mark(1)
+	}
 }
 
 // checkpoint sends the conn to run, which performs the
@@ -775,8 +977,10 @@ type NodeInfo struct {
 		Discovery int `json:"discovery"` // UDP listening port for discovery protocol
 		Listener  int `json:"listener"`  // TCP listening port for RLPx
 	} `json:"ports"`
-	ListenAddr string                 `json:"listenAddr"`
-	Protocols  map[string]interface{} `json:"protocols"`
+	ListenAddr  string                 `json:"listenAddr"`
+	ListenAddrs []string               `json:"listenAddrs"` // All effective listening addresses, including NAT-mapped endpoints
+	Protocols   map[string]interface{} `json:"protocols"`
+	Clock       *ntp.Status            `json:"clock,omitempty"` // Outcome of the last clock drift check, nil if disabled
 }
 
 // Info gathers and returns a collection of metadata known about the host.
@@ -794,6 +998,11 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	}
 	info.Ports.Discovery = int(node.UDP)
 	info.Ports.Listener = int(node.TCP)
+	info.ListenAddrs = srv.listenAddrs(node)
+	if srv.clock != nil {
+		status := srv.clock.Status()
+		info.Clock = &status
+	}
 
 	// Gather all the running protocol infos (only once per protocol type)
 	for _, proto := range srv.Protocols {
@@ -808,13 +1017,51 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	return info
 }
 
+// listenAddrs returns the effective listening addresses of the server: the
+// locally bound RLPx listener, plus the TCP and UDP endpoints advertised to
+// the network (which differ from the local ones if a NAT mapping is active).
+func (srv *Server) listenAddrs(self *discover.Node) []string {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	var addrs []string
+	add := func(network string, ip net.IP, port int) {
+		if ip == nil || port == 0 {
+			return
+		}
+		addr := network + "://" + net.JoinHostPort(ip.String(), strconv.Itoa(port))
+		for _, a := range addrs {
+			if a == addr {
+				return
+			}
+		}
+		addrs = append(addrs, addr)
+	}
+	if !srv.running {
+		return addrs
+	}
+	if srv.listener != nil {
+		laddr := srv.listener.Addr().(*net.TCPAddr)
+		add("tcp", laddr.IP, laddr.Port)
+	}
+	if !self.IP.IsUnspecified() {
+		add("tcp", self.IP, int(self.TCP))
+		if srv.ntab != nil {
+			add("udp", self.IP, int(self.UDP))
+		}
+	}
+	return addrs
+}
+
 // PeersInfo returns an array of metadata objects describing connected peers.
 func (srv *Server) PeersInfo() []*PeerInfo {
 	// Gather all the generic and sub-protocol specific infos
 	infos := make([]*PeerInfo, 0, srv.PeerCount())
 	for _, peer := range srv.Peers() {
 		if peer != nil {
-			infos = append(infos, peer.Info())
+			info := peer.Info()
+			info.Country = srv.peerCountry(peer)
+			infos = append(infos, info)
 		}
 	}
 	// Sort the result array alphabetically by node identifier
@@ -122,6 +122,24 @@ func TestServerListen(t *testing.T) {
 	}
 }
 
+func TestServerNodeInfoListenAddrs(t *testing.T) {
+	srv := startTestServer(t, randomID(), nil)
+	defer srv.Stop()
+
+	info := srv.NodeInfo()
+	want := "tcp://" + srv.ListenAddr
+	if len(info.ListenAddrs) == 0 || info.ListenAddrs[0] != want {
+		t.Fatalf("listen addrs mismatch: got %v, want %s first", info.ListenAddrs, want)
+	}
+	for i, a := range info.ListenAddrs {
+		for _, b := range info.ListenAddrs[i+1:] {
+			if a == b {
+				t.Errorf("duplicate listen addr %s", a)
+			}
+		}
+	}
+}
+
 func TestServerDial(t *testing.T) {
 	// run a one-shot TCP server to handle the connection.
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
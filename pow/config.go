@@ -0,0 +1,38 @@
+package pow
+
+import "fmt"
+
+// Mode selects the proof-of-work a chain is sealed and verified with.
+type Mode int
+
+const (
+	ModeNormal Mode = iota // Full ethash
+	ModeTest               // Ethash with tiny caches and datasets, for tests and private networks
+	ModeShared             // Like ModeTest, with the caches shared by all instances in a process
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeNormal:
+		return "normal"
+	case ModeTest:
+		return "test"
+	case ModeShared:
+		return "shared"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Config configures sealing, replacing combinations of test and shared switches.
+type Config struct {
+	Mode Mode
+}
+
+// Consensus returns the name of the consensus in chain configuration files
+// for blocks sealed under c.
+func (c Config) Consensus() string {
+	if c.Mode == ModeTest || c.Mode == ModeShared {
+		return "ethash-test"
+	}
+	return "ethash"
+}
@@ -0,0 +1,165 @@
+// Copyright 2014 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+)
+
+// ListIterator lazily iterates over the elements of an RLP list read from a
+// Stream. Elements are only decoded when asked for, so lists much larger than
+// the available memory can be processed one element at a time.
+type ListIterator struct {
+	s    *Stream
+	err  error
+	done bool
+}
+
+// Iterate starts iterating over the elements of the list ahead in the stream.
+// The stream must not be used while iterating.
+func (s *Stream) Iterate() (*ListIterator, error) {
+	if _, err := s.List(); err != nil {
+		return nil, err
+	}
+	return &ListIterator{s: s}, nil
+}
+
+// Next reports whether another element is ahead. At the end of the list it
+// returns false, leaving the stream positioned after the list.
+func (it *ListIterator) Next() bool {
+	if it.err != nil || it.done {
+		return false
+	}
+	switch _, _, err := it.s.Kind(); err {
+	case nil:
+		return true
+	case EOL:
+		it.done = true
+		it.err = it.s.ListEnd()
+	default:
+		it.err = err
+	}
+	return false
+}
+
+// Decode decodes the current element into val.
+func (it *ListIterator) Decode(val interface{}) error {
+	return it.s.Decode(val)
+}
+
+// Raw returns the encoding of the current element.
+func (it *ListIterator) Raw() ([]byte, error) {
+	return it.s.Raw()
+}
+
+// Skip moves past the current element without decoding it.
+func (it *ListIterator) Skip() error {
+	return it.s.Skip()
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *ListIterator) Err() error {
+	return it.err
+}
+
+// Skip moves past the value ahead in the stream without decoding or buffering it.
+func (s *Stream) Skip() error {
+	kind, size, err := s.Kind()
+	if err != nil {
+		return err
+	}
+	if kind == Byte {
+		s.kind = -1 // rearm Kind
+		return nil
+	}
+	if err := s.willRead(size); err != nil {
+		return err
+	}
+	if n, err := io.CopyN(ioutil.Discard, s.r, int64(size)); err != nil {
+		if err == io.EOF && uint64(n) < size {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+// StringReader returns a reader of the content of the RLP string ahead in the
+// stream, to process large strings without holding them in memory. The reader
+// must be consumed entirely before using the stream again.
+func (s *Stream) StringReader() (io.Reader, error) {
+	kind, size, err := s.Kind()
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case Byte:
+		s.kind = -1 // rearm Kind
+		return bytes.NewReader([]byte{s.byteval}), nil
+	case String:
+		if err := s.willRead(size); err != nil {
+			return nil, err
+		}
+		return &stringReader{r: s.r, remaining: size}, nil
+	default:
+		return nil, ErrExpectedString
+	}
+}
+
+// stringReader reads the content of a string from the underlying stream input.
+type stringReader struct {
+	r         io.Reader
+	remaining uint64
+}
+
+func (r *stringReader) Read(b []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+	if uint64(len(b)) > r.remaining {
+		b = b[:r.remaining]
+	}
+	n, err := r.r.Read(b)
+	r.remaining -= uint64(n)
+	if err == io.EOF {
+		if r.remaining > 0 {
+			return n, io.ErrUnexpectedEOF
+		}
+		err = nil
+	}
+	return n, err
+}
+
+// WriteListHeader writes the header of a list whose elements, contentSize bytes
+// when encoded, the caller writes after it, eg. using Encode. This allows to
+// stream large lists without buffering their elements.
+func WriteListHeader(w io.Writer, contentSize uint64) error {
+	buf := make([]byte, 9)
+	_, err := w.Write(buf[:puthead(buf, 0xC0, 0xF7, contentSize)])
+	return err
+}
+
+// WriteStringHeader writes the header of a string of the given size, whose
+// content the caller writes after it. Single bytes below 0x80 are their own
+// encoding and must not be written with a header.
+func WriteStringHeader(w io.Writer, size uint64) error {
+	buf := make([]byte, 9)
+	_, err := w.Write(buf[:puthead(buf, 0x80, 0xB7, size)])
+	return err
+}
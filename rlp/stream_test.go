@@ -0,0 +1,125 @@
+// Copyright 2014 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestListIterator(t *testing.T) {
+	enc, _ := EncodeToBytes([]interface{}{uint(1), "foo", []uint{2, 3}, uint(4)})
+	s := NewStream(bytes.NewReader(append(enc, 0x05)), 0)
+
+	it, err := s.Iterate()
+	if err != nil {
+		t.Fatalf("iterate failed: %v", err)
+	}
+	var vals []uint
+	for i := 0; it.Next(); i++ {
+		switch i {
+		case 1:
+			if err := it.Skip(); err != nil {
+				t.Fatalf("skip failed: %v", err)
+			}
+		case 2:
+			raw, err := it.Raw()
+			if err != nil || !bytes.Equal(raw, unhex("C20203")) {
+				t.Fatalf("wrong raw element: %x, %v", raw, err)
+			}
+		default:
+			var v uint
+			if err := it.Decode(&v); err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			vals = append(vals, v)
+		}
+	}
+	if it.Err() != nil {
+		t.Fatalf("iteration failed: %v", it.Err())
+	}
+	if len(vals) != 2 || vals[0] != 1 || vals[1] != 4 {
+		t.Errorf("wrong values: %v", vals)
+	}
+	// The stream is positioned after the list
+	if v, err := s.Uint(); err != nil || v != 5 {
+		t.Errorf("wrong value after list: %d, %v", v, err)
+	}
+	// Iterating something not a list fails
+	if _, err := NewStream(bytes.NewReader(unhex("05")), 0).Iterate(); err != ErrExpectedList {
+		t.Errorf("expected list error, got %v", err)
+	}
+}
+
+func TestStringReader(t *testing.T) {
+	content := bytes.Repeat([]byte{0xAA}, 1000)
+	enc, _ := EncodeToBytes([]interface{}{content, uint(7)})
+	s := NewStream(bytes.NewReader(enc), 0)
+	if _, err := s.List(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.StringReader()
+	if err != nil {
+		t.Fatalf("string reader failed: %v", err)
+	}
+	if got, err := ioutil.ReadAll(r); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("wrong content (%d bytes), %v", len(got), err)
+	}
+	if v, err := s.Uint(); err != nil || v != 7 {
+		t.Errorf("wrong value after string: %d, %v", v, err)
+	}
+	// Truncated input is reported, the input limit is unknown to the stream
+	s = NewStream(io.MultiReader(bytes.NewReader(enc[:500])), 0)
+	if _, err := s.List(); err != nil {
+		t.Fatal(err)
+	}
+	if r, err = s.StringReader(); err != nil {
+		t.Fatalf("string reader failed: %v", err)
+	}
+	if _, err := ioutil.ReadAll(r); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected unexpected EOF for truncated string, got %v", err)
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	elems := []interface{}{bytes.Repeat([]byte{1}, 60), uint(3)}
+	want, _ := EncodeToBytes(elems)
+
+	var content bytes.Buffer
+	for _, elem := range elems {
+		Encode(&content, elem)
+	}
+	var buf bytes.Buffer
+	if err := WriteListHeader(&buf, uint64(content.Len())); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(content.Bytes())
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("wrong list encoding:\ngot  %x\nwant %x", buf.Bytes(), want)
+	}
+
+	str := bytes.Repeat([]byte{2}, 100)
+	want, _ = EncodeToBytes(str)
+	buf.Reset()
+	WriteStringHeader(&buf, uint64(len(str)))
+	buf.Write(str)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("wrong string encoding:\ngot  %x\nwant %x", buf.Bytes(), want)
+	}
+}
@@ -0,0 +1,24 @@
+package rpc
+
+import "time"
+
+// BatchLimits bound the resources a single batch request can take. A zero value
+// disables the respective limit.
+type BatchLimits struct {
+	MaxItems        int           // Requests per batch, larger batches are refused as a whole
+	MaxResponseSize int           // Bytes of the batch response, requests beyond it aren't executed
+	Timeout         time.Duration // Execution time of the batch, requests beyond it aren't executed
+}
+
+// DefaultBatchLimits leave room for the batches of regular clients, the timeout
+// that of the slowest methods limited by DefaultMethodTimeouts.
+var DefaultBatchLimits = BatchLimits{
+	MaxItems:        1000,
+	MaxResponseSize: 25 * 1024 * 1024,
+	Timeout:         5 * time.Minute,
+}
+
+// SetBatchLimits limits the batch requests served after the call.
+func (s *Server) SetBatchLimits(limits BatchLimits) {
+	s.batchLimits = limits
+}
@@ -0,0 +1,148 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// SleepService has a method returning a string of the given length after the
+// given time.
+type SleepService struct{}
+
+func (s *SleepService) Sleep(ms int, length int) string {
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+	return strings.Repeat("x", length)
+}
+
+// batchCall sends a batch of n test_sleep requests to a server with the given
+// limits and returns the raw response.
+func batchCall(t *testing.T, limits BatchLimits, n int, ms int, length int) json.RawMessage {
+	server := NewServer()
+	server.SetBatchLimits(limits)
+	if err := server.RegisterName("test", new(SleepService)); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	batch := make([]map[string]interface{}, n)
+	for i := range batch {
+		batch[i] = map[string]interface{}{"id": i, "method": "test_sleep", "params": []int{ms, length}, "jsonrpc": "2.0"}
+	}
+	go json.NewEncoder(clientConn).Encode(batch)
+
+	var response json.RawMessage
+	if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// batchErrors decodes a batch response, returning the error code of each response
+// or 0 for responses with a result.
+func batchErrors(t *testing.T, raw json.RawMessage) []int {
+	var responses []JSONResponse
+	if err := json.Unmarshal(raw, &responses); err != nil {
+		t.Fatalf("not a batch response: %s", raw)
+	}
+	codes := make([]int, len(responses))
+	for i, r := range responses {
+		if r.Error != nil {
+			codes[i] = r.Error.Code
+		}
+	}
+	return codes
+}
+
+func TestBatchItemLimit(t *testing.T) {
+	raw := batchCall(t, BatchLimits{MaxItems: 3}, 4, 0, 1)
+
+	var response JSONResponse
+	if err := json.Unmarshal(raw, &response); err != nil {
+		t.Fatalf("expected a single error response, got %s", raw)
+	}
+	if response.Error == nil || response.Error.Code != -32600 {
+		t.Errorf("expected invalid request error, got %+v", response.Error)
+	}
+	if codes := batchErrors(t, batchCall(t, BatchLimits{MaxItems: 3}, 3, 0, 1)); len(codes) != 3 || codes[2] != 0 {
+		t.Errorf("batch within the limit not executed: %v", codes)
+	}
+}
+
+func TestBatchResponseSizeLimit(t *testing.T) {
+	codes := batchErrors(t, batchCall(t, BatchLimits{MaxResponseSize: 2500}, 4, 0, 1000))
+	if want := []int{0, 0, -32003, -32003}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("error codes mismatch: have %v, want %v", codes, want)
+	}
+}
+
+func TestBatchTimeout(t *testing.T) {
+	codes := batchErrors(t, batchCall(t, BatchLimits{Timeout: 150 * time.Millisecond}, 4, 100, 1))
+	if want := []int{0, 0, -32002, -32002}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("error codes mismatch: have %v, want %v", codes, want)
+	}
+}
+
+// SubscriptionService has a subscription notifying its first value at once and
+// reporting when it is cancelled.
+type SubscriptionService struct {
+	unsubscribed chan string
+}
+
+func (s *SubscriptionService) Values(ctx context.Context) (Subscription, error) {
+	notifier, _ := NotifierFromContext(ctx)
+	subscription, err := notifier.NewSubscription(func(id string) { s.unsubscribed <- id })
+	if err != nil {
+		return nil, err
+	}
+	subscription.Notify(1)
+	return subscription, nil
+}
+
+// Tests that a subscription whose id is cut from a batch response for its size
+// is cancelled instead of left running without the client knowing it.
+func TestBatchResponseSizeLimitSubscription(t *testing.T) {
+	service := &SubscriptionService{unsubscribed: make(chan string, 1)}
+	server := NewServer()
+	server.SetBatchLimits(BatchLimits{MaxResponseSize: 1000})
+	if err := server.RegisterName("test", new(SleepService)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation|OptionSubscriptions)
+
+	batch := []map[string]interface{}{
+		{"id": 0, "method": "test_sleep", "params": []int{0, 950}, "jsonrpc": "2.0"},
+		{"id": 1, "method": "eth_subscribe", "params": []string{"values"}, "jsonrpc": "2.0"},
+	}
+	go json.NewEncoder(clientConn).Encode(batch)
+
+	in := json.NewDecoder(clientConn)
+	var response json.RawMessage
+	if err := in.Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if codes := batchErrors(t, response); !reflect.DeepEqual(codes, []int{0, -32003}) {
+		t.Fatalf("error codes mismatch: have %v, want [0 -32003]", codes)
+	}
+	select {
+	case <-service.unsubscribed:
+	case <-time.After(time.Second):
+		t.Fatal("subscription not cancelled")
+	}
+	// Its notification is never sent
+	clientConn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if err := in.Decode(&response); err == nil {
+		t.Errorf("notification of a cancelled subscription sent: %s", response)
+	}
+}
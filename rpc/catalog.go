@@ -0,0 +1,183 @@
+package rpc
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// MethodInfo describes a method served by a Server, as found by reflection on
+// the registered services.
+type MethodInfo struct {
+	Name         string    `json:"name"`                   // Method name, or subscription name for subscriptions
+	Params       []*Schema `json:"params"`                 // Schemas of the positional parameters
+	Result       *Schema   `json:"result,omitempty"`       // Schema of the result, nil for methods without one
+	Subscription bool      `json:"subscription,omitempty"` // Subscribed to through <namespace>_subscribe
+}
+
+// Schema describes the JSON encoding of a Go type, after the keywords of JSON
+// Schema. Types encoding themselves are described by the encoding of their zero
+// value, if they can be encoded.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`     // JSON type, empty if unknown
+	GoType               string             `json:"goType,omitempty"`   // Name of named types, e.g. "common.Address"
+	Optional             bool               `json:"optional,omitempty"` // Can be null, or omitted as a trailing parameter
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"` // Values of maps
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Methods returns the methods and subscriptions of the registered services by
+// namespace, sorted by name.
+func (s *Server) Methods() map[string][]MethodInfo {
+	catalog := make(map[string][]MethodInfo, len(s.services))
+	for name, svc := range s.services {
+		methods := make([]MethodInfo, 0, len(svc.callbacks)+len(svc.subscriptions))
+		for mname, callb := range svc.callbacks {
+			methods = append(methods, callb.info(mname))
+		}
+		for sname, callb := range svc.subscriptions {
+			methods = append(methods, callb.info(sname))
+		}
+		sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+		catalog[name] = methods
+	}
+	return catalog
+}
+
+// info describes the callback served under the given name.
+func (c *callback) info(name string) MethodInfo {
+	info := MethodInfo{Name: name, Params: make([]*Schema, len(c.argTypes)), Subscription: c.isSubscribe}
+	for i, t := range c.argTypes {
+		info.Params[i] = typeSchema(t, make(map[reflect.Type]bool))
+	}
+	// Subscriptions return the subscription, their notifications aren't typed
+	if mtype := c.method.Type; !c.isSubscribe && mtype.NumOut() > 0 && c.errPos != 0 {
+		info.Result = typeSchema(mtype.Out(0), make(map[reflect.Type]bool))
+		if isHexNum(mtype.Out(0)) {
+			info.Result.Type = "string" // see CreateResponse
+		}
+	}
+	return info
+}
+
+// typeSchema describes the JSON encoding of t. Struct types already being
+// described further up are only named, to end the recursion of recursive types.
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) *Schema {
+	schema := new(Schema)
+	for t.Kind() == reflect.Ptr {
+		schema.Optional = true
+		t = t.Elem()
+	}
+	if t.Name() != "" && t.PkgPath() != "" {
+		schema.GoType = t.String()
+	}
+	ptr := reflect.PtrTo(t)
+	switch {
+	case ptr.Implements(jsonMarshalerType) || ptr.Implements(textMarshalerType):
+		schema.Type = encodedType(t)
+		return schema
+	case ptr.Implements(textUnmarshalerType):
+		schema.Type = "string"
+		return schema
+	case ptr.Implements(jsonUnmarshalerType):
+		return schema
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		schema.Type = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		schema.Type = "integer"
+	case reflect.Float32, reflect.Float64:
+		schema.Type = "number"
+	case reflect.String:
+		schema.Type = "string"
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			schema.Type = "string" // base64
+			break
+		}
+		schema.Type = "array"
+		schema.Items = typeSchema(t.Elem(), seen)
+	case reflect.Map:
+		schema.Type = "object"
+		schema.AdditionalProperties = typeSchema(t.Elem(), seen)
+	case reflect.Struct:
+		schema.Type = "object"
+		if seen[t] {
+			break
+		}
+		seen[t] = true
+		schema.Properties = make(map[string]*Schema)
+		addProperties(schema.Properties, t, seen)
+		delete(seen, t)
+	}
+	return schema
+}
+
+// addProperties adds the fields of struct type t encoded by encoding/json to
+// props, including the fields of embedded structs.
+func addProperties(props map[string]*Schema, t reflect.Type, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addProperties(props, ft, seen)
+				continue
+			}
+		}
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		props[name] = typeSchema(field.Type, seen)
+	}
+}
+
+// encodedType returns the JSON type of the encoded zero value of t, or an empty
+// string if it can't be encoded.
+func encodedType(t reflect.Type) (typ string) {
+	defer func() {
+		if recover() != nil {
+			typ = ""
+		}
+	}()
+	enc, err := json.Marshal(reflect.New(t).Interface())
+	if err != nil || len(enc) == 0 {
+		return ""
+	}
+	switch enc[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return ""
+	}
+	return "number"
+}
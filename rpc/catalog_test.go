@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestServerMethods(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	methods := make(map[string]MethodInfo)
+	for _, m := range server.Methods()["test"] {
+		methods[m.Name] = m
+	}
+	echo, ok := methods["echo"]
+	if !ok {
+		t.Fatalf("echo missing from %v", methods)
+	}
+	want := []*Schema{
+		{Type: "string"},
+		{Type: "integer"},
+		{Type: "object", GoType: "rpc.Args", Optional: true, Properties: map[string]*Schema{"S": {Type: "string"}}},
+	}
+	if !reflect.DeepEqual(echo.Params, want) {
+		t.Errorf("echo params mismatch: have %+v, want %+v", echo.Params, want)
+	}
+	if echo.Result == nil || echo.Result.GoType != "rpc.Result" || echo.Result.Properties["Args"].Properties["S"] == nil {
+		t.Errorf("echo result mismatch: %+v", echo.Result)
+	}
+	if sub := methods["subscription"]; !sub.Subscription || sub.Result != nil {
+		t.Errorf("subscription mismatch: %+v", sub)
+	}
+	if _, ok := server.Methods()[MetadataApi]; !ok {
+		t.Errorf("metadata API missing")
+	}
+}
+
+type recursive struct {
+	Value *big.Int   `json:"value"`
+	Next  *recursive `json:"next,omitempty"`
+	Hex   HexNumber  `json:"hex"`
+	skip  int
+}
+
+func TestTypeSchema(t *testing.T) {
+	schema := typeSchema(reflect.TypeOf(recursive{}), make(map[reflect.Type]bool))
+	if len(schema.Properties) != 3 {
+		t.Fatalf("properties mismatch: %+v", schema.Properties)
+	}
+	if next := schema.Properties["next"]; next.Type != "object" || !next.Optional || next.Properties != nil {
+		t.Errorf("recursion not ended: %+v", next)
+	}
+	if value := schema.Properties["value"]; value.Type != "number" || value.GoType != "big.Int" {
+		t.Errorf("big.Int schema mismatch: %+v", value)
+	}
+	if hex := schema.Properties["hex"]; hex.Type != "string" {
+		t.Errorf("HexNumber schema mismatch: %+v", hex)
+	}
+}
@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+// connectionCounter hands out connection identifiers.
+var connectionCounter uint64
+
+type connectionKey struct{}
+
+// ConnectionInfo describes the client connection an RPC request was received on.
+type ConnectionInfo struct {
+	ID         uint64 // unique identifier of the served connection
+	RemoteAddr string // address of the client, empty when unknown
+	Stateless  bool   // every request arrives on a new connection (HTTP)
+}
+
+// ConnectionFromContext returns the connection the request in ctx was received on, if any.
+func ConnectionFromContext(ctx context.Context) (ConnectionInfo, bool) {
+	c, ok := ctx.Value(connectionKey{}).(ConnectionInfo)
+	return c, ok
+}
+
+// Owner returns a name for the client to attribute state kept across requests to. Clients
+// are identified by their remote host, whatever the connection they use. Connections
+// without a remote address, eg. IPC, are told apart by their identifier.
+func (c ConnectionInfo) Owner() string {
+	if c.RemoteAddr == "" {
+		return fmt.Sprintf("#%d", c.ID)
+	}
+	if host, _, err := net.SplitHostPort(c.RemoteAddr); err == nil {
+		return host
+	}
+	return c.RemoteAddr
+}
+
+// newConnectionInfo assigns an identifier to a connection served through the given codec.
+func newConnectionInfo(codec ServerCodec, stateless bool) ConnectionInfo {
+	info := ConnectionInfo{ID: atomic.AddUint64(&connectionCounter, 1), Stateless: stateless}
+	if c, ok := codec.(*jsonCodec); ok {
+		if addr, ok := c.rw.(interface {
+			RemoteAddr() string
+		}); ok {
+			info.RemoteAddr = addr.RemoteAddr()
+		}
+	}
+	return info
+}
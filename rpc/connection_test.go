@@ -0,0 +1,61 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConnectionOwner(t *testing.T) {
+	tests := []struct {
+		info ConnectionInfo
+		want string
+	}{
+		{ConnectionInfo{ID: 1, RemoteAddr: "10.0.0.1:5555", Stateless: true}, "10.0.0.1"},
+		{ConnectionInfo{ID: 2, RemoteAddr: "10.0.0.1:5556", Stateless: true}, "10.0.0.1"},
+		{ConnectionInfo{ID: 3, RemoteAddr: "10.0.0.1:5557"}, "10.0.0.1"},
+		{ConnectionInfo{ID: 4, RemoteAddr: "[::1]:5558"}, "::1"},
+		{ConnectionInfo{ID: 5}, "#5"},
+	}
+	for _, tt := range tests {
+		if got := tt.info.Owner(); got != tt.want {
+			t.Errorf("%+v: owner mismatch: got %q, want %q", tt.info, got, tt.want)
+		}
+	}
+}
+
+type ConnectionTestService struct{}
+
+func (s *ConnectionTestService) Owner(ctx context.Context) string {
+	if c, ok := ConnectionFromContext(ctx); ok {
+		return c.Owner()
+	}
+	return ""
+}
+
+func TestConnectionFromContext(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(ConnectionTestService)); err != nil {
+		t.Fatal(err)
+	}
+	client := NewInProcRPCClient(server)
+	defer client.Close()
+
+	owners := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		if err := client.Send(map[string]interface{}{"id": i, "jsonrpc": "2.0", "method": "test_owner", "params": []interface{}{}}); err != nil {
+			t.Fatal(err)
+		}
+		var response JSONResponse
+		if err := client.Recv(&response); err != nil {
+			t.Fatal(err)
+		}
+		owner, _ := response.Result.(string)
+		if owner == "" {
+			t.Fatalf("no connection owner in request context: %v", response.Result)
+		}
+		owners[owner] = true
+	}
+	if len(owners) != 1 {
+		t.Errorf("requests on the same connection have different owners: %v", owners)
+	}
+}
@@ -61,6 +61,7 @@ func (e *invalidMessageError) Error() string {
 // unable to decode supplied params, or an invalid number of parameters
 type invalidParamsError struct {
 	message string
+	data    *InvalidParamsData // Details of the offending parameter, if known
 }
 
 func (e *invalidParamsError) Code() int {
@@ -71,6 +72,52 @@ func (e *invalidParamsError) Error() string {
 	return e.message
 }
 
+// ErrorData implements DataError, reporting the offending parameter.
+func (e *invalidParamsError) ErrorData() interface{} {
+	if e.data == nil {
+		return nil
+	}
+	return e.data
+}
+
+// Reasons a parameter is rejected for, reported in InvalidParamsData.
+const (
+	ParamMissing      = "missing"      // A required parameter is absent or null
+	ParamTooMany      = "tooMany"      // More parameters than the method takes
+	ParamUnknownField = "unknownField" // An object has a field the parameter type doesn't know
+	ParamInvalidType  = "invalidType"  // A value has the wrong JSON type
+	ParamInvalidHex   = "invalidHex"   // A hex string is malformed
+	ParamOutOfRange   = "outOfRange"   // A quantity is negative or too large
+	ParamInvalid      = "invalid"      // A value is otherwise invalid
+)
+
+// InvalidParamsData is the data of invalid params errors, locating the
+// offending parameter and telling why it was rejected.
+type InvalidParamsData struct {
+	Param  int    `json:"param"`           // Position of the parameter
+	Field  string `json:"field,omitempty"` // Offending field within the parameter, if any
+	Reason string `json:"reason"`          // One of the Param* reasons
+}
+
+// paramValueError is returned by the decoders of the argument types of this
+// package, telling why a value was rejected.
+type paramValueError struct {
+	reason  string
+	message string
+}
+
+func (e *paramValueError) Error() string {
+	return e.message
+}
+
+// DataError is implemented by errors returned by RPC methods that carry details
+// for the client, which are sent as the data of the error response. Errors
+// returned by methods can also implement RPCError to set the error code.
+type DataError interface {
+	Error() string
+	ErrorData() interface{}
+}
+
 // logic error, callback returned an error
 type callbackError struct {
 	message string
@@ -84,6 +131,32 @@ func (e *callbackError) Error() string {
 	return e.message
 }
 
+// issued for the requests of a batch left unexecuted because the batch response
+// exceeded its size limit.
+type responseTooLargeError struct {
+}
+
+func (e *responseTooLargeError) Code() int {
+	return -32003
+}
+
+func (e *responseTooLargeError) Error() string {
+	return "batch response too large"
+}
+
+// issued for the requests of a batch left unexecuted because the batch exceeded
+// its execution time limit.
+type batchTimeoutError struct {
+}
+
+func (e *batchTimeoutError) Code() int {
+	return -32002
+}
+
+func (e *batchTimeoutError) Error() string {
+	return "batch timed out"
+}
+
 // issued when a request is received after the server is issued to stop.
 type shutdownError struct {
 }
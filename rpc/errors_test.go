@@ -0,0 +1,46 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+)
+
+type limitError struct{}
+
+func (limitError) Error() string          { return "limit exceeded" }
+func (limitError) Code() int              { return -32005 }
+func (limitError) ErrorData() interface{} { return map[string]string{"cap": "gas"} }
+
+type FailingService struct{}
+
+func (s *FailingService) Fail() error { return limitError{} }
+
+// Tests that the code and data of errors returned by methods are sent to the client.
+func TestMethodErrorCodeAndData(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(FailingService)); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	if err := json.NewEncoder(clientConn).Encode(map[string]interface{}{"id": 1, "method": "test_fail", "version": "2.0"}); err != nil {
+		t.Fatal(err)
+	}
+	var response JSONResponse
+	if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error == nil {
+		t.Fatal("expected error response")
+	}
+	if response.Error.Code != -32005 || response.Error.Message != "limit exceeded" {
+		t.Errorf("error mismatch: have %d %q, want -32005 %q", response.Error.Code, response.Error.Message, "limit exceeded")
+	}
+	if want := map[string]interface{}{"cap": "gas"}; !reflect.DeepEqual(response.Error.Data, want) {
+		t.Errorf("error data mismatch: have %v, want %v", response.Error.Data, want)
+	}
+}
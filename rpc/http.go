@@ -82,6 +82,7 @@ func (client *httpClient) SupportedModules() (map[string]string, error) {
 type httpReadWriteNopCloser struct {
 	io.Reader
 	io.Writer
+	remoteAddr string
 }
 
 // Close does nothing and returns always nil
@@ -89,10 +90,19 @@ func (t *httpReadWriteNopCloser) Close() error {
 	return nil
 }
 
+// RemoteAddr returns the address of the client that sent the request.
+func (t *httpReadWriteNopCloser) RemoteAddr() string {
+	return t.remoteAddr
+}
+
 // newJSONHTTPHandler creates a HTTP handler that will parse incoming JSON requests,
 // send the request to the given API provider and sends the response back to the caller.
 func newJSONHTTPHandler(srv *Server) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == ReadinessPath {
+			serveReadiness(srv, w)
+			return
+		}
 		if r.ContentLength > maxHTTPRequestContentLength {
 			http.Error(w,
 				fmt.Sprintf("content length too large (%d>%d)", r.ContentLength, maxHTTPRequestContentLength),
@@ -105,10 +115,25 @@ func newJSONHTTPHandler(srv *Server) http.HandlerFunc {
 		// create a codec that reads direct from the request body until
 		// EOF and writes the response to w and order the server to process
 		// a single request.
-		codec := NewJSONCodec(&httpReadWriteNopCloser{r.Body, w})
+		codec := NewJSONCodec(&httpReadWriteNopCloser{r.Body, w, r.RemoteAddr})
 		defer codec.Close()
-		srv.ServeSingleRequest(codec, OptionMethodInvocation)
+		srv.serveRequest(r.Context(), codec, true, OptionMethodInvocation)
+	}
+}
+
+// ReadinessPath is the path on which HTTP servers answer GET requests with the
+// readiness of the server: 200 if it is ready, 503 with the reason otherwise.
+const ReadinessPath = "/ready"
+
+func serveReadiness(srv *Server, w http.ResponseWriter) {
+	w.Header().Set("content-type", "text/plain")
+	if srv.readiness != nil {
+		if err := srv.readiness(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
 	}
+	fmt.Fprintln(w, "ok")
 }
 
 // NewHTTPServer creates a new HTTP RPC server around an API provider.
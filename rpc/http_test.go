@@ -0,0 +1,37 @@
+package rpc
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPReadiness(t *testing.T) {
+	var notReady error
+	server := NewServer()
+	server.SetReadiness(func() error { return notReady })
+
+	tests := []struct {
+		err    error
+		status int
+		body   string
+	}{
+		{nil, http.StatusOK, "ok"},
+		{errors.New("head block is stale"), http.StatusServiceUnavailable, "head block is stale"},
+	}
+	for i, tt := range tests {
+		notReady = tt.err
+
+		recorder := httptest.NewRecorder()
+		newJSONHTTPHandler(server)(recorder, httptest.NewRequest("GET", ReadinessPath, nil))
+		if recorder.Code != tt.status {
+			t.Errorf("test %d: status %d, want %d", i, recorder.Code, tt.status)
+		}
+		if body, _ := ioutil.ReadAll(recorder.Body); strings.TrimSpace(string(body)) != tt.body {
+			t.Errorf("test %d: body %q, want %q", i, body, tt.body)
+		}
+	}
+}
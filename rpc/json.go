@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -233,7 +234,7 @@ func parseBatchRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, RPCErro
 // values or an error when the parsing failed.
 func (c *jsonCodec) ParseRequestArguments(argTypes []reflect.Type, params interface{}) ([]reflect.Value, RPCError) {
 	if args, ok := params.(json.RawMessage); !ok {
-		return nil, &invalidParamsError{"Invalid params supplied"}
+		return nil, &invalidParamsError{message: "Invalid params supplied"}
 	} else {
 		return parsePositionalArguments(args, argTypes)
 	}
@@ -241,42 +242,67 @@ func (c *jsonCodec) ParseRequestArguments(argTypes []reflect.Type, params interf
 
 // parsePositionalArguments tries to parse the given args to an array of values with the given types.
 // It returns the parsed values or an error when the args could not be parsed. Missing optional arguments
-// are returned as reflect.Zero values.
+// are returned as reflect.Zero values. Objects with fields unknown to the argument types are rejected.
 func parsePositionalArguments(args json.RawMessage, callbackArgs []reflect.Type) ([]reflect.Value, RPCError) {
-	params := make([]interface{}, 0, len(callbackArgs))
-	for _, t := range callbackArgs {
-		params = append(params, reflect.New(t).Interface())
-	}
-
+	var params []json.RawMessage
 	if err := json.Unmarshal(args, &params); err != nil {
-		return nil, &invalidParamsError{err.Error()}
+		return nil, &invalidParamsError{message: err.Error()}
 	}
-
 	if len(params) > len(callbackArgs) {
-		return nil, &invalidParamsError{fmt.Sprintf("too many params, want %d got %d", len(callbackArgs), len(params))}
-	}
-
-	// assume missing params are null values
-	for i := len(params); i < len(callbackArgs); i++ {
-		params = append(params, nil)
+		return nil, &invalidParamsError{
+			message: fmt.Sprintf("too many params, want %d got %d", len(callbackArgs), len(params)),
+			data:    &InvalidParamsData{Param: len(callbackArgs), Reason: ParamTooMany},
+		}
 	}
-
-	argValues := make([]reflect.Value, len(params))
-	for i, p := range params {
-		// verify that JSON null values are only supplied for optional arguments (ptr types)
-		if p == nil && callbackArgs[i].Kind() != reflect.Ptr {
-			return nil, &invalidParamsError{fmt.Sprintf("invalid or missing value for params[%d]", i)}
+	argValues := make([]reflect.Value, len(callbackArgs))
+	for i, t := range callbackArgs {
+		// verify that missing and JSON null values are only supplied for optional arguments (ptr types)
+		if i >= len(params) || bytes.Equal(bytes.TrimSpace(params[i]), []byte("null")) {
+			if t.Kind() != reflect.Ptr {
+				return nil, &invalidParamsError{
+					message: fmt.Sprintf("invalid or missing value for params[%d]", i),
+					data:    &InvalidParamsData{Param: i, Reason: ParamMissing},
+				}
+			}
+			argValues[i] = reflect.Zero(t)
+			continue
 		}
-		if p == nil {
-			argValues[i] = reflect.Zero(callbackArgs[i])
-		} else { // deref pointers values creates previously with reflect.New
-			argValues[i] = reflect.ValueOf(p).Elem()
+		arg := reflect.New(t)
+		if err := UnmarshalStrict(params[i], arg.Interface()); err != nil {
+			return nil, invalidParam(i, err)
 		}
+		argValues[i] = arg.Elem()
 	}
-
 	return argValues, nil
 }
 
+// UnmarshalStrict is like json.Unmarshal, rejecting objects with fields unknown
+// to v. Argument types decoding themselves use it to reject unknown fields like
+// the decoding of the other arguments does.
+func UnmarshalStrict(input []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// invalidParam converts the error decoding the i'th parameter to an invalid
+// params error, telling which field of the parameter was rejected and why.
+func invalidParam(i int, err error) *invalidParamsError {
+	data := &InvalidParamsData{Param: i, Reason: ParamInvalid}
+	switch err := err.(type) {
+	case *paramValueError:
+		data.Reason = err.reason
+	case *json.UnmarshalTypeError:
+		data.Field, data.Reason = err.Field, ParamInvalidType
+	default:
+		// The decoder reports unknown fields with plain errors only
+		if msg := err.Error(); strings.HasPrefix(msg, `json: unknown field "`) {
+			data.Field, data.Reason = strings.TrimSuffix(strings.TrimPrefix(msg, `json: unknown field "`), `"`), ParamUnknownField
+		}
+	}
+	return &invalidParamsError{message: fmt.Sprintf("invalid params[%d]: %v", i, err), data: data}
+}
+
 // CreateResponse will create a JSON-RPC success response with the given id and reply as result.
 func (c *jsonCodec) CreateResponse(id interface{}, reply interface{}) interface{} {
 	if isHexNum(reflect.TypeOf(reply)) {
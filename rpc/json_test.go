@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -176,3 +177,51 @@ func TestJSONRequestParamsParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestJSONRequestParameterErrors(t *testing.T) {
+	var (
+		intT       = reflect.TypeOf(int(0))
+		argsPtrT   = reflect.TypeOf(&Args{})
+		hexT       = reflect.TypeOf(HexNumber{})
+		blockT     = reflect.TypeOf(BlockNumber(0))
+		hexStructT = reflect.TypeOf(struct {
+			Value *HexNumber `json:"value"`
+		}{})
+	)
+	tests := []struct {
+		input    string
+		argTypes []reflect.Type
+		want     InvalidParamsData
+	}{
+		{`[1]`, []reflect.Type{intT, intT}, InvalidParamsData{Param: 1, Reason: ParamMissing}},
+		{`[1,2]`, []reflect.Type{intT}, InvalidParamsData{Param: 1, Reason: ParamTooMany}},
+		{`[1,{"S":"x","T":"y"}]`, []reflect.Type{intT, argsPtrT}, InvalidParamsData{Param: 1, Field: "T", Reason: ParamUnknownField}},
+		{`[{"S":1}]`, []reflect.Type{argsPtrT}, InvalidParamsData{Param: 0, Field: "S", Reason: ParamInvalidType}},
+		{`["0xzz"]`, []reflect.Type{hexT}, InvalidParamsData{Param: 0, Reason: ParamInvalidHex}},
+		{`["0x"]`, []reflect.Type{hexT}, InvalidParamsData{Param: 0, Reason: ParamInvalidHex}},
+		{`["-1"]`, []reflect.Type{hexT}, InvalidParamsData{Param: 0, Reason: ParamOutOfRange}},
+		{`["0x1` + strings.Repeat("0", 64) + `"]`, []reflect.Type{hexT}, InvalidParamsData{Param: 0, Reason: ParamOutOfRange}},
+		{`[{"value":"abc"}]`, []reflect.Type{hexStructT}, InvalidParamsData{Param: 0, Reason: ParamInvalid}},
+		{`["0x8000000000000000"]`, []reflect.Type{blockT}, InvalidParamsData{Param: 0, Reason: ParamOutOfRange}},
+		{`["newest"]`, []reflect.Type{blockT}, InvalidParamsData{Param: 0, Reason: ParamInvalid}},
+	}
+	codec := jsonCodec{}
+	for i, test := range tests {
+		_, err := codec.ParseRequestArguments(test.argTypes, json.RawMessage(test.input))
+		if err == nil {
+			t.Errorf("test %d: %s accepted", i, test.input)
+			continue
+		}
+		if err.Code() != -32602 {
+			t.Errorf("test %d: error code mismatch: have %d, want -32602", i, err.Code())
+		}
+		data, ok := err.(DataError)
+		if !ok {
+			t.Errorf("test %d: error carries no data", i)
+			continue
+		}
+		if have := data.ErrorData().(*InvalidParamsData); *have != test.want {
+			t.Errorf("test %d: error data mismatch: have %+v, want %+v", i, *have, test.want)
+		}
+	}
+}
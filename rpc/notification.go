@@ -3,11 +3,13 @@ package rpc
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/metrics"
 )
 
 var (
@@ -22,8 +24,53 @@ var (
 
 	// errNotificationQueueFull is returns when there are too many notifications in the queue
 	errNotificationQueueFull = errors.New("too many pending notifications")
+
+	// errSubscriptionBufferFull is returned when a subscription has too many pending notifications
+	// and the slow consumer policy is to disconnect
+	errSubscriptionBufferFull = errors.New("too many pending notifications for subscription")
+)
+
+// SlowConsumerPolicy determines what happens when a subscription has more notifications
+// buffered than its limit allows because the client doesn't read them fast enough.
+type SlowConsumerPolicy int
+
+const (
+	// DisconnectSlowConsumer closes the connection of the slow client.
+	DisconnectSlowConsumer SlowConsumerPolicy = iota
+	// DropOldestNotification drops the oldest buffered notification of the subscription. The
+	// client is told about dropped notifications with a SubscriptionGap notification that
+	// precedes the first notification sent after the gap.
+	DropOldestNotification
 )
 
+// ParseSlowConsumerPolicy parses a policy given as "disconnect" or "drop-oldest".
+func ParseSlowConsumerPolicy(s string) (SlowConsumerPolicy, error) {
+	switch s {
+	case "disconnect":
+		return DisconnectSlowConsumer, nil
+	case "drop-oldest":
+		return DropOldestNotification, nil
+	}
+	return 0, fmt.Errorf("unknown slow consumer policy %q, want \"disconnect\" or \"drop-oldest\"", s)
+}
+
+func (p SlowConsumerPolicy) String() string {
+	switch p {
+	case DisconnectSlowConsumer:
+		return "disconnect"
+	case DropOldestNotification:
+		return "drop-oldest"
+	}
+	return fmt.Sprintf("SlowConsumerPolicy(%d)", int(p))
+}
+
+// SubscriptionGap is sent to a subscriber in place of notifications that were dropped
+// because the subscriber didn't keep up.
+type SubscriptionGap struct {
+	Gap     bool   `json:"gap"`
+	Dropped uint64 `json:"dropped"`
+}
+
 // unsubSignal is a signal that the subscription is unsubscribed. It is used to flush buffered
 // notifications that might be pending in the internal queue.
 var unsubSignal = new(struct{})
@@ -36,6 +83,7 @@ type UnsubscribeCallback func(id string)
 type notification struct {
 	sub  *bufferedSubscription // subscription id
 	data interface{}           // event data
+	gap  uint64                // number of notifications dropped before this one
 }
 
 // A Notifier type describes the interface for objects that can send create subscriptions
@@ -75,6 +123,9 @@ type bufferedSubscription struct {
 	pending          chan interface{}    // closed when active
 	flushed          chan interface{}    // closed when all buffered notifications are send
 	lastNotification time.Time           // last time a notification was send
+	queued           int                 // number of notifications in the notifier queue
+	gap              uint64              // dropped notifications not yet attached to a queued one
+	discarded        bool                // set before pending is closed if never activated
 }
 
 // ID returns the subscription identifier that the client uses to refer to this instance.
@@ -87,6 +138,14 @@ func (s *bufferedSubscription) Cancel() error {
 	return s.notifier.Unsubscribe(s.id)
 }
 
+// unsubscribed calls the unsubscribe callback of the subscription, once. It must
+// not be called while holding the lock of the notifier.
+func (s *bufferedSubscription) unsubscribed() {
+	if s.unsub != nil {
+		s.unsubOnce.Do(func() { s.unsub(s.id) })
+	}
+}
+
 // Notify the subscriber of a particular event.
 func (s *bufferedSubscription) Notify(data interface{}) error {
 	return s.notifier.send(s.id, data)
@@ -94,25 +153,31 @@ func (s *bufferedSubscription) Notify(data interface{}) error {
 
 // bufferedNotifier is a notifier that queues notifications in an internal queue and
 // send them as fast as possible to the client from this queue. It will stop if the
-// queue grows past a given size.
+// queue grows past a given size. Notifications of a single subscription are limited
+// to subBuffer entries, beyond which the slow consumer policy applies.
 type bufferedNotifier struct {
 	codec         ServerCodec                      // underlying connection
 	mu            sync.Mutex                       // guard internal state
 	subscriptions map[string]*bufferedSubscription // keep track of subscriptions associated with codec
 	queueSize     int                              // max number of items in queue
-	queue         chan *notification               // notification queue
+	queue         []*notification                  // notification queue
+	wake          chan struct{}                    // signals the run loop that the queue is not empty
+	subBuffer     int                              // max number of queued items per subscription, 0 for no limit
+	policy        SlowConsumerPolicy               // applied when a subscription exceeds subBuffer
 	stopped       bool                             // indication if this notifier is ordered to stop
 }
 
 // newBufferedNotifier returns a notifier that queues notifications in an internal queue
 // from which notifications are send as fast as possible to the client. If the queue size
 // limit is reached (client is unable to keep up) it will stop and closes the codec.
-func newBufferedNotifier(codec ServerCodec, size int) *bufferedNotifier {
+func newBufferedNotifier(codec ServerCodec, size, subBuffer int, policy SlowConsumerPolicy) *bufferedNotifier {
 	notifier := &bufferedNotifier{
 		codec:         codec,
 		subscriptions: make(map[string]*bufferedSubscription),
-		queue:         make(chan *notification, size),
 		queueSize:     size,
+		wake:          make(chan struct{}, 1),
+		subBuffer:     subBuffer,
+		policy:        policy,
 	}
 
 	go notifier.run()
@@ -176,13 +241,24 @@ func (n *bufferedNotifier) Unsubscribe(subid string) error {
 
 // Send enques the given data for the subscription with public ID on the internal queue. t returns
 // an error when the notifier is stopped or the queue is full. If data is the unsubscribe signal it
-// will remove the subscription with the given id from the subscription collection.
+// will remove the subscription with the given id from the subscription collection and call its
+// unsubscribe callback, after releasing the lock as callbacks may take locks of their own.
 func (n *bufferedNotifier) send(id string, data interface{}) error {
+	unsub, err := n.queueNotification(id, data)
+	if unsub != nil {
+		unsub()
+	}
+	return err
+}
+
+// queueNotification implements send, returning the unsubscribe callback to call once n.mu
+// is released.
+func (n *bufferedNotifier) queueNotification(id string, data interface{}) (func(), error) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
 	if n.stopped {
-		return errNotifierStopped
+		return nil, errNotifierStopped
 	}
 
 	var (
@@ -194,30 +270,92 @@ func (n *bufferedNotifier) send(id string, data interface{}) error {
 	// (subscribe/connection closed)
 	if subscription, found = n.subscriptions[id]; !found {
 		glog.V(logger.Error).Infof("received notification for unknown subscription %s\n", id)
-		return ErrNotificationNotFound
+		return nil, ErrNotificationNotFound
 	}
 
 	// received the unsubscribe signal. Add it to the queue to make sure any pending notifications
 	// for this subscription are send. When the run loop receives this singal it will signal that
 	// all pending subscriptions are flushed and that the confirmation of the unsubscribe can be
 	// send to the user. Remove the subscriptions to make sure new notifications are not accepted.
+	var unsub func()
 	if data == unsubSignal {
 		delete(n.subscriptions, id)
-		if subscription.unsub != nil {
-			subscription.unsubOnce.Do(func() { subscription.unsub(id) })
-		}
+		unsub = subscription.unsubscribed
 	}
 
 	subscription.lastNotification = time.Now()
 
+	if data != unsubSignal && n.subBuffer > 0 && subscription.queued >= n.subBuffer {
+		if n.policy != DropOldestNotification {
+			glog.V(logger.Warn).Infof("too many buffered notifications for subscription %s -> close connection\n", id)
+			metrics.RPCSlowConsumerDisconnects.Mark(1)
+			n.codec.Close()
+			return nil, errSubscriptionBufferFull
+		}
+		n.dropOldest(subscription)
+	}
 	if len(n.queue) >= n.queueSize {
 		glog.V(logger.Warn).Infoln("too many buffered notifications -> close connection")
+		metrics.RPCSlowConsumerDisconnects.Mark(1)
 		n.codec.Close()
-		return errNotificationQueueFull
+		return unsub, errNotificationQueueFull
 	}
 
-	n.queue <- &notification{subscription, data}
-	return nil
+	n.enqueue(&notification{sub: subscription, data: data, gap: subscription.gap})
+	subscription.gap = 0
+	return unsub, nil
+}
+
+// enqueue adds a notification to the queue and wakes up the run loop. The caller must hold n.mu.
+func (n *bufferedNotifier) enqueue(notification *notification) {
+	if notification.data != unsubSignal {
+		notification.sub.queued++
+	}
+	n.queue = append(n.queue, notification)
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dropOldest removes the oldest queued notification of the given subscription. The number of
+// dropped notifications is carried over to the next notification of the subscription, which
+// is either already queued or about to be. The caller must hold n.mu.
+func (n *bufferedNotifier) dropOldest(sub *bufferedSubscription) {
+	var carry uint64
+	for i := 0; i < len(n.queue); i++ {
+		if n.queue[i].sub != sub || n.queue[i].data == unsubSignal {
+			continue
+		}
+		if carry == 0 {
+			carry = n.queue[i].gap + 1
+			n.queue = append(n.queue[:i], n.queue[i+1:]...)
+			sub.queued--
+			metrics.RPCNotificationDrops.Mark(1)
+			i--
+			continue
+		}
+		n.queue[i].gap += carry
+		return
+	}
+	sub.gap += carry
+}
+
+// dequeue takes the next notification from the queue, nil if the queue is empty.
+func (n *bufferedNotifier) dequeue() *notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.queue) == 0 {
+		return nil
+	}
+	notification := n.queue[0]
+	n.queue[0] = nil
+	n.queue = n.queue[1:]
+	if notification.data != unsubSignal {
+		notification.sub.queued--
+	}
+	return notification
 }
 
 // run reads notifications from the internal queue and sends them to the client. In case of an
@@ -225,40 +363,55 @@ func (n *bufferedNotifier) send(id string, data interface{}) error {
 func (n *bufferedNotifier) run() {
 	defer func() {
 		n.mu.Lock()
-		defer n.mu.Unlock()
-
 		n.stopped = true
-		close(n.queue)
 
-		// on exit call unsubscribe callback
-		for id, sub := range n.subscriptions {
-			if sub.unsub != nil {
-				sub.unsubOnce.Do(func() { sub.unsub(id) })
+		// Unsubscriptions still queued won't be flushed anymore, release their waiters
+		for _, notification := range n.queue {
+			if notification.data == unsubSignal {
+				close(notification.sub.flushed)
 			}
+		}
+		n.queue = nil
+
+		var subs []*bufferedSubscription
+		for id, sub := range n.subscriptions {
 			close(sub.flushed)
 			delete(n.subscriptions, id)
+			subs = append(subs, sub)
+		}
+		n.mu.Unlock()
+
+		// on exit call unsubscribe callback
+		for _, sub := range subs {
+			sub.unsubscribed()
 		}
 	}()
 
 	for {
 		select {
-		case notification := <-n.queue:
-			// It can happen that an event is raised before the RPC server was able to send the sub
-			// id to the client. Therefore subscriptions are marked as pending until the sub id was
-			// send. The RPC server will activate the subscription by closing the pending chan.
-			<-notification.sub.pending
+		case <-n.wake:
+			for notification := n.dequeue(); notification != nil; notification = n.dequeue() {
+				// It can happen that an event is raised before the RPC server was able to send the sub
+				// id to the client. Therefore subscriptions are marked as pending until the sub id was
+				// send. The RPC server will activate the subscription by closing the pending chan.
+				<-notification.sub.pending
+				if notification.sub.discarded {
+					continue
+				}
 
-			if notification.data == unsubSignal {
-				// unsubSignal is the last accepted message for this subscription. Raise the signal
-				// that all buffered notifications are sent by closing the flushed channel. This
-				// indicates that the response for the unsubscribe can be send to the client.
-				close(notification.sub.flushed)
-			} else {
-				msg := n.codec.CreateNotification(notification.sub.id, notification.data)
-				if err := n.codec.Write(msg); err != nil {
-					n.codec.Close()
-					// unable to send notification to client, unsubscribe all subscriptions
-					glog.V(logger.Warn).Infof("unable to send notification - %v\n", err)
+				if notification.data == unsubSignal {
+					// unsubSignal is the last accepted message for this subscription. Raise the signal
+					// that all buffered notifications are sent by closing the flushed channel. This
+					// indicates that the response for the unsubscribe can be send to the client.
+					close(notification.sub.flushed)
+					continue
+				}
+				if notification.gap > 0 {
+					if err := n.write(notification.sub.id, &SubscriptionGap{Gap: true, Dropped: notification.gap}); err != nil {
+						return
+					}
+				}
+				if err := n.write(notification.sub.id, notification.data); err != nil {
 					return
 				}
 			}
@@ -269,6 +422,18 @@ func (n *bufferedNotifier) run() {
 	}
 }
 
+// write sends a notification to the client, closing the codec when that fails.
+func (n *bufferedNotifier) write(subid string, data interface{}) error {
+	msg := n.codec.CreateNotification(subid, data)
+	if err := n.codec.Write(msg); err != nil {
+		n.codec.Close()
+		// unable to send notification to client, unsubscribe all subscriptions
+		glog.V(logger.Warn).Infof("unable to send notification - %v\n", err)
+		return err
+	}
+	return nil
+}
+
 // Marks the subscription as active. This will causes the notifications for this subscription to be
 // forwarded to the client.
 func (n *bufferedNotifier) activate(subid string) {
@@ -279,3 +444,26 @@ func (n *bufferedNotifier) activate(subid string) {
 		close(sub.pending)
 	}
 }
+
+// Cancels a subscription which was never activated because its id didn't reach the client. Its
+// queued notifications are dropped instead of sent.
+func (n *bufferedNotifier) discard(subid string) {
+	n.mu.Lock()
+	sub, found := n.subscriptions[subid]
+	if !found {
+		n.mu.Unlock()
+		return
+	}
+	delete(n.subscriptions, subid)
+	for i := 0; i < len(n.queue); i++ {
+		if n.queue[i].sub == sub {
+			n.queue = append(n.queue[:i], n.queue[i+1:]...)
+			i--
+		}
+	}
+	sub.discarded = true
+	close(sub.pending)
+	n.mu.Unlock()
+
+	sub.unsubscribed()
+}
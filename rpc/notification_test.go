@@ -117,3 +117,136 @@ func TestNotifications(t *testing.T) {
 		t.Error("unsubscribe callback not called after closing connection")
 	}
 }
+
+func TestSubscriptionBufferDropOldest(t *testing.T) {
+	n := &bufferedNotifier{
+		subscriptions: make(map[string]*bufferedSubscription),
+		queueSize:     100,
+		wake:          make(chan struct{}, 1),
+		subBuffer:     3,
+		policy:        DropOldestNotification,
+	}
+	slow := &bufferedSubscription{id: "slow"}
+	other := &bufferedSubscription{id: "other"}
+	n.subscriptions[slow.id] = slow
+	n.subscriptions[other.id] = other
+
+	n.send(other.id, -1)
+	for i := 0; i < 5; i++ {
+		if err := n.send(slow.id, i); err != nil {
+			t.Fatalf("notification %d: %v", i, err)
+		}
+	}
+	n.send(other.id, -2)
+
+	want := []struct {
+		sub  *bufferedSubscription
+		data int
+		gap  uint64
+	}{{other, -1, 0}, {slow, 2, 2}, {slow, 3, 0}, {slow, 4, 0}, {other, -2, 0}}
+	if len(n.queue) != len(want) {
+		t.Fatalf("queue length mismatch: got %d, want %d", len(n.queue), len(want))
+	}
+	for i, w := range want {
+		if q := n.queue[i]; q.sub != w.sub || q.data != w.data || q.gap != w.gap {
+			t.Errorf("queue entry %d mismatch: got %s/%v/%d, want %s/%v/%d", i, q.sub.id, q.data, q.gap, w.sub.id, w.data, w.gap)
+		}
+	}
+	if slow.queued != 3 || other.queued != 2 {
+		t.Errorf("queued count mismatch: slow %d, other %d", slow.queued, other.queued)
+	}
+
+	// Dropping the oldest while nothing else is queued carries the gap to the next notification.
+	n.subBuffer = 1
+	for n.dequeue() != nil {
+	}
+	n.send(slow.id, 5)
+	n.send(slow.id, 6)
+	if len(n.queue) != 1 || n.queue[0].data != 6 || n.queue[0].gap != 1 {
+		t.Errorf("unexpected queue after drop: %+v", n.queue[0])
+	}
+}
+
+func TestSubscriptionBufferDisconnect(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	codec := NewJSONCodec(serverConn)
+	n := &bufferedNotifier{
+		codec:         codec,
+		subscriptions: make(map[string]*bufferedSubscription),
+		queueSize:     100,
+		wake:          make(chan struct{}, 1),
+		subBuffer:     2,
+		policy:        DisconnectSlowConsumer,
+	}
+	sub := &bufferedSubscription{id: "slow"}
+	n.subscriptions[sub.id] = sub
+
+	for i := 0; i < 2; i++ {
+		if err := n.send(sub.id, i); err != nil {
+			t.Fatalf("notification %d: %v", i, err)
+		}
+	}
+	if err := n.send(sub.id, 2); err != errSubscriptionBufferFull {
+		t.Fatalf("expected %v, got %v", errSubscriptionBufferFull, err)
+	}
+	select {
+	case <-codec.Closed():
+	case <-time.After(time.Second):
+		t.Error("codec not closed after exceeding the subscription buffer")
+	}
+}
+
+// Tests that a subscriber overflowing its buffer can unsubscribe right away, with
+// an unsubscribe callback re-entering the notifier.
+func TestSubscriptionBufferUnsubscribe(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	n := newBufferedNotifier(NewJSONCodec(serverConn), 100, 1, DisconnectSlowConsumer)
+	other, _ := n.NewSubscription(nil)
+	unsubscribed := make(chan string, 1)
+	slow, _ := n.NewSubscription(func(id string) {
+		other.Notify("unsubscribed")
+		unsubscribed <- id
+	})
+	n.activate(slow.ID())
+	n.activate(other.ID())
+
+	// The client doesn't read: the first notification blocks on the connection,
+	// the second is buffered and the third exceeds the buffer
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; ; i++ {
+			if err := slow.Notify(i); err != nil {
+				done <- slow.Cancel()
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("unsubscribing the slow subscriber blocked")
+	}
+	select {
+	case id := <-unsubscribed:
+		if id != slow.ID() {
+			t.Errorf("unsubscribe callback id mismatch: have %s, want %s", id, slow.ID())
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("unsubscribe callback not called")
+	}
+}
+
+func TestParseSlowConsumerPolicy(t *testing.T) {
+	for _, policy := range []SlowConsumerPolicy{DisconnectSlowConsumer, DropOldestNotification} {
+		if parsed, err := ParseSlowConsumerPolicy(policy.String()); err != nil || parsed != policy {
+			t.Errorf("%v: got %v, %v", policy, parsed, err)
+		}
+	}
+	if _, err := ParseSlowConsumerPolicy("block"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"runtime"
@@ -11,8 +12,10 @@ import (
 
 	"gopkg.in/fatih/set.v0"
 
+	"github.com/openether/ethcore/internal/tracing"
 	"github.com/openether/ethcore/logger"
 	"github.com/openether/ethcore/logger/glog"
+	"github.com/openether/ethcore/metrics"
 )
 
 const (
@@ -53,6 +56,28 @@ func NewServer() *Server {
 	return server
 }
 
+// SetAdmission sets a function deciding whether the methods called on connections
+// served after the call are executed. Methods it returns an error for fail with
+// that error, see methodErrorResponse.
+func (s *Server) SetAdmission(admission func(method string) error) {
+	s.admission = admission
+}
+
+// SetReadiness sets a function reporting whether the server is ready to serve,
+// answered by the readiness endpoint of HTTP servers: it is ready unless the
+// function returns an error.
+func (s *Server) SetReadiness(readiness func() error) {
+	s.readiness = readiness
+}
+
+// SetSubscriptionLimits limits the number of notifications buffered per subscription on
+// connections served after the call. A buffer of 0 only applies the connection wide limit.
+// The policy decides what happens to subscriptions whose client falls behind.
+func (s *Server) SetSubscriptionLimits(buffer int, policy SlowConsumerPolicy) {
+	s.subscriptionBuffer = buffer
+	s.slowConsumerPolicy = policy
+}
+
 // RPCService gives meta information about the server.
 // e.g. gives information about the loaded modules.
 type RPCService struct {
@@ -122,7 +147,12 @@ func (s *Server) RegisterName(name string, rcvr interface{}) error {
 // If singleShot is true it will process a single request, otherwise it will handle
 // requests until the codec returns an error when reading a request (in most cases
 // an EOF). It executes requests in parallel when singleShot is false.
-func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecOption) error {
+//
+// The requests are canceled once the parent context is done or, for multi-shot
+// connections, once reading fails, including at the end of the stream: none of
+// the transports tells a client which only closed its sending side apart from
+// one which disconnected, so the requests of a departed client don't run on.
+func (s *Server) serveRequest(parent context.Context, codec ServerCodec, singleShot bool, options CodecOption) error {
 	var pend sync.WaitGroup
 
 	defer func() {
@@ -139,14 +169,15 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 		return
 	}()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
+	ctx = context.WithValue(ctx, connectionKey{}, newConnectionInfo(codec, singleShot))
 
 	// if the codec supports notification include a notifier that callbacks can use
 	// to send notification to clients. It is thight to the codec/connection. If the
 	// connection is closed the notifier will stop and cancels all active subscriptions.
 	if options&OptionSubscriptions == OptionSubscriptions {
-		ctx = context.WithValue(ctx, notifierKey{}, newBufferedNotifier(codec, notificationBufferSize))
+		ctx = context.WithValue(ctx, notifierKey{}, newBufferedNotifier(codec, notificationBufferSize, s.subscriptionBuffer, s.slowConsumerPolicy))
 	}
 	s.codecsMu.Lock()
 	if atomic.LoadInt32(&s.run) != 1 { // server stopped
@@ -165,7 +196,8 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 				glog.V(logger.Debug).Infof("%v", err)
 				codec.Write(codec.CreateErrorResponse(nil, err))
 			}
-			// Error or end of stream, wait for requests and tear down
+			// Error or end of stream, abandon pending requests and tear down
+			cancel()
 			pend.Wait()
 			return nil
 		}
@@ -214,14 +246,14 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 // stopped. In either case the codec is closed.
 func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	defer codec.Close()
-	s.serveRequest(codec, false, options)
+	s.serveRequest(context.Background(), codec, false, options)
 }
 
 // ServeSingleRequest reads and processes a single RPC request from the given codec. It will not
 // close the codec unless a non-recoverable error has occurred. Note, this method will return after
 // a single request has been processed!
 func (s *Server) ServeSingleRequest(codec ServerCodec, options CodecOption) {
-	s.serveRequest(codec, true, options)
+	s.serveRequest(context.Background(), codec, true, options)
 }
 
 // Stop will stop reading new requests, wait for stopPendingRequestTimeout to allow pending requests to finish,
@@ -254,10 +286,12 @@ func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *ser
 	return reply[0].Interface().(Subscription).ID(), nil
 }
 
-// handle executes a request and returns the response from the callback.
-func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
+// handle executes a request and returns the response from the callback. For
+// subscriptions it also returns a function to call once the response was written
+// or dropped, telling whether the client received the subscription id.
+func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func(sent bool)) {
 	if req.err != nil {
-		return codec.CreateErrorResponse(&req.id, req.err), nil
+		return methodErrorResponse(codec, req.id, req.err), nil
 	}
 
 	if req.isUnsubscribe { // cancel subscription, first param must be the subscription id
@@ -274,7 +308,7 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 
 			return codec.CreateResponse(req.id, true), nil
 		}
-		return codec.CreateErrorResponse(&req.id, &invalidParamsError{"Expected subscription id as first argument"}), nil
+		return codec.CreateErrorResponse(&req.id, &invalidParamsError{message: "Expected subscription id as first argument"}), nil
 	}
 
 	if req.callb.isSubscribe {
@@ -283,10 +317,15 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
 		}
 
-		// active the subscription after the sub id was successful sent to the client
-		activateSub := func() {
+		// active the subscription after the sub id was successful sent to the client,
+		// cancel it if the client never learns the id
+		activateSub := func(sent bool) {
 			notifier, _ := NotifierFromContext(ctx)
-			notifier.(*bufferedNotifier).activate(subid)
+			if sent {
+				notifier.(*bufferedNotifier).activate(subid)
+			} else {
+				notifier.(*bufferedNotifier).discard(subid)
+			}
 		}
 
 		return codec.CreateResponse(req.id, subid), activateSub
@@ -294,14 +333,24 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 
 	// regular RPC call, prepare arguments
 	if len(req.args) != len(req.callb.argTypes) {
-		rpcErr := &invalidParamsError{fmt.Sprintf("%s%s%s expects %d parameters, got %d",
+		rpcErr := &invalidParamsError{message: fmt.Sprintf("%s%s%s expects %d parameters, got %d",
 			req.svcname, serviceMethodSeparator, req.callb.method.Name,
 			len(req.callb.argTypes), len(req.args))}
 		return codec.CreateErrorResponse(&req.id, rpcErr), nil
 	}
 
+	if s.admission != nil {
+		if err := s.admission(req.svcname + serviceMethodSeparator + formatName(req.callb.method.Name)); err != nil {
+			return methodErrorResponse(codec, req.id, err), nil
+		}
+	}
 	arguments := []reflect.Value{req.callb.rcvr}
 	if req.callb.hasCtx {
+		if timeout, ok := s.timeouts[req.svcname+serviceMethodSeparator+formatName(req.callb.method.Name)]; ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
 		arguments = append(arguments, reflect.ValueOf(ctx))
 	}
 	if len(req.args) > 0 {
@@ -309,66 +358,126 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	}
 
 	// execute RPC method and return result
+	span := tracing.StartServer(req.svcname + serviceMethodSeparator + formatName(req.callb.method.Name))
+	span.SetAttribute("rpc.system", "jsonrpc")
+	span.SetAttribute("rpc.service", req.svcname)
+	span.SetAttribute("rpc.method", formatName(req.callb.method.Name))
+
 	reply := req.callb.method.Func.Call(arguments)
 	if len(reply) == 0 {
+		span.End(nil)
 		return codec.CreateResponse(req.id, nil), nil
 	}
 
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
+			span.End(e)
+			return methodErrorResponse(codec, req.id, e), nil
 		}
 	}
+	span.End(nil)
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
 }
 
+// methodErrorResponse creates the response for an error returned by a method, or
+// refusing it or its arguments, with the code and data of the error if it has any.
+func methodErrorResponse(codec ServerCodec, id interface{}, err error) interface{} {
+	var rpcErr RPCError = &callbackError{err.Error()}
+	if ce, ok := err.(RPCError); ok {
+		rpcErr = ce
+	}
+	if de, ok := err.(DataError); ok {
+		return codec.CreateErrorResponseWithInfo(&id, rpcErr, de.ErrorData())
+	}
+	return codec.CreateErrorResponse(&id, rpcErr)
+}
+
 // exec executes the given request and writes the result back using the codec.
 func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest) {
 	var response interface{}
-	var callback func()
+	var callback func(sent bool)
 	if req.err != nil {
-		response = codec.CreateErrorResponse(&req.id, req.err)
+		response = methodErrorResponse(codec, req.id, req.err)
 	} else {
 		response, callback = s.handle(ctx, codec, req)
 	}
 
-	if err := codec.Write(response); err != nil {
+	err := codec.Write(response)
+	if err != nil {
 		glog.V(logger.Error).Infof("%v\n", err)
 		codec.Close()
 	}
 
 	// when request was a subscribe request this allows these subscriptions to be actived
 	if callback != nil {
-		callback()
+		callback(err == nil)
 	}
 }
 
 // execBatch executes the given requests and writes the result back using the codec.
 // It will only write the response back when the last request is processed.
+//
+// Once the batch exceeds its execution time or response size limit, the remaining
+// requests aren't executed but answered with an error.
 func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*serverRequest) {
+	if s.batchLimits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.batchLimits.Timeout)
+		defer cancel()
+	}
 	responses := make([]interface{}, len(requests))
-	var callbacks []func()
+	var (
+		callbacks []func(sent bool)
+		size      int      // Encoded size of the responses so far
+		limitErr  RPCError // Limit exceeded by the batch, if any
+	)
 	for i, req := range requests {
+		if limitErr == nil && s.batchLimits.Timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+			limitErr = &batchTimeoutError{}
+			metrics.RPCBatchTimeouts.Mark(1)
+		}
+		if limitErr != nil {
+			responses[i] = codec.CreateErrorResponse(&req.id, limitErr)
+			continue
+		}
+		var callback func(sent bool)
 		if req.err != nil {
-			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
+			responses[i] = methodErrorResponse(codec, req.id, req.err)
 		} else {
-			var callback func()
-			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
-				callbacks = append(callbacks, callback)
+			responses[i], callback = s.handle(ctx, codec, req)
+		}
+		if s.batchLimits.MaxResponseSize > 0 {
+			// Account the response as encoded, keeping the encoding for the write
+			if enc, err := json.Marshal(responses[i]); err == nil {
+				responses[i] = json.RawMessage(enc)
+				if size += len(enc); size > s.batchLimits.MaxResponseSize {
+					limitErr = &responseTooLargeError{}
+					metrics.RPCBatchTruncations.Mark(1)
+					responses[i] = codec.CreateErrorResponse(&req.id, limitErr)
+
+					// A subscription whose id is replaced by the error is never used
+					if callback != nil {
+						callback(false)
+						callback = nil
+					}
+				}
 			}
 		}
+		if callback != nil {
+			callbacks = append(callbacks, callback)
+		}
 	}
 
-	if err := codec.Write(responses); err != nil {
+	err := codec.Write(responses)
+	if err != nil {
 		glog.V(logger.Error).Infof("%v\n", err)
 		codec.Close()
 	}
 
 	// when request holds one of more subscribe requests this allows these subscriptions to be actived
 	for _, c := range callbacks {
-		c()
+		c(err == nil)
 	}
 }
 
@@ -380,6 +489,12 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, RPCErro
 	if err != nil {
 		return nil, batch, err
 	}
+	if batch && s.batchLimits.MaxItems > 0 && len(reqs) > s.batchLimits.MaxItems {
+		// Refuse the batch with a single error instead of one per request
+		metrics.RPCBatchRefusals.Mark(1)
+		err := &invalidRequestError{fmt.Sprintf("batch of %d requests exceeds the limit of %d", len(reqs), s.batchLimits.MaxItems)}
+		return []*serverRequest{{err: err}}, false, nil
+	}
 
 	requests := make([]*serverRequest, len(reqs))
 
@@ -394,7 +509,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, RPCErro
 			if args, err := codec.ParseRequestArguments(argTypes, r.params); err == nil {
 				requests[i].args = args
 			} else {
-				requests[i].err = &invalidParamsError{err.Error()}
+				requests[i].err = err
 			}
 			continue
 		}
@@ -413,7 +528,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, RPCErro
 					if args, err := codec.ParseRequestArguments(argTypes, r.params); err == nil {
 						requests[i].args = args[1:] // first one is service.method name which isn't an actual argument
 					} else {
-						requests[i].err = &invalidParamsError{err.Error()}
+						requests[i].err = err
 					}
 				}
 			} else {
@@ -428,7 +543,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, RPCErro
 				if args, err := codec.ParseRequestArguments(callb.argTypes, r.params); err == nil {
 					requests[i].args = args
 				} else {
-					requests[i].err = &invalidParamsError{err.Error()}
+					requests[i].err = err
 				}
 			}
 			continue
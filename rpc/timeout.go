@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MethodTimeouts limits the execution time of RPC methods, by full method name
+// (e.g. "eth_call"). The context passed to a method is canceled once its limit
+// is exceeded, methods honouring it abort and return an error.
+type MethodTimeouts map[string]time.Duration
+
+// DefaultMethodTimeouts limits the methods that can execute arbitrary code or
+// scan large parts of the chain.
+var DefaultMethodTimeouts = MethodTimeouts{
+	"eth_call":                 5 * time.Second,
+	"eth_estimateGas":          5 * time.Second,
+	"eth_traceCall":            30 * time.Second,
+	"eth_getLogs":              30 * time.Second,
+	"debug_traceTransaction":   time.Minute,
+	"debug_traceBlockByNumber": 5 * time.Minute,
+	"debug_traceBlockByHash":   5 * time.Minute,
+	"debug_dumpBlock":          time.Minute,
+	"trace_block":              5 * time.Minute,
+	"trace_transaction":        5 * time.Minute,
+	"trace_filter":             5 * time.Minute,
+}
+
+// ParseMethodTimeouts parses a comma separated list of method=duration pairs,
+// such as "eth_call=5s,eth_getLogs=1m", on top of DefaultMethodTimeouts. A zero
+// duration removes the limit of a method.
+func ParseMethodTimeouts(s string) (MethodTimeouts, error) {
+	timeouts := make(MethodTimeouts, len(DefaultMethodTimeouts))
+	for method, timeout := range DefaultMethodTimeouts {
+		timeouts[method] = timeout
+	}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || !strings.Contains(parts[0], serviceMethodSeparator) {
+			return nil, fmt.Errorf("invalid method timeout %q, want method=duration", pair)
+		}
+		timeout, err := time.ParseDuration(parts[1])
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid timeout of method %s: %q", parts[0], parts[1])
+		}
+		if timeout == 0 {
+			delete(timeouts, parts[0])
+		} else {
+			timeouts[parts[0]] = timeout
+		}
+	}
+	return timeouts, nil
+}
+
+// SetMethodTimeouts limits the execution time of the methods served after the call.
+func (s *Server) SetMethodTimeouts(timeouts MethodTimeouts) {
+	s.timeouts = timeouts
+}
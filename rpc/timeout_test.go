@@ -0,0 +1,126 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// BlockingService has a method running until its request is canceled.
+type BlockingService struct {
+	done chan error
+}
+
+func (s *BlockingService) Wait(ctx context.Context) error {
+	<-ctx.Done()
+	s.done <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestMethodTimeout(t *testing.T) {
+	service := &BlockingService{done: make(chan error, 1)}
+	server := NewServer()
+	server.SetMethodTimeouts(MethodTimeouts{"test_wait": 50 * time.Millisecond})
+	if err := server.RegisterName("test", service); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	if err := json.NewEncoder(clientConn).Encode(map[string]interface{}{"id": 1, "method": "test_wait", "version": "2.0"}); err != nil {
+		t.Fatal(err)
+	}
+	var response JSONResponse
+	if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error == nil || response.Error.Message != context.DeadlineExceeded.Error() {
+		t.Errorf("expected timeout error, got %+v", response.Error)
+	}
+	if err := <-service.done; err != context.DeadlineExceeded {
+		t.Errorf("method context error mismatch: have %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// brokenConn is a connection delivering its input and then failing to read.
+type brokenConn struct {
+	in  io.Reader
+	err error
+
+	lock sync.Mutex
+	out  bytes.Buffer
+}
+
+func (c *brokenConn) Read(p []byte) (int, error) {
+	n, err := c.in.Read(p)
+	if err == io.EOF {
+		return n, c.err
+	}
+	return n, err
+}
+
+func (c *brokenConn) Write(p []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.out.Write(p)
+}
+
+func (c *brokenConn) Close() error { return nil }
+
+// Tests that the requests of a client are canceled once reading its connection
+// fails or the connection ends.
+func TestMethodCanceledOnReadError(t *testing.T) {
+	for _, readErr := range []error{errors.New("connection reset"), io.EOF} {
+		service := &BlockingService{done: make(chan error, 1)}
+		server := NewServer()
+		if err := server.RegisterName("test", service); err != nil {
+			t.Fatal(err)
+		}
+		conn := &brokenConn{
+			in:  strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"test_wait"}`),
+			err: readErr,
+		}
+		go server.ServeCodec(NewJSONCodec(conn), OptionMethodInvocation)
+
+		select {
+		case err := <-service.done:
+			if err != context.Canceled {
+				t.Errorf("%v: method context error mismatch: have %v, want %v", readErr, err, context.Canceled)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%v: method not canceled after the connection failed", readErr)
+		}
+	}
+}
+
+func TestParseMethodTimeouts(t *testing.T) {
+	timeouts, err := ParseMethodTimeouts("eth_call=10s, eth_getLogs=0,debug_traceBlock=2m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timeouts["eth_call"] != 10*time.Second || timeouts["debug_traceBlock"] != 2*time.Minute {
+		t.Errorf("timeouts not applied: %v", timeouts)
+	}
+	if _, ok := timeouts["eth_getLogs"]; ok {
+		t.Errorf("zero timeout not removed: %v", timeouts)
+	}
+	if timeouts["eth_estimateGas"] != DefaultMethodTimeouts["eth_estimateGas"] {
+		t.Errorf("default timeout lost: %v", timeouts)
+	}
+	if DefaultMethodTimeouts["eth_call"] != 5*time.Second {
+		t.Errorf("defaults modified: %v", DefaultMethodTimeouts)
+	}
+	for _, invalid := range []string{"eth_call", "call=1s", "eth_call=soon", "eth_call=-1s"} {
+		if _, err := ParseMethodTimeouts(invalid); err == nil {
+			t.Errorf("%q: expected error", invalid)
+		}
+	}
+}
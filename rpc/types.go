@@ -76,6 +76,14 @@ type Server struct {
 	run      int32
 	codecsMu sync.Mutex
 	codecs   *set.Set
+
+	subscriptionBuffer int                // max buffered notifications per subscription, 0 for no limit
+	slowConsumerPolicy SlowConsumerPolicy // applied to subscriptions exceeding subscriptionBuffer
+	batchLimits        BatchLimits        // resource limits of batch requests
+
+	timeouts  MethodTimeouts            // execution time limits of methods, by full method name
+	admission func(method string) error // refuses the execution of methods when returning an error, if set
+	readiness func() error              // reports why the server isn't ready to serve, if set
 }
 
 // rpcRequest represents a raw incoming RPC request
@@ -119,7 +127,9 @@ type ServerCodec interface {
 	Closed() <-chan interface{}
 }
 
-// HexNumber serializes a number to hex format using the "%#x" format
+// HexNumber serializes a number to hex format using the "%#x" format.
+// It is kept for API arguments, which it also accepts in decimal; results are
+// encoded with the common/hexutil types.
 type HexNumber big.Int
 
 // NewHexNumber creates a new hex number instance which will serialize the given val with `%#x` on marshal.
@@ -154,18 +164,35 @@ func NewHexNumber(val interface{}) *HexNumber {
 	return nil
 }
 
+// UnmarshalJSON parses a 0x prefixed hex or a decimal quantity of at most 256 bits.
 func (h *HexNumber) UnmarshalJSON(input []byte) error {
 	length := len(input)
 	if length >= 2 && input[0] == '"' && input[length-1] == '"' {
 		input = input[1 : length-1]
 	}
-
-	hn := (*big.Int)(h)
-	if _, ok := hn.SetString(string(input), 0); ok {
-		return nil
+	n, err := parseQuantity(string(input))
+	if err != nil {
+		return err
 	}
+	(*big.Int)(h).Set(n)
+	return nil
+}
 
-	return fmt.Errorf("Unable to parse number")
+// parseQuantity parses a 0x prefixed hex or a decimal non-negative integer of at
+// most 256 bits.
+func parseQuantity(input string) (*big.Int, error) {
+	n := new(big.Int)
+	if strings.HasPrefix(input, "0x") || strings.HasPrefix(input, "0X") {
+		if _, ok := n.SetString(input[2:], 16); !ok || strings.HasPrefix(input[2:], "-") || strings.HasPrefix(input[2:], "+") {
+			return nil, &paramValueError{ParamInvalidHex, fmt.Sprintf("invalid hex quantity %q", input)}
+		}
+	} else if _, ok := n.SetString(input, 10); !ok || strings.HasPrefix(input, "+") {
+		return nil, &paramValueError{ParamInvalid, fmt.Sprintf("invalid quantity %q", input)}
+	}
+	if n.Sign() < 0 || n.BitLen() > 256 {
+		return nil, &paramValueError{ParamOutOfRange, fmt.Sprintf("quantity %s out of range [0, 2^256)", input)}
+	}
+	return n, nil
 }
 
 // MarshalJSON serialize the hex number instance to a hex representation.
@@ -236,35 +263,30 @@ func (bn *BlockNumber) UnmarshalJSON(data []byte) error {
 		return nil
 	}
 
-	in := new(big.Int)
-	_, ok := in.SetString(input, 0)
-
-	if !ok { // test if user supplied string tag
-		strBlockNumber := input
-		if strBlockNumber == "latest" {
-			*bn = BlockNumber(latestBlockNumber.Int64())
-			return nil
-		}
-
-		if strBlockNumber == "earliest" {
-			*bn = BlockNumber(earliestBlockNumber.Int64())
-			return nil
-		}
-
-		if strBlockNumber == "pending" {
-			*bn = BlockNumber(pendingBlockNumber.Int64())
-			return nil
-		}
-
-		return fmt.Errorf(`invalid blocknumber %s`, data)
+	switch input { // test if user supplied string tag
+	case "latest":
+		*bn = BlockNumber(latestBlockNumber.Int64())
+		return nil
+	case "earliest":
+		*bn = BlockNumber(earliestBlockNumber.Int64())
+		return nil
+	case "pending":
+		*bn = BlockNumber(pendingBlockNumber.Int64())
+		return nil
 	}
 
-	if in.Cmp(earliestBlockNumber) >= 0 && in.Cmp(maxBlockNumber) <= 0 {
+	in, err := parseQuantity(input)
+	if err == nil && in.Cmp(maxBlockNumber) <= 0 {
 		*bn = BlockNumber(in.Int64())
 		return nil
 	}
-
-	return fmt.Errorf("blocknumber not in range [%d, %d]", earliestBlockNumber, maxBlockNumber)
+	if err == nil || err.(*paramValueError).reason == ParamOutOfRange {
+		return &paramValueError{ParamOutOfRange, fmt.Sprintf("blocknumber not in range [%d, %d]", earliestBlockNumber, maxBlockNumber)}
+	}
+	if err.(*paramValueError).reason == ParamInvalid {
+		return &paramValueError{ParamInvalid, fmt.Sprintf("invalid blocknumber %s", data)}
+	}
+	return err
 }
 
 func (bn *BlockNumber) Int64() int64 {
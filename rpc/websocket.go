@@ -34,6 +34,14 @@ func (rw *wsReaderWriterCloser) Close() error {
 	return rw.c.Close()
 }
 
+// RemoteAddr returns the address of the client that opened the websocket connection.
+func (rw *wsReaderWriterCloser) RemoteAddr() string {
+	if req := rw.c.Request(); req != nil {
+		return req.RemoteAddr
+	}
+	return ""
+}
+
 // wsHandshakeValidator returns a handler that verifies the origin during the
 // websocket upgrade process. When a '*' is specified as an allowed origins all
 // connections are accepted.
@@ -73,6 +73,13 @@ func (t *Trie) Prove(key []byte, fromLevel uint, proofDb DatabaseWriter) error {
 	return nil
 }
 
+// Prove constructs a merkle proof for key like Trie.Prove. The key is hashed
+// as for all accesses of the secure trie, so the proof must be verified
+// against the hash of key.
+func (t *SecureTrie) Prove(key []byte, fromLevel uint, proofDb DatabaseWriter) error {
+	return t.trie.Prove(t.hashKey(key), fromLevel, proofDb)
+}
+
 // VerifyProof checks merkle proofs. The given proof must contain the
 // value for key in a trie with the given root hash. VerifyProof
 // returns an error if the proof contains invalid trie nodes or the